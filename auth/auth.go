@@ -1,11 +1,17 @@
 package auth
 
 import (
+	"bufio"
 	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/gophish/gophish/config"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -34,6 +40,145 @@ var ErrEmptyPassword = errors.New("No password provided")
 // than MinPasswordLength
 var ErrPasswordTooShort = fmt.Errorf("Password must be at least %d characters", MinPasswordLength)
 
+// ErrPasswordMissingUpper is thrown when the password policy requires an
+// uppercase letter which the password doesn't have.
+var ErrPasswordMissingUpper = errors.New("Password must contain an uppercase letter")
+
+// ErrPasswordMissingLower is thrown when the password policy requires a
+// lowercase letter which the password doesn't have.
+var ErrPasswordMissingLower = errors.New("Password must contain a lowercase letter")
+
+// ErrPasswordMissingDigit is thrown when the password policy requires a digit
+// which the password doesn't have.
+var ErrPasswordMissingDigit = errors.New("Password must contain a digit")
+
+// ErrPasswordMissingSymbol is thrown when the password policy requires a
+// symbol which the password doesn't have.
+var ErrPasswordMissingSymbol = errors.New("Password must contain a symbol")
+
+// ErrBannedPassword is thrown when a user provides a password which the
+// password policy bans.
+var ErrBannedPassword = errors.New("Password is too common. Please choose a different password")
+
+// ErrPasswordInHistory is thrown when a user attempts to change their
+// password to one of their recent passwords.
+var ErrPasswordInHistory = errors.New("Cannot reuse a recent password")
+
+// PasswordPolicy is the policy the passwords of user accounts must meet.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Banned is the set of banned passwords, in lowercase.
+	Banned      map[string]bool
+	Expiry      time.Duration
+	HistorySize int
+}
+
+// Policy is the password policy passwords are checked against. It only
+// requires MinPasswordLength characters unless a policy is configured.
+var Policy = PasswordPolicy{MinLength: MinPasswordLength}
+
+// NewPasswordPolicy returns the configured password policy, reading the
+// banned passwords from the file at BannedPasswordsPath if one is given.
+func NewPasswordPolicy(c config.AdminPasswordPolicy) (PasswordPolicy, error) {
+	p := PasswordPolicy{
+		MinLength:     c.MinLength,
+		RequireUpper:  c.RequireUpper,
+		RequireLower:  c.RequireLower,
+		RequireDigit:  c.RequireDigit,
+		RequireSymbol: c.RequireSymbol,
+		Banned:        map[string]bool{},
+		Expiry:        time.Duration(c.ExpiryDays) * 24 * time.Hour,
+		HistorySize:   c.HistorySize,
+	}
+	if p.MinLength == 0 {
+		p.MinLength = MinPasswordLength
+	}
+	banned := c.BannedPasswords
+	if c.BannedPasswordsPath != "" {
+		f, err := os.Open(c.BannedPasswordsPath)
+		if err != nil {
+			return p, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			banned = append(banned, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return p, err
+		}
+	}
+	for _, password := range banned {
+		password = strings.TrimSpace(password)
+		if password != "" {
+			p.Banned[strings.ToLower(password)] = true
+		}
+	}
+	return p, nil
+}
+
+// Check ensures the provided password meets the policy.
+func (p PasswordPolicy) Check(password string) error {
+	switch {
+	// Admittedly, empty passwords are a subset of too short passwords, but it
+	// helps to provide a more specific error message
+	case password == "":
+		return ErrEmptyPassword
+	case len(password) < p.MinLength:
+		if p.MinLength == MinPasswordLength {
+			return ErrPasswordTooShort
+		}
+		return fmt.Errorf("Password must be at least %d characters", p.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return ErrPasswordMissingUpper
+	case p.RequireLower && !lower:
+		return ErrPasswordMissingLower
+	case p.RequireDigit && !digit:
+		return ErrPasswordMissingDigit
+	case p.RequireSymbol && !symbol:
+		return ErrPasswordMissingSymbol
+	case p.Banned[strings.ToLower(password)]:
+		return ErrBannedPassword
+	}
+	return nil
+}
+
+// Expired returns whether a password last changed at the given time has
+// expired, in which case the user must change it before continuing.
+func (p PasswordPolicy) Expired(changed time.Time) bool {
+	return p.Expiry > 0 && !changed.IsZero() && time.Since(changed) > p.Expiry
+}
+
+// CheckPasswordHistory returns ErrPasswordInHistory if the password matches
+// any of the provided bcrypt hashes of previous passwords.
+func CheckPasswordHistory(password string, hashes []string) error {
+	for _, hash := range hashes {
+		if ValidatePassword(password, hash) == nil {
+			return ErrPasswordInHistory
+		}
+	}
+	return nil
+}
+
 // GenerateSecureKey returns the hex representation of key generated from n
 // random bytes
 func GenerateSecureKey(n int) string {
@@ -52,21 +197,10 @@ func GeneratePasswordHash(password string) (string, error) {
 	return string(h), nil
 }
 
-// CheckPasswordPolicy ensures the provided password is valid according to our
-// password policy.
-//
-// The current password policy is simply a minimum of 8 characters, though this
-// may change in the future (see #1538).
+// CheckPasswordPolicy ensures the provided password is valid according to the
+// configured password policy.
 func CheckPasswordPolicy(password string) error {
-	switch {
-	// Admittedly, empty passwords are a subset of too short passwords, but it
-	// helps to provide a more specific error message
-	case password == "":
-		return ErrEmptyPassword
-	case len(password) < MinPasswordLength:
-		return ErrPasswordTooShort
-	}
-	return nil
+	return Policy.Check(password)
 }
 
 // ValidatePassword validates that the provided password matches the provided
@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/gophish/gophish/config"
 )
 
 func TestPasswordPolicy(t *testing.T) {
@@ -39,3 +44,75 @@ func TestValidatePasswordChange(t *testing.T) {
 		t.Fatalf("unexpected error received. expected %v got %v", ErrReusedPassword, got)
 	}
 }
+
+func TestConfiguredPasswordPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "banned")
+	if err != nil {
+		t.Fatalf("error creating banned passwords file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("Summer2021!\n\n")
+	f.Close()
+
+	p, err := NewPasswordPolicy(config.AdminPasswordPolicy{
+		MinLength:           10,
+		RequireUpper:        true,
+		RequireLower:        true,
+		RequireDigit:        true,
+		RequireSymbol:       true,
+		BannedPasswords:     []string{"Password123!"},
+		BannedPasswordsPath: f.Name(),
+		ExpiryDays:          30,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating password policy: %v", err)
+	}
+	tests := map[string]error{
+		"":              ErrEmptyPassword,
+		"Short1!":       nil,
+		"all lower 1!":  ErrPasswordMissingUpper,
+		"ALL UPPER 1!":  ErrPasswordMissingLower,
+		"No digits !!":  ErrPasswordMissingDigit,
+		"NoSymbols123":  ErrPasswordMissingSymbol,
+		"pASSWORD123!":  ErrBannedPassword,
+		"sUMMER2021!":   ErrBannedPassword,
+		"Valid pass 1!": nil,
+	}
+	for candidate, expected := range tests {
+		got := p.Check(candidate)
+		if candidate == "Short1!" {
+			if got == nil || got.Error() != "Password must be at least 10 characters" {
+				t.Fatalf("unexpected error received for %q. got %v", candidate, got)
+			}
+			continue
+		}
+		if got != expected {
+			t.Fatalf("unexpected error received for %q. expected %v got %v", candidate, expected, got)
+		}
+	}
+
+	if p.Expired(time.Now().Add(-29 * 24 * time.Hour)) {
+		t.Fatalf("password unexpectedly expired")
+	}
+	if !p.Expired(time.Now().Add(-31 * 24 * time.Hour)) {
+		t.Fatalf("password unexpectedly not expired")
+	}
+	if Policy.Expired(time.Now().Add(-365 * 24 * time.Hour)) {
+		t.Fatalf("password unexpectedly expired without an expiry")
+	}
+}
+
+func TestCheckPasswordHistory(t *testing.T) {
+	hash, err := GeneratePasswordHash("old password")
+	if err != nil {
+		t.Fatalf("unexpected error generating password hash: %v", err)
+	}
+	got := CheckPasswordHistory("old password", []string{hash})
+	if got != ErrPasswordInHistory {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrPasswordInHistory, got)
+	}
+	got = CheckPasswordHistory("new password", []string{hash})
+	if got != nil {
+		t.Fatalf("unexpected error received. expected %v got %v", nil, got)
+	}
+}
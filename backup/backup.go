@@ -0,0 +1,419 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gophish/gophish/config"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// FormatVersion is the version of the archive layout.
+const FormatVersion = 1
+
+// RowsPerFile is the number of rows stored in each table file in the archive,
+// which keeps the memory used by a backup or restore bounded.
+const RowsPerFile = 1000
+
+// AssetsDir is the directory holding the static files served by the phishing
+// server.
+var AssetsDir = "./static/endpoint/"
+
+const (
+	manifestFile = "manifest.json"
+	tablesDir    = "tables/"
+	assetsDir    = "assets/"
+)
+
+// ErrNewerSchema is returned when the backup was made by a newer version of
+// Gophish than the one restoring it.
+var ErrNewerSchema = errors.New("The backup was made with a newer version of Gophish. Upgrade Gophish before restoring it")
+
+// Manifest describes the contents of a backup.
+type Manifest struct {
+	FormatVersion  int       `json:"format_version"`
+	GophishVersion string    `json:"gophish_version"`
+	Database       string    `json:"db_name"`
+	SchemaVersion  int64     `json:"schema_version"`
+	CreatedAt      time.Time `json:"created_at"`
+	Tables         []string  `json:"tables"`
+}
+
+// Summary describes what was restored from a backup.
+type Summary struct {
+	Manifest Manifest         `json:"manifest"`
+	Rows     map[string]int64 `json:"rows"`
+	Assets   int              `json:"assets"`
+}
+
+// tableFile is a batch of rows from a single table.
+type tableFile struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Create writes an encrypted backup of the database and the uploaded assets
+// to w.
+//
+// Sensitive fields such as SMTP passwords are backed up as they're stored, so
+// if encryption at rest is enabled, the same key needs to be configured when
+// the backup is restored. Attachments and assets kept in object storage aren't
+// included, since the bucket is backed up separately.
+func Create(w io.Writer, passphrase string) (Manifest, error) {
+	m := Manifest{
+		FormatVersion:  FormatVersion,
+		GophishVersion: config.Version,
+		Database:       models.DatabaseName(),
+		CreatedAt:      time.Now().UTC(),
+	}
+	var err error
+	m.SchemaVersion, err = models.SchemaVersion()
+	if err != nil {
+		return m, err
+	}
+	m.Tables, err = models.BackupTables()
+	if err != nil {
+		return m, err
+	}
+	ew, err := newEncryptWriter(w, passphrase)
+	if err != nil {
+		return m, err
+	}
+	gw := gzip.NewWriter(ew)
+	tw := tar.NewWriter(gw)
+	err = writeJSON(tw, manifestFile, m)
+	if err != nil {
+		return m, err
+	}
+	for _, table := range m.Tables {
+		err = writeTable(tw, table)
+		if err != nil {
+			return m, err
+		}
+	}
+	err = writeAssets(tw)
+	if err != nil {
+		return m, err
+	}
+	for _, c := range []io.Closer{tw, gw, ew} {
+		err = c.Close()
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+func writeJSON(tw *tar.Writer, name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0600,
+		Size:     int64(len(b)),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+func writeTable(tw *tar.Writer, table string) error {
+	batch := tableFile{}
+	n := 0
+	flush := func() error {
+		n++
+		err := writeJSON(tw, fmt.Sprintf("%s%s/%06d.json", tablesDir, table, n), batch)
+		batch.Rows = nil
+		return err
+	}
+	err := models.DumpTable(table, func(columns []string, values []interface{}) error {
+		batch.Columns = columns
+		row := make([]interface{}, len(values))
+		for i, v := range values {
+			row[i] = encodeValue(v)
+		}
+		batch.Rows = append(batch.Rows, row)
+		if len(batch.Rows) < RowsPerFile {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch.Rows) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+func writeAssets(tw *tar.Writer) error {
+	_, err := os.Stat(AssetsDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(AssetsDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(AssetsDir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = tw.WriteHeader(&tar.Header{
+			Name:     assetsDir + filepath.ToSlash(rel),
+			Typeflag: tar.TypeReg,
+			Mode:     int64(fi.Mode().Perm()),
+			Size:     fi.Size(),
+			ModTime:  fi.ModTime(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// encodeValue converts a value read from the database into one which can be
+// stored as JSON without losing its type.
+func encodeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return map[string]string{"time": t.Format(time.RFC3339Nano)}
+	case []byte:
+		if utf8.Valid(t) {
+			return string(t)
+		}
+		return map[string]string{"base64": base64.StdEncoding.EncodeToString(t)}
+	}
+	return v
+}
+
+// decodeValue reverses encodeValue for a value decoded using
+// json.Decoder.UseNumber.
+func decodeValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	case map[string]interface{}:
+		if s, ok := t["time"].(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+		if s, ok := t["base64"].(string); ok {
+			return base64.StdEncoding.DecodeString(s)
+		}
+		return nil, fmt.Errorf("invalid value %v", t)
+	}
+	return v, nil
+}
+
+// Restore replaces the database and adds the uploaded assets from an
+// encrypted backup. The database is restored within a transaction, so it's
+// left untouched if the backup can't be restored. Existing assets are only
+// overwritten by assets with the same name.
+func Restore(r io.Reader, passphrase string) (Summary, error) {
+	s := Summary{Rows: map[string]int64{}}
+	dr, err := newDecryptReader(r, passphrase)
+	if err != nil {
+		return s, err
+	}
+	gr, err := gzip.NewReader(dr)
+	if err != nil {
+		return s, err
+	}
+	tr := tar.NewReader(gr)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestFile {
+		return s, ErrInvalidArchive
+	}
+	err = json.NewDecoder(tr).Decode(&s.Manifest)
+	if err != nil {
+		return s, ErrInvalidArchive
+	}
+	err = checkManifest(s.Manifest)
+	if err != nil {
+		return s, err
+	}
+	err = os.MkdirAll(AssetsDir, 0755)
+	if err != nil {
+		return s, err
+	}
+	staging, err := ioutil.TempDir(filepath.Dir(filepath.Clean(AssetsDir)), ".restore")
+	if err != nil {
+		return s, err
+	}
+	defer os.RemoveAll(staging)
+	dbr, err := models.BeginDatabaseRestore()
+	if err != nil {
+		return s, err
+	}
+	for {
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			dbr.Rollback()
+			return s, err
+		}
+		switch {
+		case strings.HasPrefix(hdr.Name, tablesDir):
+			err = restoreTable(dbr, tr, hdr.Name, s.Rows)
+		case strings.HasPrefix(hdr.Name, assetsDir):
+			err = stageAsset(staging, tr, hdr)
+			if err == nil {
+				s.Assets++
+			}
+		default:
+			err = fmt.Errorf("unexpected file %s in backup", hdr.Name)
+		}
+		if err != nil {
+			dbr.Rollback()
+			return s, err
+		}
+	}
+	// Read the rest of the archive so that truncation is detected before
+	// anything is committed
+	_, err = io.Copy(ioutil.Discard, gr)
+	if err != nil {
+		dbr.Rollback()
+		return s, err
+	}
+	err = dbr.Commit()
+	if err != nil {
+		return s, err
+	}
+	err = moveAssets(staging, AssetsDir)
+	if err != nil {
+		return s, fmt.Errorf("the database was restored, but the assets couldn't be: %v", err)
+	}
+	log.Infof("Restored backup created at %s with %d assets", s.Manifest.CreatedAt.Format(time.RFC3339), s.Assets)
+	return s, nil
+}
+
+// checkManifest checks that the backup can be restored to this instance.
+// Backups from older versions can be restored, since migrations only add
+// columns and tables.
+func checkManifest(m Manifest) error {
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported backup format version %d", m.FormatVersion)
+	}
+	if m.Database != models.DatabaseName() {
+		return fmt.Errorf("the backup is of a %s database, but this instance uses %s", m.Database, models.DatabaseName())
+	}
+	current, err := models.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if m.SchemaVersion > current {
+		return ErrNewerSchema
+	}
+	return nil
+}
+
+func restoreTable(dbr *models.DatabaseRestore, r io.Reader, name string, rows map[string]int64) error {
+	table := path.Dir(strings.TrimPrefix(name, tablesDir))
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	batch := tableFile{}
+	err := dec.Decode(&batch)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", name, err)
+	}
+	for _, row := range batch.Rows {
+		values := make([]interface{}, len(row))
+		for i, v := range row {
+			values[i], err = decodeValue(v)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", name, err)
+			}
+		}
+		err = dbr.Insert(table, batch.Columns, values)
+		if err != nil {
+			return err
+		}
+		rows[table]++
+	}
+	return nil
+}
+
+// assetPath returns the path of an asset relative to the assets directory,
+// rejecting paths which would escape it.
+func assetPath(name string) (string, error) {
+	rel := path.Clean(strings.TrimPrefix(name, assetsDir))
+	if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("invalid asset path %s", name)
+	}
+	return filepath.FromSlash(rel), nil
+}
+
+func stageAsset(staging string, r io.Reader, hdr *tar.Header) error {
+	if hdr.Typeflag != tar.TypeReg {
+		return fmt.Errorf("unexpected file type for %s", hdr.Name)
+	}
+	rel, err := assetPath(hdr.Name)
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(staging, rel)
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode).Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func moveAssets(staging, dst string) error {
+	return filepath.Walk(staging, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+		return os.Rename(p, target)
+	})
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/models"
+)
+
+func setupTest(t *testing.T) func() {
+	conf := &config.Config{
+		DBName:         "sqlite3",
+		DBPath:         ":memory:",
+		MigrationsPath: "../db/db_sqlite3/migrations/",
+	}
+	err := models.Setup(conf)
+	if err != nil {
+		t.Fatalf("error setting up database: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "gophish-backup")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	AssetsDir = filepath.Join(dir, "endpoint")
+	return func() { os.RemoveAll(dir) }
+}
+
+func TestBackupRestore(t *testing.T) {
+	defer setupTest(t)()
+	err := models.PostPage(&models.Page{Name: "Test Page", HTML: "<html><head></head><body>héllo</body></html>", UserId: 1})
+	if err != nil {
+		t.Fatalf("error creating page: %v", err)
+	}
+	err = os.MkdirAll(filepath.Join(AssetsDir, "images"), 0755)
+	if err != nil {
+		t.Fatalf("error creating assets: %v", err)
+	}
+	logo := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	err = ioutil.WriteFile(filepath.Join(AssetsDir, "images", "logo.png"), logo, 0644)
+	if err != nil {
+		t.Fatalf("error creating assets: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	m, err := Create(buf, "passphrase")
+	if err != nil {
+		t.Fatalf("error creating backup: %v", err)
+	}
+	if m.SchemaVersion == 0 || m.Database != "sqlite3" {
+		t.Fatalf("unexpected manifest: %#v", m)
+	}
+	archive := buf.Bytes()
+
+	// Changes made after the backup are reverted by restoring it
+	pages, _ := models.GetPages(1)
+	models.DeletePage(pages[0].Id, 1)
+	os.RemoveAll(AssetsDir)
+
+	_, err = Restore(bytes.NewReader(archive), "wrong")
+	if err != ErrDecrypt {
+		t.Fatalf("expected %v with the wrong passphrase, got %v", ErrDecrypt, err)
+	}
+	_, err = Restore(bytes.NewReader(archive[:len(archive)-1]), "passphrase")
+	if err == nil {
+		t.Fatalf("expected error restoring a truncated backup")
+	}
+
+	s, err := Restore(bytes.NewReader(archive), "passphrase")
+	if err != nil {
+		t.Fatalf("error restoring backup: %v", err)
+	}
+	if s.Rows["pages"] != 1 || s.Assets != 1 {
+		t.Fatalf("unexpected summary: %#v", s)
+	}
+	pages, err = models.GetPages(1)
+	if err != nil || len(pages) != 1 || pages[0].HTML != "<html><head></head><body>héllo</body></html>" {
+		t.Fatalf("page not restored: %#v %v", pages, err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(AssetsDir, "images", "logo.png"))
+	if err != nil || !bytes.Equal(got, logo) {
+		t.Fatalf("asset not restored: %v %v", got, err)
+	}
+}
+
+func TestCheckManifest(t *testing.T) {
+	defer setupTest(t)()
+	version, err := models.SchemaVersion()
+	if err != nil {
+		t.Fatalf("error getting schema version: %v", err)
+	}
+	m := Manifest{FormatVersion: FormatVersion, Database: "sqlite3", SchemaVersion: version}
+	if err = checkManifest(m); err != nil {
+		t.Fatalf("unexpected error checking manifest: %v", err)
+	}
+	m.SchemaVersion = version - 1
+	if err = checkManifest(m); err != nil {
+		t.Fatalf("unexpected error checking an older manifest: %v", err)
+	}
+	m.SchemaVersion = version + 1
+	if err = checkManifest(m); err != ErrNewerSchema {
+		t.Fatalf("expected %v, got %v", ErrNewerSchema, err)
+	}
+	m.SchemaVersion, m.Database = version, "mysql"
+	if err = checkManifest(m); err == nil {
+		t.Fatalf("expected error restoring a mysql backup")
+	}
+}
+
+func TestAssetPath(t *testing.T) {
+	for _, name := range []string{"assets/../../etc/passwd", "assets//etc/passwd/..", "assets/", "assets/a/../../b"} {
+		if _, err := assetPath(name); err == nil {
+			t.Fatalf("expected error for asset path %s", name)
+		}
+	}
+	p, err := assetPath("assets/images/./logo.png")
+	if err != nil || p != filepath.Join("images", "logo.png") {
+		t.Fatalf("unexpected asset path %s: %v", p, err)
+	}
+}
+
+func TestEncryptionChunks(t *testing.T) {
+	// Sizes around the chunk boundary, where truncation is hardest to detect
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		plain := bytes.Repeat([]byte{'a'}, size)
+		buf := &bytes.Buffer{}
+		ew, err := newEncryptWriter(buf, "passphrase")
+		if err != nil {
+			t.Fatalf("error creating writer: %v", err)
+		}
+		ew.Write(plain)
+		ew.Close()
+		dr, err := newDecryptReader(bytes.NewReader(buf.Bytes()), "passphrase")
+		if err != nil {
+			t.Fatalf("error creating reader: %v", err)
+		}
+		got, err := ioutil.ReadAll(dr)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("unexpected plaintext for size %d: %v", size, err)
+		}
+		if size <= chunkSize {
+			continue
+		}
+		dr, _ = newDecryptReader(bytes.NewReader(buf.Bytes()[:headerSize+chunkSize+16]), "passphrase")
+		_, err = ioutil.ReadAll(dr)
+		if err != ErrDecrypt {
+			t.Fatalf("expected %v reading a truncated archive of size %d, got %v", ErrDecrypt, size, err)
+		}
+	}
+}
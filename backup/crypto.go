@@ -0,0 +1,212 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Archives are encrypted with AES-256-GCM in fixed size chunks, so that large
+// backups can be streamed rather than held in memory. Each chunk's nonce
+// includes its position and whether it's the last chunk, so chunks can't be
+// reordered or truncated without detection.
+const (
+	magic       = "GPBK"
+	formatV1    = 1
+	saltSize    = 16
+	prefixSize  = 7
+	chunkSize   = 64 * 1024
+	scryptN     = 32768
+	scryptR     = 8
+	scryptP     = 1
+	keySize     = 32
+	lastChunk   = 1
+	headerSize  = len(magic) + 1 + saltSize + prefixSize
+	counterSize = 4
+)
+
+// ErrPassphraseRequired is returned when no passphrase is provided.
+var ErrPassphraseRequired = errors.New("A passphrase is required to encrypt the backup")
+
+// ErrInvalidArchive is returned when the archive isn't a Gophish backup.
+var ErrInvalidArchive = errors.New("The file is not a Gophish backup")
+
+// ErrDecrypt is returned when the archive can't be decrypted, either because
+// the passphrase is wrong or the archive has been modified.
+var ErrDecrypt = errors.New("Unable to decrypt the backup. Check the passphrase")
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce for the chunk at the given position.
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, prefixSize+counterSize+1)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = lastChunk
+	}
+	return nonce
+}
+
+// encryptWriter encrypts everything written to it. Close must be called to
+// write the final chunk.
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+func newEncryptWriter(w io.Writer, passphrase string) (*encryptWriter, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[len(magic)] = formatV1
+	_, err := io.ReadFull(rand.Reader, header[len(magic)+1:])
+	if err != nil {
+		return nil, err
+	}
+	salt := header[len(magic)+1 : len(magic)+1+saltSize]
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: header[len(magic)+1+saltSize:],
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// A full chunk is only written once more data arrives, since the
+		// final chunk has to be marked as such.
+		if len(e.buf) == chunkSize {
+			err := e.seal(false)
+			if err != nil {
+				return n, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	nonce := chunkNonce(e.prefix, e.counter, last)
+	_, err := e.w.Write(e.aead.Seal(nil, nonce, e.buf, nil))
+	if err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Close writes the final chunk. It doesn't close the underlying writer.
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+// decryptReader decrypts an archive written by an encryptWriter.
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	done    bool
+}
+
+func newDecryptReader(r io.Reader, passphrase string) (*decryptReader, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	header := make([]byte, headerSize)
+	_, err := io.ReadFull(r, header)
+	if err != nil || string(header[:len(magic)]) != magic {
+		return nil, ErrInvalidArchive
+	}
+	if header[len(magic)] != formatV1 {
+		return nil, ErrInvalidArchive
+	}
+	aead, err := newAEAD(passphrase, header[len(magic)+1:len(magic)+1+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReaderSize(r, chunkSize+aead.Overhead()+1),
+		aead:   aead,
+		prefix: header[len(magic)+1+saltSize:],
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		err := d.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	sealed := make([]byte, chunkSize+d.aead.Overhead())
+	n, err := io.ReadFull(d.r, sealed)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err == io.EOF:
+		// The final chunk is always written, so the archive was truncated
+		return ErrDecrypt
+	case err != nil:
+		return err
+	default:
+		_, err = d.r.Peek(1)
+		last = err == io.EOF
+	}
+	nonce := chunkNonce(d.prefix, d.counter, last)
+	d.buf, err = d.aead.Open(sealed[:0], nonce, sealed[:n], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.counter++
+	d.done = last
+	return nil
+}
@@ -0,0 +1,335 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/auth"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/secrets"
+	"github.com/jinzhu/gorm"
+	"sigs.k8s.io/yaml"
+)
+
+// The environment variables which provide the bootstrap configuration. The
+// configuration itself takes precedence over a path to it, which takes
+// precedence over the bootstrap_path set in config.json.
+const (
+	EnvConfig = "GOPHISH_BOOTSTRAP"
+	EnvPath   = "GOPHISH_BOOTSTRAP_PATH"
+)
+
+// The supported formats for the bootstrap configuration
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// envReference matches references to environment variables, such as
+// ${SMTP_PASSWORD}, which are replaced in the parsed configuration.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Config declares the users, sending profiles, and webhooks which should
+// exist when Gophish starts.
+//
+// By default, only missing items are created, so that changes made after
+// Gophish has started are kept. If Overwrite is set, existing items are
+// updated to match the configuration each time Gophish starts.
+type Config struct {
+	Overwrite       bool             `json:"overwrite"`
+	Users           []User           `json:"users"`
+	SendingProfiles []SendingProfile `json:"sending_profiles"`
+	Webhooks        []models.Webhook `json:"webhooks"`
+}
+
+// User declares a user account. The password and API key may be secret
+// references, such as env://ADMIN_PASSWORD. If no API key is given, one is
+// generated. Users without a password can only use the API.
+type User struct {
+	Username               string `json:"username"`
+	Password               string `json:"password"`
+	APIKey                 string `json:"api_key"`
+	Role                   string `json:"role"`
+	PasswordChangeRequired bool   `json:"password_change_required"`
+}
+
+// SendingProfile declares a sending profile, which is owned by the given
+// user. If no owner is given, the sending profile is owned by the default
+// admin user.
+type SendingProfile struct {
+	Owner string `json:"owner"`
+	models.SMTP
+}
+
+// Parse parses a bootstrap configuration in the given format. Unknown fields
+// are rejected so that typos don't go unnoticed. References to environment
+// variables are then replaced in the parsed values, so that they are used
+// as-is rather than being interpreted as part of the configuration.
+func Parse(b []byte, format string) (*Config, error) {
+	c := &Config{}
+	var err error
+	if format == FormatYAML {
+		err = yaml.UnmarshalStrict(b, c)
+	} else {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: %v", err)
+	}
+	var missing []string
+	expandEnv(reflect.ValueOf(c).Elem(), &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("bootstrap: environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return c, nil
+}
+
+// expandEnv replaces the references to environment variables in the string
+// values within v, recording the names of any which aren't set.
+func expandEnv(v reflect.Value, missing *[]string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			expandEnv(v.Elem(), missing)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				expandEnv(v.Field(i), missing)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			expandEnv(v.Index(i), missing)
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return
+		}
+		v.SetString(envReference.ReplaceAllStringFunc(v.String(), func(ref string) string {
+			name := envReference.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				*missing = append(*missing, name)
+			}
+			return value
+		}))
+	}
+}
+
+// Load reads the bootstrap configuration from the given path. Files with a
+// .json extension are parsed as JSON, and anything else as YAML.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := FormatYAML
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = FormatJSON
+	}
+	return Parse(b, format)
+}
+
+// detectFormat returns the format of a configuration given without a
+// filename. JSON configurations are objects, which YAML ones can't start
+// with.
+func detectFormat(b []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Setup applies the bootstrap configuration from the environment, or from the
+// given path if the environment doesn't provide one. Nothing is done if no
+// configuration is provided.
+func Setup(path string) error {
+	if env := os.Getenv(EnvConfig); env != "" {
+		c, err := Parse([]byte(env), detectFormat([]byte(env)))
+		if err != nil {
+			return err
+		}
+		return Apply(c)
+	}
+	if env := os.Getenv(EnvPath); env != "" {
+		path = env
+	}
+	if path == "" {
+		return nil
+	}
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+	return Apply(c)
+}
+
+// Apply creates the items declared in the configuration, updating existing
+// items if configured to do so.
+func Apply(c *Config) error {
+	for _, u := range c.Users {
+		err := applyUser(u, c.Overwrite)
+		if err != nil {
+			return fmt.Errorf("bootstrap: user %s: %v", u.Username, err)
+		}
+	}
+	for _, s := range c.SendingProfiles {
+		err := applySendingProfile(s, c.Overwrite)
+		if err != nil {
+			return fmt.Errorf("bootstrap: sending profile %s: %v", s.Name, err)
+		}
+	}
+	for _, wh := range c.Webhooks {
+		err := applyWebhook(wh, c.Overwrite)
+		if err != nil {
+			return fmt.Errorf("bootstrap: webhook %s: %v", wh.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyUser(bu User, overwrite bool) error {
+	if bu.Username == "" {
+		return fmt.Errorf("username not specified")
+	}
+	if bu.Role == "" {
+		bu.Role = models.RoleUser
+	}
+	role, err := models.GetRoleBySlug(bu.Role)
+	if err != nil {
+		return fmt.Errorf("invalid role %s", bu.Role)
+	}
+	u, err := models.GetUserByUsername(bu.Username)
+	created := err == gorm.ErrRecordNotFound
+	if err != nil && !created {
+		return err
+	}
+	// Accounts which haven't been used yet, such as the default admin
+	// account, are always brought in line with the configuration.
+	if !created && !overwrite && !u.LastLogin.IsZero() {
+		return nil
+	}
+	if !created && u.Role.Slug == models.RoleAdmin && role.Slug != models.RoleAdmin {
+		err = models.EnsureEnoughAdmins()
+		if err != nil {
+			return err
+		}
+	}
+	u.Username = bu.Username
+	u.Role = role
+	u.RoleID = role.ID
+	password, err := secrets.Resolve(bu.Password)
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		err = auth.CheckPasswordPolicy(password)
+		if err != nil {
+			return err
+		}
+		u.Hash, err = auth.GeneratePasswordHash(password)
+		if err != nil {
+			return err
+		}
+		u.PasswordChangeRequired = bu.PasswordChangeRequired
+	}
+	apiKey, err := secrets.Resolve(bu.APIKey)
+	if err != nil {
+		return err
+	}
+	if apiKey != "" {
+		existing, err := models.GetUserByAPIKey(apiKey)
+		if err == nil && existing.Id != u.Id {
+			return fmt.Errorf("API key is already in use")
+		}
+		u.ApiKey = models.EncryptedString(apiKey)
+	}
+	if u.ApiKey == "" {
+		u.ApiKey = models.EncryptedString(auth.GenerateSecureKey(auth.APIKeyLength))
+	}
+	err = models.PutUser(&u)
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Infof("Created user %s from the bootstrap configuration", u.Username)
+	} else {
+		log.Infof("Updated user %s from the bootstrap configuration", u.Username)
+	}
+	return nil
+}
+
+func applySendingProfile(bs SendingProfile, overwrite bool) error {
+	if bs.Owner == "" {
+		bs.Owner = models.DefaultAdminUsername
+	}
+	owner, err := models.GetUserByUsername(bs.Owner)
+	if err != nil {
+		return fmt.Errorf("owner %s not found", bs.Owner)
+	}
+	s := bs.SMTP
+	existing, err := models.GetSMTPForUpsert(s, owner.Id)
+	created := err == gorm.ErrRecordNotFound
+	if err != nil && !created {
+		return err
+	}
+	if !created && !overwrite {
+		return nil
+	}
+	s.Id = existing.Id
+	s.UserId = owner.Id
+	s.ModifiedDate = time.Now().UTC()
+	if created {
+		err = models.PostSMTP(&s)
+	} else {
+		err = models.PutSMTP(&s)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Saved sending profile %s from the bootstrap configuration", s.Name)
+	return nil
+}
+
+func applyWebhook(wh models.Webhook, overwrite bool) error {
+	whs, err := models.GetWebhooks()
+	if err != nil {
+		return err
+	}
+	wh.Id = 0
+	for _, existing := range whs {
+		if existing.Name == wh.Name {
+			if !overwrite {
+				return nil
+			}
+			wh.Id = existing.Id
+			break
+		}
+	}
+	wh.Secret, err = secrets.Resolve(wh.Secret)
+	if err != nil {
+		return err
+	}
+	if wh.Id == 0 {
+		err = models.PostWebhook(&wh)
+	} else {
+		err = models.PutWebhook(&wh)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Saved webhook %s from the bootstrap configuration", wh.Name)
+	return nil
+}
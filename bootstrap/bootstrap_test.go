@@ -0,0 +1,213 @@
+package bootstrap
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gophish/gophish/auth"
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/models"
+)
+
+func setupTest(t *testing.T) {
+	conf := &config.Config{
+		DBName:         "sqlite3",
+		DBPath:         ":memory:",
+		MigrationsPath: "../db/db_sqlite3/migrations/",
+	}
+	err := models.Setup(conf)
+	if err != nil {
+		t.Fatalf("error setting up database: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	os.Setenv("BOOTSTRAP_TEST_PASSWORD", "from the environment")
+	defer os.Unsetenv("BOOTSTRAP_TEST_PASSWORD")
+	yaml := `
+users:
+  - username: alice
+    password: ${BOOTSTRAP_TEST_PASSWORD}
+`
+	json := `{"users": [{"username": "alice", "password": "${BOOTSTRAP_TEST_PASSWORD}"}]}`
+	for _, doc := range []string{yaml, json} {
+		c, err := Parse([]byte(doc), detectFormat([]byte(doc)))
+		if err != nil {
+			t.Fatalf("error parsing configuration: %v", err)
+		}
+		if len(c.Users) != 1 || c.Users[0].Password != "from the environment" {
+			t.Fatalf("unexpected users: %#v", c.Users)
+		}
+	}
+	_, err := Parse([]byte("users:\n  - usrname: alice"), FormatYAML)
+	if err == nil {
+		t.Fatalf("expected an error parsing an unknown field")
+	}
+	_, err = Parse([]byte("users:\n  - password: ${BOOTSTRAP_TEST_MISSING}"), FormatYAML)
+	if err == nil {
+		t.Fatalf("expected an error referencing an unset environment variable")
+	}
+}
+
+func TestParseEnvValues(t *testing.T) {
+	values := []string{
+		`pass"word`,
+		`x", "role": "admin`,
+		"x\nrole: admin",
+		"secret #1",
+		"123456",
+		"true",
+	}
+	yaml := `
+users:
+  - username: alice
+    password: ${BOOTSTRAP_TEST_PASSWORD}
+`
+	json := `{"users": [{"username": "alice", "password": "${BOOTSTRAP_TEST_PASSWORD}"}]}`
+	for _, value := range values {
+		os.Setenv("BOOTSTRAP_TEST_PASSWORD", value)
+		for _, doc := range []string{yaml, json} {
+			c, err := Parse([]byte(doc), detectFormat([]byte(doc)))
+			if err != nil {
+				t.Fatalf("error parsing configuration with %q: %v", value, err)
+			}
+			expected := []User{{Username: "alice", Password: value}}
+			if !reflect.DeepEqual(c.Users, expected) {
+				t.Fatalf("unexpected users. expected %#v got %#v", expected, c.Users)
+			}
+		}
+	}
+	os.Unsetenv("BOOTSTRAP_TEST_PASSWORD")
+}
+
+func TestParseYAMLScalars(t *testing.T) {
+	c, err := Parse([]byte(`
+overwrite: true
+users:
+  - username: alice
+    password: 123456
+    api_key: "secret #1"
+sending_profiles:
+  - name: Relay
+    host: smtp.example.com:25
+    max_connections: 5
+`), FormatYAML)
+	if err != nil {
+		t.Fatalf("error parsing configuration: %v", err)
+	}
+	if !c.Overwrite {
+		t.Fatalf("expected overwrite to be set")
+	}
+	expected := []User{{Username: "alice", Password: "123456", APIKey: "secret #1"}}
+	if !reflect.DeepEqual(c.Users, expected) {
+		t.Fatalf("unexpected users. expected %#v got %#v", expected, c.Users)
+	}
+	if c.SendingProfiles[0].MaxConnections != 5 {
+		t.Fatalf("unexpected max connections: %d", c.SendingProfiles[0].MaxConnections)
+	}
+	_, err = Parse([]byte("overwrite: true\noverwrite: false"), FormatYAML)
+	if err == nil {
+		t.Fatalf("expected an error parsing a duplicate key")
+	}
+}
+
+func TestApply(t *testing.T) {
+	setupTest(t)
+	c, err := Parse([]byte(`
+users:
+  - username: admin
+    password: bootstrap-admin-password
+    api_key: bootstrap-api-key
+    role: admin
+  - username: reporter
+sending_profiles:
+  - name: Relay
+    host: smtp.example.com:25
+    from_address: phishing@example.com
+    headers:
+      - key: X-Mailer
+        value: Gophish
+webhooks:
+  - name: SIEM
+    url: https://siem.example.com/gophish
+    is_active: true
+`), FormatYAML)
+	if err != nil {
+		t.Fatalf("error parsing configuration: %v", err)
+	}
+	err = Apply(c)
+	if err != nil {
+		t.Fatalf("error applying configuration: %v", err)
+	}
+	admin, err := models.GetUserByAPIKey("bootstrap-api-key")
+	if err != nil {
+		t.Fatalf("error getting admin by API key: %v", err)
+	}
+	if admin.PasswordChangeRequired {
+		t.Fatalf("admin still requires a password change")
+	}
+	err = auth.ValidatePassword("bootstrap-admin-password", admin.Hash)
+	if err != nil {
+		t.Fatalf("admin password not set: %v", err)
+	}
+	reporter, err := models.GetUserByUsername("reporter")
+	if err != nil {
+		t.Fatalf("error getting reporter: %v", err)
+	}
+	if reporter.Role.Slug != models.RoleUser || reporter.ApiKey == "" {
+		t.Fatalf("unexpected reporter: %#v", reporter)
+	}
+	s, err := models.GetSMTPByName("Relay", admin.Id)
+	if err != nil {
+		t.Fatalf("error getting sending profile: %v", err)
+	}
+	if len(s.Headers) != 1 {
+		t.Fatalf("unexpected number of headers. expected %d got %d", 1, len(s.Headers))
+	}
+	whs, _ := models.GetWebhooks()
+	if len(whs) != 1 || !whs[0].IsActive {
+		t.Fatalf("unexpected webhooks: %#v", whs)
+	}
+
+	// Applying the configuration again doesn't change anything which has
+	// been modified since
+	s.Host = "changed.example.com:25"
+	models.PutSMTP(&s)
+	err = Apply(c)
+	if err != nil {
+		t.Fatalf("error applying configuration again: %v", err)
+	}
+	s, _ = models.GetSMTPByName("Relay", admin.Id)
+	if s.Host != "changed.example.com:25" {
+		t.Fatalf("sending profile was overwritten. got host %s", s.Host)
+	}
+	whs, _ = models.GetWebhooks()
+	if len(whs) != 1 {
+		t.Fatalf("unexpected number of webhooks. expected %d got %d", 1, len(whs))
+	}
+
+	c.Overwrite = true
+	err = Apply(c)
+	if err != nil {
+		t.Fatalf("error applying configuration with overwrite: %v", err)
+	}
+	s, _ = models.GetSMTPByName("Relay", admin.Id)
+	if s.Host != "smtp.example.com:25" {
+		t.Fatalf("sending profile wasn't overwritten. got host %s", s.Host)
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	setupTest(t)
+	configs := []*Config{
+		{Users: []User{{Username: "weak", Password: "short"}}},
+		{Users: []User{{Username: "nobody", Role: "superuser"}}},
+		{SendingProfiles: []SendingProfile{{Owner: "missing", SMTP: models.SMTP{Name: "Relay"}}}},
+	}
+	for _, c := range configs {
+		if err := Apply(c); err == nil {
+			t.Fatalf("expected an error applying %#v", c)
+		}
+	}
+}
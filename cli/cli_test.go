@@ -0,0 +1,399 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophish/gophish/backup"
+	"github.com/gophish/gophish/models"
+)
+
+const testAPIKey = "12345678901234567890123456789012"
+
+// testServer is a fake Gophish API which records the requests it receives.
+type testServer struct {
+	*httptest.Server
+	requests []*http.Request
+	bodies   []string
+	groups   models.GroupSummaries
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ts := &testServer{}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ts.requests = append(ts.requests, r)
+		ts.bodies = append(ts.bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer "+testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(models.Response{Success: false, Message: "Invalid API Key"})
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/groups/summary":
+			json.NewEncoder(w).Encode(ts.groups)
+		case r.Method == "POST" && r.URL.Path == "/api/import/group":
+			json.NewEncoder(w).Encode([]models.Target{{BaseRecipient: models.BaseRecipient{Email: "test@example.com"}}})
+		case r.Method == "POST" && r.URL.Path == "/api/groups/":
+			g := models.Group{}
+			json.Unmarshal(body, &g)
+			g.Id = 5
+			json.NewEncoder(w).Encode(g)
+		case r.Method == "POST" && r.URL.Path == "/api/groups/1/targets":
+			json.NewEncoder(w).Encode(models.BulkTargetResult{Updated: 1})
+		case r.Method == "POST" && r.URL.Path == "/api/campaigns/":
+			c := models.Campaign{}
+			json.Unmarshal(body, &c)
+			c.Id = 1
+			c.Status = models.CampaignInProgress
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(c)
+		case r.Method == "GET" && r.URL.Path == "/api/campaigns/1/results":
+			json.NewEncoder(w).Encode(models.CampaignResults{
+				Id:   1,
+				Name: "Test",
+				Results: []models.Result{{
+					RId:           "abc",
+					Status:        models.EventClicked,
+					SendDate:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+					ModifiedDate:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+					Tags:          "VIP,escalated",
+					Notes:         "Reported to the help desk",
+					BaseRecipient: models.BaseRecipient{Email: "test@example.com", FirstName: "Test"},
+				}},
+			})
+		case r.Method == "POST" && r.URL.Path == "/api/admin/backup":
+			w.Write([]byte("encrypted backup"))
+		case r.Method == "POST" && r.URL.Path == "/api/admin/restore":
+			json.NewEncoder(w).Encode(backup.Summary{
+				Manifest: backup.Manifest{Tables: []string{"pages"}},
+				Rows:     map[string]int64{"pages": 1},
+				Assets:   2,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(models.Response{Success: false, Message: "Campaign not found"})
+		}
+	}))
+	return ts
+}
+
+func newTestSession(ts *testServer, out *bytes.Buffer) *session {
+	return &session{
+		config:   &Config{Profiles: map[string]Profile{}},
+		override: Profile{URL: ts.URL, APIKey: testAPIKey},
+		printer:  NewPrinter(out, OutputJSON),
+		stdin:    strings.NewReader(""),
+		stdout:   out,
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gophish-cli")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nested", "cli.json")
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("error loading missing config: %v", err)
+	}
+	out := &bytes.Buffer{}
+	s := &session{config: c, configPath: path, printer: NewPrinter(out, OutputTable)}
+	s.override = Profile{URL: "https://localhost:3333"}
+	if err := addProfile(s, "prod", false); err != ErrNoAPIKey {
+		t.Fatalf("expected %v adding a profile without an API key, got %v", ErrNoAPIKey, err)
+	}
+	s.override.APIKey = testAPIKey
+	if err := addProfile(s, "prod", false); err != nil {
+		t.Fatalf("error adding profile: %v", err)
+	}
+	s.override = Profile{URL: "https://staging:3333", APIKey: "staging", Insecure: true}
+	if err := addProfile(s, "staging", false); err != nil {
+		t.Fatalf("error adding profile: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected config permissions: %v", info.Mode().Perm())
+	}
+	c, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	// The first profile added becomes the default
+	p, err := c.Profile("")
+	if err != nil {
+		t.Fatalf("error getting default profile: %v", err)
+	}
+	if p.URL != "https://localhost:3333" {
+		t.Fatalf("unexpected default profile: %v", p)
+	}
+	p, err = c.Profile("staging")
+	if err != nil {
+		t.Fatalf("error getting profile: %v", err)
+	}
+	if !p.Insecure {
+		t.Fatalf("expected staging profile to be insecure")
+	}
+	if _, err := c.Profile("missing"); err == nil {
+		t.Fatalf("expected error getting missing profile")
+	}
+
+	s.config = c
+	if err := useProfile(s, "staging"); err != nil {
+		t.Fatalf("error setting default profile: %v", err)
+	}
+	if err := removeProfile(s, "staging"); err != nil {
+		t.Fatalf("error removing profile: %v", err)
+	}
+	c, _ = LoadConfig(path)
+	if c.DefaultProfile != "" || len(c.Profiles) != 1 {
+		t.Fatalf("unexpected config after removing profile: %v", c)
+	}
+
+	out.Reset()
+	if err := listProfiles(s); err != nil {
+		t.Fatalf("error listing profiles: %v", err)
+	}
+	if strings.Contains(out.String(), testAPIKey) {
+		t.Fatalf("API key displayed when listing profiles: %s", out.String())
+	}
+}
+
+func TestSessionClientOverride(t *testing.T) {
+	s := &session{config: &Config{
+		DefaultProfile: "prod",
+		Profiles:       map[string]Profile{"prod": {URL: "https://prod:3333/", APIKey: "prod"}},
+	}}
+	s.override = Profile{APIKey: "override"}
+	c, err := s.Client()
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	if c.URL != "https://prod:3333" || c.APIKey != "override" {
+		t.Fatalf("unexpected client settings: %s %s", c.URL, c.APIKey)
+	}
+	s = &session{config: &Config{Profiles: map[string]Profile{}}}
+	if _, err := s.Client(); err != ErrNoServer {
+		t.Fatalf("expected %v, got %v", ErrNoServer, err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	out := &bytes.Buffer{}
+	s := newTestSession(ts, out)
+	err := getCampaign(s, 2)
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected API error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Campaign not found" {
+		t.Fatalf("unexpected API error: %v", apiErr)
+	}
+	s = newTestSession(ts, out)
+	s.override.APIKey = "invalid"
+	err = listCampaigns(s)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+}
+
+func TestLaunchCampaign(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	out := &bytes.Buffer{}
+	s := newTestSession(ts, out)
+	s.stdin = strings.NewReader(`{"name":"From File","template":{"name":"Template"},"page":{"name":"Page"},"smtp":{"name":"SMTP"},"url":"http://localhost"}`)
+	file, name, empty := "-", "Override", ""
+	groups := []string{"Group 1", "Group 2"}
+	launch := "2020-01-02T03:04:05Z"
+	opts := launchOptions{
+		File:       &file,
+		Name:       &name,
+		Template:   &empty,
+		Page:       &empty,
+		SMTP:       &empty,
+		URL:        &empty,
+		Groups:     &groups,
+		LaunchDate: &launch,
+		SendByDate: &empty,
+	}
+	err := launchCampaign(s, opts)
+	if err != nil {
+		t.Fatalf("error launching campaign: %v", err)
+	}
+	c := models.Campaign{}
+	json.Unmarshal([]byte(ts.bodies[0]), &c)
+	if c.Name != "Override" || c.Template.Name != "Template" || c.URL != "http://localhost" {
+		t.Fatalf("unexpected campaign sent: %s", ts.bodies[0])
+	}
+	if len(c.Groups) != 2 || c.Groups[1].Name != "Group 2" {
+		t.Fatalf("unexpected groups sent: %v", c.Groups)
+	}
+	if !c.LaunchDate.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected launch date sent: %v", c.LaunchDate)
+	}
+	created := models.Campaign{}
+	err = json.Unmarshal(out.Bytes(), &created)
+	if err != nil {
+		t.Fatalf("error parsing output: %v", err)
+	}
+	if created.Id != 1 {
+		t.Fatalf("unexpected campaign output: %s", out.String())
+	}
+
+	invalid := "tomorrow"
+	opts.LaunchDate = &invalid
+	if err := launchCampaign(s, opts); err == nil {
+		t.Fatalf("expected error with an invalid launch date")
+	}
+}
+
+func TestImportGroup(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	out := &bytes.Buffer{}
+	s := newTestSession(ts, out)
+	s.stdin = strings.NewReader("Email\ntest@example.com\n")
+
+	err := importGroup(s, "New Group", "-")
+	if err != nil {
+		t.Fatalf("error importing group: %v", err)
+	}
+	if !strings.HasPrefix(ts.requests[0].Header.Get("Content-Type"), "multipart/form-data") {
+		t.Fatalf("expected CSV to be uploaded as a form")
+	}
+	if !strings.Contains(ts.bodies[0], "test@example.com") {
+		t.Fatalf("CSV wasn't uploaded: %s", ts.bodies[0])
+	}
+	result := importResult{}
+	json.Unmarshal(out.Bytes(), &result)
+	if result.Id != 5 || !result.Created || result.Added != 1 {
+		t.Fatalf("unexpected result creating group: %s", out.String())
+	}
+
+	// Importing into an existing group adds the targets to it
+	ts.groups = models.GroupSummaries{Total: 1, Groups: []models.GroupSummary{{Id: 1, Name: "Existing"}}}
+	out.Reset()
+	s = newTestSession(ts, out)
+	s.stdin = strings.NewReader("Email\ntest@example.com\n")
+	err = importGroup(s, "Existing", "-")
+	if err != nil {
+		t.Fatalf("error importing group: %v", err)
+	}
+	result = importResult{}
+	json.Unmarshal(out.Bytes(), &result)
+	if result.Id != 1 || result.Created || result.Updated != 1 {
+		t.Fatalf("unexpected result importing into existing group: %s", out.String())
+	}
+}
+
+func TestExportResults(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	out := &bytes.Buffer{}
+	s := newTestSession(ts, out)
+	err := exportResults(s, 1, ExportCSV, "")
+	if err != nil {
+		t.Fatalf("error exporting results: %v", err)
+	}
+	expected := strings.Join(resultsHeader, ",") + "\n" +
+		"abc,test@example.com,Test,,,Clicked Link,,0,0,2020-01-02T03:04:05Z,false,2020-01-02T03:04:05Z,\"VIP,escalated\",Reported to the help desk\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected CSV export.\nexpected: %q\ngot: %q", expected, out.String())
+	}
+
+	out.Reset()
+	err = exportResults(s, 1, ExportJSON, "")
+	if err != nil {
+		t.Fatalf("error exporting results: %v", err)
+	}
+	cr := models.CampaignResults{}
+	err = json.Unmarshal(out.Bytes(), &cr)
+	if err != nil {
+		t.Fatalf("error parsing JSON export: %v", err)
+	}
+	if len(cr.Results) != 1 || cr.Results[0].Email != "test@example.com" {
+		t.Fatalf("unexpected JSON export: %s", out.String())
+	}
+}
+
+func TestBackup(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gophish-cli")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gophish.backup")
+	out := &bytes.Buffer{}
+	s := newTestSession(ts, out)
+
+	err = createBackup(s, "passphrase", path)
+	if err != nil {
+		t.Fatalf("error creating backup: %v", err)
+	}
+	if ts.bodies[0] != `{"passphrase":"passphrase"}` {
+		t.Fatalf("unexpected backup request: %s", ts.bodies[0])
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil || string(b) != "encrypted backup" {
+		t.Fatalf("backup not written: %q %v", b, err)
+	}
+
+	out.Reset()
+	err = restoreBackup(s, "passphrase", path)
+	if err != nil {
+		t.Fatalf("error restoring backup: %v", err)
+	}
+	if !strings.Contains(ts.bodies[1], "encrypted backup") || !strings.Contains(ts.bodies[1], `name="passphrase"`) {
+		t.Fatalf("backup wasn't uploaded: %s", ts.bodies[1])
+	}
+	summary := backup.Summary{}
+	json.Unmarshal(out.Bytes(), &summary)
+	if summary.Rows["pages"] != 1 || summary.Assets != 2 {
+		t.Fatalf("unexpected restore output: %s", out.String())
+	}
+
+	// Failed backups don't leave a partial file behind
+	os.Remove(path)
+	s = newTestSession(ts, out)
+	s.override.APIKey = "invalid"
+	err = createBackup(s, "passphrase", path)
+	if err == nil {
+		t.Fatalf("expected error creating backup with an invalid API key")
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected failed backup to be removed, got %v", err)
+	}
+}
+
+func TestPrinterTable(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewPrinter(out, OutputTable)
+	err := p.Print(nil, table{Headers: []string{"ID", "NAME"}, Rows: [][]string{{"1", "Campaign"}, {"10", "Other"}}})
+	if err != nil {
+		t.Fatalf("error printing table: %v", err)
+	}
+	expected := "ID  NAME\n1   Campaign\n10  Other\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected table.\nexpected: %q\ngot: %q", expected, out.String())
+	}
+}
@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/backup"
+	"github.com/gophish/gophish/models"
+)
+
+// DefaultTimeout is the timeout for requests to the Gophish API
+const DefaultTimeout = 60 * time.Second
+
+// APIError is returned when the API responds with an unsuccessful status
+// code.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status code from gophish: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s (status code %d)", e.Message, e.StatusCode)
+}
+
+// Client makes requests to the Gophish REST API.
+type Client struct {
+	URL    string
+	APIKey string
+	client *http.Client
+}
+
+// NewClient returns a new Client for the server in the given profile.
+func NewClient(p Profile) (*Client, error) {
+	err := p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if p.Insecure {
+		// The admin server uses a self-signed certificate by default
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &Client{
+		URL:    strings.TrimRight(p.URL, "/"),
+		APIKey: p.APIKey,
+		client: &http.Client{Timeout: DefaultTimeout, Transport: transport},
+	}, nil
+}
+
+// request sends a request to the API path using the given HTTP client,
+// returning an error if the response has an unsuccessful status code.
+func (c *Client) request(hc *http.Client, method, path string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.URL+"/api"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		r := models.Response{}
+		json.NewDecoder(resp.Body).Decode(&r)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: r.Message}
+	}
+	return resp, nil
+}
+
+// do sends a request to the API path and decodes the JSON response into out,
+// if it's not nil.
+func (c *Client) do(method, path string, contentType string, body io.Reader, out interface{}) error {
+	resp, err := c.request(c.client, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// transferClient returns an HTTP client for uploading or downloading backups,
+// which can take longer than DefaultTimeout.
+func (c *Client) transferClient() *http.Client {
+	hc := *c.client
+	hc.Timeout = 0
+	return &hc
+}
+
+func (c *Client) get(path string, out interface{}) error {
+	return c.do("GET", path, "", nil, out)
+}
+
+func (c *Client) send(method, path string, in interface{}, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(method, path, "application/json", bytes.NewReader(b), out)
+}
+
+// CampaignSummaries returns the summaries of the user's campaigns.
+func (c *Client) CampaignSummaries() (models.CampaignSummaries, error) {
+	cs := models.CampaignSummaries{}
+	err := c.get("/campaigns/summary", &cs)
+	return cs, err
+}
+
+// Campaign returns the campaign with the given id.
+func (c *Client) Campaign(id int64) (models.Campaign, error) {
+	campaign := models.Campaign{}
+	err := c.get(fmt.Sprintf("/campaigns/%d", id), &campaign)
+	return campaign, err
+}
+
+// CreateCampaign creates a new campaign. Campaigns without a launch date are
+// launched immediately.
+func (c *Client) CreateCampaign(campaign models.Campaign) (models.Campaign, error) {
+	created := models.Campaign{}
+	err := c.send("POST", "/campaigns/", campaign, &created)
+	return created, err
+}
+
+// CompleteCampaign marks the campaign with the given id as complete.
+func (c *Client) CompleteCampaign(id int64) error {
+	return c.get(fmt.Sprintf("/campaigns/%d/complete", id), nil)
+}
+
+// DeleteCampaign deletes the campaign with the given id.
+func (c *Client) DeleteCampaign(id int64) error {
+	return c.do("DELETE", fmt.Sprintf("/campaigns/%d", id), "", nil, nil)
+}
+
+// CampaignResults returns the results and timeline of the campaign with the
+// given id.
+func (c *Client) CampaignResults(id int64) (models.CampaignResults, error) {
+	cr := models.CampaignResults{}
+	err := c.get(fmt.Sprintf("/campaigns/%d/results", id), &cr)
+	return cr, err
+}
+
+// GroupSummaries returns the summaries of the user's groups.
+func (c *Client) GroupSummaries() (models.GroupSummaries, error) {
+	gs := models.GroupSummaries{}
+	err := c.get("/groups/summary", &gs)
+	return gs, err
+}
+
+// CreateGroup creates a new group.
+func (c *Client) CreateGroup(g models.Group) (models.Group, error) {
+	created := models.Group{}
+	err := c.send("POST", "/groups/", g, &created)
+	return created, err
+}
+
+// AddTargets adds the targets to the group with the given id, updating any
+// targets which are already in the group.
+func (c *Client) AddTargets(id int64, ts []models.Target) (models.BulkTargetResult, error) {
+	result := models.BulkTargetResult{}
+	err := c.send("POST", fmt.Sprintf("/groups/%d/targets", id), ts, &result)
+	return result, err
+}
+
+// ParseTargets uploads a CSV file to be parsed into targets by the server, so
+// that files are handled the same way as imports from the admin UI.
+func (c *Client) ParseTargets(name string, r io.Reader) ([]models.Target, error) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("file", filepath.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(part, r)
+	if err != nil {
+		return nil, err
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	ts := []models.Target{}
+	err = c.do("POST", "/import/group", mw.FormDataContentType(), body, &ts)
+	return ts, err
+}
+
+// Backup downloads a backup of the server encrypted with the passphrase,
+// writing it to w.
+func (c *Client) Backup(passphrase string, w io.Writer) error {
+	b, err := json.Marshal(map[string]string{"passphrase": passphrase})
+	if err != nil {
+		return err
+	}
+	resp, err := c.request(c.transferClient(), "POST", "/admin/backup", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Restore uploads a backup to be restored by the server. The backup is
+// streamed rather than read into memory.
+func (c *Client) Restore(passphrase string, r io.Reader) (backup.Summary, error) {
+	s := backup.Summary{}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := mw.WriteField("passphrase", passphrase)
+		if err == nil {
+			var part io.Writer
+			part, err = mw.CreateFormFile("file", "gophish.backup")
+			if err == nil {
+				_, err = io.Copy(part, r)
+			}
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	resp, err := c.request(c.transferClient(), "POST", "/admin/restore", mw.FormDataContentType(), pr)
+	// Unblock the writer if the request failed before the upload finished
+	pr.Close()
+	if err != nil {
+		return s, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	return s, err
+}
@@ -0,0 +1,589 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/models"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// The supported formats for exported results
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// Commands are the client commands registered with the command line parser.
+type Commands struct {
+	configPath *string
+	profile    *string
+	server     *string
+	apiKey     *string
+	insecure   *bool
+	output     *string
+	actions    map[string]func(*session) error
+}
+
+// session holds the state shared by a single invocation of a command.
+type session struct {
+	config     *Config
+	configPath string
+	profile    string
+	override   Profile
+	printer    *Printer
+	stdin      io.Reader
+	stdout     io.Writer
+	client     *Client
+}
+
+// Client returns a client for the selected profile, with any server settings
+// given on the command line taking precedence.
+func (s *session) Client() (*Client, error) {
+	if s.client != nil {
+		return s.client, nil
+	}
+	p, err := s.config.Profile(s.profile)
+	if err != nil {
+		return nil, err
+	}
+	if s.override.URL != "" {
+		p.URL = s.override.URL
+	}
+	if s.override.APIKey != "" {
+		p.APIKey = s.override.APIKey
+	}
+	if s.override.Insecure {
+		p.Insecure = true
+	}
+	s.client, err = NewClient(p)
+	return s.client, err
+}
+
+// Register adds the client commands to the application. The returned
+// Commands are used to run the command selected when the command line is
+// parsed.
+func Register(app *kingpin.Application) *Commands {
+	c := &Commands{actions: map[string]func(*session) error{}}
+	c.configPath = app.Flag("cli-config", "Location of the client profiles.").Default(DefaultConfigPath()).String()
+	c.profile = app.Flag("profile", "The client profile to use.").Envar("GOPHISH_PROFILE").String()
+	c.server = app.Flag("server", "The URL of the Gophish admin server, overriding the profile.").Envar("GOPHISH_SERVER").String()
+	c.apiKey = app.Flag("api-key", "The API key, overriding the profile.").Envar("GOPHISH_API_KEY").String()
+	c.insecure = app.Flag("insecure", "Don't verify the admin server's TLS certificate.").Bool()
+	c.output = app.Flag("output", "The output format.").Short('o').Default(OutputTable).Enum(OutputTable, OutputJSON)
+
+	profile := app.Command("profile", "Manage the servers the client connects to.")
+	cmd := profile.Command("list", "List the configured profiles.")
+	c.action(cmd, listProfiles)
+	cmd = profile.Command("add", "Add or update a profile using the --server, --api-key, and --insecure flags.")
+	addName := cmd.Arg("name", "The profile name.").Required().String()
+	addDefault := cmd.Flag("default", "Make this the default profile.").Bool()
+	c.action(cmd, func(s *session) error { return addProfile(s, *addName, *addDefault) })
+	cmd = profile.Command("remove", "Remove a profile.")
+	removeName := cmd.Arg("name", "The profile name.").Required().String()
+	c.action(cmd, func(s *session) error { return removeProfile(s, *removeName) })
+	cmd = profile.Command("use", "Set the default profile.")
+	useName := cmd.Arg("name", "The profile name.").Required().String()
+	c.action(cmd, func(s *session) error { return useProfile(s, *useName) })
+
+	campaign := app.Command("campaign", "Manage campaigns.")
+	cmd = campaign.Command("list", "List campaigns.")
+	c.action(cmd, listCampaigns)
+	cmd = campaign.Command("get", "Show a campaign.")
+	getID := cmd.Arg("id", "The campaign id.").Required().Int64()
+	c.action(cmd, func(s *session) error { return getCampaign(s, *getID) })
+	cmd = campaign.Command("launch", "Create and launch a campaign. Flags override the values in the campaign file.")
+	opts := launchOptions{
+		File:       cmd.Flag("file", "A JSON campaign definition, or - to read from stdin.").Short('f').String(),
+		Name:       cmd.Flag("name", "The campaign name.").String(),
+		Template:   cmd.Flag("template", "The name of the email template.").String(),
+		Page:       cmd.Flag("page", "The name of the landing page.").String(),
+		SMTP:       cmd.Flag("smtp", "The name of the sending profile.").String(),
+		URL:        cmd.Flag("url", "The URL of the phishing server.").String(),
+		Groups:     cmd.Flag("group", "The name of a group to send to. May be repeated.").Strings(),
+		LaunchDate: cmd.Flag("launch-date", "When to launch the campaign, in RFC 3339 format. Defaults to now.").String(),
+		SendByDate: cmd.Flag("send-by-date", "When all emails should be sent by, in RFC 3339 format.").String(),
+	}
+	c.action(cmd, func(s *session) error { return launchCampaign(s, opts) })
+	cmd = campaign.Command("complete", "Mark a campaign as complete.")
+	completeID := cmd.Arg("id", "The campaign id.").Required().Int64()
+	c.action(cmd, func(s *session) error { return completeCampaign(s, *completeID) })
+	cmd = campaign.Command("delete", "Delete a campaign.")
+	deleteID := cmd.Arg("id", "The campaign id.").Required().Int64()
+	c.action(cmd, func(s *session) error { return deleteCampaign(s, *deleteID) })
+
+	group := app.Command("group", "Manage groups.")
+	cmd = group.Command("list", "List groups.")
+	c.action(cmd, listGroups)
+	cmd = group.Command("import", "Import targets from a CSV file, adding them to the group if it already exists.")
+	importFile := cmd.Arg("file", "The CSV file to import, or - to read from stdin.").Required().String()
+	importName := cmd.Flag("name", "The group name.").Required().String()
+	c.action(cmd, func(s *session) error { return importGroup(s, *importName, *importFile) })
+
+	results := app.Command("results", "Manage campaign results.")
+	cmd = results.Command("export", "Export the results of a campaign.")
+	exportID := cmd.Arg("id", "The campaign id.").Required().Int64()
+	exportFormat := cmd.Flag("format", "The export format.").Default(ExportCSV).Enum(ExportCSV, ExportJSON)
+	exportPath := cmd.Flag("file", "The file to write the results to. Defaults to stdout.").Short('f').String()
+	c.action(cmd, func(s *session) error { return exportResults(s, *exportID, *exportFormat, *exportPath) })
+
+	backup := app.Command("backup", "Back up and restore the server's database and uploaded assets.")
+	passphrase := backup.Flag("passphrase", "The passphrase used to encrypt the backup.").Envar("GOPHISH_BACKUP_PASSPHRASE").Required().String()
+	cmd = backup.Command("create", "Download an encrypted backup.")
+	backupPath := cmd.Flag("file", "The file to write the backup to.").Short('f').Required().String()
+	c.action(cmd, func(s *session) error { return createBackup(s, *passphrase, *backupPath) })
+	cmd = backup.Command("restore", "Restore a backup, replacing the server's database.")
+	restorePath := cmd.Arg("file", "The backup to restore, or - to read from stdin.").Required().String()
+	c.action(cmd, func(s *session) error { return restoreBackup(s, *passphrase, *restorePath) })
+	return c
+}
+
+func (c *Commands) action(cmd *kingpin.CmdClause, fn func(*session) error) {
+	c.actions[cmd.FullCommand()] = fn
+}
+
+// Run runs the parsed command, returning false if the command isn't a client
+// command.
+func (c *Commands) Run(command string) (bool, error) {
+	fn, ok := c.actions[command]
+	if !ok {
+		return false, nil
+	}
+	config, err := LoadConfig(*c.configPath)
+	if err != nil {
+		return true, err
+	}
+	s := &session{
+		config:     config,
+		configPath: *c.configPath,
+		profile:    *c.profile,
+		override:   Profile{URL: *c.server, APIKey: *c.apiKey, Insecure: *c.insecure},
+		printer:    NewPrinter(os.Stdout, *c.output),
+		stdin:      os.Stdin,
+		stdout:     os.Stdout,
+	}
+	return true, fn(s)
+}
+
+func listProfiles(s *session) error {
+	t := table{Headers: []string{"NAME", "URL", "DEFAULT"}}
+	profiles := map[string]Profile{}
+	for _, name := range s.config.ProfileNames() {
+		p := s.config.Profiles[name]
+		// Never display API keys
+		p.APIKey = ""
+		profiles[name] = p
+		def := ""
+		if name == s.config.DefaultProfile {
+			def = "*"
+		}
+		t.Rows = append(t.Rows, []string{name, p.URL, def})
+	}
+	return s.printer.Print(profiles, t)
+}
+
+func addProfile(s *session, name string, makeDefault bool) error {
+	err := s.override.Validate()
+	if err != nil {
+		return err
+	}
+	s.config.Profiles[name] = s.override
+	if makeDefault || len(s.config.Profiles) == 1 {
+		s.config.DefaultProfile = name
+	}
+	err = s.config.Save(s.configPath)
+	if err != nil {
+		return err
+	}
+	return s.printer.Message("Profile %s saved to %s", name, s.configPath)
+}
+
+func removeProfile(s *session, name string) error {
+	if _, ok := s.config.Profiles[name]; !ok {
+		return fmt.Errorf("Profile %q not found", name)
+	}
+	delete(s.config.Profiles, name)
+	if s.config.DefaultProfile == name {
+		s.config.DefaultProfile = ""
+	}
+	err := s.config.Save(s.configPath)
+	if err != nil {
+		return err
+	}
+	return s.printer.Message("Profile %s removed", name)
+}
+
+func useProfile(s *session, name string) error {
+	if _, ok := s.config.Profiles[name]; !ok {
+		return fmt.Errorf("Profile %q not found", name)
+	}
+	s.config.DefaultProfile = name
+	err := s.config.Save(s.configPath)
+	if err != nil {
+		return err
+	}
+	return s.printer.Message("Default profile set to %s", name)
+}
+
+func listCampaigns(s *session) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	cs, err := c.CampaignSummaries()
+	if err != nil {
+		return err
+	}
+	t := table{Headers: []string{"ID", "NAME", "STATUS", "LAUNCHED", "TOTAL", "SENT", "OPENED", "CLICKED", "SUBMITTED", "REPORTED"}}
+	for _, cm := range cs.Campaigns {
+		t.Rows = append(t.Rows, []string{
+			strconv.FormatInt(cm.Id, 10),
+			cm.Name,
+			cm.Status,
+			formatTime(cm.LaunchDate),
+			strconv.FormatInt(cm.Stats.Total, 10),
+			strconv.FormatInt(cm.Stats.EmailsSent, 10),
+			strconv.FormatInt(cm.Stats.OpenedEmail, 10),
+			strconv.FormatInt(cm.Stats.ClickedLink, 10),
+			strconv.FormatInt(cm.Stats.SubmittedData, 10),
+			strconv.FormatInt(cm.Stats.EmailReported, 10),
+		})
+	}
+	return s.printer.Print(cs.Campaigns, t)
+}
+
+// campaignTable displays a single campaign as a list of fields.
+func campaignTable(cm models.Campaign) table {
+	groups := []string{}
+	for _, g := range cm.Groups {
+		groups = append(groups, g.Name)
+	}
+	return table{
+		Headers: []string{"FIELD", "VALUE"},
+		Rows: [][]string{
+			{"ID", strconv.FormatInt(cm.Id, 10)},
+			{"Name", cm.Name},
+			{"Status", cm.Status},
+			{"Created", formatTime(cm.CreatedDate)},
+			{"Launch Date", formatTime(cm.LaunchDate)},
+			{"Send By Date", formatTime(cm.SendByDate)},
+			{"Completed", formatTime(cm.CompletedDate)},
+			{"Template", cm.Template.Name},
+			{"Landing Page", cm.Page.Name},
+			{"Sending Profile", cm.SMTP.Name},
+			{"URL", cm.URL},
+			{"Groups", strings.Join(groups, ", ")},
+			{"Recipients", strconv.Itoa(len(cm.Results))},
+		},
+	}
+}
+
+func getCampaign(s *session, id int64) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	cm, err := c.Campaign(id)
+	if err != nil {
+		return err
+	}
+	return s.printer.Print(cm, campaignTable(cm))
+}
+
+// launchOptions are the flags used to define a campaign to launch.
+type launchOptions struct {
+	File       *string
+	Name       *string
+	Template   *string
+	Page       *string
+	SMTP       *string
+	URL        *string
+	Groups     *[]string
+	LaunchDate *string
+	SendByDate *string
+}
+
+// campaign returns the campaign defined by the options. Templates, pages,
+// sending profiles, and groups are referenced by name.
+func (o launchOptions) campaign(stdin io.Reader) (models.Campaign, error) {
+	cm := models.Campaign{}
+	if *o.File != "" {
+		r, err := openInput(*o.File, stdin)
+		if err != nil {
+			return cm, err
+		}
+		defer r.Close()
+		err = json.NewDecoder(r).Decode(&cm)
+		if err != nil {
+			return cm, fmt.Errorf("error parsing %s: %v", *o.File, err)
+		}
+	}
+	if *o.Name != "" {
+		cm.Name = *o.Name
+	}
+	if *o.Template != "" {
+		cm.Template = models.Template{Name: *o.Template}
+	}
+	if *o.Page != "" {
+		cm.Page = models.Page{Name: *o.Page}
+	}
+	if *o.SMTP != "" {
+		cm.SMTP = models.SMTP{Name: *o.SMTP}
+	}
+	if *o.URL != "" {
+		cm.URL = *o.URL
+	}
+	if len(*o.Groups) > 0 {
+		cm.Groups = []models.Group{}
+		for _, g := range *o.Groups {
+			cm.Groups = append(cm.Groups, models.Group{Name: g})
+		}
+	}
+	var err error
+	if *o.LaunchDate != "" {
+		cm.LaunchDate, err = time.Parse(time.RFC3339, *o.LaunchDate)
+		if err != nil {
+			return cm, fmt.Errorf("Invalid launch date: %v", err)
+		}
+	}
+	if *o.SendByDate != "" {
+		cm.SendByDate, err = time.Parse(time.RFC3339, *o.SendByDate)
+		if err != nil {
+			return cm, fmt.Errorf("Invalid send by date: %v", err)
+		}
+	}
+	return cm, nil
+}
+
+func launchCampaign(s *session, opts launchOptions) error {
+	cm, err := opts.campaign(s.stdin)
+	if err != nil {
+		return err
+	}
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	cm, err = c.CreateCampaign(cm)
+	if err != nil {
+		return err
+	}
+	return s.printer.Print(cm, campaignTable(cm))
+}
+
+func completeCampaign(s *session, id int64) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	err = c.CompleteCampaign(id)
+	if err != nil {
+		return err
+	}
+	return s.printer.Message("Campaign %d completed", id)
+}
+
+func deleteCampaign(s *session, id int64) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	err = c.DeleteCampaign(id)
+	if err != nil {
+		return err
+	}
+	return s.printer.Message("Campaign %d deleted", id)
+}
+
+func listGroups(s *session) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	gs, err := c.GroupSummaries()
+	if err != nil {
+		return err
+	}
+	t := table{Headers: []string{"ID", "NAME", "TARGETS", "MODIFIED"}}
+	for _, g := range gs.Groups {
+		t.Rows = append(t.Rows, []string{
+			strconv.FormatInt(g.Id, 10),
+			g.Name,
+			strconv.FormatInt(g.NumTargets, 10),
+			formatTime(g.ModifiedDate),
+		})
+	}
+	return s.printer.Print(gs.Groups, t)
+}
+
+// importResult is the output of importing a group.
+type importResult struct {
+	Id      int64 `json:"id"`
+	Created bool  `json:"created"`
+	models.BulkTargetResult
+}
+
+func importGroup(s *session, name string, path string) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	r, err := openInput(path, s.stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	ts, err := c.ParseTargets(path, r)
+	if err != nil {
+		return err
+	}
+	gs, err := c.GroupSummaries()
+	if err != nil {
+		return err
+	}
+	result := importResult{}
+	for _, g := range gs.Groups {
+		if g.Name == name {
+			result.Id = g.Id
+			break
+		}
+	}
+	if result.Id == 0 {
+		g, err := c.CreateGroup(models.Group{Name: name, Targets: ts})
+		if err != nil {
+			return err
+		}
+		result.Id = g.Id
+		result.Created = true
+		result.Added = len(g.Targets)
+	} else {
+		result.BulkTargetResult, err = c.AddTargets(result.Id, ts)
+		if err != nil {
+			return err
+		}
+	}
+	t := table{
+		Headers: []string{"ID", "NAME", "CREATED", "ADDED", "UPDATED"},
+		Rows: [][]string{{
+			strconv.FormatInt(result.Id, 10),
+			name,
+			strconv.FormatBool(result.Created),
+			strconv.Itoa(result.Added),
+			strconv.Itoa(result.Updated),
+		}},
+	}
+	return s.printer.Print(result, t)
+}
+
+// resultsHeader is the header row of results exported as CSV
+var resultsHeader = []string{"id", "email", "first_name", "last_name", "position", "status", "ip", "latitude", "longitude", "send_date", "reported", "modified_date", "tags", "notes"}
+
+func exportResults(s *session, id int64, format string, path string) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	cr, err := c.CampaignResults(id)
+	if err != nil {
+		return err
+	}
+	w := s.stdout
+	if path != "" {
+		// Results contain personal information, so they're only readable by
+		// the current user.
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == ExportJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cr)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(resultsHeader)
+	for _, r := range cr.Results {
+		cw.Write([]string{
+			r.RId,
+			r.Email,
+			r.FirstName,
+			r.LastName,
+			r.Position,
+			r.Status,
+			r.IP,
+			strconv.FormatFloat(r.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(r.Longitude, 'f', -1, 64),
+			r.SendDate.UTC().Format(time.RFC3339),
+			strconv.FormatBool(r.Reported),
+			r.ModifiedDate.UTC().Format(time.RFC3339),
+			r.Tags,
+			r.Notes,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func createBackup(s *session, passphrase string, path string) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	// Backups are encrypted, but are only readable by the current user to
+	// limit offline attacks on the passphrase.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = c.Backup(passphrase, f)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return s.printer.Message("Backup written to %s", path)
+}
+
+func restoreBackup(s *session, passphrase string, path string) error {
+	c, err := s.Client()
+	if err != nil {
+		return err
+	}
+	r, err := openInput(path, s.stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	summary, err := c.Restore(passphrase, r)
+	if err != nil {
+		return err
+	}
+	t := table{Headers: []string{"TABLE", "ROWS"}}
+	for _, name := range summary.Manifest.Tables {
+		t.Rows = append(t.Rows, []string{name, strconv.FormatInt(summary.Rows[name], 10)})
+	}
+	t.Rows = append(t.Rows, []string{"assets", strconv.Itoa(summary.Assets)})
+	return s.printer.Print(summary, t)
+}
+
+// openInput opens the file at the path, or returns stdin if the path is "-".
+func openInput(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(stdin), nil
+	}
+	return os.Open(path)
+}
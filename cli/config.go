@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultProfileName is the name of the profile used when no profile is
+// specified and no default has been set.
+const DefaultProfileName = "default"
+
+// ErrNoServer is thrown when no server URL has been configured for the
+// selected profile.
+var ErrNoServer = errors.New("No server URL configured. Add a profile with \"gophish profile add\" or use --server")
+
+// ErrNoAPIKey is thrown when no API key has been configured for the selected
+// profile.
+var ErrNoAPIKey = errors.New("No API key configured. Add a profile with \"gophish profile add\" or use --api-key")
+
+// Profile contains the settings used to connect to a single Gophish server.
+type Profile struct {
+	URL      string `json:"url"`
+	APIKey   string `json:"api_key"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// Validate checks that the profile has enough information to connect to the
+// server.
+func (p Profile) Validate() error {
+	if p.URL == "" {
+		return ErrNoServer
+	}
+	if p.APIKey == "" {
+		return ErrNoAPIKey
+	}
+	return nil
+}
+
+// Config is the client configuration file, which contains the named server
+// profiles.
+type Config struct {
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+// DefaultConfigPath returns the default location of the client configuration
+// file, in the user's configuration directory.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "gophish-cli.json"
+	}
+	return filepath.Join(dir, "gophish", "cli.json")
+}
+
+// LoadConfig loads the client configuration from the given path. A missing
+// file results in an empty configuration.
+func LoadConfig(path string) (*Config, error) {
+	c := &Config{Profiles: map[string]Profile{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	return c, nil
+}
+
+// Save writes the configuration to the given path. Since the file contains
+// API keys, it's only readable by the current user.
+func (c *Config) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// Profile returns the profile with the given name. If the name is empty, the
+// default profile is returned.
+func (c *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		name = DefaultProfileName
+	}
+	p, ok := c.Profiles[name]
+	if !ok && name != c.DefaultProfile && name != DefaultProfileName {
+		return p, fmt.Errorf("Profile %q not found", name)
+	}
+	return p, nil
+}
+
+// ProfileNames returns the names of the configured profiles in sorted order.
+func (c *Config) ProfileNames() []string {
+	names := []string{}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
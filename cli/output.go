@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gophish/gophish/models"
+)
+
+// The supported output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// Printer writes command output as either a table or JSON.
+type Printer struct {
+	Format string
+	w      io.Writer
+}
+
+// NewPrinter returns a new Printer which writes to w in the given format.
+func NewPrinter(w io.Writer, format string) *Printer {
+	return &Printer{Format: format, w: w}
+}
+
+// table describes how an item is displayed when printing a table.
+type table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+// Print writes the value in the configured format. In table format, the
+// table is printed, otherwise the value is printed as indented JSON.
+func (p *Printer) Print(v interface{}, t table) error {
+	if p.Format == OutputJSON {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Headers, "\t"))
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Message writes a status message. In JSON format, the message is wrapped in
+// a response object so that the output can always be parsed.
+func (p *Printer) Message(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.Format == OutputJSON {
+		return p.Print(models.Response{Success: true, Message: msg}, table{})
+	}
+	_, err := fmt.Fprintln(p.w, msg)
+	return err
+}
+
+// formatTime formats a timestamp for display, leaving unset timestamps
+// empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
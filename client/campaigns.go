@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/gophish/gophish/models"
+)
+
+// ListCampaigns returns a page of the campaigns.
+func (c *Client) ListCampaigns(ctx context.Context, opts models.ListOptions) ([]models.Campaign, models.PageInfo, error) {
+	cs := []models.Campaign{}
+	page, err := c.do(ctx, http.MethodGet, "/campaigns/", listQuery(opts), nil, &cs)
+	return cs, page, err
+}
+
+// AllCampaigns returns every campaign matching the options' filters.
+func (c *Client) AllCampaigns(ctx context.Context, opts models.ListOptions) ([]models.Campaign, error) {
+	cs := []models.Campaign{}
+	err := all(opts, func(opts models.ListOptions) (models.PageInfo, error) {
+		page, pageInfo, err := c.ListCampaigns(ctx, opts)
+		cs = append(cs, page...)
+		return pageInfo, err
+	})
+	return cs, err
+}
+
+// GetCampaign returns the campaign, including its results and timeline.
+func (c *Client) GetCampaign(ctx context.Context, id int64) (models.Campaign, error) {
+	campaign := models.Campaign{}
+	_, err := c.do(ctx, http.MethodGet, itemPath("/campaigns/", id), nil, nil, &campaign)
+	return campaign, err
+}
+
+// GetCampaignSummary returns the campaign's details and statistics.
+func (c *Client) GetCampaignSummary(ctx context.Context, id int64) (models.CampaignSummary, error) {
+	cs := models.CampaignSummary{}
+	_, err := c.do(ctx, http.MethodGet, itemPath("/campaigns/", id)+"/summary", nil, nil, &cs)
+	return cs, err
+}
+
+// ListCampaignResults returns a page of the campaign's results, along with
+// their events.
+func (c *Client) ListCampaignResults(ctx context.Context, id int64, opts models.ListOptions) (models.CampaignResults, models.PageInfo, error) {
+	cr := models.CampaignResults{}
+	page, err := c.do(ctx, http.MethodGet, itemPath("/campaigns/", id)+"/results", listQuery(opts), nil, &cr)
+	return cr, page, err
+}
+
+// AllCampaignResults returns every result of the campaign matching the
+// options' filters, without their events.
+func (c *Client) AllCampaignResults(ctx context.Context, id int64, opts models.ListOptions) ([]models.Result, error) {
+	rs := []models.Result{}
+	err := all(opts, func(opts models.ListOptions) (models.PageInfo, error) {
+		cr, page, err := c.ListCampaignResults(ctx, id, opts)
+		rs = append(rs, cr.Results...)
+		return page, err
+	})
+	return rs, err
+}
+
+// CreateCampaign creates and schedules a campaign. The template, page,
+// sending profile and groups are referenced by name.
+func (c *Client) CreateCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error) {
+	created := models.Campaign{}
+	_, err := c.do(ctx, http.MethodPost, "/campaigns/", nil, campaign, &created)
+	return created, err
+}
+
+// AnnotateResult replaces the analyst tags and notes of the result with the
+// given RId in the campaign.
+func (c *Client) AnnotateResult(ctx context.Context, id int64, rid string, a models.ResultAnnotation) (models.Result, error) {
+	r := models.Result{}
+	path := itemPath("/campaigns/", id) + "/results/" + url.PathEscape(rid) + "/annotations"
+	_, err := c.do(ctx, http.MethodPut, path, nil, a, &r)
+	return r, err
+}
+
+// CompleteCampaign marks the campaign as complete, so no more emails are
+// sent or events recorded.
+func (c *Client) CompleteCampaign(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodGet, itemPath("/campaigns/", id)+"/complete", nil, nil, nil)
+	return err
+}
+
+// DeleteCampaign deletes the campaign and its results.
+func (c *Client) DeleteCampaign(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, itemPath("/campaigns/", id), nil, nil, nil)
+	return err
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/schema"
+)
+
+// The defaults used when retrying failed requests
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryWait  = 500 * time.Millisecond
+)
+
+// The headers the API returns pagination information in
+const (
+	totalCountHeader = "X-Total-Count"
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+// Error is returned when the API responds with an error.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gophish: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("gophish: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns whether the error is the API reporting that the item
+// doesn't exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Client makes requests to the Gophish API.
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+}
+
+// Option is a functional option that is used to configure the client.
+type Option func(*Client)
+
+// WithHTTPClient is an option that sets the HTTP client requests are made
+// with, such as to trust the admin server's self-signed certificate.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetries is an option that sets the number of times failed requests are
+// retried, and the time waited before the first retry. The wait doubles with
+// each retry.
+func WithRetries(max int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = max
+		c.retryWait = wait
+	}
+}
+
+// New returns a client for the Gophish admin server at the given URL, such
+// as https://localhost:3333, which authenticates with the API key.
+func New(baseURL string, apiKey string, options ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: expected an http or https URL", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+		maxRetries: DefaultMaxRetries,
+		retryWait:  DefaultRetryWait,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c, nil
+}
+
+// do makes a request to the API path, encoding the body as JSON and decoding
+// the response into v, if they're given. The pagination headers are
+// returned for lists.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, v interface{}) (models.PageInfo, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return models.PageInfo{}, err
+		}
+	}
+	u := *c.baseURL
+	u.Path = u.Path + "/api" + path
+	u.RawQuery = query.Encode()
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+		if err != nil {
+			return models.PageInfo{}, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		req.Header.Set(schema.Header, strconv.Itoa(schema.Latest))
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err = c.httpClient.Do(req)
+		wait, retry := c.shouldRetry(method, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				return models.PageInfo{}, err
+			}
+			break
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return models.PageInfo{}, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		r := models.Response{}
+		if json.NewDecoder(resp.Body).Decode(&r) == nil {
+			apiErr.Message = r.Message
+		}
+		return models.PageInfo{}, apiErr
+	}
+	page := models.PageInfo{NextCursor: resp.Header.Get(nextCursorHeader)}
+	page.Total, _ = strconv.ParseInt(resp.Header.Get(totalCountHeader), 10, 64)
+	if v == nil {
+		return page, nil
+	}
+	return page, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// shouldRetry returns whether the request should be retried, and how long
+// to wait first. Requests which may have changed something are only retried
+// if the server rejected them before handling them.
+func (c *Client) shouldRetry(method string, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.maxRetries {
+		return 0, false
+	}
+	wait := c.retryWait << uint(attempt)
+	idempotent := method != http.MethodPost
+	if err != nil {
+		return wait, idempotent
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		return wait, true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return wait, idempotent
+	}
+	return 0, false
+}
+
+// listQuery returns the query parameters for the list options.
+func listQuery(opts models.ListOptions) url.Values {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		q.Set("cursor", opts.Cursor)
+	}
+	if opts.Sort != "" {
+		q.Set("sort", opts.Sort)
+	}
+	for field, value := range opts.Filters {
+		q.Set("filter["+field+"]", value)
+	}
+	return q
+}
+
+// all calls list with each page's cursor in turn until the last page is
+// returned. If no limit is set, the largest page size is used.
+func all(opts models.ListOptions, list func(models.ListOptions) (models.PageInfo, error)) error {
+	if opts.Limit == 0 {
+		opts.Limit = models.MaxPageSize
+	}
+	for {
+		page, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func itemPath(prefix string, id int64) string {
+	return prefix + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/controllers/api"
+	"github.com/gophish/gophish/models"
+)
+
+// setupTest returns a client for an API server using a new database.
+func setupTest(t *testing.T) (*Client, func()) {
+	conf := &config.Config{
+		DBName:         "sqlite3",
+		DBPath:         ":memory:",
+		MigrationsPath: "../db/db_sqlite3/migrations/",
+	}
+	err := models.Setup(conf)
+	if err != nil {
+		t.Fatalf("Failed creating database: %v", err)
+	}
+	u, err := models.GetUser(1)
+	if err != nil {
+		t.Fatalf("error getting admin user: %v", err)
+	}
+	ts := httptest.NewServer(api.NewServer())
+	c, err := New(ts.URL, string(u.ApiKey))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return c, ts.Close
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	_, err := New("localhost:3333", "key")
+	if err == nil {
+		t.Fatalf("expected an error creating a client without a scheme")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	c, done := setupTest(t)
+	defer done()
+	ctx := context.Background()
+	for _, name := range []string{"First", "Second", "Third"} {
+		g := models.Group{Name: name, Targets: []models.Target{
+			{BaseRecipient: models.BaseRecipient{Email: name + "@example.com"}},
+		}}
+		_, err := c.CreateGroup(ctx, g)
+		if err != nil {
+			t.Fatalf("error creating group: %v", err)
+		}
+	}
+
+	// Every page is followed
+	gs, err := c.AllGroups(ctx, models.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("error listing groups: %v", err)
+	}
+	if len(gs) != 3 {
+		t.Fatalf("unexpected number of groups. expected %d got %d", 3, len(gs))
+	}
+	_, page, err := c.ListGroups(ctx, models.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("error listing groups: %v", err)
+	}
+	if page.Total != 3 || page.NextCursor == "" {
+		t.Fatalf("unexpected page info: %+v", page)
+	}
+
+	g := gs[0]
+	g.Name = "Renamed"
+	updated, err := c.UpdateGroup(ctx, g)
+	if err != nil {
+		t.Fatalf("error updating group: %v", err)
+	}
+	if updated.Name != "Renamed" {
+		t.Fatalf("unexpected group name. expected %s got %s", "Renamed", updated.Name)
+	}
+	err = c.DeleteGroup(ctx, g.Id)
+	if err != nil {
+		t.Fatalf("error deleting group: %v", err)
+	}
+	_, err = c.GetGroup(ctx, g.Id)
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error getting a deleted group. got %v", err)
+	}
+}
+
+func TestCampaigns(t *testing.T) {
+	c, done := setupTest(t)
+	defer done()
+	ctx := context.Background()
+	_, err := c.CreateGroup(ctx, models.Group{Name: "Group", Targets: []models.Target{
+		{BaseRecipient: models.BaseRecipient{Email: "foo@example.com"}},
+	}})
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+	_, err = c.CreateTemplate(ctx, models.Template{Name: "Template", Subject: "Subject", Text: "Text"})
+	if err != nil {
+		t.Fatalf("error creating template: %v", err)
+	}
+	_, err = c.CreatePage(ctx, models.Page{Name: "Page", HTML: "<html></html>"})
+	if err != nil {
+		t.Fatalf("error creating page: %v", err)
+	}
+	_, err = c.CreateSendingProfile(ctx, models.SMTP{Name: "SMTP", Host: "example.com:25", FromAddress: "foo@example.com"})
+	if err != nil {
+		t.Fatalf("error creating sending profile: %v", err)
+	}
+	campaign, err := c.CreateCampaign(ctx, models.Campaign{
+		Name:     "Campaign",
+		Template: models.Template{Name: "Template"},
+		Page:     models.Page{Name: "Page"},
+		SMTP:     models.SMTP{Name: "SMTP"},
+		Groups:   []models.Group{{Name: "Group"}},
+		// Schedule the campaign so that the test worker doesn't send it
+		LaunchDate: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	rs, err := c.AllCampaignResults(ctx, campaign.Id, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("error listing results: %v", err)
+	}
+	if len(rs) != 1 || rs[0].Email != "foo@example.com" {
+		t.Fatalf("unexpected results: %+v", rs)
+	}
+	err = c.CompleteCampaign(ctx, campaign.Id)
+	if err != nil {
+		t.Fatalf("error completing campaign: %v", err)
+	}
+	cs, err := c.GetCampaignSummary(ctx, campaign.Id)
+	if err != nil {
+		t.Fatalf("error getting campaign summary: %v", err)
+	}
+	if cs.Status != models.CampaignComplete {
+		t.Fatalf("unexpected campaign status. expected %s got %s", models.CampaignComplete, cs.Status)
+	}
+
+	_, err = c.CreateCampaign(ctx, models.Campaign{Name: "Invalid"})
+	apiErr, ok := err.(*Error)
+	if !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != models.ErrGroupNotSpecified.Error() {
+		t.Fatalf("unexpected error creating an invalid campaign: %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+	c, err := New(ts.URL, "key", WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	_, _, err = c.ListGroups(context.Background(), models.ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error after retrying: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("unexpected number of requests. expected %d got %d", 3, calls)
+	}
+
+	// Creating items isn't retried, since the server may have handled the
+	// request
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.CreateGroup(context.Background(), models.Group{Name: "Group"})
+	apiErr, ok := err.(*Error)
+	if !ok || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("unexpected number of requests. expected %d got %d", 1, calls)
+	}
+}
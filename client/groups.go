@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gophish/gophish/models"
+)
+
+// ListGroups returns a page of the groups.
+func (c *Client) ListGroups(ctx context.Context, opts models.ListOptions) ([]models.Group, models.PageInfo, error) {
+	items := []models.Group{}
+	page, err := c.do(ctx, http.MethodGet, "/groups/", listQuery(opts), nil, &items)
+	return items, page, err
+}
+
+// AllGroups returns all of the groups matching the options' filters.
+func (c *Client) AllGroups(ctx context.Context, opts models.ListOptions) ([]models.Group, error) {
+	items := []models.Group{}
+	err := all(opts, func(opts models.ListOptions) (models.PageInfo, error) {
+		page, pageInfo, err := c.ListGroups(ctx, opts)
+		items = append(items, page...)
+		return pageInfo, err
+	})
+	return items, err
+}
+
+// GetGroup returns the group with the given id.
+func (c *Client) GetGroup(ctx context.Context, id int64) (models.Group, error) {
+	item := models.Group{}
+	_, err := c.do(ctx, http.MethodGet, itemPath("/groups/", id), nil, nil, &item)
+	return item, err
+}
+
+// CreateGroup creates a group.
+func (c *Client) CreateGroup(ctx context.Context, item models.Group) (models.Group, error) {
+	created := models.Group{}
+	_, err := c.do(ctx, http.MethodPost, "/groups/", nil, item, &created)
+	return created, err
+}
+
+// UpdateGroup replaces the group with the same id.
+func (c *Client) UpdateGroup(ctx context.Context, item models.Group) (models.Group, error) {
+	updated := models.Group{}
+	_, err := c.do(ctx, http.MethodPut, itemPath("/groups/", item.Id), nil, item, &updated)
+	return updated, err
+}
+
+// DeleteGroup deletes the group.
+func (c *Client) DeleteGroup(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, itemPath("/groups/", id), nil, nil, nil)
+	return err
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gophish/gophish/models"
+)
+
+// ListPages returns a page of the landing pages.
+func (c *Client) ListPages(ctx context.Context, opts models.ListOptions) ([]models.Page, models.PageInfo, error) {
+	items := []models.Page{}
+	page, err := c.do(ctx, http.MethodGet, "/pages/", listQuery(opts), nil, &items)
+	return items, page, err
+}
+
+// AllPages returns all of the landing pages matching the options' filters.
+func (c *Client) AllPages(ctx context.Context, opts models.ListOptions) ([]models.Page, error) {
+	items := []models.Page{}
+	err := all(opts, func(opts models.ListOptions) (models.PageInfo, error) {
+		page, pageInfo, err := c.ListPages(ctx, opts)
+		items = append(items, page...)
+		return pageInfo, err
+	})
+	return items, err
+}
+
+// GetPage returns the landing page with the given id.
+func (c *Client) GetPage(ctx context.Context, id int64) (models.Page, error) {
+	item := models.Page{}
+	_, err := c.do(ctx, http.MethodGet, itemPath("/pages/", id), nil, nil, &item)
+	return item, err
+}
+
+// CreatePage creates a landing page.
+func (c *Client) CreatePage(ctx context.Context, item models.Page) (models.Page, error) {
+	created := models.Page{}
+	_, err := c.do(ctx, http.MethodPost, "/pages/", nil, item, &created)
+	return created, err
+}
+
+// UpdatePage replaces the landing page with the same id.
+func (c *Client) UpdatePage(ctx context.Context, item models.Page) (models.Page, error) {
+	updated := models.Page{}
+	_, err := c.do(ctx, http.MethodPut, itemPath("/pages/", item.Id), nil, item, &updated)
+	return updated, err
+}
+
+// DeletePage deletes the landing page.
+func (c *Client) DeletePage(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, itemPath("/pages/", id), nil, nil, nil)
+	return err
+}
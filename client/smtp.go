@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gophish/gophish/models"
+)
+
+// ListSendingProfiles returns a page of the sending profiles.
+func (c *Client) ListSendingProfiles(ctx context.Context, opts models.ListOptions) ([]models.SMTP, models.PageInfo, error) {
+	items := []models.SMTP{}
+	page, err := c.do(ctx, http.MethodGet, "/smtp/", listQuery(opts), nil, &items)
+	return items, page, err
+}
+
+// AllSendingProfiles returns all of the sending profiles matching the options' filters.
+func (c *Client) AllSendingProfiles(ctx context.Context, opts models.ListOptions) ([]models.SMTP, error) {
+	items := []models.SMTP{}
+	err := all(opts, func(opts models.ListOptions) (models.PageInfo, error) {
+		page, pageInfo, err := c.ListSendingProfiles(ctx, opts)
+		items = append(items, page...)
+		return pageInfo, err
+	})
+	return items, err
+}
+
+// GetSendingProfile returns the sending profile with the given id.
+func (c *Client) GetSendingProfile(ctx context.Context, id int64) (models.SMTP, error) {
+	item := models.SMTP{}
+	_, err := c.do(ctx, http.MethodGet, itemPath("/smtp/", id), nil, nil, &item)
+	return item, err
+}
+
+// CreateSendingProfile creates a sending profile.
+func (c *Client) CreateSendingProfile(ctx context.Context, item models.SMTP) (models.SMTP, error) {
+	created := models.SMTP{}
+	_, err := c.do(ctx, http.MethodPost, "/smtp/", nil, item, &created)
+	return created, err
+}
+
+// UpdateSendingProfile replaces the sending profile with the same id.
+func (c *Client) UpdateSendingProfile(ctx context.Context, item models.SMTP) (models.SMTP, error) {
+	updated := models.SMTP{}
+	_, err := c.do(ctx, http.MethodPut, itemPath("/smtp/", item.Id), nil, item, &updated)
+	return updated, err
+}
+
+// DeleteSendingProfile deletes the sending profile.
+func (c *Client) DeleteSendingProfile(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, itemPath("/smtp/", id), nil, nil, nil)
+	return err
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gophish/gophish/models"
+)
+
+// ListTemplates returns a page of the templates.
+func (c *Client) ListTemplates(ctx context.Context, opts models.ListOptions) ([]models.Template, models.PageInfo, error) {
+	items := []models.Template{}
+	page, err := c.do(ctx, http.MethodGet, "/templates/", listQuery(opts), nil, &items)
+	return items, page, err
+}
+
+// AllTemplates returns all of the templates matching the options' filters.
+func (c *Client) AllTemplates(ctx context.Context, opts models.ListOptions) ([]models.Template, error) {
+	items := []models.Template{}
+	err := all(opts, func(opts models.ListOptions) (models.PageInfo, error) {
+		page, pageInfo, err := c.ListTemplates(ctx, opts)
+		items = append(items, page...)
+		return pageInfo, err
+	})
+	return items, err
+}
+
+// GetTemplate returns the template with the given id.
+func (c *Client) GetTemplate(ctx context.Context, id int64) (models.Template, error) {
+	item := models.Template{}
+	_, err := c.do(ctx, http.MethodGet, itemPath("/templates/", id), nil, nil, &item)
+	return item, err
+}
+
+// CreateTemplate creates a template.
+func (c *Client) CreateTemplate(ctx context.Context, item models.Template) (models.Template, error) {
+	created := models.Template{}
+	_, err := c.do(ctx, http.MethodPost, "/templates/", nil, item, &created)
+	return created, err
+}
+
+// UpdateTemplate replaces the template with the same id.
+func (c *Client) UpdateTemplate(ctx context.Context, item models.Template) (models.Template, error) {
+	updated := models.Template{}
+	_, err := c.do(ctx, http.MethodPut, itemPath("/templates/", item.Id), nil, item, &updated)
+	return updated, err
+}
+
+// DeleteTemplate deletes the template.
+func (c *Client) DeleteTemplate(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, itemPath("/templates/", id), nil, nil, nil)
+	return err
+}
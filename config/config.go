@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"io/ioutil"
+	"time"
 
 	log "github.com/gophish/gophish/logger"
 )
@@ -15,27 +16,420 @@ type AdminServer struct {
 	KeyPath              string   `json:"key_path"`
 	CSRFKey              string   `json:"csrf_key"`
 	AllowedInternalHosts []string `json:"allowed_internal_hosts"`
+	EnableGraphQL        bool     `json:"enable_graphql"`
+	EnableGRPC           bool     `json:"enable_grpc"`
+	TrustedOrigins       []string `json:"trusted_origins"`
+	// BasePath serves the admin server under a path prefix, such as
+	// "/gophish", for reverse proxies which route requests by their path.
+	BasePath string `json:"base_path"`
+	// TrustedProxies are the addresses and CIDR ranges of the reverse
+	// proxies whose X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host
+	// headers are used. If none are given, every request's headers are used.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// SessionIdleMinutes logs users out after they've been inactive for
+	// that many minutes, and SessionLifetimeMinutes logs them out that many
+	// minutes after they logged in (5 days by default). If SingleSession is
+	// set, users logging in are logged out of their other sessions.
+	SessionIdleMinutes     int  `json:"session_idle_minutes"`
+	SessionLifetimeMinutes int  `json:"session_lifetime_minutes"`
+	SingleSession          bool `json:"single_session"`
+	// AllowedNetworks are the IP addresses and CIDR ranges of the clients
+	// allowed to reach the admin server. If none are given, every client is
+	// allowed.
+	AllowedNetworks []string `json:"allowed_networks"`
+	// ClientCAPath is the path to the PEM encoded certificates of the
+	// certificate authorities which sign client certificates. If set, clients
+	// must present a certificate signed by one of them. This requires TLS.
+	ClientCAPath string `json:"client_ca_path"`
 }
 
 // PhishServer represents the Phish server configuration details
 type PhishServer struct {
-	ListenURL string `json:"listen_url"`
-	UseTLS    bool   `json:"use_tls"`
-	CertPath  string `json:"cert_path"`
-	KeyPath   string `json:"key_path"`
+	ListenURL          string `json:"listen_url"`
+	UseTLS             bool   `json:"use_tls"`
+	CertPath           string `json:"cert_path"`
+	KeyPath            string `json:"key_path"`
+	CaptureRequests    bool   `json:"capture_requests"`
+	CaptureMaxBodySize int64  `json:"capture_max_body_size"`
+	// TrustedProxies are the addresses and CIDR ranges of the reverse
+	// proxies whose forwarding headers are used to record the recipient's
+	// address. If none are given, every request's headers are used.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// ClientIPHeaders are the headers holding the recipient's address, read
+	// in order, such as ["CF-Connecting-IP", "X-Forwarded-For"]. If none are
+	// given, X-Forwarded-For and X-Real-IP are used.
+	ClientIPHeaders []string `json:"client_ip_headers"`
 }
 
+// DataRetention represents the number of days that potentially sensitive
+// campaign data is kept before being purged. CampaignDays is counted from
+// the date a campaign was completed. A value of zero disables purging for
+// that type of data.
+//
+// ArchiveDays is the number of days after a campaign is completed that its
+// events are moved to the archive table, keeping the tables queried by the
+// dashboard and workers small. A value of zero disables archiving.
+type DataRetention struct {
+	EventDetailsDays int `json:"event_details_days"`
+	MailLogsDays     int `json:"maillogs_days"`
+	CampaignDays     int `json:"campaign_days"`
+	ArchiveDays      int `json:"archive_days"`
+}
+
+// Encryption represents the keys used to encrypt sensitive data, such as
+// captured credentials and stored passwords, in the database. Keys are 32
+// bytes, encoded as hex or base64. The key can instead be read from KeyFile,
+// which allows it to be provided by a secrets manager. PreviousKeys are only
+// used to decrypt existing data so that the key can be rotated.
+//
+// AnonymizeKey is the key used to pseudonymize the email addresses of
+// recipients of campaigns in privacy mode. It's kept separate from the
+// encryption keys, since changing it stops pseudonyms from matching.
+type Encryption struct {
+	Key          string   `json:"key"`
+	KeyFile      string   `json:"key_file"`
+	PreviousKeys []string `json:"previous_keys"`
+	AnonymizeKey string   `json:"anonymize_key"`
+}
+
+// Secrets represents the external secrets managers which credentials, such as
+// sending profile and IMAP passwords, can be referenced from instead of being
+// stored in the database. A provider is only enabled once it's configured, and
+// references are always resolved relative to the provider's prefix so that
+// users can only reference secrets intended for Gophish.
+type Secrets struct {
+	CacheTTL  int          `json:"cache_ttl"`
+	EnvPrefix string       `json:"env_prefix"`
+	Directory string       `json:"directory"`
+	Vault     VaultSecrets `json:"vault"`
+	AWS       AWSSecrets   `json:"aws"`
+}
+
+// VaultSecrets represents the configuration for reading secrets from a
+// HashiCorp Vault KV secrets engine. The Address and Token default to the
+// VAULT_ADDR and VAULT_TOKEN environment variables.
+type VaultSecrets struct {
+	Address    string `json:"address"`
+	Token      string `json:"token"`
+	Namespace  string `json:"namespace"`
+	PathPrefix string `json:"path_prefix"`
+}
+
+// AWSSecrets represents the configuration for reading secrets from AWS
+// Secrets Manager. Credentials default to the standard AWS environment
+// variables.
+type AWSSecrets struct {
+	Region          string `json:"region"`
+	Endpoint        string `json:"endpoint"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	NamePrefix      string `json:"name_prefix"`
+}
+
+// ObjectStorage represents an S3 compatible bucket used to store attachment
+// content and static assets instead of the database and the static
+// directory. It's enabled once a bucket is configured. Path style requests,
+// which most self-hosted services require, are used when an Endpoint is set.
+// Credentials default to the standard AWS environment variables.
+type ObjectStorage struct {
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Endpoint        string `json:"endpoint"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	Prefix          string `json:"prefix"`
+}
+
+// SpamScoring represents the spam filter used to score templates before
+// they're sent. The Engine is either "rspamd" or "spamassassin", and the Host
+// is the address of Rspamd's normal worker (usually port 11333) or of spamd
+// (usually port 783). The Password is sent to Rspamd, if it requires one.
+type SpamScoring struct {
+	Engine   string `json:"engine"`
+	Host     string `json:"host"`
+	Password string `json:"password"`
+}
+
+// PhishScale configures the difficulty ratings of templates, which follow
+// the NIST Phish Scale. Templates with at most FewCues cues have few cues, and
+// those with at least ManyCues have many.
+type PhishScale struct {
+	FewCues  int `json:"few_cues"`
+	ManyCues int `json:"many_cues"`
+}
+
+// The default cue counts, as recommended by NIST
+const (
+	DefaultFewCues  = 8
+	DefaultManyCues = 15
+)
+
+// Thresholds returns the cue counts, using the defaults for those which
+// aren't set.
+func (p PhishScale) Thresholds() (int, int) {
+	few, many := p.FewCues, p.ManyCues
+	if few == 0 {
+		few = DefaultFewCues
+	}
+	if many == 0 {
+		many = DefaultManyCues
+	}
+	return few, many
+}
+
+// PasswordPolicy is the policy passwords submitted to landing pages are
+// checked against when the page scores passwords instead of capturing them.
+// Passwords are scored from 0 (easily guessed) to 4 (hard to guess), and
+// those scoring below MinScore, or shorter than MinLength, violate the policy.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	MinScore      int  `json:"min_score"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+}
+
+// The default password policy minimums
+const (
+	DefaultPasswordMinLength = 8
+	DefaultPasswordMinScore  = 3
+)
+
+// MaxPasswordScore is the score of the strongest passwords
+const MaxPasswordScore = 4
+
+// Minimums returns the minimum length and score, using the defaults for
+// those which aren't set.
+func (p PasswordPolicy) Minimums() (int, int) {
+	length, score := p.MinLength, p.MinScore
+	if length == 0 {
+		length = DefaultPasswordMinLength
+	}
+	if score == 0 {
+		score = DefaultPasswordMinScore
+	}
+	return length, score
+}
+
+// AdminPasswordPolicy is the policy the passwords of Gophish user accounts
+// must meet. Passwords must have at least MinLength characters (8 by
+// default), contain the required kinds of characters, and not be one of the
+// banned passwords, which are given in BannedPasswords or one per line in the
+// file at BannedPasswordsPath. Passwords expire after ExpiryDays, and can't
+// be changed to the current password or any of the HistorySize passwords
+// before it. Zero values disable the expiry and the history.
+type AdminPasswordPolicy struct {
+	MinLength           int      `json:"min_length"`
+	RequireUpper        bool     `json:"require_upper"`
+	RequireLower        bool     `json:"require_lower"`
+	RequireDigit        bool     `json:"require_digit"`
+	RequireSymbol       bool     `json:"require_symbol"`
+	BannedPasswords     []string `json:"banned_passwords"`
+	BannedPasswordsPath string   `json:"banned_passwords_path"`
+	ExpiryDays          int      `json:"expiry_days"`
+	HistorySize         int      `json:"history_size"`
+}
+
+// MaxPasswordHistorySize is the most previous passwords which can be kept,
+// since each is checked when a password is changed.
+const MaxPasswordHistorySize = 24
+
+// MachineOpens configures the detection of opens made by mail privacy
+// proxies, which load the tracking image whether or not the email is read.
+// The FeedURLs are fetched every RefreshHours (24 by default) for the IP
+// ranges of the proxies, such as
+// https://mask-api.icloud.com/egress-ip-ranges.csv for Apple Mail Privacy
+// Protection.
+type MachineOpens struct {
+	FeedURLs     []string `json:"feed_urls"`
+	RefreshHours int      `json:"refresh_hours"`
+}
+
+// SimulatedSending configures simulated sending. If it's enabled, emails
+// are built and recorded as sent, but are saved instead of being sent. They're
+// saved to object storage if it's configured, and otherwise to files in Path
+// ("simulated_mail" by default).
+type SimulatedSending struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path"`
+}
+
+// DomainReputation configures the checks made of the domains in the domain
+// inventory every CheckHours (24 by default). If a VirusTotal API key is
+// given, each domain's categories and detections are also looked up, and
+// domains detected as malicious are marked as burned.
+type DomainReputation struct {
+	VirusTotalAPIKey string `json:"virustotal_api_key"`
+	CheckHours       int    `json:"check_hours"`
+}
+
+// DefaultDomainCheckHours is how often domains are checked if no interval is
+// configured
+const DefaultDomainCheckHours = 24
+
+// Interval returns how often domains are checked.
+func (d DomainReputation) Interval() time.Duration {
+	if d.CheckHours == 0 {
+		return DefaultDomainCheckHours * time.Hour
+	}
+	return time.Duration(d.CheckHours) * time.Hour
+}
+
+// DNSProviders configures the DNS providers used to create the records of
+// campaign domains. Cloudflare is enabled by giving an API token with the
+// Zone:Read and DNS:Edit permissions. Route 53 credentials which aren't set
+// are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// AWS_SESSION_TOKEN environment variables.
+type DNSProviders struct {
+	Cloudflare CloudflareDNS `json:"cloudflare"`
+	Route53    Route53DNS    `json:"route53"`
+}
+
+// CloudflareDNS represents the Cloudflare API credentials.
+type CloudflareDNS struct {
+	APIToken string `json:"api_token"`
+}
+
+// Route53DNS represents the Amazon Route 53 API credentials.
+type Route53DNS struct {
+	Enabled         bool   `json:"enabled"`
+	Endpoint        string `json:"endpoint"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// LeaderElection represents the configuration for running multiple Gophish
+// instances against the same database. When enabled, only the elected leader
+// sends campaign emails and polls IMAP mailboxes, while every instance serves
+// the admin and phishing servers. The InstanceID defaults to the hostname and
+// process id.
+type LeaderElection struct {
+	Enabled      bool   `json:"enabled"`
+	InstanceID   string `json:"instance_id"`
+	LeaseSeconds int    `json:"lease_seconds"`
+}
+
+// Worker configures how the background worker sends campaign emails. Every
+// poll, up to BatchSize maillogs which are due are loaded, and the emails of
+// up to Concurrency campaigns and sending profiles are sent at once. Zero
+// values keep the defaults of polling every minute, with no limits.
+type Worker struct {
+	Concurrency int `json:"concurrency"`
+	BatchSize   int `json:"batch_size"`
+	PollSeconds int `json:"poll_seconds"`
+}
+
+// DefaultWorkerPollSeconds is how often the worker polls for maillogs if no
+// interval is configured
+const DefaultWorkerPollSeconds = 60
+
+// The upper bounds of the worker settings
+const (
+	MaxWorkerConcurrency = 1000
+	MaxWorkerBatchSize   = 100000
+	MaxWorkerPollSeconds = 3600
+)
+
+// PollInterval returns how often the worker polls for maillogs.
+func (w Worker) PollInterval() time.Duration {
+	if w.PollSeconds == 0 {
+		return DefaultWorkerPollSeconds * time.Second
+	}
+	return time.Duration(w.PollSeconds) * time.Second
+}
+
+// Tracing configures the export of traces to an OpenTelemetry collector
+// using OTLP over HTTP. Traces are only recorded when an endpoint is given.
+type Tracing struct {
+	// Endpoint is the base URL of the collector, such as
+	// http://localhost:4318. Traces are sent to its /v1/traces path.
+	Endpoint string `json:"endpoint"`
+	// Headers are sent with each export as name=value pairs, such as the
+	// API key of a hosted collector.
+	Headers []string `json:"headers"`
+	// ServiceName identifies this instance in the traces. It defaults to
+	// "gophish".
+	ServiceName string `json:"service_name"`
+}
+
+// SQLite configures the connection to a sqlite3 database. The WAL journal
+// mode lets the phishing server read the database while the worker writes to
+// it, and writes which find the database locked are retried for up to
+// BusyTimeoutMs before failing. Zero values use the defaults.
+type SQLite struct {
+	JournalMode   string `json:"journal_mode"`
+	BusyTimeoutMs int    `json:"busy_timeout_ms"`
+	// MaxConnections is the number of connections opened to databases using
+	// the WAL journal mode, which lets reads happen concurrently. Writes are
+	// still made one at a time. Other journal modes use one connection.
+	MaxConnections int `json:"max_connections"`
+}
+
+// The defaults for sqlite3 databases
+const (
+	DefaultSQLiteJournalMode    = "wal"
+	DefaultSQLiteBusyTimeoutMs  = 5000
+	DefaultSQLiteMaxConnections = 4
+)
+
 // Config represents the configuration information.
 type Config struct {
-	AdminConf      AdminServer `json:"admin_server"`
-	PhishConf      PhishServer `json:"phish_server"`
-	DBName         string      `json:"db_name"`
-	DBPath         string      `json:"db_path"`
-	DBSSLCaPath    string      `json:"db_sslca_path"`
-	MigrationsPath string      `json:"migrations_prefix"`
-	TestFlag       bool        `json:"test_flag"`
-	ContactAddress string      `json:"contact_address"`
-	Logging        *log.Config `json:"logging"`
+	AdminConf      AdminServer    `json:"admin_server"`
+	PhishConf      PhishServer    `json:"phish_server"`
+	DBName         string         `json:"db_name"`
+	DBPath         string         `json:"db_path"`
+	DBSSLCaPath    string         `json:"db_sslca_path"`
+	DBReplicaPath  string         `json:"db_replica_path"`
+	SQLite         SQLite         `json:"sqlite"`
+	MigrationsPath string         `json:"migrations_prefix"`
+	TestFlag       bool           `json:"test_flag"`
+	ContactAddress string         `json:"contact_address"`
+	Logging        *log.Config    `json:"logging"`
+	DataRetention  DataRetention  `json:"data_retention"`
+	Encryption     Encryption     `json:"encryption"`
+	Secrets        Secrets        `json:"secrets"`
+	BootstrapPath  string         `json:"bootstrap_path"`
+	LeaderElection LeaderElection `json:"leader_election"`
+	ObjectStorage  ObjectStorage  `json:"object_storage"`
+	Proxy          string         `json:"proxy"`
+	SpamScoring    SpamScoring    `json:"spam_scoring"`
+	PhishScale     PhishScale     `json:"phish_scale"`
+	MachineOpens   MachineOpens   `json:"machine_opens"`
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
+	// AdminPasswordPolicy is the policy for the passwords of user accounts.
+	AdminPasswordPolicy AdminPasswordPolicy `json:"admin_password_policy"`
+	// DomainReputation configures the checks of the domain inventory.
+	DomainReputation DomainReputation `json:"domain_reputation"`
+	// DNSProviders are used to create the DNS records of domains.
+	DNSProviders DNSProviders `json:"dns_providers"`
+	// Worker configures how campaign emails are sent.
+	Worker Worker `json:"worker"`
+	// Tracing configures the export of traces of campaign launches, email
+	// sending and event recording.
+	Tracing Tracing `json:"tracing"`
+	// SenderDomains are the domains templates may use for their envelope
+	// sender and Reply-To address. Subdomains are also allowed. If no
+	// domains are given, any domain can be used.
+	SenderDomains []string `json:"sender_domains"`
+	// OpenRedirectDomains are the trusted third party domains whose open
+	// redirects campaigns may wrap their links in. Subdomains are also
+	// allowed. If no domains are given, open redirects can't be used.
+	OpenRedirectDomains []string `json:"open_redirect_domains"`
+	// SimulatedSending saves every campaign's emails instead of sending
+	// them, such as for a test instance.
+	SimulatedSending SimulatedSending `json:"simulated_sending"`
+	// FileSendingPath is the directory which Maildir and Directory sending
+	// profiles write emails within. If it isn't set, these profiles can't be
+	// used.
+	FileSendingPath string `json:"file_sending_path"`
+	// ApprovalTags are the target tags, such as "vip", which require
+	// campaigns sent to targets with them to be approved by another
+	// administrator before any emails are sent.
+	ApprovalTags []string `json:"approval_tags"`
+	// Plugins are the paths of Go plugins which provide event processors.
+	// See the plugins package for how they're built.
+	Plugins []string `json:"plugins"`
 }
 
 // Version contains the current gophish version
@@ -44,7 +438,8 @@ var Version = ""
 // ServerName is the server type that is returned in the transparency response.
 const ServerName = "gophish"
 
-// LoadConfig loads the configuration from the specified filepath
+// LoadConfig loads the configuration from the specified filepath, applying
+// any overrides set in the environment.
 func LoadConfig(filepath string) (*Config, error) {
 	// Get the config file
 	configFile, err := ioutil.ReadFile(filepath)
@@ -59,6 +454,14 @@ func LoadConfig(filepath string) (*Config, error) {
 	if config.Logging == nil {
 		config.Logging = &log.Config{}
 	}
+	err = config.ApplyEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
 	// Choosing the migrations directory based on the database used.
 	config.MigrationsPath = config.MigrationsPath + config.DBName
 	// Explicitly set the TestFlag to false to prevent config.json overrides
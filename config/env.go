@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables which override
+// options in config.json. The rest of the variable name is the option's path
+// in config.json, in upper case and joined by underscores, such as
+// GOPHISH_ADMIN_SERVER_LISTEN_URL for admin_server.listen_url.
+//
+// Each option can also be read from a file, such as a mounted secret, by
+// appending _FILE to the variable name.
+const EnvPrefix = "GOPHISH_"
+
+// FileEnvSuffix is the suffix of environment variables which name a file to
+// read an option from.
+const FileEnvSuffix = "_FILE"
+
+// Redacted is the value which replaces secrets in a redacted configuration.
+const Redacted = "REDACTED"
+
+// dsnPassword matches the password in a MySQL DSN, such as
+// user:password@tcp(localhost:3306)/gophish
+var dsnPassword = regexp.MustCompile(`^([^:@/]*):[^@]*@`)
+
+// envOption is an option which can be overridden from the environment.
+type envOption struct {
+	Name  string
+	Value reflect.Value
+}
+
+// envOptions returns the options in v which can be overridden from the
+// environment, named after their path in config.json.
+func envOptions(v reflect.Value, prefix string) []envOption {
+	options := []envOption{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" || tag == "test_flag" {
+			continue
+		}
+		name := prefix + strings.ToUpper(tag)
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.Struct:
+			options = append(options, envOptions(f, name+"_")...)
+		case f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct:
+			if f.IsNil() {
+				f.Set(reflect.New(f.Type().Elem()))
+			}
+			options = append(options, envOptions(f.Elem(), name+"_")...)
+		default:
+			options = append(options, envOption{Name: name, Value: f})
+		}
+	}
+	return options
+}
+
+// lookupEnv returns the value of the option from the environment, reading it
+// from a file if the _FILE variable is set. Trailing newlines are removed
+// from files, since most editors add them.
+func lookupEnv(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	path, fromFile := os.LookupEnv(name + FileEnvSuffix)
+	if !fromFile {
+		return value, ok, nil
+	}
+	if ok {
+		return "", false, fmt.Errorf("only one of %s and %s%s can be set", name, name, FileEnvSuffix)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("error reading %s%s: %v", name, FileEnvSuffix, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// setOption parses the value according to the option's type. Lists are given
+// as comma separated values.
+func setOption(o envOption, value string) error {
+	switch o.Value.Kind() {
+	case reflect.String:
+		o.Value.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: expected true or false", o.Name)
+		}
+		o.Value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: expected a number", o.Name)
+		}
+		o.Value.SetInt(n)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		o.Value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%s can't be set from the environment", o.Name)
+	}
+	return nil
+}
+
+// ApplyEnv overrides options in the configuration with those set in the
+// environment.
+func (c *Config) ApplyEnv() error {
+	for _, o := range envOptions(reflect.ValueOf(c).Elem(), EnvPrefix) {
+		value, ok, err := lookupEnv(o.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		err = setOption(o, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redact returns a copy of the configuration with its secrets, such as keys
+// and the database password, replaced so that it can be safely displayed.
+func (c *Config) Redact() *Config {
+	r := *c
+	if c.Logging != nil {
+		logging := *c.Logging
+		r.Logging = &logging
+	}
+	redact := func(s *string) {
+		if *s != "" {
+			*s = Redacted
+		}
+	}
+	redact(&r.AdminConf.CSRFKey)
+	redact(&r.Encryption.Key)
+	redact(&r.Encryption.AnonymizeKey)
+	redact(&r.Secrets.Vault.Token)
+	redact(&r.Secrets.AWS.SecretAccessKey)
+	redact(&r.ObjectStorage.SecretAccessKey)
+	redact(&r.SpamScoring.Password)
+	redact(&r.DomainReputation.VirusTotalAPIKey)
+	redact(&r.DNSProviders.Cloudflare.APIToken)
+	redact(&r.DNSProviders.Route53.SecretAccessKey)
+	if len(c.Tracing.Headers) > 0 {
+		r.Tracing.Headers = make([]string, len(c.Tracing.Headers))
+		for i, header := range c.Tracing.Headers {
+			r.Tracing.Headers[i] = strings.SplitN(header, "=", 2)[0] + "=" + Redacted
+		}
+	}
+	if len(c.Encryption.PreviousKeys) > 0 {
+		r.Encryption.PreviousKeys = make([]string, len(c.Encryption.PreviousKeys))
+		for i := range r.Encryption.PreviousKeys {
+			r.Encryption.PreviousKeys[i] = Redacted
+		}
+	}
+	// The migrations path is shown as configured, before the database name
+	// is appended, so that the output can be used as a config.json.
+	r.MigrationsPath = strings.TrimSuffix(c.MigrationsPath, c.DBName)
+	r.DBPath = dsnPassword.ReplaceAllString(c.DBPath, "${1}:"+Redacted+"@")
+	r.DBReplicaPath = dsnPassword.ReplaceAllString(c.DBReplicaPath, "${1}:"+Redacted+"@")
+	if u, err := url.Parse(c.Proxy); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+			r.Proxy = u.String()
+		}
+	}
+	return &r
+}
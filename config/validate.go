@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gophish/gophish/dialer"
+)
+
+// Validate checks that the configuration can be used to start Gophish, so
+// that mistakes are reported before anything is started.
+func (c *Config) Validate() error {
+	switch c.DBName {
+	case "sqlite3", "mysql":
+	default:
+		return fmt.Errorf("invalid db_name %q: expected sqlite3 or mysql", c.DBName)
+	}
+	if c.DBPath == "" {
+		return fmt.Errorf("db_path is required")
+	}
+	switch strings.ToLower(c.SQLite.JournalMode) {
+	case "", "wal", "delete", "truncate", "persist":
+	default:
+		return fmt.Errorf("invalid sqlite.journal_mode %q: expected wal, delete, truncate or persist", c.SQLite.JournalMode)
+	}
+	if c.SQLite.BusyTimeoutMs < 0 {
+		return fmt.Errorf("sqlite.busy_timeout_ms can't be negative")
+	}
+	if c.SQLite.MaxConnections < 0 {
+		return fmt.Errorf("sqlite.max_connections can't be negative")
+	}
+	err := validateListener("admin_server", c.AdminConf.ListenURL, c.AdminConf.UseTLS, c.AdminConf.CertPath, c.AdminConf.KeyPath)
+	if err != nil {
+		return err
+	}
+	err = validateListener("phish_server", c.PhishConf.ListenURL, c.PhishConf.UseTLS, c.PhishConf.CertPath, c.PhishConf.KeyPath)
+	if err != nil {
+		return err
+	}
+	// The CSRF protection compares trusted origins to the host of the
+	// Referer header, so they can't include a scheme.
+	for _, origin := range c.AdminConf.TrustedOrigins {
+		if strings.Contains(origin, "://") || strings.Contains(origin, "/") {
+			return fmt.Errorf("invalid admin_server.trusted_origins entry %q: expected a host, such as gophish.example.com:3333", origin)
+		}
+	}
+	if p := c.AdminConf.BasePath; p != "" && (!strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") || strings.ContainsAny(p, "?#")) {
+		return fmt.Errorf("invalid admin_server.base_path %q: expected a path such as /gophish", p)
+	}
+	if c.AdminConf.SessionIdleMinutes < 0 || c.AdminConf.SessionLifetimeMinutes < 0 {
+		return fmt.Errorf("admin_server session timeouts can't be negative")
+	}
+	err = validateNetworks("admin_server.allowed_networks", c.AdminConf.AllowedNetworks)
+	if err != nil {
+		return err
+	}
+	if c.AdminConf.ClientCAPath != "" && !c.AdminConf.UseTLS {
+		return fmt.Errorf("admin_server.client_ca_path requires admin_server.use_tls")
+	}
+	err = validateTrustedProxies("admin_server", c.AdminConf.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	err = validateTrustedProxies("phish_server", c.PhishConf.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	for _, header := range c.PhishConf.ClientIPHeaders {
+		header = strings.TrimSpace(header)
+		if header == "" || strings.ContainsAny(header, " :\t\r\n") {
+			return fmt.Errorf("invalid phish_server.client_ip_headers entry %q: expected a header name, such as CF-Connecting-IP", header)
+		}
+	}
+	if c.PhishConf.CaptureMaxBodySize < 0 {
+		return fmt.Errorf("phish_server.capture_max_body_size can't be negative")
+	}
+	if c.DataRetention.EventDetailsDays < 0 || c.DataRetention.MailLogsDays < 0 || c.DataRetention.CampaignDays < 0 || c.DataRetention.ArchiveDays < 0 {
+		return fmt.Errorf("data_retention days can't be negative")
+	}
+	if c.LeaderElection.LeaseSeconds < 0 {
+		return fmt.Errorf("leader_election.lease_seconds can't be negative")
+	}
+	if c.ObjectStorage.Bucket != "" && c.ObjectStorage.Region == "" {
+		return fmt.Errorf("object_storage.region is required when a bucket is configured")
+	}
+	if _, err := dialer.ParseProxy(c.Proxy); err != nil {
+		return fmt.Errorf("invalid proxy: %v", err)
+	}
+	switch c.SpamScoring.Engine {
+	case "":
+	case "rspamd", "spamassassin":
+		if _, _, err := net.SplitHostPort(c.SpamScoring.Host); err != nil {
+			return fmt.Errorf("invalid spam_scoring.host %q: %v", c.SpamScoring.Host, err)
+		}
+	default:
+		return fmt.Errorf("invalid spam_scoring.engine %q: expected rspamd or spamassassin", c.SpamScoring.Engine)
+	}
+	if c.PhishScale.FewCues < 0 || c.PhishScale.ManyCues < 0 {
+		return fmt.Errorf("invalid phish_scale: cue counts can't be negative")
+	}
+	if few, many := c.PhishScale.Thresholds(); few >= many {
+		return fmt.Errorf("invalid phish_scale: few_cues must be less than many_cues")
+	}
+	if c.PasswordPolicy.MinLength < 0 {
+		return fmt.Errorf("password_policy.min_length can't be negative")
+	}
+	if c.PasswordPolicy.MinScore < 0 || c.PasswordPolicy.MinScore > MaxPasswordScore {
+		return fmt.Errorf("invalid password_policy.min_score: expected a score from 0 to %d", MaxPasswordScore)
+	}
+	if c.AdminPasswordPolicy.MinLength < 0 {
+		return fmt.Errorf("admin_password_policy.min_length can't be negative")
+	}
+	if c.AdminPasswordPolicy.ExpiryDays < 0 {
+		return fmt.Errorf("admin_password_policy.expiry_days can't be negative")
+	}
+	if c.AdminPasswordPolicy.HistorySize < 0 || c.AdminPasswordPolicy.HistorySize > MaxPasswordHistorySize {
+		return fmt.Errorf("invalid admin_password_policy.history_size: expected 0 to %d", MaxPasswordHistorySize)
+	}
+	if c.MachineOpens.RefreshHours < 0 {
+		return fmt.Errorf("machine_opens.refresh_hours can't be negative")
+	}
+	if c.DomainReputation.CheckHours < 0 {
+		return fmt.Errorf("domain_reputation.check_hours can't be negative")
+	}
+	if c.Worker.Concurrency < 0 || c.Worker.Concurrency > MaxWorkerConcurrency {
+		return fmt.Errorf("invalid worker.concurrency: expected 0 to %d", MaxWorkerConcurrency)
+	}
+	if c.Worker.BatchSize < 0 || c.Worker.BatchSize > MaxWorkerBatchSize {
+		return fmt.Errorf("invalid worker.batch_size: expected 0 to %d", MaxWorkerBatchSize)
+	}
+	if c.Worker.PollSeconds < 0 || c.Worker.PollSeconds > MaxWorkerPollSeconds {
+		return fmt.Errorf("invalid worker.poll_seconds: expected 0 to %d", MaxWorkerPollSeconds)
+	}
+	if c.Tracing.Endpoint != "" {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid tracing.endpoint %q: expected an http or https URL", c.Tracing.Endpoint)
+		}
+	}
+	for _, header := range c.Tracing.Headers {
+		if name := strings.SplitN(header, "=", 2)[0]; !strings.Contains(header, "=") || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid tracing.headers entry: expected name=value")
+		}
+	}
+	for _, feed := range c.MachineOpens.FeedURLs {
+		u, err := url.Parse(feed)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid machine_opens.feed_urls entry %q: expected an http or https URL", feed)
+		}
+	}
+	return nil
+}
+
+func validateListener(name, listenURL string, useTLS bool, certPath, keyPath string) error {
+	_, _, err := net.SplitHostPort(listenURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s.listen_url %q: %v", name, listenURL, err)
+	}
+	if useTLS && (certPath == "" || keyPath == "") {
+		return fmt.Errorf("%s.cert_path and %s.key_path are required when use_tls is enabled", name, name)
+	}
+	return nil
+}
+
+func validateTrustedProxies(name string, proxies []string) error {
+	return validateNetworks(name+".trusted_proxies", proxies)
+}
+
+// validateNetworks ensures each entry is an IP address or CIDR range.
+func validateNetworks(field string, entries []string) error {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			return fmt.Errorf("invalid %s entry %q: expected an IP address or CIDR range", field, entry)
+		}
+	}
+	return nil
+}
@@ -36,7 +36,7 @@ func setupTest(t *testing.T) *testContext {
 	if err != nil {
 		t.Fatalf("error getting admin user: %v", err)
 	}
-	ctx.apiKey = u.ApiKey
+	ctx.apiKey = string(u.ApiKey)
 	ctx.admin = u
 	ctx.apiServer = NewServer()
 	return ctx
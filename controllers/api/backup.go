@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gophish/gophish/backup"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// MaxRestoreMemory is the amount of an uploaded backup held in memory during
+// a restore. Larger backups are written to a temporary file.
+const MaxRestoreMemory = 32 << 20
+
+type backupRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// Backup (/api/admin/backup) downloads an encrypted backup of the database
+// and the uploaded assets.
+func (as *Server) Backup(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		br := backupRequest{}
+		err := json.NewDecoder(r.Body).Decode(&br)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		if br.Passphrase == "" {
+			JSONResponse(w, models.Response{Success: false, Message: backup.ErrPassphraseRequired.Error()}, http.StatusBadRequest)
+			return
+		}
+		filename := fmt.Sprintf("gophish-%s.backup", time.Now().UTC().Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		// The backup is streamed, so errors can't be reported once it's
+		// started. The archive is incomplete, which is detected on restore.
+		_, err = backup.Create(w, br.Passphrase)
+		if err != nil {
+			log.FromContext(r.Context()).Errorf("error creating backup: %v", err)
+		}
+	}
+}
+
+// Restore (/api/admin/restore) replaces the database and adds the uploaded
+// assets from a backup.
+func (as *Server) Restore(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		err := r.ParseMultipartForm(MaxRestoreMemory)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error parsing the upload"}, http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "No backup file provided"}, http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		s, err := backup.Restore(f, r.FormValue("passphrase"))
+		switch {
+		case err == backup.ErrPassphraseRequired, err == backup.ErrInvalidArchive, err == backup.ErrDecrypt, err == backup.ErrNewerSchema:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		case err != nil:
+			log.FromContext(r.Context()).Errorf("error restoring backup: %v", err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, s, http.StatusOK)
+	}
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	ctx "github.com/gophish/gophish/context"
 	log "github.com/gophish/gophish/logger"
@@ -17,11 +20,39 @@ import (
 func (as *Server) Campaigns(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		cs, err := models.GetCampaigns(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
 		if err != nil {
-			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		cs, page, err := models.ListCampaigns(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
 		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, cs, http.StatusOK)
+	// DELETE: Delete the campaigns matching the filters
+	case r.Method == "DELETE":
+		opts, err := listOptions(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		n, err := models.DeleteCampaigns(ctx.Get(r, "user_id").(int64), opts.Filters)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting campaigns"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Campaigns deleted successfully!", Data: bulkDeleteResult{Deleted: n}}, http.StatusOK)
 	//POST: Create a new campaign and return it as JSON
 	case r.Method == "POST":
 		c := models.Campaign{}
@@ -31,7 +62,7 @@ func (as *Server) Campaigns(w http.ResponseWriter, r *http.Request) {
 			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
 			return
 		}
-		err = models.PostCampaign(&c, ctx.Get(r, "user_id").(int64))
+		err = models.PostCampaignContext(r.Context(), &c, ctx.Get(r, "user_id").(int64))
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
@@ -49,16 +80,144 @@ func (as *Server) Campaigns(w http.ResponseWriter, r *http.Request) {
 func (as *Server) CampaignsSummary(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		cs, err := models.GetCampaignSummaries(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
 		if err != nil {
-			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		cs, page, err := models.ListCampaignSummaries(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, cs, http.StatusOK)
 	}
 }
 
+// CampaignsCompare compares the metrics of the campaigns given in the ids
+// parameter, either with the first campaign or, if the baseline parameter is
+// set, with the current user's other completed campaigns.
+func (as *Server) CampaignsCompare(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		ids := []int64{}
+		for _, param := range r.URL.Query()["ids"] {
+			for _, s := range strings.Split(param, ",") {
+				id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				if err != nil {
+					JSONResponse(w, models.Response{Success: false, Message: "Invalid campaign id " + s}, http.StatusBadRequest)
+					return
+				}
+				ids = append(ids, id)
+			}
+		}
+		baseline, _ := strconv.ParseBool(r.URL.Query().Get("baseline"))
+		cc, err := models.CompareCampaigns(ids, baseline, ctx.Get(r, "user_id").(int64))
+		switch {
+		case err == models.ErrComparisonCampaigns:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		case err == gorm.ErrRecordNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		case err != nil:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, cc, http.StatusOK)
+	}
+}
+
+// CampaignsDifficulty returns the click rates of the user's campaigns,
+// normalized by the difficulty of their templates.
+func (as *Server) CampaignsDifficulty(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		report, err := models.GetDifficultyReport(ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, report, http.StatusOK)
+	}
+}
+
+// The range of the campaign calendar, unless one is given
+const (
+	defaultCalendarPast   = 30 * 24 * time.Hour
+	defaultCalendarFuture = 60 * 24 * time.Hour
+)
+
+// CampaignsCalendar returns the campaigns running between the RFC 3339
+// timestamps given in the start and end parameters, warning of campaigns
+// which send to the same recipients within window_days of each other. Users
+// with the ModifySystem permission can set the all parameter to include every
+// user's campaigns, so that teams don't phish the same people in the same
+// week.
+func (as *Server) CampaignsCalendar(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		now := time.Now().UTC()
+		start := now.Add(-defaultCalendarPast)
+		end := now.Add(defaultCalendarFuture)
+		var err error
+		if s := r.URL.Query().Get("start"); s != "" {
+			start, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid start timestamp"}, http.StatusBadRequest)
+				return
+			}
+		}
+		if s := r.URL.Query().Get("end"); s != "" {
+			end, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid end timestamp"}, http.StatusBadRequest)
+				return
+			}
+		}
+		window := models.DefaultConflictWindowDays
+		if s := r.URL.Query().Get("window_days"); s != "" {
+			window, err = strconv.Atoi(s)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid window_days"}, http.StatusBadRequest)
+				return
+			}
+		}
+		all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+		if all {
+			currentUser := ctx.Get(r, "user").(models.User)
+			hasSystem, err := currentUser.HasPermission(models.PermissionModifySystem)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+				return
+			}
+			if !hasSystem {
+				JSONResponse(w, models.Response{Success: false, Message: http.StatusText(http.StatusForbidden)}, http.StatusForbidden)
+				return
+			}
+		}
+		cal, err := models.GetCampaignCalendar(ctx.Get(r, "user_id").(int64), all, start, end, window)
+		switch {
+		case err == models.ErrInvalidCalendarRange || err == models.ErrInvalidConflictWindow:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		case err != nil:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, cal, http.StatusOK)
+	}
+}
+
 // Campaign returns details about the requested campaign. If the campaign is not
 // valid, APICampaign returns null.
 func (as *Server) Campaign(w http.ResponseWriter, r *http.Request) {
@@ -66,7 +225,7 @@ func (as *Server) Campaign(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(vars["id"], 0, 64)
 	c, err := models.GetCampaign(id, ctx.Get(r, "user_id").(int64))
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 		JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
 		return
 	}
@@ -88,18 +247,186 @@ func (as *Server) Campaign(w http.ResponseWriter, r *http.Request) {
 func (as *Server) CampaignResults(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.ParseInt(vars["id"], 0, 64)
-	cr, err := models.GetCampaignResults(id, ctx.Get(r, "user_id").(int64))
+	opts, err := listOptions(r)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	cr, page, err := models.ListCampaignResults(id, ctx.Get(r, "user_id").(int64), opts)
+	if isListOptionsError(err) {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 		JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
 		return
 	}
 	if r.Method == "GET" {
+		setPageHeaders(w, r, page)
 		JSONResponse(w, cr, http.StatusOK)
 		return
 	}
 }
 
+// CampaignResultsDelta returns the results and events for a given campaign
+// which changed since the event id given in the since_id parameter, or the
+// RFC 3339 timestamp given in the since parameter. This lets dashboards poll
+// large campaigns without downloading every result each time.
+func (as *Server) CampaignResultsDelta(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		var since time.Time
+		var sinceID int64
+		var err error
+		if s := r.URL.Query().Get("since"); s != "" {
+			since, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid since timestamp"}, http.StatusBadRequest)
+				return
+			}
+		}
+		if s := r.URL.Query().Get("since_id"); s != "" {
+			sinceID, err = strconv.ParseInt(s, 10, 64)
+			if err != nil || sinceID < 0 {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid since_id"}, http.StatusBadRequest)
+				return
+			}
+		}
+		cd, err := models.GetCampaignResultsDelta(id, ctx.Get(r, "user_id").(int64), since, sinceID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, cd, http.StatusOK)
+	}
+}
+
+// CampaignResultTimeline returns the ordered events for a single recipient of
+// a given campaign, along with the time between them and the device and
+// location each came from.
+func (as *Server) CampaignResultTimeline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		t, err := models.GetResultTimeline(id, vars["rid"], ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Result not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, t, http.StatusOK)
+	}
+}
+
+// CampaignResultAnnotations replaces the tags and notes of a single result in
+// a given campaign.
+func (as *Server) CampaignResultAnnotations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "PUT":
+		a := models.ResultAnnotation{}
+		err := json.NewDecoder(r.Body).Decode(&a)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		result, err := models.AnnotateResult(id, vars["rid"], ctx.Get(r, "user_id").(int64), a)
+		switch {
+		case err == models.ErrResultNotesTooLong:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		case err == gorm.ErrRecordNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: "Result not found"}, http.StatusNotFound)
+			return
+		case err != nil:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, result, http.StatusOK)
+	}
+}
+
+// CampaignRequests returns the raw HTTP requests captured for clicked link
+// and submitted data events in a given campaign.
+func (as *Server) CampaignRequests(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		ers, err := models.GetEventRequests(id, ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, ers, http.StatusOK)
+	}
+}
+
+// CampaignPurge returns the purge audit records for a given campaign if
+// requested via GET. If requested via POST, CampaignPurge irreversibly removes
+// the captured credentials, event details, and raw requests for the campaign,
+// while keeping the campaign's statistics intact.
+func (as *Server) CampaignPurge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		cps, err := models.GetCampaignPurges(id, uid)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, cps, http.StatusOK)
+	case r.Method == "POST":
+		req := struct {
+			Reason string `json:"reason"`
+		}{}
+		// The request body is optional, so we only fail if something other
+		// than an empty body was sent.
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		cp, err := models.PurgeCampaignData(id, uid, req.Reason)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: "Error purging campaign data"}, http.StatusInternalServerError)
+			}
+			return
+		}
+		JSONResponse(w, cp, http.StatusOK)
+	}
+}
+
 // CampaignSummary returns the summary for a given campaign.
 func (as *Server) CampaignSummary(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -113,13 +440,118 @@ func (as *Server) CampaignSummary(w http.ResponseWriter, r *http.Request) {
 			} else {
 				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			}
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			return
 		}
 		JSONResponse(w, cs, http.StatusOK)
 	}
 }
 
+// CampaignPasswords returns how the passwords submitted to the campaign's
+// landing page scored against the password policy.
+func (as *Server) CampaignPasswords(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		ps, err := models.GetPasswordStats(id, ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, ps, http.StatusOK)
+	}
+}
+
+// CampaignAllowlist returns the header, senders and mail gateway rules needed
+// to allowlist the campaign's emails.
+func (as *Server) CampaignAllowlist(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		a, err := models.GetCampaignAllowlist(id, ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, a, http.StatusOK)
+	}
+}
+
+// CampaignProgress returns how many of the campaign's results have been
+// created, allowing the creation of large campaigns to be monitored.
+func (as *Server) CampaignProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		p, err := models.GetLaunchProgress(id, ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, p, http.StatusOK)
+	}
+}
+
+// CampaignMailLogs returns the emails waiting to be sent for a campaign. Emails
+// being retried include the SMTP transcript of the failed attempt.
+func (as *Server) CampaignMailLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		ms, err := models.GetCampaignMailLogs(id, ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, ms, http.StatusOK)
+	}
+}
+
+// CampaignWaves returns the waves of a campaign, along with the stats of each
+// wave's results.
+func (as *Server) CampaignWaves(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		ws, err := models.GetCampaignWaves(id, ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			} else {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			}
+			log.FromContext(r.Context()).Error(err)
+			return
+		}
+		JSONResponse(w, ws, http.StatusOK)
+	}
+}
+
 // CampaignComplete effectively "ends" a campaign.
 // Future phishing emails clicked will return a simple "404" page.
 func (as *Server) CampaignComplete(w http.ResponseWriter, r *http.Request) {
@@ -135,3 +567,171 @@ func (as *Server) CampaignComplete(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: true, Message: "Campaign completed successfully!"}, http.StatusOK)
 	}
 }
+
+// CampaignResend re-queues the emails which failed to send in a campaign,
+// optionally using a different sending profile.
+func (as *Server) CampaignResend(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "POST":
+		req := models.ResendRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		rr, err := models.ResendFailedEmails(id, ctx.Get(r, "user_id").(int64), req)
+		if err == gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		}
+		if err == models.ErrSMTPNotFound || err == models.ErrCampaignArchived || err == models.ErrCampaignAnonymized {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error re-sending emails"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, rr, http.StatusOK)
+	}
+}
+
+// CampaignClone creates a new campaign from an existing one, replacing the
+// groups, sending profile, URL, and launch date given in the request.
+func (as *Server) CampaignClone(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "POST":
+		req := models.CloneRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		c, err := models.CloneCampaign(r.Context(), id, ctx.Get(r, "user_id").(int64), req)
+		if err == gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if c.Status == models.CampaignInProgress {
+			go as.worker.LaunchCampaign(c)
+		}
+		JSONResponse(w, c, http.StatusCreated)
+	}
+}
+
+// CampaignApprove approves a campaign sent to targets with an approval tag.
+// Campaigns can't be approved by their owner.
+func (as *Server) CampaignApprove(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "POST":
+		c, err := models.ApproveCampaign(id, ctx.Get(r, "user").(models.User))
+		switch err {
+		case nil:
+			JSONResponse(w, c, http.StatusOK)
+		case gorm.ErrRecordNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+		case models.ErrCampaignNotPending, models.ErrSelfApproval:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		default:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error approving campaign"}, http.StatusInternalServerError)
+		}
+	}
+}
+
+// CampaignNotFoundPage sets the landing page a campaign serves in place of the
+// phishing server's 404 response.
+func (as *Server) CampaignNotFoundPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "PUT":
+		req := models.NotFoundPageRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		err = models.SetCampaignNotFoundPage(id, ctx.Get(r, "user_id").(int64), req.PageId)
+		switch err {
+		case nil:
+			JSONResponse(w, models.Response{Success: true, Message: "Not found page updated successfully!"}, http.StatusOK)
+		case gorm.ErrRecordNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+		case models.ErrPageNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		default:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error updating not found page"}, http.StatusInternalServerError)
+		}
+	}
+}
+
+// CampaignPriority changes the priority of a campaign's queued emails.
+func (as *Server) CampaignPriority(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "PUT":
+		req := models.PriorityRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		err = models.SetCampaignPriority(id, ctx.Get(r, "user_id").(int64), req.Priority)
+		switch err {
+		case nil:
+			JSONResponse(w, models.Response{Success: true, Message: "Priority updated successfully!"}, http.StatusOK)
+		case gorm.ErrRecordNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+		case models.ErrInvalidPriority:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		default:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error updating priority"}, http.StatusInternalServerError)
+		}
+	}
+}
+
+// CampaignRecipients adds recipients to a campaign which has already been
+// launched.
+func (as *Server) CampaignRecipients(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "POST":
+		req := models.AddRecipientsRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		ar, err := models.AddCampaignRecipients(id, ctx.Get(r, "user_id").(int64), req)
+		switch err {
+		case nil:
+		case gorm.ErrRecordNotFound:
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		case models.ErrCampaignNotRunning, models.ErrGroupNotFound, models.ErrNoTargetsSpecified, models.ErrEmailNotSpecified:
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		default:
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error adding recipients"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ar, http.StatusCreated)
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestCampaignResultTimeline(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	c, err := models.GetCampaign(1, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	result := c.Results[0]
+	details := models.EventDetails{Browser: map[string]string{
+		"address":    "127.0.0.1",
+		"user-agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 14_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1 Mobile/15E148 Safari/604.1",
+	}}
+	for _, handle := range []func() error{
+		result.HandleEmailSent,
+		func() error { return result.HandleEmailOpened(details) },
+		func() error { return result.HandleClickedLink(details) },
+	} {
+		if err := handle(); err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+	}
+
+	get := func(rid string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/campaigns/1/results/%s/timeline", rid), nil)
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+	w := get(result.RId)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	got := models.Timeline{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("error decoding timeline: %v", err)
+	}
+	messages := []string{}
+	for _, e := range got.Events {
+		messages = append(messages, e.Message)
+	}
+	expected := []string{models.EventSent, models.EventOpened, models.EventClicked}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected events received. expected %v got %v", expected, messages)
+	}
+	clicked := got.Events[2]
+	if clicked.Device == nil || clicked.Device.Type != models.DeviceMobile || clicked.IP != "127.0.0.1" {
+		t.Fatalf("unexpected device received for clicked event: %#v %q", clicked.Device, clicked.IP)
+	}
+	if clicked.SinceSent == nil || got.Milestones.Clicked == nil || *got.Milestones.Clicked != *clicked.SinceSent {
+		t.Fatalf("expected the clicked milestone to match the clicked event")
+	}
+	if got.Milestones.Submitted != nil {
+		t.Fatalf("unexpected submitted milestone %v", *got.Milestones.Submitted)
+	}
+
+	w = get("unknown")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCampaignsCompare(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{query: "ids=1", code: http.StatusBadRequest},
+		{query: "ids=1,a", code: http.StatusBadRequest},
+		{query: "ids=1,42", code: http.StatusNotFound},
+		{query: "ids=1&baseline=true", code: http.StatusOK},
+		{query: "ids=1&ids=1", code: http.StatusOK},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/campaigns/compare?"+tc.query, nil)
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Fatalf("unexpected status code received for %s. expected %d got %d", tc.query, tc.code, w.Code)
+		}
+	}
+}
+
+func TestCampaignsDifficulty(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	tmpl, err := models.GetTemplate(1, 1)
+	if err != nil {
+		t.Fatalf("error getting template: %v", err)
+	}
+	tmpl.CueCount = 20
+	tmpl.PremiseAlignment = models.PremiseLow
+	if err := models.PutTemplate(&tmpl); err != nil {
+		t.Fatalf("error rating template: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/campaigns/difficulty", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	report := models.DifficultyReport{}
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("error decoding report: %v", err)
+	}
+	if len(report.Campaigns) != 1 || report.Campaigns[0].Difficulty != models.DifficultyLeast {
+		t.Fatalf("unexpected campaigns in report: %#v", report.Campaigns)
+	}
+}
+
+func TestCampaignResend(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	ms, err := models.GetMailLogsByCampaign(1)
+	if err != nil {
+		t.Fatalf("error getting maillogs: %v", err)
+	}
+	err = ms[0].Error(errors.New("550 mailbox unavailable"))
+	if err != nil {
+		t.Fatalf("error failing maillog: %v", err)
+	}
+
+	resend := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/campaigns/1/resend", bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+	w := resend(`{"smtp": "Missing"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	w = resend("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	got := models.ResendResult{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("error decoding result: %v", err)
+	}
+	if got.Queued != 1 {
+		t.Fatalf("unexpected number of emails queued. expected %d got %d", 1, got.Queued)
+	}
+	r, err := models.GetResult(ms[0].RId)
+	if err != nil {
+		t.Fatalf("error getting result: %v", err)
+	}
+	if r.Status != models.StatusSending {
+		t.Fatalf("unexpected result status. expected %q got %q", models.StatusSending, r.Status)
+	}
+}
+
+func TestCampaignRecipients(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	add := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/campaigns/1/recipients", bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+	w := add(`{"groups": [{"name": "Missing"}]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	w = add(`{"targets": [{"email": "late@example.com"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusCreated, w.Code)
+	}
+	got := models.AddRecipientsResult{}
+	err := json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("error decoding result: %v", err)
+	}
+	if len(got.Results) != 1 || got.Results[0].Email != "late@example.com" {
+		t.Fatalf("unexpected results received: %#v", got.Results)
+	}
+	c, err := models.GetCampaign(1, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	found := false
+	for _, r := range c.Results {
+		found = found || r.Email == "late@example.com"
+	}
+	if !found {
+		t.Fatalf("expected the recipient to be added to the campaign")
+	}
+}
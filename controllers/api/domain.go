@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// Domains returns the domains in the user's inventory, or adds a new one.
+func (as *Server) Domains(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		ds, err := models.GetDomains(uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching domains"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ds, http.StatusOK)
+
+	case r.Method == "POST":
+		d := models.Domain{}
+		err := json.NewDecoder(r.Body).Decode(&d)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		d.UserId = uid
+		err = models.PostDomain(&d)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, d, http.StatusCreated)
+	}
+}
+
+// Domain returns, edits, or deletes the domain specified by the "id"
+// parameter.
+func (as *Server) Domain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	d, err := models.GetDomain(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Domain not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, d, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeleteDomain(id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting domain"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Domain deleted successfully!"}, http.StatusOK)
+
+	case r.Method == "PUT":
+		d = models.Domain{}
+		err = json.NewDecoder(r.Body).Decode(&d)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		d.Id = id
+		d.UserId = uid
+		err = models.PutDomain(&d)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, d, http.StatusOK)
+	}
+}
+
+// DomainCheck checks the domain specified by the "id" parameter immediately,
+// rather than waiting for the periodic checks.
+func (as *Server) DomainCheck(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	d, err := models.GetDomain(id, ctx.Get(r, "user_id").(int64))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Domain not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "POST":
+		err = d.Check(r.Context())
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error checking domain"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, d, http.StatusOK)
+	}
+}
+
+// DomainDNS creates the DNS records of the domain specified by the "id"
+// parameter using its DNS provider, or removes them.
+func (as *Server) DomainDNS(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	d, err := models.GetDomain(id, ctx.Get(r, "user_id").(int64))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Domain not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "POST":
+		err = d.ProvisionDNS(r.Context())
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error creating DNS records: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, d, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = d.TeardownDNS(r.Context())
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error removing DNS records: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, d, http.StatusOK)
+	}
+}
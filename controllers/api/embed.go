@@ -0,0 +1,364 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// The metrics which can be queried using an embed token
+const (
+	embedMetricStats            = "stats"
+	embedMetricCampaigns        = "campaigns"
+	embedMetricEventsByType     = "events_by_type"
+	embedMetricEventsByCampaign = "events_by_campaign"
+)
+
+// errUnknownMetric is thrown when a dashboard queries a metric which doesn't
+// exist.
+var errUnknownMetric = errors.New("Unknown metric")
+
+// embedMetrics describes the metrics which can be queried, in the format of
+// the Grafana JSON datasource.
+var embedMetrics = []embedMetric{
+	{Label: "Campaign statistics", Value: embedMetricStats},
+	{Label: "Campaigns", Value: embedMetricCampaigns},
+	{Label: "Events by type", Value: embedMetricEventsByType},
+	{Label: "Events by campaign", Value: embedMetricEventsByCampaign},
+}
+
+type embedMetric struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// embedTarget is a metric queried by a dashboard panel. The events can be
+// limited to a single campaign using the target's payload.
+type embedTarget struct {
+	Target  string `json:"target"`
+	RefId   string `json:"refId"`
+	Payload struct {
+		CampaignId int64 `json:"campaign_id"`
+	} `json:"payload"`
+}
+
+// embedQuery is a query made by a dashboard using the Grafana JSON
+// datasource.
+type embedQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs int64         `json:"intervalMs"`
+	Targets    []embedTarget `json:"targets"`
+}
+
+type embedColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// embedTable is the response to a query for a table.
+type embedTable struct {
+	Type    string          `json:"type"`
+	RefId   string          `json:"refId,omitempty"`
+	Columns []embedColumn   `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// embedTimeSeries is the response to a query for a time series. Each
+// datapoint is the value followed by its time in milliseconds.
+type embedTimeSeries struct {
+	Target     string     `json:"target"`
+	RefId      string     `json:"refId,omitempty"`
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+// eventSeriesRow is a single point of an event series, flattened so that it
+// can be read as a table by the Grafana Infinity datasource.
+type eventSeriesRow struct {
+	Time       time.Time `json:"time"`
+	Series     string    `json:"series"`
+	CampaignId int64     `json:"campaign_id,omitempty"`
+	Count      int64     `json:"count"`
+}
+
+// statsColumns are the columns of the campaign statistics in tables.
+var statsColumns = []embedColumn{
+	{Text: "Recipients", Type: "number"},
+	{Text: "Sent", Type: "number"},
+	{Text: "Opened", Type: "number"},
+	{Text: "Clicked", Type: "number"},
+	{Text: "Submitted Data", Type: "number"},
+	{Text: "Reported", Type: "number"},
+	{Text: "Error", Type: "number"},
+}
+
+// statsRow returns the values of the statistics' columns.
+func statsRow(s models.CampaignStats) []interface{} {
+	return []interface{}{s.Total, s.EmailsSent, s.OpenedEmail, s.ClickedLink, s.SubmittedData, s.EmailReported, s.Error}
+}
+
+// EmbedTokens lists the current user's embed tokens, and creates new ones.
+// The token is only returned when it's created.
+func (as *Server) EmbedTokens(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		ts, err := models.GetEmbedTokens(ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ts, http.StatusOK)
+
+	case r.Method == "POST":
+		t := models.EmbedToken{}
+		err := json.NewDecoder(r.Body).Decode(&t)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		t.UserId = ctx.Get(r, "user_id").(int64)
+		err = models.PostEmbedToken(&t)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, t, http.StatusCreated)
+	}
+}
+
+// EmbedToken revokes one of the current user's embed tokens.
+func (as *Server) EmbedToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "DELETE":
+		err := models.DeleteEmbedToken(id, ctx.Get(r, "user_id").(int64))
+		if err == models.ErrEmbedTokenNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		log.FromContext(r.Context()).Infof("Deleted embed token with id: %d", id)
+		JSONResponse(w, models.Response{Success: true, Message: "Embed token deleted successfully!"}, http.StatusOK)
+	}
+}
+
+// EmbedHealth lets dashboards check their embed token, which the Grafana
+// JSON datasource does when it's saved.
+func (as *Server) EmbedHealth(w http.ResponseWriter, r *http.Request) {
+	JSONResponse(w, models.Response{Success: true, Message: "OK"}, http.StatusOK)
+}
+
+// EmbedStats returns the aggregate statistics of the token owner's
+// campaigns.
+func (as *Server) EmbedStats(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		es, err := models.GetEmbedStats(ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, es, http.StatusOK)
+	}
+}
+
+// EmbedCampaigns returns the summaries of the token owner's campaigns.
+func (as *Server) EmbedCampaigns(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		cs, err := models.GetCampaignSummaries(ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, cs.Campaigns, http.StatusOK)
+	}
+}
+
+// EmbedMetrics lists the metrics which dashboards can query.
+func (as *Server) EmbedMetrics(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		JSONResponse(w, embedMetrics, http.StatusOK)
+	}
+}
+
+// EmbedSearch lists the names of the metrics which dashboards can query, as
+// used by the older SimpleJSON datasource.
+func (as *Server) EmbedSearch(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		names := []string{}
+		for _, m := range embedMetrics {
+			names = append(names, m.Value)
+		}
+		JSONResponse(w, names, http.StatusOK)
+	}
+}
+
+// EmbedQuery answers the queries made by dashboards using the Grafana JSON
+// datasource.
+func (as *Server) EmbedQuery(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		q := embedQuery{}
+		err := json.NewDecoder(r.Body).Decode(&q)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		uid := ctx.Get(r, "user_id").(int64)
+		results := []interface{}{}
+		for _, t := range q.Targets {
+			result, err := embedQueryTarget(uid, q, t)
+			switch err {
+			case nil:
+			case errUnknownMetric:
+				JSONResponse(w, models.Response{Success: false, Message: "Unknown metric " + strconv.Quote(t.Target)}, http.StatusBadRequest)
+				return
+			case models.ErrInvalidSeriesRange:
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			default:
+				log.FromContext(r.Context()).Error(err)
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+				return
+			}
+			results = append(results, result...)
+		}
+		JSONResponse(w, results, http.StatusOK)
+	}
+}
+
+// embedQueryTarget returns the results of querying a single metric. Tables
+// have a single result, while time series have one for each series.
+func embedQueryTarget(uid int64, q embedQuery, t embedTarget) ([]interface{}, error) {
+	switch t.Target {
+	case embedMetricStats:
+		es, err := models.GetEmbedStats(uid)
+		if err != nil {
+			return nil, err
+		}
+		columns := append([]embedColumn{{Text: "Campaigns", Type: "number"}, {Text: "Active Campaigns", Type: "number"}}, statsColumns...)
+		row := append([]interface{}{es.Campaigns, es.ActiveCampaigns}, statsRow(es.Stats)...)
+		return []interface{}{embedTable{Type: "table", RefId: t.RefId, Columns: columns, Rows: [][]interface{}{row}}}, nil
+	case embedMetricCampaigns:
+		cs, err := models.GetCampaignSummaries(uid)
+		if err != nil {
+			return nil, err
+		}
+		columns := append([]embedColumn{{Text: "Campaign", Type: "string"}, {Text: "Status", Type: "string"}, {Text: "Launched", Type: "time"}}, statsColumns...)
+		rows := [][]interface{}{}
+		for _, c := range cs.Campaigns {
+			rows = append(rows, append([]interface{}{c.Name, c.Status, c.LaunchDate.UnixNano() / int64(time.Millisecond)}, statsRow(c.Stats)...))
+		}
+		return []interface{}{embedTable{Type: "table", RefId: t.RefId, Columns: columns, Rows: rows}}, nil
+	case embedMetricEventsByType, embedMetricEventsByCampaign:
+		opts := models.EventSeriesOptions{
+			From:       q.Range.From,
+			To:         q.Range.To,
+			Interval:   time.Duration(q.IntervalMs) * time.Millisecond,
+			CampaignId: t.Payload.CampaignId,
+			GroupBy:    models.SeriesByType,
+		}
+		if t.Target == embedMetricEventsByCampaign {
+			opts.GroupBy = models.SeriesByCampaign
+		}
+		series, err := models.GetEventSeries(uid, opts)
+		if err != nil {
+			return nil, err
+		}
+		results := []interface{}{}
+		for _, s := range series {
+			ts := embedTimeSeries{Target: s.Name, RefId: t.RefId, Datapoints: [][2]int64{}}
+			for _, p := range s.Points {
+				ts.Datapoints = append(ts.Datapoints, [2]int64{p.Count, p.Time.UnixNano() / int64(time.Millisecond)})
+			}
+			results = append(results, ts)
+		}
+		return results, nil
+	}
+	return nil, errUnknownMetric
+}
+
+// parseSeriesTime parses a time given either in RFC 3339 format, or as
+// milliseconds since the epoch, as Grafana's ${__from} and ${__to}
+// variables are.
+func parseSeriesTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// EmbedTimeSeries counts the events of the token owner's recipients during
+// each interval of a time range, grouped by the type of event or by
+// campaign. The points are returned as rows, which the Grafana Infinity
+// datasource can chart directly.
+func (as *Server) EmbedTimeSeries(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		opts := models.EventSeriesOptions{GroupBy: r.URL.Query().Get("group_by")}
+		var err error
+		opts.From, err = parseSeriesTime(r.URL.Query().Get("from"))
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid from time"}, http.StatusBadRequest)
+			return
+		}
+		opts.To, err = parseSeriesTime(r.URL.Query().Get("to"))
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid to time"}, http.StatusBadRequest)
+			return
+		}
+		if v := r.URL.Query().Get("interval"); v != "" {
+			opts.Interval, err = time.ParseDuration(v)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid interval"}, http.StatusBadRequest)
+				return
+			}
+		}
+		if v := r.URL.Query().Get("campaign_id"); v != "" {
+			opts.CampaignId, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid campaign id"}, http.StatusBadRequest)
+				return
+			}
+		}
+		series, err := models.GetEventSeries(ctx.Get(r, "user_id").(int64), opts)
+		if err == models.ErrInvalidSeriesGroup || err == models.ErrInvalidSeriesRange {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		rows := []eventSeriesRow{}
+		for _, s := range series {
+			for _, p := range s.Points {
+				rows = append(rows, eventSeriesRow{Time: p.Time, Series: s.Name, CampaignId: s.CampaignId, Count: p.Count})
+			}
+		}
+		JSONResponse(w, rows, http.StatusOK)
+	}
+}
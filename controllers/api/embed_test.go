@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestEmbedTokens(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+
+	body, _ := json.Marshal(models.EmbedToken{Name: "Wallboard"})
+	r := httptest.NewRequest(http.MethodPost, "/api/embed_tokens/", bytes.NewBuffer(body))
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusCreated, w.Code)
+	}
+	token := models.EmbedToken{}
+	err := json.NewDecoder(w.Body).Decode(&token)
+	if err != nil {
+		t.Fatalf("error decoding embed token: %v", err)
+	}
+
+	request := func(method, path, key string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+
+	// Embed tokens only grant access to the embed endpoints, which don't
+	// accept API keys
+	if w := request(http.MethodGet, "/api/campaigns/", token.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code using an embed token. expected %d got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := request(http.MethodGet, "/api/embed/stats", testCtx.apiKey, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code using an API key. expected %d got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	w = request(http.MethodGet, "/api/embed/stats", token.Token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	stats := models.EmbedStats{}
+	err = json.NewDecoder(w.Body).Decode(&stats)
+	if err != nil {
+		t.Fatalf("error decoding stats: %v", err)
+	}
+	if stats.Campaigns != 1 {
+		t.Fatalf("unexpected number of campaigns. expected %d got %d", 1, stats.Campaigns)
+	}
+
+	query, _ := json.Marshal(embedQuery{Targets: []embedTarget{{Target: embedMetricStats, RefId: "A"}, {Target: embedMetricCampaigns, RefId: "B"}}})
+	w = request(http.MethodPost, "/api/embed/query", token.Token, query)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	tables := []embedTable{}
+	err = json.NewDecoder(w.Body).Decode(&tables)
+	if err != nil {
+		t.Fatalf("error decoding query response: %v", err)
+	}
+	if len(tables) != 2 || tables[0].RefId != "A" || len(tables[1].Rows) != 1 {
+		t.Fatalf("unexpected query response: %#v", tables)
+	}
+	for _, table := range tables {
+		if len(table.Rows[0]) != len(table.Columns) {
+			t.Fatalf("unexpected number of values. expected %d got %d", len(table.Columns), len(table.Rows[0]))
+		}
+	}
+
+	// The campaign's events are counted for the last day by default
+	c, err := models.GetCampaign(1, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	err = c.Results[0].HandleEmailSent()
+	if err != nil {
+		t.Fatalf("error adding event: %v", err)
+	}
+	w = request(http.MethodGet, "/api/embed/timeseries?interval=1h&campaign_id=1", token.Token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	rows := []eventSeriesRow{}
+	err = json.NewDecoder(w.Body).Decode(&rows)
+	if err != nil {
+		t.Fatalf("error decoding time series: %v", err)
+	}
+	if len(rows) == 0 || len(rows)%25 != 0 {
+		t.Fatalf("unexpected number of time series rows. got %d", len(rows))
+	}
+	if w := request(http.MethodGet, "/api/embed/timeseries?group_by=template", token.Token, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code grouping by an unknown field. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	query, _ = json.Marshal(embedQuery{Targets: []embedTarget{{Target: "unknown"}}})
+	if w := request(http.MethodPost, "/api/embed/query", token.Token, query); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code querying an unknown metric. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Revoked tokens can't be used
+	w = request(http.MethodDelete, fmt.Sprintf("/api/embed_tokens/%d", token.Id), testCtx.apiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	if w := request(http.MethodGet, "/api/embed/", token.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code using a revoked token. expected %d got %d", http.StatusUnauthorized, w.Code)
+	}
+}
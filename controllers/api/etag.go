@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gophish/gophish/models"
+)
+
+// The headers used for optimistic concurrency control
+const (
+	ETagHeader        = "ETag"
+	IfMatchHeader     = "If-Match"
+	IfNoneMatchHeader = "If-None-Match"
+)
+
+// etag returns the entity tag of an item, which is a hash of its JSON
+// representation. Any change to the item changes its modified date, so the
+// tag changes whenever the item is updated.
+func etag(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256(b)
+	return "\"" + hex.EncodeToString(h[:16]) + "\""
+}
+
+// etagMatches returns whether the tag matches the value of an If-Match or
+// If-None-Match header, which is either "*" or a list of tags.
+func etagMatches(header string, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions evaluates the If-Match and If-None-Match headers against
+// the current item, which is nil if the item doesn't exist yet. If a
+// precondition fails, the error response is written and false is returned.
+//
+// This lets clients make sure that they're updating the version of an item
+// they last read, rather than overwriting someone else's changes.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, current interface{}) bool {
+	tag := ""
+	if current != nil {
+		tag = etag(current)
+	}
+	if h := r.Header.Get(IfMatchHeader); h != "" {
+		if current == nil || !etagMatches(h, tag) {
+			JSONResponse(w, models.Response{Success: false, Message: "The item has been modified"}, http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	if h := r.Header.Get(IfNoneMatchHeader); h != "" && current != nil && etagMatches(h, tag) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			w.Header().Set(ETagHeader, tag)
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		}
+		JSONResponse(w, models.Response{Success: false, Message: "The item already exists"}, http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// etagResponse writes the item as JSON, along with its entity tag.
+func etagResponse(w http.ResponseWriter, v interface{}, status int) {
+	w.Header().Set(ETagHeader, etag(v))
+	JSONResponse(w, v, status)
+}
+
+// upsertErrorStatus returns the status code for an error saving an item.
+// Conflicts with other items are reported as such, and anything else is a
+// validation error.
+func upsertErrorStatus(err error) int {
+	switch err {
+	case models.ErrNameInUse, models.ErrExternalIdInUse:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func upsertRequest(testCtx *testContext, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	return w
+}
+
+func TestTemplateUpsert(t *testing.T) {
+	testCtx := setupTest(t)
+	body := `{"name": "Managed", "external_id": "tf-1", "subject": "First", "text": "Text"}`
+	w := upsertRequest(testCtx, http.MethodPut, "/api/templates/", body, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code creating template. expected %d got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	created := models.Template{}
+	json.NewDecoder(w.Body).Decode(&created)
+	tag := w.Header().Get(ETagHeader)
+	if tag == "" {
+		t.Fatalf("no ETag returned when creating template")
+	}
+
+	// The same request updates the template rather than creating another
+	body = `{"name": "Renamed", "external_id": "tf-1", "subject": "Second", "text": "Text"}`
+	w = upsertRequest(testCtx, http.MethodPut, "/api/templates/", body, map[string]string{IfMatchHeader: tag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code updating template. expected %d got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	updated := models.Template{}
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.Id != created.Id || updated.Name != "Renamed" || updated.Subject != "Second" {
+		t.Fatalf("unexpected template after update: %#v", updated)
+	}
+	ts, _ := models.GetTemplates(1)
+	if len(ts) != 1 {
+		t.Fatalf("unexpected number of templates. expected %d got %d", 1, len(ts))
+	}
+
+	// The previous ETag is now stale
+	w = upsertRequest(testCtx, http.MethodPut, "/api/templates/", body, map[string]string{IfMatchHeader: tag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("unexpected status code with a stale ETag. expected %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	// The current ETag is returned when getting the template
+	w = upsertRequest(testCtx, http.MethodGet, fmt.Sprintf("/api/templates/%d", created.Id), "", nil)
+	newTag := w.Header().Get(ETagHeader)
+	if newTag == "" || newTag == tag {
+		t.Fatalf("unexpected ETag after update: %s", newTag)
+	}
+	w = upsertRequest(testCtx, http.MethodGet, fmt.Sprintf("/api/templates/%d", created.Id), "", map[string]string{IfNoneMatchHeader: newTag})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("unexpected status code for unmodified template. expected %d got %d", http.StatusNotModified, w.Code)
+	}
+	w = upsertRequest(testCtx, http.MethodDelete, fmt.Sprintf("/api/templates/%d", created.Id), "", map[string]string{IfMatchHeader: tag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("unexpected status code deleting with a stale ETag. expected %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	// If-None-Match: * only allows creating new templates
+	w = upsertRequest(testCtx, http.MethodPut, "/api/templates/", body, map[string]string{IfNoneMatchHeader: "*"})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("unexpected status code creating an existing template. expected %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+}
+
+func TestTemplateUpsertByName(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	// Existing templates are brought under management by name
+	body := `{"name": "Test Template", "external_id": "tf-1", "subject": "Managed", "text": "Text"}`
+	w := upsertRequest(testCtx, http.MethodPut, "/api/templates/", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code adopting template. expected %d got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	tmpl, err := models.GetTemplateByName("Test Template", 1)
+	if err != nil {
+		t.Fatalf("error getting template: %v", err)
+	}
+	if tmpl.ExternalId != "tf-1" || tmpl.Subject != "Managed" {
+		t.Fatalf("unexpected template after upsert: %#v", tmpl)
+	}
+
+	// A template managed with a different external id can't be taken over
+	body = `{"name": "Test Template", "external_id": "tf-2", "subject": "Other", "text": "Text"}`
+	w = upsertRequest(testCtx, http.MethodPut, "/api/templates/", body, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("unexpected status code with conflicting external id. expected %d got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestGroupUpsert(t *testing.T) {
+	testCtx := setupTest(t)
+	body := `{"name": "Managed", "external_id": "group-1", "targets": [{"email": "test@example.com"}]}`
+	w := upsertRequest(testCtx, http.MethodPut, "/api/groups/", body, map[string]string{IfNoneMatchHeader: "*"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code creating group. expected %d got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	tag := w.Header().Get(ETagHeader)
+	g := models.Group{}
+	json.NewDecoder(w.Body).Decode(&g)
+
+	w = upsertRequest(testCtx, http.MethodGet, fmt.Sprintf("/api/groups/%d", g.Id), "", nil)
+	if w.Header().Get(ETagHeader) != tag {
+		t.Fatalf("ETag returned when getting the group doesn't match. expected %s got %s", tag, w.Header().Get(ETagHeader))
+	}
+	body = fmt.Sprintf(`{"id": %d, "name": "Managed", "external_id": "group-1", "targets": [{"email": "other@example.com"}]}`, g.Id)
+	w = upsertRequest(testCtx, http.MethodPut, fmt.Sprintf("/api/groups/%d", g.Id), body, map[string]string{IfMatchHeader: tag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code updating group. expected %d got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = upsertRequest(testCtx, http.MethodPut, fmt.Sprintf("/api/groups/%d", g.Id), body, map[string]string{IfMatchHeader: tag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("unexpected status code with a stale ETag. expected %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"def", "abc"`, true},
+		{`*`, true},
+		{`"def"`, false},
+	}
+	for _, test := range tests {
+		if got := etagMatches(test.header, `"abc"`); got != test.expected {
+			t.Fatalf("unexpected match for %s. expected %v got %v", test.header, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+)
+
+// The formats campaign results can be exported in
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// exportFlushInterval is the number of results written between flushes, so
+// that large exports are sent to the client as they're read.
+const exportFlushInterval = 100
+
+// exportFields maps the fields which can be exported to their values.
+var exportFields = map[string]func(models.Result) interface{}{
+	"id":                func(r models.Result) interface{} { return r.RId },
+	"email":             func(r models.Result) interface{} { return r.Email },
+	"first_name":        func(r models.Result) interface{} { return r.FirstName },
+	"last_name":         func(r models.Result) interface{} { return r.LastName },
+	"position":          func(r models.Result) interface{} { return r.Position },
+	"language":          func(r models.Result) interface{} { return r.Language },
+	"status":            func(r models.Result) interface{} { return r.Status },
+	"ip":                func(r models.Result) interface{} { return r.IP },
+	"latitude":          func(r models.Result) interface{} { return r.Latitude },
+	"longitude":         func(r models.Result) interface{} { return r.Longitude },
+	"send_date":         func(r models.Result) interface{} { return r.SendDate },
+	"modified_date":     func(r models.Result) interface{} { return r.ModifiedDate },
+	"reported":          func(r models.Result) interface{} { return r.Reported },
+	"forwarded":         func(r models.Result) interface{} { return r.Forwarded },
+	"auto_replied":      func(r models.Result) interface{} { return r.AutoReplied },
+	"unsubscribed":      func(r models.Result) interface{} { return r.Unsubscribed },
+	"mail_client":       func(r models.Result) interface{} { return r.MailClient },
+	"machine_opened":    func(r models.Result) interface{} { return r.MachineOpened },
+	"human_opened":      func(r models.Result) interface{} { return r.HumanOpened },
+	"click_count":       func(r models.Result) interface{} { return r.ClickCount },
+	"first_click_date":  func(r models.Result) interface{} { return r.FirstClickDate },
+	"last_click_date":   func(r models.Result) interface{} { return r.LastClickDate },
+	"submit_count":      func(r models.Result) interface{} { return r.SubmitCount },
+	"first_submit_date": func(r models.Result) interface{} { return r.FirstSubmitDate },
+	"last_submit_date":  func(r models.Result) interface{} { return r.LastSubmitDate },
+	"tags":              func(r models.Result) interface{} { return r.Tags },
+	"notes":             func(r models.Result) interface{} { return r.Notes },
+}
+
+// defaultExportFields are the fields exported if none are requested.
+var defaultExportFields = []string{"id", "email", "first_name", "last_name", "position", "status", "ip", "latitude", "longitude", "send_date", "reported", "modified_date", "tags", "notes"}
+
+// exportFieldsParam returns the comma separated fields given in the fields
+// parameter, or the default fields.
+func exportFieldsParam(r *http.Request) ([]string, error) {
+	param := r.URL.Query().Get("fields")
+	if param == "" {
+		return defaultExportFields, nil
+	}
+	fields := []string{}
+	for _, f := range strings.Split(param, ",") {
+		f = strings.TrimSpace(f)
+		if _, ok := exportFields[f]; !ok {
+			return nil, fmt.Errorf("Invalid export field %s", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// formulaPrefixes start values which spreadsheets treat as formulas.
+const formulaPrefixes = "=+-@\t\r"
+
+// csvValue formats an exported value for a CSV file. Strings which would be
+// treated as formulas are escaped, as the results page did when exporting.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if v != "" && strings.ContainsAny(v[:1], formulaPrefixes) {
+			return "'" + v
+		}
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+// resultExporter writes exported results in a format.
+type resultExporter interface {
+	Begin() error
+	Write(models.Result) error
+	End() error
+}
+
+// csvExporter writes results as a CSV file with a header row.
+type csvExporter struct {
+	w      *csv.Writer
+	fields []string
+}
+
+func (ce *csvExporter) Begin() error {
+	return ce.w.Write(ce.fields)
+}
+
+func (ce *csvExporter) Write(r models.Result) error {
+	record := make([]string, len(ce.fields))
+	for i, f := range ce.fields {
+		record[i] = csvValue(exportFields[f](r))
+	}
+	return ce.w.Write(record)
+}
+
+func (ce *csvExporter) End() error {
+	ce.w.Flush()
+	return ce.w.Error()
+}
+
+// jsonExporter writes results as a JSON array of objects.
+type jsonExporter struct {
+	w      http.ResponseWriter
+	fields []string
+	count  int
+}
+
+func (je *jsonExporter) Begin() error {
+	_, err := je.w.Write([]byte("["))
+	return err
+}
+
+func (je *jsonExporter) Write(r models.Result) error {
+	item := make(map[string]interface{}, len(je.fields))
+	for _, f := range je.fields {
+		item[f] = exportFields[f](r)
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if je.count > 0 {
+		b = append([]byte(","), b...)
+	}
+	je.count++
+	_, err = je.w.Write(b)
+	return err
+}
+
+func (je *jsonExporter) End() error {
+	_, err := je.w.Write([]byte("]\n"))
+	return err
+}
+
+// CampaignExport streams the results of a campaign as a CSV file or a JSON
+// array, as given in the format parameter. The exported fields are given in
+// the fields parameter, and the results can be filtered and sorted like the
+// campaign's results.
+//
+// Results are read from the database and written as they're exported, so
+// large campaigns can be exported without holding every result in memory.
+func (as *Server) CampaignExport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	switch {
+	case r.Method == "GET":
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exportCSV
+		}
+		if format != exportCSV && format != exportJSON {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid export format"}, http.StatusBadRequest)
+			return
+		}
+		fields, err := exportFieldsParam(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		opts, err := listOptions(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		uid := ctx.Get(r, "user_id").(int64)
+		_, err = models.GetCampaignSummary(id, uid)
+		if err == gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching campaign"}, http.StatusInternalServerError)
+			return
+		}
+
+		var exporter resultExporter
+		switch format {
+		case exportCSV:
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			exporter = &csvExporter{w: csv.NewWriter(w), fields: fields}
+		case exportJSON:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			exporter = &jsonExporter{w: w, fields: fields}
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"campaign-%d-results.%s\"", id, format))
+		flusher, _ := w.(http.Flusher)
+		// Errors found before the first result is written, such as invalid
+		// filters, can still be returned to the client.
+		started := false
+		written := 0
+		err = models.EachCampaignResult(id, uid, opts, func(result models.Result) error {
+			if !started {
+				started = true
+				err := exporter.Begin()
+				if err != nil {
+					return err
+				}
+			}
+			err := exporter.Write(result)
+			if err != nil {
+				return err
+			}
+			written++
+			if written%exportFlushInterval == 0 {
+				if ce, ok := exporter.(*csvExporter); ok {
+					ce.w.Flush()
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			return nil
+		})
+		if err != nil && !started {
+			w.Header().Del("Content-Disposition")
+			if isListOptionsError(err) {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			// The response has already started, so the export is cut short
+			log.FromContext(r.Context()).Errorf("error exporting results for campaign %d: %v", id, err)
+			return
+		}
+		if !started {
+			err = exporter.Begin()
+			if err != nil {
+				log.FromContext(r.Context()).Error(err)
+				return
+			}
+		}
+		err = exporter.End()
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+	}
+}
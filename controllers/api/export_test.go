@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestCampaignExport(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	cr, err := models.GetCampaignResults(1, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign results: %v", err)
+	}
+	_, err = models.AnnotateResult(1, cr.Results[1].RId, 1, models.ResultAnnotation{Notes: "=HYPERLINK(\"http://example.com\")"})
+	if err != nil {
+		t.Fatalf("error annotating result: %v", err)
+	}
+	export := func(query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/campaigns/1/export"+query, nil)
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+
+	w := export("?fields=email,first_name,notes&sort=-email")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading CSV export: %v", err)
+	}
+	expected := [][]string{
+		{"email", "first_name", "notes"},
+		// Formulas are escaped
+		{"test2@example.com", "Second", "'=HYPERLINK(\"http://example.com\")"},
+		{"test1@example.com", "First", ""},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("unexpected CSV export. expected %v got %v", expected, records)
+	}
+
+	w = export("?format=json&fields=email,reported&filter[email]=test1@example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	items := []map[string]interface{}{}
+	err = json.NewDecoder(w.Body).Decode(&items)
+	if err != nil {
+		t.Fatalf("error decoding JSON export: %v", err)
+	}
+	expectedItems := []map[string]interface{}{{"email": "test1@example.com", "reported": false}}
+	if !reflect.DeepEqual(items, expectedItems) {
+		t.Fatalf("unexpected JSON export. expected %v got %v", expectedItems, items)
+	}
+
+	// Exports without any results are still valid
+	w = export("?format=json&filter[email]=missing@example.com")
+	if w.Body.String() != "[]\n" {
+		t.Fatalf("unexpected empty JSON export. got %q", w.Body.String())
+	}
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{query: "?format=xml", code: http.StatusBadRequest},
+		{query: "?fields=email,password", code: http.StatusBadRequest},
+		{query: "?filter[password]=foo", code: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		w = export(tc.query)
+		if w.Code != tc.code {
+			t.Fatalf("unexpected status code received for %s. expected %d got %d", tc.query, tc.code, w.Code)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/campaigns/2/export", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w = httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,627 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ctx "github.com/gophish/gophish/context"
+	"github.com/gophish/gophish/models"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/jinzhu/gorm"
+)
+
+// graphqlMaxDepth is how deeply fields can be nested in a GraphQL query.
+const graphqlMaxDepth = 5
+
+// graphqlMaxLookups is the number of database lookups a GraphQL query can
+// make. Fields nested in lists are looked up for each item, so this bounds
+// the cost of queries which the depth limit allows.
+const graphqlMaxLookups = 100
+
+// errGraphQLCost is returned when a query makes more than graphqlMaxLookups
+// database lookups.
+var errGraphQLCost = fmt.Errorf("The query needs more than %d database lookups. Request fewer nested fields, or use the limit arguments", graphqlMaxLookups)
+
+// graphqlSchemaString describes the data which can be queried. The fields
+// have the same names as in the rest of the API.
+const graphqlSchemaString = `
+schema {
+	query: Query
+}
+
+# DateTime is a time, represented as an RFC 3339 string
+scalar DateTime
+
+type Query {
+	campaign(id: Int!): Campaign
+	campaigns(name: String, status: String, limit: Int, sort: String): [Campaign!]
+	results(campaign_id: Int, email: String, status: String, position: String, reported: Boolean, forwarded: Boolean, auto_replied: Boolean, unsubscribed: Boolean, mail_client: String, machine_opened: Boolean, human_opened: Boolean, limit: Int, sort: String): [Result!]
+	events(campaign_id: Int, email: String, message: String, limit: Int, sort: String): [Event!]
+	group(id: Int!): Group
+	groups(name: String, limit: Int, sort: String): [Group!]
+}
+
+type Campaign {
+	id: Int!
+	name: String!
+	status: String!
+	created_date: DateTime!
+	launch_date: DateTime!
+	send_by_date: DateTime!
+	completed_date: DateTime!
+	stats: CampaignStats!
+	results(email: String, status: String, position: String, reported: Boolean, forwarded: Boolean, auto_replied: Boolean, unsubscribed: Boolean, mail_client: String, machine_opened: Boolean, human_opened: Boolean, limit: Int, sort: String): [Result!]
+	events(email: String, message: String, limit: Int, sort: String): [Event!]
+}
+
+type CampaignStats {
+	total: Int!
+	sent: Int!
+	opened: Int!
+	clicked: Int!
+	submitted_data: Int!
+	email_reported: Int!
+	forwarded: Int!
+	auto_replied: Int!
+	unsubscribed: Int!
+	error: Int!
+	machine_opened: Int!
+	human_opened: Int!
+}
+
+type Result {
+	id: String!
+	campaign_id: Int!
+	email: String!
+	first_name: String!
+	last_name: String!
+	position: String!
+	status: String!
+	ip: String!
+	latitude: Float!
+	longitude: Float!
+	send_date: DateTime!
+	modified_date: DateTime!
+	reported: Boolean!
+	forwarded: Boolean!
+	auto_replied: Boolean!
+	unsubscribed: Boolean!
+	mail_client: String!
+	machine_opened: Boolean!
+	human_opened: Boolean!
+	events(message: String, limit: Int, sort: String): [Event!]
+}
+
+type Event {
+	id: Int!
+	campaign_id: Int!
+	email: String!
+	time: DateTime!
+	message: String!
+	# The JSON encoded details of the event
+	details: String!
+	# The result for the recipient the event is for
+	result: Result
+}
+
+type Group {
+	id: Int!
+	name: String!
+	modified_date: DateTime!
+	num_targets: Int!
+	targets: [Target!]
+}
+
+type Target {
+	email: String!
+	first_name: String!
+	last_name: String!
+	position: String!
+}
+`
+
+// graphqlParams is a GraphQL request.
+type graphqlParams struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlResult documents the response to a GraphQL request. Data is
+// omitted if the request couldn't be executed.
+type graphqlResult struct {
+	Data   map[string]interface{}  `json:"data,omitempty"`
+	Errors []*gqlerrors.QueryError `json:"errors,omitempty"`
+}
+
+// graphqlContextKey is the key used to store the graphqlLoader in the context
+// passed to resolvers.
+type graphqlContextKey struct{}
+
+// graphqlLoader holds the user making a GraphQL request, counts the database
+// lookups made for it, and caches the results looked up while resolving
+// events so that each campaign's results are only loaded once per request.
+// Fields are resolved concurrently, so it's guarded by a mutex.
+type graphqlLoader struct {
+	uid     int64
+	mu      sync.Mutex
+	lookups int
+	results map[int64]map[string]models.Result
+}
+
+func loaderFrom(c context.Context) *graphqlLoader {
+	return c.Value(graphqlContextKey{}).(*graphqlLoader)
+}
+
+// lookup counts a database lookup, returning errGraphQLCost once the query
+// has made too many.
+func (l *graphqlLoader) lookup() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lookups >= graphqlMaxLookups {
+		return errGraphQLCost
+	}
+	l.lookups++
+	return nil
+}
+
+// result returns the result for the email in the campaign.
+func (l *graphqlLoader) result(cid int64, email string) (*graphqlResultResolver, error) {
+	l.mu.Lock()
+	rs, ok := l.results[cid]
+	l.mu.Unlock()
+	if !ok {
+		err := l.lookup()
+		if err != nil {
+			return nil, err
+		}
+		results, _, err := models.ListResults(l.uid, models.ListOptions{
+			Filters: map[string]string{"campaign_id": strconv.FormatInt(cid, 10)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		rs = make(map[string]models.Result, len(results))
+		for _, r := range results {
+			rs[r.Email] = r
+		}
+		l.mu.Lock()
+		l.results[cid] = rs
+		l.mu.Unlock()
+	}
+	r, ok := rs[email]
+	if !ok {
+		return nil, nil
+	}
+	return &graphqlResultResolver{r}, nil
+}
+
+// graphqlDateTime is the DateTime scalar.
+type graphqlDateTime struct {
+	time.Time
+}
+
+// ImplementsGraphQLType returns whether the type is the named scalar.
+func (graphqlDateTime) ImplementsGraphQLType(name string) bool {
+	return name == "DateTime"
+}
+
+// UnmarshalGraphQL parses a DateTime given as an argument.
+func (t *graphqlDateTime) UnmarshalGraphQL(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return fmt.Errorf("DateTime cannot represent value %v", input)
+	}
+	var err error
+	t.Time, err = time.Parse(time.RFC3339Nano, s)
+	return err
+}
+
+// The arguments of the list fields. Each argument other than the limit and
+// sort filters the items by the field named in its json tag.
+type (
+	graphqlListArgs struct {
+		Limit *int32
+		Sort  *string
+	}
+	graphqlCampaignArgs struct {
+		Name   *string `json:"name"`
+		Status *string `json:"status"`
+		graphqlListArgs
+	}
+	graphqlResultArgs struct {
+		CampaignId    *int32  `json:"campaign_id"`
+		Email         *string `json:"email"`
+		Status        *string `json:"status"`
+		Position      *string `json:"position"`
+		Reported      *bool   `json:"reported"`
+		Forwarded     *bool   `json:"forwarded"`
+		AutoReplied   *bool   `json:"auto_replied"`
+		Unsubscribed  *bool   `json:"unsubscribed"`
+		MailClient    *string `json:"mail_client"`
+		MachineOpened *bool   `json:"machine_opened"`
+		HumanOpened   *bool   `json:"human_opened"`
+		graphqlListArgs
+	}
+	graphqlEventArgs struct {
+		CampaignId *int32  `json:"campaign_id"`
+		Email      *string `json:"email"`
+		Message    *string `json:"message"`
+		graphqlListArgs
+	}
+	graphqlGroupArgs struct {
+		Name *string `json:"name"`
+		graphqlListArgs
+	}
+	graphqlIdArgs struct {
+		Id int32
+	}
+)
+
+// options converts the limit and sort arguments into list options.
+func (a graphqlListArgs) options() models.ListOptions {
+	opts := models.ListOptions{Filters: map[string]string{}}
+	if a.Limit != nil {
+		opts.Limit = int(*a.Limit)
+	}
+	if a.Sort != nil {
+		opts.Sort = *a.Sort
+	}
+	return opts
+}
+
+// graphqlFilters are the arguments of a list field, which embed the
+// graphqlListArgs.
+type graphqlFilters interface {
+	options() models.ListOptions
+}
+
+// graphqlListOptions converts the arguments of a list field into list
+// options.
+func graphqlListOptions(args graphqlFilters) models.ListOptions {
+	opts := args.options()
+	v := reflect.ValueOf(args)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("json")
+		f := v.Field(i)
+		if name == "" || f.IsNil() {
+			continue
+		}
+		switch value := f.Elem().Interface().(type) {
+		case int32:
+			opts.Filters[name] = strconv.FormatInt(int64(value), 10)
+		case bool:
+			// Booleans are stored as integers
+			opts.Filters[name] = "0"
+			if value {
+				opts.Filters[name] = "1"
+			}
+		case string:
+			opts.Filters[name] = value
+		}
+	}
+	return opts
+}
+
+// graphqlQuery resolves the Query type.
+type graphqlQuery struct{}
+
+func (*graphqlQuery) Campaign(c context.Context, args graphqlIdArgs) (*graphqlCampaign, error) {
+	l := loaderFrom(c)
+	err := l.lookup()
+	if err != nil {
+		return nil, err
+	}
+	cs, err := models.GetCampaignSummary(int64(args.Id), l.uid)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &graphqlCampaign{cs}, nil
+}
+
+func (*graphqlQuery) Campaigns(c context.Context, args graphqlCampaignArgs) (*[]*graphqlCampaign, error) {
+	l := loaderFrom(c)
+	err := l.lookup()
+	if err != nil {
+		return nil, err
+	}
+	cs, _, err := models.ListCampaignSummaries(l.uid, graphqlListOptions(args))
+	if err != nil {
+		return nil, err
+	}
+	campaigns := make([]*graphqlCampaign, len(cs.Campaigns))
+	for i, s := range cs.Campaigns {
+		campaigns[i] = &graphqlCampaign{s}
+	}
+	return &campaigns, nil
+}
+
+func (*graphqlQuery) Results(c context.Context, args graphqlResultArgs) (*[]*graphqlResultResolver, error) {
+	return listGraphQLResults(c, graphqlListOptions(args))
+}
+
+func (*graphqlQuery) Events(c context.Context, args graphqlEventArgs) (*[]*graphqlEvent, error) {
+	return listGraphQLEvents(c, graphqlListOptions(args))
+}
+
+func (*graphqlQuery) Group(c context.Context, args graphqlIdArgs) (*graphqlGroup, error) {
+	l := loaderFrom(c)
+	err := l.lookup()
+	if err != nil {
+		return nil, err
+	}
+	g, err := models.GetGroupSummary(int64(args.Id), l.uid)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &graphqlGroup{g}, nil
+}
+
+func (*graphqlQuery) Groups(c context.Context, args graphqlGroupArgs) (*[]*graphqlGroup, error) {
+	l := loaderFrom(c)
+	err := l.lookup()
+	if err != nil {
+		return nil, err
+	}
+	gs, _, err := models.ListGroupSummaries(l.uid, graphqlListOptions(args))
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*graphqlGroup, len(gs.Groups))
+	for i, g := range gs.Groups {
+		groups[i] = &graphqlGroup{g}
+	}
+	return &groups, nil
+}
+
+func listGraphQLResults(c context.Context, opts models.ListOptions) (*[]*graphqlResultResolver, error) {
+	l := loaderFrom(c)
+	err := l.lookup()
+	if err != nil {
+		return nil, err
+	}
+	rs, _, err := models.ListResults(l.uid, opts)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*graphqlResultResolver, len(rs))
+	for i, r := range rs {
+		results[i] = &graphqlResultResolver{r}
+	}
+	return &results, nil
+}
+
+func listGraphQLEvents(c context.Context, opts models.ListOptions) (*[]*graphqlEvent, error) {
+	l := loaderFrom(c)
+	err := l.lookup()
+	if err != nil {
+		return nil, err
+	}
+	es, _, err := models.ListEvents(l.uid, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*graphqlEvent, len(es))
+	for i, e := range es {
+		events[i] = &graphqlEvent{e}
+	}
+	return &events, nil
+}
+
+// graphqlCampaign resolves the Campaign type.
+type graphqlCampaign struct {
+	c models.CampaignSummary
+}
+
+func (g *graphqlCampaign) Id() int32                      { return int32(g.c.Id) }
+func (g *graphqlCampaign) Name() string                   { return g.c.Name }
+func (g *graphqlCampaign) Status() string                 { return g.c.Status }
+func (g *graphqlCampaign) CreatedDate() graphqlDateTime   { return graphqlDateTime{g.c.CreatedDate} }
+func (g *graphqlCampaign) LaunchDate() graphqlDateTime    { return graphqlDateTime{g.c.LaunchDate} }
+func (g *graphqlCampaign) SendByDate() graphqlDateTime    { return graphqlDateTime{g.c.SendByDate} }
+func (g *graphqlCampaign) CompletedDate() graphqlDateTime { return graphqlDateTime{g.c.CompletedDate} }
+func (g *graphqlCampaign) Stats() *graphqlStats           { return &graphqlStats{g.c.Stats} }
+
+func (g *graphqlCampaign) Results(c context.Context, args graphqlResultArgs) (*[]*graphqlResultResolver, error) {
+	opts := graphqlListOptions(args)
+	opts.Filters["campaign_id"] = strconv.FormatInt(g.c.Id, 10)
+	return listGraphQLResults(c, opts)
+}
+
+func (g *graphqlCampaign) Events(c context.Context, args graphqlEventArgs) (*[]*graphqlEvent, error) {
+	opts := graphqlListOptions(args)
+	opts.Filters["campaign_id"] = strconv.FormatInt(g.c.Id, 10)
+	return listGraphQLEvents(c, opts)
+}
+
+// graphqlStats resolves the CampaignStats type.
+type graphqlStats struct {
+	s models.CampaignStats
+}
+
+func (g *graphqlStats) Total() int32         { return int32(g.s.Total) }
+func (g *graphqlStats) Sent() int32          { return int32(g.s.EmailsSent) }
+func (g *graphqlStats) Opened() int32        { return int32(g.s.OpenedEmail) }
+func (g *graphqlStats) Clicked() int32       { return int32(g.s.ClickedLink) }
+func (g *graphqlStats) SubmittedData() int32 { return int32(g.s.SubmittedData) }
+func (g *graphqlStats) EmailReported() int32 { return int32(g.s.EmailReported) }
+func (g *graphqlStats) Forwarded() int32     { return int32(g.s.Forwarded) }
+func (g *graphqlStats) AutoReplied() int32   { return int32(g.s.AutoReplied) }
+func (g *graphqlStats) Unsubscribed() int32  { return int32(g.s.Unsubscribed) }
+func (g *graphqlStats) Error() int32         { return int32(g.s.Error) }
+func (g *graphqlStats) MachineOpened() int32 { return int32(g.s.MachineOpened) }
+func (g *graphqlStats) HumanOpened() int32   { return int32(g.s.HumanOpened) }
+
+// graphqlResultResolver resolves the Result type.
+type graphqlResultResolver struct {
+	r models.Result
+}
+
+func (g *graphqlResultResolver) Id() string                { return g.r.RId }
+func (g *graphqlResultResolver) CampaignId() int32         { return int32(g.r.CampaignId) }
+func (g *graphqlResultResolver) Email() string             { return g.r.Email }
+func (g *graphqlResultResolver) FirstName() string         { return g.r.FirstName }
+func (g *graphqlResultResolver) LastName() string          { return g.r.LastName }
+func (g *graphqlResultResolver) Position() string          { return g.r.Position }
+func (g *graphqlResultResolver) Status() string            { return g.r.Status }
+func (g *graphqlResultResolver) Ip() string                { return g.r.IP }
+func (g *graphqlResultResolver) Latitude() float64         { return g.r.Latitude }
+func (g *graphqlResultResolver) Longitude() float64        { return g.r.Longitude }
+func (g *graphqlResultResolver) SendDate() graphqlDateTime { return graphqlDateTime{g.r.SendDate} }
+func (g *graphqlResultResolver) ModifiedDate() graphqlDateTime {
+	return graphqlDateTime{g.r.ModifiedDate}
+}
+func (g *graphqlResultResolver) Reported() bool      { return g.r.Reported }
+func (g *graphqlResultResolver) Forwarded() bool     { return g.r.Forwarded }
+func (g *graphqlResultResolver) AutoReplied() bool   { return g.r.AutoReplied }
+func (g *graphqlResultResolver) Unsubscribed() bool  { return g.r.Unsubscribed }
+func (g *graphqlResultResolver) MailClient() string  { return g.r.MailClient }
+func (g *graphqlResultResolver) MachineOpened() bool { return g.r.MachineOpened }
+func (g *graphqlResultResolver) HumanOpened() bool   { return g.r.HumanOpened }
+
+func (g *graphqlResultResolver) Events(c context.Context, args graphqlEventArgs) (*[]*graphqlEvent, error) {
+	opts := graphqlListOptions(args)
+	opts.Filters["campaign_id"] = strconv.FormatInt(g.r.CampaignId, 10)
+	opts.Filters["email"] = g.r.Email
+	return listGraphQLEvents(c, opts)
+}
+
+// graphqlEvent resolves the Event type.
+type graphqlEvent struct {
+	e models.Event
+}
+
+func (g *graphqlEvent) Id() int32             { return int32(g.e.Id) }
+func (g *graphqlEvent) CampaignId() int32     { return int32(g.e.CampaignId) }
+func (g *graphqlEvent) Email() string         { return g.e.Email }
+func (g *graphqlEvent) Time() graphqlDateTime { return graphqlDateTime{g.e.Time} }
+func (g *graphqlEvent) Message() string       { return g.e.Message }
+func (g *graphqlEvent) Details() string       { return string(g.e.Details) }
+
+func (g *graphqlEvent) Result(c context.Context) (*graphqlResultResolver, error) {
+	if g.e.Email == "" {
+		return nil, nil
+	}
+	return loaderFrom(c).result(g.e.CampaignId, g.e.Email)
+}
+
+// graphqlGroup resolves the Group type.
+type graphqlGroup struct {
+	g models.GroupSummary
+}
+
+func (g *graphqlGroup) Id() int32                     { return int32(g.g.Id) }
+func (g *graphqlGroup) Name() string                  { return g.g.Name }
+func (g *graphqlGroup) ModifiedDate() graphqlDateTime { return graphqlDateTime{g.g.ModifiedDate} }
+func (g *graphqlGroup) NumTargets() int32             { return int32(g.g.NumTargets) }
+
+func (g *graphqlGroup) Targets(c context.Context) (*[]*graphqlTarget, error) {
+	err := loaderFrom(c).lookup()
+	if err != nil {
+		return nil, err
+	}
+	ts, err := models.GetTargets(g.g.Id)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*graphqlTarget, len(ts))
+	for i, t := range ts {
+		targets[i] = &graphqlTarget{t}
+	}
+	return &targets, nil
+}
+
+// graphqlTarget resolves the Target type.
+type graphqlTarget struct {
+	t models.Target
+}
+
+func (g *graphqlTarget) Email() string     { return g.t.Email }
+func (g *graphqlTarget) FirstName() string { return g.t.FirstName }
+func (g *graphqlTarget) LastName() string  { return g.t.LastName }
+func (g *graphqlTarget) Position() string  { return g.t.Position }
+
+var graphqlSchema = graphql.MustParseSchema(graphqlSchemaString, &graphqlQuery{},
+	graphql.MaxDepth(graphqlMaxDepth),
+	graphql.DisableIntrospection(),
+)
+
+// errGraphQLQuery is returned when a GraphQL request has no query.
+var errGraphQLQuery = errors.New("No query specified")
+
+// GraphQL executes GraphQL queries over campaigns, results, events, groups,
+// and targets. Queries can be sent using GET, with the query, variables, and
+// operationName parameters, or using POST with a JSON body.
+//
+// The endpoint is disabled unless enable_graphql is set in the admin server
+// configuration.
+func (as *Server) GraphQL(w http.ResponseWriter, r *http.Request) {
+	if !as.graphql {
+		JSONResponse(w, models.Response{Success: false, Message: "GraphQL is not enabled"}, http.StatusNotFound)
+		return
+	}
+	params := graphqlParams{}
+	switch {
+	case r.Method == "GET":
+		q := r.URL.Query()
+		params.Query = q.Get("query")
+		params.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			err := json.Unmarshal([]byte(v), &params.Variables)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid variables"}, http.StatusBadRequest)
+				return
+			}
+		}
+	case r.Method == "POST":
+		// The query can be sent as the body on its own
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Error reading request"}, http.StatusBadRequest)
+				return
+			}
+			params.Query = string(b)
+			break
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+	default:
+		JSONResponse(w, models.Response{Success: false, Message: "Method not allowed"}, http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		JSONResponse(w, models.Response{Success: false, Message: errGraphQLQuery.Error()}, http.StatusBadRequest)
+		return
+	}
+	loader := &graphqlLoader{
+		uid:     ctx.Get(r, "user_id").(int64),
+		results: make(map[int64]map[string]models.Result),
+	}
+	c := context.WithValue(r.Context(), graphqlContextKey{}, loader)
+	result := graphqlSchema.Exec(c, params.Query, params.OperationName, params.Variables)
+	if result.Data == nil {
+		JSONResponse(w, result, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, result, http.StatusOK)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+type graphqlTestResponse struct {
+	Data struct {
+		Events []struct {
+			Email   string `json:"email"`
+			Message string `json:"message"`
+			Result  struct {
+				FirstName string `json:"first_name"`
+				Position  string `json:"position"`
+			} `json:"result"`
+		} `json:"events"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func graphqlRequest(t *testing.T, as *Server, apiKey string, query string) (int, graphqlTestResponse) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": map[string]interface{}{"message": models.EventDataSubmit},
+	})
+	r := httptest.NewRequest(http.MethodPost, "/api/graphql", bytes.NewBuffer(body))
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	as.ServeHTTP(w, r)
+	resp := graphqlTestResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestGraphQL(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	c, err := models.GetCampaign(1, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	c.Results[0].HandleFormSubmit(models.EventDetails{})
+	query := `query Submissions($message: String) {
+		events(message: $message) { email message result { first_name position } }
+	}`
+
+	// The endpoint is disabled by default
+	code, _ := graphqlRequest(t, testCtx.apiServer, testCtx.apiKey, query)
+	if code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, code)
+	}
+
+	as := NewServer(WithGraphQL(true))
+	code, resp := graphqlRequest(t, as, testCtx.apiKey, query)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, code)
+	}
+	if len(resp.Errors) != 0 {
+		t.Fatalf("unexpected error received: %s", resp.Errors[0].Message)
+	}
+	if len(resp.Data.Events) != 1 {
+		t.Fatalf("unexpected number of events. expected %d got %d", 1, len(resp.Data.Events))
+	}
+	e := resp.Data.Events[0]
+	if e.Email != c.Results[0].Email || e.Message != models.EventDataSubmit {
+		t.Fatalf("unexpected event received: %#v", e)
+	}
+	if e.Result.FirstName != c.Results[0].FirstName {
+		t.Fatalf("unexpected result received. expected %s got %s", c.Results[0].FirstName, e.Result.FirstName)
+	}
+
+	// Other users can't see the events
+	user := createUnpriviledgedUser(t, models.RoleUser)
+	code, resp = graphqlRequest(t, as, string(user.ApiKey), query)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, code)
+	}
+	if len(resp.Data.Events) != 0 {
+		t.Fatalf("unexpected number of events. expected %d got %d", 0, len(resp.Data.Events))
+	}
+
+	code, resp = graphqlRequest(t, as, testCtx.apiKey, `{ events { unknown } }`)
+	if code != http.StatusBadRequest || len(resp.Errors) != 1 {
+		t.Fatalf("expected an error for an unknown field")
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	as := NewServer(WithGraphQL(true))
+
+	// Queries can't nest fields too deeply
+	code, resp := graphqlRequest(t, as, testCtx.apiKey, `{ campaigns { results { events { result { events { message } } } } } }`)
+	if code != http.StatusBadRequest || len(resp.Errors) == 0 {
+		t.Fatalf("expected an error for a query which is too deep")
+	}
+
+	// Or make too many database lookups
+	fields := []string{}
+	for i := 0; i <= graphqlMaxLookups; i++ {
+		fields = append(fields, fmt.Sprintf("e%d: events { email }", i))
+	}
+	code, resp = graphqlRequest(t, as, testCtx.apiKey, fmt.Sprintf("{ %s }", strings.Join(fields, " ")))
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, code)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != errGraphQLCost.Error() {
+		t.Fatalf("expected an error for a query which is too costly, got %v", resp.Errors)
+	}
+}
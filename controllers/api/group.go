@@ -2,6 +2,8 @@ package api
 
 import (
 	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,14 +17,26 @@ import (
 
 // Groups returns a list of groups if requested via GET.
 // If requested via POST, APIGroups creates a new group and returns a reference to it.
+// If requested via PUT, the group with the given external id or name is
+// created or updated.
 func (as *Server) Groups(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		gs, err := models.GetGroups(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		gs, page, err := models.ListGroups(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: "No groups found"}, http.StatusNotFound)
 			return
 		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, gs, http.StatusOK)
 	//POST: Create a new group and return it as JSON
 	case r.Method == "POST":
@@ -42,10 +56,52 @@ func (as *Server) Groups(w http.ResponseWriter, r *http.Request) {
 		g.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PostGroup(&g)
 		if err != nil {
-			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
 			return
 		}
 		JSONResponse(w, g, http.StatusCreated)
+	// PUT: Create or update the group with the given external id or name
+	case r.Method == "PUT":
+		g := models.Group{}
+		err := json.NewDecoder(r.Body).Decode(&g)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		uid := ctx.Get(r, "user_id").(int64)
+		existing, err := models.GetGroupForUpsert(g, uid)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		var current interface{}
+		if err == nil {
+			current = existing
+		}
+		if !checkPreconditions(w, r, current) {
+			return
+		}
+		g.Id = existing.Id
+		g.ModifiedDate = time.Now().UTC()
+		g.UserId = uid
+		status := http.StatusOK
+		if current == nil {
+			status = http.StatusCreated
+			err = models.PostGroup(&g)
+		} else {
+			err = models.PutGroup(&g)
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		g, err = models.GetGroup(g.Id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading group"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, g, status)
 	}
 }
 
@@ -53,12 +109,22 @@ func (as *Server) Groups(w http.ResponseWriter, r *http.Request) {
 func (as *Server) GroupsSummary(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		gs, err := models.GetGroupSummaries(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		gs, page, err := models.ListGroupSummaries(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, gs, http.StatusOK)
 	}
 }
@@ -73,9 +139,12 @@ func (as *Server) Group(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: "Group not found"}, http.StatusNotFound)
 		return
 	}
+	if !checkPreconditions(w, r, g) {
+		return
+	}
 	switch {
 	case r.Method == "GET":
-		JSONResponse(w, g, http.StatusOK)
+		etagResponse(w, g, http.StatusOK)
 	case r.Method == "DELETE":
 		err = models.DeleteGroup(&g)
 		if err != nil {
@@ -85,10 +154,11 @@ func (as *Server) Group(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: true, Message: "Group deleted successfully!"}, http.StatusOK)
 	case r.Method == "PUT":
 		// Change this to get from URL and uid (don't bother with id in r.Body)
+		externalId := g.ExternalId
 		g = models.Group{}
 		err = json.NewDecoder(r.Body).Decode(&g)
 		if err != nil {
-			log.Errorf("error decoding group: %v", err)
+			log.FromContext(r.Context()).Errorf("error decoding group: %v", err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
@@ -96,14 +166,25 @@ func (as *Server) Group(w http.ResponseWriter, r *http.Request) {
 			JSONResponse(w, models.Response{Success: false, Message: "Error: /:id and group_id mismatch"}, http.StatusInternalServerError)
 			return
 		}
+		// Editing the group from the admin UI shouldn't unlink it from the
+		// tool managing it
+		if g.ExternalId == "" {
+			g.ExternalId = externalId
+		}
 		g.ModifiedDate = time.Now().UTC()
 		g.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PutGroup(&g)
 		if err != nil {
-			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
 			return
 		}
-		JSONResponse(w, g, http.StatusOK)
+		g, err = models.GetGroup(id, g.UserId)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading group"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, g, http.StatusOK)
 	}
 }
 
@@ -121,3 +202,93 @@ func (as *Server) GroupSummary(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, g, http.StatusOK)
 	}
 }
+
+// GroupTargets modifies the targets in a group in bulk, without replacing the
+// whole group. The targets are provided as a JSON array, or as newline
+// delimited JSON if the Content-Type is application/x-ndjson.
+//
+// POST adds the targets, updating any already in the group. PUT updates the
+// targets already in the group. DELETE removes the targets, matching them by
+// email address.
+func (as *Server) GroupTargets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	var op func(int64, int64, []models.Target) (models.BulkTargetResult, error)
+	switch {
+	case r.Method == "POST":
+		op = models.AddTargets
+	case r.Method == "PUT":
+		op = models.UpdateTargets
+	case r.Method == "DELETE":
+		op = models.RemoveTargets
+	default:
+		JSONResponse(w, models.Response{Success: false, Message: "Method not allowed"}, http.StatusMethodNotAllowed)
+		return
+	}
+	ts, err := decodeTargets(r)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+		return
+	}
+	result, err := op(id, uid, ts)
+	if err == gorm.ErrRecordNotFound {
+		JSONResponse(w, models.Response{Success: false, Message: "Group not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	JSONResponse(w, result, http.StatusOK)
+}
+
+// decodeTargets decodes the targets in the request body, which is either a
+// JSON array or newline delimited JSON.
+func decodeTargets(r *http.Request) ([]models.Target, error) {
+	ts := []models.Target{}
+	d := json.NewDecoder(r.Body)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		for {
+			t := models.Target{}
+			err := d.Decode(&t)
+			if err == io.EOF {
+				return ts, nil
+			}
+			if err != nil {
+				return ts, err
+			}
+			ts = append(ts, t)
+		}
+	}
+	err := d.Decode(&ts)
+	return ts, err
+}
+
+// TargetStatus sets the status of targets in every group owned by the user,
+// such as when a directory sync finds people who are on leave or have left.
+func (as *Server) TargetStatus(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "POST":
+		req := models.TargetStatusRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		result, err := models.SetTargetStatus(uid, req)
+		if err == models.ErrInvalidTargetStatus || err == models.ErrNoTargetsSpecified {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error updating targets"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, result, http.StatusOK)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestGroupTargetsNDJSON(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	body := bytes.NewBufferString(`{"email": "test2@example.com", "first_name": "Updated"}
+{"email": "test3@example.com", "first_name": "Third"}
+`)
+	r := httptest.NewRequest(http.MethodPost, "/api/groups/1/targets", body)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	result := models.BulkTargetResult{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	if err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if result.Added != 1 || result.Updated != 1 {
+		t.Fatalf("unexpected result received. expected 1 added and 1 updated got %d added and %d updated", result.Added, result.Updated)
+	}
+	ts, err := models.GetTargets(1)
+	if err != nil {
+		t.Fatalf("error getting targets: %v", err)
+	}
+	if len(ts) != 3 {
+		t.Fatalf("unexpected number of targets. expected %d got %d", 3, len(ts))
+	}
+}
+
+func TestGroupTargetsNotFound(t *testing.T) {
+	testCtx := setupTest(t)
+	body := bytes.NewBufferString(`[{"email": "test@example.com"}]`)
+	r := httptest.NewRequest(http.MethodDelete, "/api/groups/100/targets", body)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// Honeytokens returns the honeytokens planted in the user's campaigns, along
+// with their sightings. The campaign_id parameter returns a single
+// campaign's honeytoken.
+func (as *Server) Honeytokens(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		var cid int64
+		if v := r.URL.Query().Get("campaign_id"); v != "" {
+			var err error
+			cid, err = strconv.ParseInt(v, 0, 64)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid campaign_id"}, http.StatusBadRequest)
+				return
+			}
+		}
+		hts, err := models.GetHoneytokens(ctx.Get(r, "user_id").(int64), cid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching honeytokens"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, hts, http.StatusOK)
+	}
+}
+
+// HoneytokenSightings records a honeytoken being seen by an external system,
+// such as failed logins found by a SIEM, and notifies the webhooks.
+func (as *Server) HoneytokenSightings(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		report := models.HoneytokenReport{}
+		err := json.NewDecoder(r.Body).Decode(&report)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
+			return
+		}
+		s, err := models.ReportHoneytokenSighting(ctx.Get(r, "user_id").(int64), report)
+		if err == models.ErrHoneytokenNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+		if err == models.ErrHoneytokenSourceNotSpecified {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error recording sighting"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, s, http.StatusCreated)
+	}
+}
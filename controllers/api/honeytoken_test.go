@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestHoneytokenSightings(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/honeytokens/?campaign_id=1", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	hts := []models.Honeytoken{}
+	if err := json.NewDecoder(w.Body).Decode(&hts); err != nil {
+		t.Fatalf("error decoding honeytokens: %v", err)
+	}
+	if len(hts) != 1 || hts[0].CampaignId != 1 {
+		t.Fatalf("unexpected honeytokens received: %#v", hts)
+	}
+
+	report := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/honeytokens/sightings", bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+	w = report(fmt.Sprintf(`{"username": "%s", "password": "%s", "source": "siem"}`, hts[0].Username, hts[0].Password))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusCreated, w.Code)
+	}
+	s := models.HoneytokenSighting{}
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatalf("error decoding sighting: %v", err)
+	}
+	if s.HoneytokenId != hts[0].Id || !s.PasswordMatched || s.Source != "siem" {
+		t.Fatalf("unexpected sighting received: %#v", s)
+	}
+
+	w = report(`{"username": "svc-unknown", "source": "siem"}`)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -22,6 +22,12 @@ func (as *Server) IMAPServerValidate(w http.ResponseWriter, r *http.Request) {
 			JSONResponse(w, models.Response{Success: false, Message: "Invalid request"}, http.StatusBadRequest)
 			return
 		}
+		im.UserId = ctx.Get(r, "user_id").(int64)
+		err = im.LoadRefreshToken(im.UserId)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
 		err = imap.Validate(&im)
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusOK)
@@ -60,3 +66,40 @@ func (as *Server) IMAPServer(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: true, Message: "Successfully saved IMAP settings."}, http.StatusCreated)
 	}
 }
+
+// IMAPDeviceAuthorization handles requests for the
+// /api/imap/oauth2/device endpoint. POSTs start the OAuth2 device code flow
+// for the saved IMAP settings, returning the code the user signs in with,
+// and GETs return whether the mailbox has been authorized.
+func (as *Server) IMAPDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		status, ok := imap.GetDeviceAuthorizationStatus(uid)
+		if !ok {
+			JSONResponse(w, models.Response{Success: false, Message: "No device authorization has been started"}, http.StatusNotFound)
+			return
+		}
+		JSONResponse(w, status, http.StatusOK)
+	case r.Method == "POST":
+		ims, err := models.GetIMAP(uid)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if len(ims) == 0 {
+			JSONResponse(w, models.Response{Success: false, Message: imap.ErrNotDeviceCode.Error()}, http.StatusBadRequest)
+			return
+		}
+		da, err := imap.StartDeviceAuthorization(ims[0])
+		if err == imap.ErrNotDeviceCode {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadGateway)
+			return
+		}
+		JSONResponse(w, da, http.StatusCreated)
+	}
+}
@@ -67,7 +67,7 @@ func (as *Server) ImportEmail(w http.ResponseWriter, r *http.Request) {
 	}
 	e, err := email.NewEmailFromReader(strings.NewReader(ir.Content))
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 	}
 	// If the user wants to convert links to point to
 	// the landing page, let's make it happen by changing up
@@ -114,7 +114,11 @@ func (as *Server) ImportSite(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 		return
 	}
-	restrictedDialer := dialer.Dialer()
+	restrictedDialer, err := dialer.ProxyDialer("")
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
 	tr := &http.Transport{
 		DialContext: restrictedDialer.DialContext,
 		TLSClientConfig: &tls.Config{
@@ -133,8 +137,19 @@ func (as *Server) ImportSite(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 		return
 	}
-	// Assuming we don't want to include resources, we'll need a base href
-	if d.Find("head base").Length() == 0 {
+	if cr.IncludeResources {
+		// The stylesheets' assets are copied, and the page's other
+		// resources are loaded from the original site
+		base := resp.Request.URL
+		if href, ok := d.Find("head base[href]").Attr("href"); ok {
+			if u, err := base.Parse(href); err == nil {
+				base = u
+			}
+		}
+		d.Find("head base").Remove()
+		newAssetImporter(client, models.ObjectStore()).importDocument(d, base)
+	} else if d.Find("head base").Length() == 0 {
+		// Without including resources, we'll need a base href
 		d.Find("head").PrependHtml(fmt.Sprintf("<base href=\"%s\">", cr.URL))
 	}
 	forms := d.Find("form")
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/storage"
+)
+
+// importedAssetsPath is the path the phishing server serves imported assets
+// from. It's under the static directory, or the static prefix in object
+// storage.
+const importedAssetsPath = "/static/imported/"
+
+// importedAssetsPrefix is the prefix of imported assets in object storage
+const importedAssetsPrefix = "static/imported/"
+
+// ImportAssetsDir is the directory imported assets are saved to when object
+// storage isn't configured.
+var ImportAssetsDir = "./static/endpoint/imported/"
+
+// Limits on the assets downloaded when importing a site, so that a page
+// can't make Gophish download an unbounded amount of content.
+const (
+	maxImportedAssets    = 200
+	maxImportedAssetSize = 10 << 20
+	maxStylesheetDepth   = 3
+)
+
+// cssReference matches the @import rules with a string URL and the url()
+// references in a stylesheet.
+var cssReference = regexp.MustCompile(`@import\s+['"]([^'"]+)['"]|url\(\s*['"]?([^'")]*)['"]?\s*\)`)
+
+// errTooManyAssets is returned once the limit on imported assets is reached
+var errTooManyAssets = errors.New("too many assets")
+
+// assetImporter downloads the assets referenced by stylesheets, saving local
+// copies which the phishing server serves.
+type assetImporter struct {
+	client *http.Client
+	store  storage.Store
+	// saved maps the URLs of the assets already imported to their local
+	// paths
+	saved map[string]string
+}
+
+func newAssetImporter(client *http.Client, store storage.Store) *assetImporter {
+	return &assetImporter{client: client, store: store, saved: map[string]string{}}
+}
+
+// importDocument rewrites the stylesheets in the document to use local
+// copies of the assets they reference. Linked stylesheets are imported
+// themselves, while inline stylesheets and style attributes are rewritten in
+// place. Other relative URLs are made absolute, since the document is no
+// longer served from the page's URL.
+func (ai *assetImporter) importDocument(d *goquery.Document, base *url.URL) {
+	d.Find("link[rel~=stylesheet][href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		local, err := ai.importAsset(base, href, 0)
+		if err != nil {
+			log.Warnf("error importing stylesheet %s: %v", href, err)
+			return
+		}
+		s.SetAttr("href", local)
+	})
+	d.Find("style").Each(func(_ int, s *goquery.Selection) {
+		s.SetText(ai.rewriteCSS(s.Text(), base, 0))
+	})
+	d.Find("[style]").Each(func(_ int, s *goquery.Selection) {
+		style, _ := s.Attr("style")
+		s.SetAttr("style", ai.rewriteCSS(style, base, 0))
+	})
+	for _, attr := range []string{"src", "href"} {
+		d.Find("[" + attr + "]").Each(func(_ int, s *goquery.Selection) {
+			v, _ := s.Attr(attr)
+			if strings.HasPrefix(v, importedAssetsPath) || strings.HasPrefix(v, "#") || strings.HasPrefix(v, "{{") {
+				return
+			}
+			if u, err := base.Parse(strings.TrimSpace(v)); err == nil {
+				s.SetAttr(attr, u.String())
+			}
+		})
+	}
+}
+
+// rewriteCSS replaces the url() and @import references in the stylesheet
+// with the paths of local copies. References which can't be imported are
+// left as they were, resolved against the stylesheet's URL.
+func (ai *assetImporter) rewriteCSS(css string, base *url.URL, depth int) string {
+	rewrite := func(ref string) string {
+		local, err := ai.importAsset(base, ref, depth)
+		if err != nil {
+			log.Warnf("error importing %s: %v", ref, err)
+			if u, err := base.Parse(ref); err == nil {
+				ref = u.String()
+			}
+			return strings.Replace(ref, "\"", "%22", -1)
+		}
+		return local
+	}
+	return cssReference.ReplaceAllStringFunc(css, func(m string) string {
+		sub := cssReference.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return "@import url(\"" + rewrite(sub[1]) + "\")"
+		}
+		ref := strings.TrimSpace(sub[2])
+		if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return m
+		}
+		return "url(\"" + rewrite(ref) + "\")"
+	})
+}
+
+// importAsset downloads the referenced asset and saves a local copy,
+// returning the path it's served from. Stylesheets are rewritten to use
+// local copies of their own assets before they're saved.
+func (ai *assetImporter) importAsset(base *url.URL, ref string, depth int) (string, error) {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	u.Fragment = ""
+	if local, ok := ai.saved[u.String()]; ok {
+		return local, nil
+	}
+	if len(ai.saved) >= maxImportedAssets {
+		return "", errTooManyAssets
+	}
+	resp, err := ai.client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImportedAssetSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxImportedAssetSize {
+		return "", fmt.Errorf("the asset is larger than %d bytes", maxImportedAssetSize)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	name := assetName(u, contentType)
+	local := importedAssetsPath + name
+	// Record the asset before rewriting stylesheets, so that stylesheets
+	// importing each other don't loop
+	ai.saved[u.String()] = local
+	if path.Ext(name) == ".css" && depth < maxStylesheetDepth {
+		content = []byte(ai.rewriteCSS(string(content), u, depth+1))
+	}
+	err = ai.save(name, content, contentType)
+	if err != nil {
+		delete(ai.saved, u.String())
+		return "", err
+	}
+	return local, nil
+}
+
+// assetName returns the name of the local copy of the asset, which is
+// derived from its URL so that importing a site again reuses the same names.
+func assetName(u *url.URL, contentType string) string {
+	h := sha256.Sum256([]byte(u.String()))
+	ext := strings.ToLower(path.Ext(u.Path))
+	if len(ext) < 2 || len(ext) > 6 || strings.IndexFunc(ext[1:], func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) != -1 {
+		ext = ""
+		if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+			if mt == "text/css" {
+				ext = ".css"
+			} else if exts, _ := mime.ExtensionsByType(mt); len(exts) > 0 {
+				ext = exts[0]
+			}
+		}
+	}
+	return hex.EncodeToString(h[:12]) + ext
+}
+
+// save stores the asset in object storage if it's configured, or else in the
+// imported assets directory.
+func (ai *assetImporter) save(name string, content []byte, contentType string) error {
+	if ai.store != nil {
+		return ai.store.Put(importedAssetsPrefix+name, content, contentType)
+	}
+	err := os.MkdirAll(ImportAssetsDir, 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(ImportAssetsDir, name), content, 0644)
+}
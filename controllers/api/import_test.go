@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -82,3 +87,85 @@ func TestCustomDeniedImport(t *testing.T) {
 		t.Fatalf("incorrect response error provided: %s", got.Message)
 	}
 }
+
+func TestImportSiteResources(t *testing.T) {
+	ctx := setupTest(t)
+	dir, err := ioutil.TempDir("", "gophish-import")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ImportAssetsDir = dir
+	orig := dialer.DefaultDialer.AllowedHosts()
+	dialer.SetAllowedHosts([]string{"127.0.0.1"})
+	defer dialer.SetAllowedHosts(orig)
+
+	assets := map[string]string{
+		"/css/site.css":  "@import \"print.css\";\nbody { background: url(../img/bg.png) }",
+		"/css/print.css": "h1 { background: url('/img/logo.png') }",
+		"/img/bg.png":    "background",
+		"/img/logo.png":  "logo",
+	}
+	page := `<html><head><link rel="stylesheet" href="/css/site.css"><style>div { background: url("img/logo.png") } p { background: url(data:image/png;base64,AAAA) }</style></head>` +
+		`<body><div style="background-image: url('/img/bg.png')"></div><img src="img/photo.jpg"></body></html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, page)
+			return
+		}
+		content, ok := assets[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".css") {
+			w.Header().Set("Content-Type", "text/css")
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer ts.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/import/site",
+		bytes.NewBufferString(fmt.Sprintf(`{"url": "%s/", "include_resources": true}`, ts.URL)))
+	req.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	ctx.apiServer.ImportSite(response, req)
+	if response.Code != http.StatusOK {
+		t.Fatalf("incorrect status code received. expected %d got %d", http.StatusOK, response.Code)
+	}
+	cr := cloneResponse{}
+	err = json.NewDecoder(response.Body).Decode(&cr)
+	if err != nil {
+		t.Fatalf("error decoding body: %v", err)
+	}
+	local := func(p string) string {
+		u, _ := url.Parse(ts.URL + p)
+		return importedAssetsPath + assetName(u, "")
+	}
+	for _, expected := range []string{
+		`href="` + local("/css/site.css") + `"`,
+		`url("` + local("/img/logo.png") + `")`,
+		`url(&#34;` + local("/img/bg.png") + `&#34;)`,
+		`url(data:image/png;base64,AAAA)`,
+		`src="` + ts.URL + `/img/photo.jpg"`,
+	} {
+		if !strings.Contains(cr.HTML, expected) {
+			t.Fatalf("expected imported page to contain %s. got %s", expected, cr.HTML)
+		}
+	}
+	if strings.Contains(cr.HTML, "<base") {
+		t.Fatalf("unexpected base tag in imported page: %s", cr.HTML)
+	}
+	css, err := ioutil.ReadFile(filepath.Join(dir, path.Base(local("/css/site.css"))))
+	if err != nil {
+		t.Fatalf("error reading imported stylesheet: %v", err)
+	}
+	expected := "@import url(\"" + local("/css/print.css") + "\");\nbody { background: url(\"" + local("/img/bg.png") + "\") }"
+	if string(css) != expected {
+		t.Fatalf("unexpected imported stylesheet. expected %q got %q", expected, css)
+	}
+	bg, err := ioutil.ReadFile(filepath.Join(dir, path.Base(local("/img/bg.png"))))
+	if err != nil || string(bg) != "background" {
+		t.Fatalf("unexpected imported image %q: %v", bg, err)
+	}
+}
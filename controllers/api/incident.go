@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// IncidentConnectors returns a list of the user's incident connectors, or
+// creates a new one.
+func (as *Server) IncidentConnectors(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		ics, err := models.GetIncidentConnectors(uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching incident connectors"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ics, http.StatusOK)
+
+	case r.Method == "POST":
+		ic := models.IncidentConnector{}
+		err := json.NewDecoder(r.Body).Decode(&ic)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		ic.UserId = uid
+		err = models.PostIncidentConnector(&ic)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, ic, http.StatusCreated)
+	}
+}
+
+// IncidentConnector returns, edits, or deletes the incident connector
+// specified by the "id" parameter.
+func (as *Server) IncidentConnector(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	ic, err := models.GetIncidentConnector(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, ic, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeleteIncidentConnector(id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting incident connector"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Incident connector deleted successfully!"}, http.StatusOK)
+
+	case r.Method == "PUT":
+		ic = models.IncidentConnector{}
+		err = json.NewDecoder(r.Body).Decode(&ic)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		ic.Id = id
+		ic.UserId = uid
+		err = models.PutIncidentConnector(&ic)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, ic, http.StatusOK)
+	}
+}
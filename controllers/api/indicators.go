@@ -0,0 +1,189 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+)
+
+// exportSTIX is the format of indicators exported as a STIX 2.1 bundle
+const exportSTIX = "stix"
+
+// stixObject is an object in a STIX 2.1 bundle, either the identity which
+// created the indicators or an indicator.
+type stixObject struct {
+	Type           string   `json:"type"`
+	SpecVersion    string   `json:"spec_version"`
+	Id             string   `json:"id"`
+	Created        string   `json:"created"`
+	Modified       string   `json:"modified"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description,omitempty"`
+	IdentityClass  string   `json:"identity_class,omitempty"`
+	CreatedByRef   string   `json:"created_by_ref,omitempty"`
+	IndicatorTypes []string `json:"indicator_types,omitempty"`
+	Pattern        string   `json:"pattern,omitempty"`
+	PatternType    string   `json:"pattern_type,omitempty"`
+	ValidFrom      string   `json:"valid_from,omitempty"`
+	Labels         []string `json:"labels,omitempty"`
+}
+
+// stixBundle is a STIX 2.1 bundle of indicators.
+type stixBundle struct {
+	Type    string       `json:"type"`
+	Id      string       `json:"id"`
+	Objects []stixObject `json:"objects"`
+}
+
+// stixId returns a random identifier for a STIX object of the given type.
+func stixId(t string) (string, error) {
+	id, err := models.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s--%s", t, id), nil
+}
+
+// stixPattern returns the STIX pattern matching the indicator.
+func stixPattern(i models.CampaignIndicator) string {
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(i.Value)
+	switch i.Type {
+	case models.IndicatorDomain:
+		return fmt.Sprintf("[domain-name:value = '%s']", value)
+	case models.IndicatorIP:
+		if strings.Contains(i.Value, ":") {
+			return fmt.Sprintf("[ipv6-addr:value = '%s']", value)
+		}
+		return fmt.Sprintf("[ipv4-addr:value = '%s']", value)
+	case models.IndicatorEmail:
+		return fmt.Sprintf("[email-addr:value = '%s']", value)
+	case models.IndicatorURL:
+		return fmt.Sprintf("[url:value = '%s']", value)
+	case models.IndicatorHash:
+		return fmt.Sprintf("[file:hashes.'SHA-256' = '%s']", value)
+	case models.IndicatorMessageId:
+		return fmt.Sprintf("[email-message:message_id = '%s']", value)
+	}
+	return ""
+}
+
+// newSTIXBundle returns the campaign's indicators as a STIX 2.1 bundle. The
+// indicators are labeled as benign, since they belong to a sanctioned
+// simulation.
+func newSTIXBundle(c models.CampaignSummary, is []models.CampaignIndicator) (stixBundle, error) {
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	identityId, err := stixId("identity")
+	if err != nil {
+		return stixBundle{}, err
+	}
+	bundleId, err := stixId("bundle")
+	if err != nil {
+		return stixBundle{}, err
+	}
+	identity := stixObject{
+		Type:          "identity",
+		SpecVersion:   "2.1",
+		Id:            identityId,
+		Created:       now,
+		Modified:      now,
+		Name:          "Gophish",
+		IdentityClass: "system",
+	}
+	b := stixBundle{Type: "bundle", Id: bundleId, Objects: []stixObject{identity}}
+	for _, i := range is {
+		id, err := stixId("indicator")
+		if err != nil {
+			return stixBundle{}, err
+		}
+		description := fmt.Sprintf("%s of the Gophish campaign %s", i.Description, c.Name)
+		if i.Filename != "" {
+			description += fmt.Sprintf(" (%s)", i.Filename)
+		}
+		b.Objects = append(b.Objects, stixObject{
+			Type:           "indicator",
+			SpecVersion:    "2.1",
+			Id:             id,
+			Created:        now,
+			Modified:       now,
+			Name:           i.Value,
+			Description:    description,
+			CreatedByRef:   identity.Id,
+			IndicatorTypes: []string{"benign"},
+			Pattern:        stixPattern(i),
+			PatternType:    "stix",
+			ValidFrom:      c.CreatedDate.UTC().Format("2006-01-02T15:04:05.000Z"),
+			Labels:         []string{"gophish", fmt.Sprintf("gophish-campaign-%d", c.Id)},
+		})
+	}
+	return b, nil
+}
+
+// CampaignIndicators exports the indicators generated by a campaign, such as
+// its sending domains, links, Message-IDs and attachment hashes, as a CSV
+// file, a JSON array or a STIX 2.1 bundle, as given in the format parameter.
+func (as *Server) CampaignIndicators(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exportCSV
+		}
+		if format != exportCSV && format != exportJSON && format != exportSTIX {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid export format"}, http.StatusBadRequest)
+			return
+		}
+		c, err := models.GetCampaignSummary(id, uid)
+		if err == gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching campaign"}, http.StatusInternalServerError)
+			return
+		}
+		is, err := models.GetCampaignIndicators(id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		switch format {
+		case exportJSON:
+			JSONResponse(w, is, http.StatusOK)
+		case exportSTIX:
+			b, err := newSTIXBundle(c, is)
+			if err != nil {
+				log.FromContext(r.Context()).Error(err)
+				JSONResponse(w, models.Response{Success: false, Message: "Error exporting indicators"}, http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"campaign-%d-indicators.json\"", id))
+			JSONResponse(w, b, http.StatusOK)
+		case exportCSV:
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"campaign-%d-indicators.csv\"", id))
+			cw := csv.NewWriter(w)
+			cw.Write([]string{"type", "value", "description", "rid", "filename"})
+			for _, i := range is {
+				cw.Write([]string{i.Type, csvValue(i.Value), i.Description, i.RId, csvValue(i.Filename)})
+			}
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				log.FromContext(r.Context()).Error(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// NotificationRules returns a list of the user's notification rules, or
+// creates a new one.
+func (as *Server) NotificationRules(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		nrs, err := models.GetNotificationRules(uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching notification rules"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, nrs, http.StatusOK)
+
+	case r.Method == "POST":
+		nr := models.NotificationRule{}
+		err := json.NewDecoder(r.Body).Decode(&nr)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		nr.UserId = uid
+		err = models.PostNotificationRule(&nr)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, nr, http.StatusCreated)
+	}
+}
+
+// NotificationRule returns, edits, or deletes the notification rule specified
+// by the "id" parameter.
+func (as *Server) NotificationRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	nr, err := models.GetNotificationRule(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Notification rule not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, nr, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeleteNotificationRule(id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting notification rule"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Notification rule deleted successfully!"}, http.StatusOK)
+
+	case r.Method == "PUT":
+		nr = models.NotificationRule{}
+		err = json.NewDecoder(r.Body).Decode(&nr)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		nr.Id = id
+		nr.UserId = uid
+		err = models.PutNotificationRule(&nr)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, nr, http.StatusOK)
+	}
+}
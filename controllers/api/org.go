@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/util"
+)
+
+// OrgChart returns the members of the user's org chart (GET), or replaces
+// them (PUT).
+func (as *Server) OrgChart(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		ms, err := models.GetOrgChart(uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ms, http.StatusOK)
+
+	case r.Method == "PUT":
+		ms := []models.OrgMember{}
+		err := json.NewDecoder(r.Body).Decode(&ms)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		err = models.ImportOrgChart(uid, ms)
+		if err == models.ErrOrgMemberEmail {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		ms, err = models.GetOrgChart(uid)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, ms, http.StatusOK)
+	}
+}
+
+// ImportOrgChart parses the members of an org chart from a CSV
+func (as *Server) ImportOrgChart(w http.ResponseWriter, r *http.Request) {
+	ms, err := util.ParseOrgCSV(r)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Error parsing CSV"}, http.StatusInternalServerError)
+		return
+	}
+	JSONResponse(w, ms, http.StatusOK)
+}
+
+// campaignIds returns the comma separated campaign ids given in the
+// campaign_ids parameter.
+func campaignIds(r *http.Request) ([]int64, error) {
+	ids := []int64{}
+	for _, param := range r.URL.Query()["campaign_ids"] {
+		for _, s := range strings.Split(param, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// orgRollup writes the rollup returned by the function for the user's
+// campaigns, or the campaigns given in the campaign_ids parameter.
+func orgRollup(w http.ResponseWriter, r *http.Request, rollup func(int64, []int64) ([]models.OrgRollup, error)) {
+	switch {
+	case r.Method == "GET":
+		ids, err := campaignIds(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid campaign ids"}, http.StatusBadRequest)
+			return
+		}
+		rollups, err := rollup(ctx.Get(r, "user_id").(int64), ids)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, rollups, http.StatusOK)
+	}
+}
+
+// OrgDepartmentRollup returns the results of the user's campaigns by the
+// department of each recipient.
+func (as *Server) OrgDepartmentRollup(w http.ResponseWriter, r *http.Request) {
+	orgRollup(w, r, models.GetDepartmentRollup)
+}
+
+// OrgManagerRollup returns the results of the user's campaigns by manager,
+// including everyone in each manager's reporting chain.
+func (as *Server) OrgManagerRollup(w http.ResponseWriter, r *http.Request) {
+	orgRollup(w, r, models.GetManagerRollup)
+}
+
+// OrgTagRollup returns the results of the user's campaigns by the tags of
+// each recipient, such as to report on VIPs separately.
+func (as *Server) OrgTagRollup(w http.ResponseWriter, r *http.Request) {
+	orgRollup(w, r, models.GetTagRollup)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestOrgRollup(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(http.MethodPut, "/api/org/", `[{"email":"test1@example.com","department":"Engineering"},{"department":"Sales"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	w = request(http.MethodPut, "/api/org/", `[{"email":"test1@example.com","department":"Engineering"}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	w = request(http.MethodGet, "/api/org/rollup/departments", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	rollups := []models.OrgRollup{}
+	err := json.NewDecoder(w.Body).Decode(&rollups)
+	if err != nil {
+		t.Fatalf("error decoding rollups: %v", err)
+	}
+	if len(rollups) == 0 || rollups[0].Name != "Engineering" {
+		t.Fatalf("expected an Engineering rollup. got %v", rollups)
+	}
+
+	w = request(http.MethodGet, "/api/org/rollup/managers?campaign_ids=1,foo", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
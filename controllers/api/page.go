@@ -13,14 +13,25 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-// Pages handles requests for the /api/pages/ endpoint
+// Pages handles requests for the /api/pages/ endpoint. A PUT creates or
+// updates the page with the given external id or name.
 func (as *Server) Pages(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		ps, err := models.GetPages(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
 		if err != nil {
-			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		ps, page, err := models.ListPages(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
 		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, ps, http.StatusOK)
 	//POST: Create a new page and return it as JSON
 	case r.Method == "POST":
@@ -35,17 +46,63 @@ func (as *Server) Pages(w http.ResponseWriter, r *http.Request) {
 		_, err = models.GetPageByName(p.Name, ctx.Get(r, "user_id").(int64))
 		if err != gorm.ErrRecordNotFound {
 			JSONResponse(w, models.Response{Success: false, Message: "Page name already in use"}, http.StatusConflict)
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			return
 		}
 		p.ModifiedDate = time.Now().UTC()
 		p.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PostPage(&p)
+		if err == models.ErrExternalIdInUse {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusConflict)
+			return
+		}
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
 		JSONResponse(w, p, http.StatusCreated)
+	// PUT: Create or update the page with the given external id or name
+	case r.Method == "PUT":
+		p := models.Page{}
+		err := json.NewDecoder(r.Body).Decode(&p)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		uid := ctx.Get(r, "user_id").(int64)
+		existing, err := models.GetPageForUpsert(p, uid)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		var current interface{}
+		if err == nil {
+			current = existing
+		}
+		if !checkPreconditions(w, r, current) {
+			return
+		}
+		p.Id = existing.Id
+		p.ModifiedDate = time.Now().UTC()
+		p.UserId = uid
+		status := http.StatusOK
+		if current == nil {
+			status = http.StatusCreated
+			err = models.PostPage(&p)
+		} else {
+			err = models.PutPage(&p)
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		p, err = models.GetPage(p.Id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading page"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, p, status)
 	}
 }
 
@@ -59,9 +116,12 @@ func (as *Server) Page(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: "Page not found"}, http.StatusNotFound)
 		return
 	}
+	if !checkPreconditions(w, r, p) {
+		return
+	}
 	switch {
 	case r.Method == "GET":
-		JSONResponse(w, p, http.StatusOK)
+		etagResponse(w, p, http.StatusOK)
 	case r.Method == "DELETE":
 		err = models.DeletePage(id, ctx.Get(r, "user_id").(int64))
 		if err != nil {
@@ -70,22 +130,37 @@ func (as *Server) Page(w http.ResponseWriter, r *http.Request) {
 		}
 		JSONResponse(w, models.Response{Success: true, Message: "Page Deleted Successfully"}, http.StatusOK)
 	case r.Method == "PUT":
+		externalId := p.ExternalId
 		p = models.Page{}
 		err = json.NewDecoder(r.Body).Decode(&p)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		if p.Id != id {
 			JSONResponse(w, models.Response{Success: false, Message: "/:id and /:page_id mismatch"}, http.StatusBadRequest)
 			return
 		}
+		// The admin UI doesn't send the external id, so keep the existing one
+		if p.ExternalId == "" {
+			p.ExternalId = externalId
+		}
 		p.ModifiedDate = time.Now().UTC()
 		p.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PutPage(&p)
+		if err == models.ErrExternalIdInUse {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusConflict)
+			return
+		}
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: "Error updating page: " + err.Error()}, http.StatusInternalServerError)
 			return
 		}
-		JSONResponse(w, p, http.StatusOK)
+		p, err = models.GetPage(id, p.UserId)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading page"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, p, http.StatusOK)
 	}
 }
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gophish/gophish/models"
+)
+
+// The headers used to return pagination information alongside a list
+const (
+	TotalCountHeader = "X-Total-Count"
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// listOptions parses the filtering, sorting, and pagination options from the
+// query string. The supported parameters are:
+//
+//	limit         the maximum number of items to return
+//	cursor        the cursor returned with the previous page
+//	sort          the field to sort by, prefixed with "-" for descending order
+//	filter[field] only return items where the field equals the value
+//
+// If none are provided, every item is returned as before.
+func listOptions(r *http.Request) (models.ListOptions, error) {
+	q := r.URL.Query()
+	opts := models.ListOptions{
+		Cursor:  q.Get("cursor"),
+		Sort:    q.Get("sort"),
+		Filters: map[string]string{},
+	}
+	if limit := q.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 {
+			return opts, models.ErrInvalidLimit
+		}
+		opts.Limit = l
+	}
+	for k := range q {
+		if strings.HasPrefix(k, "filter[") && strings.HasSuffix(k, "]") {
+			opts.Filters[k[len("filter["):len(k)-1]] = q.Get(k)
+		}
+	}
+	return opts, nil
+}
+
+// isListOptionsError returns whether or not the error was caused by invalid
+// list options, rather than a failure loading the list.
+func isListOptionsError(err error) bool {
+	switch err {
+	case models.ErrInvalidLimit, models.ErrInvalidSortField, models.ErrInvalidFilterField, models.ErrInvalidCursor, models.ErrNoFilterSpecified:
+		return true
+	}
+	return false
+}
+
+// setPageHeaders adds the pagination information for a list to the response
+// headers. The response body is the same list returned without pagination, so
+// existing clients are unaffected.
+func setPageHeaders(w http.ResponseWriter, r *http.Request, page models.PageInfo) {
+	w.Header().Set(TotalCountHeader, strconv.FormatInt(page.Total, 10))
+	if page.NextCursor == "" {
+		return
+	}
+	w.Header().Set(NextCursorHeader, page.NextCursor)
+	next := *r.URL
+	q := next.Query()
+	q.Set("cursor", page.NextCursor)
+	next.RawQuery = q.Encode()
+	w.Header().Set("Link", "<"+next.String()+">; rel=\"next\"")
+}
+
+// bulkDeleteResult is returned after deleting the items matching a filter
+type bulkDeleteResult struct {
+	Deleted int `json:"deleted"`
+}
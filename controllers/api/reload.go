@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/reload"
+)
+
+// Reload (/api/admin/reload) reloads the TLS certificates and log level
+// without restarting Gophish, which lets orchestration tools apply renewed
+// certificates while campaigns are running.
+func (as *Server) Reload(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		err := reload.Reload()
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Configuration reloaded"}, http.StatusOK)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// ReportedMessages returns the emails reported to the user's mailbox which
+// weren't sent by a campaign, optionally filtered by their status and
+// classification.
+func (as *Server) ReportedMessages(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		f := models.ReportedMessageFilter{
+			Status:         r.URL.Query().Get("status"),
+			Classification: r.URL.Query().Get("classification"),
+		}
+		rms, err := models.GetReportedMessages(uid, f)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching reported messages"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, rms, http.StatusOK)
+	}
+}
+
+// ReportedMessage returns, triages, or deletes the reported message specified
+// by the "id" parameter.
+func (as *Server) ReportedMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	rm, err := models.GetReportedMessage(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, rm, http.StatusOK)
+
+	case r.Method == "PUT":
+		t := models.ReportedMessageTriage{}
+		err = json.NewDecoder(r.Body).Decode(&t)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		rm, err = models.TriageReportedMessage(id, uid, t)
+		if err == models.ErrReportStatus {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error updating reported message"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, rm, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeleteReportedMessage(id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting reported message"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Reported message deleted successfully!"}, http.StatusOK)
+	}
+}
@@ -6,11 +6,16 @@ import (
 	"net/http"
 
 	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/schema"
 )
 
 // JSONResponse attempts to set the status code, c, and marshal the given interface, d, into a response that
 // is written to the given ResponseWriter.
+// If the client requested a schema version, the response is converted to it.
 func JSONResponse(w http.ResponseWriter, d interface{}, c int) {
+	if vw, ok := w.(*versionWriter); ok {
+		d = schema.Convert(d, vw.version)
+	}
 	dj, err := json.MarshalIndent(d, "", "  ")
 	if err != nil {
 		http.Error(w, "Error creating JSON response", http.StatusInternalServerError)
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/schema"
+)
+
+// versionWriter is a ResponseWriter which records the schema version the
+// client requested, so that JSONResponse can convert responses to it.
+type versionWriter struct {
+	http.ResponseWriter
+	version int
+}
+
+// Flush flushes the underlying writer, so that event streams still work.
+func (vw *versionWriter) Flush() {
+	if f, ok := vw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// requestedVersion returns the schema version requested by the
+// X-Gophish-Schema-Version or Accept headers.
+func requestedVersion(r *http.Request) (int, error) {
+	if h := r.Header.Get(schema.Header); h != "" {
+		return schema.Parse(h)
+	}
+	v, err := schema.FromAccept(r.Header.Get("Accept"))
+	if err != nil || v != 0 {
+		return v, err
+	}
+	return schema.Oldest, nil
+}
+
+// negotiateVersion responds to requests using the schema version they ask
+// for, returning a 406 if the version isn't supported.
+func negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := requestedVersion(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set(schema.Header, strconv.Itoa(version))
+		next.ServeHTTP(&versionWriter{ResponseWriter: w, version: version}, r)
+	})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestSchemaVersionNegotiation(t *testing.T) {
+	testCtx := setupTest(t)
+	tests := []struct {
+		header   string
+		accept   string
+		code     int
+		expected string
+	}{
+		{code: http.StatusOK, expected: "1"},
+		{header: "2", code: http.StatusOK, expected: "2"},
+		{accept: "application/vnd.gophish+json; version=2", code: http.StatusOK, expected: "2"},
+		{accept: "application/json", code: http.StatusOK, expected: "1"},
+		{header: "9", code: http.StatusNotAcceptable},
+		{accept: "application/vnd.gophish+json; version=0", code: http.StatusNotAcceptable},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/templates/", nil)
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		if tc.header != "" {
+			r.Header.Set("X-Gophish-Schema-Version", tc.header)
+		}
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Fatalf("unexpected status code for %q %q. expected %d got %d", tc.header, tc.accept, tc.code, w.Code)
+		}
+		if got := w.Header().Get("X-Gophish-Schema-Version"); got != tc.expected {
+			t.Fatalf("unexpected schema version for %q %q. expected %q got %q", tc.header, tc.accept, tc.expected, got)
+		}
+	}
+}
+
+func TestWebhookSchemaVersion(t *testing.T) {
+	testCtx := setupTest(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/webhooks/", bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+	// Webhooks default to the oldest version
+	w := post(`{"name":"Default","url":"http://example.com"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusCreated, w.Code)
+	}
+	wh := models.Webhook{}
+	err := json.NewDecoder(w.Body).Decode(&wh)
+	if err != nil {
+		t.Fatalf("error decoding webhook: %v", err)
+	}
+	if wh.SchemaVersion != 1 {
+		t.Fatalf("unexpected schema version. expected 1 got %d", wh.SchemaVersion)
+	}
+
+	w = post(`{"name":"Latest","url":"http://example.com","schema_version":2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusCreated, w.Code)
+	}
+	w = post(`{"name":"Future","url":"http://example.com","schema_version":9}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
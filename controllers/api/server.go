@@ -2,10 +2,13 @@ package api
 
 import (
 	"net/http"
+	"sync"
 
 	mid "github.com/gophish/gophish/middleware"
 	"github.com/gophish/gophish/middleware/ratelimit"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/openapi"
+	"github.com/gophish/gophish/spam"
 	"github.com/gophish/gophish/worker"
 	"github.com/gorilla/mux"
 )
@@ -18,9 +21,13 @@ type ServerOption func(*Server)
 // stopped. Rather, it's meant to be used as an http.Handler in the
 // AdminServer.
 type Server struct {
-	handler http.Handler
-	worker  worker.Worker
-	limiter *ratelimit.PostLimiter
+	handler  http.Handler
+	worker   worker.Worker
+	limiter  *ratelimit.PostLimiter
+	spec     *openapi.Document
+	specOnce sync.Once
+	graphql  bool
+	scorer   spam.Scorer
 }
 
 // NewServer returns a new instance of the API handler with the provided
@@ -52,41 +59,132 @@ func WithLimiter(limiter *ratelimit.PostLimiter) ServerOption {
 	}
 }
 
+// WithGraphQL is an option that enables the GraphQL endpoint.
+func WithGraphQL(enabled bool) ServerOption {
+	return func(as *Server) {
+		as.graphql = enabled
+	}
+}
+
+// WithSpamScorer is an option that sets the spam filter used to score
+// templates.
+func WithSpamScorer(s spam.Scorer) ServerOption {
+	return func(as *Server) {
+		as.scorer = s
+	}
+}
+
 func (as *Server) registerRoutes() {
 	root := mux.NewRouter()
 	root = root.StrictSlash(true)
+	// The specification doesn't require an API key, so that it can be used
+	// to generate clients.
+	root.HandleFunc("/api/spec", as.Spec)
+	// Embed tokens only grant access to the read-only statistics used by
+	// dashboards, rather than the rest of the API.
+	embed := root.PathPrefix("/api/embed/").Subrouter()
+	embed.Use(mid.RequireEmbedToken)
+	embed.HandleFunc("/", as.EmbedHealth)
+	embed.HandleFunc("/stats", as.EmbedStats)
+	embed.HandleFunc("/campaigns", as.EmbedCampaigns)
+	embed.HandleFunc("/timeseries", as.EmbedTimeSeries)
+	embed.HandleFunc("/metrics", as.EmbedMetrics)
+	embed.HandleFunc("/search", as.EmbedSearch)
+	embed.HandleFunc("/query", as.EmbedQuery)
 	router := root.PathPrefix("/api/").Subrouter()
 	router.Use(mid.RequireAPIKey)
 	router.Use(mid.EnforceViewOnly)
+	router.Use(negotiateVersion)
+	router.HandleFunc("/admin/backup", mid.Use(as.Backup, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/admin/restore", mid.Use(as.Restore, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/admin/reload", mid.Use(as.Reload, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/admin/worker", mid.Use(as.WorkerStats, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/imap/", as.IMAPServer)
 	router.HandleFunc("/imap/validate", as.IMAPServerValidate)
+	router.HandleFunc("/imap/oauth2/device", as.IMAPDeviceAuthorization)
+	router.HandleFunc("/incident_connectors/", as.IncidentConnectors)
+	router.HandleFunc("/incident_connectors/{id:[0-9]+}", as.IncidentConnector)
+	router.HandleFunc("/reported_messages/", as.ReportedMessages)
+	router.HandleFunc("/reported_messages/{id:[0-9]+}", as.ReportedMessage)
 	router.HandleFunc("/reset", as.Reset)
 	router.HandleFunc("/campaigns/", as.Campaigns)
 	router.HandleFunc("/campaigns/summary", as.CampaignsSummary)
+	router.HandleFunc("/campaigns/compare", as.CampaignsCompare)
+	router.HandleFunc("/campaigns/difficulty", as.CampaignsDifficulty)
+	router.HandleFunc("/campaigns/calendar", as.CampaignsCalendar)
 	router.HandleFunc("/campaigns/{id:[0-9]+}", as.Campaign)
 	router.HandleFunc("/campaigns/{id:[0-9]+}/results", as.CampaignResults)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/export", as.CampaignExport)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/indicators", as.CampaignIndicators)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/allowlist", as.CampaignAllowlist)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/results/delta", as.CampaignResultsDelta)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/results/{rid:[A-Za-z0-9]+}/timeline", as.CampaignResultTimeline)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/results/{rid:[A-Za-z0-9]+}/annotations", as.CampaignResultAnnotations)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/requests", as.CampaignRequests)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/purge", as.CampaignPurge)
 	router.HandleFunc("/campaigns/{id:[0-9]+}/summary", as.CampaignSummary)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/passwords", as.CampaignPasswords)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/progress", as.CampaignProgress)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/maillogs", as.CampaignMailLogs)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/waves", as.CampaignWaves)
 	router.HandleFunc("/campaigns/{id:[0-9]+}/complete", as.CampaignComplete)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/resend", as.CampaignResend)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/clone", as.CampaignClone)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/approve", mid.Use(as.CampaignApprove, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/campaigns/{id:[0-9]+}/recipients", as.CampaignRecipients)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/not_found_page", as.CampaignNotFoundPage)
+	router.HandleFunc("/campaigns/{id:[0-9]+}/priority", as.CampaignPriority)
+	router.HandleFunc("/domains/", as.Domains)
+	router.HandleFunc("/domains/{id:[0-9]+}", as.Domain)
+	router.HandleFunc("/domains/{id:[0-9]+}/check", as.DomainCheck)
+	router.HandleFunc("/domains/{id:[0-9]+}/dns", as.DomainDNS)
+	router.HandleFunc("/embed_tokens/", as.EmbedTokens)
+	router.HandleFunc("/embed_tokens/{id:[0-9]+}", as.EmbedToken)
+	router.HandleFunc("/events/stream", as.EventStream)
+	router.HandleFunc("/graphql", as.GraphQL)
 	router.HandleFunc("/groups/", as.Groups)
 	router.HandleFunc("/groups/summary", as.GroupsSummary)
 	router.HandleFunc("/groups/{id:[0-9]+}", as.Group)
 	router.HandleFunc("/groups/{id:[0-9]+}/summary", as.GroupSummary)
+	router.HandleFunc("/groups/{id:[0-9]+}/targets", as.GroupTargets)
+	router.HandleFunc("/targets/status", as.TargetStatus)
+	router.HandleFunc("/honeytokens/", as.Honeytokens)
+	router.HandleFunc("/honeytokens/sightings", as.HoneytokenSightings)
 	router.HandleFunc("/templates/", as.Templates)
 	router.HandleFunc("/templates/{id:[0-9]+}", as.Template)
+	router.HandleFunc("/templates/{id:[0-9]+}/images", as.TemplateImages)
+	router.HandleFunc("/templates/{id:[0-9]+}/preview", as.TemplatePreview)
+	router.HandleFunc("/templates/{id:[0-9]+}/score", as.TemplateScore)
+	router.HandleFunc("/notification_rules/", as.NotificationRules)
+	router.HandleFunc("/notification_rules/{id:[0-9]+}", as.NotificationRule)
+	router.HandleFunc("/ticket_connectors/", as.TicketConnectors)
+	router.HandleFunc("/ticket_connectors/{id:[0-9]+}", as.TicketConnector)
+	router.HandleFunc("/ticket_connectors/{id:[0-9]+}/tickets", as.TicketConnectorTickets)
+	router.HandleFunc("/org/", as.OrgChart)
+	router.HandleFunc("/org/rollup/departments", as.OrgDepartmentRollup)
+	router.HandleFunc("/org/rollup/managers", as.OrgManagerRollup)
+	router.HandleFunc("/org/rollup/tags", as.OrgTagRollup)
 	router.HandleFunc("/pages/", as.Pages)
 	router.HandleFunc("/pages/{id:[0-9]+}", as.Page)
 	router.HandleFunc("/smtp/", as.SendingProfiles)
+	router.HandleFunc("/smtp/health", as.SendingProfilesHealth)
 	router.HandleFunc("/smtp/{id:[0-9]+}", as.SendingProfile)
+	router.HandleFunc("/smtp/{id:[0-9]+}/preflight", as.SendingProfilePreflight)
+	router.HandleFunc("/smtp/{id:[0-9]+}/health", as.SendingProfileHealth)
 	router.HandleFunc("/users/", mid.Use(as.Users, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/users/{id:[0-9]+}", mid.Use(as.User))
+	router.HandleFunc("/users/{id:[0-9]+}/sessions", as.UserSessions)
+	router.HandleFunc("/users/{id:[0-9]+}/sessions/{sid:[0-9]+}", as.UserSession)
 	router.HandleFunc("/util/send_test_email", as.SendTestEmail)
 	router.HandleFunc("/import/group", as.ImportGroup)
+	router.HandleFunc("/import/org", as.ImportOrgChart)
 	router.HandleFunc("/import/email", as.ImportEmail)
 	router.HandleFunc("/import/site", as.ImportSite)
 	router.HandleFunc("/webhooks/", mid.Use(as.Webhooks, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/webhooks/{id:[0-9]+}/validate", mid.Use(as.ValidateWebhook, mid.RequirePermission(models.PermissionModifySystem)))
+	router.HandleFunc("/webhooks/{id:[0-9]+}/rotate", mid.Use(as.RotateWebhookSecret, mid.RequirePermission(models.PermissionModifySystem)))
 	router.HandleFunc("/webhooks/{id:[0-9]+}", mid.Use(as.Webhook, mid.RequirePermission(models.PermissionModifySystem)))
-	as.handler = router
+	as.handler = root
 }
 
 func (as *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// sessionUser returns the user whose sessions are requested. Users without
+// the ModifySystem permission can only manage their own sessions.
+func sessionUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	currentUser := ctx.Get(r, "user").(models.User)
+	hasSystem, err := currentUser.HasPermission(models.PermissionModifySystem)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		return models.User{}, false
+	}
+	if !hasSystem && currentUser.Id != id {
+		JSONResponse(w, models.Response{Success: false, Message: http.StatusText(http.StatusForbidden)}, http.StatusForbidden)
+		return models.User{}, false
+	}
+	u, err := models.GetUser(id)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "User not found"}, http.StatusNotFound)
+		return models.User{}, false
+	}
+	return u, true
+}
+
+// UserSessions lists the active admin sessions of a user, marking the session
+// making the request as the current one.
+func (as *Server) UserSessions(w http.ResponseWriter, r *http.Request) {
+	u, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		ss, err := models.GetAdminSessions(u.Id)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if current, ok := ctx.Get(r, "admin_session").(models.AdminSession); ok {
+			for i := range ss {
+				ss[i].Current = ss[i].Id == current.Id
+			}
+		}
+		JSONResponse(w, ss, http.StatusOK)
+	}
+}
+
+// UserSession revokes one of a user's admin sessions, logging it out on its
+// next request.
+func (as *Server) UserSession(w http.ResponseWriter, r *http.Request) {
+	u, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	sid, _ := strconv.ParseInt(vars["sid"], 0, 64)
+	switch {
+	case r.Method == "DELETE":
+		err := models.DeleteAdminSession(sid, u.Id)
+		if err == models.ErrSessionNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		log.FromContext(r.Context()).Infof("Revoked session %d of user %s", sid, u.Username)
+		JSONResponse(w, models.Response{Success: true, Message: "Session revoked successfully"}, http.StatusOK)
+	}
+}
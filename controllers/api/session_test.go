@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ctx "github.com/gophish/gophish/context"
+	"github.com/gophish/gophish/models"
+)
+
+func TestUserSessions(t *testing.T) {
+	testCtx := setupTest(t)
+	user := createUnpriviledgedUser(t, models.RoleUser)
+	current, _, err := models.CreateAdminSession(user.Id, "127.0.0.1", "current")
+	if err != nil {
+		t.Fatalf("error creating session: %v", err)
+	}
+	other, _, err := models.CreateAdminSession(user.Id, "127.0.0.2", "other")
+	if err != nil {
+		t.Fatalf("error creating session: %v", err)
+	}
+
+	url := fmt.Sprintf("/api/users/%d/sessions", user.Id)
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", user.ApiKey))
+	r = ctx.Set(r, "admin_session", current)
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected error code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	ss := []models.AdminSession{}
+	err = json.NewDecoder(w.Body).Decode(&ss)
+	if err != nil {
+		t.Fatalf("error decoding sessions: %v", err)
+	}
+	if len(ss) != 2 {
+		t.Fatalf("unexpected number of sessions. expected %d got %d", 2, len(ss))
+	}
+	for _, s := range ss {
+		if s.Current != (s.Id == current.Id) {
+			t.Fatalf("unexpected current session %d", s.Id)
+		}
+	}
+
+	// Users can't list the sessions of other users
+	url = fmt.Sprintf("/api/users/%d/sessions", testCtx.admin.Id)
+	r = httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", user.ApiKey))
+	w = httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unexpected error code received. expected %d got %d", http.StatusForbidden, w.Code)
+	}
+
+	// Admins can revoke the sessions of other users
+	url = fmt.Sprintf("/api/users/%d/sessions/%d", user.Id, other.Id)
+	r = httptest.NewRequest(http.MethodDelete, url, nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w = httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected error code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	ss, err = models.GetAdminSessions(user.Id)
+	if err != nil {
+		t.Fatalf("error getting sessions: %v", err)
+	}
+	if len(ss) != 1 || ss[0].Id != current.Id {
+		t.Fatalf("unexpected sessions after revoking. got %#v", ss)
+	}
+
+	w = httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected error code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,14 +15,29 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-// SendingProfiles handles requests for the /api/smtp/ endpoint
+// preflightTimeout limits how long the DNS lookups and probe email made for a
+// pre-flight check can take.
+const preflightTimeout = 30 * time.Second
+
+// SendingProfiles handles requests for the /api/smtp/ endpoint. A PUT creates
+// or updates the sending profile with the given external id or name.
 func (as *Server) SendingProfiles(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		ss, err := models.GetSMTPs(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
 		if err != nil {
-			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		ss, page, err := models.ListSMTPs(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
 		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, ss, http.StatusOK)
 	//POST: Create a new SMTP and return it as JSON
 	case r.Method == "POST":
@@ -35,17 +52,63 @@ func (as *Server) SendingProfiles(w http.ResponseWriter, r *http.Request) {
 		_, err = models.GetSMTPByName(s.Name, ctx.Get(r, "user_id").(int64))
 		if err != gorm.ErrRecordNotFound {
 			JSONResponse(w, models.Response{Success: false, Message: "SMTP name already in use"}, http.StatusConflict)
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			return
 		}
 		s.ModifiedDate = time.Now().UTC()
 		s.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PostSMTP(&s)
+		if err == models.ErrExternalIdInUse {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusConflict)
+			return
+		}
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
 		JSONResponse(w, s, http.StatusCreated)
+	// PUT: Create or update the sending profile with the given external id or name
+	case r.Method == "PUT":
+		s := models.SMTP{}
+		err := json.NewDecoder(r.Body).Decode(&s)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		uid := ctx.Get(r, "user_id").(int64)
+		existing, err := models.GetSMTPForUpsert(s, uid)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		var current interface{}
+		if err == nil {
+			current = existing
+		}
+		if !checkPreconditions(w, r, current) {
+			return
+		}
+		s.Id = existing.Id
+		s.ModifiedDate = time.Now().UTC()
+		s.UserId = uid
+		status := http.StatusOK
+		if current == nil {
+			status = http.StatusCreated
+			err = models.PostSMTP(&s)
+		} else {
+			err = models.PutSMTP(&s)
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		s, err = models.GetSMTP(s.Id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading sending profile"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, s, status)
 	}
 }
 
@@ -59,9 +122,12 @@ func (as *Server) SendingProfile(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: "SMTP not found"}, http.StatusNotFound)
 		return
 	}
+	if !checkPreconditions(w, r, s) {
+		return
+	}
 	switch {
 	case r.Method == "GET":
-		JSONResponse(w, s, http.StatusOK)
+		etagResponse(w, s, http.StatusOK)
 	case r.Method == "DELETE":
 		err = models.DeleteSMTP(id, ctx.Get(r, "user_id").(int64))
 		if err != nil {
@@ -70,27 +136,119 @@ func (as *Server) SendingProfile(w http.ResponseWriter, r *http.Request) {
 		}
 		JSONResponse(w, models.Response{Success: true, Message: "SMTP Deleted Successfully"}, http.StatusOK)
 	case r.Method == "PUT":
+		externalId := s.ExternalId
 		s = models.SMTP{}
 		err = json.NewDecoder(r.Body).Decode(&s)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		if s.Id != id {
 			JSONResponse(w, models.Response{Success: false, Message: "/:id and /:smtp_id mismatch"}, http.StatusBadRequest)
 			return
 		}
+		// Keep the existing external id if one isn't given
+		if s.ExternalId == "" {
+			s.ExternalId = externalId
+		}
+		s.UserId = ctx.Get(r, "user_id").(int64)
 		err = s.Validate()
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
 		s.ModifiedDate = time.Now().UTC()
-		s.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PutSMTP(&s)
+		if err == models.ErrExternalIdInUse {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusConflict)
+			return
+		}
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: "Error updating page"}, http.StatusInternalServerError)
 			return
 		}
-		JSONResponse(w, s, http.StatusOK)
+		s, err = models.GetSMTP(id, s.UserId)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading sending profile"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, s, http.StatusOK)
+	}
+}
+
+// SendingProfilePreflight checks the deliverability of emails sent using the
+// sending profile before a campaign is launched.
+func (as *Server) SendingProfilePreflight(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	s, err := models.GetSMTP(id, ctx.Get(r, "user_id").(int64))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "SMTP not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "POST":
+		req := models.PreflightRequest{}
+		// The request body is optional, since every field has a default.
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		c, cancel := context.WithTimeout(r.Context(), preflightTimeout)
+		defer cancel()
+		report, err := s.Preflight(c, req)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, report, http.StatusOK)
+	}
+}
+
+// SendingProfilesHealth returns the health of each of the user's sending
+// profiles.
+func (as *Server) SendingProfilesHealth(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		hs, err := models.GetSMTPHealths(ctx.Get(r, "user_id").(int64))
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching sending profile health"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, hs, http.StatusOK)
+	}
+}
+
+// SendingProfileHealth returns the health of a sending profile if requested
+// via GET. If requested via POST, the profile's health is checked straight
+// away, resuming its emails if its circuit breaker had tripped and the check
+// succeeds.
+func (as *Server) SendingProfileHealth(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	s, err := models.GetSMTP(id, ctx.Get(r, "user_id").(int64))
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "SMTP not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		h, err := models.GetSMTPHealth(s.Id, s.UserId)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching sending profile health"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, h, http.StatusOK)
+	case r.Method == "POST":
+		h, err := s.CheckHealth()
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error checking sending profile health"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, h, http.StatusOK)
 	}
 }
@@ -0,0 +1,489 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gophish/gophish/backup"
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/deliverability"
+	"github.com/gophish/gophish/imap"
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/openapi"
+	"github.com/gophish/gophish/schema"
+	"github.com/gophish/gophish/spam"
+	"github.com/gophish/gophish/worker"
+)
+
+// specOperation documents a single API operation. The OpenAPI specification
+// served at /api/spec is built from these, and the tests ensure that every
+// registered route is documented.
+type specOperation struct {
+	Method   string
+	Path     string
+	ID       string
+	Tag      string
+	Summary  string
+	Query    []openapi.Parameter
+	Request  interface{}
+	Response interface{}
+	Status   int
+	// List is set for lists supporting filtering, sorting, and pagination
+	List bool
+	// Admin is set for operations requiring the modify_system permission
+	Admin bool
+	// Content overrides the media type of the request body, or of the
+	// response for streams and downloads
+	Content string
+	// Conditional is set for operations supporting ETag based concurrency
+	// control using the If-Match and If-None-Match headers
+	Conditional bool
+	// Upsert is set for conditional operations which create the item if it
+	// doesn't exist
+	Upsert bool
+	// Embed is set for operations authenticated using an embed token rather
+	// than an API key
+	Embed bool
+}
+
+// The media types used by operations which don't send or receive JSON
+const (
+	contentNDJSON      = "application/x-ndjson"
+	contentMultipart   = "multipart/form-data"
+	contentEventStream = "text/event-stream"
+	contentBinary      = "application/octet-stream"
+	contentCSV         = "text/csv"
+)
+
+// listParameters are the parameters accepted by lists which support
+// filtering, sorting, and pagination.
+var listParameters = []openapi.Parameter{
+	{Name: "limit", In: "query", Description: "The maximum number of items to return", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "cursor", In: "query", Description: "The cursor returned in the X-Next-Cursor header of the previous page", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "sort", In: "query", Description: "The field to sort by, prefixed with \"-\" for descending order", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "filter", In: "query", Description: "Only return items where the field equals the value, e.g. filter[name]=value", Style: "deepObject", Explode: true,
+		Schema: &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}}},
+}
+
+// conditionalParameters are the headers accepted by operations supporting
+// concurrency control.
+var conditionalParameters = []openapi.Parameter{
+	{Name: IfMatchHeader, In: "header", Description: "Only apply the request if the item's current ETag matches", Schema: &openapi.Schema{Type: "string"}},
+	{Name: IfNoneMatchHeader, In: "header", Description: "Only apply the request if the item's current ETag doesn't match. Use * to only create new items.", Schema: &openapi.Schema{Type: "string"}},
+}
+
+// schemaVersionParameter selects the schema version of the response. The
+// version can also be given in the Accept header.
+var schemaVersionParameter = openapi.Parameter{Name: schema.Header, In: "header",
+	Description: fmt.Sprintf("The schema version of the response, from %d to %d. It defaults to %d.", schema.Oldest, schema.Latest, schema.Oldest),
+	Schema:      &openapi.Schema{Type: "integer"}}
+
+// filterParameter is the filter required by bulk deletes
+var filterParameter = openapi.Parameter{Name: "filter", In: "query", Required: true, Description: "Delete the items where the field equals the value, e.g. filter[status]=Completed", Style: "deepObject", Explode: true,
+	Schema: &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}}}
+
+// campaignIdsParameter limits the org chart rollups to some campaigns
+var campaignIdsParameter = openapi.Parameter{Name: "campaign_ids", In: "query", Description: "The comma separated ids of the campaigns to include. Defaults to every campaign.", Schema: &openapi.Schema{Type: "string"}}
+
+// previewFormatParameter selects how a template preview is returned
+var previewFormatParameter = openapi.Parameter{Name: "format", In: "query", Description: "Return just the HTML body (html) or the raw email (eml) instead of JSON",
+	Schema: &openapi.Schema{Type: "string", Enum: []string{"html", "eml"}}}
+
+type inlineImageRequest struct {
+	File []byte `json:"file"`
+}
+
+type importEmailRequest struct {
+	Content      string `json:"content"`
+	ConvertLinks bool   `json:"convert_links"`
+}
+
+type purgeRequest struct {
+	Reason string `json:"reason"`
+}
+
+type importGroupRequest struct {
+	File []byte `json:"file"`
+}
+
+type restoreRequest struct {
+	File       []byte `json:"file"`
+	Passphrase string `json:"passphrase"`
+}
+
+var specOperations = []specOperation{
+	{Method: "POST", Path: "/admin/backup", ID: "createBackup", Tag: "admin", Summary: "Download an encrypted backup of the database and uploaded assets", Request: backupRequest{}, Content: contentBinary, Admin: true},
+	{Method: "POST", Path: "/admin/restore", ID: "restoreBackup", Tag: "admin", Summary: "Restore the database and uploaded assets from a backup", Request: restoreRequest{}, Response: backup.Summary{}, Content: contentMultipart, Admin: true},
+	{Method: "POST", Path: "/admin/reload", ID: "reload", Tag: "admin", Summary: "Reload the TLS certificates and log level", Admin: true},
+	{Method: "GET", Path: "/admin/worker", ID: "getWorkerStats", Tag: "admin", Summary: "Get the background worker's batching and sending metrics", Response: worker.Stats{}, Admin: true},
+	{Method: "GET", Path: "/campaigns/", ID: "listCampaigns", Tag: "campaigns", Summary: "List campaigns", Response: []models.Campaign{}, List: true},
+	{Method: "POST", Path: "/campaigns/", ID: "createCampaign", Tag: "campaigns", Summary: "Create and launch a campaign", Request: models.Campaign{}, Response: models.Campaign{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/campaigns/", ID: "deleteCampaigns", Tag: "campaigns", Summary: "Delete the campaigns matching a filter", Query: []openapi.Parameter{filterParameter}},
+	{Method: "GET", Path: "/campaigns/summary", ID: "listCampaignSummaries", Tag: "campaigns", Summary: "List campaign summaries", Response: models.CampaignSummaries{}, List: true},
+	{Method: "GET", Path: "/campaigns/compare", ID: "compareCampaigns", Tag: "campaigns", Summary: "Compare the metrics of campaigns", Response: models.CampaignComparison{},
+		Query: []openapi.Parameter{
+			{Name: "ids", In: "query", Required: true, Description: "The comma separated ids of the campaigns to compare. The first campaign is the reference, unless the baseline is used.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "baseline", In: "query", Description: "Compare the campaigns to the combined results of the other completed campaigns", Schema: &openapi.Schema{Type: "boolean"}},
+		}},
+	{Method: "GET", Path: "/campaigns/difficulty", ID: "getCampaignsDifficulty", Tag: "campaigns", Summary: "Get click rates normalized by template difficulty", Response: models.DifficultyReport{}},
+	{Method: "GET", Path: "/campaigns/calendar", ID: "getCampaignCalendar", Tag: "campaigns", Summary: "List the campaigns on a calendar, warning of campaigns which send to the same recipients close together", Response: models.Calendar{},
+		Query: []openapi.Parameter{
+			{Name: "start", In: "query", Description: "The RFC 3339 start of the calendar. Defaults to 30 days ago.", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "end", In: "query", Description: "The RFC 3339 end of the calendar. Defaults to 60 days from now.", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "window_days", In: "query", Description: "How many days apart campaigns sending to the same recipients must run to avoid a conflict. Defaults to 7.", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "all", In: "query", Description: "Include every user's campaigns. Requires the modify_system permission.", Schema: &openapi.Schema{Type: "boolean"}},
+		}},
+	{Method: "GET", Path: "/campaigns/{id}", ID: "getCampaign", Tag: "campaigns", Summary: "Get a campaign", Response: models.Campaign{}},
+	{Method: "DELETE", Path: "/campaigns/{id}", ID: "deleteCampaign", Tag: "campaigns", Summary: "Delete a campaign"},
+	{Method: "GET", Path: "/campaigns/{id}/results", ID: "getCampaignResults", Tag: "campaigns", Summary: "Get the results for a campaign", Response: models.CampaignResults{}, List: true},
+	{Method: "GET", Path: "/campaigns/{id}/export", ID: "exportCampaignResults", Tag: "campaigns", Summary: "Stream the results of a campaign as a CSV file or JSON array", Response: []map[string]interface{}{}, Content: contentCSV,
+		Query: []openapi.Parameter{
+			{Name: "format", In: "query", Description: "The export format, csv or json. Defaults to csv.", Schema: &openapi.Schema{Type: "string", Enum: []string{exportCSV, exportJSON}}},
+			{Name: "fields", In: "query", Description: "The comma separated fields to export, in order. Defaults to the recipient's details, status, tags and notes.", Schema: &openapi.Schema{Type: "string"}},
+			listParameters[2],
+			listParameters[3],
+		}},
+	{Method: "GET", Path: "/campaigns/{id}/indicators", ID: "exportCampaignIndicators", Tag: "campaigns", Summary: "Export the indicators generated by a campaign, such as its sending domains, links, Message-IDs and attachment hashes", Response: []models.CampaignIndicator{}, Content: contentCSV,
+		Query: []openapi.Parameter{
+			{Name: "format", In: "query", Description: "The export format, csv, json, or stix for a STIX 2.1 bundle. Defaults to csv.", Schema: &openapi.Schema{Type: "string", Enum: []string{exportCSV, exportJSON, exportSTIX}}},
+		}},
+	{Method: "GET", Path: "/campaigns/{id}/allowlist", ID: "getCampaignAllowlist", Tag: "campaigns", Summary: "Get the header token, senders and mail gateway rules needed to allowlist a campaign's emails", Response: models.CampaignAllowlist{}},
+	{Method: "GET", Path: "/campaigns/{id}/results/delta", ID: "getCampaignResultsDelta", Tag: "campaigns", Summary: "Get the results which changed since the last request", Response: models.CampaignResultsDelta{},
+		Query: []openapi.Parameter{
+			{Name: "since", In: "query", Description: "Only return changes after this time", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "since_id", In: "query", Description: "Only return events after this event id", Schema: &openapi.Schema{Type: "integer"}},
+		}},
+	{Method: "GET", Path: "/campaigns/{id}/results/{rid}/timeline", ID: "getCampaignResultTimeline", Tag: "campaigns", Summary: "Get the ordered events for a single recipient", Response: models.Timeline{}},
+	{Method: "PUT", Path: "/campaigns/{id}/results/{rid}/annotations", ID: "annotateCampaignResult", Tag: "campaigns", Summary: "Replace the analyst tags and notes of a single recipient", Request: models.ResultAnnotation{}, Response: models.Result{}},
+	{Method: "GET", Path: "/campaigns/{id}/requests", ID: "getCampaignRequests", Tag: "campaigns", Summary: "Get the HTTP requests captured for a campaign", Response: []models.EventRequest{}},
+	{Method: "GET", Path: "/campaigns/{id}/purge", ID: "listCampaignPurges", Tag: "campaigns", Summary: "List the purges of a campaign's data", Response: []models.CampaignPurge{}},
+	{Method: "POST", Path: "/campaigns/{id}/purge", ID: "purgeCampaign", Tag: "campaigns", Summary: "Purge the captured data for a campaign", Request: purgeRequest{}, Response: models.CampaignPurge{}},
+	{Method: "GET", Path: "/campaigns/{id}/summary", ID: "getCampaignSummary", Tag: "campaigns", Summary: "Get a campaign summary", Response: models.CampaignSummary{}},
+	{Method: "GET", Path: "/campaigns/{id}/passwords", ID: "getCampaignPasswords", Tag: "campaigns", Summary: "Get the password policy stats of a campaign", Response: models.PasswordStats{}},
+	{Method: "GET", Path: "/campaigns/{id}/progress", ID: "getCampaignProgress", Tag: "campaigns", Summary: "Get the progress of creating a campaign's results", Response: models.LaunchProgress{}},
+	{Method: "GET", Path: "/campaigns/{id}/maillogs", ID: "listCampaignMailLogs", Tag: "campaigns", Summary: "List the emails waiting to be sent for a campaign, including SMTP transcripts of failed attempts", Response: []models.MailLog{}},
+	{Method: "GET", Path: "/campaigns/{id}/waves", ID: "listCampaignWaves", Tag: "campaigns", Summary: "List the waves of a campaign, along with the stats of each wave's results", Response: []models.CampaignWave{}},
+	{Method: "GET", Path: "/campaigns/{id}/complete", ID: "completeCampaign", Tag: "campaigns", Summary: "Mark a campaign as complete"},
+	{Method: "POST", Path: "/campaigns/{id}/resend", ID: "resendCampaign", Tag: "campaigns", Summary: "Re-send the emails which failed to send in a campaign", Request: models.ResendRequest{}, Response: models.ResendResult{}},
+	{Method: "POST", Path: "/campaigns/{id}/approve", ID: "approveCampaign", Tag: "campaigns", Summary: "Approve a campaign sent to targets with an approval tag, so that its emails are sent", Response: models.Campaign{}, Admin: true},
+	{Method: "POST", Path: "/campaigns/{id}/clone", ID: "cloneCampaign", Tag: "campaigns", Summary: "Create a campaign from an existing one, replacing its groups, sending profile, URL, and launch date", Request: models.CloneRequest{}, Response: models.Campaign{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/campaigns/{id}/not_found_page", ID: "setCampaignNotFoundPage", Tag: "campaigns", Summary: "Set the landing page served in place of a 404 response for a campaign's requests", Request: models.NotFoundPageRequest{}},
+	{Method: "PUT", Path: "/campaigns/{id}/priority", ID: "setCampaignPriority", Tag: "campaigns", Summary: "Set the priority of a campaign's queued emails", Request: models.PriorityRequest{}},
+	{Method: "POST", Path: "/campaigns/{id}/recipients", ID: "addCampaignRecipients", Tag: "campaigns", Summary: "Add recipients to a campaign which has already been launched", Request: models.AddRecipientsRequest{}, Response: models.AddRecipientsResult{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/embed_tokens/", ID: "listEmbedTokens", Tag: "embed", Summary: "List the read-only embed tokens used by dashboards", Response: []models.EmbedToken{}},
+	{Method: "POST", Path: "/embed_tokens/", ID: "createEmbedToken", Tag: "embed", Summary: "Create an embed token, which is only returned once", Request: models.EmbedToken{}, Response: models.EmbedToken{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/embed_tokens/{id}", ID: "deleteEmbedToken", Tag: "embed", Summary: "Revoke an embed token"},
+	{Method: "GET", Path: "/embed/", ID: "checkEmbedToken", Tag: "embed", Summary: "Check an embed token", Embed: true},
+	{Method: "GET", Path: "/embed/stats", ID: "getEmbedStats", Tag: "embed", Summary: "Get the aggregate statistics of the campaigns", Response: models.EmbedStats{}, Embed: true},
+	{Method: "GET", Path: "/embed/campaigns", ID: "listEmbedCampaigns", Tag: "embed", Summary: "List the campaign summaries", Response: []models.CampaignSummary{}, Embed: true},
+	{Method: "GET", Path: "/embed/timeseries", ID: "getEmbedTimeSeries", Tag: "embed", Summary: "Count the events during each interval of a time range, grouped by type or campaign", Response: []eventSeriesRow{}, Embed: true,
+		Query: []openapi.Parameter{
+			{Name: "from", In: "query", Description: "The start of the time range, in RFC 3339 format or milliseconds since the epoch. It defaults to a day before the end.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "to", In: "query", Description: "The end of the time range, in RFC 3339 format or milliseconds since the epoch. It defaults to now.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "interval", In: "query", Description: "The length of each interval, such as 5m or 1h", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "campaign_id", In: "query", Description: "Only count the events of this campaign", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+			{Name: "group_by", In: "query", Description: "Group the events by type or campaign. It defaults to type.", Schema: &openapi.Schema{Type: "string", Enum: []string{models.SeriesByType, models.SeriesByCampaign}}},
+		}},
+	{Method: "POST", Path: "/embed/metrics", ID: "listEmbedMetrics", Tag: "embed", Summary: "List the metrics which can be queried by the Grafana JSON datasource", Response: []embedMetric{}, Embed: true},
+	{Method: "POST", Path: "/embed/search", ID: "searchEmbedMetrics", Tag: "embed", Summary: "List the names of the metrics which can be queried by the SimpleJSON datasource", Response: []string{}, Embed: true},
+	{Method: "POST", Path: "/embed/query", ID: "queryEmbedMetrics", Tag: "embed", Summary: "Query metrics for the Grafana JSON datasource", Request: embedQuery{}, Response: []interface{}{}, Embed: true},
+	{Method: "GET", Path: "/events/stream", ID: "streamEvents", Tag: "campaigns", Summary: "Stream campaign events as they happen using Server-Sent Events", Response: models.Event{}, Content: contentEventStream,
+		Query: []openapi.Parameter{
+			{Name: "campaign_id", In: "query", Description: "Only stream events for this campaign", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "last_event_id", In: "query", Description: "Replay the events after this event id", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "Last-Event-ID", In: "header", Description: "Replay the events after this event id", Schema: &openapi.Schema{Type: "integer"}},
+		}},
+	{Method: "GET", Path: "/graphql", ID: "queryGraphQLGet", Tag: "graphql", Summary: "Execute a GraphQL query, if enabled", Response: graphqlResult{},
+		Query: []openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Description: "The GraphQL query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "variables", In: "query", Description: "The JSON encoded variables", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "operationName", In: "query", Description: "The operation to execute", Schema: &openapi.Schema{Type: "string"}},
+		}},
+	{Method: "POST", Path: "/graphql", ID: "queryGraphQL", Tag: "graphql", Summary: "Execute a GraphQL query, if enabled", Request: graphqlParams{}, Response: graphqlResult{}},
+	{Method: "GET", Path: "/domains/", ID: "listDomains", Tag: "domains", Summary: "List the sending and landing domains in the inventory, along with the results of their checks", Response: []models.Domain{}},
+	{Method: "POST", Path: "/domains/", ID: "createDomain", Tag: "domains", Summary: "Add a domain to the inventory", Request: models.Domain{}, Response: models.Domain{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/domains/{id}", ID: "getDomain", Tag: "domains", Summary: "Get a domain", Response: models.Domain{}},
+	{Method: "PUT", Path: "/domains/{id}", ID: "updateDomain", Tag: "domains", Summary: "Update the registration details and DNS settings of a domain", Request: models.Domain{}, Response: models.Domain{}},
+	{Method: "DELETE", Path: "/domains/{id}", ID: "deleteDomain", Tag: "domains", Summary: "Remove a domain from the inventory"},
+	{Method: "POST", Path: "/domains/{id}/check", ID: "checkDomain", Tag: "domains", Summary: "Check a domain's DNS records, blocklistings, reputation and certificate now", Response: models.Domain{}},
+	{Method: "POST", Path: "/domains/{id}/dns", ID: "provisionDomainDNS", Tag: "domains", Summary: "Create a domain's A, MX, SPF, DKIM and DMARC records using its DNS provider", Response: models.Domain{}},
+	{Method: "DELETE", Path: "/domains/{id}/dns", ID: "teardownDomainDNS", Tag: "domains", Summary: "Remove the DNS records created for a domain", Response: models.Domain{}},
+	{Method: "GET", Path: "/groups/", ID: "listGroups", Tag: "groups", Summary: "List groups", Response: []models.Group{}, List: true},
+	{Method: "POST", Path: "/groups/", ID: "createGroup", Tag: "groups", Summary: "Create a group", Request: models.Group{}, Response: models.Group{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/groups/", ID: "upsertGroup", Tag: "groups", Summary: "Create or update the group with the given external id or name", Request: models.Group{}, Response: models.Group{}, Upsert: true},
+	{Method: "GET", Path: "/groups/summary", ID: "listGroupSummaries", Tag: "groups", Summary: "List group summaries", Response: models.GroupSummaries{}, List: true},
+	{Method: "GET", Path: "/groups/{id}", ID: "getGroup", Tag: "groups", Summary: "Get a group", Response: models.Group{}, Conditional: true},
+	{Method: "PUT", Path: "/groups/{id}", ID: "updateGroup", Tag: "groups", Summary: "Replace a group", Request: models.Group{}, Response: models.Group{}, Conditional: true},
+	{Method: "DELETE", Path: "/groups/{id}", ID: "deleteGroup", Tag: "groups", Summary: "Delete a group", Conditional: true},
+	{Method: "GET", Path: "/groups/{id}/summary", ID: "getGroupSummary", Tag: "groups", Summary: "Get a group summary", Response: models.GroupSummary{}},
+	{Method: "POST", Path: "/groups/{id}/targets", ID: "addGroupTargets", Tag: "groups", Summary: "Add or update targets in a group", Request: []models.Target{}, Response: models.BulkTargetResult{}, Content: contentNDJSON},
+	{Method: "PUT", Path: "/groups/{id}/targets", ID: "updateGroupTargets", Tag: "groups", Summary: "Update the targets in a group", Request: []models.Target{}, Response: models.BulkTargetResult{}, Content: contentNDJSON},
+	{Method: "DELETE", Path: "/groups/{id}/targets", ID: "removeGroupTargets", Tag: "groups", Summary: "Remove targets from a group", Request: []models.Target{}, Response: models.BulkTargetResult{}, Content: contentNDJSON},
+	{Method: "POST", Path: "/targets/status", ID: "setTargetStatus", Tag: "groups", Summary: "Set the status of targets in every group, so that targets on leave or who have exited are skipped", Request: models.TargetStatusRequest{}, Response: models.BulkTargetResult{}},
+	{Method: "GET", Path: "/honeytokens/", ID: "listHoneytokens", Tag: "honeytokens", Summary: "List the honeytoken credentials planted in campaigns, along with their sightings", Response: []models.Honeytoken{},
+		Query: []openapi.Parameter{
+			{Name: "campaign_id", In: "query", Description: "Only return this campaign's honeytoken", Schema: &openapi.Schema{Type: "integer"}},
+		}},
+	{Method: "POST", Path: "/honeytokens/sightings", ID: "reportHoneytokenSighting", Tag: "honeytokens", Summary: "Report a honeytoken seen by an external system, notifying the webhooks", Request: models.HoneytokenReport{}, Response: models.HoneytokenSighting{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/templates/", ID: "listTemplates", Tag: "templates", Summary: "List templates", Response: []models.Template{}, List: true},
+	{Method: "POST", Path: "/templates/", ID: "createTemplate", Tag: "templates", Summary: "Create a template", Request: models.Template{}, Response: models.Template{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/templates/", ID: "upsertTemplate", Tag: "templates", Summary: "Create or update the template with the given external id or name", Request: models.Template{}, Response: models.Template{}, Upsert: true},
+	{Method: "DELETE", Path: "/templates/", ID: "deleteTemplates", Tag: "templates", Summary: "Delete the templates matching a filter", Query: []openapi.Parameter{filterParameter}},
+	{Method: "GET", Path: "/templates/{id}", ID: "getTemplate", Tag: "templates", Summary: "Get a template", Response: models.Template{}, Conditional: true},
+	{Method: "PUT", Path: "/templates/{id}", ID: "updateTemplate", Tag: "templates", Summary: "Update a template", Request: models.Template{}, Response: models.Template{}, Conditional: true},
+	{Method: "DELETE", Path: "/templates/{id}", ID: "deleteTemplate", Tag: "templates", Summary: "Delete a template", Conditional: true},
+	{Method: "GET", Path: "/templates/{id}/images", ID: "listTemplateImages", Tag: "templates", Summary: "List the images embedded in a template's emails", Response: []models.InlineImage{}},
+	{Method: "POST", Path: "/templates/{id}/images", ID: "uploadTemplateImages", Tag: "templates", Summary: "Upload images to embed in a template's emails, referenced from the HTML by their CID", Request: inlineImageRequest{}, Response: []models.InlineImage{}, Status: http.StatusCreated, Content: contentMultipart},
+	{Method: "POST", Path: "/templates/{id}/preview", ID: "previewTemplate", Tag: "templates", Summary: "Render a template for a sample recipient without sending it", Request: models.SampleEmailRequest{}, Response: models.EmailPreview{}, Query: []openapi.Parameter{previewFormatParameter}},
+	{Method: "POST", Path: "/templates/{id}/score", ID: "scoreTemplate", Tag: "templates", Summary: "Score a template for a sample recipient using the configured spam filter", Request: models.SampleEmailRequest{}, Response: spam.Result{}},
+	{Method: "GET", Path: "/notification_rules/", ID: "listNotificationRules", Tag: "notifications", Summary: "List the rules which notify you of campaign events, error rates, and completions", Response: []models.NotificationRule{}},
+	{Method: "POST", Path: "/notification_rules/", ID: "createNotificationRule", Tag: "notifications", Summary: "Create a notification rule", Request: models.NotificationRule{}, Response: models.NotificationRule{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/notification_rules/{id}", ID: "getNotificationRule", Tag: "notifications", Summary: "Get a notification rule", Response: models.NotificationRule{}},
+	{Method: "PUT", Path: "/notification_rules/{id}", ID: "updateNotificationRule", Tag: "notifications", Summary: "Update a notification rule", Request: models.NotificationRule{}, Response: models.NotificationRule{}},
+	{Method: "DELETE", Path: "/notification_rules/{id}", ID: "deleteNotificationRule", Tag: "notifications", Summary: "Delete a notification rule"},
+	{Method: "GET", Path: "/ticket_connectors/", ID: "listTicketConnectors", Tag: "notifications", Summary: "List the connectors which open Jira or ServiceNow tickets for campaign events", Response: []models.TicketConnector{}},
+	{Method: "POST", Path: "/ticket_connectors/", ID: "createTicketConnector", Tag: "notifications", Summary: "Create a ticket connector", Request: models.TicketConnector{}, Response: models.TicketConnector{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/ticket_connectors/{id}", ID: "getTicketConnector", Tag: "notifications", Summary: "Get a ticket connector", Response: models.TicketConnector{}},
+	{Method: "PUT", Path: "/ticket_connectors/{id}", ID: "updateTicketConnector", Tag: "notifications", Summary: "Update a ticket connector", Request: models.TicketConnector{}, Response: models.TicketConnector{}},
+	{Method: "DELETE", Path: "/ticket_connectors/{id}", ID: "deleteTicketConnector", Tag: "notifications", Summary: "Delete a ticket connector"},
+	{Method: "GET", Path: "/ticket_connectors/{id}/tickets", ID: "listConnectorTickets", Tag: "notifications", Summary: "List the tickets opened by a ticket connector", Response: []models.TicketLog{}},
+	{Method: "GET", Path: "/org/", ID: "getOrgChart", Tag: "org", Summary: "Get the org chart", Response: []models.OrgMember{}},
+	{Method: "PUT", Path: "/org/", ID: "replaceOrgChart", Tag: "org", Summary: "Replace the org chart", Request: []models.OrgMember{}, Response: []models.OrgMember{}},
+	{Method: "GET", Path: "/org/rollup/departments", ID: "getDepartmentRollup", Tag: "org", Summary: "Get campaign results by department", Response: []models.OrgRollup{}, Query: []openapi.Parameter{campaignIdsParameter}},
+	{Method: "GET", Path: "/org/rollup/managers", ID: "getManagerRollup", Tag: "org", Summary: "Get campaign results by manager, including their whole reporting chain", Response: []models.OrgRollup{}, Query: []openapi.Parameter{campaignIdsParameter}},
+	{Method: "GET", Path: "/org/rollup/tags", ID: "getTagRollup", Tag: "org", Summary: "Get campaign results by the tags recipients had when they were sent each campaign", Response: []models.OrgRollup{}, Query: []openapi.Parameter{campaignIdsParameter}},
+	{Method: "GET", Path: "/pages/", ID: "listPages", Tag: "pages", Summary: "List landing pages", Response: []models.Page{}, List: true},
+	{Method: "POST", Path: "/pages/", ID: "createPage", Tag: "pages", Summary: "Create a landing page", Request: models.Page{}, Response: models.Page{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/pages/", ID: "upsertPage", Tag: "pages", Summary: "Create or update the landing page with the given external id or name", Request: models.Page{}, Response: models.Page{}, Upsert: true},
+	{Method: "GET", Path: "/pages/{id}", ID: "getPage", Tag: "pages", Summary: "Get a landing page", Response: models.Page{}, Conditional: true},
+	{Method: "PUT", Path: "/pages/{id}", ID: "updatePage", Tag: "pages", Summary: "Update a landing page", Request: models.Page{}, Response: models.Page{}, Conditional: true},
+	{Method: "DELETE", Path: "/pages/{id}", ID: "deletePage", Tag: "pages", Summary: "Delete a landing page", Conditional: true},
+	{Method: "GET", Path: "/smtp/", ID: "listSendingProfiles", Tag: "sending profiles", Summary: "List sending profiles", Response: []models.SMTP{}, List: true},
+	{Method: "POST", Path: "/smtp/", ID: "createSendingProfile", Tag: "sending profiles", Summary: "Create a sending profile", Request: models.SMTP{}, Response: models.SMTP{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/smtp/", ID: "upsertSendingProfile", Tag: "sending profiles", Summary: "Create or update the sending profile with the given external id or name", Request: models.SMTP{}, Response: models.SMTP{}, Upsert: true},
+	{Method: "GET", Path: "/smtp/health", ID: "listSendingProfileHealth", Tag: "sending profiles", Summary: "Get the health of each sending profile", Response: []models.SMTPHealth{}},
+	{Method: "GET", Path: "/smtp/{id}", ID: "getSendingProfile", Tag: "sending profiles", Summary: "Get a sending profile", Response: models.SMTP{}, Conditional: true},
+	{Method: "PUT", Path: "/smtp/{id}", ID: "updateSendingProfile", Tag: "sending profiles", Summary: "Update a sending profile", Request: models.SMTP{}, Response: models.SMTP{}, Conditional: true},
+	{Method: "DELETE", Path: "/smtp/{id}", ID: "deleteSendingProfile", Tag: "sending profiles", Summary: "Delete a sending profile", Conditional: true},
+	{Method: "POST", Path: "/smtp/{id}/preflight", ID: "preflightSendingProfile", Tag: "sending profiles", Summary: "Check SPF, DKIM, DMARC, reverse DNS, and blocklists for a sending profile, optionally sending a probe email", Request: models.PreflightRequest{}, Response: deliverability.Report{}},
+	{Method: "GET", Path: "/smtp/{id}/health", ID: "getSendingProfileHealth", Tag: "sending profiles", Summary: "Get the health of a sending profile", Response: models.SMTPHealth{}},
+	{Method: "POST", Path: "/smtp/{id}/health", ID: "checkSendingProfileHealth", Tag: "sending profiles", Summary: "Check the health of a sending profile, resuming its emails if its circuit breaker has tripped and the check succeeds", Response: models.SMTPHealth{}},
+	{Method: "GET", Path: "/imap/", ID: "getIMAP", Tag: "imap", Summary: "Get the IMAP settings used for reporting", Response: []models.IMAP{}},
+	{Method: "POST", Path: "/imap/", ID: "updateIMAP", Tag: "imap", Summary: "Update the IMAP settings used for reporting", Request: models.IMAP{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/imap/validate", ID: "validateIMAP", Tag: "imap", Summary: "Test logging in with IMAP settings", Request: models.IMAP{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/imap/oauth2/device", ID: "getIMAPDeviceAuthorization", Tag: "imap", Summary: "Get whether the mailbox has been authorized using the OAuth2 device code flow", Response: imap.DeviceAuthorizationStatus{}},
+	{Method: "POST", Path: "/imap/oauth2/device", ID: "startIMAPDeviceAuthorization", Tag: "imap", Summary: "Start authorizing the mailbox using the OAuth2 device code flow", Response: imap.DeviceAuthorization{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/incident_connectors/", ID: "listIncidentConnectors", Tag: "imap", Summary: "List the connectors which push reported emails to TheHive or MISP", Response: []models.IncidentConnector{}},
+	{Method: "POST", Path: "/incident_connectors/", ID: "createIncidentConnector", Tag: "imap", Summary: "Create an incident connector", Request: models.IncidentConnector{}, Response: models.IncidentConnector{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/incident_connectors/{id}", ID: "getIncidentConnector", Tag: "imap", Summary: "Get an incident connector", Response: models.IncidentConnector{}},
+	{Method: "PUT", Path: "/incident_connectors/{id}", ID: "updateIncidentConnector", Tag: "imap", Summary: "Update an incident connector", Request: models.IncidentConnector{}, Response: models.IncidentConnector{}},
+	{Method: "DELETE", Path: "/incident_connectors/{id}", ID: "deleteIncidentConnector", Tag: "imap", Summary: "Delete an incident connector"},
+	{Method: "GET", Path: "/reported_messages/", ID: "listReportedMessages", Tag: "imap", Summary: "List the emails reported to the monitored mailbox which weren't sent by a campaign", Response: []models.ReportedMessage{},
+		Query: []openapi.Parameter{
+			{Name: "status", In: "query", Description: "Only list the messages with this triage status", Schema: &openapi.Schema{Type: "string", Enum: []string{models.ReportStatusNew, models.ReportStatusConfirmed, models.ReportStatusFalsePositive}}},
+			{Name: "classification", In: "query", Description: "Only list the messages with this classification", Schema: &openapi.Schema{Type: "string", Enum: []string{models.ReportClassLikelyPhish, models.ReportClassSuspicious, models.ReportClassLowRisk}}},
+		}},
+	{Method: "GET", Path: "/reported_messages/{id}", ID: "getReportedMessage", Tag: "imap", Summary: "Get a reported message, with its headers, observables, and classification", Response: models.ReportedMessage{}},
+	{Method: "PUT", Path: "/reported_messages/{id}", ID: "triageReportedMessage", Tag: "imap", Summary: "Set the triage status and notes of a reported message", Request: models.ReportedMessageTriage{}, Response: models.ReportedMessage{}},
+	{Method: "DELETE", Path: "/reported_messages/{id}", ID: "deleteReportedMessage", Tag: "imap", Summary: "Delete a reported message"},
+	{Method: "POST", Path: "/reset", ID: "resetAPIKey", Tag: "users", Summary: "Reset the current user's API key"},
+	{Method: "GET", Path: "/users/", ID: "listUsers", Tag: "users", Summary: "List users", Response: []models.User{}, Admin: true},
+	{Method: "POST", Path: "/users/", ID: "createUser", Tag: "users", Summary: "Create a user", Request: userRequest{}, Response: models.User{}, Admin: true},
+	{Method: "GET", Path: "/users/{id}", ID: "getUser", Tag: "users", Summary: "Get a user", Response: models.User{}},
+	{Method: "PUT", Path: "/users/{id}", ID: "updateUser", Tag: "users", Summary: "Update a user", Request: userRequest{}, Response: models.User{}},
+	{Method: "DELETE", Path: "/users/{id}", ID: "deleteUser", Tag: "users", Summary: "Delete a user"},
+	{Method: "GET", Path: "/users/{id}/sessions", ID: "listUserSessions", Tag: "users", Summary: "List a user's active admin sessions", Response: []models.AdminSession{}},
+	{Method: "DELETE", Path: "/users/{id}/sessions/{sid}", ID: "revokeUserSession", Tag: "users", Summary: "Revoke one of a user's admin sessions"},
+	{Method: "POST", Path: "/util/send_test_email", ID: "sendTestEmail", Tag: "utilities", Summary: "Send a test email", Request: models.EmailRequest{}},
+	{Method: "POST", Path: "/import/group", ID: "importGroup", Tag: "utilities", Summary: "Parse targets from a CSV file", Request: importGroupRequest{}, Response: []models.Target{}, Content: contentMultipart},
+	{Method: "POST", Path: "/import/org", ID: "importOrgChart", Tag: "utilities", Summary: "Parse org chart members from a CSV file", Request: importGroupRequest{}, Response: []models.OrgMember{}, Content: contentMultipart},
+	{Method: "POST", Path: "/import/email", ID: "importEmail", Tag: "utilities", Summary: "Parse a template from a raw email", Request: importEmailRequest{}, Response: emailResponse{}},
+	{Method: "POST", Path: "/import/site", ID: "importSite", Tag: "utilities", Summary: "Clone a landing page from a URL", Request: cloneRequest{}, Response: cloneResponse{}},
+	{Method: "GET", Path: "/webhooks/", ID: "listWebhooks", Tag: "webhooks", Summary: "List webhooks", Response: []models.Webhook{}, Admin: true},
+	{Method: "POST", Path: "/webhooks/", ID: "createWebhook", Tag: "webhooks", Summary: "Create a webhook", Request: models.Webhook{}, Response: models.Webhook{}, Status: http.StatusCreated, Admin: true},
+	{Method: "GET", Path: "/webhooks/{id}", ID: "getWebhook", Tag: "webhooks", Summary: "Get a webhook", Response: models.Webhook{}, Admin: true},
+	{Method: "PUT", Path: "/webhooks/{id}", ID: "updateWebhook", Tag: "webhooks", Summary: "Update a webhook", Request: models.Webhook{}, Response: models.Webhook{}, Admin: true},
+	{Method: "DELETE", Path: "/webhooks/{id}", ID: "deleteWebhook", Tag: "webhooks", Summary: "Delete a webhook", Admin: true},
+	{Method: "POST", Path: "/webhooks/{id}/validate", ID: "validateWebhook", Tag: "webhooks", Summary: "Send a test event to a webhook", Response: models.Webhook{}, Admin: true},
+	{Method: "POST", Path: "/webhooks/{id}/rotate", ID: "rotateWebhookSecret", Tag: "webhooks", Summary: "Rotate the secret of a webhook, keeping the current secret for an overlap period", Request: models.WebhookRotation{}, Response: models.Webhook{}, Admin: true},
+}
+
+// pathParameter matches the parameters in a route's path template
+var pathParameter = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+// specPath returns the OpenAPI path for a route's path template, removing the
+// patterns from the parameters and the /api prefix.
+func specPath(route string) string {
+	return pathParameter.ReplaceAllString(strings.TrimPrefix(route, "/api"), "{$1}")
+}
+
+// buildSpec builds the OpenAPI specification for the API.
+func buildSpec() *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:       "Gophish API",
+		Description: "The API used to manage Gophish campaigns, and everything needed to run them.",
+		Version:     config.Version,
+	})
+	d.Servers = []openapi.Server{{URL: "/api"}}
+	d.Components.SecuritySchemes["bearer"] = &openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "The API key, sent in the Authorization header",
+	}
+	d.Components.SecuritySchemes["api_key"] = &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "query",
+		Name:        "api_key",
+		Description: "The API key, sent as a query parameter",
+	}
+	d.Security = []openapi.SecurityRequirement{{"bearer": {}}, {"api_key": {}}}
+	d.Components.SecuritySchemes["embed_token"] = &openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "A read-only embed token, sent in the Authorization header or as the token query parameter",
+	}
+	errorResponse := d.SchemaOf(models.Response{})
+	errorContent := map[string]openapi.MediaType{"application/json": {Schema: errorResponse}}
+	tags := map[string]bool{}
+	for _, so := range specOperations {
+		op := &openapi.Operation{
+			OperationID: so.ID,
+			Summary:     so.Summary,
+			Tags:        []string{so.Tag},
+			Parameters:  []openapi.Parameter{},
+			Responses: map[string]*openapi.Response{
+				"400": {Description: "The request was invalid", Content: errorContent},
+				"401": {Description: "The API key is missing or invalid", Content: errorContent},
+			},
+		}
+		if !tags[so.Tag] {
+			tags[so.Tag] = true
+			d.Tags = append(d.Tags, openapi.Tag{Name: so.Tag})
+		}
+		if so.Embed {
+			op.Description = "Requires an embed token rather than an API key."
+			op.Security = &[]openapi.SecurityRequirement{{"embed_token": {}}}
+			op.Responses["401"] = &openapi.Response{Description: "The embed token is missing or invalid", Content: errorContent}
+		}
+		if so.Admin {
+			op.Description = "Requires the modify_system permission."
+			op.Responses["403"] = &openapi.Response{Description: "The user doesn't have permission", Content: errorContent}
+		}
+		for _, m := range pathParameter.FindAllStringSubmatch(so.Path, -1) {
+			ps := &openapi.Schema{Type: "integer", Format: "int64"}
+			// Result ids are strings
+			if m[1] == "rid" {
+				ps = &openapi.Schema{Type: "string"}
+			}
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   ps,
+			})
+			op.Responses["404"] = &openapi.Response{Description: "Not found", Content: errorContent}
+		}
+		op.Parameters = append(op.Parameters, so.Query...)
+		op.Parameters = append(op.Parameters, schemaVersionParameter)
+		op.Responses["406"] = &openapi.Response{Description: "The schema version isn't supported", Content: errorContent}
+		if so.List {
+			op.Parameters = append(op.Parameters, listParameters...)
+		}
+		if so.Conditional || so.Upsert {
+			op.Parameters = append(op.Parameters, conditionalParameters...)
+			op.Responses["412"] = &openapi.Response{Description: "A precondition failed", Content: errorContent}
+		}
+		if so.Upsert {
+			op.Responses["409"] = &openapi.Response{Description: "The name or external id is used by another item", Content: errorContent}
+		}
+		if so.Request != nil {
+			op.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]openapi.MediaType{},
+			}
+			switch so.Content {
+			case contentMultipart:
+				op.RequestBody.Content[contentMultipart] = openapi.MediaType{Schema: multipartSchema(d, so.Request)}
+			case contentNDJSON:
+				op.RequestBody.Content["application/json"] = openapi.MediaType{Schema: d.SchemaOf(so.Request)}
+				// Each line of the stream is a single item
+				op.RequestBody.Content[contentNDJSON] = openapi.MediaType{Schema: d.SchemaOf(so.Request).Items}
+			default:
+				op.RequestBody.Content["application/json"] = openapi.MediaType{Schema: d.SchemaOf(so.Request)}
+			}
+		}
+		status := so.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := so.Response
+		if response == nil {
+			response = models.Response{}
+		}
+		ok := &openapi.Response{Description: http.StatusText(status)}
+		if so.Content == contentEventStream {
+			ok.Description = "A stream of events. The data of each event is the JSON encoded event."
+			ok.Content = map[string]openapi.MediaType{contentEventStream: {Schema: &openapi.Schema{Type: "string"}}}
+			d.SchemaOf(response)
+		} else if so.Content == contentBinary {
+			ok.Content = map[string]openapi.MediaType{contentBinary: {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}
+		} else if so.Content == contentCSV {
+			// Exports are CSV files or JSON arrays, depending on the format
+			ok.Content = map[string]openapi.MediaType{
+				contentCSV:         {Schema: &openapi.Schema{Type: "string"}},
+				"application/json": {Schema: d.SchemaOf(response)},
+			}
+		} else {
+			ok.Content = map[string]openapi.MediaType{"application/json": {Schema: d.SchemaOf(response)}}
+		}
+		if so.List {
+			ok.Headers = map[string]openapi.Header{
+				TotalCountHeader: {Description: "The number of items matching the filters", Schema: &openapi.Schema{Type: "integer"}},
+				NextCursorHeader: {Description: "The cursor used to request the next page", Schema: &openapi.Schema{Type: "string"}},
+			}
+		}
+		if (so.Conditional || so.Upsert) && so.Method != "DELETE" {
+			ok.Headers = map[string]openapi.Header{
+				ETagHeader: {Description: "The entity tag of the item", Schema: &openapi.Schema{Type: "string"}},
+			}
+		}
+		op.Responses[fmt.Sprintf("%d", status)] = ok
+		if so.Upsert {
+			op.Responses[fmt.Sprintf("%d", http.StatusCreated)] = &openapi.Response{Description: "The item was created", Headers: ok.Headers, Content: ok.Content}
+		}
+		d.AddOperation(so.Method, so.Path, op)
+	}
+	return d
+}
+
+// multipartSchema describes a form upload using the fields of the request.
+// Byte slices are uploaded as files rather than base64 encoded strings.
+func multipartSchema(d *openapi.Document, v interface{}) *openapi.Schema {
+	s := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Uint8 {
+			s.Properties[name] = &openapi.Schema{Type: "string", Format: "binary"}
+			continue
+		}
+		s.Properties[name] = d.SchemaOf(reflect.Zero(f.Type).Interface())
+	}
+	return s
+}
+
+// Spec returns the OpenAPI specification describing the API, which can be
+// used to generate API clients.
+func (as *Server) Spec(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		as.specOnce.Do(func() {
+			as.spec = buildSpec()
+		})
+		JSONResponse(w, as.spec, http.StatusOK)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gophish/gophish/openapi"
+	"github.com/gorilla/mux"
+)
+
+func TestSpecDocumentsAllRoutes(t *testing.T) {
+	testCtx := setupTest(t)
+	router := testCtx.apiServer.handler.(*mux.Router)
+	routes := map[string]bool{}
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || tpl == "/api/" || tpl == "/api/spec" {
+			return nil
+		}
+		routes[specPath(tpl)] = true
+		return nil
+	})
+	spec := buildSpec()
+	for path := range routes {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Fatalf("route %s is not documented in the spec", path)
+		}
+	}
+	for path := range spec.Paths {
+		if !routes[path] {
+			t.Fatalf("documented path %s is not a registered route", path)
+		}
+	}
+}
+
+// findRefs returns every schema reference in the decoded document.
+func findRefs(v interface{}) []string {
+	refs := []string{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if ref, ok := child.(string); ok && k == "$ref" {
+				refs = append(refs, ref)
+				continue
+			}
+			refs = append(refs, findRefs(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			refs = append(refs, findRefs(child)...)
+		}
+	}
+	return refs
+}
+
+func TestSpec(t *testing.T) {
+	testCtx := setupTest(t)
+	// The spec is served without an API key
+	r := httptest.NewRequest(http.MethodGet, "/api/spec", nil)
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.Bytes()
+	spec := openapi.Document{}
+	err := json.Unmarshal(body, &spec)
+	if err != nil {
+		t.Fatalf("error decoding spec: %v", err)
+	}
+	if spec.OpenAPI != openapi.Version {
+		t.Fatalf("unexpected openapi version. expected %s got %s", openapi.Version, spec.OpenAPI)
+	}
+	ids := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			if ids[op.OperationID] {
+				t.Fatalf("duplicate operation id %s for %s %s", op.OperationID, method, path)
+			}
+			ids[op.OperationID] = true
+		}
+	}
+	campaign, ok := spec.Components.Schemas["Campaign"]
+	if !ok {
+		t.Fatalf("campaign schema not found")
+	}
+	if _, ok := campaign.Properties["launch_date"]; !ok {
+		t.Fatalf("campaign schema is missing the launch_date property")
+	}
+	// Every reference should point to a schema in the document
+	raw := map[string]interface{}{}
+	json.Unmarshal(body, &raw)
+	for _, ref := range findRefs(raw) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Fatalf("schema %s is referenced but not defined", name)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/jinzhu/gorm"
+)
+
+// EventStreamKeepAlive is how often a comment is sent on an idle event stream
+// so that proxies don't close the connection.
+var EventStreamKeepAlive = 15 * time.Second
+
+// EventStream streams campaign events to the client as they happen using
+// Server-Sent Events. The stream can be limited to a single campaign using
+// the campaign_id parameter.
+//
+// Each event's id is sent with the event, so clients which reconnect with the
+// Last-Event-ID header (or the last_event_id parameter) receive any events
+// they missed while disconnected.
+func (as *Server) EventStream(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		uid := ctx.Get(r, "user_id").(int64)
+		var cid int64
+		var err error
+		if v := r.URL.Query().Get("campaign_id"); v != "" {
+			cid, err = strconv.ParseInt(v, 0, 64)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid campaign_id"}, http.StatusBadRequest)
+				return
+			}
+			_, err = models.GetCampaignSummary(cid, uid)
+			if err == gorm.ErrRecordNotFound {
+				JSONResponse(w, models.Response{Success: false, Message: "Campaign not found"}, http.StatusNotFound)
+				return
+			} else if err != nil {
+				log.FromContext(r.Context()).Error(err)
+				JSONResponse(w, models.Response{Success: false, Message: "Error fetching campaign"}, http.StatusInternalServerError)
+				return
+			}
+		}
+		lastId := r.Header.Get("Last-Event-ID")
+		if lastId == "" {
+			lastId = r.URL.Query().Get("last_event_id")
+		}
+		var since int64
+		if lastId != "" {
+			since, err = strconv.ParseInt(lastId, 0, 64)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid last event id"}, http.StatusBadRequest)
+				return
+			}
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			JSONResponse(w, models.Response{Success: false, Message: "Streaming is not supported"}, http.StatusInternalServerError)
+			return
+		}
+		// Subscribe before catching up on missed events so that none are
+		// lost in between.
+		sub := models.SubscribeEvents(uid, cid)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		// The admin server compresses responses, buffering them until enough
+		// has been written. Setting the encoding skips the compression so
+		// that each event is flushed to the client immediately.
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+
+		if since > 0 {
+			es, err := models.GetEventsSince(uid, cid, since)
+			if err != nil {
+				log.FromContext(r.Context()).Error(err)
+				return
+			}
+			for _, e := range es {
+				err = writeStreamEvent(w, e)
+				if err != nil {
+					return
+				}
+				since = e.Id
+			}
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(EventStreamKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				// The subscription is closed if the client falls behind. The
+				// client will reconnect and catch up using the last event id.
+				if !ok {
+					return
+				}
+				if e.Id <= since {
+					continue
+				}
+				err = writeStreamEvent(w, e)
+				if err != nil {
+					return
+				}
+				since = e.Id
+			case <-ticker.C:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+				if err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes the event to the stream.
+func writeStreamEvent(w http.ResponseWriter, e models.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.Id, data)
+	return err
+}
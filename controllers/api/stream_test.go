@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophish/gophish/models"
+)
+
+// readStreamEvent reads lines from the stream until an event is received,
+// returning its id and data.
+func readStreamEvent(t *testing.T, r *bufio.Reader) (string, models.Event) {
+	id := ""
+	e := models.Event{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e)
+			if err != nil {
+				t.Fatalf("error decoding event: %v", err)
+			}
+		case line == "" && id != "":
+			return id, e
+		}
+	}
+}
+
+func openEventStream(t *testing.T, testCtx *testContext, url string, lastId string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	if lastId != "" {
+		req.Header.Set("Last-Event-ID", lastId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error opening event stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	return resp
+}
+
+func TestEventStream(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+	ts := httptest.NewServer(testCtx.apiServer)
+	defer ts.Close()
+
+	resp := openEventStream(t, testCtx, ts.URL+"/api/events/stream?campaign_id=1", "")
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type received. expected %s got %s", "text/event-stream", ct)
+	}
+
+	c, err := models.GetCampaign(1, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	result := c.Results[0]
+	go func() {
+		// Give the stream a moment to be ready before adding the event
+		time.Sleep(50 * time.Millisecond)
+		result.HandleClickedLink(models.EventDetails{})
+	}()
+	id, e := readStreamEvent(t, bufio.NewReader(resp.Body))
+	if e.Message != models.EventClicked {
+		t.Fatalf("unexpected event received. expected %s got %s", models.EventClicked, e.Message)
+	}
+	if e.Email != result.Email {
+		t.Fatalf("unexpected email received. expected %s got %s", result.Email, e.Email)
+	}
+
+	// Reconnecting with the last event id should replay the events which
+	// were added after it.
+	result.HandleFormSubmit(models.EventDetails{})
+	replay := openEventStream(t, testCtx, ts.URL+"/api/events/stream", id)
+	defer replay.Body.Close()
+	_, e = readStreamEvent(t, bufio.NewReader(replay.Body))
+	if e.Message != models.EventDataSubmit {
+		t.Fatalf("unexpected event received. expected %s got %s", models.EventDataSubmit, e.Message)
+	}
+}
+
+func TestEventStreamCampaignNotFound(t *testing.T) {
+	testCtx := setupTest(t)
+	r := httptest.NewRequest(http.MethodGet, "/api/events/stream?campaign_id=100", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
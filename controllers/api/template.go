@@ -1,27 +1,68 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"time"
 
 	ctx "github.com/gophish/gophish/context"
 	log "github.com/gophish/gophish/logger"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/spam"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 )
 
+// MaxInlineImageUpload is the largest upload of inline images accepted for a
+// template.
+const MaxInlineImageUpload = 10 << 20
+
 // Templates handles the functionality for the /api/templates endpoint
+//
+// A PUT to this endpoint creates or updates the template with the given
+// external id, or the given name if it doesn't exist. This lets tools manage
+// templates declaratively without tracking their ids.
 func (as *Server) Templates(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == "GET":
-		ts, err := models.GetTemplates(ctx.Get(r, "user_id").(int64))
+		opts, err := listOptions(r)
 		if err != nil {
-			log.Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
 		}
+		ts, page, err := models.ListTemplates(ctx.Get(r, "user_id").(int64), opts)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+		setPageHeaders(w, r, page)
 		JSONResponse(w, ts, http.StatusOK)
+	// DELETE: Delete the templates matching the filters
+	case r.Method == "DELETE":
+		opts, err := listOptions(r)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		n, err := models.DeleteTemplates(ctx.Get(r, "user_id").(int64), opts.Filters)
+		if isListOptionsError(err) {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting templates"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Templates deleted successfully!", Data: bulkDeleteResult{Deleted: n}}, http.StatusOK)
 	//POST: Create a new template and return it as JSON
 	case r.Method == "POST":
 		t := models.Template{}
@@ -47,12 +88,58 @@ func (as *Server) Templates(w http.ResponseWriter, r *http.Request) {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
+		if err == models.ErrExternalIdInUse || err == models.ErrExternalIdTooLong {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: "Error inserting template into database"}, http.StatusInternalServerError)
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			return
 		}
 		JSONResponse(w, t, http.StatusCreated)
+	// PUT: Create or update the template with the given external id or name
+	case r.Method == "PUT":
+		t := models.Template{}
+		err := json.NewDecoder(r.Body).Decode(&t)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		uid := ctx.Get(r, "user_id").(int64)
+		existing, err := models.GetTemplateForUpsert(t, uid)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		var current interface{}
+		if err == nil {
+			current = existing
+		}
+		if !checkPreconditions(w, r, current) {
+			return
+		}
+		t.Id = existing.Id
+		t.ModifiedDate = time.Now().UTC()
+		t.UserId = uid
+		status := http.StatusOK
+		if current == nil {
+			status = http.StatusCreated
+			err = models.PostTemplate(&t)
+		} else {
+			err = models.PutTemplate(&t)
+		}
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		t, err = models.GetTemplate(t.Id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading template"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, t, status)
 	}
 }
 
@@ -65,9 +152,12 @@ func (as *Server) Template(w http.ResponseWriter, r *http.Request) {
 		JSONResponse(w, models.Response{Success: false, Message: "Template not found"}, http.StatusNotFound)
 		return
 	}
+	if !checkPreconditions(w, r, t) {
+		return
+	}
 	switch {
 	case r.Method == "GET":
-		JSONResponse(w, t, http.StatusOK)
+		etagResponse(w, t, http.StatusOK)
 	case r.Method == "DELETE":
 		err = models.DeleteTemplate(id, ctx.Get(r, "user_id").(int64))
 		if err != nil {
@@ -76,22 +166,179 @@ func (as *Server) Template(w http.ResponseWriter, r *http.Request) {
 		}
 		JSONResponse(w, models.Response{Success: true, Message: "Template deleted successfully!"}, http.StatusOK)
 	case r.Method == "PUT":
+		externalId := t.ExternalId
 		t = models.Template{}
 		err = json.NewDecoder(r.Body).Decode(&t)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		if t.Id != id {
 			JSONResponse(w, models.Response{Success: false, Message: "Error: /:id and template_id mismatch"}, http.StatusBadRequest)
 			return
 		}
+		// Updates which don't include the external id, such as those made
+		// from the admin UI, keep the item under management
+		if t.ExternalId == "" {
+			t.ExternalId = externalId
+		}
 		t.ModifiedDate = time.Now().UTC()
 		t.UserId = ctx.Get(r, "user_id").(int64)
 		err = models.PutTemplate(&t)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, upsertErrorStatus(err))
+			return
+		}
+		t, err = models.GetTemplate(id, t.UserId)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error loading template"}, http.StatusInternalServerError)
+			return
+		}
+		etagResponse(w, t, http.StatusOK)
+	}
+}
+
+// TemplateScore renders the template for a sample recipient and submits it to
+// the configured spam filter, returning the score and the rules it matched.
+func (as *Server) TemplateScore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	t, err := models.GetTemplate(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Template not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "POST":
+		if as.scorer == nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Spam scoring isn't configured"}, http.StatusNotImplemented)
+			return
+		}
+		req := models.SampleEmailRequest{}
+		// The request body is optional, since every field has a default.
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		msg, err := models.RenderSampleEmail(t, req, uid)
 		if err != nil {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
-		JSONResponse(w, t, http.StatusOK)
+		c, cancel := context.WithTimeout(r.Context(), spam.DefaultTimeout)
+		defer cancel()
+		result, err := as.scorer.Score(c, msg)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error scoring template: " + err.Error()}, http.StatusBadGateway)
+			return
+		}
+		JSONResponse(w, result, http.StatusOK)
+	}
+}
+
+// TemplatePreview renders the template for a sample recipient without sending
+// it. By default the parts of the email are returned as JSON. The format
+// query parameter can be set to "html" to return just the HTML body, such as
+// for taking screenshots, or to "eml" to return the raw email.
+func (as *Server) TemplatePreview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	t, err := models.GetTemplate(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Template not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "POST":
+		req := models.SampleEmailRequest{}
+		// The request body is optional, since every field has a default.
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("format") {
+		case "":
+			p, err := models.PreviewSampleEmail(t, req, uid)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			JSONResponse(w, p, http.StatusOK)
+		case "html":
+			p, err := models.PreviewSampleEmail(t, req, uid)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(p.HTML))
+		case "eml":
+			msg, err := models.RenderSampleEmail(t, req, uid)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "message/rfc822")
+			w.Write(msg)
+		default:
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid format. Expected html or eml"}, http.StatusBadRequest)
+		}
+	}
+}
+
+// TemplateImages handles the inline images of the template. A GET lists them,
+// and a POST uploads the images in the multipart "file" fields, which can
+// then be referenced from the template's HTML using their CID.
+func (as *Server) TemplateImages(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	t, err := models.GetTemplate(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: "Template not found"}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, t.InlineImages(), http.StatusOK)
+	case r.Method == "POST":
+		r.Body = http.MaxBytesReader(w, r.Body, MaxInlineImageUpload)
+		err := r.ParseMultipartForm(MaxInlineImageUpload)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Error parsing the upload"}, http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		files := r.MultipartForm.File["file"]
+		if len(files) == 0 {
+			JSONResponse(w, models.Response{Success: false, Message: "No image provided"}, http.StatusBadRequest)
+			return
+		}
+		images := []models.InlineImage{}
+		for _, fh := range files {
+			f, err := fh.Open()
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Error reading the upload"}, http.StatusBadRequest)
+				return
+			}
+			content, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Error reading the upload"}, http.StatusBadRequest)
+				return
+			}
+			image, err := models.AddInlineImage(id, uid, filepath.Base(fh.Filename), content)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			images = append(images, image)
+		}
+		JSONResponse(w, images, http.StatusCreated)
 	}
 }
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/spam"
+)
+
+// fakeScorer gives every email the same score, keeping the last email it was
+// given.
+type fakeScorer struct {
+	msg []byte
+}
+
+func (f *fakeScorer) Score(ctx context.Context, msg []byte) (*spam.Result, error) {
+	f.msg = msg
+	return &spam.Result{
+		Engine:    "fake",
+		Score:     3.5,
+		Threshold: 5,
+		Rules:     []spam.Rule{{Name: "MISSING_MID", Score: 3.5}},
+	}, nil
+}
+
+func scoreRequest(as *Server, apiKey string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/templates/1/score", bytes.NewBufferString(body))
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	w := httptest.NewRecorder()
+	as.ServeHTTP(w, r)
+	return w
+}
+
+func TestTemplateScore(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+
+	// Scoring isn't available unless a spam filter is configured
+	w := scoreRequest(testCtx.apiServer, testCtx.apiKey, "")
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	scorer := &fakeScorer{}
+	as := NewServer(WithSpamScorer(scorer))
+	w = scoreRequest(as, testCtx.apiKey, "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an error without a from address. got %d", w.Code)
+	}
+
+	w = scoreRequest(as, testCtx.apiKey, `{"smtp_id": 1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	result := spam.Result{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	if err != nil {
+		t.Fatalf("error decoding result: %v", err)
+	}
+	if result.Score != 3.5 || len(result.Rules) != 1 {
+		t.Fatalf("unexpected result received: %+v", result)
+	}
+	if !strings.Contains(string(scorer.msg), "Subject: Test subject") {
+		t.Fatalf("unexpected email scored: %s", scorer.msg)
+	}
+	if !strings.Contains(string(scorer.msg), models.SampleRecipient.Email) {
+		t.Fatalf("expected the email to be sent to the sample recipient: %s", scorer.msg)
+	}
+}
+
+func TestTemplatePreview(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+
+	preview := func(format string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/templates/1/preview?format="+format, bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+
+	w := preview("", `{"smtp_id": 1, "first_name": "Jane", "email": "jane@example.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	p := models.EmailPreview{}
+	err := json.NewDecoder(w.Body).Decode(&p)
+	if err != nil {
+		t.Fatalf("error decoding preview: %v", err)
+	}
+	if p.Subject != "Test subject" || p.HTML != "<html>Test</html>" || p.Text != "Text text" {
+		t.Fatalf("unexpected preview received: %+v", p)
+	}
+	if len(p.To) != 1 || p.To[0] != "jane@example.com" {
+		t.Fatalf("unexpected recipient received: %v", p.To)
+	}
+
+	w = preview("html", `{"smtp_id": 1}`)
+	if w.Code != http.StatusOK || w.Body.String() != "<html>Test</html>" {
+		t.Fatalf("unexpected HTML preview received: %d %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected content type received: %s", ct)
+	}
+
+	w = preview("eml", `{"smtp_id": 1}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Subject: Test subject") {
+		t.Fatalf("unexpected raw preview received: %d %s", w.Code, w.Body.String())
+	}
+
+	w = preview("pdf", `{"smtp_id": 1}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an error for an unknown format. got %d", w.Code)
+	}
+}
+
+func TestTemplateImages(t *testing.T) {
+	testCtx := setupTest(t)
+	createTestData(t)
+
+	png, _ := base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=")
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, _ := mw.CreateFormFile("file", "logo.png")
+	fw.Write(png)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/templates/1/images", body)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status code received. expected %d got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/templates/1/images", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+	w = httptest.NewRecorder()
+	testCtx.apiServer.ServeHTTP(w, r)
+	images := []models.InlineImage{}
+	err := json.NewDecoder(w.Body).Decode(&images)
+	if err != nil {
+		t.Fatalf("error decoding images: %v", err)
+	}
+	expected := []models.InlineImage{{Name: "logo.png", Type: "image/png", CID: "cid:logo.png"}}
+	if !reflect.DeepEqual(images, expected) {
+		t.Fatalf("unexpected images received. expected %v got %v", expected, images)
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gorilla/mux"
+)
+
+// TicketConnectors returns a list of the user's ticket connectors, or creates
+// a new one.
+func (as *Server) TicketConnectors(w http.ResponseWriter, r *http.Request) {
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		tcs, err := models.GetTicketConnectors(uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching ticket connectors"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, tcs, http.StatusOK)
+
+	case r.Method == "POST":
+		tc := models.TicketConnector{}
+		err := json.NewDecoder(r.Body).Decode(&tc)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		tc.UserId = uid
+		err = models.PostTicketConnector(&tc)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, tc, http.StatusCreated)
+	}
+}
+
+// TicketConnector returns, edits, or deletes the ticket connector specified
+// by the "id" parameter.
+func (as *Server) TicketConnector(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	tc, err := models.GetTicketConnector(id, uid)
+	if err != nil {
+		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == "GET":
+		JSONResponse(w, tc, http.StatusOK)
+
+	case r.Method == "DELETE":
+		err = models.DeleteTicketConnector(id, uid)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error deleting ticket connector"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, models.Response{Success: true, Message: "Ticket connector deleted successfully!"}, http.StatusOK)
+
+	case r.Method == "PUT":
+		tc = models.TicketConnector{}
+		err = json.NewDecoder(r.Body).Decode(&tc)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+			return
+		}
+		tc.Id = id
+		tc.UserId = uid
+		err = models.PutTicketConnector(&tc)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, tc, http.StatusOK)
+	}
+}
+
+// TicketConnectorTickets returns the tickets opened by the ticket connector
+// specified by the "id" parameter, along with the errors opening them.
+func (as *Server) TicketConnectorTickets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.ParseInt(vars["id"], 0, 64)
+	uid := ctx.Get(r, "user_id").(int64)
+	switch {
+	case r.Method == "GET":
+		tls, err := models.GetTicketLogs(id, uid)
+		if err == models.ErrTicketConnectorNotFound {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: "Error fetching tickets"}, http.StatusInternalServerError)
+			return
+		}
+		JSONResponse(w, tls, http.StatusOK)
+	}
+}
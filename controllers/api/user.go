@@ -105,7 +105,7 @@ func (as *Server) Users(w http.ResponseWriter, r *http.Request) {
 		user := models.User{
 			Username:               ur.Username,
 			Hash:                   hash,
-			ApiKey:                 auth.GenerateSecureKey(auth.APIKeyLength),
+			ApiKey:                 models.EncryptedString(auth.GenerateSecureKey(auth.APIKeyLength)),
 			Role:                   role,
 			RoleID:                 role.ID,
 			PasswordChangeRequired: ur.PasswordChangeRequired,
@@ -152,19 +152,19 @@ func (as *Server) User(w http.ResponseWriter, r *http.Request) {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
-		log.Infof("Deleted user account for %s", existingUser.Username)
+		log.FromContext(r.Context()).Infof("Deleted user account for %s", existingUser.Username)
 		JSONResponse(w, models.Response{Success: true, Message: "User deleted Successfully!"}, http.StatusOK)
 	case r.Method == "PUT":
 		ur := &userRequest{}
 		err = json.NewDecoder(r.Body).Decode(ur)
 		if err != nil {
-			log.Errorf("error decoding user request: %v", err)
+			log.FromContext(r.Context()).Errorf("error decoding user request: %v", err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
 		err = ur.Validate(&existingUser)
 		if err != nil {
-			log.Errorf("invalid user request received: %v", err)
+			log.FromContext(r.Context()).Errorf("invalid user request received: %v", err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
@@ -208,12 +208,25 @@ func (as *Server) User(w http.ResponseWriter, r *http.Request) {
 				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 				return
 			}
+			err = existingUser.CheckPasswordHistory(ur.Password)
+			if err == auth.ErrPasswordInHistory {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+				return
+			}
 			hash, err := auth.GeneratePasswordHash(ur.Password)
 			if err != nil {
 				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 				return
 			}
-			existingUser.Hash = hash
+			err = existingUser.SetPasswordHash(hash)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+				return
+			}
 		}
 		err = models.PutUser(&existingUser)
 		if err != nil {
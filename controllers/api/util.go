@@ -48,13 +48,13 @@ func (as *Server) SendTestEmail(w http.ResponseWriter, r *http.Request) {
 		// Get the Template requested by name
 		s.Template, err = models.GetTemplateByName(s.Template.Name, s.UserId)
 		if err == gorm.ErrRecordNotFound {
-			log.WithFields(logrus.Fields{
+			log.FromContext(r.Context()).WithFields(logrus.Fields{
 				"template": s.Template.Name,
 			}).Error("Template does not exist")
 			JSONResponse(w, models.Response{Success: false, Message: models.ErrTemplateNotFound.Error()}, http.StatusBadRequest)
 			return
 		} else if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
@@ -67,13 +67,13 @@ func (as *Server) SendTestEmail(w http.ResponseWriter, r *http.Request) {
 	if s.Page.Name != "" {
 		s.Page, err = models.GetPageByName(s.Page.Name, s.UserId)
 		if err == gorm.ErrRecordNotFound {
-			log.WithFields(logrus.Fields{
+			log.FromContext(r.Context()).WithFields(logrus.Fields{
 				"page": s.Page.Name,
 			}).Error("Page does not exist")
 			JSONResponse(w, models.Response{Success: false, Message: models.ErrPageNotFound.Error()}, http.StatusBadRequest)
 			return
 		} else if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
@@ -81,13 +81,14 @@ func (as *Server) SendTestEmail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If a complete sending profile is provided use it
+	s.SMTP.UserId = s.UserId
 	if err := s.SMTP.Validate(); err != nil {
 		// Otherwise get the SMTP requested by name
 		smtp, lookupErr := models.GetSMTPByName(s.SMTP.Name, s.UserId)
 		// If the Sending Profile doesn't exist, let's err on the side
 		// of caution and assume that the validation failure was more important.
 		if lookupErr != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
@@ -105,7 +106,7 @@ func (as *Server) SendTestEmail(w http.ResponseWriter, r *http.Request) {
 	if storeRequest {
 		err = models.PostEmailRequest(s)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
@@ -113,8 +114,8 @@ func (as *Server) SendTestEmail(w http.ResponseWriter, r *http.Request) {
 	// Send the test email
 	err = as.worker.SendTestEmail(s)
 	if err != nil {
-		log.Error(err)
-		JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+		log.FromContext(r.Context()).Error(err)
+		JSONResponse(w, models.Response{Success: false, Message: err.Error(), Data: s.Transcript}, http.StatusInternalServerError)
 		return
 	}
 	JSONResponse(w, models.Response{Success: true, Message: "Email Sent"}, http.StatusOK)
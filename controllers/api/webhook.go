@@ -17,7 +17,7 @@ func (as *Server) Webhooks(w http.ResponseWriter, r *http.Request) {
 	case r.Method == "GET":
 		whs, err := models.GetWebhooks()
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
@@ -58,14 +58,14 @@ func (as *Server) Webhook(w http.ResponseWriter, r *http.Request) {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
-		log.Infof("Deleted webhook with id: %d", id)
+		log.FromContext(r.Context()).Infof("Deleted webhook with id: %d", id)
 		JSONResponse(w, models.Response{Success: true, Message: "Webhook deleted Successfully!"}, http.StatusOK)
 
 	case r.Method == "PUT":
 		wh = models.Webhook{}
 		err = json.NewDecoder(r.Body).Decode(&wh)
 		if err != nil {
-			log.Errorf("error decoding webhook: %v", err)
+			log.FromContext(r.Context()).Errorf("error decoding webhook: %v", err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
@@ -79,27 +79,71 @@ func (as *Server) Webhook(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// validationEvent is the payload sent to validate a webhook
+type validationEvent struct {
+	Success bool `json:"success"`
+}
+
+// WebhookType returns the type of the payload
+func (validationEvent) WebhookType() string {
+	return "validation"
+}
+
 // ValidateWebhook makes an HTTP request to a specified remote url to ensure that it's valid.
 func (as *Server) ValidateWebhook(w http.ResponseWriter, r *http.Request) {
-	type validationEvent struct {
-		Success bool `json:"success"`
-	}
 	switch {
 	case r.Method == "POST":
 		vars := mux.Vars(r)
 		id, _ := strconv.ParseInt(vars["id"], 0, 64)
 		wh, err := models.GetWebhook(id)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
 			return
 		}
 		payload := validationEvent{Success: true}
-		err = webhook.Send(webhook.EndPoint{URL: wh.URL, Secret: wh.Secret}, payload)
+		err = webhook.SendContext(r.Context(), wh.EndPoint(), payload)
+		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		JSONResponse(w, wh, http.StatusOK)
+	}
+}
+
+// RotateWebhookSecret replaces the secret of a webhook. The current secret is
+// still used to sign events for the requested overlap, so that receivers can
+// be updated without rejecting events.
+func (as *Server) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST":
+		vars := mux.Vars(r)
+		id, _ := strconv.ParseInt(vars["id"], 0, 64)
+		_, err := models.GetWebhook(id)
 		if err != nil {
+			JSONResponse(w, models.Response{Success: false, Message: "Webhook not found"}, http.StatusNotFound)
+			return
+		}
+		rotation := models.WebhookRotation{}
+		// An empty body rotates to a generated secret
+		if r.ContentLength != 0 {
+			err = json.NewDecoder(r.Body).Decode(&rotation)
+			if err != nil {
+				JSONResponse(w, models.Response{Success: false, Message: "Invalid JSON structure"}, http.StatusBadRequest)
+				return
+			}
+		}
+		wh, err := models.RotateWebhookSecret(id, rotation)
+		if err == models.ErrInvalidOverlap {
 			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusBadRequest)
 			return
 		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			JSONResponse(w, models.Response{Success: false, Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		log.FromContext(r.Context()).Infof("Rotated the secret of webhook with id: %d", id)
 		JSONResponse(w, wh, http.StatusOK)
 	}
 }
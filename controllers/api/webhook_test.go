@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+)
+
+func TestRotateWebhookSecret(t *testing.T) {
+	testCtx := setupTest(t)
+	wh := models.Webhook{Name: "Webhook", URL: "http://example.com", Secret: "original"}
+	err := models.PostWebhook(&wh)
+	if err != nil {
+		t.Fatalf("error creating webhook: %v", err)
+	}
+	rotate := func(id int64, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/webhooks/%d/rotate", id), bytes.NewBufferString(body))
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", testCtx.apiKey))
+		w := httptest.NewRecorder()
+		testCtx.apiServer.ServeHTTP(w, r)
+		return w
+	}
+
+	w := rotate(wh.Id, `{"secret":"new","overlap_hours":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+	got := models.Webhook{}
+	err = json.NewDecoder(w.Body).Decode(&got)
+	if err != nil {
+		t.Fatalf("error decoding webhook: %v", err)
+	}
+	if got.Secret != "new" || got.KeyId == "" {
+		t.Fatalf("unexpected webhook secret. expected new with a key id got %q %q", got.Secret, got.KeyId)
+	}
+	if len(got.PreviousSecrets) != 1 || got.PreviousSecrets[0].Secret != "original" {
+		t.Fatalf("expected the original secret to still be used. got %v", got.PreviousSecrets)
+	}
+
+	// An empty body generates a new secret
+	w = rotate(wh.Id, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	w = rotate(wh.Id, `{"overlap_hours":-1}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	w = rotate(wh.Id+1, "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code received. expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/worker"
+)
+
+// WorkerStats (/api/admin/worker) returns how the background worker is
+// batching and sending campaign emails.
+func (as *Server) WorkerStats(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET":
+		sr, ok := as.worker.(worker.StatsReporter)
+		if !ok {
+			JSONResponse(w, models.Response{Success: false, Message: "The background worker isn't running"}, http.StatusNotFound)
+			return
+		}
+		JSONResponse(w, sr.Stats(), http.StatusOK)
+	}
+}
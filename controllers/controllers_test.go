@@ -57,7 +57,7 @@ func setupTest(t *testing.T) *testContext {
 		t.Fatalf("error creating new user: %v", err)
 	}
 
-	ctx.apiKey = u.ApiKey
+	ctx.apiKey = string(u.ApiKey)
 	// Start the phishing server
 	ctx.phishServer = httptest.NewUnstartedServer(NewPhishingServer(ctx.config.PhishConf).server.Handler)
 	ctx.phishServer.Config.Addr = ctx.config.PhishConf.ListenURL
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/gophish/gophish/models"
+)
+
+// submitDelayScript is injected into landing pages which show a loading
+// spinner when their form is submitted. The submission is held back for the
+// page's delay while the spinner covers the page, as the real site would
+// while it checks the credentials.
+const submitDelayScript = `<script>(function () {
+  var delay = %d;
+  document.addEventListener("submit", function (e) {
+    var form = e.target;
+    if (e.defaultPrevented || form.hasAttribute("data-submitting")) {
+      return;
+    }
+    e.preventDefault();
+    form.setAttribute("data-submitting", "");
+    if (e.submitter && e.submitter.name) {
+      var input = document.createElement("input");
+      input.type = "hidden";
+      input.name = e.submitter.name;
+      input.value = e.submitter.value;
+      form.appendChild(input);
+    }
+    var overlay = document.createElement("div");
+    overlay.setAttribute("style", "position:fixed;top:0;right:0;bottom:0;left:0;z-index:2147483647;display:flex;align-items:center;justify-content:center;background:rgba(255,255,255,0.8)");
+    overlay.innerHTML = '<style>@keyframes spin{to{transform:rotate(360deg)}}</style><div style="width:40px;height:40px;border:4px solid #ddd;border-top-color:#666;border-radius:50%%;animation:spin 1s linear infinite"></div>';
+    document.body.appendChild(overlay);
+    setTimeout(function () {
+      HTMLFormElement.prototype.submit.call(form);
+    }, delay);
+  }, true);
+})();</script>`
+
+// injectSubmitDelayScript adds the loading spinner script to the page.
+func injectSubmitDelayScript(html string, seconds int) string {
+	return injectScript(html, fmt.Sprintf(submitDelayScript, seconds*1000))
+}
+
+// setFormState sets the step of the page's form shown to the recipient, along
+// with the page's error if their submission was rejected.
+func setFormState(ptx *models.PhishingTemplateContext, p models.Page, state models.FormState) error {
+	ptx.FormStep = state.Step
+	if !state.Rejected {
+		return nil
+	}
+	var err error
+	ptx.FormError, err = models.ExecuteTemplate(p.SubmitError, *ptx)
+	return err
+}
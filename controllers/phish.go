@@ -5,8 +5,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -15,10 +21,14 @@ import (
 	ctx "github.com/gophish/gophish/context"
 	"github.com/gophish/gophish/controllers/api"
 	log "github.com/gophish/gophish/logger"
+	mid "github.com/gophish/gophish/middleware"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/storage"
+	"github.com/gophish/gophish/tracing"
 	"github.com/gophish/gophish/util"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
 	"github.com/jordan-wright/unindexed"
 )
 
@@ -38,10 +48,19 @@ type TransparencyResponse struct {
 	SendDate       time.Time `json:"send_date"`
 }
 
+// StaticDir is the directory of static assets served by the phishing server.
+const StaticDir = "./static/endpoint/"
+
+// StaticPrefix is the prefix of the keys of static assets in object storage.
+const StaticPrefix = "static/"
+
 // TransparencySuffix (when appended to a valid result ID), will cause Gophish
 // to return a transparency response.
 const TransparencySuffix = "+"
 
+// unsubscribedMessage is shown to recipients who follow the unsubscribe link
+const unsubscribedMessage = "You have been unsubscribed."
+
 // PhishingServerOption is a functional option that is used to configure the
 // the phishing server
 type PhishingServerOption func(*PhishingServer)
@@ -52,6 +71,9 @@ type PhishingServer struct {
 	server         *http.Server
 	config         config.PhishServer
 	contactAddress string
+	cert           *util.Certificate
+	store          storage.Store
+	challengeKey   []byte
 }
 
 // NewPhishingServer returns a new instance of the phishing server with
@@ -63,8 +85,9 @@ func NewPhishingServer(config config.PhishServer, options ...PhishingServerOptio
 		Addr:         config.ListenURL,
 	}
 	ps := &PhishingServer{
-		server: defaultServer,
-		config: config,
+		server:       defaultServer,
+		config:       config,
+		challengeKey: loadChallengeKey(),
 	}
 	for _, opt := range options {
 		opt(ps)
@@ -81,17 +104,25 @@ func WithContactAddress(addr string) PhishingServerOption {
 	}
 }
 
+// WithObjectStore sets the object storage which static assets are served
+// from when they aren't in the static directory.
+func WithObjectStore(s storage.Store) PhishingServerOption {
+	return func(ps *PhishingServer) {
+		ps.store = s
+	}
+}
+
 // Start launches the phishing server, listening on the configured address.
 func (ps *PhishingServer) Start() {
 	if ps.config.UseTLS {
-		// Only support TLS 1.2 and above - ref #1691, #1689
-		ps.server.TLSConfig = defaultTLSConfig
-		err := util.CheckAndCreateSSL(ps.config.CertPath, ps.config.KeyPath)
+		tlsConfig, cert, err := loadTLSConfig("phishing server", ps.config.CertPath, ps.config.KeyPath)
 		if err != nil {
 			log.Fatal(err)
 		}
+		ps.server.TLSConfig = tlsConfig
+		ps.cert = cert
 		log.Infof("Starting phishing server at https://%s", ps.config.ListenURL)
-		log.Fatal(ps.server.ListenAndServeTLS(ps.config.CertPath, ps.config.KeyPath))
+		log.Fatal(ps.server.ListenAndServeTLS("", ""))
 	}
 	// If TLS isn't configured, just listen on HTTP
 	log.Infof("Starting phishing server at http://%s", ps.config.ListenURL)
@@ -100,6 +131,9 @@ func (ps *PhishingServer) Start() {
 
 // Shutdown attempts to gracefully shutdown the server.
 func (ps *PhishingServer) Shutdown() error {
+	if ps.cert != nil {
+		ps.cert.Close()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 	return ps.server.Shutdown(ctx)
@@ -108,25 +142,39 @@ func (ps *PhishingServer) Shutdown() error {
 // CreatePhishingRouter creates the router that handles phishing connections.
 func (ps *PhishingServer) registerRoutes() {
 	router := mux.NewRouter()
-	fileServer := http.FileServer(unindexed.Dir("./static/endpoint/"))
-	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fileServer))
+	fileServer := http.FileServer(unindexed.Dir(StaticDir))
+	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", ps.staticHandler(fileServer)))
 	router.HandleFunc("/track", ps.TrackHandler)
 	router.HandleFunc("/robots.txt", ps.RobotsHandler)
 	router.HandleFunc("/{path:.*}/track", ps.TrackHandler)
 	router.HandleFunc("/{path:.*}/report", ps.ReportHandler)
 	router.HandleFunc("/report", ps.ReportHandler)
+	router.HandleFunc("/{path:.*}/unsubscribe", ps.UnsubscribeHandler)
+	router.HandleFunc("/unsubscribe", ps.UnsubscribeHandler)
+	router.HandleFunc(CapturePath, ps.CaptureHandler)
 	router.HandleFunc("/{path:.*}", ps.PhishHandler)
 
 	// Setup GZIP compression
 	gzipWrapper, _ := gziphandler.NewGzipLevelHandler(gzip.BestCompression)
 	phishHandler := gzipWrapper(router)
 
-	// Respect X-Forwarded-For and X-Real-IP headers in case we're behind a
-	// reverse proxy.
-	phishHandler = handlers.ProxyHeaders(phishHandler)
+	// Respect the configured client address headers, or X-Forwarded-For and
+	// X-Real-IP, in case we're behind a reverse proxy or CDN. The address is
+	// recorded in the details of each event.
+	trustedProxies, err := mid.ParseTrustedProxies(ps.config.TrustedProxies)
+	if err != nil {
+		log.Error(err)
+	}
+	phishHandler = mid.ProxyHeaders(trustedProxies, ps.config.ClientIPHeaders...)(phishHandler)
 
-	// Setup logging
-	phishHandler = handlers.CombinedLoggingHandler(log.Writer(), phishHandler)
+	// Trace each request, so that the spans started while handling it are
+	// grouped together
+	phishHandler = tracing.Middleware("phish")(phishHandler)
+
+	// Setup logging, giving each request an ID which is logged along with
+	// the messages logged while handling it
+	phishHandler = handlers.CustomLoggingHandler(ioutil.Discard, phishHandler, mid.LogRequest)
+	phishHandler = mid.RequestID(phishHandler)
 	ps.server.Handler = phishHandler
 }
 
@@ -136,7 +184,7 @@ func (ps *PhishingServer) TrackHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// Log the error if it wasn't something we can safely ignore
 		if err != ErrInvalidRequest && err != ErrCampaignComplete {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		http.NotFound(w, r)
 		return
@@ -156,9 +204,9 @@ func (ps *PhishingServer) TrackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = rs.HandleEmailOpened(d)
+	err = recordEvent(r, models.EventOpened, rs.HandleEmailOpened, d)
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 	}
 	http.ServeFile(w, r, "static/images/pixel.png")
 }
@@ -170,7 +218,7 @@ func (ps *PhishingServer) ReportHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		// Log the error if it wasn't something we can safely ignore
 		if err != ErrInvalidRequest && err != ErrCampaignComplete {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		http.NotFound(w, r)
 		return
@@ -190,23 +238,71 @@ func (ps *PhishingServer) ReportHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = rs.HandleEmailReport(d)
+	err = recordEvent(r, models.EventReported, rs.HandleEmailReport, d)
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// UnsubscribeHandler tracks recipients following the unsubscribe link,
+// updating the status for the given Result
+func (ps *PhishingServer) UnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	r, err := setupContext(r)
+	if err != nil {
+		// Log the error if it wasn't something we can safely ignore
+		if err != ErrInvalidRequest && err != ErrCampaignComplete {
+			log.FromContext(r.Context()).Error(err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	// Check for a preview
+	if _, ok := ctx.Get(r, "result").(models.EmailRequest); ok {
+		fmt.Fprintln(w, unsubscribedMessage)
+		return
+	}
+	rs := ctx.Get(r, "result").(models.Result)
+	rid := ctx.Get(r, "rid").(string)
+	d := ctx.Get(r, "details").(models.EventDetails)
+
+	// Check for a transparency request
+	if strings.HasSuffix(rid, TransparencySuffix) {
+		ps.TransparencyHandler(w, r)
+		return
+	}
+
+	err = recordEvent(r, models.EventUnsubscribed, rs.HandleUnsubscribe, d)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+	}
+	fmt.Fprintln(w, unsubscribedMessage)
+}
+
 // PhishHandler handles incoming client connections and registers the associated actions performed
 // (such as clicked link, etc.)
 func (ps *PhishingServer) PhishHandler(w http.ResponseWriter, r *http.Request) {
+	if ps.handleShortLink(w, r) {
+		return
+	}
+	// The raw request needs to be captured before the form is parsed, since
+	// parsing the form consumes the request body. It's filtered using the page
+	// settings once the page is loaded.
+	var er *models.EventRequest
+	if ps.config.CaptureRequests {
+		var err error
+		er, err = models.NewEventRequest(r, ps.config.CaptureMaxBodySize)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+	}
 	r, err := setupContext(r)
 	if err != nil {
 		// Log the error if it wasn't something we can safely ignore
 		if err != ErrInvalidRequest && err != ErrCampaignComplete {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
-		http.NotFound(w, r)
+		renderNotFound(w, r)
 		return
 	}
 	w.Header().Set("X-Server", config.ServerName) // Useful for checking if this is a GoPhish server (e.g. for campaign reporting plugins)
@@ -215,17 +311,19 @@ func (ps *PhishingServer) PhishHandler(w http.ResponseWriter, r *http.Request) {
 	if preview, ok := ctx.Get(r, "result").(models.EmailRequest); ok {
 		ptx, err = models.NewPhishingTemplateContext(&preview, preview.BaseRecipient, preview.RId)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			http.NotFound(w, r)
 			return
 		}
 		p, err := models.GetPage(preview.PageId, preview.UserId)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			http.NotFound(w, r)
 			return
 		}
-		renderPhishResponse(w, r, ptx, p)
+		// Previews always show the first step of the page's form
+		ptx.FormStep = 1
+		renderPhishResponse(w, r, ptx, p.Localize(preview.Language))
 		return
 	}
 	rs := ctx.Get(r, "result").(models.Result)
@@ -241,26 +339,76 @@ func (ps *PhishingServer) PhishHandler(w http.ResponseWriter, r *http.Request) {
 
 	p, err := models.GetPage(c.PageId, c.UserId)
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 		http.NotFound(w, r)
 		return
 	}
+	p = p.Localize(rs.Language)
+	d.Request = p.FilterEventRequest(er)
+	allowed, rule := p.CheckAccess(r.Header.Get("User-Agent"), r.Referer())
+	if rule != nil {
+		d.Browser["access-rule"] = rule.String()
+	}
+	if !allowed {
+		err = recordEvent(r, models.EventBlocked, rs.HandleBlockedRequest, d)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
+		ptx, err = models.NewPhishingTemplateContext(&c, rs.BaseRecipient, rs.RId)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			http.NotFound(w, r)
+			return
+		}
+		renderDecoyResponse(w, r, ptx, p)
+		return
+	}
+	// The recipient's progress through the form is tracked by the number of
+	// times they've submitted it, so reloading the page shows the step they
+	// were on, without the error from a rejected submission
+	form := p.FormState(rs.SubmitCount)
+	form.Rejected = false
 	switch {
 	case r.Method == "GET":
-		err = rs.HandleClickedLink(d)
+		if needsChallenge(p, rs) {
+			if !ps.passedChallenge(r, rid) {
+				err = recordEvent(r, models.EventLinkFetched, rs.HandleLinkFetched, d)
+				if err != nil {
+					log.FromContext(r.Context()).Error(err)
+				}
+				ps.renderClickChallenge(w, r, rid)
+				return
+			}
+			d.Payload.Del(ChallengeParameter)
+			d.Browser["click-challenge"] = "passed"
+		}
+		err = recordEvent(r, models.EventClicked, rs.HandleClickedLink, d)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 	case r.Method == "POST":
-		err = rs.HandleFormSubmit(d)
+		err = recordEvent(r, models.EventDataSubmit, rs.HandleFormSubmit, p.ScoreCapturedPasswords(rs.BaseRecipient, d))
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
+		form = p.FormState(rs.SubmitCount)
 	}
 	ptx, err = models.NewPhishingTemplateContext(&c, rs.BaseRecipient, rs.RId)
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
+		http.NotFound(w, r)
+	}
+	err = setFormState(&ptx, p, form)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
 		http.NotFound(w, r)
+		return
+	}
+	// Submissions before the last step of the form, or which are rejected,
+	// show the page again rather than redirecting
+	if r.Method == "POST" && !form.Complete {
+		renderPhishPage(w, r, ptx, p)
+		return
 	}
 	renderPhishResponse(w, r, ptx, p)
 }
@@ -275,7 +423,7 @@ func renderPhishResponse(w http.ResponseWriter, r *http.Request, ptx models.Phis
 		if p.RedirectURL != "" {
 			redirectURL, err := models.ExecuteTemplate(p.RedirectURL, ptx)
 			if err != nil {
-				log.Error(err)
+				log.FromContext(r.Context()).Error(err)
 				http.NotFound(w, r)
 				return
 			}
@@ -284,15 +432,94 @@ func renderPhishResponse(w http.ResponseWriter, r *http.Request, ptx models.Phis
 		}
 	}
 	// Otherwise, we just need to write out the templated HTML
+	renderPhishPage(w, r, ptx, p)
+}
+
+// renderPhishPage writes out the page's templated HTML, along with any
+// scripts the page uses.
+func renderPhishPage(w http.ResponseWriter, r *http.Request, ptx models.PhishingTemplateContext, p models.Page) {
 	html, err := models.ExecuteTemplate(p.HTML, ptx)
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	if p.CaptureScript {
+		html = injectCaptureScript(html, ptx.RId)
+	}
+	if p.SubmitDelay > 0 {
+		html = injectSubmitDelayScript(html, p.SubmitDelay)
+	}
+	w.Write([]byte(html))
+}
+
+// renderDecoyResponse writes out the page's decoy HTML for requests blocked by
+// its access rules. Pages without a decoy respond as if they don't exist.
+func renderDecoyResponse(w http.ResponseWriter, r *http.Request, ptx models.PhishingTemplateContext, p models.Page) {
+	if p.DecoyHTML == "" {
+		renderNotFound(w, r)
+		return
+	}
+	html, err := models.ExecuteTemplate(p.DecoyHTML, ptx)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(html))
+}
+
+// renderNotFound writes out the not found page of the campaign the request is
+// for, falling back to a 404 response if the campaign doesn't have one.
+func renderNotFound(w http.ResponseWriter, r *http.Request) {
+	rid := strings.TrimSuffix(strings.TrimRight(r.Form.Get(models.RecipientParameter), " "), TransparencySuffix)
+	p, err := models.GetNotFoundPage(rid, r.Host)
+	if err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.FromContext(r.Context()).Error(err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	// The recipient isn't known, so the page is templated without their
+	// details.
+	html, err := models.ExecuteTemplate(p.HTML, models.PhishingTemplateContext{})
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
 		http.NotFound(w, r)
 		return
 	}
 	w.Write([]byte(html))
 }
 
+// staticHandler serves static assets from the static directory, falling back
+// to object storage if it's configured.
+func (ps *PhishingServer) staticHandler(fileServer http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		_, err := os.Stat(filepath.Join(StaticDir, filepath.FromSlash(name)))
+		if ps.store == nil || err == nil {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		content, err := ps.store.Get(StaticPrefix + strings.TrimPrefix(name, "/"))
+		if err == storage.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Errorf("error reading %s from object storage: %v", name, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer content.Close()
+		if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		io.Copy(w, content)
+	})
+}
+
 // RobotsHandler prevents search engines, etc. from indexing phishing materials
 func (ps *PhishingServer) RobotsHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "User-agent: *\nDisallow: /")
@@ -310,12 +537,26 @@ func (ps *PhishingServer) TransparencyHandler(w http.ResponseWriter, r *http.Req
 	api.JSONResponse(w, tr, http.StatusOK)
 }
 
+// recordEvent records an event for the result using its handler, tracing how
+// long it takes.
+func recordEvent(r *http.Request, event string, handle func(models.EventDetails) error, d models.EventDetails) error {
+	_, span := tracing.Start(r.Context(), "event.record")
+	defer span.End()
+	span.SetAttribute("event", event)
+	if c, ok := ctx.Get(r, "campaign").(models.Campaign); ok {
+		span.SetAttribute("campaign_id", c.Id)
+	}
+	err := handle(d)
+	span.SetError(err)
+	return err
+}
+
 // setupContext handles some of the administrative work around receiving a new
 // request, such as checking the result ID, the campaign, etc.
 func setupContext(r *http.Request) (*http.Request, error) {
 	err := r.ParseForm()
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 		return r, err
 	}
 	rid := r.Form.Get(models.RecipientParameter)
@@ -351,7 +592,7 @@ func setupContext(r *http.Request) (*http.Request, error) {
 	}
 	c, err := models.GetCampaign(rs.CampaignId, rs.UserId)
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 		return r, err
 	}
 	// Don't process events for completed campaigns
@@ -362,17 +603,24 @@ func setupContext(r *http.Request) (*http.Request, error) {
 	if err != nil {
 		ip = r.RemoteAddr
 	}
-	// Handle post processing such as GeoIP
-	err = rs.UpdateGeo(ip)
-	if err != nil {
-		log.Error(err)
+	// Handle post processing such as GeoIP. We skip this for campaigns in
+	// privacy mode, since we don't want to store the recipient's address.
+	if !c.Anonymize {
+		err = rs.UpdateGeo(ip)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+		}
 	}
 	d := models.EventDetails{
-		Payload: r.Form,
-		Browser: make(map[string]string),
+		Payload:   r.Form,
+		Browser:   make(map[string]string),
+		RequestId: log.RequestID(r.Context()),
 	}
 	d.Browser["address"] = ip
 	d.Browser["user-agent"] = r.Header.Get("User-Agent")
+	if client := models.DetectMailClient(r.Header.Get("User-Agent"), r.Referer()); client != "" {
+		d.Browser["mail-client"] = client
+	}
 
 	r = ctx.Set(r, "rid", rid)
 	r = ctx.Set(r, "result", rs)
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/gophish/gophish/config"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/storage"
 )
 
 func getFirstCampaign(t *testing.T) models.Campaign {
@@ -177,6 +181,38 @@ func TestOpenedPhishingEmail(t *testing.T) {
 	}
 }
 
+func TestOpenedPhishingEmailMailClient(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	campaign := getFirstCampaign(t)
+	result := campaign.Results[0]
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/track?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId), nil)
+	if err != nil {
+		t.Fatalf("error creating /track request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Microsoft Office/16.0 (Windows NT 10.0; Microsoft Outlook 16.0.13901; Pro)")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error requesting /track endpoint: %v", err)
+	}
+	resp.Body.Close()
+
+	campaign = getFirstCampaign(t)
+	result = campaign.Results[0]
+	if result.MailClient != models.MailClientOutlook {
+		t.Fatalf("unexpected mail client received. expected %s got %s", models.MailClientOutlook, result.MailClient)
+	}
+	lastEvent := campaign.Events[len(campaign.Events)-1]
+	details := models.EventDetails{}
+	if err := json.Unmarshal([]byte(lastEvent.Details), &details); err != nil {
+		t.Fatalf("error unmarshaling event details: %v", err)
+	}
+	if details.Browser["mail-client"] != models.MailClientOutlook {
+		t.Fatalf("unexpected mail client in event details. expected %s got %s", models.MailClientOutlook, details.Browser["mail-client"])
+	}
+}
+
 func TestReportedPhishingEmail(t *testing.T) {
 	ctx := setupTest(t)
 	defer tearDown(t, ctx)
@@ -203,6 +239,42 @@ func TestReportedPhishingEmail(t *testing.T) {
 	}
 }
 
+func TestUnsubscribedPhishingEmail(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	campaign := getFirstCampaign(t)
+	result := campaign.Results[0]
+
+	resp, err := http.Get(fmt.Sprintf("%s/unsubscribe?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId))
+	if err != nil {
+		t.Fatalf("error requesting /unsubscribe endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("invalid status code received for /unsubscribe endpoint. expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	campaign = getFirstCampaign(t)
+	result = campaign.Results[0]
+	lastEvent := campaign.Events[len(campaign.Events)-1]
+	if !result.Unsubscribed {
+		t.Fatalf("unexpected result unsubscribe status received. expected %v got %v", true, result.Unsubscribed)
+	}
+	if result.Status != models.StatusSending {
+		t.Fatalf("unexpected result status received. expected %s got %s", models.StatusSending, result.Status)
+	}
+	if lastEvent.Message != models.EventUnsubscribed {
+		t.Fatalf("unexpected event status received. expected %s got %s", models.EventUnsubscribed, lastEvent.Message)
+	}
+	stats, err := models.GetCampaignSummary(campaign.Id, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error getting campaign summary: %v", err)
+	}
+	if stats.Stats.Unsubscribed != 1 {
+		t.Fatalf("unexpected unsubscribed count. expected %d got %d", 1, stats.Stats.Unsubscribed)
+	}
+}
+
 func TestClickedPhishingLinkAfterOpen(t *testing.T) {
 	ctx := setupTest(t)
 	defer tearDown(t, ctx)
@@ -289,6 +361,39 @@ func TestCompletedCampaignClick(t *testing.T) {
 	}
 }
 
+func TestNotFoundPage(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{Name: "Parked Page", HTML: "<html>Parked domain</html>", UserId: 1}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting new page: %v", err)
+	}
+	clickLink404(t, ctx, "unknown")
+
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	page, _ := models.GetPage(1, 1)
+	group, _ := models.GetGroup(1, 1)
+	campaign := models.Campaign{Name: "Parked campaign", URL: ctx.phishServer.URL, NotFoundPageId: p.Id}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = page
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	// Completed campaigns serve the page rather than a 404 response, as do
+	// requests on the campaign's host without a valid recipient id
+	models.CompleteCampaign(campaign.Id, 1)
+	clickLink(t, ctx, result.RId, p.HTML)
+	clickLink(t, ctx, "unknown", p.HTML)
+}
+
 func TestRobotsHandler(t *testing.T) {
 	ctx := setupTest(t)
 	defer tearDown(t, ctx)
@@ -312,6 +417,29 @@ func TestRobotsHandler(t *testing.T) {
 	}
 }
 
+func TestStaticObjectStorage(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Put(StaticPrefix+"css/site.css", []byte("body {}"), "text/css")
+	ps := NewPhishingServer(config.PhishServer{}, WithObjectStore(store))
+
+	r := httptest.NewRequest("GET", "/static/css/site.css", nil)
+	w := httptest.NewRecorder()
+	ps.server.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "body {}" {
+		t.Fatalf("unexpected response for stored asset: %d %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("unexpected content type: %s", w.Header().Get("Content-Type"))
+	}
+
+	r = httptest.NewRequest("GET", "/static/missing.css", nil)
+	w = httptest.NewRecorder()
+	ps.server.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing asset, got %d", w.Code)
+	}
+}
+
 func TestInvalidPreviewID(t *testing.T) {
 	ctx := setupTest(t)
 	defer tearDown(t, ctx)
@@ -414,3 +542,488 @@ func TestRedirectTemplating(t *testing.T) {
 		t.Fatalf("invalid redirect received. expected %s got %s", expectedURL, gotURL)
 	}
 }
+
+// expectFormPage checks that the form submission showed the expected page
+func expectFormPage(t *testing.T, submission string, status int, got string, expected string) {
+	if status != http.StatusOK {
+		t.Fatalf("invalid status code received for %s. expected %d got %d", submission, http.StatusOK, status)
+	}
+	if got != expected {
+		t.Fatalf("unexpected page received for %s. expected %s got %s", submission, expected, got)
+	}
+}
+
+func TestFormSimulation(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{
+		Name:               "Form Page",
+		HTML:               "<html><head></head><body>{{if eq .FormStep 1}}Username{{else}}Password{{end}} {{.FormError}}</body></html>",
+		UserId:             1,
+		CaptureCredentials: true,
+		RedirectURL:        "http://example.com/",
+		FormSteps:          2,
+		RejectFirstSubmit:  true,
+		SubmitError:        "Wrong password for {{.Email}}",
+		SubmitDelay:        2,
+	}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting new page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Form campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	page := func(body string) string {
+		return injectSubmitDelayScript("<html><head></head><body>"+body+"</body></html>", 2)
+	}
+	clickLink(t, ctx, result.RId, page("Username "))
+
+	client := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	submit := func() (int, string) {
+		resp, err := client.PostForm(fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId), url.Values{"username": {"test"}})
+		if err != nil {
+			t.Fatalf("error requesting / endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+		got, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("error reading payload from / endpoint response: %v", err)
+		}
+		return resp.StatusCode, string(got)
+	}
+	// The username step moves on to the password step, which rejects the
+	// first password before redirecting. Reloading the page shows the step
+	// the recipient was on.
+	status, body := submit()
+	expectFormPage(t, "username", status, body, page("Password "))
+	clickLink(t, ctx, result.RId, page("Password "))
+	status, body = submit()
+	expectFormPage(t, "first password", status, body, page("Password Wrong password for "+result.Email))
+	clickLink(t, ctx, result.RId, page("Password "))
+	status, _ = submit()
+	if status != http.StatusFound {
+		t.Fatalf("invalid status code received for second password. expected %d got %d", http.StatusFound, status)
+	}
+	campaign, err = models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	if campaign.Results[0].SubmitCount != 3 {
+		t.Fatalf("unexpected submit count. expected 3 got %d", campaign.Results[0].SubmitCount)
+	}
+}
+
+func TestCaptureScript(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{
+		Name:               "Capture Page",
+		HTML:               "<html><head><title>Sign in</title></head><body></body></html>",
+		UserId:             1,
+		CaptureCredentials: true,
+		CaptureScript:      true,
+	}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting new page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Capture campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	expectedHTML := injectCaptureScript("<html><head><title>Sign in</title></head><body></body></html>", result.RId)
+	if !strings.HasPrefix(expectedHTML, "<html><head><script>") {
+		t.Fatalf("capture script wasn't injected at the start of the head element: %s", expectedHTML)
+	}
+	clickLink(t, ctx, result.RId, expectedHTML)
+
+	captureURL := fmt.Sprintf("%s%s?%s=%s", ctx.phishServer.URL, CapturePath, models.RecipientParameter, result.RId)
+	resp, err := http.Post(captureURL, "application/json", strings.NewReader(`{"user": {"email": "foo@example.com", "password": "secret"}}`))
+	if err != nil {
+		t.Fatalf("error requesting %s endpoint: %v", CapturePath, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("invalid status code received for %s endpoint. expected %d got %d", CapturePath, http.StatusNoContent, resp.StatusCode)
+	}
+
+	campaign, err = models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	if campaign.Results[0].Status != models.EventDataSubmit {
+		t.Fatalf("unexpected result status received. expected %s got %s", models.EventDataSubmit, campaign.Results[0].Status)
+	}
+	lastEvent := campaign.Events[len(campaign.Events)-1]
+	details := models.EventDetails{}
+	if err := json.Unmarshal([]byte(lastEvent.Details), &details); err != nil {
+		t.Fatalf("error unmarshaling event details: %v", err)
+	}
+	expectedPayload := url.Values{
+		models.RecipientParameter: {result.RId},
+		"user.email":              {"foo@example.com"},
+	}
+	if !reflect.DeepEqual(details.Payload, expectedPayload) {
+		t.Fatalf("unexpected payload received. expected %v got %v", expectedPayload, details.Payload)
+	}
+
+	resp, err = http.Post(captureURL, "application/json", strings.NewReader(`username=foo`))
+	if err != nil {
+		t.Fatalf("error requesting %s endpoint: %v", CapturePath, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid status code received for %s endpoint. expected %d got %d", CapturePath, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestCaptureRequestsFiltered(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	ps := httptest.NewServer(NewPhishingServer(config.PhishServer{CaptureRequests: true}).server.Handler)
+	defer ps.Close()
+	p := models.Page{
+		Name:               "No Passwords Page",
+		HTML:               "<html><head></head><body></body></html>",
+		UserId:             1,
+		CaptureCredentials: true,
+		CapturePasswords:   false,
+		CaptureScript:      true,
+	}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting new page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Capture requests campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	form := url.Values{"username": {"foo"}, "password": {"hunter2"}}
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/?%s=%s", ps.URL, models.RecipientParameter, result.RId), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Cookie", "session=secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error requesting / endpoint: %v", err)
+	}
+	resp.Body.Close()
+
+	captureURL := fmt.Sprintf("%s%s?%s=%s", ps.URL, CapturePath, models.RecipientParameter, result.RId)
+	resp, err = http.Post(captureURL, "application/json", strings.NewReader(`{"user": {"email": "foo@example.com", "password": "hunter2"}}`))
+	if err != nil {
+		t.Fatalf("error requesting %s endpoint: %v", CapturePath, err)
+	}
+	resp.Body.Close()
+
+	ers, err := models.GetEventRequests(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting captured requests: %v", err)
+	}
+	if len(ers) != 2 {
+		t.Fatalf("unexpected number of captured requests. expected %d got %d", 2, len(ers))
+	}
+	if string(ers[0].Body) != "username=foo" {
+		t.Fatalf("unexpected form body stored. expected %q got %q", "username=foo", ers[0].Body)
+	}
+	if strings.Contains(string(ers[0].Headers), "session=secret") {
+		t.Fatalf("unexpected Cookie header stored: %s", ers[0].Headers)
+	}
+	expectedBody := `{"user.email":["foo@example.com"]}`
+	if string(ers[1].Body) != expectedBody {
+		t.Fatalf("unexpected JSON body stored. expected %q got %q", expectedBody, ers[1].Body)
+	}
+}
+
+func TestPageAccessRules(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{
+		Name:      "Gated Page",
+		HTML:      "<html>Landing page</html>",
+		DecoyHTML: "<html>Decoy page</html>",
+		UserId:    1,
+		AccessRules: []models.PageAccessRule{
+			{Field: models.AccessFieldUserAgent, Pattern: "curl/", Action: models.AccessBlock},
+		},
+	}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting new page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Gated campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId), nil)
+	if err != nil {
+		t.Fatalf("error creating / request: %v", err)
+	}
+	req.Header.Set("User-Agent", "curl/7.68.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error requesting / endpoint: %v", err)
+	}
+	got, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("error reading payload from / endpoint response: %v", err)
+	}
+	if string(got) != p.DecoyHTML {
+		t.Fatalf("invalid response received from / endpoint. expected %s got %s", p.DecoyHTML, got)
+	}
+
+	campaign, err = models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	if campaign.Results[0].Status == models.EventClicked {
+		t.Fatalf("blocked request was recorded as a click")
+	}
+	lastEvent := campaign.Events[len(campaign.Events)-1]
+	if lastEvent.Message != models.EventBlocked {
+		t.Fatalf("unexpected event status received. expected %s got %s", models.EventBlocked, lastEvent.Message)
+	}
+	details := models.EventDetails{}
+	if err := json.Unmarshal([]byte(lastEvent.Details), &details); err != nil {
+		t.Fatalf("error unmarshaling event details: %v", err)
+	}
+	expectedRule := "block if user_agent matches curl/"
+	if details.Browser["access-rule"] != expectedRule {
+		t.Fatalf("unexpected access rule in event details. expected %s got %s", expectedRule, details.Browser["access-rule"])
+	}
+
+	clickLink(t, ctx, result.RId, p.HTML)
+	campaign, err = models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	if campaign.Results[0].Status != models.EventClicked {
+		t.Fatalf("unexpected result status received. expected %s got %s", models.EventClicked, campaign.Results[0].Status)
+	}
+}
+
+func TestClickChallenge(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	p := models.Page{
+		Name:           "Challenged Page",
+		HTML:           "<html>Landing page</html>",
+		ClickChallenge: true,
+		UserId:         1,
+	}
+	err := models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error posting new page: %v", err)
+	}
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Challenged campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = p
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	err = models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	fetch := func(answer string) string {
+		u := fmt.Sprintf("%s/?%s=%s", ctx.phishServer.URL, models.RecipientParameter, result.RId)
+		if answer != "" {
+			u += "&" + ChallengeParameter + "=" + url.QueryEscape(answer)
+		}
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("error requesting / endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+		got, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("error reading payload from / endpoint response: %v", err)
+		}
+		return string(got)
+	}
+	lastEvent := func() models.Event {
+		c, err := models.GetCampaign(campaign.Id, 1)
+		if err != nil {
+			t.Fatalf("error getting campaign: %v", err)
+		}
+		return c.Events[len(c.Events)-1]
+	}
+
+	// Fetching the link shows the challenge without recording a click
+	body := fetch("")
+	answer := regexp.MustCompile(`name="` + ChallengeParameter + `" value="([^"]+)"`).FindStringSubmatch(body)
+	if answer == nil {
+		t.Fatalf("expected the click challenge, got %s", body)
+	}
+	if !strings.Contains(body, fmt.Sprintf(`name="%s" value="%s"`, models.RecipientParameter, result.RId)) {
+		t.Fatalf("expected the challenge to include the recipient id, got %s", body)
+	}
+	if e := lastEvent(); e.Message != models.EventLinkFetched {
+		t.Fatalf("unexpected event status received. expected %s got %s", models.EventLinkFetched, e.Message)
+	}
+	if body := fetch("wrong"); !strings.Contains(body, `id="challenge"`) {
+		t.Fatalf("expected a wrong answer to be challenged again, got %s", body)
+	}
+
+	// Other instances sharing the database accept the same answer
+	other := NewPhishingServer(ctx.config.PhishConf)
+	if got := other.challengeAnswer(result.RId); got != answer[1] {
+		t.Fatalf("expected other instances to give the same answer. expected %s got %s", answer[1], got)
+	}
+
+	// Answering it shows the landing page and records the click
+	if body := fetch(answer[1]); body != p.HTML {
+		t.Fatalf("invalid response received from / endpoint. expected %s got %s", p.HTML, body)
+	}
+	e := lastEvent()
+	if e.Message != models.EventClicked {
+		t.Fatalf("unexpected event status received. expected %s got %s", models.EventClicked, e.Message)
+	}
+	details := models.EventDetails{}
+	if err := json.Unmarshal([]byte(e.Details), &details); err != nil {
+		t.Fatalf("error unmarshaling event details: %v", err)
+	}
+	if details.Browser["click-challenge"] != "passed" {
+		t.Fatalf("expected the click challenge to be passed, got %v", details.Browser)
+	}
+	if _, ok := details.Payload[ChallengeParameter]; ok {
+		t.Fatalf("expected the answer to be removed from the payload, got %v", details.Payload)
+	}
+
+	// Recipients who have clicked aren't challenged again
+	clickLink(t, ctx, result.RId, p.HTML)
+}
+
+func TestShortLink(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	smtp, _ := models.GetSMTP(1, 1)
+	template, _ := models.GetTemplate(1, 1)
+	page, _ := models.GetPage(1, 1)
+	group, _ := models.GetGroup(1, 1)
+
+	campaign := models.Campaign{Name: "Short link campaign"}
+	campaign.UserId = 1
+	campaign.Template = template
+	campaign.Page = page
+	campaign.SMTP = smtp
+	campaign.Groups = []models.Group{group}
+	campaign.URL = "http://phish.example.com"
+	campaign.ShortURL = "http://go.example.com/s"
+	err := models.PostCampaign(&campaign, campaign.UserId)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	result := campaign.Results[0]
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	fetch := func(host string, path string) *http.Response {
+		req, err := http.NewRequest("GET", ctx.phishServer.URL+path, nil)
+		if err != nil {
+			t.Fatalf("error creating request: %v", err)
+		}
+		req.Host = host
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("error requesting %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// The short link redirects to the recipient's full link
+	resp := fetch("go.example.com", "/s/"+result.RId)
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("invalid status code received. expected %d got %d", http.StatusFound, resp.StatusCode)
+	}
+	expected := fmt.Sprintf("http://phish.example.com?%s=%s", models.RecipientParameter, result.RId)
+	if got := resp.Header.Get("Location"); got != expected {
+		t.Fatalf("invalid redirect received. expected %s got %s", expected, got)
+	}
+	c, err := models.GetCampaign(campaign.Id, 1)
+	if err != nil {
+		t.Fatalf("error getting campaign: %v", err)
+	}
+	e := c.Events[len(c.Events)-1]
+	if e.Message != models.EventShortLink || e.Email != result.Email {
+		t.Fatalf("unexpected event received. expected %s got %s", models.EventShortLink, e.Message)
+	}
+	if c.Results[0].Status == models.EventClicked {
+		t.Fatalf("expected the click to be recorded once the full link is requested")
+	}
+
+	// Requests for other hosts and paths aren't short links
+	for _, r := range []struct{ host, path string }{
+		{"phish.example.com", "/s/" + result.RId},
+		{"go.example.com", "/" + result.RId},
+		{"go.example.com", "/s/unknown"},
+	} {
+		if resp := fetch(r.host, r.path); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected %s%s to be not found, got %d", r.host, r.path, resp.StatusCode)
+		}
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"html/template"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
@@ -14,10 +17,14 @@ import (
 	"github.com/gophish/gophish/config"
 	ctx "github.com/gophish/gophish/context"
 	"github.com/gophish/gophish/controllers/api"
+	"github.com/gophish/gophish/grpc"
 	log "github.com/gophish/gophish/logger"
 	mid "github.com/gophish/gophish/middleware"
 	"github.com/gophish/gophish/middleware/ratelimit"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/reload"
+	"github.com/gophish/gophish/spam"
+	"github.com/gophish/gophish/tracing"
 	"github.com/gophish/gophish/util"
 	"github.com/gophish/gophish/worker"
 	"github.com/gorilla/csrf"
@@ -25,6 +32,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 	"github.com/jordan-wright/unindexed"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // AdminServerOption is a functional option that is used to configure the
@@ -38,6 +47,8 @@ type AdminServer struct {
 	worker  worker.Worker
 	config  config.AdminServer
 	limiter *ratelimit.PostLimiter
+	cert    *util.Certificate
+	scorer  spam.Scorer
 }
 
 var defaultTLSConfig = &tls.Config{
@@ -61,6 +72,39 @@ var defaultTLSConfig = &tls.Config{
 	},
 }
 
+// loadTLSConfig loads the certificate for a server, creating a self-signed
+// certificate if needed. The certificate is reloaded whenever the files
+// change or Gophish is reloaded, so renewing it doesn't require a restart.
+func loadTLSConfig(name string, certPath string, keyPath string) (*tls.Config, *util.Certificate, error) {
+	err := util.CheckAndCreateSSL(certPath, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := util.LoadCertificate(certPath, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	go cert.Watch(util.CertificateWatchInterval)
+	reload.Register(name+" certificate", cert.Reload)
+	// Only support TLS 1.2 and above - ref #1691, #1689
+	tlsConfig := defaultTLSConfig.Clone()
+	tlsConfig.GetCertificate = cert.GetCertificate
+	return tlsConfig, cert, nil
+}
+
+// loadCertPool returns a pool of the PEM encoded certificates in the file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
 // WithWorker is an option that sets the background worker.
 func WithWorker(w worker.Worker) AdminServerOption {
 	return func(as *AdminServer) {
@@ -68,6 +112,14 @@ func WithWorker(w worker.Worker) AdminServerOption {
 	}
 }
 
+// WithSpamScorer is an option that sets the spam filter used to score
+// templates.
+func WithSpamScorer(s spam.Scorer) AdminServerOption {
+	return func(as *AdminServer) {
+		as.scorer = s
+	}
+}
+
 // NewAdminServer returns a new instance of the AdminServer with the
 // provided config and options applied.
 func NewAdminServer(config config.AdminServer, options ...AdminServerOption) *AdminServer {
@@ -96,14 +148,21 @@ func (as *AdminServer) Start() {
 		go as.worker.Start()
 	}
 	if as.config.UseTLS {
-		// Only support TLS 1.2 and above - ref #1691, #1689
-		as.server.TLSConfig = defaultTLSConfig
-		err := util.CheckAndCreateSSL(as.config.CertPath, as.config.KeyPath)
+		tlsConfig, cert, err := loadTLSConfig("admin server", as.config.CertPath, as.config.KeyPath)
 		if err != nil {
 			log.Fatal(err)
 		}
+		if as.config.ClientCAPath != "" {
+			tlsConfig.ClientCAs, err = loadCertPool(as.config.ClientCAPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		as.server.TLSConfig = tlsConfig
+		as.cert = cert
 		log.Infof("Starting admin server at https://%s", as.config.ListenURL)
-		log.Fatal(as.server.ListenAndServeTLS(as.config.CertPath, as.config.KeyPath))
+		log.Fatal(as.server.ListenAndServeTLS("", ""))
 	}
 	// If TLS isn't configured, just listen on HTTP
 	log.Infof("Starting admin server at http://%s", as.config.ListenURL)
@@ -112,9 +171,21 @@ func (as *AdminServer) Start() {
 
 // Shutdown attempts to gracefully shutdown the server.
 func (as *AdminServer) Shutdown() error {
+	if as.cert != nil {
+		as.cert.Close()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
-	return as.server.Shutdown(ctx)
+	err := as.server.Shutdown(ctx)
+	if as.worker != nil {
+		// Let the emails being sent finish so that they aren't sent again
+		// when Gophish is restarted
+		werr := as.worker.Shutdown(ctx)
+		if werr != nil {
+			log.Errorf("error waiting for the mailer to finish: %v", werr)
+		}
+	}
+	return err
 }
 
 // SetupAdminRoutes creates the routes for handling requests to the web interface.
@@ -140,6 +211,8 @@ func (as *AdminServer) registerRoutes() {
 	api := api.NewServer(
 		api.WithWorker(as.worker),
 		api.WithLimiter(as.limiter),
+		api.WithGraphQL(as.config.EnableGraphQL),
+		api.WithSpamScorer(as.scorer),
 	)
 	router.PathPrefix("/api/").Handler(api)
 
@@ -153,7 +226,8 @@ func (as *AdminServer) registerRoutes() {
 	}
 	csrfHandler := csrf.Protect(csrfKey,
 		csrf.FieldName("csrf_token"),
-		csrf.Secure(as.config.UseTLS))
+		csrf.Secure(as.config.UseTLS),
+		csrf.TrustedOrigins(as.config.TrustedOrigins))
 	adminHandler := csrfHandler(router)
 	adminHandler = mid.Use(adminHandler.ServeHTTP, mid.CSRFExceptions, mid.GetContext, mid.ApplySecurityHeaders)
 
@@ -161,15 +235,62 @@ func (as *AdminServer) registerRoutes() {
 	gzipWrapper, _ := gziphandler.NewGzipLevelHandler(gzip.BestCompression)
 	adminHandler = gzipWrapper(adminHandler)
 
+	// Serve the admin server under its base path, if one is configured
+	adminHandler = mid.StripBasePath(as.config.BasePath)(adminHandler)
+
 	// Respect X-Forwarded-For and X-Real-IP headers in case we're behind a
 	// reverse proxy.
-	adminHandler = handlers.ProxyHeaders(adminHandler)
+	trustedProxies, err := mid.ParseTrustedProxies(as.config.TrustedProxies)
+	if err != nil {
+		log.Error(err)
+	}
+	adminHandler = mid.ProxyHeaders(trustedProxies)(adminHandler)
 
-	// Setup logging
-	adminHandler = handlers.CombinedLoggingHandler(log.Writer(), adminHandler)
+	// Serve gRPC calls before they reach the CSRF protection and compression,
+	// which would reject and corrupt them
+	if as.config.EnableGRPC {
+		adminHandler = grpcHandler(grpc.NewServer(grpc.WithWorker(as.worker)), adminHandler)
+	}
+
+	// Only allow the configured networks and clients with a trusted
+	// certificate to reach the admin server, including over gRPC
+	allowedNetworks, err := mid.ParseAllowedNetworks(as.config.AllowedNetworks)
+	if err != nil {
+		log.Fatal(err)
+	}
+	adminHandler = mid.RestrictNetworks(allowedNetworks, trustedProxies)(adminHandler)
+	if as.config.ClientCAPath != "" {
+		adminHandler = mid.RequireClientCertificate(adminHandler)
+	}
+
+	// Trace each request, so that the spans started while handling it are
+	// grouped together
+	adminHandler = tracing.Middleware("admin")(adminHandler)
+
+	// Setup logging, giving each request an ID which is logged along with
+	// the messages logged while handling it
+	adminHandler = handlers.CustomLoggingHandler(ioutil.Discard, adminHandler, mid.LogRequest)
+	adminHandler = mid.RequestID(adminHandler)
+	// gRPC requires HTTP/2, which is only negotiated over TLS, so it's
+	// accepted in plaintext as well when TLS isn't used
+	if as.config.EnableGRPC && !as.config.UseTLS {
+		adminHandler = h2c.NewHandler(adminHandler, &http2.Server{})
+	}
 	as.server.Handler = adminHandler
 }
 
+// grpcHandler sends gRPC calls to the gRPC server, and other requests to
+// the next handler.
+func grpcHandler(gs *grpc.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if grpc.IsRequest(r) {
+			gs.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 type templateParams struct {
 	Title        string
 	Flashes      []interface{}
@@ -177,6 +298,7 @@ type templateParams struct {
 	Token        string
 	Version      string
 	ModifySystem bool
+	BasePath     string
 }
 
 // newTemplateParams returns the default template parameters for a user and
@@ -191,6 +313,7 @@ func newTemplateParams(r *http.Request) templateParams {
 		ModifySystem: modifySystem,
 		Version:      config.Version,
 		Flashes:      session.Flashes(),
+		BasePath:     mid.BasePath(r),
 	}
 }
 
@@ -273,7 +396,22 @@ func (as *AdminServer) Settings(w http.ResponseWriter, r *http.Request) {
 			api.JSONResponse(w, msg, http.StatusBadRequest)
 			return
 		}
-		u.Hash = string(newHash)
+		err = u.CheckPasswordHistory(newPassword)
+		if err == auth.ErrPasswordInHistory {
+			msg.Message = err.Error()
+			msg.Success = false
+			api.JSONResponse(w, msg, http.StatusBadRequest)
+			return
+		}
+		if err == nil {
+			err = u.SetPasswordHash(newHash)
+		}
+		if err != nil {
+			msg.Message = err.Error()
+			msg.Success = false
+			api.JSONResponse(w, msg, http.StatusInternalServerError)
+			return
+		}
 		if err = models.PutUser(&u); err != nil {
 			msg.Message = err.Error()
 			msg.Success = false
@@ -308,17 +446,18 @@ func (as *AdminServer) handleInvalidLogin(w http.ResponseWriter, r *http.Request
 	session := ctx.Get(r, "session").(*sessions.Session)
 	Flash(w, r, "danger", message)
 	params := struct {
-		User    models.User
-		Title   string
-		Flashes []interface{}
-		Token   string
-	}{Title: "Login", Token: csrf.Token(r)}
+		User     models.User
+		Title    string
+		Flashes  []interface{}
+		Token    string
+		BasePath string
+	}{Title: "Login", Token: csrf.Token(r), BasePath: mid.BasePath(r)}
 	params.Flashes = session.Flashes()
 	session.Save(r, w)
 	templates := template.New("template")
 	_, err := templates.ParseFiles("templates/login.html", "templates/flashes.html")
 	if err != nil {
-		log.Error(err)
+		log.FromContext(r.Context()).Error(err)
 	}
 	// w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusUnauthorized)
@@ -339,12 +478,20 @@ func (as *AdminServer) Impersonate(w http.ResponseWriter, r *http.Request) {
 		username := r.FormValue("username")
 		u, err := models.GetUserByUsername(username)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 		session := ctx.Get(r, "session").(*sessions.Session)
-		session.Values["id"] = u.Id
+		err = mid.EndAdminSession(session)
+		if err == nil {
+			err = mid.StartAdminSession(r, session, u.Id)
+		}
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		session.Save(r, w)
 	}
 	http.Redirect(w, r, "/", http.StatusFound)
@@ -354,11 +501,12 @@ func (as *AdminServer) Impersonate(w http.ResponseWriter, r *http.Request) {
 // a session is created
 func (as *AdminServer) Login(w http.ResponseWriter, r *http.Request) {
 	params := struct {
-		User    models.User
-		Title   string
-		Flashes []interface{}
-		Token   string
-	}{Title: "Login", Token: csrf.Token(r)}
+		User     models.User
+		Title    string
+		Flashes  []interface{}
+		Token    string
+		BasePath string
+	}{Title: "Login", Token: csrf.Token(r), BasePath: mid.BasePath(r)}
 	session := ctx.Get(r, "session").(*sessions.Session)
 	switch {
 	case r.Method == "GET":
@@ -367,7 +515,7 @@ func (as *AdminServer) Login(w http.ResponseWriter, r *http.Request) {
 		templates := template.New("template")
 		_, err := templates.ParseFiles("templates/login.html", "templates/flashes.html")
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		template.Must(templates, err).ExecuteTemplate(w, "base", params)
 	case r.Method == "POST":
@@ -375,14 +523,14 @@ func (as *AdminServer) Login(w http.ResponseWriter, r *http.Request) {
 		username, password := r.FormValue("username"), r.FormValue("password")
 		u, err := models.GetUserByUsername(username)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			as.handleInvalidLogin(w, r, "Invalid Username/Password")
 			return
 		}
 		// Validate the user's password
 		err = auth.ValidatePassword(password, u.Hash)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 			as.handleInvalidLogin(w, r, "Invalid Username/Password")
 			return
 		}
@@ -393,10 +541,15 @@ func (as *AdminServer) Login(w http.ResponseWriter, r *http.Request) {
 		u.LastLogin = time.Now().UTC()
 		err = models.PutUser(&u)
 		if err != nil {
-			log.Error(err)
+			log.FromContext(r.Context()).Error(err)
 		}
 		// If we've logged in, save the session and redirect to the dashboard
-		session.Values["id"] = u.Id
+		err = mid.StartAdminSession(r, session, u.Id)
+		if err != nil {
+			log.FromContext(r.Context()).Error(err)
+			http.Error(w, "Error starting session", http.StatusInternalServerError)
+			return
+		}
 		session.Save(r, w)
 		as.nextOrIndex(w, r)
 	}
@@ -405,7 +558,10 @@ func (as *AdminServer) Login(w http.ResponseWriter, r *http.Request) {
 // Logout destroys the current user session
 func (as *AdminServer) Logout(w http.ResponseWriter, r *http.Request) {
 	session := ctx.Get(r, "session").(*sessions.Session)
-	delete(session.Values, "id")
+	err := mid.EndAdminSession(session)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+	}
 	Flash(w, r, "success", "You have successfully logged out")
 	session.Save(r, w)
 	http.Redirect(w, r, "/login", http.StatusFound)
@@ -426,7 +582,7 @@ func (as *AdminServer) Logout(w http.ResponseWriter, r *http.Request) {
 func (as *AdminServer) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	u := ctx.Get(r, "user").(models.User)
 	session := ctx.Get(r, "session").(*sessions.Session)
-	if !u.PasswordChangeRequired {
+	if !u.PasswordChangeRequired && !u.PasswordExpired() {
 		Flash(w, r, "info", "Please reset your password through the settings page")
 		session.Save(r, w)
 		http.Redirect(w, r, "/settings", http.StatusTemporaryRedirect)
@@ -444,6 +600,9 @@ func (as *AdminServer) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		newPassword := r.FormValue("password")
 		confirmPassword := r.FormValue("confirm_password")
 		newHash, err := auth.ValidatePasswordChange(u.Hash, newPassword, confirmPassword)
+		if err == nil {
+			err = u.CheckPasswordHistory(newPassword)
+		}
 		if err != nil {
 			Flash(w, r, "danger", err.Error())
 			params.Flashes = session.Flashes()
@@ -453,8 +612,11 @@ func (as *AdminServer) ResetPassword(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		u.PasswordChangeRequired = false
-		u.Hash = newHash
-		if err = models.PutUser(&u); err != nil {
+		err = u.SetPasswordHash(newHash)
+		if err == nil {
+			err = models.PutUser(&u)
+		}
+		if err != nil {
 			Flash(w, r, "danger", err.Error())
 			params.Flashes = session.Flashes()
 			session.Save(r, w)
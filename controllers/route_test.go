@@ -3,11 +3,13 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/gophish/gophish/config"
 )
 
 func attemptLogin(t *testing.T, ctx *testContext, client *http.Client, username, password, optionalPath string) *http.Response {
@@ -128,3 +130,51 @@ func TestAccountLocked(t *testing.T) {
 		t.Fatalf("invalid status code received. expected %d got %d", expected, got)
 	}
 }
+
+func TestBasePath(t *testing.T) {
+	ctx := setupTest(t)
+	defer tearDown(t, ctx)
+	as := NewAdminServer(config.AdminServer{BasePath: "/gophish"}, WithWorker(nil))
+	server := httptest.NewServer(as.server.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("%s/gophish/login", server.URL))
+	if err != nil {
+		t.Fatalf("error requesting the /gophish/login endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("invalid status code received. expected %d got %d", http.StatusOK, resp.StatusCode)
+	}
+	doc, err := goquery.NewDocumentFromResponse(resp)
+	if err != nil {
+		t.Fatalf("error parsing /gophish/login response body")
+	}
+	expected := "/gophish/css/dist/gophish.css"
+	if got, _ := doc.Find("link[rel='stylesheet']").First().Attr("href"); got != expected {
+		t.Fatalf("unexpected stylesheet path. expected %s got %s", expected, got)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+	resp, err = client.Get(fmt.Sprintf("%s/gophish/campaigns", server.URL))
+	if err != nil {
+		t.Fatalf("error requesting the /gophish/campaigns endpoint: %v", err)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("error parsing response Location header: %v", err)
+	}
+	if location.Path != "/gophish/login" || location.Query().Get("next") != "/campaigns" {
+		t.Fatalf("unexpected Location header received: %s", location)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s/login", server.URL))
+	if err != nil {
+		t.Fatalf("error requesting the /login endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("invalid status code received. expected %d got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
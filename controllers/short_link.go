@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"net/http"
+
+	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// handleShortLink redirects requests for a recipient's short link to their
+// full link, recording the request as its own event. The recipient's click
+// is then recorded when the full link is requested. It returns false if the
+// request isn't for a short link.
+func (ps *PhishingServer) handleShortLink(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	q := r.URL.Query()
+	if q.Get(models.RecipientParameter) != "" {
+		return false
+	}
+	rs, err := models.GetShortLinkResult(r.Host, r.URL.Path)
+	if err != nil {
+		return false
+	}
+	// The rest of the request is handled as if it had the recipient's id
+	q.Set(models.RecipientParameter, rs.RId)
+	r.URL.RawQuery = q.Encode()
+	r, err = setupContext(r)
+	if err != nil {
+		if err != ErrCampaignComplete {
+			log.FromContext(r.Context()).Error(err)
+		}
+		renderNotFound(w, r)
+		return true
+	}
+	rs = ctx.Get(r, "result").(models.Result)
+	c := ctx.Get(r, "campaign").(models.Campaign)
+	d := ctx.Get(r, "details").(models.EventDetails)
+	err = recordEvent(r, models.EventShortLink, rs.HandleShortLinkClicked, d)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+	}
+	ptx, err := models.NewPhishingTemplateContext(&c, rs.BaseRecipient, rs.RId)
+	if err != nil {
+		log.FromContext(r.Context()).Error(err)
+		http.NotFound(w, r)
+		return true
+	}
+	http.Redirect(w, r, ptx.LongURL, http.StatusFound)
+	return true
+}
@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `campaign_purges` (
+    `id` integer primary key auto_increment,
+    `campaign_id` integer,
+    `user_id` integer,
+    `reason` varchar(255),
+    `events_purged` integer,
+    `requests_purged` integer,
+    `purged_date` datetime
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `campaign_purges`;
@@ -0,0 +1,13 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `users` ADD COLUMN api_key_hash VARCHAR(255);
+CREATE INDEX `users_api_key_hash` ON `users` (`api_key_hash`);
+-- Encrypted values are larger than the plaintext they replace
+ALTER TABLE `events` MODIFY details MEDIUMBLOB;
+ALTER TABLE `smtp` MODIFY password TEXT;
+ALTER TABLE `imap` MODIFY password TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX `users_api_key_hash` ON `users`;
@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `templates` ADD COLUMN thread BOOLEAN DEFAULT 0;
+ALTER TABLE `templates` ADD COLUMN thread_from VARCHAR(255) DEFAULT '';
+ALTER TABLE `templates` ADD COLUMN thread_body TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
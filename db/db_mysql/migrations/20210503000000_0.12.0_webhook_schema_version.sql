@@ -0,0 +1,7 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `webhooks` ADD COLUMN schema_version INTEGER DEFAULT 1;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
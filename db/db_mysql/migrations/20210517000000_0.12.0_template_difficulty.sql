@@ -0,0 +1,8 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `templates` ADD COLUMN cue_count INTEGER DEFAULT 0;
+ALTER TABLE `templates` ADD COLUMN premise_alignment VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
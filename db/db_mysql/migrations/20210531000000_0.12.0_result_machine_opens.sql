@@ -0,0 +1,8 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `results` ADD COLUMN machine_opened BOOLEAN DEFAULT 0;
+ALTER TABLE `results` ADD COLUMN human_opened BOOLEAN DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
@@ -0,0 +1,13 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `imap` ADD COLUMN auth_method VARCHAR(255) DEFAULT 'password';
+ALTER TABLE `imap` ADD COLUMN oauth2_token_url VARCHAR(255) DEFAULT '';
+ALTER TABLE `imap` ADD COLUMN oauth2_device_url VARCHAR(255) DEFAULT '';
+ALTER TABLE `imap` ADD COLUMN oauth2_client_id VARCHAR(255) DEFAULT '';
+ALTER TABLE `imap` ADD COLUMN oauth2_client_secret TEXT;
+ALTER TABLE `imap` ADD COLUMN oauth2_scope VARCHAR(255) DEFAULT '';
+ALTER TABLE `imap` ADD COLUMN oauth2_refresh_token TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
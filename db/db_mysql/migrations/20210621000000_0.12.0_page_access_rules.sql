@@ -0,0 +1,15 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `page_access_rules` (
+    `id` integer primary key auto_increment,
+    `page_id` integer,
+    `field` varchar(255),
+    `pattern` varchar(255),
+    `action` varchar(255)
+);
+ALTER TABLE `pages` ADD COLUMN decoy_html TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `page_access_rules`;
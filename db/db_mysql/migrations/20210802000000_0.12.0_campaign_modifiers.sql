@@ -0,0 +1,14 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `campaign_modifiers` (
+    `id` integer primary key auto_increment,
+    `campaign_id` integer,
+    `name` varchar(255),
+    `options` TEXT
+);
+CREATE INDEX `campaign_modifiers_campaign_id` ON `campaign_modifiers` (`campaign_id`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `campaign_modifiers`;
@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `webhooks` ADD COLUMN `key_id` VARCHAR(255) DEFAULT '';
+CREATE TABLE IF NOT EXISTS `webhook_secrets` (
+    `id` integer primary key auto_increment,
+    `webhook_id` integer,
+    `key_id` varchar(255),
+    `secret` varchar(255),
+    `expires_date` datetime
+);
+CREATE INDEX `webhook_secrets_webhook_id` ON `webhook_secrets` (`webhook_id`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `webhook_secrets`;
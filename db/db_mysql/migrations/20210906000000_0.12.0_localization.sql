@@ -0,0 +1,28 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `targets` ADD COLUMN `language` VARCHAR(255) DEFAULT '';
+ALTER TABLE `results` ADD COLUMN `language` VARCHAR(255) DEFAULT '';
+ALTER TABLE `email_requests` ADD COLUMN `language` VARCHAR(255) DEFAULT '';
+CREATE TABLE IF NOT EXISTS `template_translations` (
+    `id` integer primary key auto_increment,
+    `template_id` integer,
+    `language` varchar(255),
+    `subject` varchar(255),
+    `text` TEXT,
+    `html` TEXT
+);
+CREATE INDEX `template_translations_template_id` ON `template_translations` (`template_id`);
+CREATE TABLE IF NOT EXISTS `page_translations` (
+    `id` integer primary key auto_increment,
+    `page_id` integer,
+    `language` varchar(255),
+    `html` TEXT,
+    `redirect_url` varchar(255)
+);
+CREATE INDEX `page_translations_page_id` ON `page_translations` (`page_id`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `template_translations`;
+DROP TABLE `page_translations`;
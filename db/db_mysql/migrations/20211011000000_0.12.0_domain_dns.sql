@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `domains` ADD COLUMN dns_provider VARCHAR(255);
+ALTER TABLE `domains` ADD COLUMN dns_zone VARCHAR(255);
+ALTER TABLE `domains` ADD COLUMN ip VARCHAR(255);
+ALTER TABLE `domains` ADD COLUMN smtp_id BIGINT;
+ALTER TABLE `domains` ADD COLUMN dkim_selector VARCHAR(255);
+ALTER TABLE `domains` ADD COLUMN dkim_public_key TEXT;
+ALTER TABLE `domains` ADD COLUMN dmarc_policy VARCHAR(255);
+ALTER TABLE `domains` ADD COLUMN dns_records TEXT;
+ALTER TABLE `domains` ADD COLUMN dns_provisioned_date DATETIME;
+ALTER TABLE `domains` ADD COLUMN dns_error TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
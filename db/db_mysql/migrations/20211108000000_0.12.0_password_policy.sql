@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `users` ADD COLUMN password_changed_date datetime;
+UPDATE `users` SET password_changed_date = UTC_TIMESTAMP();
+CREATE TABLE IF NOT EXISTS `password_history` (
+    `id` integer primary key auto_increment,
+    `user_id` bigint,
+    `hash` varchar(255),
+    `created_date` datetime
+);
+CREATE INDEX `password_history_user_id` ON `password_history` (`user_id`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `password_history`;
@@ -0,0 +1,23 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `incident_connectors` (
+    `id` integer primary key auto_increment,
+    `user_id` bigint,
+    `name` varchar(255),
+    `type` varchar(255),
+    `url` varchar(255),
+    `api_key` text,
+    `severity` integer,
+    `tlp` integer,
+    `tags` varchar(255),
+    `simulations` boolean,
+    `real_phish` boolean,
+    `enabled` boolean,
+    `created_date` datetime,
+    `modified_date` datetime
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `incident_connectors`;
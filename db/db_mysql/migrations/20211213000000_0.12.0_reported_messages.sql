@@ -0,0 +1,26 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS `reported_messages` (
+    `id` integer primary key auto_increment,
+    `user_id` bigint,
+    `reporter` varchar(255),
+    `from_address` varchar(255),
+    `subject` text,
+    `message_id` varchar(255),
+    `sent_date` datetime,
+    `reported_date` datetime,
+    `headers` text,
+    `classification` varchar(255),
+    `score` integer,
+    `reasons` text,
+    `observables` text,
+    `status` varchar(255),
+    `notes` text,
+    `modified_date` datetime
+);
+CREATE INDEX `reported_messages_user_id` ON `reported_messages` (`user_id`, `reported_date`);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE `reported_messages`;
@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE `groups` ADD COLUMN `default_smtp_id` bigint;
+ALTER TABLE `groups` ADD COLUMN `default_page_id` bigint;
+ALTER TABLE `groups` ADD COLUMN `default_language` varchar(255);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
@@ -0,0 +1,22 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "event_requests" (
+    "id" integer primary key autoincrement,
+    "campaign_id" integer,
+    "event_id" integer,
+    "email" varchar(255),
+    "method" varchar(16),
+    "url" text,
+    "host" varchar(255),
+    "proto" varchar(16),
+    "headers" text,
+    "body" text,
+    "truncated" boolean default 0,
+    "created_date" datetime
+);
+CREATE INDEX IF NOT EXISTS "event_requests_campaign_id" ON "event_requests" ("campaign_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "event_requests";
@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "users" ADD COLUMN api_key_hash VARCHAR(255);
+CREATE INDEX IF NOT EXISTS "users_api_key_hash" ON "users" ("api_key_hash");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS "users_api_key_hash";
@@ -0,0 +1,18 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "templates" ADD COLUMN external_id VARCHAR(255) DEFAULT '';
+ALTER TABLE "pages" ADD COLUMN external_id VARCHAR(255) DEFAULT '';
+ALTER TABLE "smtp" ADD COLUMN external_id VARCHAR(255) DEFAULT '';
+ALTER TABLE "groups" ADD COLUMN external_id VARCHAR(255) DEFAULT '';
+CREATE INDEX "templates_external_id" ON "templates" ("user_id", "external_id");
+CREATE INDEX "pages_external_id" ON "pages" ("user_id", "external_id");
+CREATE INDEX "smtp_external_id" ON "smtp" ("user_id", "external_id");
+CREATE INDEX "groups_external_id" ON "groups" ("user_id", "external_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX "templates_external_id";
+DROP INDEX "pages_external_id";
+DROP INDEX "smtp_external_id";
+DROP INDEX "groups_external_id";
@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "attachments" ADD COLUMN storage_key VARCHAR(255) DEFAULT '';
+CREATE INDEX "attachments_storage_key" ON "attachments" ("storage_key");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX "attachments_storage_key";
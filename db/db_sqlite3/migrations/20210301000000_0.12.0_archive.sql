@@ -0,0 +1,24 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE INDEX "results_campaign_id" ON "results" ("campaign_id");
+CREATE INDEX "results_user_id" ON "results" ("user_id");
+CREATE INDEX "results_r_id" ON "results" ("r_id");
+CREATE INDEX "events_campaign_id" ON "events" ("campaign_id", "id");
+CREATE INDEX "events_time" ON "events" ("time");
+CREATE INDEX "mail_logs_campaign_id" ON "mail_logs" ("campaign_id");
+CREATE INDEX "mail_logs_send_date" ON "mail_logs" ("send_date");
+CREATE TABLE IF NOT EXISTS "archived_events" ("id" integer primary key,"campaign_id" bigint,"email" varchar(255),"time" datetime,"message" varchar(255),"details" BLOB );
+CREATE INDEX "archived_events_campaign_id" ON "archived_events" ("campaign_id", "id");
+ALTER TABLE "campaigns" ADD COLUMN archived_date DATETIME;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX "results_campaign_id";
+DROP INDEX "results_user_id";
+DROP INDEX "results_r_id";
+DROP INDEX "events_campaign_id";
+DROP INDEX "events_time";
+DROP INDEX "mail_logs_campaign_id";
+DROP INDEX "mail_logs_send_date";
+DROP TABLE "archived_events";
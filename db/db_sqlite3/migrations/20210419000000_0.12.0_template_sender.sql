@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "templates" ADD COLUMN from_name VARCHAR(255) DEFAULT '';
+ALTER TABLE "templates" ADD COLUMN envelope_sender VARCHAR(255) DEFAULT '';
+ALTER TABLE "templates" ADD COLUMN reply_to VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
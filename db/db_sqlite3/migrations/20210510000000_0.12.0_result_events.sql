@@ -0,0 +1,10 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "results" ADD COLUMN forwarded BOOLEAN DEFAULT 0;
+ALTER TABLE "results" ADD COLUMN auto_replied BOOLEAN DEFAULT 0;
+ALTER TABLE "results" ADD COLUMN unsubscribed BOOLEAN DEFAULT 0;
+ALTER TABLE "imap" ADD COLUMN forward_addresses VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
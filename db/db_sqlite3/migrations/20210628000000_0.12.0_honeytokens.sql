@@ -0,0 +1,29 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "honeytokens" (
+    "id" integer primary key autoincrement,
+    "campaign_id" integer,
+    "user_id" integer,
+    "username" varchar(255),
+    "password" TEXT,
+    "created_date" datetime
+);
+CREATE INDEX "honeytokens_user_id" ON "honeytokens" ("user_id", "username");
+CREATE TABLE IF NOT EXISTS "honeytoken_sightings" (
+    "id" integer primary key autoincrement,
+    "honeytoken_id" integer,
+    "campaign_id" integer,
+    "user_id" integer,
+    "username" varchar(255),
+    "source" varchar(255),
+    "password_matched" boolean,
+    "address" varchar(255),
+    "details" TEXT,
+    "seen_date" datetime
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "honeytoken_sightings";
+DROP TABLE "honeytokens";
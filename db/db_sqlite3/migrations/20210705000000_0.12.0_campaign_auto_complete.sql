@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "campaigns" ADD COLUMN auto_complete_days INTEGER DEFAULT 0;
+ALTER TABLE "campaigns" ADD COLUMN auto_complete_quiet_hours INTEGER DEFAULT 0;
+ALTER TABLE "campaigns" ADD COLUMN auto_complete_click_percent INTEGER DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
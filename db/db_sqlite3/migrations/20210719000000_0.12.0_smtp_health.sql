@@ -0,0 +1,19 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "smtp" ADD COLUMN health_check_address VARCHAR(255) DEFAULT '';
+CREATE TABLE IF NOT EXISTS "smtp_health" (
+    "id" integer primary key autoincrement,
+    "smtp_id" integer,
+    "user_id" integer,
+    "status" varchar(255),
+    "consecutive_failures" integer DEFAULT 0,
+    "last_error" TEXT,
+    "last_checked_date" datetime,
+    "tripped_date" datetime
+);
+CREATE UNIQUE INDEX "smtp_health_smtp_id" ON "smtp_health" ("smtp_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "smtp_health";
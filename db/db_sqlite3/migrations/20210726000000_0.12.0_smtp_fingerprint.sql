@@ -0,0 +1,11 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "smtp" ADD COLUMN message_id_format VARCHAR(255) DEFAULT '';
+ALTER TABLE "smtp" ADD COLUMN message_id_domain VARCHAR(255) DEFAULT '';
+ALTER TABLE "smtp" ADD COLUMN remove_mailer_header BOOLEAN DEFAULT 0;
+ALTER TABLE "smtp" ADD COLUMN boundary_prefix VARCHAR(255) DEFAULT '';
+ALTER TABLE "smtp" ADD COLUMN header_order VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
@@ -0,0 +1,8 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "events" ADD COLUMN tags VARCHAR(255) DEFAULT '';
+ALTER TABLE "archived_events" ADD COLUMN tags VARCHAR(255) DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
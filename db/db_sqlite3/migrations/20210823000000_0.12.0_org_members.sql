@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "org_members" (
+    "id" integer primary key autoincrement,
+    "user_id" integer,
+    "email" varchar(255),
+    "department" varchar(255),
+    "manager_email" varchar(255),
+    "modified_date" datetime
+);
+CREATE INDEX "org_members_user_id" ON "org_members" ("user_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "org_members";
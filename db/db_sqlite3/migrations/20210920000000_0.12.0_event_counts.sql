@@ -0,0 +1,13 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "results" ADD COLUMN click_count INTEGER DEFAULT 0;
+ALTER TABLE "results" ADD COLUMN first_click_date datetime;
+ALTER TABLE "results" ADD COLUMN last_click_date datetime;
+ALTER TABLE "results" ADD COLUMN submit_count INTEGER DEFAULT 0;
+ALTER TABLE "results" ADD COLUMN first_submit_date datetime;
+ALTER TABLE "results" ADD COLUMN last_submit_date datetime;
+ALTER TABLE "campaigns" ADD COLUMN max_duplicate_events INTEGER DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
@@ -0,0 +1,35 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "notification_rules" (
+    "id" integer primary key autoincrement,
+    "user_id" bigint,
+    "name" varchar(255),
+    "campaign_id" bigint,
+    "trigger_type" varchar(255),
+    "event" varchar(255),
+    "tag" varchar(255),
+    "threshold" real,
+    "channel" varchar(255),
+    "target" varchar(255),
+    "smtp_id" bigint,
+    "enabled" boolean,
+    "created_date" datetime,
+    "modified_date" datetime
+);
+CREATE INDEX "notification_rules_user_id" ON "notification_rules" ("user_id");
+CREATE TABLE IF NOT EXISTS "notification_logs" (
+    "id" integer primary key autoincrement,
+    "rule_id" bigint,
+    "campaign_id" bigint,
+    "email" varchar(255),
+    "message" TEXT,
+    "error" TEXT,
+    "sent_date" datetime
+);
+CREATE INDEX "notification_logs_rule_id" ON "notification_logs" ("rule_id", "campaign_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "notification_rules";
+DROP TABLE "notification_logs";
@@ -0,0 +1,31 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "domains" (
+    "id" integer primary key autoincrement,
+    "user_id" bigint,
+    "name" varchar(255),
+    "registrar" varchar(255),
+    "expiry_date" datetime,
+    "notes" TEXT,
+    "dns_status" varchar(255),
+    "deliverability_score" integer,
+    "blocklisted" boolean,
+    "categories" TEXT,
+    "malicious" integer,
+    "suspicious" integer,
+    "burned" boolean,
+    "burned_date" datetime,
+    "certificate_issuer" varchar(255),
+    "certificate_expiry" datetime,
+    "certificate_valid" boolean,
+    "check_error" TEXT,
+    "last_checked_date" datetime,
+    "created_date" datetime,
+    "modified_date" datetime
+);
+CREATE INDEX "domains_user_id" ON "domains" ("user_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "domains";
@@ -0,0 +1,18 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "admin_sessions" (
+    "id" integer primary key autoincrement,
+    "user_id" bigint,
+    "token_hash" varchar(255),
+    "ip" varchar(255),
+    "user_agent" text,
+    "created_date" datetime,
+    "last_activity" datetime
+);
+CREATE UNIQUE INDEX "admin_sessions_token_hash" ON "admin_sessions" ("token_hash");
+CREATE INDEX "admin_sessions_user_id" ON "admin_sessions" ("user_id");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "admin_sessions";
@@ -0,0 +1,16 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "embed_tokens" (
+    "id" integer primary key autoincrement,
+    "user_id" bigint,
+    "name" varchar(255),
+    "token_hash" varchar(255),
+    "created_date" datetime,
+    "last_used" datetime
+);
+CREATE UNIQUE INDEX "embed_tokens_token_hash" ON "embed_tokens" ("token_hash");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "embed_tokens";
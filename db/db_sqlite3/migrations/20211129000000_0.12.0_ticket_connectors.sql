@@ -0,0 +1,38 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "ticket_connectors" (
+    "id" integer primary key autoincrement,
+    "user_id" bigint,
+    "name" varchar(255),
+    "type" varchar(255),
+    "url" varchar(255),
+    "username" varchar(255),
+    "secret" text,
+    "project" varchar(255),
+    "issue_type" varchar(255),
+    "campaign_id" bigint,
+    "event" varchar(255),
+    "tag" varchar(255),
+    "fields" text,
+    "enabled" boolean,
+    "created_date" datetime,
+    "modified_date" datetime
+);
+CREATE TABLE IF NOT EXISTS "ticket_logs" (
+    "id" integer primary key autoincrement,
+    "connector_id" bigint,
+    "campaign_id" bigint,
+    "event_id" bigint,
+    "email" varchar(255),
+    "ticket_id" varchar(255),
+    "ticket_url" text,
+    "error" text,
+    "created_date" datetime
+);
+CREATE INDEX "ticket_logs_connector_id" ON "ticket_logs" ("connector_id", "campaign_id", "email");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "ticket_logs";
+DROP TABLE "ticket_connectors";
@@ -0,0 +1,11 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "targets" ADD COLUMN "tags" VARCHAR(255) DEFAULT '';
+ALTER TABLE "results" ADD COLUMN "target_tags" VARCHAR(255) DEFAULT '';
+ALTER TABLE "campaigns" ADD COLUMN "include_tags" VARCHAR(255) DEFAULT '';
+ALTER TABLE "campaigns" ADD COLUMN "exclude_tags" VARCHAR(255) DEFAULT '';
+ALTER TABLE "campaigns" ADD COLUMN "approved_by" bigint;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
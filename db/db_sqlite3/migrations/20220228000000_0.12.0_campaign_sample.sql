@@ -0,0 +1,9 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "campaigns" ADD COLUMN "sample_percent" integer DEFAULT 0;
+ALTER TABLE "campaigns" ADD COLUMN "sample_seed" bigint DEFAULT 0;
+ALTER TABLE "campaigns" ADD COLUMN "sample_population" integer DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
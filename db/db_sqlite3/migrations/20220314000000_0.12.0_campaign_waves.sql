@@ -0,0 +1,21 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "campaign_waves" (
+    "id" integer primary key autoincrement,
+    "campaign_id" integer,
+    "name" varchar(255),
+    "template_id" integer,
+    "launch_date" datetime,
+    "send_by_date" datetime,
+    "percent" integer DEFAULT 0,
+    "non_clickers_of" varchar(255) DEFAULT '',
+    "launched" boolean DEFAULT 0
+);
+CREATE INDEX "campaign_waves_campaign_id" ON "campaign_waves" ("campaign_id");
+ALTER TABLE "results" ADD COLUMN "wave" varchar(255) DEFAULT '';
+ALTER TABLE "mail_logs" ADD COLUMN "template_id" integer DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "campaign_waves";
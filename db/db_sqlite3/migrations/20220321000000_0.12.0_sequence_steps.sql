@@ -0,0 +1,18 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "sequence_steps" (
+    "id" integer primary key autoincrement,
+    "campaign_id" integer,
+    "step" integer,
+    "template_id" integer,
+    "delay_days" integer,
+    "send_condition" varchar(255)
+);
+CREATE INDEX "sequence_steps_campaign_id" ON "sequence_steps" ("campaign_id");
+ALTER TABLE "results" ADD COLUMN "sequence_r_id" varchar(255) DEFAULT '';
+ALTER TABLE "results" ADD COLUMN "sequence_step" integer DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "sequence_steps";
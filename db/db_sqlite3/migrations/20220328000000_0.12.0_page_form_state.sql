@@ -0,0 +1,10 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE "pages" ADD COLUMN "form_steps" integer DEFAULT 0;
+ALTER TABLE "pages" ADD COLUMN "reject_first_submit" boolean DEFAULT 0;
+ALTER TABLE "pages" ADD COLUMN "submit_error" text;
+ALTER TABLE "pages" ADD COLUMN "submit_delay" integer DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
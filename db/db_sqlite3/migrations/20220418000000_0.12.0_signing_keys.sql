@@ -0,0 +1,14 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS "signing_keys" (
+    "id" integer primary key autoincrement,
+    "name" varchar(255),
+    "value" text,
+    "created_date" datetime
+);
+CREATE UNIQUE INDEX "signing_keys_name" ON "signing_keys" ("name");
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE "signing_keys";
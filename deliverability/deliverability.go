@@ -0,0 +1,357 @@
+package deliverability
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+// The possible outcomes of a check. Skipped checks couldn't be run, such as
+// blocklist lookups for a private address, and don't affect the score.
+const (
+	StatusPass    Status = "pass"
+	StatusWarn    Status = "warn"
+	StatusFail    Status = "fail"
+	StatusSkipped Status = "skipped"
+)
+
+// DefaultBlocklists are the DNS-based blocklists checked for the sending
+// addresses.
+var DefaultBlocklists = []string{
+	"zen.spamhaus.org",
+	"bl.spamcop.net",
+	"b.barracudacentral.org",
+}
+
+// DefaultDomainBlocklists are the DNS-based blocklists checked for the
+// sending domain.
+var DefaultDomainBlocklists = []string{
+	"dbl.spamhaus.org",
+}
+
+// DefaultDKIMSelectors are the DKIM selectors looked up when none are given.
+// Since there's no way to list the selectors published for a domain, these
+// are the ones commonly used by mail providers.
+var DefaultDKIMSelectors = []string{
+	"default", "dkim", "google", "k1", "mail", "s1", "s2", "selector1", "selector2",
+}
+
+// Resolver performs the DNS lookups needed for the checks. It's implemented
+// by net.Resolver.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// Check is the result of a single check.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	// Weight is how many points are deducted from the score when the check
+	// fails. Half are deducted for a warning.
+	Weight int `json:"weight"`
+}
+
+// Report is the result of checking a sending domain and its addresses.
+type Report struct {
+	Domain string   `json:"domain"`
+	IPs    []string `json:"ips"`
+	// Score estimates deliverability from 0 to 100, where 100 means that
+	// every check passed.
+	Score  int     `json:"score"`
+	Checks []Check `json:"checks"`
+}
+
+// Add adds the result of a check to the report, updating the score.
+func (r *Report) Add(c Check) {
+	r.Checks = append(r.Checks, c)
+	r.Score = 100
+	for _, c := range r.Checks {
+		switch c.Status {
+		case StatusFail:
+			r.Score -= c.Weight
+		case StatusWarn:
+			r.Score -= c.Weight / 2
+		}
+	}
+	if r.Score < 0 {
+		r.Score = 0
+	}
+}
+
+// Checker runs deliverability checks.
+type Checker struct {
+	Resolver         Resolver
+	Blocklists       []string
+	DomainBlocklists []string
+}
+
+// NewChecker returns a Checker using the system resolver and the default
+// blocklists.
+func NewChecker() *Checker {
+	return &Checker{
+		Resolver:         net.DefaultResolver,
+		Blocklists:       DefaultBlocklists,
+		DomainBlocklists: DefaultDomainBlocklists,
+	}
+}
+
+// Check checks the DNS configuration of the sending domain, along with the
+// addresses emails are sent from. If no DKIM selectors are given, the
+// DefaultDKIMSelectors are looked up.
+func (c *Checker) Check(ctx context.Context, domain string, ips []net.IP, selectors []string) *Report {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	r := &Report{Domain: domain, IPs: []string{}, Score: 100, Checks: []Check{}}
+	for _, ip := range ips {
+		r.IPs = append(r.IPs, ip.String())
+	}
+	spfAligned := c.addSPFChecks(ctx, r, domain, ips)
+	dkimFound := c.addDKIMCheck(ctx, r, domain, selectors)
+	c.addDMARCCheck(ctx, r, domain, spfAligned, dkimFound)
+	for _, ip := range ips {
+		c.addReverseDNSCheck(ctx, r, ip)
+		c.addBlocklistChecks(ctx, r, ip)
+	}
+	c.addDomainBlocklistChecks(ctx, r, domain)
+	return r
+}
+
+// addSPFChecks checks that the domain publishes an SPF record which allows
+// each of the addresses, returning whether they all pass.
+func (c *Checker) addSPFChecks(ctx context.Context, r *Report, domain string, ips []net.IP) bool {
+	record, err := c.spfRecord(ctx, domain)
+	switch {
+	case err != nil:
+		r.Add(Check{Name: "spf", Status: StatusFail, Weight: 20, Message: fmt.Sprintf("Unable to look up the SPF record: %v", err)})
+		return false
+	case record == "":
+		r.Add(Check{Name: "spf", Status: StatusFail, Weight: 20, Message: "No SPF record is published for " + domain})
+		return false
+	}
+	if len(ips) == 0 {
+		r.Add(Check{Name: "spf", Status: StatusPass, Weight: 20, Message: "An SPF record is published: " + record})
+		return false
+	}
+	passed := true
+	for _, ip := range ips {
+		result, msg := c.checkSPF(ctx, ip, domain)
+		check := Check{Name: "spf", Weight: 20}
+		switch result {
+		case SPFPass:
+			check.Status = StatusPass
+			check.Message = fmt.Sprintf("The SPF record allows %s", ip)
+		case SPFSoftFail, SPFNeutral, SPFTempError:
+			check.Status = StatusWarn
+			check.Message = fmt.Sprintf("SPF %s for %s: %s", result, ip, msg)
+		default:
+			check.Status = StatusFail
+			check.Message = fmt.Sprintf("SPF %s for %s: %s", result, ip, msg)
+		}
+		if result != SPFPass {
+			passed = false
+		}
+		r.Add(check)
+	}
+	return passed
+}
+
+// addDKIMCheck looks for a DKIM key published for the domain, returning
+// whether one was found.
+func (c *Checker) addDKIMCheck(ctx context.Context, r *Report, domain string, selectors []string) bool {
+	if len(selectors) == 0 {
+		selectors = DefaultDKIMSelectors
+	}
+	found := []string{}
+	for _, selector := range selectors {
+		txts, err := c.Resolver.LookupTXT(ctx, selector+"._domainkey."+domain)
+		if err != nil {
+			continue
+		}
+		for _, txt := range txts {
+			// Revoked keys are published with an empty p= tag
+			if tagValue(txt, "p") != "" {
+				found = append(found, selector)
+				break
+			}
+		}
+	}
+	if len(found) == 0 {
+		r.Add(Check{Name: "dkim", Status: StatusWarn, Weight: 20, Message: fmt.Sprintf("No DKIM key was found for the selectors %s", strings.Join(selectors, ", "))})
+		return false
+	}
+	r.Add(Check{Name: "dkim", Status: StatusPass, Weight: 20, Message: fmt.Sprintf("DKIM keys are published for the selectors %s. Emails are only aligned if the sending server signs them using one of these keys.", strings.Join(found, ", "))})
+	return true
+}
+
+// addDMARCCheck checks the DMARC policy of the domain, and whether emails are
+// likely to be aligned with it.
+func (c *Checker) addDMARCCheck(ctx context.Context, r *Report, domain string, spfAligned, dkimFound bool) {
+	record, policyDomain := c.dmarcRecord(ctx, domain)
+	if record == "" {
+		r.Add(Check{Name: "dmarc", Status: StatusWarn, Weight: 15, Message: "No DMARC record is published for " + domain})
+		return
+	}
+	policy := tagValue(record, "p")
+	if policyDomain != domain {
+		if sp := tagValue(record, "sp"); sp != "" {
+			policy = sp
+		}
+	}
+	switch {
+	case spfAligned:
+		r.Add(Check{Name: "dmarc", Status: StatusPass, Weight: 15, Message: fmt.Sprintf("The DMARC policy of %s is %q, and SPF is aligned", policyDomain, policy)})
+	case policy == "none" || policy == "":
+		r.Add(Check{Name: "dmarc", Status: StatusPass, Weight: 15, Message: fmt.Sprintf("The DMARC policy of %s is \"none\", so unaligned emails aren't rejected", policyDomain)})
+	case dkimFound:
+		r.Add(Check{Name: "dmarc", Status: StatusWarn, Weight: 15, Message: fmt.Sprintf("The DMARC policy of %s is %q. SPF isn't aligned, so emails need to be signed with the domain's DKIM key.", policyDomain, policy)})
+	default:
+		r.Add(Check{Name: "dmarc", Status: StatusFail, Weight: 15, Message: fmt.Sprintf("The DMARC policy of %s is %q, but neither SPF nor DKIM is aligned", policyDomain, policy)})
+	}
+}
+
+// dmarcRecord returns the DMARC record for the domain, falling back to the
+// record of its parent domain, along with the domain the record was found
+// for. The parent is assumed to be the last two labels, since checking the
+// public suffix list isn't supported.
+func (c *Checker) dmarcRecord(ctx context.Context, domain string) (string, string) {
+	domains := []string{domain}
+	labels := strings.Split(domain, ".")
+	if len(labels) > 2 {
+		domains = append(domains, strings.Join(labels[len(labels)-2:], "."))
+	}
+	for _, d := range domains {
+		txts, err := c.Resolver.LookupTXT(ctx, "_dmarc."+d)
+		if err != nil {
+			continue
+		}
+		for _, txt := range txts {
+			if strings.HasPrefix(strings.ToLower(txt), "v=dmarc1") {
+				return txt, d
+			}
+		}
+	}
+	return "", ""
+}
+
+// addReverseDNSCheck checks that the address has a PTR record which resolves
+// back to the address.
+func (c *Checker) addReverseDNSCheck(ctx context.Context, r *Report, ip net.IP) {
+	if isPrivate(ip) {
+		r.Add(Check{Name: "rdns", Status: StatusSkipped, Weight: 10, Message: fmt.Sprintf("%s is a private address", ip)})
+		return
+	}
+	names, err := c.Resolver.LookupAddr(ctx, ip.String())
+	if err != nil || len(names) == 0 {
+		r.Add(Check{Name: "rdns", Status: StatusFail, Weight: 10, Message: fmt.Sprintf("%s has no reverse DNS record", ip)})
+		return
+	}
+	for _, name := range names {
+		addrs, err := c.Resolver.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if net.ParseIP(addr).Equal(ip) {
+				r.Add(Check{Name: "rdns", Status: StatusPass, Weight: 10, Message: fmt.Sprintf("%s resolves to %s", ip, name)})
+				return
+			}
+		}
+	}
+	r.Add(Check{Name: "rdns", Status: StatusWarn, Weight: 10, Message: fmt.Sprintf("%s resolves to %s, which doesn't resolve back to %s", ip, strings.Join(names, ", "), ip)})
+}
+
+// addBlocklistChecks checks whether the address is listed by each of the
+// blocklists.
+func (c *Checker) addBlocklistChecks(ctx context.Context, r *Report, ip net.IP) {
+	if isPrivate(ip) {
+		return
+	}
+	reversed := reverseIP(ip)
+	for _, bl := range c.Blocklists {
+		c.addListingCheck(ctx, r, reversed+"."+bl, ip.String(), bl)
+	}
+}
+
+// addDomainBlocklistChecks checks whether the domain is listed by each of the
+// domain blocklists.
+func (c *Checker) addDomainBlocklistChecks(ctx context.Context, r *Report, domain string) {
+	for _, bl := range c.DomainBlocklists {
+		c.addListingCheck(ctx, r, domain+"."+bl, domain, bl)
+	}
+}
+
+func (c *Checker) addListingCheck(ctx context.Context, r *Report, query, listed, bl string) {
+	addrs, err := c.Resolver.LookupHost(ctx, query)
+	if err != nil {
+		if isNotFound(err) {
+			r.Add(Check{Name: "blocklist", Status: StatusPass, Weight: 25, Message: fmt.Sprintf("%s isn't listed by %s", listed, bl)})
+		} else {
+			r.Add(Check{Name: "blocklist", Status: StatusSkipped, Weight: 25, Message: fmt.Sprintf("Unable to query %s: %v", bl, err)})
+		}
+		return
+	}
+	for _, addr := range addrs {
+		// Spamhaus returns addresses in 127.255.255.0/24 when it refuses to
+		// answer a query, such as those made through public resolvers.
+		if strings.HasPrefix(addr, "127.255.255.") {
+			r.Add(Check{Name: "blocklist", Status: StatusSkipped, Weight: 25, Message: fmt.Sprintf("%s refused the query (%s)", bl, addr)})
+			return
+		}
+	}
+	r.Add(Check{Name: "blocklist", Status: StatusFail, Weight: 25, Message: fmt.Sprintf("%s is listed by %s (%s)", listed, bl, strings.Join(addrs, ", "))})
+}
+
+// reverseIP returns the address in the form used for DNS-based blocklist
+// queries, such as 4.3.2.1 for 1.2.3.4.
+func reverseIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	ip = ip.To16()
+	nibbles := make([]string, 0, 32)
+	for i := len(ip) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x", ip[i]&0x0f), fmt.Sprintf("%x", ip[i]>>4))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// tagValue returns the value of a tag in a DKIM or DMARC record, such as
+// "reject" for "p" in "v=DMARC1; p=reject".
+func tagValue(record, tag string) string {
+	for _, part := range strings.Split(record, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), tag) {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// isPrivate returns whether the address can't be reached from the internet,
+// so that blocklist and reverse DNS checks don't apply.
+func isPrivate(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, network, _ := net.ParseCIDR(cidr)
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound returns whether the error means the name doesn't exist, rather
+// than the lookup failing.
+func isNotFound(err error) bool {
+	de, ok := err.(*net.DNSError)
+	return ok && de.IsNotFound
+}
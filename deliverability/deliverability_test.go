@@ -0,0 +1,174 @@
+package deliverability
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// mockResolver answers lookups from static records.
+type mockResolver struct {
+	txt  map[string][]string
+	host map[string][]string
+	addr map[string][]string
+	mx   map[string][]*net.MX
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (m *mockResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txts, ok := m.txt[name]; ok {
+		return txts, nil
+	}
+	return nil, notFound(name)
+}
+
+func (m *mockResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := m.host[host]; ok {
+		return addrs, nil
+	}
+	return nil, notFound(host)
+}
+
+func (m *mockResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if names, ok := m.addr[addr]; ok {
+		return names, nil
+	}
+	return nil, notFound(addr)
+}
+
+func (m *mockResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mxs, ok := m.mx[name]; ok {
+		return mxs, nil
+	}
+	return nil, notFound(name)
+}
+
+func newMockChecker() (*Checker, *mockResolver) {
+	r := &mockResolver{
+		txt: map[string][]string{
+			"example.com":                      {"google-site-verification=abc", "v=spf1 mx include:_spf.example.net ~all"},
+			"_spf.example.net":                 {"v=spf1 ip4:203.0.113.0/24 ip6:2001:db8::/32 -all"},
+			"selector1._domainkey.example.com": {"v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQC"},
+			"_dmarc.example.com":               {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+			"loop.example.com":                 {"v=spf1 include:loop.example.com -all"},
+		},
+		host: map[string][]string{
+			"mail.example.com":                  {"198.51.100.10"},
+			"10.100.51.198.zen.spamhaus.org":    {"127.0.0.2"},
+			"mail.example.com.dbl.spamhaus.org": {"127.0.1.2"},
+		},
+		addr: map[string][]string{
+			"198.51.100.10": {"mail.example.com."},
+			"203.0.113.5":   {"unknown.example.net."},
+		},
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mail.example.com.", Pref: 10}},
+		},
+	}
+	r.host["mail.example.com."] = r.host["mail.example.com"]
+	c := &Checker{
+		Resolver:         r,
+		Blocklists:       []string{"zen.spamhaus.org"},
+		DomainBlocklists: []string{"dbl.spamhaus.org"},
+	}
+	return c, r
+}
+
+func TestCheckSPF(t *testing.T) {
+	c, _ := newMockChecker()
+	tests := []struct {
+		ip       string
+		domain   string
+		expected string
+	}{
+		{"198.51.100.10", "example.com", SPFPass},
+		{"203.0.113.5", "example.com", SPFPass},
+		{"2001:db8::1", "example.com", SPFPass},
+		{"192.0.2.1", "example.com", SPFSoftFail},
+		{"192.0.2.1", "_spf.example.net", SPFFail},
+		{"192.0.2.1", "example.org", SPFNone},
+		{"192.0.2.1", "loop.example.com", SPFPermError},
+	}
+	for _, test := range tests {
+		got, msg := c.checkSPF(context.Background(), net.ParseIP(test.ip), test.domain)
+		if got != test.expected {
+			t.Fatalf("unexpected SPF result for %s from %s. expected %s got %s (%s)", test.domain, test.ip, test.expected, got, msg)
+		}
+	}
+}
+
+func TestParseDomainSpec(t *testing.T) {
+	domain, p4, p6, err := parseDomainSpec("example.com", ":mail.example.com/24//64")
+	if err != nil || domain != "mail.example.com" || p4 != 24 || p6 != 64 {
+		t.Fatalf("unexpected domain spec %s/%d//%d: %v", domain, p4, p6, err)
+	}
+	domain, p4, p6, err = parseDomainSpec("example.com", "")
+	if err != nil || domain != "example.com" || p4 != 32 || p6 != 128 {
+		t.Fatalf("unexpected domain spec %s/%d//%d: %v", domain, p4, p6, err)
+	}
+	_, _, _, err = parseDomainSpec("example.com", "/33")
+	if err == nil {
+		t.Fatalf("expected error parsing an invalid prefix length")
+	}
+}
+
+func findCheck(r *Report, name string, status Status) *Check {
+	for i, c := range r.Checks {
+		if c.Name == name && c.Status == status {
+			return &r.Checks[i]
+		}
+	}
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	c, _ := newMockChecker()
+	r := c.Check(context.Background(), "Example.com.", []net.IP{net.ParseIP("198.51.100.10")}, []string{"selector1"})
+	if r.Domain != "example.com" {
+		t.Fatalf("unexpected domain %s", r.Domain)
+	}
+	for _, name := range []string{"spf", "dkim", "dmarc", "rdns"} {
+		if findCheck(r, name, StatusPass) == nil {
+			t.Fatalf("expected the %s check to pass: %#v", name, r.Checks)
+		}
+	}
+	if findCheck(r, "blocklist", StatusFail) == nil {
+		t.Fatalf("expected the address to be listed: %#v", r.Checks)
+	}
+	if r.Score != 75 {
+		t.Fatalf("unexpected score. expected 75 got %d", r.Score)
+	}
+
+	// An address the SPF record doesn't allow isn't aligned, so the DMARC
+	// policy depends on DKIM.
+	r = c.Check(context.Background(), "example.com", []net.IP{net.ParseIP("192.0.2.1")}, []string{"missing"})
+	if findCheck(r, "spf", StatusWarn) == nil || findCheck(r, "dkim", StatusWarn) == nil || findCheck(r, "dmarc", StatusFail) == nil {
+		t.Fatalf("unexpected checks: %#v", r.Checks)
+	}
+	if check := findCheck(r, "rdns", StatusFail); check == nil || !strings.Contains(check.Message, "192.0.2.1") {
+		t.Fatalf("expected the reverse DNS check to fail: %#v", r.Checks)
+	}
+
+	// Private addresses aren't checked against blocklists
+	r = c.Check(context.Background(), "mail.example.com", []net.IP{net.ParseIP("10.0.0.1")}, nil)
+	if findCheck(r, "rdns", StatusSkipped) == nil {
+		t.Fatalf("expected the reverse DNS check to be skipped: %#v", r.Checks)
+	}
+	if check := findCheck(r, "blocklist", StatusFail); check == nil || !strings.Contains(check.Message, "mail.example.com") {
+		t.Fatalf("expected the domain to be listed: %#v", r.Checks)
+	}
+}
+
+func TestReverseIP(t *testing.T) {
+	if got := reverseIP(net.ParseIP("1.2.3.4")); got != "4.3.2.1" {
+		t.Fatalf("unexpected reversed address %s", got)
+	}
+	expected := "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2"
+	if got := reverseIP(net.ParseIP("2001:db8::1")); got != expected {
+		t.Fatalf("unexpected reversed address %s", got)
+	}
+}
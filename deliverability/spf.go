@@ -0,0 +1,248 @@
+package deliverability
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SPF results, as defined in RFC 7208
+const (
+	SPFNone      = "none"
+	SPFNeutral   = "neutral"
+	SPFPass      = "pass"
+	SPFFail      = "fail"
+	SPFSoftFail  = "softfail"
+	SPFTempError = "temperror"
+	SPFPermError = "permerror"
+)
+
+// maxSPFLookups is the maximum number of DNS lookups made while evaluating
+// an SPF record, as required by RFC 7208 section 4.6.4.
+const maxSPFLookups = 10
+
+var errSPFLookupLimit = errors.New("too many DNS lookups")
+
+// spfRecord returns the SPF record published for the domain, if there is one.
+func (c *Checker) spfRecord(ctx context.Context, domain string) (string, error) {
+	txts, err := c.Resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	record := ""
+	for _, txt := range txts {
+		if txt == "v=spf1" || strings.HasPrefix(strings.ToLower(txt), "v=spf1 ") {
+			if record != "" {
+				return "", errors.New("multiple SPF records are published")
+			}
+			record = txt
+		}
+	}
+	return record, nil
+}
+
+// spfEvaluator evaluates SPF records for an IP address, counting the DNS
+// lookups made along the way.
+type spfEvaluator struct {
+	checker *Checker
+	ip      net.IP
+	lookups int
+}
+
+// checkSPF evaluates the SPF policy of the domain for the IP address,
+// returning the result along with an explanation for results other than
+// pass.
+func (c *Checker) checkSPF(ctx context.Context, ip net.IP, domain string) (string, string) {
+	e := &spfEvaluator{checker: c, ip: ip}
+	return e.check(ctx, domain)
+}
+
+func (e *spfEvaluator) check(ctx context.Context, domain string) (string, string) {
+	record, err := e.checker.spfRecord(ctx, domain)
+	if err != nil {
+		return SPFTempError, err.Error()
+	}
+	if record == "" {
+		return SPFNone, "no SPF record is published for " + domain
+	}
+	redirect := ""
+	for _, term := range strings.Fields(record)[1:] {
+		if strings.HasPrefix(strings.ToLower(term), "redirect=") {
+			redirect = term[len("redirect="):]
+			continue
+		}
+		if strings.Contains(term, "=") {
+			// Other modifiers, such as exp=, don't affect the result
+			continue
+		}
+		qualifier := SPFPass
+		switch term[0] {
+		case '+':
+			term = term[1:]
+		case '-':
+			qualifier, term = SPFFail, term[1:]
+		case '~':
+			qualifier, term = SPFSoftFail, term[1:]
+		case '?':
+			qualifier, term = SPFNeutral, term[1:]
+		}
+		matched, result, msg := e.match(ctx, domain, term)
+		if result != "" {
+			return result, msg
+		}
+		if matched {
+			if qualifier == SPFPass {
+				return SPFPass, ""
+			}
+			return qualifier, "the SPF record for " + domain + " doesn't allow " + e.ip.String() + " (" + term + ")"
+		}
+	}
+	if redirect != "" {
+		if err := e.lookup(); err != nil {
+			return SPFPermError, err.Error()
+		}
+		result, msg := e.check(ctx, redirect)
+		if result == SPFNone {
+			return SPFPermError, "the redirect domain " + redirect + " has no SPF record"
+		}
+		return result, msg
+	}
+	return SPFNeutral, "the SPF record for " + domain + " doesn't mention " + e.ip.String()
+}
+
+// lookup counts a DNS lookup against the limit.
+func (e *spfEvaluator) lookup() error {
+	e.lookups++
+	if e.lookups > maxSPFLookups {
+		return errSPFLookupLimit
+	}
+	return nil
+}
+
+// match returns whether the mechanism matches the IP address. If evaluating
+// the mechanism ends the evaluation, such as from an error, the result and an
+// explanation are returned.
+func (e *spfEvaluator) match(ctx context.Context, domain, term string) (bool, string, string) {
+	name, arg := term, ""
+	if i := strings.IndexAny(term, ":/"); i != -1 {
+		name, arg = term[:i], term[i:]
+	}
+	name = strings.ToLower(name)
+	switch name {
+	case "all":
+		return true, "", ""
+	case "ip4", "ip6":
+		cidr := strings.TrimPrefix(arg, ":")
+		if !strings.Contains(cidr, "/") {
+			if name == "ip4" {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false, SPFPermError, "invalid SPF mechanism " + term
+		}
+		return network.Contains(e.ip), "", ""
+	case "include":
+		target := strings.TrimPrefix(arg, ":")
+		if target == "" {
+			return false, SPFPermError, "invalid SPF mechanism " + term
+		}
+		if err := e.lookup(); err != nil {
+			return false, SPFPermError, err.Error()
+		}
+		result, msg := e.check(ctx, target)
+		switch result {
+		case SPFPass:
+			return true, "", ""
+		case SPFFail, SPFSoftFail, SPFNeutral:
+			return false, "", ""
+		case SPFNone:
+			return false, SPFPermError, "the included domain " + target + " has no SPF record"
+		}
+		return false, result, msg
+	case "a", "mx":
+		if err := e.lookup(); err != nil {
+			return false, SPFPermError, err.Error()
+		}
+		target, prefix4, prefix6, err := parseDomainSpec(domain, arg)
+		if err != nil {
+			return false, SPFPermError, "invalid SPF mechanism " + term
+		}
+		hosts := []string{target}
+		if name == "mx" {
+			mxs, err := e.checker.Resolver.LookupMX(ctx, target)
+			if err != nil && !isNotFound(err) {
+				return false, SPFTempError, err.Error()
+			}
+			hosts = hosts[:0]
+			for _, mx := range mxs {
+				hosts = append(hosts, mx.Host)
+			}
+		}
+		for _, host := range hosts {
+			addrs, err := e.checker.Resolver.LookupHost(ctx, host)
+			if err != nil {
+				if isNotFound(err) {
+					continue
+				}
+				return false, SPFTempError, err.Error()
+			}
+			for _, addr := range addrs {
+				ip := net.ParseIP(addr)
+				// Addresses are only compared with those of the same family
+				if ip == nil || (ip.To4() == nil) != (e.ip.To4() == nil) {
+					continue
+				}
+				bits, prefix := 32, prefix4
+				if ip.To4() == nil {
+					bits, prefix = 128, prefix6
+				}
+				network := &net.IPNet{IP: ip.Mask(net.CIDRMask(prefix, bits)), Mask: net.CIDRMask(prefix, bits)}
+				if network.Contains(e.ip) {
+					return true, "", ""
+				}
+			}
+		}
+		return false, "", ""
+	case "exists", "ptr":
+		// These mechanisms are rarely used and aren't evaluated, so they
+		// never match.
+		if err := e.lookup(); err != nil {
+			return false, SPFPermError, err.Error()
+		}
+		return false, "", ""
+	}
+	return false, SPFPermError, "unknown SPF mechanism " + term
+}
+
+// parseDomainSpec parses the optional domain and CIDR lengths of an a or mx
+// mechanism, such as ":example.com/24//64".
+func parseDomainSpec(domain, arg string) (string, int, int, error) {
+	prefix4, prefix6 := 32, 128
+	if i := strings.Index(arg, "//"); i != -1 {
+		p, err := strconv.Atoi(arg[i+2:])
+		if err != nil || p < 0 || p > 128 {
+			return "", 0, 0, errors.New("invalid prefix length")
+		}
+		prefix6, arg = p, arg[:i]
+	}
+	if i := strings.Index(arg, "/"); i != -1 {
+		p, err := strconv.Atoi(arg[i+1:])
+		if err != nil || p < 0 || p > 32 {
+			return "", 0, 0, errors.New("invalid prefix length")
+		}
+		prefix4, arg = p, arg[:i]
+	}
+	if strings.HasPrefix(arg, ":") {
+		domain = arg[1:]
+	}
+	return domain, prefix4, prefix6, nil
+}
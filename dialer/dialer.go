@@ -3,6 +3,7 @@ package dialer
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"syscall"
 	"time"
 )
@@ -11,6 +12,7 @@ import (
 // connections to only allowlisted IP ranges.
 type RestrictedDialer struct {
 	allowedHosts []*net.IPNet
+	proxy        *url.URL
 }
 
 // DefaultDialer is a global instance of a RestrictedDialer
@@ -0,0 +1,77 @@
+package dialer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrUnsupportedDialer is returned when binding a source address to a dialer
+// which wasn't created by this package.
+var ErrUnsupportedDialer = errors.New("Source addresses can't be bound to this dialer")
+
+// ParseSourceAddress returns the local address to bind outbound connections
+// to. The source can be either an IP address or the name of a network
+// interface, in which case the interface's first IPv4 address is used,
+// falling back to its first IPv6 address. An empty string returns a nil
+// address, meaning the operating system chooses the source.
+func ParseSourceAddress(source string) (*net.TCPAddr, error) {
+	if source == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(source); ip != nil {
+		return &net.TCPAddr{IP: ip}, nil
+	}
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an IP address or network interface", source)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var ip6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return &net.TCPAddr{IP: ipNet.IP}, nil
+		}
+		if ip6 == nil {
+			ip6 = ipNet.IP
+		}
+	}
+	if ip6 == nil {
+		return nil, fmt.Errorf("network interface %s has no usable addresses", source)
+	}
+	return &net.TCPAddr{IP: ip6}, nil
+}
+
+// BindSourceAddress returns a copy of the dialer which makes connections from
+// the given source address, so that a host with several addresses can choose
+// which one is used. When connecting through a proxy, the connection to the
+// proxy is bound to the address.
+func BindSourceAddress(d ContextDialer, source string) (ContextDialer, error) {
+	addr, err := ParseSourceAddress(source)
+	if err != nil {
+		return nil, err
+	}
+	if addr == nil {
+		return d, nil
+	}
+	switch nd := d.(type) {
+	case *net.Dialer:
+		bound := *nd
+		bound.LocalAddr = addr
+		return &bound, nil
+	case *proxyDialer:
+		forward := *nd.forward
+		forward.LocalAddr = addr
+		bound := *nd
+		bound.forward = &forward
+		return &bound, nil
+	}
+	return nil, ErrUnsupportedDialer
+}
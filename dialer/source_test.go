@@ -0,0 +1,68 @@
+package dialer
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseSourceAddress(t *testing.T) {
+	addr, err := ParseSourceAddress("")
+	if addr != nil || err != nil {
+		t.Fatalf("expected no source address, got %v, %v", addr, err)
+	}
+	addr, err = ParseSourceAddress("192.0.2.1")
+	if err != nil || !addr.IP.Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected source address %v: %v", addr, err)
+	}
+	addr, err = ParseSourceAddress("lo")
+	if err != nil || !addr.IP.IsLoopback() {
+		t.Fatalf("unexpected address for the loopback interface %v: %v", addr, err)
+	}
+	_, err = ParseSourceAddress("gophish0")
+	if err == nil {
+		t.Fatalf("expected error parsing an unknown interface")
+	}
+}
+
+func TestBindSourceAddress(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer l.Close()
+	remote := make(chan net.Addr, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		remote <- conn.RemoteAddr()
+		conn.Close()
+	}()
+	d, err := BindSourceAddress((&RestrictedDialer{}).Dialer(), "127.0.0.2")
+	if err != nil {
+		t.Fatalf("error binding source address: %v", err)
+	}
+	conn, err := d.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing from source address: %v", err)
+	}
+	conn.Close()
+	addr := (<-remote).(*net.TCPAddr)
+	if !addr.IP.Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("unexpected source address. expected 127.0.0.2 got %s", addr.IP)
+	}
+
+	// Connections to a proxy are bound to the source address
+	pd, err := (&RestrictedDialer{}).ProxyDialer("socks5://127.0.0.1:1080")
+	if err != nil {
+		t.Fatalf("error creating proxy dialer: %v", err)
+	}
+	bound, err := BindSourceAddress(pd, "127.0.0.2")
+	if err != nil {
+		t.Fatalf("error binding source address: %v", err)
+	}
+	if bound.(*proxyDialer).forward.LocalAddr == nil || pd.(*proxyDialer).forward.LocalAddr != nil {
+		t.Fatalf("expected only the bound proxy dialer to have a source address")
+	}
+}
@@ -0,0 +1,168 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultCloudflareURL is the base URL of the Cloudflare API.
+const DefaultCloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare manages records using the Cloudflare API. The API token needs
+// the Zone:Read and DNS:Edit permissions.
+type Cloudflare struct {
+	URL      string
+	APIToken string
+	Client   *http.Client
+}
+
+type cloudflareRecord struct {
+	Id       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	TTL      int    `json:"ttl"`
+	Priority *int   `json:"priority,omitempty"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// do makes a request to the API, decoding the result into v.
+func (c *Cloudflare) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	cr := cloudflareResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&cr)
+	if err != nil {
+		return fmt.Errorf("cloudflare returned %s", resp.Status)
+	}
+	if !cr.Success {
+		messages := []string{}
+		for _, e := range cr.Errors {
+			messages = append(messages, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("cloudflare returned %s: %s", resp.Status, strings.Join(messages, ", "))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(cr.Result, v)
+}
+
+// zoneID returns the id of the zone with the given name.
+func (c *Cloudflare) zoneID(ctx context.Context, zone string) (string, error) {
+	zones := []struct {
+		Id string `json:"id"`
+	}{}
+	err := c.do(ctx, "GET", "/zones?name="+url.QueryEscape(zone), nil, &zones)
+	if err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("cloudflare zone %s not found", zone)
+	}
+	return zones[0].Id, nil
+}
+
+// existing returns the records in the zone with the same type and name as
+// the record.
+func (c *Cloudflare) existing(ctx context.Context, zid string, r Record) ([]cloudflareRecord, error) {
+	q := url.Values{}
+	q.Set("type", r.Type)
+	q.Set("name", r.Name)
+	crs := []cloudflareRecord{}
+	err := c.do(ctx, "GET", "/zones/"+zid+"/dns_records?"+q.Encode(), nil, &crs)
+	return crs, err
+}
+
+// Upsert creates the records in the zone, updating the records they replace.
+func (c *Cloudflare) Upsert(ctx context.Context, zone string, records []Record) error {
+	zid, err := c.zoneID(ctx, zone)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		crs, err := c.existing(ctx, zid, r)
+		if err != nil {
+			return err
+		}
+		cr := cloudflareRecord{Type: r.Type, Name: r.Name, Content: r.Value, TTL: r.TTL}
+		if strings.EqualFold(r.Type, "MX") {
+			priority := r.Priority
+			cr.Priority = &priority
+		}
+		path := "/zones/" + zid + "/dns_records"
+		method := "POST"
+		for _, existing := range crs {
+			if r.Replaces(Record{Type: existing.Type, Name: existing.Name, Value: existing.Content}) {
+				path += "/" + existing.Id
+				method = "PUT"
+				break
+			}
+		}
+		err = c.do(ctx, method, path, cr, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the records from the zone.
+func (c *Cloudflare) Delete(ctx context.Context, zone string, records []Record) error {
+	zid, err := c.zoneID(ctx, zone)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		crs, err := c.existing(ctx, zid, r)
+		if err != nil {
+			return err
+		}
+		for _, existing := range crs {
+			if existing.Content != r.Value {
+				continue
+			}
+			err = c.do(ctx, "DELETE", "/zones/"+zid+"/dns_records/"+existing.Id, nil, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/config"
+)
+
+// DefaultTimeout is how long to wait for a DNS provider's API.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultTTL is the TTL of the records created, in seconds.
+const DefaultTTL = 300
+
+// Record is a DNS record. Names are fully qualified, without a trailing dot.
+// The Priority is only used by MX records.
+type Record struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	TTL      int    `json:"ttl"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// Provider creates and removes records in the zones it hosts.
+type Provider interface {
+	// Upsert creates each record in the zone, replacing the existing record
+	// it corresponds to.
+	Upsert(ctx context.Context, zone string, records []Record) error
+	// Delete removes the records from the zone. Records which don't exist
+	// are skipped.
+	Delete(ctx context.Context, zone string, records []Record) error
+}
+
+// txtKind returns the kind of a TXT record from its version tag, such as
+// "v=spf1" or "v=dmarc1", or the whole value if it doesn't have one.
+func txtKind(value string) string {
+	kind := strings.ToLower(strings.TrimSpace(value))
+	if !strings.HasPrefix(kind, "v=") {
+		return kind
+	}
+	if i := strings.IndexAny(kind, " ;"); i != -1 {
+		kind = kind[:i]
+	}
+	return kind
+}
+
+// Replaces returns whether the record replaces the existing one. A records,
+// for example, replace the other A records with the same name, while an SPF
+// record only replaces the SPF record, leaving other TXT records alone.
+func (r Record) Replaces(existing Record) bool {
+	if !strings.EqualFold(r.Type, existing.Type) || !strings.EqualFold(r.Name, existing.Name) {
+		return false
+	}
+	if strings.EqualFold(r.Type, "TXT") {
+		return txtKind(r.Value) == txtKind(existing.Value)
+	}
+	return true
+}
+
+// NewProviders returns the configured DNS providers, by name.
+func NewProviders(c config.DNSProviders) (map[string]Provider, error) {
+	providers := map[string]Provider{}
+	if c.Cloudflare.APIToken != "" {
+		providers["cloudflare"] = &Cloudflare{
+			URL:      DefaultCloudflareURL,
+			APIToken: c.Cloudflare.APIToken,
+			Client:   &http.Client{Timeout: DefaultTimeout},
+		}
+	}
+	if c.Route53.Enabled {
+		r := &Route53{
+			Endpoint:        c.Route53.Endpoint,
+			AccessKeyID:     c.Route53.AccessKeyID,
+			SecretAccessKey: c.Route53.SecretAccessKey,
+			Client:          &http.Client{Timeout: DefaultTimeout},
+		}
+		if r.AccessKeyID == "" && r.SecretAccessKey == "" {
+			r.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+			r.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			r.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if r.AccessKeyID == "" || r.SecretAccessKey == "" {
+			return nil, fmt.Errorf("route53 requires an access key id and secret access key")
+		}
+		if r.Endpoint == "" {
+			r.Endpoint = DefaultRoute53Endpoint
+		}
+		providers["route53"] = r
+	}
+	return providers, nil
+}
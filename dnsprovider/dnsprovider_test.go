@@ -0,0 +1,239 @@
+package dnsprovider
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+)
+
+func TestNewProviders(t *testing.T) {
+	ps, err := NewProviders(config.DNSProviders{})
+	if err != nil || len(ps) != 0 {
+		t.Fatalf("expected no providers, got %v, %v", ps, err)
+	}
+	_, err = NewProviders(config.DNSProviders{Route53: config.Route53DNS{Enabled: true, AccessKeyID: "id"}})
+	if err == nil {
+		t.Fatal("expected an error for route53 without a secret access key")
+	}
+	ps, err = NewProviders(config.DNSProviders{
+		Cloudflare: config.CloudflareDNS{APIToken: "token"},
+		Route53:    config.Route53DNS{Enabled: true, AccessKeyID: "id", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := ps["cloudflare"].(*Cloudflare); !ok {
+		t.Fatalf("expected a cloudflare provider, got %T", ps["cloudflare"])
+	}
+	r, ok := ps["route53"].(*Route53)
+	if !ok {
+		t.Fatalf("expected a route53 provider, got %T", ps["route53"])
+	}
+	if r.Endpoint != DefaultRoute53Endpoint {
+		t.Fatalf("unexpected endpoint %s", r.Endpoint)
+	}
+}
+
+func TestReplaces(t *testing.T) {
+	tests := []struct {
+		record   Record
+		existing Record
+		replaces bool
+	}{
+		{Record{Type: "A", Name: "example.com", Value: "192.0.2.1"}, Record{Type: "a", Name: "Example.com", Value: "192.0.2.2"}, true},
+		{Record{Type: "A", Name: "example.com"}, Record{Type: "AAAA", Name: "example.com"}, false},
+		{Record{Type: "A", Name: "example.com"}, Record{Type: "A", Name: "www.example.com"}, false},
+		{Record{Type: "TXT", Name: "example.com", Value: "v=spf1 a ~all"}, Record{Type: "TXT", Name: "example.com", Value: "v=spf1 -all"}, true},
+		{Record{Type: "TXT", Name: "example.com", Value: "v=spf1 a ~all"}, Record{Type: "TXT", Name: "example.com", Value: "google-site-verification=abc"}, false},
+		{Record{Type: "TXT", Name: "_dmarc.example.com", Value: "v=DMARC1; p=none"}, Record{Type: "TXT", Name: "_dmarc.example.com", Value: "v=DMARC1; p=reject"}, true},
+	}
+	for _, tc := range tests {
+		if got := tc.record.Replaces(tc.existing); got != tc.replaces {
+			t.Fatalf("expected %+v replacing %+v to be %v", tc.record, tc.existing, tc.replaces)
+		}
+	}
+}
+
+func TestRoute53Value(t *testing.T) {
+	long := "v=DKIM1; k=rsa; p=" + strings.Repeat("A", 400) + `"quoted"`
+	tests := []Record{
+		{Type: "MX", Name: "example.com", Value: "mail.example.com", Priority: 10},
+		{Type: "CNAME", Name: "www.example.com", Value: "example.com"},
+		{Type: "TXT", Name: "example.com", Value: "v=spf1 a ~all"},
+		{Type: "TXT", Name: "s1._domainkey.example.com", Value: long},
+	}
+	for _, rec := range tests {
+		value := route53Value(rec)
+		got := parseRoute53Value(fqdn(rec.Name), rec.Type, value)
+		if got != rec {
+			t.Fatalf("expected %+v, got %+v from %s", rec, got, value)
+		}
+	}
+	parts := strings.Split(route53Value(Record{Type: "TXT", Value: long}), `" "`)
+	if len(parts) != 2 {
+		t.Fatalf("expected the DKIM key to be split into 2 strings, got %d", len(parts))
+	}
+}
+
+func TestCloudflare(t *testing.T) {
+	records := map[string]cloudflareRecord{
+		"1": {Id: "1", Type: "TXT", Name: "example.com", Content: "v=spf1 -all", TTL: 1},
+		"2": {Id: "2", Type: "TXT", Name: "example.com", Content: "google-site-verification=abc", TTL: 1},
+	}
+	next := 3
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"success": false, "errors": [{"code": 9109, "message": "Invalid access token"}]}`))
+			return
+		}
+		var result interface{}
+		switch {
+		case r.URL.Path == "/zones":
+			result = []map[string]string{{"id": "zone"}}
+		case r.URL.Path == "/zones/zone/dns_records" && r.Method == "GET":
+			found := []cloudflareRecord{}
+			for _, cr := range records {
+				if cr.Type == r.URL.Query().Get("type") && cr.Name == r.URL.Query().Get("name") {
+					found = append(found, cr)
+				}
+			}
+			result = found
+		case strings.HasPrefix(r.URL.Path, "/zones/zone/dns_records"):
+			id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/zones/zone/dns_records"), "/")
+			if r.Method == "DELETE" {
+				delete(records, id)
+				break
+			}
+			cr := cloudflareRecord{}
+			json.NewDecoder(r.Body).Decode(&cr)
+			if r.Method == "POST" {
+				id = strconv.Itoa(next)
+				next++
+			}
+			cr.Id = id
+			records[id] = cr
+		}
+		b, _ := json.Marshal(result)
+		w.Write([]byte(`{"success": true, "errors": [], "result": ` + string(b) + `}`))
+	}))
+	defer ts.Close()
+
+	c := &Cloudflare{URL: ts.URL, APIToken: "token"}
+	planned := []Record{
+		{Type: "MX", Name: "example.com", Value: "mail.example.com", Priority: 10, TTL: DefaultTTL},
+		{Type: "TXT", Name: "example.com", Value: "v=spf1 a:mail.example.com ~all", TTL: DefaultTTL},
+	}
+	err := c.Upsert(context.Background(), "example.com", planned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %v", records)
+	}
+	// The SPF record is replaced, leaving the other TXT record alone
+	if records["1"].Content != planned[1].Value || records["2"].Content != "google-site-verification=abc" {
+		t.Fatalf("unexpected TXT records %+v, %+v", records["1"], records["2"])
+	}
+	if records["3"].Priority == nil || *records["3"].Priority != 10 {
+		t.Fatalf("unexpected MX record %+v", records["3"])
+	}
+
+	err = c.Delete(context.Background(), "example.com", planned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %v", records)
+	}
+
+	c.APIToken = "wrong"
+	err = c.Upsert(context.Background(), "example.com", planned)
+	if err == nil || !strings.Contains(err.Error(), "Invalid access token") {
+		t.Fatalf("expected an invalid token error, got %v", err)
+	}
+}
+
+func TestRoute53(t *testing.T) {
+	sets := map[string]route53ResourceRecordSet{
+		"example.com./TXT": {Name: "example.com.", Type: "TXT", TTL: 60, ResourceRecords: []string{`"v=spf1 -all"`, `"google-site-verification=abc"`}},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.URL.Path == "/2013-04-01/hostedzonesbyname":
+			w.Write([]byte(`<ListHostedZonesByNameResponse><HostedZones><HostedZone><Id>/hostedzone/Z1</Id><Name>example.com.</Name></HostedZone></HostedZones></ListHostedZonesByNameResponse>`))
+		case r.URL.Path == "/2013-04-01/hostedzone/Z1/rrset" && r.Method == "GET":
+			resp := route53RecordSets{}
+			if set, ok := sets[r.URL.Query().Get("name")+"/"+r.URL.Query().Get("type")]; ok {
+				resp.ResourceRecordSets = append(resp.ResourceRecordSets, set)
+			}
+			xml.NewEncoder(w).Encode(resp)
+		case r.URL.Path == "/2013-04-01/hostedzone/Z1/rrset/" && r.Method == "POST":
+			b, _ := ioutil.ReadAll(r.Body)
+			req := route53ChangeRequest{}
+			if err := xml.Unmarshal(b, &req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for _, c := range req.Changes {
+				key := c.ResourceRecordSet.Name + "/" + c.ResourceRecordSet.Type
+				if c.Action == "DELETE" {
+					delete(sets, key)
+				} else {
+					sets[key] = c.ResourceRecordSet
+				}
+			}
+			w.Write([]byte(`<ChangeResourceRecordSetsResponse/>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<ErrorResponse><Error><Code>NoSuchHostedZone</Code><Message>not found</Message></Error></ErrorResponse>`))
+		}
+	}))
+	defer ts.Close()
+
+	r := &Route53{Endpoint: ts.URL, AccessKeyID: "id", SecretAccessKey: "secret"}
+	planned := []Record{
+		{Type: "MX", Name: "example.com", Value: "mail.example.com", Priority: 10, TTL: DefaultTTL},
+		{Type: "TXT", Name: "example.com", Value: "v=spf1 a:mail.example.com ~all", TTL: DefaultTTL},
+	}
+	err := r.Upsert(context.Background(), "example.com", planned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := sets["example.com./MX"].ResourceRecords; len(got) != 1 || got[0] != "10 mail.example.com." {
+		t.Fatalf("unexpected MX records %v", got)
+	}
+	// The SPF record is replaced, leaving the other TXT record alone
+	got := sets["example.com./TXT"].ResourceRecords
+	if len(got) != 2 || got[0] != `"google-site-verification=abc"` || got[1] != `"v=spf1 a:mail.example.com ~all"` {
+		t.Fatalf("unexpected TXT records %v", got)
+	}
+
+	err = r.Delete(context.Background(), "example.com", planned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := sets["example.com./MX"]; ok {
+		t.Fatal("expected the MX record set to be deleted")
+	}
+	if got := sets["example.com./TXT"]; len(got.ResourceRecords) != 1 || got.TTL != DefaultTTL {
+		t.Fatalf("unexpected TXT record set %+v", got)
+	}
+
+	err = r.Upsert(context.Background(), "unknown.com", planned)
+	if err == nil {
+		t.Fatal("expected an error for an unknown zone")
+	}
+}
@@ -0,0 +1,297 @@
+package dnsprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/sigv4"
+)
+
+// DefaultRoute53Endpoint is the endpoint of the Route 53 API.
+const DefaultRoute53Endpoint = "https://route53.amazonaws.com"
+
+const (
+	route53Service = "route53"
+	// Route 53 is a global service, signed using us-east-1.
+	route53Region  = "us-east-1"
+	route53Version = "2013-04-01"
+	route53XMLNS   = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+// maxTXTString is the longest string a TXT record value can have. Longer
+// values, such as DKIM keys, are split into several strings.
+const maxTXTString = 255
+
+// Route53 manages records using the Amazon Route 53 API. Requests are signed
+// using AWS Signature Version 4.
+//
+// Route 53 groups the records with the same name and type into a record set,
+// so the records which aren't replaced are kept when a set is changed.
+type Route53 struct {
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+	now             func() time.Time
+}
+
+type route53ResourceRecordSet struct {
+	Name            string   `xml:"Name"`
+	Type            string   `xml:"Type"`
+	TTL             int      `xml:"TTL"`
+	ResourceRecords []string `xml:"ResourceRecords>ResourceRecord>Value"`
+}
+
+type route53Change struct {
+	Action            string                   `xml:"Action"`
+	ResourceRecordSet route53ResourceRecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53HostedZones struct {
+	HostedZones []struct {
+		Id   string `xml:"Id"`
+		Name string `xml:"Name"`
+	} `xml:"HostedZones>HostedZone"`
+}
+
+type route53RecordSets struct {
+	ResourceRecordSets []route53ResourceRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// do makes a signed request to the API, decoding the XML response into v.
+func (r *Route53) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = xml.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(r.Endpoint, "/")+"/"+route53Version+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	now := r.now
+	if now == nil {
+		now = time.Now
+	}
+	sigv4.Sign(req, payload, now(), route53Region, route53Service, r.AccessKeyID, r.SecretAccessKey, r.SessionToken)
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		re := route53Error{}
+		if xml.Unmarshal(b, &re) == nil && re.Code != "" {
+			return fmt.Errorf("route53 returned %s: %s %s", resp.Status, re.Code, re.Message)
+		}
+		return fmt.Errorf("route53 returned %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// fqdn returns the name with a trailing dot, as used by Route 53.
+func fqdn(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".") + "."
+}
+
+// hostedZoneID returns the id of the hosted zone with the given name.
+func (r *Route53) hostedZoneID(ctx context.Context, zone string) (string, error) {
+	q := url.Values{}
+	q.Set("dnsname", fqdn(zone))
+	q.Set("maxitems", "1")
+	hz := route53HostedZones{}
+	err := r.do(ctx, "GET", "/hostedzonesbyname?"+q.Encode(), nil, &hz)
+	if err != nil {
+		return "", err
+	}
+	if len(hz.HostedZones) == 0 || !strings.EqualFold(hz.HostedZones[0].Name, fqdn(zone)) {
+		return "", fmt.Errorf("route53 hosted zone %s not found", zone)
+	}
+	return strings.TrimPrefix(hz.HostedZones[0].Id, "/hostedzone/"), nil
+}
+
+// recordSet returns the record set with the same name and type as the
+// record, if it exists.
+func (r *Route53) recordSet(ctx context.Context, zid string, rec Record) (*route53ResourceRecordSet, error) {
+	q := url.Values{}
+	q.Set("name", fqdn(rec.Name))
+	q.Set("type", strings.ToUpper(rec.Type))
+	q.Set("maxitems", "1")
+	sets := route53RecordSets{}
+	err := r.do(ctx, "GET", "/hostedzone/"+zid+"/rrset?"+q.Encode(), nil, &sets)
+	if err != nil {
+		return nil, err
+	}
+	// The sets are listed starting from the name and type, so the first
+	// set is a different one if the set doesn't exist.
+	for _, set := range sets.ResourceRecordSets {
+		if strings.EqualFold(set.Name, fqdn(rec.Name)) && strings.EqualFold(set.Type, rec.Type) {
+			return &set, nil
+		}
+	}
+	return nil, nil
+}
+
+// route53Value returns the value of the record as written in a record set.
+func route53Value(rec Record) string {
+	switch strings.ToUpper(rec.Type) {
+	case "MX":
+		return strconv.Itoa(rec.Priority) + " " + fqdn(rec.Value)
+	case "CNAME":
+		return fqdn(rec.Value)
+	case "TXT":
+		escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(rec.Value)
+		parts := []string{}
+		for len(escaped) > maxTXTString {
+			// Escapes aren't split between strings
+			n := maxTXTString
+			for n > 0 && escaped[n-1] == '\\' {
+				n--
+			}
+			parts = append(parts, `"`+escaped[:n]+`"`)
+			escaped = escaped[n:]
+		}
+		parts = append(parts, `"`+escaped+`"`)
+		return strings.Join(parts, " ")
+	}
+	return rec.Value
+}
+
+// parseRoute53Value returns the record a value in a record set is for.
+func parseRoute53Value(name, typ, value string) Record {
+	rec := Record{Type: typ, Name: strings.TrimSuffix(name, "."), Value: value}
+	switch strings.ToUpper(typ) {
+	case "MX":
+		fields := strings.Fields(value)
+		if len(fields) == 2 {
+			rec.Priority, _ = strconv.Atoi(fields[0])
+			rec.Value = strings.TrimSuffix(fields[1], ".")
+		}
+	case "CNAME":
+		rec.Value = strings.TrimSuffix(value, ".")
+	case "TXT":
+		var b strings.Builder
+		quoted, escaped := false, false
+		for _, c := range value {
+			switch {
+			case escaped:
+				b.WriteRune(c)
+				escaped = false
+			case c == '\\' && quoted:
+				escaped = true
+			case c == '"':
+				quoted = !quoted
+			case quoted:
+				b.WriteRune(c)
+			}
+		}
+		rec.Value = b.String()
+	}
+	return rec
+}
+
+// change applies the change to the record set, keeping the records which
+// aren't replaced. If add is false, the record is only removed.
+func (r *Route53) change(ctx context.Context, zid string, rec Record, add bool) error {
+	existing, err := r.recordSet(ctx, zid, rec)
+	if err != nil {
+		return err
+	}
+	set := route53ResourceRecordSet{Name: fqdn(rec.Name), Type: strings.ToUpper(rec.Type), TTL: rec.TTL}
+	if set.TTL == 0 {
+		set.TTL = DefaultTTL
+	}
+	if existing != nil {
+		if !add {
+			set.TTL = existing.TTL
+		}
+		for _, value := range existing.ResourceRecords {
+			old := parseRoute53Value(existing.Name, existing.Type, value)
+			if add && rec.Replaces(old) {
+				continue
+			}
+			if !add && old.Value == rec.Value {
+				continue
+			}
+			set.ResourceRecords = append(set.ResourceRecords, value)
+		}
+	}
+	if add {
+		set.ResourceRecords = append(set.ResourceRecords, route53Value(rec))
+	}
+	change := route53Change{Action: "UPSERT", ResourceRecordSet: set}
+	if len(set.ResourceRecords) == 0 {
+		if existing == nil {
+			return nil
+		}
+		change = route53Change{Action: "DELETE", ResourceRecordSet: *existing}
+	}
+	return r.do(ctx, "POST", "/hostedzone/"+zid+"/rrset/", route53ChangeRequest{XMLNS: route53XMLNS, Changes: []route53Change{change}}, nil)
+}
+
+// Upsert creates the records in the zone, replacing the existing records
+// they correspond to.
+func (r *Route53) Upsert(ctx context.Context, zone string, records []Record) error {
+	zid, err := r.hostedZoneID(ctx, zone)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		err = r.change(ctx, zid, rec, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the records from the zone.
+func (r *Route53) Delete(ctx context.Context, zone string, records []Record) error {
+	zid, err := r.hostedZoneID(ctx, zone)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		err = r.change(ctx, zid, rec, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
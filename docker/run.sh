@@ -1,74 +1,33 @@
 #!/bin/bash
 
+# Gophish reads GOPHISH_* environment variables as overrides for config.json,
+# such as GOPHISH_ADMIN_SERVER_LISTEN_URL for admin_server.listen_url. The
+# variables below are kept for compatibility with earlier images.
+map_env() {
+    if [ -n "${!1+set}" ] ; then
+        export "$2"="${!1}"
+    fi
+}
+
 # set config for admin_server
-if [ -n "${ADMIN_LISTEN_URL+set}" ] ; then
-    jq -r \
-        --arg ADMIN_LISTEN_URL "${ADMIN_LISTEN_URL}" \
-        '.admin_server.listen_url = $ADMIN_LISTEN_URL' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
-if [ -n "${ADMIN_USE_TLS+set}" ] ; then
-    jq -r \
-        --argjson ADMIN_USE_TLS "${ADMIN_USE_TLS}" \
-        '.admin_server.use_tls = $ADMIN_USE_TLS' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
-if [ -n "${ADMIN_CERT_PATH+set}" ] ; then
-    jq -r \
-        --arg ADMIN_CERT_PATH "${ADMIN_CERT_PATH}" \
-        '.admin_server.cert_path = $ADMIN_CERT_PATH' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
-if [ -n "${ADMIN_KEY_PATH+set}" ] ; then
-    jq -r \
-        --arg ADMIN_KEY_PATH "${ADMIN_KEY_PATH}" \
-        '.admin_server.key_path = $ADMIN_KEY_PATH' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
+map_env ADMIN_LISTEN_URL GOPHISH_ADMIN_SERVER_LISTEN_URL
+map_env ADMIN_USE_TLS GOPHISH_ADMIN_SERVER_USE_TLS
+map_env ADMIN_CERT_PATH GOPHISH_ADMIN_SERVER_CERT_PATH
+map_env ADMIN_KEY_PATH GOPHISH_ADMIN_SERVER_KEY_PATH
 
 # set config for phish_server
-if [ -n "${PHISH_LISTEN_URL+set}" ] ; then
-    jq -r \
-        --arg PHISH_LISTEN_URL "${PHISH_LISTEN_URL}" \
-        '.phish_server.listen_url = $PHISH_LISTEN_URL' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
-if [ -n "${PHISH_USE_TLS+set}" ] ; then
-    jq -r \
-        --argjson PHISH_USE_TLS "${PHISH_USE_TLS}" \
-        '.phish_server.use_tls = $PHISH_USE_TLS' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
-if [ -n "${PHISH_CERT_PATH+set}" ] ; then
-    jq -r \
-        --arg PHISH_CERT_PATH "${PHISH_CERT_PATH}" \
-        '.phish_server.cert_path = $PHISH_CERT_PATH' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
-if [ -n "${PHISH_KEY_PATH+set}" ] ; then
-    jq -r \
-        --arg PHISH_KEY_PATH "${PHISH_KEY_PATH}" \
-        '.phish_server.key_path = $PHISH_KEY_PATH' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
+map_env PHISH_LISTEN_URL GOPHISH_PHISH_SERVER_LISTEN_URL
+map_env PHISH_USE_TLS GOPHISH_PHISH_SERVER_USE_TLS
+map_env PHISH_CERT_PATH GOPHISH_PHISH_SERVER_CERT_PATH
+map_env PHISH_KEY_PATH GOPHISH_PHISH_SERVER_KEY_PATH
 
 # set contact_address
-if [ -n "${CONTACT_ADDRESS+set}" ] ; then
-    jq -r \
-        --arg CONTACT_ADDRESS "${CONTACT_ADDRESS}" \
-        '.contact_address = $CONTACT_ADDRESS' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
+map_env CONTACT_ADDRESS GOPHISH_CONTACT_ADDRESS
 
-if [ -n "${DB_FILE_PATH+set}" ] ; then
-    jq -r \
-        --arg DB_FILE_PATH "${DB_FILE_PATH}" \
-        '.db_path = $DB_FILE_PATH' config.json > config.json.tmp && \
-        cat config.json.tmp > config.json
-fi
+map_env DB_FILE_PATH GOPHISH_DB_PATH
 
 echo "Runtime configuration: "
-cat config.json
+./gophish print-config || exit 1
 
 # start gophish
-./gophish
+exec ./gophish
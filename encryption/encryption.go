@@ -0,0 +1,164 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// KeySize is the size, in bytes, of the keys used to encrypt data
+const KeySize = 32
+
+// Prefix is prepended to every encrypted value so that encrypted values can
+// be told apart from plaintext values stored before encryption was enabled.
+const Prefix = "enc:v1:"
+
+// PlainPrefix is prepended to unencrypted values which would otherwise be
+// mistaken for encrypted values, such as captured data which happens to start
+// with Prefix. Every value starting with "enc:" is escaped, so stored values
+// are never ambiguous.
+const PlainPrefix = "enc:plain:"
+
+// reservedPrefix is the prefix shared by Prefix and PlainPrefix.
+const reservedPrefix = "enc:"
+
+// ErrInvalidKey is thrown when a key isn't KeySize bytes encoded as hex or
+// base64
+var ErrInvalidKey = errors.New("Encryption keys must be 32 bytes, encoded as hex or base64")
+
+// ErrNoKey is thrown when attempting to decrypt a value without a key
+var ErrNoKey = errors.New("No encryption key is configured")
+
+// ErrUnknownKey is thrown when a value was encrypted with a key that isn't in
+// the keyring
+var ErrUnknownKey = errors.New("Value was encrypted with an unknown key")
+
+// ErrMalformedValue is thrown when an encrypted value can't be parsed
+var ErrMalformedValue = errors.New("Malformed encrypted value")
+
+// Keyring holds the key used to encrypt new values, as well as any previous
+// keys which are still needed to decrypt existing values.
+//
+// A nil Keyring is valid and leaves values unencrypted.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// ParseKey decodes a hex or base64 encoded key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// NewKeyring returns a keyring which encrypts values with the given key. The
+// previous keys are only used for decryption, allowing keys to be rotated.
+func NewKeyring(key []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{
+		aeads: make(map[string]cipher.AEAD),
+	}
+	for i, b := range append([][]byte{key}, previous...) {
+		if len(b) != KeySize {
+			return nil, ErrInvalidKey
+		}
+		block, err := aes.NewCipher(b)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(b)
+		if i == 0 {
+			k.primary = id
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// keyID returns a short identifier for the key, which is stored alongside
+// encrypted values to find the key needed to decrypt them.
+func keyID(key []byte) string {
+	h := sha256.Sum256(key)
+	return hex.EncodeToString(h[:4])
+}
+
+// IsEncrypted returns whether or not the value was produced by Encrypt.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// IsCurrent returns whether or not the value is encrypted with the keyring's
+// primary key. If the keyring is nil, this is true for any unencrypted value.
+func (k *Keyring) IsCurrent(s string) bool {
+	if k == nil {
+		return !IsEncrypted(s)
+	}
+	return strings.HasPrefix(s, Prefix+k.primary+":")
+}
+
+// Encrypt encrypts the plaintext with the primary key using AES-GCM. If the
+// keyring is nil, the plaintext is returned unchanged, unless it needs to be
+// escaped with PlainPrefix.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if k == nil {
+		if strings.HasPrefix(plaintext, reservedPrefix) {
+			return PlainPrefix + plaintext, nil
+		}
+		return plaintext, nil
+	}
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext for a value returned by Encrypt. Values which
+// aren't encrypted are returned unchanged, other than removing PlainPrefix.
+func (k *Keyring) Decrypt(s string) (string, error) {
+	if strings.HasPrefix(s, PlainPrefix) {
+		return strings.TrimPrefix(s, PlainPrefix), nil
+	}
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	if k == nil {
+		return "", ErrNoKey
+	}
+	parts := strings.SplitN(strings.TrimPrefix(s, Prefix), ":", 2)
+	if len(parts) != 2 {
+		return "", ErrMalformedValue
+	}
+	aead, ok := k.aeads[parts[0]]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedValue
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func newTestKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestParseKey(t *testing.T) {
+	key := newTestKey(1)
+	for _, encoded := range []string{hex.EncodeToString(key), base64.StdEncoding.EncodeToString(key)} {
+		got, err := ParseKey(encoded)
+		if err != nil {
+			t.Fatalf("unexpected error parsing key %s: %v", encoded, err)
+		}
+		if !bytes.Equal(got, key) {
+			t.Fatalf("unexpected key parsed. expected %x got %x", key, got)
+		}
+	}
+	_, err := ParseKey("too short")
+	if err != ErrInvalidKey {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrInvalidKey, err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring(newTestKey(1))
+	if err != nil {
+		t.Fatalf("unexpected error creating keyring: %v", err)
+	}
+	plaintext := "username=foo&password=bar"
+	encrypted, err := k.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error encrypting: %v", err)
+	}
+	if !IsEncrypted(encrypted) || !k.IsCurrent(encrypted) {
+		t.Fatalf("expected value to be encrypted with the primary key, got %s", encrypted)
+	}
+	again, _ := k.Encrypt(plaintext)
+	if again == encrypted {
+		t.Fatalf("expected a unique nonce for each encryption")
+	}
+	got, err := k.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("unexpected error decrypting: %v", err)
+	}
+	if got != plaintext {
+		t.Fatalf("unexpected plaintext. expected %s got %s", plaintext, got)
+	}
+	// Values stored before encryption was enabled are returned as-is
+	got, err = k.Decrypt(plaintext)
+	if err != nil || got != plaintext {
+		t.Fatalf("unexpected result decrypting plaintext. got %s, %v", got, err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	k, _ := NewKeyring(newTestKey(1))
+	encrypted, _ := k.Encrypt("secret")
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if tampered == encrypted {
+		tampered = encrypted[:len(encrypted)-2] + "BB"
+	}
+	_, err := k.Decrypt(tampered)
+	if err == nil {
+		t.Fatalf("expected error decrypting tampered value")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, _ := NewKeyring(newTestKey(1))
+	encrypted, _ := old.Encrypt("secret")
+
+	rotated, err := NewKeyring(newTestKey(2), newTestKey(1))
+	if err != nil {
+		t.Fatalf("unexpected error creating keyring: %v", err)
+	}
+	if rotated.IsCurrent(encrypted) {
+		t.Fatalf("expected value encrypted with the previous key to not be current")
+	}
+	got, err := rotated.Decrypt(encrypted)
+	if err != nil || got != "secret" {
+		t.Fatalf("unexpected result decrypting with previous key. got %s, %v", got, err)
+	}
+
+	other, _ := NewKeyring(newTestKey(3))
+	_, err = other.Decrypt(encrypted)
+	if err != ErrUnknownKey {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrUnknownKey, err)
+	}
+}
+
+func TestNilKeyring(t *testing.T) {
+	var k *Keyring
+	got, err := k.Encrypt("secret")
+	if err != nil || got != "secret" {
+		t.Fatalf("expected nil keyring to leave value unencrypted. got %s, %v", got, err)
+	}
+	k2, _ := NewKeyring(newTestKey(1))
+	encrypted, _ := k2.Encrypt("secret")
+	_, err = k.Decrypt(encrypted)
+	if err != ErrNoKey {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrNoKey, err)
+	}
+}
+
+func TestEscapedPlaintext(t *testing.T) {
+	var k *Keyring
+	k2, _ := NewKeyring(newTestKey(1))
+	for _, plaintext := range []string{Prefix + "abcd1234:Zm9v", PlainPrefix + "foo", "enc:foo"} {
+		stored, err := k.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("unexpected error received: %v", err)
+		}
+		if IsEncrypted(stored) {
+			t.Fatalf("expected %s to be escaped. got %s", plaintext, stored)
+		}
+		if !k.IsCurrent(stored) {
+			t.Fatalf("expected escaped value %s to be current for a nil keyring", stored)
+		}
+		// The value should read back the same with or without a key
+		for _, keyring := range []*Keyring{k, k2} {
+			got, err := keyring.Decrypt(stored)
+			if err != nil || got != plaintext {
+				t.Fatalf("unexpected plaintext received. expected %s got %s, %v", plaintext, got, err)
+			}
+		}
+	}
+}
@@ -26,22 +26,40 @@ OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 THE SOFTWARE.
 */
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/gophish/gophish/auth"
+	"github.com/gophish/gophish/bootstrap"
+	"github.com/gophish/gophish/cli"
 	"github.com/gophish/gophish/config"
 	"github.com/gophish/gophish/controllers"
 	"github.com/gophish/gophish/dialer"
+	"github.com/gophish/gophish/dnsprovider"
 	"github.com/gophish/gophish/imap"
+	"github.com/gophish/gophish/leader"
 	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/mailer"
 	"github.com/gophish/gophish/middleware"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/plugins"
+	"github.com/gophish/gophish/proxyranges"
+	"github.com/gophish/gophish/reload"
+	"github.com/gophish/gophish/reputation"
+	"github.com/gophish/gophish/secrets"
+	"github.com/gophish/gophish/spam"
+	"github.com/gophish/gophish/tracing"
 	"github.com/gophish/gophish/webhook"
+	"github.com/gophish/gophish/worker"
 )
 
 const (
@@ -55,20 +73,46 @@ var (
 	disableMailer = kingpin.Flag("disable-mailer", "Disable the mailer (for use with multi-system deployments)").Bool()
 	mode          = kingpin.Flag("mode", fmt.Sprintf("Run the binary in one of the modes (%s, %s or %s)", modeAll, modeAdmin, modePhish)).
 			Default("all").Enum(modeAll, modeAdmin, modePhish)
+
+	serveCommand       = kingpin.Command("serve", "Run the Gophish servers. This is the default command.").Default()
+	printConfigCommand = kingpin.Command("print-config", "Print the configuration, including any environment overrides, with secrets redacted.")
+	cliCommands        = cli.Register(kingpin.CommandLine)
 )
 
 func main() {
-	// Load the version
-
-	version, err := ioutil.ReadFile("./VERSION")
-	if err != nil {
-		log.Fatal(err)
-	}
+	// Load the version. The client commands can be run from any directory,
+	// so a missing version file is only fatal when running the servers.
+	version, versionErr := ioutil.ReadFile("./VERSION")
 	kingpin.Version(string(version))
 
 	// Parse the CLI flags and load the config
 	kingpin.CommandLine.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
+
+	// Print the configuration as it would be loaded when serving
+	if command == printConfigCommand.FullCommand() {
+		err := printConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run the client commands against the REST API
+	if command != serveCommand.FullCommand() {
+		ok, err := cliCommands.Run(command)
+		if ok {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if versionErr != nil {
+		log.Fatal(versionErr)
+	}
 
 	// Load the config
 	conf, err := config.LoadConfig(*configPath)
@@ -85,8 +129,16 @@ func main() {
 	// Configure our various upstream clients to make sure that we restrict
 	// outbound connections as needed.
 	dialer.SetAllowedHosts(conf.AdminConf.AllowedInternalHosts)
+	err = dialer.SetProxy(conf.Proxy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	proxyDialer, err := dialer.ProxyDialer("")
+	if err != nil {
+		log.Fatal(err)
+	}
 	webhook.SetTransport(&http.Transport{
-		DialContext: dialer.Dialer().DialContext,
+		DialContext: proxyDialer.DialContext,
 	})
 
 	err = log.Setup(conf.Logging)
@@ -94,6 +146,16 @@ func main() {
 		log.Fatal(err)
 	}
 
+	err = secrets.Setup(conf.Secrets)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auth.Policy, err = auth.NewPasswordPolicy(conf.AdminPasswordPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Provide the option to disable the built-in mailer
 	// Setup the global variables and settings
 	err = models.Setup(conf)
@@ -101,45 +163,195 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Load the event processors provided by plugins
+	err = plugins.Load(conf.Plugins)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Unlock any maillogs that may have been locked for processing
-	// when Gophish was last shutdown.
-	err = models.UnlockAllMailLogs()
+	// when Gophish was last shutdown. When running multiple instances,
+	// this is done by the leader once it's elected instead.
+	if !conf.LeaderElection.Enabled {
+		err = models.RecoverMailLogs()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Create any users, sending profiles, and webhooks declared in the
+	// bootstrap configuration
+	err = bootstrap.Setup(conf.BootstrapPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scorer, err := spam.NewScorer(conf.SpamScoring)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	models.DomainReputationChecker, err = reputation.NewChecker(conf.DomainReputation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	worker.DomainCheckInterval = conf.DomainReputation.Interval()
+	worker.PollInterval = conf.Worker.PollInterval()
+	worker.BatchSize = conf.Worker.BatchSize
+	mailer.MaxConcurrentBatches = conf.Worker.Concurrency
+
+	models.DNSProviders, err = dnsprovider.NewProviders(conf.DNSProviders)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Export traces to the OpenTelemetry collector, if one is configured
+	exporter, err := tracing.NewExporter(conf.Tracing)
 	if err != nil {
 		log.Fatal(err)
 	}
+	stopTracing := func() {}
+	if exporter != nil {
+		tracing.SetExporter(exporter)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			exporter.Run(ctx)
+			close(done)
+		}()
+		stopTracing = func() {
+			cancel()
+			<-done
+		}
+	}
 
 	// Create our servers
-	adminOptions := []controllers.AdminServerOption{}
-	if *disableMailer {
+	adminOptions := []controllers.AdminServerOption{controllers.WithSpamScorer(scorer)}
+	var electedWorker *worker.ElectedWorker
+	switch {
+	case *disableMailer:
 		adminOptions = append(adminOptions, controllers.WithWorker(nil))
+	case conf.LeaderElection.Enabled:
+		electedWorker = worker.NewElectedWorker()
+		adminOptions = append(adminOptions, controllers.WithWorker(electedWorker))
 	}
 	adminConfig := conf.AdminConf
 	adminServer := controllers.NewAdminServer(adminConfig, adminOptions...)
 	middleware.Store.Options.Secure = adminConfig.UseTLS
+	models.AdminSessionPolicy = models.SessionPolicy{
+		IdleTimeout:   time.Duration(adminConfig.SessionIdleMinutes) * time.Minute,
+		Lifetime:      models.DefaultSessionLifetime,
+		SingleSession: adminConfig.SingleSession,
+	}
+	if adminConfig.SessionLifetimeMinutes > 0 {
+		models.AdminSessionPolicy.Lifetime = time.Duration(adminConfig.SessionLifetimeMinutes) * time.Minute
+	}
+	middleware.Store.MaxAge(int(models.AdminSessionPolicy.Lifetime / time.Second))
 
 	phishConfig := conf.PhishConf
-	phishServer := controllers.NewPhishingServer(phishConfig)
+	phishServer := controllers.NewPhishingServer(phishConfig, controllers.WithObjectStore(models.ObjectStore()))
 
 	imapMonitor := imap.NewMonitor()
+	stopElection := func() {}
 	if *mode == "admin" || *mode == "all" {
 		go adminServer.Start()
-		go imapMonitor.Start()
+		if conf.LeaderElection.Enabled {
+			stopElection = runElection(conf.LeaderElection, electedWorker, imapMonitor)
+		} else {
+			go imapMonitor.Start()
+		}
 	}
+	// Opens are recorded by the phishing server, so it keeps the ranges of
+	// the mail privacy proxies up to date
+	stopRanges := func() {}
 	if *mode == "phish" || *mode == "all" {
 		go phishServer.Start()
+		ctx, cancel := context.WithCancel(context.Background())
+		go proxyranges.Default.Refresh(ctx, conf.MachineOpens)
+		stopRanges = cancel
 	}
 
+	// Reload the log level and certificates on SIGHUP or through the API,
+	// without interrupting running campaigns
+	reload.Register("log level", func() error {
+		conf, err := config.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		return log.SetLevel(conf.Logging.Level)
+	})
+	reload.HandleSignals()
+
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
-	log.Info("CTRL+C Received... Gracefully shutting down servers")
+	log.Info("Shutdown signal received... Gracefully shutting down servers")
 	if *mode == modeAdmin || *mode == modeAll {
+		stopElection()
 		adminServer.Shutdown()
 		imapMonitor.Shutdown()
 	}
 	if *mode == modePhish || *mode == modeAll {
+		stopRanges()
 		phishServer.Shutdown()
 	}
+	// The spans of the emails sent while shutting down are exported last
+	stopTracing()
 
 }
+
+// runElection takes part in the leader election, only sending campaign emails
+// and polling IMAP mailboxes while this instance is the leader. The returned
+// function steps down and waits for the background work to stop, so that
+// another instance can take over straight away.
+func runElection(c config.LeaderElection, w *worker.ElectedWorker, im *imap.Monitor) func() {
+	ttl := time.Duration(c.LeaseSeconds) * time.Second
+	elector := leader.NewElector(c.InstanceID, ttl, leader.DatabaseLock{Name: leader.DefaultLeaseName})
+	elector.OnElected(func() {
+		if w != nil {
+			err := w.Promote()
+			if err != nil {
+				log.Error(err)
+			}
+		}
+		im.Start()
+	})
+	elector.OnDemoted(func() {
+		if w != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := w.Demote(ctx)
+			if err != nil {
+				log.Error(err)
+			}
+		}
+		im.Shutdown()
+	})
+	log.Infof("Leader election enabled for instance %s", elector.ID())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// printConfig prints the configuration loaded from the given path, so that
+// environment overrides can be checked before deploying them.
+func printConfig(path string) error {
+	conf, err := config.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(conf.Redact(), "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
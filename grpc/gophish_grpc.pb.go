@@ -0,0 +1,532 @@
+// The Gophish gRPC API. Timestamps are RFC 3339 strings, and are empty when
+// they aren't set.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: gophish.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Gophish_ListCampaigns_FullMethodName    = "/gophish.v1.Gophish/ListCampaigns"
+	Gophish_GetCampaign_FullMethodName      = "/gophish.v1.Gophish/GetCampaign"
+	Gophish_CreateCampaign_FullMethodName   = "/gophish.v1.Gophish/CreateCampaign"
+	Gophish_CompleteCampaign_FullMethodName = "/gophish.v1.Gophish/CompleteCampaign"
+	Gophish_DeleteCampaign_FullMethodName   = "/gophish.v1.Gophish/DeleteCampaign"
+	Gophish_StreamResults_FullMethodName    = "/gophish.v1.Gophish/StreamResults"
+	Gophish_ListGroups_FullMethodName       = "/gophish.v1.Gophish/ListGroups"
+	Gophish_GetGroup_FullMethodName         = "/gophish.v1.Gophish/GetGroup"
+	Gophish_CreateGroup_FullMethodName      = "/gophish.v1.Gophish/CreateGroup"
+	Gophish_UpdateGroup_FullMethodName      = "/gophish.v1.Gophish/UpdateGroup"
+	Gophish_DeleteGroup_FullMethodName      = "/gophish.v1.Gophish/DeleteGroup"
+)
+
+// GophishClient is the client API for Gophish service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GophishClient interface {
+	// ListCampaigns returns a summary of each campaign.
+	ListCampaigns(ctx context.Context, in *ListCampaignsRequest, opts ...grpc.CallOption) (*ListCampaignsResponse, error)
+	// GetCampaign returns a summary of the campaign.
+	GetCampaign(ctx context.Context, in *CampaignRequest, opts ...grpc.CallOption) (*Campaign, error)
+	// CreateCampaign creates and schedules a campaign.
+	CreateCampaign(ctx context.Context, in *CreateCampaignRequest, opts ...grpc.CallOption) (*Campaign, error)
+	// CompleteCampaign marks the campaign as complete.
+	CompleteCampaign(ctx context.Context, in *CampaignRequest, opts ...grpc.CallOption) (*Campaign, error)
+	// DeleteCampaign deletes the campaign and its results.
+	DeleteCampaign(ctx context.Context, in *CampaignRequest, opts ...grpc.CallOption) (*Empty, error)
+	// StreamResults streams the results of the campaign, in pages.
+	StreamResults(ctx context.Context, in *StreamResultsRequest, opts ...grpc.CallOption) (Gophish_StreamResultsClient, error)
+	// ListGroups returns a summary of each group, without its targets.
+	ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error)
+	// GetGroup returns the group and its targets.
+	GetGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*Group, error)
+	// CreateGroup creates a group.
+	CreateGroup(ctx context.Context, in *Group, opts ...grpc.CallOption) (*Group, error)
+	// UpdateGroup replaces the name and targets of the group.
+	UpdateGroup(ctx context.Context, in *Group, opts ...grpc.CallOption) (*Group, error)
+	// DeleteGroup deletes the group.
+	DeleteGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type gophishClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGophishClient(cc grpc.ClientConnInterface) GophishClient {
+	return &gophishClient{cc}
+}
+
+func (c *gophishClient) ListCampaigns(ctx context.Context, in *ListCampaignsRequest, opts ...grpc.CallOption) (*ListCampaignsResponse, error) {
+	out := new(ListCampaignsResponse)
+	err := c.cc.Invoke(ctx, Gophish_ListCampaigns_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) GetCampaign(ctx context.Context, in *CampaignRequest, opts ...grpc.CallOption) (*Campaign, error) {
+	out := new(Campaign)
+	err := c.cc.Invoke(ctx, Gophish_GetCampaign_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) CreateCampaign(ctx context.Context, in *CreateCampaignRequest, opts ...grpc.CallOption) (*Campaign, error) {
+	out := new(Campaign)
+	err := c.cc.Invoke(ctx, Gophish_CreateCampaign_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) CompleteCampaign(ctx context.Context, in *CampaignRequest, opts ...grpc.CallOption) (*Campaign, error) {
+	out := new(Campaign)
+	err := c.cc.Invoke(ctx, Gophish_CompleteCampaign_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) DeleteCampaign(ctx context.Context, in *CampaignRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Gophish_DeleteCampaign_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) StreamResults(ctx context.Context, in *StreamResultsRequest, opts ...grpc.CallOption) (Gophish_StreamResultsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Gophish_ServiceDesc.Streams[0], Gophish_StreamResults_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gophishStreamResultsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Gophish_StreamResultsClient interface {
+	Recv() (*Result, error)
+	grpc.ClientStream
+}
+
+type gophishStreamResultsClient struct {
+	grpc.ClientStream
+}
+
+func (x *gophishStreamResultsClient) Recv() (*Result, error) {
+	m := new(Result)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *gophishClient) ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error) {
+	out := new(ListGroupsResponse)
+	err := c.cc.Invoke(ctx, Gophish_ListGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) GetGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, Gophish_GetGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) CreateGroup(ctx context.Context, in *Group, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, Gophish_CreateGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) UpdateGroup(ctx context.Context, in *Group, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, Gophish_UpdateGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophishClient) DeleteGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Gophish_DeleteGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GophishServer is the server API for Gophish service.
+// All implementations must embed UnimplementedGophishServer
+// for forward compatibility
+type GophishServer interface {
+	// ListCampaigns returns a summary of each campaign.
+	ListCampaigns(context.Context, *ListCampaignsRequest) (*ListCampaignsResponse, error)
+	// GetCampaign returns a summary of the campaign.
+	GetCampaign(context.Context, *CampaignRequest) (*Campaign, error)
+	// CreateCampaign creates and schedules a campaign.
+	CreateCampaign(context.Context, *CreateCampaignRequest) (*Campaign, error)
+	// CompleteCampaign marks the campaign as complete.
+	CompleteCampaign(context.Context, *CampaignRequest) (*Campaign, error)
+	// DeleteCampaign deletes the campaign and its results.
+	DeleteCampaign(context.Context, *CampaignRequest) (*Empty, error)
+	// StreamResults streams the results of the campaign, in pages.
+	StreamResults(*StreamResultsRequest, Gophish_StreamResultsServer) error
+	// ListGroups returns a summary of each group, without its targets.
+	ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error)
+	// GetGroup returns the group and its targets.
+	GetGroup(context.Context, *GroupRequest) (*Group, error)
+	// CreateGroup creates a group.
+	CreateGroup(context.Context, *Group) (*Group, error)
+	// UpdateGroup replaces the name and targets of the group.
+	UpdateGroup(context.Context, *Group) (*Group, error)
+	// DeleteGroup deletes the group.
+	DeleteGroup(context.Context, *GroupRequest) (*Empty, error)
+	mustEmbedUnimplementedGophishServer()
+}
+
+// UnimplementedGophishServer must be embedded to have forward compatible implementations.
+type UnimplementedGophishServer struct {
+}
+
+func (UnimplementedGophishServer) ListCampaigns(context.Context, *ListCampaignsRequest) (*ListCampaignsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCampaigns not implemented")
+}
+func (UnimplementedGophishServer) GetCampaign(context.Context, *CampaignRequest) (*Campaign, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCampaign not implemented")
+}
+func (UnimplementedGophishServer) CreateCampaign(context.Context, *CreateCampaignRequest) (*Campaign, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCampaign not implemented")
+}
+func (UnimplementedGophishServer) CompleteCampaign(context.Context, *CampaignRequest) (*Campaign, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteCampaign not implemented")
+}
+func (UnimplementedGophishServer) DeleteCampaign(context.Context, *CampaignRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCampaign not implemented")
+}
+func (UnimplementedGophishServer) StreamResults(*StreamResultsRequest, Gophish_StreamResultsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamResults not implemented")
+}
+func (UnimplementedGophishServer) ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (UnimplementedGophishServer) GetGroup(context.Context, *GroupRequest) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroup not implemented")
+}
+func (UnimplementedGophishServer) CreateGroup(context.Context, *Group) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGroup not implemented")
+}
+func (UnimplementedGophishServer) UpdateGroup(context.Context, *Group) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGroup not implemented")
+}
+func (UnimplementedGophishServer) DeleteGroup(context.Context, *GroupRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGroup not implemented")
+}
+func (UnimplementedGophishServer) mustEmbedUnimplementedGophishServer() {}
+
+// UnsafeGophishServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GophishServer will
+// result in compilation errors.
+type UnsafeGophishServer interface {
+	mustEmbedUnimplementedGophishServer()
+}
+
+func RegisterGophishServer(s grpc.ServiceRegistrar, srv GophishServer) {
+	s.RegisterService(&Gophish_ServiceDesc, srv)
+}
+
+func _Gophish_ListCampaigns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCampaignsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).ListCampaigns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_ListCampaigns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).ListCampaigns(ctx, req.(*ListCampaignsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_GetCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).GetCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_GetCampaign_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).GetCampaign(ctx, req.(*CampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_CreateCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).CreateCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_CreateCampaign_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).CreateCampaign(ctx, req.(*CreateCampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_CompleteCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).CompleteCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_CompleteCampaign_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).CompleteCampaign(ctx, req.(*CampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_DeleteCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).DeleteCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_DeleteCampaign_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).DeleteCampaign(ctx, req.(*CampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_StreamResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamResultsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GophishServer).StreamResults(m, &gophishStreamResultsServer{stream})
+}
+
+type Gophish_StreamResultsServer interface {
+	Send(*Result) error
+	grpc.ServerStream
+}
+
+type gophishStreamResultsServer struct {
+	grpc.ServerStream
+}
+
+func (x *gophishStreamResultsServer) Send(m *Result) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Gophish_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_ListGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).ListGroups(ctx, req.(*ListGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_GetGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).GetGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_GetGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).GetGroup(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_CreateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Group)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).CreateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_CreateGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).CreateGroup(ctx, req.(*Group))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_UpdateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Group)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).UpdateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_UpdateGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).UpdateGroup(ctx, req.(*Group))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophish_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophishServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophish_DeleteGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophishServer).DeleteGroup(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Gophish_ServiceDesc is the grpc.ServiceDesc for Gophish service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Gophish_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gophish.v1.Gophish",
+	HandlerType: (*GophishServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCampaigns",
+			Handler:    _Gophish_ListCampaigns_Handler,
+		},
+		{
+			MethodName: "GetCampaign",
+			Handler:    _Gophish_GetCampaign_Handler,
+		},
+		{
+			MethodName: "CreateCampaign",
+			Handler:    _Gophish_CreateCampaign_Handler,
+		},
+		{
+			MethodName: "CompleteCampaign",
+			Handler:    _Gophish_CompleteCampaign_Handler,
+		},
+		{
+			MethodName: "DeleteCampaign",
+			Handler:    _Gophish_DeleteCampaign_Handler,
+		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _Gophish_ListGroups_Handler,
+		},
+		{
+			MethodName: "GetGroup",
+			Handler:    _Gophish_GetGroup_Handler,
+		},
+		{
+			MethodName: "CreateGroup",
+			Handler:    _Gophish_CreateGroup_Handler,
+		},
+		{
+			MethodName: "UpdateGroup",
+			Handler:    _Gophish_UpdateGroup_Handler,
+		},
+		{
+			MethodName: "DeleteGroup",
+			Handler:    _Gophish_DeleteGroup_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamResults",
+			Handler:       _Gophish_StreamResults_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gophish.proto",
+}
@@ -0,0 +1,208 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// setupTest serves the gRPC server over HTTP/2, returning a client connected
+// to it and the admin user's API key.
+func setupTest(t *testing.T) (GophishClient, string) {
+	conf := &config.Config{
+		DBName:         "sqlite3",
+		DBPath:         ":memory:",
+		MigrationsPath: "../db/db_sqlite3/migrations/",
+	}
+	err := models.Setup(conf)
+	if err != nil {
+		t.Fatalf("Failed creating database: %v", err)
+	}
+	u, err := models.GetUser(1)
+	if err != nil {
+		t.Fatalf("error getting admin user: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(NewServer())
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	conn, err := grpc.NewClient(ts.Listener.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "example.com")))
+	if err != nil {
+		t.Fatalf("error connecting to server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewGophishClient(conn), string(u.ApiKey)
+}
+
+func createTestData(t *testing.T) {
+	group := models.Group{Name: "Test Group", UserId: 1}
+	group.Targets = []models.Target{
+		{BaseRecipient: models.BaseRecipient{Email: "test1@example.com", FirstName: "First"}},
+		{BaseRecipient: models.BaseRecipient{Email: "test2@example.com", FirstName: "Second"}},
+	}
+	err := models.PostGroup(&group)
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+	template := models.Template{Name: "Test Template", Subject: "Test subject", Text: "Text", UserId: 1}
+	err = models.PostTemplate(&template)
+	if err != nil {
+		t.Fatalf("error creating template: %v", err)
+	}
+	p := models.Page{Name: "Test Page", HTML: "<html>Test</html>", UserId: 1}
+	err = models.PostPage(&p)
+	if err != nil {
+		t.Fatalf("error creating page: %v", err)
+	}
+	smtp := models.SMTP{Name: "Test SMTP", Host: "example.com:25", FromAddress: "test@example.com", UserId: 1}
+	err = models.PostSMTP(&smtp)
+	if err != nil {
+		t.Fatalf("error creating sending profile: %v", err)
+	}
+}
+
+// withKey returns a context which sends the API key with calls.
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestAuthentication(t *testing.T) {
+	c, _ := setupTest(t)
+	_, err := c.ListCampaigns(context.Background(), &ListCampaignsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unexpected status without an API key. expected %s got %v", codes.Unauthenticated, err)
+	}
+	_, err = c.ListCampaigns(withKey("invalid"), &ListCampaignsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unexpected status with an invalid API key. expected %s got %v", codes.Unauthenticated, err)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c, key := setupTest(t)
+	conn := c.(*gophishClient).cc
+	err := conn.Invoke(withKey(key), "/gophish.v1.Gophish/Unknown", &Empty{}, &Empty{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("unexpected status. expected %s got %v", codes.Unimplemented, err)
+	}
+}
+
+func TestCampaignLifecycle(t *testing.T) {
+	c, key := setupTest(t)
+	ctx := withKey(key)
+	createTestData(t)
+
+	req := &CreateCampaignRequest{
+		Name:     "gRPC Campaign",
+		Template: "Test Template",
+		Page:     "Test Page",
+		Smtp:     "Test SMTP",
+		Groups:   []string{"Test Group"},
+		Url:      "http://example.com",
+	}
+	campaign, err := c.CreateCampaign(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error creating campaign: %v", err)
+	}
+	if campaign.Name != req.Name || campaign.Stats == nil || campaign.Stats.Total != 2 {
+		t.Fatalf("unexpected campaign created: %+v", campaign)
+	}
+
+	// Results are streamed as separate messages
+	stream, err := c.StreamResults(ctx, &StreamResultsRequest{CampaignId: campaign.Id})
+	if err != nil {
+		t.Fatalf("unexpected error streaming results: %v", err)
+	}
+	results := []*Result{}
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error streaming results: %v", err)
+		}
+		results = append(results, r)
+	}
+	if len(results) != 2 {
+		t.Fatalf("unexpected number of results. expected %d got %d", 2, len(results))
+	}
+	if results[0].Email != "test1@example.com" || results[0].FirstName != "First" {
+		t.Fatalf("unexpected result streamed: %+v", results[0])
+	}
+
+	campaign, err = c.CompleteCampaign(ctx, &CampaignRequest{Id: campaign.Id})
+	if err != nil {
+		t.Fatalf("unexpected error completing campaign: %v", err)
+	}
+	if campaign.Status != models.CampaignComplete || campaign.CompletedDate == "" {
+		t.Fatalf("expected the campaign to be complete. got %+v", campaign)
+	}
+
+	_, err = c.DeleteCampaign(ctx, &CampaignRequest{Id: campaign.Id})
+	if err != nil {
+		t.Fatalf("unexpected error deleting campaign: %v", err)
+	}
+	_, err = c.GetCampaign(ctx, &CampaignRequest{Id: campaign.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected status getting a deleted campaign. expected %s got %v", codes.NotFound, err)
+	}
+}
+
+func TestCreateCampaignInvalid(t *testing.T) {
+	c, key := setupTest(t)
+	_, err := c.CreateCampaign(withKey(key), &CreateCampaignRequest{Name: "No groups"})
+	s := status.Convert(err)
+	if s.Code() != codes.InvalidArgument || s.Message() != models.ErrGroupNotSpecified.Error() {
+		t.Fatalf("unexpected status: %v", err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	c, key := setupTest(t)
+	ctx := withKey(key)
+	g := &Group{Name: "gRPC Group", Targets: []*Target{{Email: "foo@example.com"}}}
+	created, err := c.CreateGroup(ctx, g)
+	if err != nil {
+		t.Fatalf("unexpected error creating group: %v", err)
+	}
+	_, err = c.CreateGroup(ctx, g)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("unexpected status creating a duplicate group. expected %s got %v", codes.AlreadyExists, err)
+	}
+
+	created.Targets = append(created.Targets, &Target{Email: "bar@example.com"})
+	_, err = c.UpdateGroup(ctx, created)
+	if err != nil {
+		t.Fatalf("unexpected error updating group: %v", err)
+	}
+	gs, err := c.ListGroups(ctx, &ListGroupsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error listing groups: %v", err)
+	}
+	if len(gs.Groups) != 1 || gs.Groups[0].NumTargets != 2 {
+		t.Fatalf("unexpected groups listed: %+v", gs.Groups)
+	}
+
+	_, err = c.DeleteGroup(ctx, &GroupRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("unexpected error deleting group: %v", err)
+	}
+	_, err = c.GetGroup(ctx, &GroupRequest{Id: created.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected status getting a deleted group. expected %s got %v", codes.NotFound, err)
+	}
+}
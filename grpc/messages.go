@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/gophish/gophish/models"
+)
+
+// Conversions between the models and the messages generated from
+// gophish.proto
+
+func newCampaign(cs models.CampaignSummary) *Campaign {
+	return &Campaign{
+		Id:            cs.Id,
+		Name:          cs.Name,
+		Status:        cs.Status,
+		CreatedDate:   formatTime(cs.CreatedDate),
+		LaunchDate:    formatTime(cs.LaunchDate),
+		SendByDate:    formatTime(cs.SendByDate),
+		CompletedDate: formatTime(cs.CompletedDate),
+		Stats: &CampaignStats{
+			Total:         cs.Stats.Total,
+			Sent:          cs.Stats.EmailsSent,
+			Opened:        cs.Stats.OpenedEmail,
+			Clicked:       cs.Stats.ClickedLink,
+			SubmittedData: cs.Stats.SubmittedData,
+			EmailReported: cs.Stats.EmailReported,
+			Error:         cs.Stats.Error,
+		},
+	}
+}
+
+func newResult(r models.Result) *Result {
+	return &Result{
+		Id:           r.RId,
+		Email:        r.Email,
+		FirstName:    r.FirstName,
+		LastName:     r.LastName,
+		Position:     r.Position,
+		Status:       r.Status,
+		Ip:           r.IP,
+		Latitude:     r.Latitude,
+		Longitude:    r.Longitude,
+		SendDate:     formatTime(r.SendDate),
+		ModifiedDate: formatTime(r.ModifiedDate),
+		Reported:     r.Reported,
+	}
+}
+
+func newGroup(g models.Group) *Group {
+	m := &Group{
+		Id:           g.Id,
+		Name:         g.Name,
+		ModifiedDate: formatTime(g.ModifiedDate),
+		NumTargets:   int64(len(g.Targets)),
+	}
+	for _, t := range g.Targets {
+		m.Targets = append(m.Targets, &Target{
+			Email:     t.Email,
+			FirstName: t.FirstName,
+			LastName:  t.LastName,
+			Position:  t.Position,
+		})
+	}
+	return m
+}
+
+// groupModel returns the group as a models.Group owned by the user.
+func groupModel(m *Group, uid int64) models.Group {
+	g := models.Group{
+		Id:           m.Id,
+		UserId:       uid,
+		Name:         m.Name,
+		ModifiedDate: time.Now().UTC(),
+		Targets:      []models.Target{},
+	}
+	for _, t := range m.Targets {
+		g.Targets = append(g.Targets, models.Target{BaseRecipient: models.BaseRecipient{
+			Email:     t.Email,
+			FirstName: t.FirstName,
+			LastName:  t.LastName,
+			Position:  t.Position,
+		}})
+	}
+	return g
+}
+
+// formatTime formats the time as RFC 3339, leaving zero times empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseTime parses an RFC 3339 time, returning the zero time if it's empty.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MaxMessageSize is the largest request message accepted, in bytes.
+const MaxMessageSize = 4 << 20
+
+// modifyMethods are the methods which change objects, which users need the
+// PermissionModifyObjects permission to call.
+var modifyMethods = map[string]bool{
+	Gophish_CreateCampaign_FullMethodName:   true,
+	Gophish_CompleteCampaign_FullMethodName: true,
+	Gophish_DeleteCampaign_FullMethodName:   true,
+	Gophish_CreateGroup_FullMethodName:      true,
+	Gophish_UpdateGroup_FullMethodName:      true,
+	Gophish_DeleteGroup_FullMethodName:      true,
+}
+
+// Server serves the gRPC API. It's an http.Handler, which must be served
+// over HTTP/2.
+type Server struct {
+	UnimplementedGophishServer
+	worker worker.Worker
+	server *grpc.Server
+}
+
+// ServerOption is a functional option that is used to configure the gRPC
+// server.
+type ServerOption func(*Server)
+
+// NewServer returns a new gRPC server with the provided options applied.
+func NewServer(options ...ServerOption) *Server {
+	s := &Server{}
+	for _, opt := range options {
+		opt(s)
+	}
+	s.server = grpc.NewServer(
+		grpc.MaxRecvMsgSize(MaxMessageSize),
+		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.StreamInterceptor(streamInterceptor),
+	)
+	RegisterGophishServer(s.server, s)
+	return s
+}
+
+// WithWorker is an option that sets the background worker which campaigns
+// are launched with. Without a worker, campaigns are launched when the
+// worker next checks for queued emails.
+func WithWorker(w worker.Worker) ServerOption {
+	return func(s *Server) {
+		s.worker = w
+	}
+}
+
+// IsRequest returns whether the request is a gRPC call.
+func IsRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// ServeHTTP serves a gRPC call.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.server.ServeHTTP(w, r)
+}
+
+// userKey is the context key of the user making the call.
+type userKey struct{}
+
+// contextUser returns the user making the call.
+func contextUser(ctx context.Context) models.User {
+	u, _ := ctx.Value(userKey{}).(models.User)
+	return u
+}
+
+// authenticate looks up the user from the API key sent as
+// "authorization: Bearer <key>" metadata, and checks that they're allowed
+// to call the method. It returns the context with the user added.
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var key string
+	if values := md.Get("authorization"); len(values) > 0 {
+		key = strings.TrimPrefix(values[0], "Bearer ")
+	}
+	if key == "" {
+		return nil, status.Error(codes.Unauthenticated, "API Key not set")
+	}
+	u, err := models.GetUserByAPIKey(key)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid API Key")
+	}
+	if modifyMethods[method] {
+		access, err := u.HasPermission(models.PermissionModifyObjects)
+		if err != nil {
+			return nil, internalError(method, err)
+		}
+		if !access {
+			return nil, status.Error(codes.PermissionDenied, "Permission denied")
+		}
+	}
+	return context.WithValue(ctx, userKey{}, u), nil
+}
+
+// internalError logs errors which aren't a gRPC status, and returns an
+// Internal status in their place so that they aren't sent to the client.
+func internalError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	log.Errorf("error serving gRPC call %s: %v", method, err)
+	return status.Error(codes.Internal, "Internal error")
+}
+
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	return resp, internalError(info.FullMethod, err)
+}
+
+// authenticatedStream is a server stream with the user added to its context.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	err = handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	return internalError(info.FullMethod, err)
+}
@@ -0,0 +1,232 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gophish/gophish/models"
+	"github.com/jinzhu/gorm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resultsPageSize is the number of results loaded at a time when streaming
+// the results of a campaign.
+const resultsPageSize = 500
+
+// notFound returns a NotFound status if the record wasn't found, or the
+// error otherwise.
+func notFound(err error, message string) error {
+	if err == gorm.ErrRecordNotFound {
+		return status.Error(codes.NotFound, message)
+	}
+	return err
+}
+
+// ListCampaigns returns a summary of each campaign.
+func (s *Server) ListCampaigns(ctx context.Context, req *ListCampaignsRequest) (*ListCampaignsResponse, error) {
+	u := contextUser(ctx)
+	cs, err := models.GetCampaignSummaries(u.Id)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ListCampaignsResponse{}
+	for _, c := range cs.Campaigns {
+		resp.Campaigns = append(resp.Campaigns, newCampaign(c))
+	}
+	return resp, nil
+}
+
+// GetCampaign returns a summary of the campaign.
+func (s *Server) GetCampaign(ctx context.Context, req *CampaignRequest) (*Campaign, error) {
+	u := contextUser(ctx)
+	cs, err := models.GetCampaignSummary(req.Id, u.Id)
+	if err != nil {
+		return nil, notFound(err, "Campaign not found")
+	}
+	return newCampaign(cs), nil
+}
+
+// CreateCampaign creates and schedules a campaign.
+func (s *Server) CreateCampaign(ctx context.Context, req *CreateCampaignRequest) (*Campaign, error) {
+	u := contextUser(ctx)
+	c := models.Campaign{
+		Name:     req.Name,
+		Template: models.Template{Name: req.Template},
+		Page:     models.Page{Name: req.Page},
+		SMTP:     models.SMTP{Name: req.Smtp},
+		URL:      req.Url,
+	}
+	for _, name := range req.Groups {
+		c.Groups = append(c.Groups, models.Group{Name: name})
+	}
+	var err error
+	c.LaunchDate, err = parseTime(req.LaunchDate)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid launch date")
+	}
+	c.SendByDate, err = parseTime(req.SendByDate)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid send by date")
+	}
+	err = models.PostCampaign(&c, u.Id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// If the campaign is scheduled to launch immediately, send it to the
+	// worker. Otherwise, the worker will pick it up at the scheduled time
+	if c.Status == models.CampaignInProgress && s.worker != nil {
+		go s.worker.LaunchCampaign(c)
+	}
+	cs, err := models.GetCampaignSummary(c.Id, u.Id)
+	if err != nil {
+		return nil, err
+	}
+	return newCampaign(cs), nil
+}
+
+// CompleteCampaign marks the campaign as complete.
+func (s *Server) CompleteCampaign(ctx context.Context, req *CampaignRequest) (*Campaign, error) {
+	u := contextUser(ctx)
+	_, err := models.GetCampaignSummary(req.Id, u.Id)
+	if err != nil {
+		return nil, notFound(err, "Campaign not found")
+	}
+	err = models.CompleteCampaign(req.Id, u.Id)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := models.GetCampaignSummary(req.Id, u.Id)
+	if err != nil {
+		return nil, err
+	}
+	return newCampaign(cs), nil
+}
+
+// DeleteCampaign deletes the campaign and its results.
+func (s *Server) DeleteCampaign(ctx context.Context, req *CampaignRequest) (*Empty, error) {
+	u := contextUser(ctx)
+	_, err := models.GetCampaignSummary(req.Id, u.Id)
+	if err != nil {
+		return nil, notFound(err, "Campaign not found")
+	}
+	err = models.DeleteCampaign(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, nil
+}
+
+// StreamResults sends each of the campaign's results, loading them a page
+// at a time so that large campaigns aren't held in memory.
+func (s *Server) StreamResults(req *StreamResultsRequest, stream Gophish_StreamResultsServer) error {
+	u := contextUser(stream.Context())
+	_, err := models.GetCampaignSummary(req.CampaignId, u.Id)
+	if err != nil {
+		return notFound(err, "Campaign not found")
+	}
+	opts := models.ListOptions{
+		Limit:   resultsPageSize,
+		Sort:    "id",
+		Filters: map[string]string{"campaign_id": strconv.FormatInt(req.CampaignId, 10)},
+	}
+	if req.Status != "" {
+		opts.Filters["status"] = req.Status
+	}
+	for {
+		rs, page, err := models.ListResults(u.Id, opts)
+		if err != nil {
+			return err
+		}
+		for _, result := range rs {
+			err = stream.Send(newResult(result))
+			if err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// ListGroups returns a summary of each group, without its targets.
+func (s *Server) ListGroups(ctx context.Context, req *ListGroupsRequest) (*ListGroupsResponse, error) {
+	u := contextUser(ctx)
+	gs, err := models.GetGroupSummaries(u.Id)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ListGroupsResponse{}
+	for _, g := range gs.Groups {
+		resp.Groups = append(resp.Groups, &Group{
+			Id:           g.Id,
+			Name:         g.Name,
+			ModifiedDate: formatTime(g.ModifiedDate),
+			NumTargets:   g.NumTargets,
+		})
+	}
+	return resp, nil
+}
+
+// GetGroup returns the group and its targets.
+func (s *Server) GetGroup(ctx context.Context, req *GroupRequest) (*Group, error) {
+	u := contextUser(ctx)
+	g, err := models.GetGroup(req.Id, u.Id)
+	if err != nil {
+		return nil, notFound(err, "Group not found")
+	}
+	return newGroup(g), nil
+}
+
+// CreateGroup creates a group.
+func (s *Server) CreateGroup(ctx context.Context, req *Group) (*Group, error) {
+	u := contextUser(ctx)
+	g := groupModel(req, u.Id)
+	g.Id = 0
+	_, err := models.GetGroupByName(g.Name, u.Id)
+	if err != gorm.ErrRecordNotFound {
+		return nil, status.Error(codes.AlreadyExists, "Group name already in use")
+	}
+	err = models.PostGroup(&g)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return newGroup(g), nil
+}
+
+// UpdateGroup replaces the name and targets of the group.
+func (s *Server) UpdateGroup(ctx context.Context, req *Group) (*Group, error) {
+	u := contextUser(ctx)
+	g := groupModel(req, u.Id)
+	existing, err := models.GetGroup(g.Id, u.Id)
+	if err != nil {
+		return nil, notFound(err, "Group not found")
+	}
+	// Updating the group shouldn't unlink it from the tool managing it
+	g.ExternalId = existing.ExternalId
+	err = models.PutGroup(&g)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	g, err = models.GetGroup(g.Id, u.Id)
+	if err != nil {
+		return nil, err
+	}
+	return newGroup(g), nil
+}
+
+// DeleteGroup deletes the group.
+func (s *Server) DeleteGroup(ctx context.Context, req *GroupRequest) (*Empty, error) {
+	u := contextUser(ctx)
+	g, err := models.GetGroup(req.Id, u.Id)
+	if err != nil {
+		return nil, notFound(err, "Group not found")
+	}
+	err = models.DeleteGroup(&g)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, nil
+}
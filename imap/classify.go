@@ -0,0 +1,169 @@
+package imap
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gophish/gophish/models"
+	"github.com/jordan-wright/email"
+)
+
+// autoReplySubjects are the subject prefixes used by common mail clients for
+// automatic replies
+var autoReplySubjects = []string{
+	"automatic reply:",
+	"auto reply:",
+	"auto-reply:",
+	"autoreply:",
+	"out of office",
+	"out of the office",
+}
+
+// isAutoReply returns whether the email is an automatic reply, such as an
+// out of office message. Automatic replies are identified by the headers
+// defined in RFC 3834, the headers set by some servers which predate it, or
+// the subject.
+func isAutoReply(em *email.Email) bool {
+	if as := strings.ToLower(em.Headers.Get("Auto-Submitted")); as != "" && as != "no" {
+		return true
+	}
+	if em.Headers.Get("X-Autoreply") != "" || em.Headers.Get("X-Autorespond") != "" {
+		return true
+	}
+	if strings.ToLower(em.Headers.Get("Precedence")) == "auto_reply" {
+		return true
+	}
+	subject := strings.ToLower(strings.TrimSpace(em.Subject))
+	for _, prefix := range autoReplySubjects {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isForward returns whether the email was sent to one of the forwarding
+// addresses, rather than reported to the monitored mailbox.
+func isForward(em *email.Email, forwardAddresses []string) bool {
+	if len(forwardAddresses) == 0 {
+		return false
+	}
+	recipients := append(append([]string{}, em.To...), em.Cc...)
+	for _, r := range recipients {
+		addr := strings.ToLower(senderAddress(r))
+		for _, f := range forwardAddresses {
+			if addr == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// senderAddress returns the email address of a header such as
+// "Name <name@example.com>", or the header itself if it can't be parsed.
+func senderAddress(header string) string {
+	a, err := mail.ParseAddress(header)
+	if err != nil {
+		return strings.TrimSpace(header)
+	}
+	return a.Address
+}
+
+// The scores at which reported messages are classified as likely phishing
+// or suspicious
+const (
+	likelyPhishScore = 5
+	suspiciousScore  = 2
+)
+
+// riskyExtensions are the extensions of attachments commonly used to deliver
+// malware or credential phishing pages
+var riskyExtensions = map[string]bool{
+	".exe": true, ".scr": true, ".com": true, ".bat": true, ".cmd": true,
+	".js": true, ".jse": true, ".vbs": true, ".vbe": true, ".wsf": true,
+	".hta": true, ".lnk": true, ".iso": true, ".img": true, ".html": true,
+	".htm": true, ".shtml": true, ".svg": true, ".docm": true, ".xlsm": true,
+	".pptm": true, ".xlam": true, ".one": true,
+}
+
+// lureWords are common in the subjects of phishing emails
+var lureWords = []string{
+	"urgent", "password", "verify", "suspended", "locked", "invoice",
+	"payment", "wire transfer", "gift card", "action required", "expire",
+}
+
+// authFailure matches failed SPF, DKIM and DMARC checks in the
+// Authentication-Results header
+var authFailure = regexp.MustCompile(`\b(spf|dkim|dmarc)=(fail|softfail|permerror)\b`)
+
+// addressDomain returns the lowercase domain of an address header.
+func addressDomain(header string) string {
+	addr := senderAddress(header)
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return strings.ToLower(addr[i+1:])
+	}
+	return ""
+}
+
+// classifyReport scores the signs of phishing in a reported message, such as
+// failed sender authentication, links to IP addresses and risky attachments.
+// It returns the message's classification, its score and the reasons for it.
+func classifyReport(em *email.Email, observables []models.Observable) (string, int, []string) {
+	score := 0
+	reasons := []string{}
+	flag := func(points int, reason string) {
+		score += points
+		reasons = append(reasons, reason)
+	}
+	results := strings.ToLower(strings.Join(em.Headers["Authentication-Results"], " "))
+	for _, m := range authFailure.FindAllStringSubmatch(results, -1) {
+		points := 2
+		if m[1] == "dmarc" {
+			points = 3
+		}
+		flag(points, fmt.Sprintf("%s check failed", strings.ToUpper(m[1])))
+	}
+	fromDomain := addressDomain(em.From)
+	if replyTo := em.Headers.Get("Reply-To"); replyTo != "" {
+		if d := addressDomain(replyTo); d != "" && d != fromDomain {
+			flag(2, fmt.Sprintf("Reply-To domain %s differs from the sender's domain %s", d, fromDomain))
+		}
+	}
+	if from, err := mail.ParseAddress(em.From); err == nil && strings.Contains(from.Name, "@") &&
+		!strings.Contains(strings.ToLower(from.Name), strings.ToLower(from.Address)) {
+		flag(2, "The sender's display name contains a different email address")
+	}
+	for _, o := range observables {
+		switch o.Type {
+		case models.ObservableURL:
+			u, err := url.Parse(o.Value)
+			if err == nil && net.ParseIP(u.Hostname()) != nil {
+				flag(3, fmt.Sprintf("Links to an IP address: %s", o.Value))
+			}
+		case models.ObservableFilename:
+			if ext := strings.ToLower(filepath.Ext(o.Value)); riskyExtensions[ext] {
+				flag(3, fmt.Sprintf("Risky attachment: %s", o.Value))
+			}
+		}
+	}
+	subject := strings.ToLower(em.Subject)
+	for _, w := range lureWords {
+		if strings.Contains(subject, w) {
+			flag(1, fmt.Sprintf("The subject contains %q", w))
+			break
+		}
+	}
+	switch {
+	case score >= likelyPhishScore:
+		return models.ReportClassLikelyPhish, score, reasons
+	case score >= suspiciousScore:
+		return models.ReportClassSuspicious, score, reasons
+	}
+	return models.ReportClassLowRisk, score, reasons
+}
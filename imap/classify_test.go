@@ -0,0 +1,88 @@
+package imap
+
+import (
+	"net/textproto"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+	"github.com/jordan-wright/email"
+)
+
+func TestIsAutoReply(t *testing.T) {
+	tests := []struct {
+		headers  map[string]string
+		subject  string
+		expected bool
+	}{
+		{subject: "Fwd: Your invoice", expected: false},
+		{subject: "Automatic reply: Your invoice", expected: true},
+		{subject: "Out of Office: Your invoice", expected: true},
+		{headers: map[string]string{"Auto-Submitted": "auto-replied"}, subject: "Re: Your invoice", expected: true},
+		{headers: map[string]string{"Auto-Submitted": "no"}, subject: "Re: Your invoice", expected: false},
+		{headers: map[string]string{"X-Autoreply": "yes"}, subject: "Re: Your invoice", expected: true},
+		{headers: map[string]string{"Precedence": "auto_reply"}, subject: "Re: Your invoice", expected: true},
+	}
+	for _, tc := range tests {
+		em := email.NewEmail()
+		em.Headers = textproto.MIMEHeader{}
+		for k, v := range tc.headers {
+			em.Headers.Set(k, v)
+		}
+		em.Subject = tc.subject
+		if got := isAutoReply(em); got != tc.expected {
+			t.Fatalf("unexpected result for %v %q. expected %v got %v", tc.headers, tc.subject, tc.expected, got)
+		}
+	}
+}
+
+func TestIsForward(t *testing.T) {
+	em := email.NewEmail()
+	em.To = []string{"Security <security@example.com>"}
+	em.Cc = []string{"Helpdesk <HelpDesk@example.com>"}
+	if isForward(em, []string{}) {
+		t.Fatalf("expected the email not to be a forward without forwarding addresses")
+	}
+	if !isForward(em, []string{"helpdesk@example.com"}) {
+		t.Fatalf("expected the email copied to the helpdesk to be a forward")
+	}
+	if isForward(em, []string{"it@example.com"}) {
+		t.Fatalf("expected the email not to be a forward")
+	}
+}
+
+func TestClassifyReport(t *testing.T) {
+	tests := []struct {
+		from           string
+		headers        map[string]string
+		subject        string
+		observables    []models.Observable
+		classification string
+		score          int
+	}{
+		{from: "news@example.org", subject: "Weekly news", classification: models.ReportClassLowRisk, score: 0},
+		{from: "it@example.org", subject: "Verify your password", classification: models.ReportClassLowRisk, score: 1},
+		{from: "it@example.org", headers: map[string]string{"Reply-To": "help@example.net"}, subject: "Hello",
+			classification: models.ReportClassSuspicious, score: 2},
+		{from: "ceo@example.org", headers: map[string]string{"Authentication-Results": "mx.example.com; spf=softfail smtp.mailfrom=example.org; dmarc=fail header.from=example.org"},
+			subject: "Wire transfer", classification: models.ReportClassLikelyPhish, score: 6},
+		{from: "billing@example.org", subject: "Invoice",
+			observables:    []models.Observable{{Type: models.ObservableFilename, Value: "Invoice.HTML"}, {Type: models.ObservableURL, Value: "http://198.51.100.7/login"}},
+			classification: models.ReportClassLikelyPhish, score: 7},
+	}
+	for _, tc := range tests {
+		em := email.NewEmail()
+		em.From = tc.from
+		em.Subject = tc.subject
+		em.Headers = textproto.MIMEHeader{}
+		for k, v := range tc.headers {
+			em.Headers.Set(k, v)
+		}
+		classification, score, reasons := classifyReport(em, tc.observables)
+		if classification != tc.classification || score != tc.score {
+			t.Fatalf("unexpected classification of %q. expected %s (%d) got %s (%d): %v", tc.subject, tc.classification, tc.score, classification, score, reasons)
+		}
+		if len(reasons) == 0 && score > 0 {
+			t.Fatalf("expected reasons for the score of %q", tc.subject)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package imap
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"regexp"
@@ -44,6 +45,36 @@ type Mailbox struct {
 	Folder           string
 	// Read only mode, false (original logic) if not initialized
 	ReadOnly bool
+	// Token returns the OAuth2 access token to log in with. If it's set,
+	// the password isn't used.
+	Token func() (string, error)
+}
+
+// NewMailbox returns the mailbox for the IMAP settings, resolving the
+// password or setting up the OAuth2 access tokens needed to log in.
+func NewMailbox(im models.IMAP) (*Mailbox, error) {
+	mbox := &Mailbox{
+		Host:             im.Host + ":" + strconv.Itoa(int(im.Port)),
+		TLS:              im.TLS,
+		IgnoreCertErrors: im.IgnoreCertErrors,
+		User:             im.Username,
+		Folder:           im.Folder,
+	}
+	switch im.AuthMethod {
+	case "", models.IMAPAuthPassword:
+		password, err := models.ResolveSecret(string(im.Password), im.UserId)
+		if err != nil {
+			return nil, err
+		}
+		mbox.Pwd = password
+	default:
+		mbox.Token = func() (string, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), OAuth2Timeout)
+			defer cancel()
+			return accessToken(ctx, im)
+		}
+	}
+	return mbox, nil
 }
 
 // Validate validates supplied IMAP model by connecting to the server
@@ -54,14 +85,11 @@ func Validate(s *models.IMAP) error {
 		return err
 	}
 
-	s.Host = s.Host + ":" + strconv.Itoa(int(s.Port)) // Append port
-	mailServer := Mailbox{
-		Host:             s.Host,
-		TLS:              s.TLS,
-		IgnoreCertErrors: s.IgnoreCertErrors,
-		User:             s.Username,
-		Pwd:              s.Password,
-		Folder:           s.Folder}
+	mailServer, err := NewMailbox(*s)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 
 	imapClient, err := mailServer.newClient()
 	if err != nil {
@@ -185,7 +213,10 @@ func (mbox *Mailbox) GetUnread(markAsRead, delete bool) ([]Email, error) {
 func (mbox *Mailbox) newClient() (*client.Client, error) {
 	var imapClient *client.Client
 	var err error
-	restrictedDialer := dialer.Dialer()
+	restrictedDialer, err := dialer.ProxyDialer("")
+	if err != nil {
+		return nil, err
+	}
 	if mbox.TLS {
 		config := new(tls.Config)
 		config.InsecureSkipVerify = mbox.IgnoreCertErrors
@@ -197,7 +228,16 @@ func (mbox *Mailbox) newClient() (*client.Client, error) {
 		return imapClient, err
 	}
 
-	err = imapClient.Login(mbox.User, mbox.Pwd)
+	if mbox.Token != nil {
+		var token string
+		token, err = mbox.Token()
+		if err != nil {
+			return imapClient, err
+		}
+		err = imapClient.Authenticate(&xoauth2{username: mbox.User, token: token})
+	} else {
+		err = imapClient.Login(mbox.User, mbox.Pwd)
+	}
 	if err != nil {
 		return imapClient, err
 	}
@@ -11,14 +11,15 @@ import (
 	"context"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
 	"github.com/jordan-wright/email"
 
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/secrets"
 )
 
 // Pattern for GoPhish emails e.g ?rid=AbC1234
@@ -29,6 +30,7 @@ var goPhishRegex = regexp.MustCompile("((\\?|%3F)rid(=|%3D)(3D)?([A-Za-z0-9]{7})
 // Monitor is a worker that monitors IMAP servers for reported campaign emails
 type Monitor struct {
 	cancel func()
+	wg     sync.WaitGroup
 }
 
 // Monitor.start() checks for campaign emails
@@ -51,14 +53,29 @@ func (im *Monitor) start(ctx context.Context) {
 				if _, ok := usermap[dbuser.Id]; !ok { // If we don't currently have a running Go routine for this user, start one.
 					log.Info("Starting new IMAP monitor for user ", dbuser.Username)
 					usermap[dbuser.Id] = 1
-					go monitor(dbuser.Id, ctx)
+					im.wg.Add(1)
+					go func(uid int64) {
+						defer im.wg.Done()
+						monitor(uid, ctx)
+					}(dbuser.Id)
 				}
 			}
-			time.Sleep(10 * time.Second) // Every ten seconds we check if a new user has been created
+			sleep(ctx, 10*time.Second) // Every ten seconds we check if a new user has been created
 		}
 	}
 }
 
+// sleep waits for the given duration, returning early if the context is
+// cancelled so that shutting down isn't delayed.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
 // monitor will continuously login to the IMAP settings associated to the supplied user id (if the user account has IMAP settings, and they're enabled.)
 // It also verifies the user account exists, and returns if not (for the case of a user being deleted).
 func monitor(uid int64, ctx context.Context) {
@@ -85,11 +102,11 @@ func monitor(uid int64, ctx context.Context) {
 				if im.Enabled {
 					log.Debug("Checking IMAP for user ", uid, ": ", im.Username, " -> ", im.Host)
 					checkForNewEmails(im)
-					time.Sleep((time.Duration(im.IMAPFreq) - 10) * time.Second) // Subtract 10 to compensate for the default sleep of 10 at the bottom
+					sleep(ctx, (time.Duration(im.IMAPFreq)-10)*time.Second) // Subtract 10 to compensate for the default sleep of 10 at the bottom
 				}
 			}
 		}
-		time.Sleep(10 * time.Second)
+		sleep(ctx, 10*time.Second)
 	}
 }
 
@@ -104,38 +121,60 @@ func (im *Monitor) Start() error {
 	log.Info("Starting IMAP monitor manager")
 	ctx, cancel := context.WithCancel(context.Background()) // ctx is the derivedContext
 	im.cancel = cancel
-	go im.start(ctx)
+	im.wg.Add(1)
+	go func() {
+		defer im.wg.Done()
+		im.start(ctx)
+	}()
 	return nil
 }
 
-// Shutdown attempts to gracefully shutdown the IMAP monitor.
+// Shutdown attempts to gracefully shutdown the IMAP monitor, waiting for any
+// mailboxes being checked to be logged out of.
 func (im *Monitor) Shutdown() error {
 	log.Info("Shutting down IMAP monitor manager")
+	if im.cancel == nil {
+		return nil
+	}
 	im.cancel()
+	done := make(chan struct{})
+	go func() {
+		im.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		log.Warn("Timed out waiting for the IMAP monitors to stop")
+	}
 	return nil
 }
 
 // checkForNewEmails logs into an IMAP account and checks unread emails
 //  for the rid campaign identifier.
 func checkForNewEmails(im models.IMAP) {
-	im.Host = im.Host + ":" + strconv.Itoa(int(im.Port)) // Append port
-	mailServer := Mailbox{
-		Host:             im.Host,
-		TLS:              im.TLS,
-		IgnoreCertErrors: im.IgnoreCertErrors,
-		User:             im.Username,
-		Pwd:              im.Password,
-		Folder:           im.Folder,
+	mailServer, err := NewMailbox(im)
+	if err != nil {
+		log.Error(err)
+		return
 	}
 
 	msgs, err := mailServer.GetUnread(true, false)
 	if err != nil {
 		log.Error(err)
+		// The credentials may have been rotated, so make sure the secret
+		// is fetched again on the next attempt.
+		secrets.Invalidate(string(im.Password))
 		return
 	}
 	// Update last_succesful_login here via im.Host
 	err = models.SuccessfulLogin(&im)
 
+	forwardAddresses, err := im.ForwardAddressList()
+	if err != nil {
+		log.Error(err)
+	}
+
 	if len(msgs) > 0 {
 		log.Debugf("%d new emails for %s", len(msgs), im.Username)
 		var reportingFailed []uint32 // SeqNums of emails that were unable to be reported to phishing server, mark as unread
@@ -157,23 +196,54 @@ func checkForNewEmails(im models.IMAP) {
 				log.Errorf("Error searching email for rids from user '%s': %s", m.Email.From, err.Error())
 				continue
 			}
-			if len(rids) < 1 {
-				// In the future this should be an alert in Gophish
+			autoReply := isAutoReply(m.Email)
+			forwarded := !autoReply && isForward(m.Email, forwardAddresses)
+			// Automatic replies often don't quote the campaign email, so they're
+			// matched to the recipient's latest result instead
+			if len(rids) < 1 && autoReply {
+				result, err := models.GetLatestResultByEmail(im.UserId, senderAddress(m.Email.From))
+				if err == nil {
+					rids[result.RId] = true
+				}
+			}
+			if len(rids) < 1 && !autoReply {
+				// Emails which aren't part of a campaign are kept for triage
 				log.Infof("User '%s' reported email with subject '%s'. This is not a GoPhish campaign; you should investigate it.", m.Email.From, m.Email.Subject)
+				rm := newReportedMessage(im.UserId, m.Email)
+				err = models.PostReportedMessage(&rm)
+				if err != nil {
+					log.Error("Error saving reported email: ", err.Error())
+				}
+				err = models.PushReportedEmail(im.UserId, rm.ReportedEmail())
+				if err != nil {
+					log.Error("Error pushing reported email to incident connectors: ", err.Error())
+				}
 			}
 			for rid := range rids {
-				log.Infof("User '%s' reported email with rid %s", m.Email.From, rid)
 				result, err := models.GetResult(rid)
 				if err != nil {
 					log.Error("Error reporting GoPhish email with rid ", rid, ": ", err.Error())
 					reportingFailed = append(reportingFailed, m.SeqNum)
 					continue
 				}
-				err = result.HandleEmailReport(models.EventDetails{})
+				switch {
+				case autoReply:
+					log.Infof("User '%s' sent an automatic reply to email with rid %s", m.Email.From, rid)
+					err = result.HandleAutoReply(models.EventDetails{})
+				case forwarded:
+					log.Infof("User '%s' forwarded email with rid %s", m.Email.From, rid)
+					err = result.HandleEmailForwarded(models.EventDetails{})
+				default:
+					log.Infof("User '%s' reported email with rid %s", m.Email.From, rid)
+					err = result.HandleEmailReport(models.EventDetails{})
+				}
 				if err != nil {
 					log.Error("Error updating GoPhish email with rid ", rid, ": ", err.Error())
 					continue
 				}
+				if !autoReply && !forwarded {
+					pushReportedSimulation(im.UserId, m.Email, result)
+				}
 				if im.DeleteReportedCampaignEmail {
 					deleteEmails = append(deleteEmails, m.SeqNum)
 				}
@@ -202,6 +272,21 @@ func checkForNewEmails(im models.IMAP) {
 	}
 }
 
+// pushReportedSimulation pushes the campaign email reported by the result's
+// recipient to the user's incident connectors.
+func pushReportedSimulation(uid int64, em *email.Email, result models.Result) {
+	re := newReportedEmail(em)
+	re.RId = result.RId
+	re.CampaignId = result.CampaignId
+	if c, err := models.GetCampaignSummary(result.CampaignId, uid); err == nil {
+		re.Campaign = c.Name
+	}
+	err := models.PushReportedEmail(uid, re)
+	if err != nil {
+		log.Error("Error pushing reported email to incident connectors: ", err.Error())
+	}
+}
+
 func checkRIDs(em *email.Email, rids map[string]bool) {
 	// Check Text and HTML
 	emailContent := string(em.Text) + string(em.HTML)
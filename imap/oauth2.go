@@ -0,0 +1,362 @@
+package imap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophish/gophish/dialer"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// OAuth2Timeout is how long to wait for the OAuth2 server to respond
+const OAuth2Timeout = 30 * time.Second
+
+// tokenExpiryMargin is how long before an access token expires that a new
+// one is requested, so that tokens don't expire while they're being used.
+const tokenExpiryMargin = time.Minute
+
+// defaultDeviceInterval is how often the token endpoint is polled during the
+// device code flow, unless the server says otherwise.
+const defaultDeviceInterval = 5 * time.Second
+
+// deviceCodeGrant is the grant type used to poll for the token during the
+// device code flow, as defined in RFC 8628
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ErrNotAuthorized is returned when a mailbox using the device code flow
+// hasn't been authorized yet.
+var ErrNotAuthorized = errors.New("The mailbox hasn't been authorized. Sign in using the device code flow to authorize it.")
+
+// ErrNotDeviceCode is returned when the device code flow is started for a
+// mailbox which doesn't use it.
+var ErrNotDeviceCode = errors.New("The IMAP settings don't use the OAuth2 device code flow")
+
+// OAuth2Client is the HTTP client used to request OAuth2 tokens. If it isn't
+// set, tokens are requested through the configured proxy.
+var OAuth2Client *http.Client
+
+func oauth2Client() (*http.Client, error) {
+	if OAuth2Client != nil {
+		return OAuth2Client, nil
+	}
+	d, err := dialer.ProxyDialer("")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout:   OAuth2Timeout,
+		Transport: &http.Transport{DialContext: d.DialContext},
+	}, nil
+}
+
+// tokenResponse is the response from an OAuth2 token or device authorization
+// endpoint. Errors are returned in the same response.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (tr *tokenResponse) err() error {
+	if tr.ErrorDescription != "" {
+		return fmt.Errorf("%s: %s", tr.Error, tr.ErrorDescription)
+	}
+	return errors.New(tr.Error)
+}
+
+// postForm posts the form to an OAuth2 endpoint, decoding the JSON response
+// into v. OAuth2 servers return errors with a 400 status, so the body is
+// decoded regardless of the status.
+func postForm(ctx context.Context, endpoint string, form url.Values, v interface{}) error {
+	client, err := oauth2Client()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// clientForm returns the form identifying the OAuth2 client.
+func clientForm(im models.IMAP) (url.Values, error) {
+	form := url.Values{"client_id": {im.OAuth2ClientID}}
+	if im.OAuth2Scope != "" {
+		form.Set("scope", im.OAuth2Scope)
+	}
+	if im.OAuth2ClientSecret != "" {
+		secret, err := models.ResolveSecret(string(im.OAuth2ClientSecret), im.UserId)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_secret", secret)
+	}
+	return form, nil
+}
+
+// tokenKey identifies the settings an access token was requested with, so
+// that changing the settings requests a new token.
+type tokenKey struct {
+	uid      int64
+	method   string
+	tokenURL string
+	clientID string
+	scope    string
+	modified time.Time
+}
+
+// cachedToken is an access token along with the latest refresh token, which
+// some servers rotate each time it's used.
+type cachedToken struct {
+	access  string
+	refresh string
+	expiry  time.Time
+}
+
+var tokens = struct {
+	sync.Mutex
+	cache map[tokenKey]cachedToken
+}{cache: map[tokenKey]cachedToken{}}
+
+// accessToken returns an access token for logging into the mailbox,
+// requesting a new one when the cached token is about to expire. Refresh
+// tokens returned by the server are saved for the next time.
+func accessToken(ctx context.Context, im models.IMAP) (string, error) {
+	key := tokenKey{
+		uid:      im.UserId,
+		method:   im.AuthMethod,
+		tokenURL: im.OAuth2TokenURL,
+		clientID: im.OAuth2ClientID,
+		scope:    im.OAuth2Scope,
+		modified: im.ModifiedDate,
+	}
+	tokens.Lock()
+	defer tokens.Unlock()
+	cached, ok := tokens.cache[key]
+	if ok && time.Until(cached.expiry) > tokenExpiryMargin {
+		return cached.access, nil
+	}
+	form, err := clientForm(im)
+	if err != nil {
+		return "", err
+	}
+	refresh := string(im.OAuth2RefreshToken)
+	switch im.AuthMethod {
+	case models.IMAPAuthClientCredentials:
+		form.Set("grant_type", "client_credentials")
+	case models.IMAPAuthDeviceCode:
+		if cached.refresh != "" {
+			refresh = cached.refresh
+		}
+		if refresh == "" {
+			return "", ErrNotAuthorized
+		}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refresh)
+	default:
+		return "", models.ErrInvalidIMAPAuthMethod
+	}
+	tr := tokenResponse{}
+	err = postForm(ctx, im.OAuth2TokenURL, form, &tr)
+	if err != nil {
+		return "", err
+	}
+	if tr.AccessToken == "" {
+		return "", tr.err()
+	}
+	if tr.RefreshToken != "" && tr.RefreshToken != refresh && im.AuthMethod == models.IMAPAuthDeviceCode {
+		refresh = tr.RefreshToken
+		err = models.SetIMAPRefreshToken(im.UserId, refresh)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	tokens.cache[key] = cachedToken{
+		access:  tr.AccessToken,
+		refresh: refresh,
+		expiry:  time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}
+	return tr.AccessToken, nil
+}
+
+// xoauth2 implements the XOAUTH2 SASL mechanism used by Microsoft and Google
+// to log into mailboxes with an OAuth2 access token.
+type xoauth2 struct {
+	username string
+	token    string
+}
+
+func (a *xoauth2) Start() (string, []byte, error) {
+	ir := "user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"
+	return "XOAUTH2", []byte(ir), nil
+}
+
+// Next responds to the error the server sends when the token is rejected.
+// An empty response is expected, after which the server fails the
+// authentication.
+func (a *xoauth2) Next(challenge []byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// Device authorization statuses
+const (
+	DeviceAuthorizationPending    = "pending"
+	DeviceAuthorizationAuthorized = "authorized"
+	DeviceAuthorizationFailed     = "failed"
+)
+
+// DeviceAuthorization tells the user where to sign in, and which code to
+// enter, to authorize Gophish to access their mailbox.
+type DeviceAuthorization struct {
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+	Message                 string `json:"message,omitempty"`
+}
+
+// deviceAuthorizationResponse is the response from the device authorization
+// endpoint. Google calls the verification URI the verification URL.
+type deviceAuthorizationResponse struct {
+	DeviceAuthorization
+	DeviceCode       string `json:"device_code"`
+	VerificationURL  string `json:"verification_url"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// DeviceAuthorizationStatus is the status of the latest device authorization
+// started by a user.
+type DeviceAuthorizationStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+var deviceAuthorizations = struct {
+	sync.Mutex
+	status map[int64]DeviceAuthorizationStatus
+}{status: map[int64]DeviceAuthorizationStatus{}}
+
+func setDeviceAuthorizationStatus(uid int64, status, message string) {
+	deviceAuthorizations.Lock()
+	defer deviceAuthorizations.Unlock()
+	deviceAuthorizations.status[uid] = DeviceAuthorizationStatus{Status: status, Message: message}
+}
+
+// GetDeviceAuthorizationStatus returns the status of the latest device
+// authorization started by the user, if there is one.
+func GetDeviceAuthorizationStatus(uid int64) (DeviceAuthorizationStatus, bool) {
+	deviceAuthorizations.Lock()
+	defer deviceAuthorizations.Unlock()
+	s, ok := deviceAuthorizations.status[uid]
+	return s, ok
+}
+
+// StartDeviceAuthorization starts the device code flow for the mailbox. The
+// token endpoint is polled in the background until the user signs in, and the
+// refresh token is then saved with the IMAP settings.
+func StartDeviceAuthorization(im models.IMAP) (*DeviceAuthorization, error) {
+	if im.AuthMethod != models.IMAPAuthDeviceCode {
+		return nil, ErrNotDeviceCode
+	}
+	form, err := clientForm(im)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), OAuth2Timeout)
+	defer cancel()
+	dr := deviceAuthorizationResponse{}
+	err = postForm(ctx, im.OAuth2DeviceURL, form, &dr)
+	if err != nil {
+		return nil, err
+	}
+	if dr.DeviceCode == "" {
+		tr := tokenResponse{Error: dr.Error, ErrorDescription: dr.ErrorDescription}
+		return nil, tr.err()
+	}
+	da := dr.DeviceAuthorization
+	if da.VerificationURI == "" {
+		da.VerificationURI = dr.VerificationURL
+	}
+	setDeviceAuthorizationStatus(im.UserId, DeviceAuthorizationPending, "")
+	go pollDeviceAuthorization(im, dr.DeviceCode, da)
+	return &da, nil
+}
+
+// pollDeviceAuthorization polls the token endpoint until the user signs in,
+// the device code expires, or the authorization fails.
+func pollDeviceAuthorization(im models.IMAP, deviceCode string, da DeviceAuthorization) {
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+	deadline := time.Now().Add(time.Duration(da.ExpiresIn) * time.Second)
+	fail := func(err error) {
+		log.Error(err)
+		setDeviceAuthorizationStatus(im.UserId, DeviceAuthorizationFailed, err.Error())
+	}
+	for {
+		time.Sleep(interval)
+		if time.Now().After(deadline) {
+			fail(errors.New("The device code expired before the mailbox was authorized"))
+			return
+		}
+		form, err := clientForm(im)
+		if err != nil {
+			fail(err)
+			return
+		}
+		form.Set("grant_type", deviceCodeGrant)
+		form.Set("device_code", deviceCode)
+		ctx, cancel := context.WithTimeout(context.Background(), OAuth2Timeout)
+		tr := tokenResponse{}
+		err = postForm(ctx, im.OAuth2TokenURL, form, &tr)
+		cancel()
+		switch {
+		case err != nil:
+			fail(err)
+			return
+		case tr.Error == "authorization_pending":
+			continue
+		case tr.Error == "slow_down":
+			interval += 5 * time.Second
+			continue
+		case tr.RefreshToken == "":
+			if tr.Error == "" {
+				tr.Error = "no refresh token was returned. Make sure the offline_access scope is requested"
+			}
+			fail(tr.err())
+			return
+		}
+		err = models.SetIMAPRefreshToken(im.UserId, tr.RefreshToken)
+		if err != nil {
+			fail(err)
+			return
+		}
+		setDeviceAuthorizationStatus(im.UserId, DeviceAuthorizationAuthorized, "")
+		return
+	}
+}
@@ -0,0 +1,184 @@
+package imap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/models"
+)
+
+func setupOAuth2Test(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	conf := &config.Config{
+		DBName:         "sqlite3",
+		DBPath:         ":memory:",
+		MigrationsPath: "../db/db_sqlite3/migrations/",
+	}
+	err := models.Setup(conf)
+	if err != nil {
+		t.Fatalf("error setting up database: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	OAuth2Client = ts.Client()
+	return ts
+}
+
+func tearDownOAuth2Test(ts *httptest.Server) {
+	ts.Close()
+	OAuth2Client = nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestAccessTokenClientCredentials(t *testing.T) {
+	var requests int32
+	ts := setupOAuth2Test(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != "https://outlook.office365.com/.default" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "access", "expires_in": 3600})
+	})
+	defer tearDownOAuth2Test(ts)
+
+	im := models.IMAP{
+		UserId:             1,
+		AuthMethod:         models.IMAPAuthClientCredentials,
+		OAuth2TokenURL:     ts.URL,
+		OAuth2ClientID:     "client",
+		OAuth2ClientSecret: "secret",
+		OAuth2Scope:        "https://outlook.office365.com/.default",
+		ModifiedDate:       time.Now(),
+	}
+	for i := 0; i < 2; i++ {
+		token, err := accessToken(context.Background(), im)
+		if err != nil {
+			t.Fatalf("error getting access token: %v", err)
+		}
+		if token != "access" {
+			t.Fatalf("unexpected access token. expected access got %s", token)
+		}
+	}
+	// The token is cached until it expires
+	if requests != 1 {
+		t.Fatalf("unexpected number of token requests. expected 1 got %d", requests)
+	}
+
+	im.OAuth2ClientSecret = "wrong"
+	im.ModifiedDate = time.Now().Add(time.Second)
+	if _, err := accessToken(context.Background(), im); err == nil {
+		t.Fatalf("expected error requesting token with invalid client secret")
+	}
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	var polls int32
+	ts := setupOAuth2Test(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/devicecode":
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"device_code":      "device",
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://microsoft.com/devicelogin",
+				"expires_in":       60,
+				"interval":         1,
+			})
+		case r.Form.Get("grant_type") == deviceCodeGrant && r.Form.Get("device_code") == "device":
+			// The user signs in after the first poll
+			if atomic.AddInt32(&polls, 1) == 1 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "access", "refresh_token": "refresh-1", "expires_in": 3600})
+		case r.Form.Get("grant_type") == "refresh_token" && r.Form.Get("refresh_token") == "refresh-1":
+			// The refresh token is rotated when it's used
+			writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "access-2", "refresh_token": "refresh-2", "expires_in": 3600})
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		}
+	})
+	defer tearDownOAuth2Test(ts)
+
+	im := models.IMAP{
+		UserId:          1,
+		Host:            "127.0.0.1",
+		Port:            993,
+		Username:        "phishing@example.com",
+		AuthMethod:      models.IMAPAuthDeviceCode,
+		OAuth2TokenURL:  ts.URL + "/token",
+		OAuth2DeviceURL: ts.URL + "/devicecode",
+		OAuth2ClientID:  "client",
+	}
+	err := models.PostIMAP(&im, im.UserId)
+	if err != nil {
+		t.Fatalf("error saving IMAP settings: %v", err)
+	}
+	if _, err := accessToken(context.Background(), im); err != ErrNotAuthorized {
+		t.Fatalf("unexpected error getting access token before authorizing. expected %v got %v", ErrNotAuthorized, err)
+	}
+
+	da, err := StartDeviceAuthorization(im)
+	if err != nil {
+		t.Fatalf("error starting device authorization: %v", err)
+	}
+	if da.UserCode != "ABCD-EFGH" || da.VerificationURI != "https://microsoft.com/devicelogin" {
+		t.Fatalf("unexpected device authorization: %#v", da)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, _ := GetDeviceAuthorizationStatus(im.UserId)
+		if status.Status == DeviceAuthorizationAuthorized {
+			break
+		}
+		if status.Status == DeviceAuthorizationFailed || time.Now().After(deadline) {
+			t.Fatalf("device authorization didn't succeed: %#v", status)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	ims, err := models.GetIMAP(im.UserId)
+	if err != nil {
+		t.Fatalf("error getting IMAP settings: %v", err)
+	}
+	token, err := accessToken(context.Background(), ims[0])
+	if err != nil {
+		t.Fatalf("error getting access token: %v", err)
+	}
+	if token != "access-2" {
+		t.Fatalf("unexpected access token. expected access-2 got %s", token)
+	}
+	ims, err = models.GetIMAP(im.UserId)
+	if err != nil {
+		t.Fatalf("error getting IMAP settings: %v", err)
+	}
+	if ims[0].OAuth2RefreshToken != "refresh-2" {
+		t.Fatalf("unexpected refresh token saved. expected refresh-2 got %s", ims[0].OAuth2RefreshToken)
+	}
+}
+
+func TestXOAuth2(t *testing.T) {
+	a := &xoauth2{username: "phishing@example.com", token: "access"}
+	mech, ir, err := a.Start()
+	if err != nil {
+		t.Fatalf("error starting authentication: %v", err)
+	}
+	if mech != "XOAUTH2" {
+		t.Fatalf("unexpected mechanism. expected XOAUTH2 got %s", mech)
+	}
+	expected := "user=phishing@example.com\x01auth=Bearer access\x01\x01"
+	if string(ir) != expected {
+		t.Fatalf("unexpected initial response. expected %q got %q", expected, ir)
+	}
+}
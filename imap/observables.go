@@ -0,0 +1,179 @@
+package imap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gophish/gophish/models"
+	"github.com/jordan-wright/email"
+)
+
+// urlRegex matches the http and https URLs in the text and HTML of emails
+var urlRegex = regexp.MustCompile(`https?://[^\s"'<>\\]+`)
+
+// receivedIPRegex matches the bracketed IPv4 addresses in Received headers,
+// such as "from mail.example.com (mail.example.com [203.0.113.5])"
+var receivedIPRegex = regexp.MustCompile(`\[(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\]`)
+
+// privateNetworks are the networks whose addresses aren't useful as
+// observables, since they belong to the mail servers which received the
+// email internally.
+var privateNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+}
+
+// reportedMessage returns the email which was reported. Emails reported
+// using a mail client's report button, or forwarded as an attachment, have
+// the original email attached, which is returned instead of the report.
+func reportedMessage(em *email.Email) *email.Email {
+	for _, a := range em.Attachments {
+		ext := filepath.Ext(a.Filename)
+		if a.Header.Get("Content-Type") != "message/rfc822" && ext != ".eml" {
+			continue
+		}
+		original, err := email.NewEmailFromReader(bytes.NewReader(a.Content))
+		if err == nil {
+			return original
+		}
+	}
+	return em
+}
+
+// isPublicIP returns whether the address is a public IPv4 address.
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.To4() == nil || ip.IsUnspecified() {
+		return false
+	}
+	for _, cidr := range privateNetworks {
+		_, network, _ := net.ParseCIDR(cidr)
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// extractObservables returns the observables of the email, such as its
+// sender, the URLs and domains it links to and the hashes of its
+// attachments. Each observable is only returned once.
+func extractObservables(em *email.Email) []models.Observable {
+	observables := []models.Observable{}
+	seen := map[models.Observable]bool{}
+	add := func(t string, v string) {
+		v = strings.TrimSpace(v)
+		o := models.Observable{Type: t, Value: v}
+		if v == "" || seen[o] {
+			return
+		}
+		seen[o] = true
+		observables = append(observables, o)
+	}
+	if from, err := mail.ParseAddress(em.From); err == nil {
+		add(models.ObservableMail, strings.ToLower(from.Address))
+	}
+	if replyTo, err := mail.ParseAddress(em.Headers.Get("Reply-To")); err == nil {
+		add(models.ObservableMail, strings.ToLower(replyTo.Address))
+	}
+	add(models.ObservableSubject, em.Subject)
+	for _, received := range em.Headers["Received"] {
+		for _, m := range receivedIPRegex.FindAllStringSubmatch(received, -1) {
+			if ip := net.ParseIP(m[1]); isPublicIP(ip) {
+				add(models.ObservableIP, ip.String())
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.Trim(em.Headers.Get("X-Originating-IP"), "[] ")); isPublicIP(ip) {
+		add(models.ObservableIP, ip.String())
+	}
+	content := string(em.Text) + "\n" + strings.Replace(string(em.HTML), "&amp;", "&", -1)
+	for _, raw := range urlRegex.FindAllString(content, -1) {
+		raw = strings.TrimRight(raw, ".,;:!?)]}")
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		add(models.ObservableURL, raw)
+		host := strings.ToLower(u.Hostname())
+		if ip := net.ParseIP(host); ip != nil {
+			add(models.ObservableIP, ip.String())
+		} else {
+			add(models.ObservableDomain, host)
+		}
+	}
+	for _, a := range em.Attachments {
+		if a.HTMLRelated {
+			continue
+		}
+		add(models.ObservableFilename, a.Filename)
+		h := sha256.Sum256(a.Content)
+		add(models.ObservableHash, hex.EncodeToString(h[:]))
+	}
+	return observables
+}
+
+// newReportedEmail returns the report of an email reported by the sender of
+// em, with the observables of the reported email.
+func newReportedEmail(em *email.Email) models.ReportedEmail {
+	original := reportedMessage(em)
+	re := models.ReportedEmail{
+		Reporter:    senderAddress(em.From),
+		From:        original.From,
+		Subject:     original.Subject,
+		MessageId:   original.Headers.Get("Message-Id"),
+		Observables: extractObservables(original),
+	}
+	if d, err := mail.ParseDate(original.Headers.Get("Date")); err == nil {
+		re.Date = d
+	}
+	return re
+}
+
+// headerLines returns the headers of the email, one per line.
+func headerLines(em *email.Email) string {
+	keys := make([]string, 0, len(em.Headers))
+	for k := range em.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := &strings.Builder{}
+	for _, k := range keys {
+		for _, v := range em.Headers[k] {
+			fmt.Fprintf(b, "%s: %s\n", k, v)
+		}
+	}
+	return b.String()
+}
+
+// newReportedMessage returns the reported message for an email which isn't
+// part of a campaign, classifying the email which was reported.
+func newReportedMessage(uid int64, em *email.Email) models.ReportedMessage {
+	original := reportedMessage(em)
+	re := newReportedEmail(em)
+	classification, score, reasons := classifyReport(original, re.Observables)
+	return models.ReportedMessage{
+		UserId:         uid,
+		Reporter:       re.Reporter,
+		From:           re.From,
+		Subject:        re.Subject,
+		MessageId:      re.MessageId,
+		SentDate:       re.Date,
+		Headers:        models.EncryptedString(headerLines(original)),
+		Classification: classification,
+		Score:          score,
+		Reasons:        reasons,
+		Observables:    re.Observables,
+	}
+}
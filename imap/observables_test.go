@@ -0,0 +1,64 @@
+package imap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gophish/gophish/models"
+	"github.com/jordan-wright/email"
+)
+
+func TestExtractObservables(t *testing.T) {
+	em := email.NewEmail()
+	em.From = "IT Support <Support@Example.net>"
+	em.Subject = "Reset your password"
+	em.Headers = textproto.MIMEHeader{}
+	em.Headers.Set("Reply-To", "helpdesk@example.org")
+	em.Headers["Received"] = []string{
+		"from mail.example.net (mail.example.net [203.0.113.5]) by mx.example.com",
+		"from internal (internal [10.0.0.5]) by mx.example.com",
+	}
+	em.Text = []byte("Reset it at https://login.example.net/reset?id=1. Thanks")
+	em.HTML = []byte(`<a href="https://login.example.net/reset?id=1&amp;x=2">Reset</a> <a href="http://198.51.100.7/">here</a>`)
+	em.Attach(strings.NewReader("%PDF"), "invoice.pdf", "application/pdf")
+	hash := sha256.Sum256([]byte("%PDF"))
+
+	expected := []models.Observable{
+		{Type: models.ObservableMail, Value: "support@example.net"},
+		{Type: models.ObservableMail, Value: "helpdesk@example.org"},
+		{Type: models.ObservableSubject, Value: "Reset your password"},
+		{Type: models.ObservableIP, Value: "203.0.113.5"},
+		{Type: models.ObservableURL, Value: "https://login.example.net/reset?id=1"},
+		{Type: models.ObservableDomain, Value: "login.example.net"},
+		{Type: models.ObservableURL, Value: "https://login.example.net/reset?id=1&x=2"},
+		{Type: models.ObservableURL, Value: "http://198.51.100.7/"},
+		{Type: models.ObservableIP, Value: "198.51.100.7"},
+		{Type: models.ObservableFilename, Value: "invoice.pdf"},
+		{Type: models.ObservableHash, Value: hex.EncodeToString(hash[:])},
+	}
+	got := extractObservables(em)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected observables.\nexpected: %v\ngot: %v", expected, got)
+	}
+}
+
+func TestReportedMessage(t *testing.T) {
+	original := "From: attacker@example.net\r\nTo: victim@example.com\r\nSubject: Urgent\r\nMessage-Id: <1@example.net>\r\n\r\nhttps://evil.example.net/\r\n"
+	report := email.NewEmail()
+	report.From = "victim@example.com"
+	report.Subject = "FW: Urgent"
+	report.Headers = textproto.MIMEHeader{}
+	report.Attach(strings.NewReader(original), "Urgent.eml", "message/rfc822")
+
+	re := newReportedEmail(report)
+	if re.Reporter != "victim@example.com" || re.Subject != "Urgent" || re.MessageId != "<1@example.net>" {
+		t.Fatalf("unexpected report of the attached email: %+v", re)
+	}
+	if re.IsSimulation() {
+		t.Fatalf("expected the report not to be a simulation")
+	}
+}
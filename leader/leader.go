@@ -0,0 +1,163 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// DefaultLeaseName is the name of the lease held by the leader.
+const DefaultLeaseName = "leader"
+
+// DefaultTTL is how long the leader holds its lease without renewing it.
+const DefaultTTL = 30 * time.Second
+
+// Lock is a lock which is held by a single instance until it expires.
+type Lock interface {
+	// Acquire acquires or renews the lock for the instance, returning
+	// whether the instance holds the lock.
+	Acquire(id string, ttl time.Duration) (bool, error)
+	// Release releases the lock if it's held by the instance.
+	Release(id string) error
+}
+
+// DatabaseLock is a Lock stored in the Gophish database.
+type DatabaseLock struct {
+	Name string
+}
+
+// Acquire acquires or renews the lease in the database.
+func (l DatabaseLock) Acquire(id string, ttl time.Duration) (bool, error) {
+	return models.AcquireLease(l.Name, id, ttl)
+}
+
+// Release releases the lease in the database.
+func (l DatabaseLock) Release(id string) error {
+	return models.ReleaseLease(l.Name, id)
+}
+
+// DefaultID returns an id for this instance based on its hostname and
+// process id.
+func DefaultID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gophish"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Elector repeatedly tries to acquire the lock, calling the elected functions
+// when the instance becomes the leader and the demoted functions when it
+// stops being the leader.
+//
+// The lock is renewed every third of its TTL. If renewing fails, the leader
+// steps down straight away, so that it has stopped by the time the lock
+// expires and another instance takes over.
+type Elector struct {
+	id        string
+	ttl       time.Duration
+	lock      Lock
+	mu        sync.Mutex
+	leader    bool
+	onElected []func()
+	onDemoted []func()
+}
+
+// NewElector returns an Elector for the instance with the given id.
+func NewElector(id string, ttl time.Duration, lock Lock) *Elector {
+	if id == "" {
+		id = DefaultID()
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Elector{
+		id:   id,
+		ttl:  ttl,
+		lock: lock,
+	}
+}
+
+// ID returns the id of the instance.
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// OnElected adds a function which is called when the instance becomes the
+// leader.
+func (e *Elector) OnElected(f func()) {
+	e.onElected = append(e.onElected, f)
+}
+
+// OnDemoted adds a function which is called when the instance stops being
+// the leader.
+func (e *Elector) OnDemoted(f func()) {
+	e.onDemoted = append(e.onDemoted, f)
+}
+
+// IsLeader returns whether the instance is currently the leader.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// setLeader records whether the instance is the leader, calling the
+// functions for the change, if any.
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mu.Unlock()
+	if !changed {
+		return
+	}
+	fns := e.onDemoted
+	if leader {
+		log.Infof("Instance %s elected as leader", e.id)
+		fns = e.onElected
+	} else {
+		log.Infof("Instance %s is no longer the leader", e.id)
+	}
+	for _, f := range fns {
+		f()
+	}
+}
+
+// elect tries to acquire or renew the lock once.
+func (e *Elector) elect() {
+	leader, err := e.lock.Acquire(e.id, e.ttl)
+	if err != nil {
+		log.Errorf("error acquiring leader lock: %v", err)
+		leader = false
+	}
+	e.setLeader(leader)
+}
+
+// Run takes part in the election until the context is cancelled, at which
+// point the instance steps down and releases the lock.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	e.elect()
+	for {
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.setLeader(false)
+				err := e.lock.Release(e.id)
+				if err != nil {
+					log.Errorf("error releasing leader lock: %v", err)
+				}
+			}
+			return
+		case <-ticker.C:
+			e.elect()
+		}
+	}
+}
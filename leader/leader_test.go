@@ -0,0 +1,89 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockLock is a Lock held by whichever instance acquires it first, which
+// can be made to fail to simulate losing the connection to the database.
+type mockLock struct {
+	mu     sync.Mutex
+	holder string
+	err    error
+}
+
+func (l *mockLock) Acquire(id string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	if l.holder == "" {
+		l.holder = id
+	}
+	return l.holder == id, nil
+}
+
+func (l *mockLock) Release(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == id {
+		l.holder = ""
+	}
+	return nil
+}
+
+func (l *mockLock) setErr(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
+
+func TestElector(t *testing.T) {
+	lock := &mockLock{}
+	first := NewElector("first", 30*time.Millisecond, lock)
+	second := NewElector("second", 30*time.Millisecond, lock)
+	elected := make(chan string, 10)
+	demoted := make(chan string, 10)
+	for _, e := range []*Elector{first, second} {
+		e := e
+		e.OnElected(func() { elected <- e.ID() })
+		e.OnDemoted(func() { demoted <- e.ID() })
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		first.Run(ctx)
+		close(done)
+	}()
+	if id := <-elected; id != "first" {
+		t.Fatalf("unexpected leader. expected %s got %s", "first", id)
+	}
+	secondCtx, secondCancel := context.WithCancel(context.Background())
+	defer secondCancel()
+	go second.Run(secondCtx)
+
+	// The leader steps down as soon as it can't renew the lock
+	lock.setErr(errors.New("database unavailable"))
+	if id := <-demoted; id != "first" {
+		t.Fatalf("unexpected demotion. expected %s got %s", "first", id)
+	}
+	lock.setErr(nil)
+	if id := <-elected; id != "first" {
+		t.Fatalf("unexpected leader after recovering. expected %s got %s", "first", id)
+	}
+
+	// Stopping the leader releases the lock for the other instance
+	cancel()
+	<-done
+	if first.IsLeader() {
+		t.Fatalf("instance is still the leader after stopping")
+	}
+	if id := <-elected; id != "second" {
+		t.Fatalf("unexpected leader after stopping. expected %s got %s", "second", id)
+	}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDField is the field holding the ID of the request a message was
+// logged for.
+const RequestIDField = "request_id"
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random ID for a request.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		Logger.Error(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of the context holding the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID held by the context, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns an entry which logs messages with the request ID held
+// by the context, so that the messages logged while handling a request, and
+// the events and webhooks it causes, can be found together.
+func FromContext(ctx context.Context) *logrus.Entry {
+	id := RequestID(ctx)
+	if id == "" {
+		return logrus.NewEntry(Logger)
+	}
+	return Logger.WithField(RequestIDField, id)
+}
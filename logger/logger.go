@@ -15,10 +15,23 @@ var Logger *logrus.Logger
 // ErrInvalidLevel is returned when an invalid log level is given in the config
 var ErrInvalidLevel = errors.New("invalid log level")
 
+// ErrInvalidFormat is returned when an invalid log format is given in the
+// config
+var ErrInvalidFormat = errors.New("invalid log format")
+
+// The formats logs can be written in
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 // Config represents configuration details for logging.
 type Config struct {
 	Filename string `json:"filename"`
 	Level    string `json:"level"`
+	// Format is either "text", the default, or "json" to write structured
+	// logs which can be shipped to log management tools.
+	Format string `json:"format"`
 }
 
 func init() {
@@ -28,16 +41,15 @@ func init() {
 
 // Setup configures the logger based on options in the config.json.
 func Setup(config *Config) error {
-	var err error
 	// Set up logging level
-	level := logrus.InfoLevel
-	if config.Level != "" {
-		level, err = logrus.ParseLevel(config.Level)
-		if err != nil {
-			return err
-		}
+	err := SetLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	err = SetFormat(config.Format)
+	if err != nil {
+		return err
 	}
-	Logger.SetLevel(level)
 	// Set up logging to a file if specified in the config
 	logFile := config.Filename
 	if logFile != "" {
@@ -51,6 +63,35 @@ func Setup(config *Config) error {
 	return nil
 }
 
+// SetLevel sets the logging level, defaulting to info if no level is given.
+// The level can be changed while Gophish is running.
+func SetLevel(name string) error {
+	level := logrus.InfoLevel
+	if name != "" {
+		var err error
+		level, err = logrus.ParseLevel(name)
+		if err != nil {
+			return err
+		}
+	}
+	Logger.SetLevel(level)
+	return nil
+}
+
+// SetFormat sets the format logs are written in, defaulting to text if no
+// format is given.
+func SetFormat(name string) error {
+	switch name {
+	case "", FormatText:
+		Logger.Formatter = &logrus.TextFormatter{DisableColors: true}
+	case FormatJSON:
+		Logger.Formatter = &logrus.JSONFormatter{}
+	default:
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
 // Debug logs a debug message
 func Debug(args ...interface{}) {
 	Logger.Debug(args...)
@@ -1,8 +1,13 @@
 package logger
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
 
-import "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus"
+)
 
 func TestLogLevel(t *testing.T) {
 	tests := map[string]logrus.Level{
@@ -24,3 +29,37 @@ func TestLogLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestLogFormat(t *testing.T) {
+	defer SetFormat(FormatText)
+	out := Logger.Out
+	defer func() { Logger.Out = out }()
+	buf := &bytes.Buffer{}
+	Logger.Out = buf
+
+	err := Setup(&Config{Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("error setting logging format %v", err)
+	}
+	ctx := WithRequestID(context.Background(), "4c8f0a9e21d7b356")
+	FromContext(ctx).Info("handled request")
+	entry := map[string]interface{}{}
+	err = json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("error unmarshaling log entry %v", err)
+	}
+	if entry["msg"] != "handled request" || entry[RequestIDField] != "4c8f0a9e21d7b356" {
+		t.Fatalf("unexpected log entry %v", entry)
+	}
+
+	buf.Reset()
+	FromContext(context.Background()).Info("no request")
+	if bytes.Contains(buf.Bytes(), []byte(RequestIDField)) {
+		t.Fatalf("unexpected request id logged without a request: %s", buf.String())
+	}
+
+	err = Setup(&Config{Format: "xml"})
+	if err != ErrInvalidFormat {
+		t.Fatalf("unexpected error for an invalid format. expected %v got %v", ErrInvalidFormat, err)
+	}
+}
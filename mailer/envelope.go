@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"io"
+)
+
+// EnvelopeSender is implemented by Mail whose envelope sender, which is used
+// in the MAIL FROM command and receives bounces, differs from the From
+// header.
+type EnvelopeSender interface {
+	// EnvelopeFrom returns the envelope sender, or an empty string to use
+	// the From header. It's called after the email is generated.
+	EnvelopeFrom() string
+}
+
+// envelopeSender sends emails from a fixed envelope sender.
+type envelopeSender struct {
+	Sender
+	from string
+}
+
+func (s *envelopeSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.Sender.Send(s.from, to, msg)
+}
+
+// withEnvelope returns a sender which uses the mail's envelope sender, if it
+// has one.
+func withEnvelope(s Sender, m Mail) Sender {
+	es, ok := m.(EnvelopeSender)
+	if !ok || es.EnvelopeFrom() == "" {
+		return s
+	}
+	return &envelopeSender{Sender: s, from: es.EnvelopeFrom()}
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// envelopeMessage is a mockMessage with a separate envelope sender
+type envelopeMessage struct {
+	*mockMessage
+	envelope string
+}
+
+func (em *envelopeMessage) EnvelopeFrom() string {
+	return em.envelope
+}
+
+func TestEnvelopeSender(t *testing.T) {
+	sender := newMockSender()
+	sender.setSend(func(*mockMessage) error { return nil })
+	dialer := newMockDialer()
+	dialer.setDial(func() (Sender, error) {
+		return sender, nil
+	})
+	to := []string{"to@example.com"}
+	messages := []Mail{
+		&envelopeMessage{
+			mockMessage: newMockMessage("from@example.com", to, bytes.NewBufferString("First email")),
+			envelope:    "bounces@example.com",
+		},
+		&envelopeMessage{
+			mockMessage: newMockMessage("from@example.com", to, bytes.NewBufferString("Second email")),
+		},
+	}
+	sendMail(context.Background(), dialer, messages)
+	if len(sender.messages) != 2 {
+		t.Fatalf("unexpected number of messages sent. expected %d got %d", 2, len(sender.messages))
+	}
+	if sender.messages[0].from != "bounces@example.com" {
+		t.Fatalf("unexpected envelope sender. expected %s got %s", "bounces@example.com", sender.messages[0].from)
+	}
+	if sender.messages[1].from != "from@example.com" {
+		t.Fatalf("unexpected envelope sender. expected %s got %s", "from@example.com", sender.messages[1].from)
+	}
+}
@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// fileCounter makes the names of files written in the same microsecond
+// unique.
+var fileCounter uint64
+
+// FileDialer writes emails to files in a directory instead of sending them.
+// If Maildir is set, the directory is a maildir, and emails are delivered to
+// its "new" directory as a mail delivery agent would. Otherwise each email is
+// written to the directory as an .eml file.
+type FileDialer struct {
+	Path    string
+	Maildir bool
+}
+
+// Dial creates the directory, and the maildir's subdirectories, if they
+// don't exist.
+func (d *FileDialer) Dial() (Sender, error) {
+	dirs := []string{d.Path}
+	if d.Maildir {
+		dirs = []string{
+			filepath.Join(d.Path, "tmp"),
+			filepath.Join(d.Path, "new"),
+			filepath.Join(d.Path, "cur"),
+		}
+	}
+	for _, dir := range dirs {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fileSender{dialer: d}, nil
+}
+
+// fileSender writes emails to the dialer's directory.
+type fileSender struct {
+	dialer *FileDialer
+}
+
+// uniqueName returns a unique filename for an email, in the format used by
+// maildirs.
+func uniqueName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	now := time.Now()
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(),
+		atomic.AddUint64(&fileCounter, 1), filepath.Base(hostname))
+}
+
+// Send writes the email with the envelope sender and recipients in the
+// Return-Path and Delivered-To headers, since they aren't otherwise kept.
+func (s *fileSender) Send(from string, to []string, msg io.WriterTo) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Return-Path: <%s>\r\n", from)
+	for _, rcpt := range to {
+		fmt.Fprintf(buf, "Delivered-To: %s\r\n", rcpt)
+	}
+	if _, err := msg.WriteTo(buf); err != nil {
+		return err
+	}
+	name := uniqueName()
+	if !s.dialer.Maildir {
+		return ioutil.WriteFile(filepath.Join(s.dialer.Path, name+".eml"), buf.Bytes(), 0600)
+	}
+	// Emails are written to tmp and then moved to new, so that mail clients
+	// never read a partially written email.
+	tmp := filepath.Join(s.dialer.Path, "tmp", name)
+	err := ioutil.WriteFile(tmp, buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, filepath.Join(s.dialer.Path, "new", name))
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Close does nothing, since there's no connection to close.
+func (s *fileSender) Close() error {
+	return nil
+}
+
+// Reset does nothing, since there's no connection to reset.
+func (s *fileSender) Reset() error {
+	return nil
+}
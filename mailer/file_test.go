@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileDialer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gophish-mailer")
+	if err != nil {
+		t.Fatalf("error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, maildir := range []bool{false, true} {
+		path := filepath.Join(dir, "eml")
+		if maildir {
+			path = filepath.Join(dir, "maildir")
+		}
+		d := &FileDialer{Path: path, Maildir: maildir}
+		sender, err := d.Dial()
+		if err != nil {
+			t.Fatalf("error dialing: %v", err)
+		}
+		for _, content := range []string{"First email", "Second email"} {
+			err = sender.Send("from@example.com", []string{"to@example.com"}, bytes.NewBufferString(content))
+			if err != nil {
+				t.Fatalf("error sending email: %v", err)
+			}
+		}
+		sender.Close()
+
+		delivered := path
+		if maildir {
+			delivered = filepath.Join(path, "new")
+			tmp, _ := ioutil.ReadDir(filepath.Join(path, "tmp"))
+			if len(tmp) != 0 {
+				t.Fatalf("expected no files to be left in tmp, got %d", len(tmp))
+			}
+		}
+		files, err := ioutil.ReadDir(delivered)
+		if err != nil {
+			t.Fatalf("error reading directory: %v", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("expected 2 emails, got %d", len(files))
+		}
+		for _, f := range files {
+			if strings.HasSuffix(f.Name(), ".eml") == maildir {
+				t.Fatalf("unexpected filename %s", f.Name())
+			}
+			b, err := ioutil.ReadFile(filepath.Join(delivered, f.Name()))
+			if err != nil {
+				t.Fatalf("error reading email: %v", err)
+			}
+			if !strings.HasPrefix(string(b), "Return-Path: <from@example.com>\r\nDelivered-To: to@example.com\r\n") {
+				t.Fatalf("expected the envelope to be written, got %q", b)
+			}
+		}
+	}
+}
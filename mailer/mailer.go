@@ -2,18 +2,31 @@ package mailer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/textproto"
+	"sync"
+	"time"
 
 	"github.com/gophish/gomail"
 	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/tracing"
 	"github.com/sirupsen/logrus"
 )
 
 // MaxReconnectAttempts is the maximum number of times we should reconnect to a server
 var MaxReconnectAttempts = 10
 
+// MaxConcurrentBatches limits the number of batches a MailWorker sends at
+// once. Batches queued beyond the limit wait for another batch to finish. If
+// it's zero, every batch is sent as soon as it's queued.
+var MaxConcurrentBatches = 0
+
+// ErrStopped is returned for emails which weren't sent because the mailer was
+// stopped.
+var ErrStopped = errors.New("The mailer was stopped before the email was sent")
+
 // ErrMaxConnectAttempts is thrown when the maximum number of reconnect attempts
 // is reached.
 type ErrMaxConnectAttempts struct {
@@ -55,52 +68,106 @@ type Mail interface {
 	Success() error
 	Generate(msg *gomail.Message) error
 	GetDialer() (Dialer, error)
+	MarkInFlight() error
+	Unlock() error
 }
 
 // MailWorker is the worker that receives slices of emails
 // on a channel to send. It's assumed that every slice of emails received is meant
 // to be sent to the same server.
 type MailWorker struct {
+	stats stats
 	queue chan []Mail
+	done  chan struct{}
+	wg    sync.WaitGroup
+	pool  *Pool
+	// slots limits the number of batches sent at once, if it's set.
+	slots chan struct{}
 }
 
 // NewMailWorker returns an instance of MailWorker with the mail queue
-// initialized.
+// initialized, limited to sending MaxConcurrentBatches at once.
 func NewMailWorker() *MailWorker {
-	return &MailWorker{
+	mw := &MailWorker{
 		queue: make(chan []Mail),
+		done:  make(chan struct{}),
+		pool:  NewPool(),
+	}
+	if MaxConcurrentBatches > 0 {
+		mw.slots = make(chan struct{}, MaxConcurrentBatches)
 	}
+	return mw
 }
 
 // Start launches the mail worker to begin listening on the Queue channel
-// for new slices of Mail instances to process.
+// for new slices of Mail instances to process. Once the context is cancelled,
+// Start waits for the emails currently being sent before returning, and any
+// emails which weren't sent are unlocked so they can be sent later.
+//
+// Connections made by a PooledDialer are kept open and reused by later
+// batches sent to the same server.
 func (mw *MailWorker) Start(ctx context.Context) {
+	defer close(mw.done)
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
+			mw.wg.Wait()
+			mw.pool.Close()
 			return
+		case <-ticker.C:
+			go mw.pool.KeepAlive()
 		case ms := <-mw.queue:
+			mw.wg.Add(1)
 			go func(ctx context.Context, ms []Mail) {
+				defer mw.wg.Done()
+				if !mw.acquire(ctx) {
+					unlockMail(ms)
+					return
+				}
+				defer mw.release(len(ms))
 				dialer, err := ms[0].GetDialer()
 				if err != nil {
 					errorMail(err, ms)
 					return
 				}
+				if pd, ok := dialer.(PooledDialer); ok {
+					dialer = mw.pool.Dialer(ctx, pd)
+				}
 				sendMail(ctx, dialer, ms)
 			}(ctx, ms)
 		}
 	}
 }
 
-// Queue sends the provided mail to the internal queue for processing.
+// Queue sends the provided mail to the internal queue for processing. If the
+// mail worker has stopped, the mail is unlocked instead.
 func (mw *MailWorker) Queue(ms []Mail) {
-	mw.queue <- ms
+	select {
+	case mw.queue <- ms:
+	case <-mw.done:
+		unlockMail(ms)
+	}
+}
+
+// unlockMail releases Mail instances which weren't sent so that they can be
+// sent later.
+func unlockMail(ms []Mail) {
+	for _, m := range ms {
+		err := m.Unlock()
+		if err != nil {
+			log.Warn(err)
+		}
+	}
 }
 
 // errorMail is a helper to handle erroring out a slice of Mail instances
 // in the case that an unrecoverable error occurs.
 func errorMail(err error, ms []Mail) {
+	transcript := errorTranscript(err)
 	for _, m := range ms {
+		recordTranscript(transcript, m)
 		m.Error(err)
 	}
 }
@@ -109,6 +176,8 @@ func errorMail(err error, ms []Mail) {
 // It returns MaxReconnectAttempts if the number of connection attempts has been
 // exceeded.
 func dialHost(ctx context.Context, dialer Dialer) (Sender, error) {
+	_, span := tracing.Start(ctx, "smtp.dial")
+	defer span.End()
 	sendAttempt := 0
 	var sender Sender
 	var err error
@@ -131,37 +200,67 @@ func dialHost(ctx context.Context, dialer Dialer) (Sender, error) {
 			break
 		}
 	}
+	span.SetError(err)
 	return sender, err
 }
 
 // sendMail attempts to send the provided Mail instances.
 // If the context is cancelled before all of the mail are sent,
-// sendMail just returns and does not modify those emails.
+// sendMail unlocks the remaining emails and returns.
 func sendMail(ctx context.Context, dialer Dialer, ms []Mail) {
+	ctx, span := tracing.Start(ctx, "mailer.send_batch")
+	defer span.End()
+	span.SetAttribute("emails", len(ms))
 	sender, err := dialHost(ctx, dialer)
 	if err != nil {
 		log.Warn(err)
 		errorMail(err, ms)
 		return
 	}
-	defer sender.Close()
+	if sender == nil {
+		unlockMail(ms)
+		return
+	}
+	// The sender is replaced if we need to reconnect, so we make sure to
+	// close the current one.
+	defer func() {
+		if sender != nil {
+			sender.Close()
+		}
+	}()
 	message := gomail.NewMessage()
 	for i, m := range ms {
 		select {
 		case <-ctx.Done():
+			unlockMail(ms[i:])
 			return
 		default:
 			break
 		}
 		message.Reset()
+		_, generateSpan := tracing.Start(ctx, "email.generate")
 		err = m.Generate(message)
+		generateSpan.SetError(err)
+		generateSpan.End()
 		if err != nil {
 			log.Warn(err)
 			m.Error(err)
 			continue
 		}
-		err = gomail.Send(sender, message)
+		// If we can't record that the email is being sent, we won't know
+		// whether it was delivered if Gophish stops, so we try again later.
+		err = m.MarkInFlight()
+		if err != nil {
+			log.Warn(err)
+			m.Backoff(err)
+			continue
+		}
+		_, sendSpan := tracing.Start(ctx, "smtp.send")
+		err = gomail.Send(withEnvelope(withRewriter(sender, m), m), message)
+		sendSpan.SetError(err)
+		sendSpan.End()
 		if err != nil {
+			recordTranscript(senderTranscript(sender), m)
 			if te, ok := err.(*textproto.Error); ok {
 				switch {
 				// If it's a temporary error, we should backoff and try again later.
@@ -279,3 +279,39 @@ func TestUnknownError(t *testing.T) {
 		t.Fatalf("Did not received expected error. Got %#v\nExpected %#v", message.err, expectedError)
 	}
 }
+
+func TestMailWorkerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mw := NewMailWorker()
+	done := make(chan struct{})
+	go func() {
+		mw.Start(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	// Emails queued once the worker has stopped are unlocked rather than
+	// blocking forever
+	messages := generateMessages(newMockDialer())
+	mw.Queue(messages)
+	for _, m := range messages {
+		mm := m.(*mockMessage)
+		if !mm.unlocked || mm.inFlight {
+			t.Fatalf("message wasn't unlocked after the worker stopped: %#v", mm)
+		}
+	}
+}
+
+func TestSendMailCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	messages := generateMessages(newMockDialer())
+	sendMail(ctx, newMockDialer(), messages)
+	for _, m := range messages {
+		mm := m.(*mockMessage)
+		if !mm.unlocked || mm.finished {
+			t.Fatalf("unsent message wasn't unlocked: %#v", mm)
+		}
+	}
+}
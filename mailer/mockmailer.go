@@ -109,6 +109,8 @@ type mockMessage struct {
 	getdialer    func() (Dialer, error)
 	err          error
 	finished     bool
+	inFlight     bool
+	unlocked     bool
 }
 
 func newMockMessage(from string, to []string, msg io.WriterTo) *mockMessage {
@@ -166,3 +168,13 @@ func (mm *mockMessage) Success() error {
 	mm.finished = true
 	return nil
 }
+
+func (mm *mockMessage) MarkInFlight() error {
+	mm.inFlight = true
+	return nil
+}
+
+func (mm *mockMessage) Unlock() error {
+	mm.unlocked = true
+	return nil
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gophish/gomail"
+)
+
+// MessageModifier changes each message before it's sent, such as to add
+// headers. Modifiers are registered by name and enabled per campaign, so
+// that custom changes can be made to outgoing messages without changing the
+// mailer.
+type MessageModifier interface {
+	// Modify changes the generated message, which is sent to the given
+	// recipient. The options are those the modifier was enabled with.
+	Modify(msg *gomail.Message, recipient string, options map[string]string) error
+}
+
+// RawModifier is implemented by modifiers which change the raw message after
+// it's written, such as to change its MIME boundaries.
+type RawModifier interface {
+	ModifyRaw(raw []byte, options map[string]string) ([]byte, error)
+}
+
+// OptionsValidator is implemented by modifiers which check the options
+// they're enabled with.
+type OptionsValidator interface {
+	ValidateOptions(options map[string]string) error
+}
+
+var modifiers = struct {
+	sync.RWMutex
+	m map[string]MessageModifier
+}{m: map[string]MessageModifier{}}
+
+// RegisterModifier makes a message modifier available by the given name. It
+// panics if a modifier is already registered with the name.
+func RegisterModifier(name string, m MessageModifier) {
+	modifiers.Lock()
+	defer modifiers.Unlock()
+	if _, ok := modifiers.m[name]; ok {
+		panic(fmt.Sprintf("mailer: modifier %s is already registered", name))
+	}
+	modifiers.m[name] = m
+}
+
+// GetModifier returns the message modifier registered with the given name.
+func GetModifier(name string) (MessageModifier, bool) {
+	modifiers.RLock()
+	defer modifiers.RUnlock()
+	m, ok := modifiers.m[name]
+	return m, ok
+}
+
+// Modifiers returns the names of the registered message modifiers, sorted
+// alphabetically.
+func Modifiers() []string {
+	modifiers.RLock()
+	defer modifiers.RUnlock()
+	names := make([]string, 0, len(modifiers.m))
+	for name := range modifiers.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gophish/gomail"
+)
+
+// noopModifier is a MessageModifier which doesn't change messages
+type noopModifier struct{}
+
+func (noopModifier) Modify(*gomail.Message, string, map[string]string) error { return nil }
+
+func TestRegisterModifier(t *testing.T) {
+	RegisterModifier("test_noop", noopModifier{})
+	defer func() {
+		modifiers.Lock()
+		delete(modifiers.m, "test_noop")
+		modifiers.Unlock()
+	}()
+	if _, ok := GetModifier("test_noop"); !ok {
+		t.Fatalf("expected the modifier to be registered")
+	}
+	if _, ok := GetModifier("unknown"); ok {
+		t.Fatalf("unexpected modifier found")
+	}
+	expected := []string{"boundaries", "header_order", "headers", "test_noop"}
+	if got := Modifiers(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected modifiers. expected %v got %v", expected, got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected registering a duplicate modifier to panic")
+		}
+	}()
+	RegisterModifier("test_noop", noopModifier{})
+}
+
+func TestBuiltinModifiers(t *testing.T) {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", "foo@example.com")
+	msg.SetHeader("To", "bar@example.com")
+	msg.SetHeader("Subject", "Hello")
+	msg.SetBody("text/plain", "Text")
+	msg.AddAlternative("text/html", "<p>HTML</p>")
+
+	headers, _ := GetModifier("headers")
+	err := headers.Modify(msg, "bar@example.com", map[string]string{"X-Priority": "3"})
+	if err != nil {
+		t.Fatalf("unexpected error modifying message: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	_, err = msg.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("unexpected error writing message: %v", err)
+	}
+	raw := buf.Bytes()
+
+	boundaries, _ := GetModifier("boundaries")
+	raw, err = boundaries.(RawModifier).ModifyRaw(raw, map[string]string{"prefix": "----=_Part_"})
+	if err != nil {
+		t.Fatalf("unexpected error modifying message: %v", err)
+	}
+	order, _ := GetModifier("header_order")
+	raw, err = order.(RawModifier).ModifyRaw(raw, map[string]string{"order": "X-Priority, Subject"})
+	if err != nil {
+		t.Fatalf("unexpected error modifying message: %v", err)
+	}
+	got := string(raw)
+	if !strings.HasPrefix(got, "X-Priority: 3\r\nSubject: Hello\r\n") {
+		t.Fatalf("unexpected header order. got %s", got)
+	}
+	if !strings.Contains(got, `boundary="----=_Part_`) || !strings.Contains(got, "\r\n------=_Part_") {
+		t.Fatalf("expected the boundaries to be replaced. got %s", got)
+	}
+}
+
+func TestValidateModifierOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+		valid   bool
+	}{
+		{"headers", map[string]string{"X-Priority": "3"}, true},
+		{"headers", map[string]string{"X Priority": "3"}, false},
+		{"boundaries", map[string]string{"prefix": "----=_Part_"}, true},
+		{"boundaries", map[string]string{"prefix": "has space"}, false},
+		{"header_order", map[string]string{"order": "From, To"}, true},
+		{"header_order", map[string]string{"order": "From Address"}, false},
+	}
+	for _, test := range tests {
+		m, _ := GetModifier(test.name)
+		err := m.(OptionsValidator).ValidateOptions(test.options)
+		if (err == nil) != test.valid {
+			t.Fatalf("unexpected result validating %s options %v: %v", test.name, test.options, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/gophish/gomail"
+)
+
+// The modifiers included with Gophish
+func init() {
+	RegisterModifier("headers", headersModifier{})
+	RegisterModifier("boundaries", boundariesModifier{})
+	RegisterModifier("header_order", headerOrderModifier{})
+}
+
+// MaxBoundaryPrefix is the longest boundary prefix allowed. Random
+// characters are added to the prefix, and boundaries can't be longer than 70
+// characters.
+const MaxBoundaryPrefix = 46
+
+// boundaryRandomLength is the number of random characters added to a
+// boundary prefix.
+const boundaryRandomLength = 24
+
+var (
+	boundaryPrefix = regexp.MustCompile(`^[A-Za-z0-9'()+_,\-./:=?]*$`)
+	boundaryParam  = regexp.MustCompile(`(multipart/[A-Za-z]+;\s*boundary=)"?([^"\s;]+)"?`)
+	headerName     = regexp.MustCompile("^[!-9;-~]+$")
+)
+
+// ValidBoundaryPrefix returns whether the prefix can start MIME boundaries.
+func ValidBoundaryPrefix(prefix string) bool {
+	return len(prefix) <= MaxBoundaryPrefix && boundaryPrefix.MatchString(prefix)
+}
+
+// ValidHeaderName returns whether the name can be used as a header name.
+func ValidHeaderName(name string) bool {
+	return headerName.MatchString(name)
+}
+
+// RewriteBoundaries replaces each MIME boundary in the raw message with a
+// new random boundary starting with the prefix.
+func RewriteBoundaries(raw []byte, prefix string) ([]byte, error) {
+	for _, match := range boundaryParam.FindAllSubmatch(raw, -1) {
+		param, old := string(match[0]), string(match[2])
+		r := make([]byte, boundaryRandomLength/2)
+		_, err := rand.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		boundary := prefix + hex.EncodeToString(r)
+		// The parameter is quoted, since the prefix may contain characters
+		// which need to be.
+		raw = bytes.Replace(raw, []byte(param), []byte(string(match[1])+`"`+boundary+`"`), 1)
+		raw = bytes.Replace(raw, []byte("--"+old), []byte("--"+boundary), -1)
+	}
+	return raw, nil
+}
+
+// ReorderHeaders moves the named headers to the start of the raw message, in
+// the given order. The remaining headers keep their order.
+func ReorderHeaders(raw []byte, order []string) []byte {
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	if end == -1 {
+		return raw
+	}
+	fields := [][]byte{}
+	for _, line := range bytes.SplitAfter(raw[:end+2], []byte("\r\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		// Folded lines continue the previous field
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] = append(fields[len(fields)-1], line...)
+			continue
+		}
+		fields = append(fields, append([]byte{}, line...))
+	}
+	ordered := make([]byte, 0, len(raw))
+	used := make([]bool, len(fields))
+	for _, name := range order {
+		prefix := strings.ToLower(name) + ":"
+		for i, field := range fields {
+			if !used[i] && strings.HasPrefix(strings.ToLower(string(field)), prefix) {
+				ordered = append(ordered, field...)
+				used[i] = true
+			}
+		}
+	}
+	for i, field := range fields {
+		if !used[i] {
+			ordered = append(ordered, field...)
+		}
+	}
+	return append(ordered, raw[end+2:]...)
+}
+
+// splitList splits a comma separated list, ignoring empty entries.
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// headersModifier adds a header for each option, overwriting any header
+// with the same name.
+type headersModifier struct{}
+
+func (headersModifier) ValidateOptions(options map[string]string) error {
+	for name := range options {
+		if !ValidHeaderName(name) {
+			return errors.New("invalid header name " + name)
+		}
+	}
+	return nil
+}
+
+func (headersModifier) Modify(msg *gomail.Message, recipient string, options map[string]string) error {
+	for name, value := range options {
+		msg.SetHeader(name, value)
+	}
+	return nil
+}
+
+// boundariesModifier replaces the MIME boundaries with random boundaries
+// starting with the "prefix" option.
+type boundariesModifier struct{}
+
+func (boundariesModifier) ValidateOptions(options map[string]string) error {
+	if !ValidBoundaryPrefix(options["prefix"]) {
+		return errors.New("invalid boundary prefix")
+	}
+	return nil
+}
+
+func (boundariesModifier) Modify(msg *gomail.Message, recipient string, options map[string]string) error {
+	return nil
+}
+
+func (boundariesModifier) ModifyRaw(raw []byte, options map[string]string) ([]byte, error) {
+	return RewriteBoundaries(raw, options["prefix"])
+}
+
+// headerOrderModifier writes the headers in the comma separated "order"
+// option first, in order.
+type headerOrderModifier struct{}
+
+func (headerOrderModifier) ValidateOptions(options map[string]string) error {
+	for _, name := range splitList(options["order"]) {
+		if !ValidHeaderName(name) {
+			return errors.New("invalid header name " + name)
+		}
+	}
+	return nil
+}
+
+func (headerOrderModifier) Modify(msg *gomail.Message, recipient string, options map[string]string) error {
+	return nil
+}
+
+func (headerOrderModifier) ModifyRaw(raw []byte, options map[string]string) ([]byte, error) {
+	return ReorderHeaders(raw, splitList(options["order"])), nil
+}
@@ -0,0 +1,290 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/textproto"
+	"sync"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// IdleTimeout is how long an unused connection is kept open before it's
+// closed.
+var IdleTimeout = 2 * time.Minute
+
+// KeepAliveInterval is how often idle connections are checked, both to close
+// those which have timed out and to keep the others from being closed by the
+// server.
+var KeepAliveInterval = 30 * time.Second
+
+// ErrConnectionClosed is returned when using a pooled connection which has
+// been closed.
+var ErrConnectionClosed = errors.New("The SMTP connection was closed")
+
+// PooledDialer is a Dialer whose connections can be reused by later batches
+// of emails sent to the same server.
+type PooledDialer interface {
+	Dialer
+	// PoolKey identifies the connection made by the dialer. Connections are
+	// only shared by dialers with the same key.
+	PoolKey() string
+	// ConnectionLimit is the maximum number of connections to keep open
+	// for the key. A value of zero means there's no limit.
+	ConnectionLimit() int
+}
+
+// Pool keeps connections to SMTP servers open so that they can be reused,
+// rather than connecting and authenticating for each batch of emails.
+type Pool struct {
+	mu    sync.Mutex
+	hosts map[hostKey]*hostPool
+}
+
+// hostKey identifies a hostPool. The connection limit is part of the key, so
+// that changing a sending profile's limit takes effect for the connections
+// it opens afterwards.
+type hostKey struct {
+	key   string
+	limit int
+}
+
+// hostPool holds the connections for a single pool key.
+type hostPool struct {
+	idle  []*idleSender
+	slots chan struct{}
+}
+
+type idleSender struct {
+	sender   Sender
+	lastUsed time.Time
+}
+
+// NewPool returns an empty Pool.
+func NewPool() *Pool {
+	return &Pool{hosts: map[hostKey]*hostPool{}}
+}
+
+func (p *Pool) host(d PooledDialer) *hostPool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := hostKey{key: d.PoolKey(), limit: d.ConnectionLimit()}
+	hp, ok := p.hosts[key]
+	if !ok {
+		hp = &hostPool{}
+		if key.limit > 0 {
+			hp.slots = make(chan struct{}, key.limit)
+		}
+		p.hosts[key] = hp
+	}
+	return hp
+}
+
+// Dialer returns a Dialer which takes connections from the pool. Senders it
+// returns are given back to the pool when they're closed. If the connection
+// limit has been reached, dialing waits for a connection to be returned
+// until the context is cancelled.
+func (p *Pool) Dialer(ctx context.Context, d PooledDialer) Dialer {
+	return &poolDialer{ctx: ctx, pool: p, dialer: d}
+}
+
+type poolDialer struct {
+	ctx    context.Context
+	pool   *Pool
+	dialer PooledDialer
+}
+
+// Dial reuses an idle connection if one is available, checking that it's
+// still usable, and otherwise opens a new connection.
+func (pd *poolDialer) Dial() (Sender, error) {
+	hp := pd.pool.host(pd.dialer)
+	if hp.slots != nil {
+		select {
+		case hp.slots <- struct{}{}:
+		case <-pd.ctx.Done():
+			return nil, ErrStopped
+		}
+	}
+	for {
+		s := pd.pool.take(hp)
+		if s == nil {
+			break
+		}
+		if s.Reset() == nil {
+			return &pooledSender{Sender: s, pool: pd.pool, host: hp}, nil
+		}
+		s.Close()
+	}
+	s, err := pd.dialer.Dial()
+	if err != nil {
+		hp.release()
+		return nil, err
+	}
+	return &pooledSender{Sender: s, pool: pd.pool, host: hp}, nil
+}
+
+// take removes the most recently used idle connection from the pool.
+func (p *Pool) take(hp *hostPool) Sender {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(hp.idle) == 0 {
+		return nil
+	}
+	is := hp.idle[len(hp.idle)-1]
+	hp.idle = hp.idle[:len(hp.idle)-1]
+	return is.sender
+}
+
+// put returns a connection to the pool.
+func (p *Pool) put(hp *hostPool, s Sender) {
+	p.mu.Lock()
+	hp.idle = append(hp.idle, &idleSender{sender: s, lastUsed: time.Now()})
+	p.mu.Unlock()
+	hp.release()
+}
+
+// acquire takes a slot without waiting, returning whether one was free.
+func (hp *hostPool) acquire() bool {
+	if hp.slots == nil {
+		return true
+	}
+	select {
+	case hp.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (hp *hostPool) release() {
+	if hp.slots != nil {
+		<-hp.slots
+	}
+}
+
+// KeepAlive closes the connections which have been idle longer than the
+// IdleTimeout, and resets the others so the server doesn't close them.
+func (p *Pool) KeepAlive() {
+	type hostSender struct {
+		host *hostPool
+		*idleSender
+	}
+	p.mu.Lock()
+	checked := []hostSender{}
+	expired := []Sender{}
+	for _, hp := range p.hosts {
+		kept := []*idleSender{}
+		for _, is := range hp.idle {
+			switch {
+			case time.Since(is.lastUsed) > IdleTimeout:
+				expired = append(expired, is.sender)
+			// Connections being checked hold a slot, so that dialing
+			// doesn't open others in their place. If every slot is in
+			// use, the connection is checked next time.
+			case hp.acquire():
+				checked = append(checked, hostSender{host: hp, idleSender: is})
+			default:
+				kept = append(kept, is)
+			}
+		}
+		hp.idle = kept
+	}
+	p.mu.Unlock()
+	for _, s := range expired {
+		s.Close()
+	}
+	// Connections are checked outside of the lock, so they're removed from
+	// the pool while the server responds and added back afterwards. They
+	// keep the time they were last used, so that they still expire.
+	for _, hs := range checked {
+		err := hs.sender.Reset()
+		if err != nil {
+			log.Debugf("closing idle SMTP connection: %v", err)
+			hs.sender.Close()
+		} else {
+			p.mu.Lock()
+			hs.host.idle = append(hs.host.idle, hs.idleSender)
+			p.mu.Unlock()
+		}
+		hs.host.release()
+	}
+}
+
+// Close closes every idle connection.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, hp := range p.hosts {
+		for _, is := range hp.idle {
+			is.sender.Close()
+		}
+		hp.idle = nil
+	}
+}
+
+// pooledSender is a connection taken from the pool. Closing it returns the
+// connection to the pool. If sending fails in a way which suggests the
+// connection is no longer usable, it's closed straight away, since the mailer
+// reconnects without closing the failed sender.
+type pooledSender struct {
+	Sender
+	pool   *Pool
+	host   *hostPool
+	closed bool
+}
+
+// Send sends the email, discarding the connection if the server didn't
+// respond with an SMTP error.
+func (ps *pooledSender) Send(from string, to []string, msg io.WriterTo) error {
+	if ps.closed {
+		return ErrConnectionClosed
+	}
+	err := ps.Sender.Send(from, to, msg)
+	if err != nil && !isSMTPError(err) {
+		ps.discard()
+	}
+	return err
+}
+
+// isSMTPError returns whether the error is a response from the SMTP server,
+// rather than a problem with the connection.
+func isSMTPError(err error) bool {
+	_, ok := err.(*textproto.Error)
+	return ok
+}
+
+// Reset resets the connection, discarding it if that fails.
+func (ps *pooledSender) Reset() error {
+	if ps.closed {
+		return ErrConnectionClosed
+	}
+	err := ps.Sender.Reset()
+	if err != nil {
+		ps.discard()
+	}
+	return err
+}
+
+// Transcript returns the transcript of the underlying connection.
+func (ps *pooledSender) Transcript() []string {
+	return senderTranscript(ps.Sender)
+}
+
+// discard closes the connection rather than returning it to the pool.
+func (ps *pooledSender) discard() {
+	ps.closed = true
+	ps.Sender.Close()
+	ps.host.release()
+}
+
+// Close returns the connection to the pool.
+func (ps *pooledSender) Close() error {
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+	ps.pool.put(ps.host, ps.Sender)
+	return nil
+}
@@ -0,0 +1,225 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// mockPoolSender is a Sender which records whether it's been closed.
+type mockPoolSender struct {
+	resetErr error
+	sendErr  error
+	closed   bool
+	resets   int
+	onReset  func()
+}
+
+func (s *mockPoolSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.sendErr
+}
+
+func (s *mockPoolSender) Reset() error {
+	s.resets++
+	if s.onReset != nil {
+		s.onReset()
+	}
+	return s.resetErr
+}
+
+func (s *mockPoolSender) Close() error {
+	s.closed = true
+	return nil
+}
+
+// mockPooledDialer is a PooledDialer which returns new mockPoolSenders.
+type mockPooledDialer struct {
+	key     string
+	limit   int
+	senders []*mockPoolSender
+}
+
+func (d *mockPooledDialer) Dial() (Sender, error) {
+	s := &mockPoolSender{}
+	d.senders = append(d.senders, s)
+	return s, nil
+}
+
+func (d *mockPooledDialer) PoolKey() string {
+	return d.key
+}
+
+func (d *mockPooledDialer) ConnectionLimit() int {
+	return d.limit
+}
+
+func TestPoolReusesConnections(t *testing.T) {
+	p := NewPool()
+	md := &mockPooledDialer{key: "smtp.example.com"}
+	dialer := p.Dialer(context.Background(), md)
+	s, err := dialer.Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	s.Close()
+	s, err = dialer.Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	if len(md.senders) != 1 {
+		t.Fatalf("expected the connection to be reused, got %d connections", len(md.senders))
+	}
+	if md.senders[0].resets != 1 {
+		t.Fatalf("expected the reused connection to be checked")
+	}
+	// Connections which fail aren't returned to the pool
+	md.senders[0].sendErr = errors.New("connection reset")
+	s.Send("from@example.com", []string{"to@example.com"}, &bytes.Buffer{})
+	if !md.senders[0].closed {
+		t.Fatalf("expected the failed connection to be closed")
+	}
+	s.Close()
+	_, err = dialer.Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	if len(md.senders) != 2 {
+		t.Fatalf("expected a new connection, got %d connections", len(md.senders))
+	}
+
+	// Connections for other servers aren't shared
+	other := &mockPooledDialer{key: "smtp.example.org"}
+	_, err = p.Dialer(context.Background(), other).Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	if len(other.senders) != 1 {
+		t.Fatalf("expected a new connection for a different server")
+	}
+}
+
+func TestPoolConnectionLimit(t *testing.T) {
+	p := NewPool()
+	md := &mockPooledDialer{key: "smtp.example.com", limit: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	dialer := p.Dialer(ctx, md)
+	s, err := dialer.Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	dialed := make(chan error)
+	go func() {
+		_, err := dialer.Dial()
+		dialed <- err
+	}()
+	select {
+	case <-dialed:
+		t.Fatalf("expected dialing to wait for a free connection")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.Close()
+	err = <-dialed
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	if len(md.senders) != 1 {
+		t.Fatalf("expected the returned connection to be reused")
+	}
+	// Waiting for a connection stops when the context is cancelled
+	go func() {
+		_, err := dialer.Dial()
+		dialed <- err
+	}()
+	cancel()
+	err = <-dialed
+	if err != ErrStopped {
+		t.Fatalf("expected %v, got %v", ErrStopped, err)
+	}
+
+	// Changing the limit takes effect for new connections
+	md.limit = 2
+	_, err = p.Dialer(context.Background(), md).Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	if len(md.senders) != 2 {
+		t.Fatalf("expected a new connection under the new limit, got %d connections", len(md.senders))
+	}
+}
+
+func TestPoolKeepAlive(t *testing.T) {
+	p := NewPool()
+	md := &mockPooledDialer{key: "smtp.example.com"}
+	dialer := p.Dialer(context.Background(), md)
+	a, _ := dialer.Dial()
+	b, _ := dialer.Dial()
+	a.Close()
+	b.Close()
+	md.senders[1].resetErr = errors.New("connection reset")
+	p.KeepAlive()
+	if md.senders[0].closed || md.senders[0].resets != 1 {
+		t.Fatalf("expected the idle connection to be kept alive")
+	}
+	if !md.senders[1].closed {
+		t.Fatalf("expected the broken connection to be closed")
+	}
+
+	orig := IdleTimeout
+	defer func() { IdleTimeout = orig }()
+	IdleTimeout = 0
+	p.KeepAlive()
+	if !md.senders[0].closed {
+		t.Fatalf("expected the expired connection to be closed")
+	}
+	p.Close()
+}
+
+func TestPoolKeepAliveExpiry(t *testing.T) {
+	orig := IdleTimeout
+	defer func() { IdleTimeout = orig }()
+	IdleTimeout = 100 * time.Millisecond
+
+	p := NewPool()
+	md := &mockPooledDialer{key: "smtp.example.com"}
+	s, _ := p.Dialer(context.Background(), md).Dial()
+	s.Close()
+	// Keeping the connection alive doesn't count as using it
+	time.Sleep(60 * time.Millisecond)
+	p.KeepAlive()
+	time.Sleep(60 * time.Millisecond)
+	p.KeepAlive()
+	if md.senders[0].resets != 1 || !md.senders[0].closed {
+		t.Fatalf("expected the idle connection to expire")
+	}
+}
+
+func TestPoolKeepAliveHoldsSlot(t *testing.T) {
+	p := NewPool()
+	md := &mockPooledDialer{key: "smtp.example.com", limit: 1}
+	s, _ := p.Dialer(context.Background(), md).Dial()
+	s.Close()
+	hp := p.host(md)
+	held := 0
+	md.senders[0].onReset = func() {
+		held = len(hp.slots)
+	}
+	p.KeepAlive()
+	if held != 1 {
+		t.Fatalf("expected the connection to hold a slot while it's checked")
+	}
+	if len(hp.slots) != 0 || len(hp.idle) != 1 {
+		t.Fatalf("expected the connection to be returned to the pool")
+	}
+
+	// Connections aren't checked if every slot is in use
+	s, _ = p.Dialer(context.Background(), md).Dial()
+	other := &mockPoolSender{}
+	hp.idle = append(hp.idle, &idleSender{sender: other, lastUsed: time.Now()})
+	p.KeepAlive()
+	if other.resets != 0 || len(hp.idle) != 1 {
+		t.Fatalf("expected the connection to be kept without being checked")
+	}
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+)
+
+// MessageRewriter is implemented by Mail which changes the raw message after
+// it's generated, such as to change its MIME boundaries.
+type MessageRewriter interface {
+	// RewriteMessage returns the message which is sent in place of the
+	// generated one.
+	RewriteMessage(raw []byte) ([]byte, error)
+}
+
+// rewrittenMessage writes a message after it's been rewritten.
+type rewrittenMessage struct {
+	msg      io.WriterTo
+	rewriter MessageRewriter
+}
+
+func (r *rewrittenMessage) WriteTo(w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	_, err := r.msg.WriteTo(buf)
+	if err != nil {
+		return 0, err
+	}
+	raw, err := r.rewriter.RewriteMessage(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(raw)
+	return int64(n), err
+}
+
+// rewriteSender rewrites each message before it's sent.
+type rewriteSender struct {
+	Sender
+	rewriter MessageRewriter
+}
+
+func (s *rewriteSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.Sender.Send(from, to, &rewrittenMessage{msg: msg, rewriter: s.rewriter})
+}
+
+// withRewriter returns a sender which rewrites the mail's message, if the
+// mail rewrites its messages.
+func withRewriter(s Sender, m Mail) Sender {
+	mr, ok := m.(MessageRewriter)
+	if !ok {
+		return s
+	}
+	return &rewriteSender{Sender: s, rewriter: mr}
+}
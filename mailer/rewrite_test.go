@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// rewriteMessage is a mockMessage which rewrites its message
+type rewriteMessage struct {
+	*mockMessage
+}
+
+func (rm *rewriteMessage) RewriteMessage(raw []byte) ([]byte, error) {
+	return bytes.ToUpper(raw), nil
+}
+
+func TestMessageRewriter(t *testing.T) {
+	sender := newMockSender()
+	sender.setSend(func(*mockMessage) error { return nil })
+	dialer := newMockDialer()
+	dialer.setDial(func() (Sender, error) {
+		return sender, nil
+	})
+	to := []string{"to@example.com"}
+	messages := []Mail{
+		&rewriteMessage{
+			mockMessage: newMockMessage("from@example.com", to, bytes.NewBufferString("First email")),
+		},
+		newMockMessage("from@example.com", to, bytes.NewBufferString("Second email")),
+	}
+	sendMail(context.Background(), dialer, messages)
+	if len(sender.messages) != 2 {
+		t.Fatalf("unexpected number of messages sent. expected %d got %d", 2, len(sender.messages))
+	}
+	if !bytes.Contains(sender.messages[0].message, []byte("FIRST EMAIL")) {
+		t.Fatalf("expected the message to be rewritten. got %s", sender.messages[0].message)
+	}
+	if !bytes.Contains(sender.messages[1].message, []byte("Second email")) {
+		t.Fatalf("unexpected message received. got %s", sender.messages[1].message)
+	}
+}
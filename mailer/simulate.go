@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+)
+
+// SimulatedDialer is a PooledDialer whose senders save each email rather
+// than sending it. Emails still go through the worker, so they're rendered,
+// throttled and recorded as they would be if they were sent, which allows
+// campaigns to be tested without any email leaving the server.
+type SimulatedDialer struct {
+	// Key identifies the dialer's connections in the pool.
+	Key string
+	// MaxConnections is the maximum number of simulated connections to keep
+	// open at once, as for the SMTP server which would have been used.
+	MaxConnections int
+	// Save stores the fully built message for the given envelope.
+	Save func(from string, to []string, msg []byte) error
+}
+
+// Dial returns a sender which saves emails.
+func (d *SimulatedDialer) Dial() (Sender, error) {
+	return &simulatedSender{save: d.Save}, nil
+}
+
+// PoolKey returns the key identifying the dialer's connections.
+func (d *SimulatedDialer) PoolKey() string {
+	return "simulated\x00" + d.Key
+}
+
+// ConnectionLimit returns the maximum number of connections to keep open.
+func (d *SimulatedDialer) ConnectionLimit() int {
+	return d.MaxConnections
+}
+
+// simulatedSender is a Sender which saves emails instead of sending them.
+type simulatedSender struct {
+	save func(from string, to []string, msg []byte) error
+}
+
+// Send builds the message and saves it.
+func (s *simulatedSender) Send(from string, to []string, msg io.WriterTo) error {
+	buf := &bytes.Buffer{}
+	if _, err := msg.WriteTo(buf); err != nil {
+		return err
+	}
+	return s.save(from, to, buf.Bytes())
+}
+
+// Close does nothing, since there's no connection to close.
+func (s *simulatedSender) Close() error {
+	return nil
+}
+
+// Reset does nothing, since there's no connection to reset.
+func (s *simulatedSender) Reset() error {
+	return nil
+}
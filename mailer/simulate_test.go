@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimulatedDialer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mw := NewMailWorker()
+	go mw.Start(ctx)
+
+	saved := make(chan []byte, 2)
+	dialer := &SimulatedDialer{
+		Key: "test",
+		Save: func(from string, to []string, msg []byte) error {
+			if len(to) != 1 || to[0] != "to@example.com" {
+				t.Errorf("unexpected recipients %v", to)
+			}
+			saved <- msg
+			return nil
+		},
+	}
+	mw.Queue(generateMessages(dialer))
+
+	for _, want := range []string{"First email", "Second email"} {
+		select {
+		case msg := <-saved:
+			if !bytes.Contains(msg, []byte("To: to@example.com")) {
+				t.Fatalf("expected the message headers to be saved, got %q", msg)
+			}
+			if !bytes.Contains(msg, []byte(want)) {
+				t.Fatalf("expected the message to contain %q, got %q", want, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the message to be saved")
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// DefaultDialTimeout is how long to wait when connecting to an SMTP server if
+// no network dialer is provided.
+const DefaultDialTimeout = 10 * time.Second
+
+// SMTPDialer connects and authenticates to an SMTP server. When the server
+// supports it, commands are pipelined as described in RFC 2920, so that each
+// email only waits on the server once before its content is sent.
+type SMTPDialer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// SSL determines whether the connection uses implicit TLS rather than
+	// STARTTLS. It defaults to true for port 465.
+	SSL       bool
+	TLSConfig *tls.Config
+	// LocalName is the hostname sent with the EHLO command.
+	LocalName string
+	// MaxConnections is the maximum number of connections to keep open to
+	// the server. A value of zero means there's no limit.
+	MaxConnections int
+	// NetDialer is the network dialer used to establish the connection,
+	// such as a net.Dialer or a dialer which connects through a proxy.
+	NetDialer NetDialer
+}
+
+// NetDialer establishes network connections.
+type NetDialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// NewSMTPDialer returns a new SMTPDialer for the given server.
+func NewSMTPDialer(host string, port int, username, password string) *SMTPDialer {
+	return &SMTPDialer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		SSL:      port == 465,
+	}
+}
+
+// PoolKey identifies the server and credentials used by the dialer, so that
+// connections are only reused by dialers which would have created the same
+// connection. The credentials are hashed so they aren't kept in the key.
+func (d *SMTPDialer) PoolKey() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s\x00%s\x00%t\x00%s", d.Host, d.Port, d.Username, d.Password, d.SSL, d.LocalName)
+	if d.TLSConfig != nil {
+		fmt.Fprintf(h, "\x00%s\x00%t", d.TLSConfig.ServerName, d.TLSConfig.InsecureSkipVerify)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ConnectionLimit returns the maximum number of connections to keep open to
+// the server.
+func (d *SMTPDialer) ConnectionLimit() int {
+	return d.MaxConnections
+}
+
+func (d *SMTPDialer) tlsConfig() *tls.Config {
+	if d.TLSConfig == nil {
+		return &tls.Config{ServerName: d.Host}
+	}
+	return d.TLSConfig
+}
+
+// Dial connects and authenticates to the SMTP server. The returned Sender
+// should be closed when done using it. If connecting fails, the error is a
+// *TranscriptError.
+func (d *SMTPDialer) Dial() (Sender, error) {
+	t := &transcript{}
+	c, err := d.dial(t)
+	if err != nil {
+		t.response(0, "", err)
+		return nil, &TranscriptError{Err: err, Transcript: t.lines}
+	}
+	s := &smtpSender{client: c, dialTranscript: t.lines}
+	s.pipelining, _ = c.Extension("PIPELINING")
+	if ok, _ := c.Extension("8BITMIME"); ok {
+		s.mailParams += " BODY=8BITMIME"
+	}
+	if ok, _ := c.Extension("SMTPUTF8"); ok {
+		s.mailParams += " SMTPUTF8"
+	}
+	return s, nil
+}
+
+// dial establishes the session, recording each step in the transcript. Any
+// error is recorded by the caller.
+func (d *SMTPDialer) dial(t *transcript) (*smtp.Client, error) {
+	addr := net.JoinHostPort(d.Host, fmt.Sprintf("%d", d.Port))
+	t.add("* Connecting to " + addr)
+	var conn net.Conn
+	var err error
+	if d.NetDialer != nil {
+		conn, err = d.NetDialer.Dial("tcp", addr)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, DefaultDialTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.SSL {
+		t.add("* Starting TLS")
+		conn = tls.Client(conn, d.tlsConfig())
+	}
+	c, err := smtp.NewClient(conn, d.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.response(220, "", nil)
+	localName := d.LocalName
+	if localName == "" {
+		localName = "localhost"
+	}
+	t.command("EHLO %s", localName)
+	err = c.Hello(localName)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	t.response(250, "", nil)
+	if !d.SSL {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			t.command("STARTTLS")
+			err = c.StartTLS(d.tlsConfig())
+			if err != nil {
+				c.Close()
+				return nil, err
+			}
+			t.response(220, "", nil)
+		}
+	}
+	if d.Username != "" {
+		if ok, auths := c.Extension("AUTH"); ok {
+			var auth smtp.Auth
+			mechanism := "PLAIN"
+			switch {
+			case strings.Contains(auths, "CRAM-MD5"):
+				auth = smtp.CRAMMD5Auth(d.Username, d.Password)
+				mechanism = "CRAM-MD5"
+			case strings.Contains(auths, "LOGIN") && !strings.Contains(auths, "PLAIN"):
+				auth = &loginAuth{username: d.Username, password: d.Password, host: d.Host}
+				mechanism = "LOGIN"
+			default:
+				auth = smtp.PlainAuth("", d.Username, d.Password, d.Host)
+			}
+			// The credentials exchanged during authentication are never
+			// recorded.
+			t.command("AUTH %s [credentials for %s]", mechanism, d.Username)
+			err = c.Auth(auth)
+			if err != nil {
+				c.Close()
+				return nil, err
+			}
+			t.response(235, "", nil)
+		}
+	}
+	return c, nil
+}
+
+// smtpSender sends emails over an established SMTP connection.
+type smtpSender struct {
+	client     *smtp.Client
+	pipelining bool
+	mailParams string
+
+	dialTranscript []string
+	transcript     transcript
+}
+
+// Transcript returns the conversation from connecting to the server, followed
+// by the conversation for the most recent email.
+func (s *smtpSender) Transcript() []string {
+	lines := make([]string, 0, len(s.dialTranscript)+len(s.transcript.lines))
+	lines = append(lines, s.dialTranscript...)
+	return append(lines, s.transcript.lines...)
+}
+
+// errInvalidAddress is returned when an address would inject additional SMTP
+// commands.
+var errInvalidAddress = errors.New("smtp: address contains CR or LF")
+
+// Send sends the email. If the server supports pipelining, the MAIL, RCPT, and
+// DATA commands are written together before their responses are read.
+func (s *smtpSender) Send(from string, to []string, msg io.WriterTo) error {
+	s.transcript.reset()
+	for _, addr := range append([]string{from}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return errInvalidAddress
+		}
+	}
+	if !s.pipelining {
+		return s.send(from, to, msg)
+	}
+	t := &s.transcript
+	text := s.client.Text
+	id := text.Next()
+	text.StartRequest(id)
+	t.command("MAIL FROM:<%s>%s", from, s.mailParams)
+	fmt.Fprintf(text.W, "MAIL FROM:<%s>%s\r\n", from, s.mailParams)
+	for _, addr := range to {
+		t.command("RCPT TO:<%s>", addr)
+		fmt.Fprintf(text.W, "RCPT TO:<%s>\r\n", addr)
+	}
+	t.command("DATA")
+	fmt.Fprintf(text.W, "DATA\r\n")
+	err := text.W.Flush()
+	text.EndRequest(id)
+	if err != nil {
+		t.response(0, "", err)
+		return err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	// Every response has to be read, even once a command has failed, so
+	// the connection can be used for the next email.
+	code, resp, err := text.ReadResponse(250)
+	t.response(code, resp, err)
+	for range to {
+		code, resp, rerr := text.ReadResponse(25)
+		t.response(code, resp, rerr)
+		if err == nil {
+			err = rerr
+		}
+	}
+	code, resp, derr := text.ReadResponse(354)
+	t.response(code, resp, derr)
+	if derr != nil {
+		if err == nil {
+			err = derr
+		}
+		return err
+	}
+	if err != nil {
+		// The server accepted the DATA command despite an earlier failure,
+		// so we send an empty message to end it.
+		t.command(".")
+		fmt.Fprintf(text.W, ".\r\n")
+		text.W.Flush()
+		code, resp, derr = text.ReadResponse(250)
+		t.response(code, resp, derr)
+		return err
+	}
+	t.add("* Sending message content")
+	w := text.DotWriter()
+	_, err = msg.WriteTo(w)
+	if err != nil {
+		w.Close()
+		t.response(0, "", err)
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		t.response(0, "", err)
+		return err
+	}
+	code, resp, err = text.ReadResponse(250)
+	t.response(code, resp, err)
+	return err
+}
+
+// send sends the email, waiting for the response to each command.
+func (s *smtpSender) send(from string, to []string, msg io.WriterTo) error {
+	t := &s.transcript
+	t.command("MAIL FROM:<%s>%s", from, s.mailParams)
+	err := s.client.Mail(from)
+	t.response(250, "", err)
+	if err != nil {
+		return err
+	}
+	for _, addr := range to {
+		t.command("RCPT TO:<%s>", addr)
+		err = s.client.Rcpt(addr)
+		t.response(250, "", err)
+		if err != nil {
+			return err
+		}
+	}
+	t.command("DATA")
+	w, err := s.client.Data()
+	t.response(354, "", err)
+	if err != nil {
+		return err
+	}
+	t.add("* Sending message content")
+	_, err = msg.WriteTo(w)
+	if err != nil {
+		w.Close()
+		t.response(0, "", err)
+		return err
+	}
+	// Closing the writer returned by net/smtp reads the final response.
+	err = w.Close()
+	t.response(250, "", err)
+	return err
+}
+
+// Reset aborts the current mail transaction.
+func (s *smtpSender) Reset() error {
+	return s.client.Reset()
+}
+
+// Close ends the session and closes the connection.
+func (s *smtpSender) Close() error {
+	err := s.client.Quit()
+	if err != nil {
+		s.client.Close()
+	}
+	return err
+}
+
+// loginAuth implements the LOGIN authentication mechanism, which is used by
+// servers that don't support PLAIN.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		advertised := false
+		for _, mechanism := range server.Auth {
+			if mechanism == "LOGIN" {
+				advertised = true
+				break
+			}
+		}
+		if !advertised {
+			return "", nil, errors.New("smtp: unencrypted connection")
+		}
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch {
+	case bytes.Equal(fromServer, []byte("Username:")):
+		return []byte(a.username), nil
+	case bytes.Equal(fromServer, []byte("Password:")):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("smtp: unexpected server challenge: %s", fromServer)
+	}
+}
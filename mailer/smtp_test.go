@@ -0,0 +1,251 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTPServer is a minimal SMTP server which records the commands it
+// receives, along with how many were buffered when each was read.
+type fakeSMTPServer struct {
+	listener   net.Listener
+	pipelining bool
+	rejectRcpt string
+
+	mu       sync.Mutex
+	commands []string
+	batched  []int
+	messages []string
+}
+
+func newFakeSMTPServer(t *testing.T, pipelining bool) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	s := &fakeSMTPServer{listener: l, pipelining: pipelining}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) dialer() *SMTPDialer {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return NewSMTPDialer(addr.IP.String(), addr.Port, "", "")
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	text := textproto.NewReader(r)
+	w := bufio.NewWriter(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+		w.Flush()
+	}
+	reply("220 localhost ESMTP")
+	rejected := 0
+	rcpts := 0
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.batched = append(s.batched, r.Buffered())
+		s.mu.Unlock()
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case cmd == "EHLO":
+			if s.pipelining {
+				reply("250-localhost\r\n250-PIPELINING\r\n250 8BITMIME")
+			} else {
+				reply("250-localhost\r\n250 8BITMIME")
+			}
+		case cmd == "MAIL":
+			rejected, rcpts = 0, 0
+			reply("250 OK")
+		case cmd == "RCPT":
+			rcpts++
+			if s.rejectRcpt != "" && strings.Contains(line, s.rejectRcpt) {
+				rejected++
+				reply("550 No such user")
+				continue
+			}
+			reply("250 OK")
+		case cmd == "DATA":
+			if rejected == rcpts {
+				reply("554 No valid recipients")
+				continue
+			}
+			reply("354 Go ahead")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, strings.Join(lines, "\n"))
+			s.mu.Unlock()
+			reply("250 Queued")
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Unknown command")
+		}
+	}
+}
+
+func TestSMTPDialerPipelining(t *testing.T) {
+	s := newFakeSMTPServer(t, true)
+	defer s.listener.Close()
+	sender, err := s.dialer().Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	err = sender.Send("from@example.com", []string{"a@example.com", "b@example.com"}, bytes.NewBufferString("Subject: Test\r\n\r\nHello"))
+	if err != nil {
+		t.Fatalf("error sending: %v", err)
+	}
+	sender.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mail := -1
+	for i, c := range s.commands {
+		if strings.HasPrefix(c, "MAIL FROM:<from@example.com>") {
+			mail = i
+			break
+		}
+	}
+	if mail == -1 {
+		t.Fatalf("MAIL command not received: %v", s.commands)
+	}
+	expected := []string{"MAIL FROM:<from@example.com> BODY=8BITMIME", "RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>", "DATA"}
+	for i, e := range expected {
+		if s.commands[mail+i] != e {
+			t.Fatalf("unexpected command %d. Expected %q, got %q", i, e, s.commands[mail+i])
+		}
+	}
+	// Every command up to DATA should have arrived before the server
+	// responded to the first one.
+	if s.batched[mail] == 0 {
+		t.Fatalf("expected the commands to be pipelined")
+	}
+	if len(s.messages) != 1 || !strings.HasSuffix(s.messages[0], "Hello") {
+		t.Fatalf("unexpected messages: %v", s.messages)
+	}
+}
+
+func TestSMTPDialerRejectedRecipient(t *testing.T) {
+	for _, pipelining := range []bool{true, false} {
+		s := newFakeSMTPServer(t, pipelining)
+		s.rejectRcpt = "bad@example.com"
+		sender, err := s.dialer().Dial()
+		if err != nil {
+			t.Fatalf("error dialing: %v", err)
+		}
+		err = sender.Send("from@example.com", []string{"bad@example.com"}, bytes.NewBufferString("Hello"))
+		te, ok := err.(*textproto.Error)
+		if !ok || te.Code != 550 {
+			t.Fatalf("expected the rejected recipient error, got %v", err)
+		}
+		// The connection should still be usable for the next email
+		err = sender.Reset()
+		if err != nil {
+			t.Fatalf("error resetting: %v", err)
+		}
+		err = sender.Send("from@example.com", []string{"good@example.com"}, bytes.NewBufferString("Hello"))
+		if err != nil {
+			t.Fatalf("error sending after rejection with pipelining=%v: %v", pipelining, err)
+		}
+		sender.Close()
+		s.listener.Close()
+	}
+}
+
+func TestSMTPDialerInvalidAddress(t *testing.T) {
+	s := newFakeSMTPServer(t, true)
+	defer s.listener.Close()
+	sender, err := s.dialer().Dial()
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	defer sender.Close()
+	err = sender.Send("from@example.com", []string{"a@example.com>\r\nRCPT TO:<b@example.com"}, bytes.NewBufferString("Hello"))
+	if err != errInvalidAddress {
+		t.Fatalf("expected %v, got %v", errInvalidAddress, err)
+	}
+}
+
+func TestSMTPDialerPoolKey(t *testing.T) {
+	a := NewSMTPDialer("smtp.example.com", 25, "user", "password")
+	b := NewSMTPDialer("smtp.example.com", 25, "user", "password")
+	if a.PoolKey() != b.PoolKey() {
+		t.Fatalf("expected dialers with the same settings to share a key")
+	}
+	b.Password = "rotated"
+	if a.PoolKey() == b.PoolKey() {
+		t.Fatalf("expected dialers with different credentials to have different keys")
+	}
+	if strings.Contains(a.PoolKey(), "password") {
+		t.Fatalf("pool key contains the password: %s", a.PoolKey())
+	}
+}
+
+func TestSMTPDialerTranscript(t *testing.T) {
+	for _, pipelining := range []bool{true, false} {
+		s := newFakeSMTPServer(t, pipelining)
+		s.rejectRcpt = "bad@example.com"
+		sender, err := s.dialer().Dial()
+		if err != nil {
+			t.Fatalf("error dialing: %v", err)
+		}
+		err = sender.Send("from@example.com", []string{"good@example.com"}, bytes.NewBufferString("Secret content"))
+		if err != nil {
+			t.Fatalf("error sending: %v", err)
+		}
+		sender.Send("from@example.com", []string{"bad@example.com"}, bytes.NewBufferString("Secret content"))
+		transcript := strings.Join(sender.(Transcriber).Transcript(), "\n")
+		for _, expected := range []string{"C: EHLO localhost", "C: RCPT TO:<bad@example.com>", "S: 550"} {
+			if !strings.Contains(transcript, expected) {
+				t.Fatalf("expected transcript with pipelining=%v to contain %q, got:\n%s", pipelining, expected, transcript)
+			}
+		}
+		// Only the most recent email is included, and never its content
+		if strings.Contains(transcript, "good@example.com") || strings.Contains(transcript, "Secret content") {
+			t.Fatalf("unexpected transcript with pipelining=%v:\n%s", pipelining, transcript)
+		}
+		sender.Close()
+		s.listener.Close()
+	}
+
+	// Failing to connect returns the transcript up to the failure
+	s := newFakeSMTPServer(t, true)
+	d := s.dialer()
+	s.listener.Close()
+	_, err := d.Dial()
+	te, ok := err.(*TranscriptError)
+	if !ok {
+		t.Fatalf("expected a *TranscriptError, got %#v", err)
+	}
+	if len(te.Transcript) != 2 || !strings.HasPrefix(te.Transcript[1], "E: ") {
+		t.Fatalf("unexpected transcript: %v", te.Transcript)
+	}
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Stats reports the batches of emails being sent by a MailWorker.
+type Stats struct {
+	// Concurrency is the number of batches which can be sent at once, or
+	// zero if there's no limit.
+	Concurrency int `json:"concurrency"`
+	// ActiveBatches are being sent, while WaitingBatches are waiting for
+	// another batch to finish.
+	ActiveBatches  int64 `json:"active_batches"`
+	WaitingBatches int64 `json:"waiting_batches"`
+	// BatchesSent and EmailsSent count the batches which have been sent since
+	// the worker was started, and the emails in them. Emails which failed
+	// to send are included.
+	BatchesSent int64 `json:"batches_sent"`
+	EmailsSent  int64 `json:"emails_sent"`
+}
+
+// stats holds the counters reported in Stats. They're updated atomically,
+// so they're kept first in the MailWorker to be aligned on 32 bit platforms.
+type stats struct {
+	active  int64
+	waiting int64
+	batches int64
+	emails  int64
+}
+
+// acquire waits until another batch can be sent, returning false if the
+// context is cancelled first.
+func (mw *MailWorker) acquire(ctx context.Context) bool {
+	if mw.slots != nil {
+		atomic.AddInt64(&mw.stats.waiting, 1)
+		defer atomic.AddInt64(&mw.stats.waiting, -1)
+		select {
+		case mw.slots <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	atomic.AddInt64(&mw.stats.active, 1)
+	return true
+}
+
+// release records that a batch of the given size has been sent, allowing
+// another batch to be sent.
+func (mw *MailWorker) release(size int) {
+	atomic.AddInt64(&mw.stats.active, -1)
+	atomic.AddInt64(&mw.stats.batches, 1)
+	atomic.AddInt64(&mw.stats.emails, int64(size))
+	if mw.slots != nil {
+		<-mw.slots
+	}
+}
+
+// Stats returns the batches being sent by the worker.
+func (mw *MailWorker) Stats() Stats {
+	return Stats{
+		Concurrency:    cap(mw.slots),
+		ActiveBatches:  atomic.LoadInt64(&mw.stats.active),
+		WaitingBatches: atomic.LoadInt64(&mw.stats.waiting),
+		BatchesSent:    atomic.LoadInt64(&mw.stats.batches),
+		EmailsSent:     atomic.LoadInt64(&mw.stats.emails),
+	}
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMailWorkerConcurrency(t *testing.T) {
+	defer func(orig int) { MaxConcurrentBatches = orig }(MaxConcurrentBatches)
+	MaxConcurrentBatches = 1
+	mw := NewMailWorker()
+	if !mw.acquire(context.Background()) {
+		t.Fatal("expected the first batch to be sent")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan bool)
+	go func() {
+		acquired <- mw.acquire(ctx)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for mw.Stats().WaitingBatches != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the second batch to wait for the first")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expected := Stats{Concurrency: 1, ActiveBatches: 1, WaitingBatches: 1}
+	if got := mw.Stats(); got != expected {
+		t.Fatalf("unexpected stats. expected %+v got %+v", expected, got)
+	}
+
+	// Batches waiting when the worker is stopped aren't sent
+	cancel()
+	if <-acquired {
+		t.Fatal("expected the waiting batch not to be sent once cancelled")
+	}
+	mw.release(2)
+	expected = Stats{Concurrency: 1, BatchesSent: 1, EmailsSent: 2}
+	if got := mw.Stats(); got != expected {
+		t.Fatalf("unexpected stats. expected %+v got %+v", expected, got)
+	}
+	if !mw.acquire(context.Background()) {
+		t.Fatal("expected a batch to be sent once the first finished")
+	}
+}
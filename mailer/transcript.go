@@ -0,0 +1,107 @@
+package mailer
+
+import (
+	"fmt"
+	"net/textproto"
+)
+
+// MaxTranscriptLines is the maximum number of lines kept in a transcript.
+// Later lines are dropped, since the start of the conversation is usually
+// the most useful when troubleshooting.
+var MaxTranscriptLines = 200
+
+// Transcriber is implemented by Senders which record their conversation with
+// the SMTP server.
+type Transcriber interface {
+	// Transcript returns the commands sent when connecting and when sending
+	// the most recent email, along with the server's responses. Credentials
+	// and message content aren't included.
+	Transcript() []string
+}
+
+// TranscriptRecorder is implemented by Mail which keeps the SMTP transcript
+// of a failed send.
+type TranscriptRecorder interface {
+	SetTranscript(transcript []string)
+}
+
+// TranscriptError is returned when connecting to an SMTP server fails. It
+// includes the conversation up to the failure.
+type TranscriptError struct {
+	Err        error
+	Transcript []string
+}
+
+// Error returns the underlying error
+func (e *TranscriptError) Error() string {
+	return e.Err.Error()
+}
+
+// transcript records an SMTP conversation. Client commands are prefixed with
+// "C:", server responses with "S:", and errors which aren't SMTP responses
+// with "E:".
+type transcript struct {
+	lines []string
+}
+
+func (t *transcript) add(line string) {
+	if len(t.lines) < MaxTranscriptLines {
+		t.lines = append(t.lines, line)
+	}
+}
+
+func (t *transcript) command(format string, args ...interface{}) {
+	t.add("C: " + fmt.Sprintf(format, args...))
+}
+
+// response records the server's response to a command. Since net/smtp
+// doesn't return successful responses, the expected code is recorded when
+// there's no message.
+func (t *transcript) response(code int, msg string, err error) {
+	if te, ok := err.(*textproto.Error); ok {
+		t.add(fmt.Sprintf("S: %d %s", te.Code, te.Msg))
+		return
+	}
+	if err != nil {
+		t.add(fmt.Sprintf("E: %v", err))
+		return
+	}
+	if msg == "" {
+		t.add(fmt.Sprintf("S: %d", code))
+		return
+	}
+	t.add(fmt.Sprintf("S: %d %s", code, msg))
+}
+
+func (t *transcript) reset() {
+	t.lines = nil
+}
+
+// errorTranscript returns the transcript included with an error from dialing.
+func errorTranscript(err error) []string {
+	if me, ok := err.(*ErrMaxConnectAttempts); ok {
+		err = me.underlyingError
+	}
+	if te, ok := err.(*TranscriptError); ok {
+		return te.Transcript
+	}
+	return nil
+}
+
+// recordTranscript gives the transcript to the mail, if it keeps one.
+func recordTranscript(lines []string, m Mail) {
+	if len(lines) == 0 {
+		return
+	}
+	if tr, ok := m.(TranscriptRecorder); ok {
+		tr.SetTranscript(lines)
+	}
+}
+
+// senderTranscript returns the sender's transcript, if it records one.
+func senderTranscript(s Sender) []string {
+	if t, ok := s.(Transcriber); ok {
+		return t.Transcript()
+	}
+	return nil
+}
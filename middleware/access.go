@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// AllowedNetworks are the IP addresses and CIDR ranges allowed to reach the
+// admin server.
+type AllowedNetworks []*net.IPNet
+
+// ParseAllowedNetworks parses a list of IP addresses and CIDR ranges, such as
+// 192.168.1.10 or 10.0.0.0/8.
+func ParseAllowedNetworks(entries []string) (AllowedNetworks, error) {
+	networks, err := parseNetworks("allowed network", entries)
+	return AllowedNetworks(networks), err
+}
+
+// RestrictNetworks rejects requests from clients outside of the allowed
+// networks. The client's address is read from the forwarding headers only
+// when the request is made by one of the trusted proxies, since the headers
+// of other requests can be forged. If no networks are given, every client is
+// allowed.
+func RestrictNetworks(an AllowedNetworks, tp TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(an) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := r.RemoteAddr
+			if len(tp) > 0 {
+				addr = tp.ClientIP(r)
+			}
+			if !containsAddr(an, addr) {
+				log.FromContext(r.Context()).Warnf("rejected request from %s outside of the allowed networks", addr)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireClientCertificate rejects requests which weren't made over TLS
+// using a client certificate signed by one of the trusted certificate
+// authorities.
+func RequireClientCertificate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			log.FromContext(r.Context()).Warnf("rejected request from %s without a client certificate", r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestrictNetworks(t *testing.T) {
+	an, err := ParseAllowedNetworks([]string{"192.168.0.0/16", "203.0.113.7"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing allowed networks: %v", err)
+	}
+	tp, err := ParseTrustedProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing trusted proxies: %v", err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		proxies  TrustedProxies
+		remote   string
+		xff      string
+		expected int
+	}{
+		{nil, "192.168.1.5:1234", "", http.StatusOK},
+		{nil, "203.0.113.7:1234", "", http.StatusOK},
+		{nil, "198.51.100.1:1234", "", http.StatusForbidden},
+		// The forwarding headers aren't used unless proxies are trusted
+		{nil, "198.51.100.1:1234", "192.168.1.5", http.StatusForbidden},
+		{tp, "10.0.0.1:1234", "192.168.1.5", http.StatusOK},
+		{tp, "10.0.0.1:1234", "198.51.100.1", http.StatusForbidden},
+		{tp, "198.51.100.1:1234", "192.168.1.5", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remote
+		if test.xff != "" {
+			r.Header.Set("X-Forwarded-For", test.xff)
+		}
+		w := httptest.NewRecorder()
+		RestrictNetworks(an, test.proxies)(ok).ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Fatalf("unexpected status for %s (%q). expected %d got %d", test.remote, test.xff, test.expected, w.Code)
+		}
+	}
+
+	// Every client is allowed when no networks are given
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "198.51.100.1:1234"
+	w := httptest.NewRecorder()
+	RestrictNetworks(nil, nil)(ok).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status without allowed networks. expected %d got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRequireClientCertificate(t *testing.T) {
+	h := RequireClientCertificate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		state    *tls.ConnectionState
+		expected int
+	}{
+		{nil, http.StatusForbidden},
+		{&tls.ConnectionState{}, http.StatusForbidden},
+		{&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.TLS = test.state
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Fatalf("unexpected status. expected %d got %d", test.expected, w.Code)
+		}
+	}
+}
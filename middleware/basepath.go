@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	ctx "github.com/gophish/gophish/context"
+)
+
+// BasePath returns the path prefix the admin server is served under, such as
+// "/gophish", or an empty string if it's served from the root.
+func BasePath(r *http.Request) string {
+	if p, ok := ctx.Get(r, "base_path").(string); ok {
+		return p
+	}
+	return ""
+}
+
+// StripBasePath serves the admin server under the given path prefix, for
+// reverse proxies which route requests to Gophish by their path. The prefix
+// is removed from requests before they're routed, and added to the redirects
+// sent in response. Requests outside of the prefix aren't found.
+func StripBasePath(base string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if base == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == base {
+				http.Redirect(w, r, base+"/", http.StatusMovedPermanently)
+				return
+			}
+			if !strings.HasPrefix(r.URL.Path, base+"/") {
+				http.NotFound(w, r)
+				return
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = strings.TrimPrefix(r.URL.Path, base)
+			r2.URL.RawPath = ""
+			r2 = ctx.Set(r2, "base_path", base)
+			next.ServeHTTP(&basePathWriter{ResponseWriter: w, base: base}, r2)
+		})
+	}
+}
+
+// basePathWriter adds the base path to the redirects sent by the admin
+// server's handlers, which redirect to paths from the root.
+type basePathWriter struct {
+	http.ResponseWriter
+	base string
+}
+
+func (w *basePathWriter) WriteHeader(code int) {
+	location := w.Header().Get("Location")
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") && !strings.HasPrefix(location, w.base+"/") {
+		w.Header().Set("Location", w.base+location)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets streamed responses, such as exports and the event stream, be
+// flushed through the writer.
+func (w *basePathWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripBasePath(t *testing.T) {
+	handler := StripBasePath("/gophish")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if BasePath(r) != "/gophish" {
+			t.Fatalf("unexpected base path. expected %q got %q", "/gophish", BasePath(r))
+		}
+		if r.URL.Path == "/campaigns" {
+			w.Write([]byte(r.URL.Path))
+			return
+		}
+		http.Redirect(w, r, "/login?next="+r.URL.Path, http.StatusFound)
+	}))
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/gophish/campaigns", http.StatusOK, ""},
+		{"/gophish/settings", http.StatusFound, "/gophish/login?next=/settings"},
+		{"/gophish", http.StatusMovedPermanently, "/gophish/"},
+		{"/campaigns", http.StatusNotFound, ""},
+		{"/gophishing/campaigns", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status {
+			t.Fatalf("unexpected status for %s. expected %d got %d", test.path, test.status, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != test.location {
+			t.Fatalf("unexpected location for %s. expected %q got %q", test.path, test.location, got)
+		}
+	}
+}
+
+func TestStripBasePathDisabled(t *testing.T) {
+	handler := StripBasePath("")(successHandler)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/campaigns", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status. expected %d got %d", http.StatusOK, w.Code)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	ctx "github.com/gophish/gophish/context"
+	log "github.com/gophish/gophish/logger"
 	"github.com/gophish/gophish/models"
 	"github.com/gorilla/csrf"
 )
@@ -56,11 +57,26 @@ func GetContext(handler http.Handler) http.HandlerFunc {
 		// reuse the values in different handlers
 		r = ctx.Set(r, "session", session)
 		if id, ok := session.Values["id"]; ok {
-			u, err := models.GetUser(id.(int64))
+			// The user is only logged in while their admin session hasn't
+			// been revoked or expired.
+			token, _ := session.Values[sessionTokenKey].(string)
+			s, err := models.GetAdminSession(token)
+			var u models.User
+			if err == nil && s.UserId == id.(int64) {
+				u, err = models.GetUser(s.UserId)
+			} else if err == nil {
+				err = models.ErrSessionNotFound
+			}
 			if err != nil {
+				delete(session.Values, "id")
+				delete(session.Values, sessionTokenKey)
 				r = ctx.Set(r, "user", nil)
 			} else {
+				if err := s.Touch(); err != nil {
+					log.FromContext(r.Context()).Error(err)
+				}
 				r = ctx.Set(r, "user", u)
+				r = ctx.Set(r, "admin_session", s)
 			}
 		} else {
 			r = ctx.Set(r, "user", nil)
@@ -114,10 +130,10 @@ func RequireAPIKey(handler http.Handler) http.Handler {
 func RequireLogin(handler http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if u := ctx.Get(r, "user"); u != nil {
-			// If a password change is required for the user, then redirect them
-			// to the login page
+			// If a password change is required for the user, or their
+			// password has expired, then redirect them to the reset page
 			currentUser := u.(models.User)
-			if currentUser.PasswordChangeRequired && r.URL.Path != "/reset_password" {
+			if (currentUser.PasswordChangeRequired || currentUser.PasswordExpired()) && r.URL.Path != "/reset_password" {
 				q := r.URL.Query()
 				q.Set("next", r.URL.Path)
 				http.Redirect(w, r, fmt.Sprintf("/reset_password?%s", q.Encode()), http.StatusTemporaryRedirect)
@@ -132,6 +148,38 @@ func RequireLogin(handler http.Handler) http.HandlerFunc {
 	}
 }
 
+// RequireEmbedToken ensures that a valid embed token is set as either the
+// token GET parameter, or a Bearer token. Since embed tokens are read-only,
+// the request is only given the id of the token's owner, and not the user
+// needed by the rest of the API.
+func RequireEmbedToken(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+			w.Header().Set("Access-Control-Max-Age", "1000")
+			w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
+			return
+		}
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if token == "" {
+			JSONError(w, http.StatusUnauthorized, "Embed token not set")
+			return
+		}
+		t, err := models.GetEmbedTokenByToken(token)
+		if err != nil {
+			JSONError(w, http.StatusUnauthorized, "Invalid embed token")
+			return
+		}
+		r = ctx.Set(r, "user_id", t.UserId)
+		r = ctx.Set(r, "embed_token", t)
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // EnforceViewOnly is a global middleware that limits the ability to edit
 // objects to accounts with the PermissionModifyObjects permission.
 func EnforceViewOnly(next http.Handler) http.Handler {
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+// TrustedProxies are the reverse proxies whose forwarding headers are used
+// to find the client's address, the scheme, and the host of requests.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges, such as
+// 10.0.0.1 or 10.0.0.0/8.
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	networks, err := parseNetworks("trusted proxy", entries)
+	return TrustedProxies(networks), err
+}
+
+// parseNetworks parses a list of IP addresses and CIDR ranges, treating
+// single addresses as ranges holding only that address.
+func parseNetworks(kind string, entries []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid %s %q", kind, entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", kind, entry)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+// Contains returns whether the address is one of the trusted proxies.
+func (tp TrustedProxies) Contains(addr string) bool {
+	return containsAddr(tp, addr)
+}
+
+// containsAddr returns whether the address, which may include a port, is in
+// one of the networks.
+func containsAddr(networks []*net.IPNet, addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultClientIPHeaders are the headers read to find the client's address
+// when none are configured.
+var DefaultClientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// ClientIP returns the address of the client which made the request. If the
+// request was made by a trusted proxy, the headers are read in order, such as
+// CF-Connecting-IP followed by X-Forwarded-For, and the first one holding an
+// address is used. Headers listing several addresses are read from the right,
+// skipping the proxies which are trusted, since the addresses on the left are
+// given by the client and can be forged. If no headers are given, the
+// DefaultClientIPHeaders are used.
+func (tp TrustedProxies) ClientIP(r *http.Request, headers ...string) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !tp.trusts(remote) {
+		return remote
+	}
+	if len(headers) == 0 {
+		headers = DefaultClientIPHeaders
+	}
+	for _, header := range headers {
+		values := r.Header[http.CanonicalHeaderKey(strings.TrimSpace(header))]
+		if len(values) == 0 {
+			continue
+		}
+		hops := strings.Split(strings.Join(values, ","), ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			client = hop
+			if !tp.Contains(hop) {
+				break
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+	return remote
+}
+
+// trusts returns whether the forwarding headers of requests from the address
+// are used. If no proxies are trusted, every request's headers are used.
+func (tp TrustedProxies) trusts(addr string) bool {
+	return len(tp) == 0 || tp.Contains(addr)
+}
+
+// ProxyHeaders sets the client's address, the scheme and the host of requests
+// made by trusted proxies from their forwarding headers, so that events record
+// the address of the recipient rather than the proxy's. The client's address
+// is read from the given headers, or from the X-Forwarded-For and X-Real-IP
+// headers if none are given. The headers of other requests are ignored. If no
+// proxies are trusted, the headers of every request are used.
+func ProxyHeaders(tp TrustedProxies, headers ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(tp) == 0 && len(headers) == 0 {
+			return handlers.ProxyHeaders(next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tp.trusts(r.RemoteAddr) {
+				r.RemoteAddr = tp.ClientIP(r, headers...)
+				if proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+					r.URL.Scheme = proto
+				}
+				if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+					r.Host = host
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing trusted proxies: %v", err)
+	}
+	for addr, expected := range map[string]bool{
+		"10.1.2.3":         true,
+		"10.1.2.3:443":     true,
+		"192.0.2.1":        true,
+		"192.0.2.2":        false,
+		"[2001:db8::1]:80": true,
+		"2001:db8::2":      false,
+		"invalid":          false,
+	} {
+		if got := tp.Contains(addr); got != expected {
+			t.Fatalf("unexpected trust for %s. expected %v got %v", addr, expected, got)
+		}
+	}
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	if err == nil {
+		t.Fatalf("expected an error parsing an invalid CIDR range")
+	}
+}
+
+func TestProxyHeaders(t *testing.T) {
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing trusted proxies: %v", err)
+	}
+	tests := []struct {
+		remote   string
+		xff      string
+		xri      string
+		proto    string
+		expected string
+		scheme   string
+	}{
+		// Requests from untrusted addresses can't forge their address
+		{"198.51.100.1:1234", "203.0.113.1", "", "https", "198.51.100.1:1234", ""},
+		{"10.0.0.1:1234", "203.0.113.1", "", "https", "203.0.113.1", "https"},
+		// The client's own entries on the left are skipped
+		{"10.0.0.1:1234", "192.0.2.1, 203.0.113.1, 10.0.0.2", "", "", "203.0.113.1", ""},
+		{"10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "", "10.0.0.3", ""},
+		{"10.0.0.1:1234", "", "203.0.113.1", "", "203.0.113.1", ""},
+		{"10.0.0.1:1234", "", "", "", "10.0.0.1", ""},
+	}
+	for _, test := range tests {
+		var got *http.Request
+		handler := ProxyHeaders(tp)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remote
+		r.Header.Set("X-Forwarded-For", test.xff)
+		r.Header.Set("X-Real-IP", test.xri)
+		r.Header.Set("X-Forwarded-Proto", test.proto)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got.RemoteAddr != test.expected {
+			t.Fatalf("unexpected address for %s %q. expected %s got %s", test.remote, test.xff, test.expected, got.RemoteAddr)
+		}
+		if got.URL.Scheme != test.scheme {
+			t.Fatalf("unexpected scheme for %s. expected %q got %q", test.remote, test.scheme, got.URL.Scheme)
+		}
+	}
+}
+
+func TestClientIPHeaders(t *testing.T) {
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing trusted proxies: %v", err)
+	}
+	headers := []string{"CF-Connecting-IP", "X-Forwarded-For"}
+	tests := []struct {
+		proxies  TrustedProxies
+		remote   string
+		cf       string
+		xff      string
+		expected string
+	}{
+		// Requests from untrusted addresses can't forge their address
+		{tp, "198.51.100.1:1234", "203.0.113.5", "", "198.51.100.1:1234"},
+		// The headers are read in order
+		{tp, "10.0.0.1:1234", "203.0.113.5", "203.0.113.1", "203.0.113.5"},
+		{tp, "10.0.0.1:1234", "", "192.0.2.1, 203.0.113.1", "203.0.113.1"},
+		{tp, "10.0.0.1:1234", "invalid", "203.0.113.1", "203.0.113.1"},
+		// X-Real-IP is only read by default
+		{tp, "10.0.0.1:1234", "", "", "10.0.0.1"},
+		// If no proxies are trusted, every request's headers are used
+		{TrustedProxies{}, "198.51.100.1:1234", "203.0.113.5", "", "203.0.113.5"},
+		{TrustedProxies{}, "198.51.100.1:1234", "", "192.0.2.1, 203.0.113.1", "203.0.113.1"},
+	}
+	for _, test := range tests {
+		var got *http.Request
+		handler := ProxyHeaders(test.proxies, headers...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remote
+		r.Header.Set("CF-Connecting-IP", test.cf)
+		r.Header.Set("X-Forwarded-For", test.xff)
+		r.Header.Set("X-Real-IP", "192.0.2.9")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got.RemoteAddr != test.expected {
+			t.Fatalf("unexpected address for %s %q %q. expected %s got %s", test.remote, test.cf, test.xff, test.expected, got.RemoteAddr)
+		}
+	}
+}
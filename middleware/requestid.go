@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/gorilla/handlers"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header holding the ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID accepted from a client or
+// reverse proxy.
+const maxRequestIDLength = 64
+
+// validRequestID returns whether the ID can be used in logs as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID gives each request an ID, which is stored in the request's
+// context and returned in the X-Request-ID header. IDs set by a load balancer
+// or reverse proxy in the X-Request-ID header are kept, so that requests can
+// be traced across them.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = log.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(log.WithRequestID(r.Context(), id)))
+	})
+}
+
+// LogRequest logs the request once it's been handled, along with its ID. It
+// is used as the formatter of a gorilla/handlers logging handler, and logs
+// through the logger rather than the given writer so that the request's
+// fields are written in the configured format.
+func LogRequest(_ io.Writer, p handlers.LogFormatterParams) {
+	log.FromContext(p.Request.Context()).WithFields(logrus.Fields{
+		"method":      p.Request.Method,
+		"path":        p.URL.Path,
+		"status":      p.StatusCode,
+		"size":        p.Size,
+		"duration_ms": time.Since(p.TimeStamp).Milliseconds(),
+		"remote_addr": p.Request.RemoteAddr,
+		"user_agent":  p.Request.UserAgent(),
+		"referer":     p.Request.Referer(),
+	}).Info("request")
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"", false},
+		{"lb-7f3a9c2e.01", true},
+		{"invalid id", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, test := range tests {
+		var got string
+		handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = log.RequestID(r.Context())
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, test.header)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if got == "" {
+			t.Fatalf("no request id set for %q", test.header)
+		}
+		if (got == test.header) != test.keep {
+			t.Fatalf("unexpected request id for %q. got %q", test.header, got)
+		}
+		if w.Header().Get(RequestIDHeader) != got {
+			t.Fatalf("unexpected request id header. expected %q got %q", got, w.Header().Get(RequestIDHeader))
+		}
+	}
+}
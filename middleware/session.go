@@ -2,12 +2,18 @@ package middleware
 
 import (
 	"encoding/gob"
+	"net"
+	"net/http"
 
 	"github.com/gophish/gophish/models"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 )
 
+// sessionTokenKey is the session value holding the token of the user's
+// admin session.
+const sessionTokenKey = "token"
+
 // init registers the necessary models to be saved in the session later
 func init() {
 	gob.Register(&models.User{})
@@ -21,3 +27,31 @@ func init() {
 var Store = sessions.NewCookieStore(
 	[]byte(securecookie.GenerateRandomKey(64)), //Signing key
 	[]byte(securecookie.GenerateRandomKey(32)))
+
+// StartAdminSession logs the user in, starting an admin session which is
+// kept in the session cookie. The session still needs to be saved.
+func StartAdminSession(r *http.Request, session *sessions.Session, uid int64) error {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	_, token, err := models.CreateAdminSession(uid, ip, r.UserAgent())
+	if err != nil {
+		return err
+	}
+	session.Values["id"] = uid
+	session.Values[sessionTokenKey] = token
+	return nil
+}
+
+// EndAdminSession logs the user out, ending the admin session kept in the
+// session cookie. The session still needs to be saved.
+func EndAdminSession(session *sessions.Session) error {
+	token, _ := session.Values[sessionTokenKey].(string)
+	delete(session.Values, "id")
+	delete(session.Values, sessionTokenKey)
+	if token == "" {
+		return nil
+	}
+	return models.DeleteAdminSessionByToken(token)
+}
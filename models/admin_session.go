@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gophish/gophish/auth"
+)
+
+// DefaultSessionLifetime is how long admin sessions last from when the user
+// logs in, unless a different lifetime is configured.
+const DefaultSessionLifetime = 5 * 24 * time.Hour
+
+// sessionActivityInterval is how often the last activity of a session is
+// saved, so that every request doesn't write to the database.
+const sessionActivityInterval = time.Minute
+
+// ErrSessionNotFound is thrown when an admin session doesn't exist, has been
+// revoked or has expired.
+var ErrSessionNotFound = errors.New("Session not found")
+
+// SessionPolicy configures how long admin sessions last. Sessions end once
+// they've been idle for IdleTimeout, or after Lifetime since the user logged
+// in. A zero IdleTimeout lets sessions stay idle for their whole lifetime.
+// If SingleSession is set, logging in ends the user's other sessions.
+type SessionPolicy struct {
+	IdleTimeout   time.Duration
+	Lifetime      time.Duration
+	SingleSession bool
+}
+
+// AdminSessionPolicy is the policy admin sessions are held to.
+var AdminSessionPolicy = SessionPolicy{Lifetime: DefaultSessionLifetime}
+
+// AdminSession is a user's logged in session on the admin server. The
+// session's token is kept in the user's session cookie, and only its hash is
+// stored.
+type AdminSession struct {
+	Id           int64     `json:"id"`
+	UserId       int64     `json:"-"`
+	TokenHash    string    `json:"-"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	CreatedDate  time.Time `json:"created_date"`
+	LastActivity time.Time `json:"last_activity"`
+	// Current is whether this is the session making the request.
+	Current bool `json:"current" gorm:"-"`
+}
+
+// Expired returns whether the session has ended under the session policy.
+func (s *AdminSession) Expired() bool {
+	now := time.Now().UTC()
+	p := AdminSessionPolicy
+	if p.Lifetime > 0 && now.Sub(s.CreatedDate) > p.Lifetime {
+		return true
+	}
+	return p.IdleTimeout > 0 && now.Sub(s.LastActivity) > p.IdleTimeout
+}
+
+// Touch records activity on the session, extending its idle timeout.
+func (s *AdminSession) Touch() error {
+	now := time.Now().UTC()
+	if now.Sub(s.LastActivity) < sessionActivityInterval {
+		return nil
+	}
+	s.LastActivity = now
+	return db.Model(&AdminSession{}).Where("id=?", s.Id).Update("last_activity", now).Error
+}
+
+// CreateAdminSession starts a session for the user, returning the token
+// which identifies it. If the session policy only allows a single session,
+// the user's other sessions are ended.
+func CreateAdminSession(uid int64, ip string, userAgent string) (AdminSession, string, error) {
+	if AdminSessionPolicy.SingleSession {
+		err := DeleteAdminSessions(uid)
+		if err != nil {
+			return AdminSession{}, "", err
+		}
+	}
+	token := auth.GenerateSecureKey(auth.APIKeyLength)
+	now := time.Now().UTC()
+	s := AdminSession{
+		UserId:       uid,
+		TokenHash:    hashAPIKey(token),
+		IP:           ip,
+		UserAgent:    userAgent,
+		CreatedDate:  now,
+		LastActivity: now,
+	}
+	err := db.Save(&s).Error
+	return s, token, err
+}
+
+// GetAdminSession returns the session the token identifies. Expired sessions
+// are removed, returning ErrSessionNotFound.
+func GetAdminSession(token string) (AdminSession, error) {
+	s := AdminSession{}
+	if token == "" {
+		return s, ErrSessionNotFound
+	}
+	err := db.Where("token_hash=?", hashAPIKey(token)).First(&s).Error
+	if err != nil {
+		return s, ErrSessionNotFound
+	}
+	if s.Expired() {
+		err = db.Where("id=?", s.Id).Delete(&AdminSession{}).Error
+		if err != nil {
+			return s, err
+		}
+		return s, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+// GetAdminSessions returns the user's active sessions, most recently active
+// first. Expired sessions are removed.
+func GetAdminSessions(uid int64) ([]AdminSession, error) {
+	ss := []AdminSession{}
+	err := db.Where("user_id=?", uid).Order("last_activity desc").Find(&ss).Error
+	if err != nil {
+		return ss, err
+	}
+	active := []AdminSession{}
+	for _, s := range ss {
+		if !s.Expired() {
+			active = append(active, s)
+			continue
+		}
+		err = db.Where("id=?", s.Id).Delete(&AdminSession{}).Error
+		if err != nil {
+			return active, err
+		}
+	}
+	return active, nil
+}
+
+// DeleteAdminSession ends the user's session with the given id.
+func DeleteAdminSession(id int64, uid int64) error {
+	s := AdminSession{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&s).Error
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	return db.Where("id=?", s.Id).Delete(&AdminSession{}).Error
+}
+
+// DeleteAdminSessionByToken ends the session the token identifies.
+func DeleteAdminSessionByToken(token string) error {
+	return db.Where("token_hash=?", hashAPIKey(token)).Delete(&AdminSession{}).Error
+}
+
+// DeleteAdminSessions ends all of the user's sessions.
+func DeleteAdminSessions(uid int64) error {
+	return db.Where("user_id=?", uid).Delete(&AdminSession{}).Error
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAdminSession(c *check.C) {
+	sess, token, err := CreateAdminSession(1, "127.0.0.1", "test agent")
+	c.Assert(err, check.Equals, nil)
+
+	got, err := GetAdminSession(token)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(got.Id, check.Equals, sess.Id)
+	c.Assert(got.TokenHash, check.Not(check.Equals), token)
+
+	_, err = GetAdminSession(token + "x")
+	c.Assert(err, check.Equals, ErrSessionNotFound)
+
+	ss, err := GetAdminSessions(1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(ss), check.Equals, 1)
+
+	c.Assert(DeleteAdminSession(sess.Id, 2), check.Equals, ErrSessionNotFound)
+	c.Assert(DeleteAdminSession(sess.Id, 1), check.Equals, nil)
+	_, err = GetAdminSession(token)
+	c.Assert(err, check.Equals, ErrSessionNotFound)
+}
+
+func (s *ModelsSuite) TestAdminSessionTimeouts(c *check.C) {
+	defer func(p SessionPolicy) { AdminSessionPolicy = p }(AdminSessionPolicy)
+	AdminSessionPolicy = SessionPolicy{IdleTimeout: time.Hour, Lifetime: 24 * time.Hour}
+
+	idle, idleToken, err := CreateAdminSession(1, "127.0.0.1", "")
+	c.Assert(err, check.Equals, nil)
+	err = db.Model(&idle).Update("last_activity", time.Now().UTC().Add(-2*time.Hour)).Error
+	c.Assert(err, check.Equals, nil)
+
+	old, oldToken, err := CreateAdminSession(1, "127.0.0.1", "")
+	c.Assert(err, check.Equals, nil)
+	err = db.Model(&old).Update("created_date", time.Now().UTC().Add(-25*time.Hour)).Error
+	c.Assert(err, check.Equals, nil)
+
+	_, activeToken, err := CreateAdminSession(1, "127.0.0.1", "")
+	c.Assert(err, check.Equals, nil)
+
+	_, err = GetAdminSession(idleToken)
+	c.Assert(err, check.Equals, ErrSessionNotFound)
+	_, err = GetAdminSession(oldToken)
+	c.Assert(err, check.Equals, ErrSessionNotFound)
+	_, err = GetAdminSession(activeToken)
+	c.Assert(err, check.Equals, nil)
+
+	ss, err := GetAdminSessions(1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(ss), check.Equals, 1)
+}
+
+func (s *ModelsSuite) TestSingleAdminSession(c *check.C) {
+	defer func(p SessionPolicy) { AdminSessionPolicy = p }(AdminSessionPolicy)
+	AdminSessionPolicy = SessionPolicy{Lifetime: DefaultSessionLifetime, SingleSession: true}
+
+	_, first, err := CreateAdminSession(1, "127.0.0.1", "")
+	c.Assert(err, check.Equals, nil)
+	_, second, err := CreateAdminSession(1, "127.0.0.2", "")
+	c.Assert(err, check.Equals, nil)
+
+	_, err = GetAdminSession(first)
+	c.Assert(err, check.Equals, ErrSessionNotFound)
+	_, err = GetAdminSession(second)
+	c.Assert(err, check.Equals, nil)
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// AllowlistHeader is the header added to campaign emails with the campaign's
+// allowlist token, so that mail gateways can recognize them.
+const AllowlistHeader = "X-Gophish-Allowlist-Token"
+
+// The mail gateways which allowlist rules are generated for
+const (
+	GatewayMicrosoft365 = "microsoft365"
+	GatewayGoogle       = "google_workspace"
+	GatewayProofpoint   = "proofpoint"
+)
+
+// lookupIP resolves the sending server's hostname. It's a variable so that
+// it can be replaced in tests.
+var lookupIP = net.LookupIP
+
+// GatewayRule describes how to allowlist a campaign's emails in a mail
+// gateway. Steps are followed in the gateway's admin console, while the
+// script, if any, applies the same rules from the gateway's command line.
+type GatewayRule struct {
+	Gateway string   `json:"gateway"`
+	Name    string   `json:"name"`
+	Steps   []string `json:"steps"`
+	Script  string   `json:"script,omitempty"`
+}
+
+// CampaignAllowlist contains what mail gateways need to match to let a
+// campaign's emails through, and the rules to do so in common gateways.
+type CampaignAllowlist struct {
+	CampaignId      int64         `json:"campaign_id"`
+	HeaderName      string        `json:"header_name"`
+	HeaderValue     string        `json:"header_value"`
+	SenderAddresses []string      `json:"sender_addresses"`
+	SenderDomains   []string      `json:"sender_domains"`
+	SendingHosts    []string      `json:"sending_hosts"`
+	SendingIPs      []string      `json:"sending_ips"`
+	LinkDomains     []string      `json:"link_domains"`
+	Rules           []GatewayRule `json:"rules"`
+}
+
+// newAllowlistToken returns a new secret for a campaign's AllowlistHeader.
+func newAllowlistToken() (EncryptedString, error) {
+	token, err := randomString(32, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789")
+	return EncryptedString(token), err
+}
+
+// appendUnique appends the value to the slice if it isn't empty or already
+// in it.
+func appendUnique(values []string, v string) []string {
+	if v == "" {
+		return values
+	}
+	for _, existing := range values {
+		if existing == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
+
+// GetCampaignAllowlist returns the allowlist entries and gateway rules for
+// the campaign. Campaigns created before allowlist tokens were added are
+// given one, which is sent with the emails they send from then on.
+func GetCampaignAllowlist(id int64, uid int64) (CampaignAllowlist, error) {
+	c, err := GetCampaignMailContext(id, uid)
+	if err != nil {
+		return CampaignAllowlist{}, err
+	}
+	if c.AllowlistToken == "" {
+		c.AllowlistToken, err = newAllowlistToken()
+		if err != nil {
+			return CampaignAllowlist{}, err
+		}
+		err = db.Model(&Campaign{}).Where("id=?", c.Id).Update("allowlist_token", c.AllowlistToken).Error
+		if err != nil {
+			return CampaignAllowlist{}, err
+		}
+	}
+	a := CampaignAllowlist{
+		CampaignId:      c.Id,
+		HeaderName:      AllowlistHeader,
+		HeaderValue:     string(c.AllowlistToken),
+		SenderAddresses: []string{},
+		SenderDomains:   []string{},
+		SendingHosts:    []string{},
+		SendingIPs:      []string{},
+		LinkDomains:     []string{},
+	}
+	senders := []string{c.SMTP.FromAddress, c.Template.envelopeFrom()}
+	for _, sender := range senders {
+		addr, err := mail.ParseAddress(sender)
+		if err != nil {
+			continue
+		}
+		address := strings.ToLower(addr.Address)
+		a.SenderAddresses = appendUnique(a.SenderAddresses, address)
+		a.SenderDomains = appendUnique(a.SenderDomains, address[strings.LastIndex(address, "@")+1:])
+	}
+	host := c.SMTP.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if ip := net.ParseIP(host); ip != nil {
+		a.SendingIPs = appendUnique(a.SendingIPs, ip.String())
+	} else if host != "" {
+		a.SendingHosts = appendUnique(a.SendingHosts, host)
+		ips, err := lookupIP(host)
+		if err != nil {
+			log.Warnf("Unable to resolve the sending server %s: %v", host, err)
+		}
+		for _, ip := range ips {
+			a.SendingIPs = appendUnique(a.SendingIPs, ip.String())
+		}
+	}
+	for _, link := range []string{c.URL, c.ShortURL} {
+		if u, err := url.Parse(link); err == nil {
+			a.LinkDomains = appendUnique(a.LinkDomains, strings.ToLower(u.Hostname()))
+		}
+	}
+	name := fmt.Sprintf("Gophish campaign %d - %s", c.Id, c.Name)
+	a.Rules = []GatewayRule{
+		a.microsoft365Rule(name),
+		a.googleRule(name),
+		a.proofpointRule(name),
+	}
+	return a, nil
+}
+
+// psQuote quotes the value as a PowerShell string.
+func psQuote(v string) string {
+	return "'" + strings.Replace(v, "'", "''", -1) + "'"
+}
+
+// psList quotes the values as a PowerShell array.
+func psList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = psQuote(v)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// microsoft365Rule returns an Exchange Online transport rule which skips
+// spam filtering for emails with the campaign's token, and the advanced
+// delivery entries which stop Defender from acting on the emails and links.
+func (a CampaignAllowlist) microsoft365Rule(name string) GatewayRule {
+	r := GatewayRule{
+		Gateway: GatewayMicrosoft365,
+		Name:    name,
+		Steps: []string{
+			fmt.Sprintf("In the Exchange admin center, go to Mail flow > Rules and add a rule named \"%s\".", name),
+			fmt.Sprintf("Apply the rule if the message header \"%s\" matches the pattern \"^%s$\".", a.HeaderName, a.HeaderValue),
+			"Set the spam confidence level (SCL) to \"Bypass spam filtering\".",
+		},
+	}
+	script := []string{
+		fmt.Sprintf("New-TransportRule -Name %s -HeaderMatchesMessageHeader %s -HeaderMatchesPatterns %s -SetSCL -1",
+			psQuote(name), psQuote(a.HeaderName), psQuote("^"+a.HeaderValue+"$")),
+	}
+	if len(a.SenderDomains) > 0 && len(a.SendingIPs) > 0 {
+		r.Steps = append(r.Steps, fmt.Sprintf("In the Microsoft 365 Defender portal, go to Policies & rules > Threat policies > Advanced delivery > Phishing simulation and add the domains %s and the sending IPs %s.",
+			strings.Join(a.SenderDomains, ", "), strings.Join(a.SendingIPs, ", ")))
+		script = append(script,
+			"if (-not (Get-PhishSimOverridePolicy -ErrorAction SilentlyContinue)) { New-PhishSimOverridePolicy -Name PhishSimOverridePolicy }",
+			fmt.Sprintf("New-ExoPhishSimOverrideRule -Policy PhishSimOverridePolicy -Domains %s -SenderIpRanges %s",
+				psList(a.SenderDomains), psList(a.SendingIPs)))
+	}
+	if len(a.LinkDomains) > 0 {
+		entries := make([]string, len(a.LinkDomains))
+		for i, d := range a.LinkDomains {
+			entries[i] = d + "/*"
+		}
+		r.Steps = append(r.Steps, fmt.Sprintf("Add the simulation URLs %s, so that Safe Links doesn't block or detonate them.", strings.Join(entries, ", ")))
+		script = append(script, fmt.Sprintf("New-TenantAllowBlockListItems -ListType Url -Allow -ListSubType AdvancedDelivery -NoExpiration -Entries %s",
+			psList(entries)))
+	}
+	r.Script = strings.Join(script, "\n") + "\n"
+	return r
+}
+
+// googleRule returns a Gmail content compliance rule which bypasses the spam
+// filter for emails with the campaign's token.
+func (a CampaignAllowlist) googleRule(name string) GatewayRule {
+	r := GatewayRule{
+		Gateway: GatewayGoogle,
+		Name:    name,
+		Steps: []string{
+			fmt.Sprintf("In the Google Admin console, go to Apps > Google Workspace > Gmail > Compliance and add a Content compliance rule named \"%s\".", name),
+			"Apply the rule to inbound messages.",
+			fmt.Sprintf("Add an advanced content match on \"Full headers\" which contains the text \"%s: %s\".", a.HeaderName, a.HeaderValue),
+			"Under \"Modify message\", select \"Bypass spam filter for this message\" and \"Skip quarantine\".",
+		},
+	}
+	if len(a.SendingIPs) > 0 {
+		r.Steps = append(r.Steps, fmt.Sprintf("Under Gmail > Spam, Phishing and Malware, add %s to the Email allowlist.", strings.Join(a.SendingIPs, ", ")))
+	}
+	return r
+}
+
+// proofpointRule returns a Proofpoint email firewall rule which lets emails
+// with the campaign's token through, and the safe list entries for the
+// sending IPs and links.
+func (a CampaignAllowlist) proofpointRule(name string) GatewayRule {
+	r := GatewayRule{
+		Gateway: GatewayProofpoint,
+		Name:    name,
+		Steps: []string{
+			fmt.Sprintf("In the Proofpoint admin portal, go to Email Protection > Email Firewall > Rules and add a rule named \"%s\".", name),
+			fmt.Sprintf("Add the condition \"Message Headers\": the header \"%s\" is \"%s\".", a.HeaderName, a.HeaderValue),
+			"Set the disposition to \"Continue\" and enable \"Safe\" so that spam and phishing filtering are skipped.",
+		},
+	}
+	if len(a.SendingIPs) > 0 {
+		r.Steps = append(r.Steps, fmt.Sprintf("Under Email Protection > Spam Detection > Organizational Safe List, add the sender IPs %s.", strings.Join(a.SendingIPs, ", ")))
+	}
+	if len(a.LinkDomains) > 0 {
+		r.Steps = append(r.Steps, fmt.Sprintf("Exclude %s from URL Defense rewriting, so that link clicks aren't made by the sandbox.", strings.Join(a.LinkDomains, ", ")))
+	}
+	return r
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignAllowlist(ch *check.C) {
+	defer func() { lookupIP = net.LookupIP }()
+	lookupIP = func(host string) ([]net.IP, error) {
+		ch.Assert(host, check.Equals, "smtp.example.com")
+		return []net.IP{net.ParseIP("203.0.113.5"), net.ParseIP("203.0.113.5")}, nil
+	}
+	c := s.createCampaignDependencies(ch)
+	c.SMTP.Host = "smtp.example.com:587"
+	c.SMTP.FromAddress = "IT Support <Support@Example.net>"
+	ch.Assert(PutSMTP(&c.SMTP), check.Equals, nil)
+	c.URL = "https://login.example.org"
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.AllowlistToken, check.Not(check.Equals), EncryptedString(""))
+
+	// The token is sent with the campaign's emails
+	m := &MailLog{}
+	ch.Assert(db.Where("campaign_id=?", c.Id).First(m).Error, check.Equals, nil)
+	msg := gomail.NewMessage()
+	ch.Assert(m.Generate(msg), check.Equals, nil)
+	ch.Assert(msg.GetHeader(AllowlistHeader), check.DeepEquals, []string{string(c.AllowlistToken)})
+
+	a, err := GetCampaignAllowlist(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(a.HeaderName, check.Equals, AllowlistHeader)
+	ch.Assert(a.HeaderValue, check.Equals, string(c.AllowlistToken))
+	ch.Assert(a.SenderAddresses, check.DeepEquals, []string{"support@example.net"})
+	ch.Assert(a.SenderDomains, check.DeepEquals, []string{"example.net"})
+	ch.Assert(a.SendingHosts, check.DeepEquals, []string{"smtp.example.com"})
+	ch.Assert(a.SendingIPs, check.DeepEquals, []string{"203.0.113.5"})
+	ch.Assert(a.LinkDomains, check.DeepEquals, []string{"login.example.org"})
+	ch.Assert(a.Rules, check.HasLen, 3)
+	for _, r := range a.Rules {
+		ch.Assert(strings.Contains(strings.Join(r.Steps, "\n"), a.HeaderValue), check.Equals, true)
+	}
+	ch.Assert(a.Rules[0].Gateway, check.Equals, GatewayMicrosoft365)
+	ch.Assert(strings.Contains(a.Rules[0].Script, "-HeaderMatchesPatterns '^"+a.HeaderValue+"$' -SetSCL -1"), check.Equals, true)
+	ch.Assert(strings.Contains(a.Rules[0].Script, "-Domains 'example.net' -SenderIpRanges '203.0.113.5'"), check.Equals, true)
+	ch.Assert(strings.Contains(a.Rules[0].Script, "-Entries 'login.example.org/*'"), check.Equals, true)
+
+	// Campaigns created without a token are given one
+	ch.Assert(db.Model(&Campaign{}).Where("id=?", c.Id).Update("allowlist_token", "").Error, check.Equals, nil)
+	lookupIP = func(string) ([]net.IP, error) { return nil, errors.New("no such host") }
+	a, err = GetCampaignAllowlist(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(a.HeaderValue, check.HasLen, 32)
+	ch.Assert(a.HeaderValue, check.Not(check.Equals), string(c.AllowlistToken))
+	ch.Assert(a.SendingIPs, check.HasLen, 0)
+	ch.Assert(strings.Contains(a.Rules[0].Script, "PhishSimOverride"), check.Equals, false)
+	got, err := GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(got.AllowlistToken), check.Equals, a.HeaderValue)
+
+	_, err = GetCampaignAllowlist(c.Id, 2)
+	ch.Assert(err, check.NotNil)
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/encryption"
+)
+
+// AnonymizedEmailPrefix is the prefix given to pseudonymized email addresses
+// stored for campaigns running in privacy mode.
+const AnonymizedEmailPrefix = "hmac-sha256:"
+
+// ErrNoAnonymizeKey is thrown when a campaign is created in privacy mode
+// without a key to pseudonymize its recipients' addresses with.
+var ErrNoAnonymizeKey = errors.New("Privacy mode needs an anonymize_key to be configured under encryption")
+
+// anonymizeKey is the key used to pseudonymize email addresses. If no key is
+// configured, this is nil and campaigns can't be created in privacy mode.
+var anonymizeKey []byte
+
+// loadAnonymizeKey returns the configured key used to pseudonymize email
+// addresses, or nil if no key is configured.
+func loadAnonymizeKey(c config.Encryption) ([]byte, error) {
+	if c.AnonymizeKey == "" {
+		return nil, nil
+	}
+	return encryption.ParseKey(c.AnonymizeKey)
+}
+
+// AnonymizeEmail returns a pseudonym for the given email address. The same
+// address always maps to the same pseudonym, which lets results still be
+// correlated without storing who the recipient was. Pseudonyms are keyed, so
+// they can't be reversed by hashing a list of known addresses.
+//
+// Addresses which have already been pseudonymized are returned unchanged. If
+// no key is configured, the address is dropped entirely.
+func AnonymizeEmail(email string) string {
+	if strings.HasPrefix(email, AnonymizedEmailPrefix) {
+		return email
+	}
+	if anonymizeKey == nil {
+		return AnonymizedEmailPrefix
+	}
+	h := hmac.New(sha256.New, anonymizeKey)
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return AnonymizedEmailPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// containsPseudonym returns whether the set of lowercased addresses includes
+// the pseudonym of the given email address. Without a key, pseudonyms can't
+// be matched.
+func containsPseudonym(emails map[string]bool, email string) bool {
+	return anonymizeKey != nil && emails[AnonymizeEmail(email)]
+}
+
+// isCampaignAnonymized returns whether or not the campaign with the given id
+// was created in privacy mode.
+func isCampaignAnonymized(cid int64) bool {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("anonymize").Where("id=?", cid).Find(&c).Error
+	if err != nil {
+		return false
+	}
+	return c.Anonymize
+}
+
+// anonymizeDetails strips anything identifying the recipient from the event
+// details, such as submitted form values, the raw request, and the client's
+// IP address.
+func anonymizeDetails(details interface{}) interface{} {
+	d, ok := details.(EventDetails)
+	if !ok {
+		return details
+	}
+	browser := make(map[string]string)
+	for k, v := range d.Browser {
+		if k == "address" {
+			continue
+		}
+		browser[k] = v
+	}
+	return EventDetails{Browser: browser, PasswordChecks: d.PasswordChecks}
+}
+
+// anonymize removes the recipient's identifying information from the result.
+// This must only be called once the email has been sent, since the real
+// address and name are needed to generate the email.
+func (r *Result) anonymize() {
+	r.Email = AnonymizeEmail(r.Email)
+	r.FirstName = ""
+	r.LastName = ""
+	r.Position = ""
+	r.IP = ""
+	r.Latitude = 0
+	r.Longitude = 0
+}
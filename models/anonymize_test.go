@@ -0,0 +1,77 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAnonymizeEmail(c *check.C) {
+	anon := AnonymizeEmail("Foo@Example.com ")
+	c.Assert(strings.HasPrefix(anon, AnonymizedEmailPrefix), check.Equals, true)
+	c.Assert(anon, check.Equals, AnonymizeEmail("foo@example.com"))
+	// Pseudonyms shouldn't be hashed a second time
+	c.Assert(AnonymizeEmail(anon), check.Equals, anon)
+
+	// Pseudonyms are keyed, so they can't be found by hashing the address
+	h := sha256.Sum256([]byte("foo@example.com"))
+	c.Assert(strings.Contains(anon, hex.EncodeToString(h[:])), check.Equals, false)
+	key := anonymizeKey
+	defer func() { anonymizeKey = key }()
+	anonymizeKey = []byte("another key")
+	c.Assert(AnonymizeEmail("foo@example.com"), check.Not(check.Equals), anon)
+
+	// Without a key, addresses are dropped rather than stored
+	anonymizeKey = nil
+	c.Assert(AnonymizeEmail("foo@example.com"), check.Equals, AnonymizedEmailPrefix)
+	c.Assert(containsPseudonym(map[string]bool{AnonymizedEmailPrefix: true}, "foo@example.com"), check.Equals, false)
+}
+
+func (s *ModelsSuite) TestAnonymizeKeyRequired(ch *check.C) {
+	key := anonymizeKey
+	defer func() { anonymizeKey = key }()
+	anonymizeKey = nil
+	campaign := s.createCampaignDependencies(ch)
+	campaign.Anonymize = true
+	ch.Assert(PostCampaign(&campaign, campaign.UserId), check.Equals, ErrNoAnonymizeKey)
+}
+
+func (s *ModelsSuite) TestAnonymizedCampaignResults(ch *check.C) {
+	campaign := s.createCampaignDependencies(ch)
+	campaign.Anonymize = true
+	ch.Assert(PostCampaign(&campaign, campaign.UserId), check.Equals, nil)
+
+	result := campaign.Results[0]
+	email := result.Email
+	// The real recipient details are needed until the email is sent
+	ch.Assert(result.FirstName, check.Not(check.Equals), "")
+
+	ch.Assert(result.HandleEmailSent(), check.Equals, nil)
+	result, err := GetResult(result.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Email, check.Equals, AnonymizeEmail(email))
+	ch.Assert(result.FirstName, check.Equals, "")
+	ch.Assert(result.LastName, check.Equals, "")
+
+	details := EventDetails{
+		Payload: url.Values{"password": []string{"secret"}},
+		Browser: map[string]string{"address": "127.0.0.1", "user-agent": "gophish-test"},
+	}
+	ch.Assert(result.HandleFormSubmit(details), check.Equals, nil)
+
+	events := []Event{}
+	err = db.Where("campaign_id=? and message=?", campaign.Id, EventDataSubmit).Find(&events).Error
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(events), check.Equals, 1)
+	ch.Assert(events[0].Email, check.Equals, AnonymizeEmail(email))
+
+	got := EventDetails{}
+	ch.Assert(json.Unmarshal([]byte(events[0].Details), &got), check.Equals, nil)
+	ch.Assert(len(got.Payload), check.Equals, 0)
+	ch.Assert(got.Browser["address"], check.Equals, "")
+	ch.Assert(got.Browser["user-agent"], check.Equals, "gophish-test")
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// eventColumns are the columns copied when a campaign's events are archived.
+const eventColumns = "id, campaign_id, email, time, message, details, tags"
+
+// eventsTable returns the name of the table holding the events for a campaign
+// with the given archived date.
+func eventsTable(archived time.Time) string {
+	if archived.IsZero() {
+		return "events"
+	}
+	return "archived_events"
+}
+
+// allEventsTable returns a derived table containing both the live and the
+// archived events for the campaigns owned by the given user.
+func allEventsTable(uid int64) string {
+	return fmt.Sprintf("(SELECT %[1]s FROM events WHERE campaign_id IN (SELECT id FROM campaigns WHERE user_id = %[2]d)"+
+		" UNION ALL SELECT %[1]s FROM archived_events WHERE campaign_id IN (SELECT id FROM campaigns WHERE user_id = %[2]d)) events",
+		eventColumns, uid)
+}
+
+// archiveCompletedCampaigns archives the campaigns that were completed before
+// the cutoff and haven't been archived yet. Archived campaigns which have
+// since received events, such as an email being reported, are archived again
+// so that the new events are moved as well.
+func archiveCompletedCampaigns(cutoff time.Time) error {
+	cs := []Campaign{}
+	err := db.Table("campaigns").Select("id").
+		Where("status = ? AND completed_date < ?", CampaignComplete, cutoff).
+		Where("archived_date IS NULL OR archived_date < completed_date OR EXISTS (SELECT 1 FROM events WHERE events.campaign_id = campaigns.id)").
+		Find(&cs).Error
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		err = archiveCampaign(c.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveCampaign moves the events for the campaign with the given id to the
+// archive table. Any maillogs left for the campaign are removed, since they're
+// only used to send emails, and the DNS records of the domains it used are
+// removed.
+func archiveCampaign(cid int64) error {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id, archived_date").Where("id = ?", cid).First(&c).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	tx := db.Begin()
+	err = tx.Exec(fmt.Sprintf("INSERT INTO archived_events (%[1]s) SELECT %[1]s FROM events WHERE campaign_id = ?", eventColumns), cid).Error
+	if err != nil {
+		tx.Rollback()
+		log.Error(err)
+		return err
+	}
+	result := tx.Where("campaign_id = ?", cid).Delete(&Event{})
+	if result.Error != nil {
+		tx.Rollback()
+		log.Error(result.Error)
+		return result.Error
+	}
+	err = tx.Where("campaign_id = ?", cid).Delete(&MailLog{}).Error
+	if err != nil {
+		tx.Rollback()
+		log.Error(err)
+		return err
+	}
+	err = tx.Table("campaigns").Where("id = ?", cid).UpdateColumn("archived_date", time.Now().UTC()).Error
+	if err != nil {
+		tx.Rollback()
+		log.Error(err)
+		return err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"campaign_id": cid,
+		"events":      result.RowsAffected,
+	}).Info("Archived campaign")
+	// The DNS records of the campaign's domains are only removed the first
+	// time it's archived, since the domains may have been provisioned again
+	// for other campaigns since.
+	if c.ArchivedDate.IsZero() {
+		err = teardownCampaignDomains(cid)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	return nil
+}
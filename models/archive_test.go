@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestArchiveCompletedCampaigns(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	ch.Assert(result.HandleClickedLink(EventDetails{Browser: map[string]string{"address": "127.0.0.1"}}), check.Equals, nil)
+	ch.Assert(CompleteCampaign(campaign.Id, campaign.UserId), check.Equals, nil)
+	before, err := GetCampaign(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	summary, err := GetCampaignSummary(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+
+	original := conf.DataRetention
+	defer func() { conf.DataRetention = original }()
+	conf.DataRetention = config.DataRetention{ArchiveDays: 7}
+
+	// Campaigns completed within the archive period aren't archived
+	ch.Assert(PurgeExpiredData(time.Now()), check.Equals, nil)
+	count := 0
+	db.Table("archived_events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+
+	ch.Assert(PurgeExpiredData(time.Now().AddDate(0, 0, 8)), check.Equals, nil)
+	db.Table("events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+	db.Table("archived_events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, len(before.Events))
+
+	// The archived events are still returned with the campaign
+	after, err := GetCampaign(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(after.ArchivedDate.IsZero(), check.Equals, false)
+	ch.Assert(len(after.Events), check.Equals, len(before.Events))
+	for i, e := range after.Events {
+		ch.Assert(e.Message, check.Equals, before.Events[i].Message)
+		ch.Assert(e.Details, check.Equals, before.Events[i].Details)
+	}
+	results, _, err := ListCampaignResults(campaign.Id, campaign.UserId, ListOptions{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(results.Events), check.Equals, len(before.Events))
+	delta, err := GetCampaignResultsDelta(campaign.Id, campaign.UserId, time.Time{}, 0)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(delta.Events), check.Equals, len(before.Events))
+	es, _, err := ListEvents(campaign.UserId, ListOptions{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(es), check.Equals, len(before.Events))
+	archived, err := GetCampaignSummary(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(archived.Stats, check.Equals, summary.Stats)
+
+	// Events received after archiving are archived on the next run
+	ch.Assert(result.HandleEmailReport(EventDetails{}), check.Equals, nil)
+	ch.Assert(PurgeExpiredData(time.Now().AddDate(0, 0, 8)), check.Equals, nil)
+	db.Table("events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+	db.Table("archived_events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, len(before.Events)+1)
+
+	ch.Assert(DeleteCampaign(campaign.Id), check.Equals, nil)
+	db.Table("archived_events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
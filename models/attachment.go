@@ -3,7 +3,6 @@ package models
 import (
 	"archive/zip"
 	"bytes"
-	"encoding/base64"
 	"io"
 	"io/ioutil"
 	"path/filepath"
@@ -11,13 +10,16 @@ import (
 )
 
 // Attachment contains the fields and methods for
-// an email attachment
+// an email attachment. Inline attachments are embedded in the email so that
+// the HTML can reference them using cid:<name>.
 type Attachment struct {
 	Id          int64  `json:"-"`
 	TemplateId  int64  `json:"-"`
 	Content     string `json:"content"`
 	Type        string `json:"type"`
 	Name        string `json:"name"`
+	StorageKey  string `json:"-"`
+	Inline      bool   `json:"inline"`
 	vanillaFile bool   // Vanilla file has no template variables
 }
 
@@ -40,14 +42,21 @@ func (a Attachment) Validate() error {
 	if err != nil {
 		return err
 	}
-	_, err = a.ApplyTemplate(ptx)
-	return err
+	r, err := a.ApplyTemplate(ptx)
+	if err != nil {
+		return err
+	}
+	return r.Close()
 }
 
 // ApplyTemplate parses different attachment files and applies the supplied phishing template.
-func (a *Attachment) ApplyTemplate(ptx PhishingTemplateContext) (io.Reader, error) {
+// The returned reader must be closed.
+func (a *Attachment) ApplyTemplate(ptx PhishingTemplateContext) (io.ReadCloser, error) {
 
-	decodedAttachment := base64.NewDecoder(base64.StdEncoding, strings.NewReader(a.Content))
+	decodedAttachment, err := a.Open()
+	if err != nil {
+		return nil, err
+	}
 
 	// If we've already determined there are no template variables in this attachment return it immediately
 	if a.vanillaFile == true {
@@ -69,6 +78,7 @@ func (a *Attachment) ApplyTemplate(ptx PhishingTemplateContext) (io.Reader, erro
 		// See https://stackoverflow.com/questions/16946978/how-to-unzip-io-readcloser
 		b := new(bytes.Buffer)
 		b.ReadFrom(decodedAttachment)
+		decodedAttachment.Close()
 		zipReader, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len())) // Create a new zip reader from the file
 
 		if err != nil {
@@ -121,10 +131,11 @@ func (a *Attachment) ApplyTemplate(ptx PhishingTemplateContext) (io.Reader, erro
 			}
 		}
 		zipWriter.Close()
-		return bytes.NewReader(newZipArchive.Bytes()), err
+		return ioutil.NopCloser(bytes.NewReader(newZipArchive.Bytes())), err
 
 	case ".txt", ".html", ".ics":
 		b, err := ioutil.ReadAll(decodedAttachment)
+		decodedAttachment.Close()
 		if err != nil {
 			return nil, err
 		}
@@ -135,7 +146,7 @@ func (a *Attachment) ApplyTemplate(ptx PhishingTemplateContext) (io.Reader, erro
 		if processedAttachment == string(b) {
 			a.vanillaFile = true
 		}
-		return strings.NewReader(processedAttachment), nil
+		return ioutil.NopCloser(strings.NewReader(processedAttachment)), nil
 	default:
 		return decodedAttachment, nil // Default is to simply return the file
 	}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// AutoCompleteCampaigns completes the running campaigns which meet one of
+// their automatic completion rules, so that their tracking links don't stay
+// live after they've been forgotten about.
+func AutoCompleteCampaigns(t time.Time) error {
+	cs := []Campaign{}
+	err := db.Where("status <> ? AND (auto_complete_days > 0 OR auto_complete_quiet_hours > 0 OR auto_complete_click_percent > 0)", CampaignComplete).
+		Find(&cs).Error
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		reason, err := c.autoCompleteReason(t.UTC())
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"campaign_id": c.Id,
+			"reason":      reason,
+		}).Info("Automatically completing campaign")
+		err = CompleteCampaign(c.Id, c.UserId)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+	return nil
+}
+
+// autoCompleteReason returns which of the campaign's automatic completion
+// rules has been met, or an empty string if none have.
+func (c *Campaign) autoCompleteReason(t time.Time) (string, error) {
+	if c.LaunchDate.After(t) {
+		return "", nil
+	}
+	// Campaigns stay open until every follow-up wave has launched, since
+	// waves are only launched for campaigns in progress
+	var unlaunched int64
+	err := db.Model(&CampaignWave{}).Where("campaign_id=? AND launched=?", c.Id, false).Count(&unlaunched).Error
+	if err != nil {
+		return "", err
+	}
+	if unlaunched > 0 {
+		return "", nil
+	}
+	if c.AutoCompleteDays > 0 && !t.Before(c.LaunchDate.AddDate(0, 0, c.AutoCompleteDays)) {
+		return fmt.Sprintf("%d days since launch", c.AutoCompleteDays), nil
+	}
+	if c.AutoCompleteClickPercent > 0 {
+		stats, err := getCampaignStats(c.Id)
+		if err != nil {
+			return "", err
+		}
+		if stats.Total > 0 && stats.ClickedLink*100 >= int64(c.AutoCompleteClickPercent)*stats.Total {
+			return fmt.Sprintf("%d%% of recipients clicked", c.AutoCompleteClickPercent), nil
+		}
+	}
+	if c.AutoCompleteQuietHours > 0 {
+		var pending int64
+		err := db.Model(&MailLog{}).Where("campaign_id=?", c.Id).Count(&pending).Error
+		if err != nil {
+			return "", err
+		}
+		if pending > 0 {
+			return "", nil
+		}
+		last := struct{ Time time.Time }{}
+		err = db.Table("events").Select("time").Where("campaign_id=?", c.Id).Order("time desc").Limit(1).Scan(&last).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return "", err
+		}
+		if !t.Before(last.Time.Add(time.Duration(c.AutoCompleteQuietHours) * time.Hour)) {
+			return fmt.Sprintf("no activity for %d hours after every email was sent", c.AutoCompleteQuietHours), nil
+		}
+	}
+	return "", nil
+}
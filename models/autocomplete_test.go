@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAutoCompleteValidation(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.AutoCompleteClickPercent = 101
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidAutoComplete)
+	c.AutoCompleteClickPercent = 0
+	c.AutoCompleteDays = -1
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidAutoComplete)
+}
+
+func (s *ModelsSuite) TestAutoCompleteCampaigns(ch *check.C) {
+	manual := s.createCampaign(ch)
+
+	byDays := s.createCampaignDependencies(ch)
+	byDays.Name = "Completed after a week"
+	byDays.AutoCompleteDays = 7
+	ch.Assert(PostCampaign(&byDays, byDays.UserId), check.Equals, nil)
+
+	byClicks := s.createCampaignDependencies(ch)
+	byClicks.Name = "Completed after half the recipients click"
+	byClicks.AutoCompleteClickPercent = 50
+	ch.Assert(PostCampaign(&byClicks, byClicks.UserId), check.Equals, nil)
+
+	byQuiet := s.createCampaignDependencies(ch)
+	byQuiet.Name = "Completed when quiet"
+	byQuiet.AutoCompleteQuietHours = 24
+	ch.Assert(PostCampaign(&byQuiet, byQuiet.UserId), check.Equals, nil)
+
+	status := func(c Campaign) string {
+		got, err := GetCampaign(c.Id, c.UserId)
+		ch.Assert(err, check.Equals, nil)
+		return got.Status
+	}
+
+	// Nothing is completed straight away, since the quiet campaign still has
+	// emails to send
+	now := time.Now().UTC()
+	ch.Assert(AutoCompleteCampaigns(now), check.Equals, nil)
+	for _, c := range []Campaign{manual, byDays, byClicks, byQuiet} {
+		ch.Assert(status(c), check.Not(check.Equals), CampaignComplete)
+	}
+
+	// Half the recipients clicking completes the campaign
+	for _, r := range byClicks.Results[:2] {
+		ch.Assert(r.HandleClickedLink(EventDetails{Browser: map[string]string{}}), check.Equals, nil)
+	}
+	ch.Assert(AutoCompleteCampaigns(now), check.Equals, nil)
+	ch.Assert(status(byClicks), check.Equals, CampaignComplete)
+
+	// Once every email is sent, the campaign is completed after the quiet
+	// period
+	ch.Assert(db.Where("campaign_id=?", byQuiet.Id).Delete(&MailLog{}).Error, check.Equals, nil)
+	ch.Assert(AutoCompleteCampaigns(now.Add(23*time.Hour)), check.Equals, nil)
+	ch.Assert(status(byQuiet), check.Not(check.Equals), CampaignComplete)
+	ch.Assert(AutoCompleteCampaigns(now.Add(25*time.Hour)), check.Equals, nil)
+	ch.Assert(status(byQuiet), check.Equals, CampaignComplete)
+
+	ch.Assert(AutoCompleteCampaigns(now.AddDate(0, 0, 8)), check.Equals, nil)
+	ch.Assert(status(byDays), check.Equals, CampaignComplete)
+	ch.Assert(status(manual), check.Not(check.Equals), CampaignComplete)
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrationsTable is the table goose uses to track the applied migrations. It
+// describes the schema rather than its contents, so it's never backed up or
+// restored.
+const migrationsTable = "goose_db_version"
+
+// DatabaseName returns the name of the database driver in use, such as
+// "sqlite3" or "mysql".
+func DatabaseName() string {
+	return db.Dialect().GetName()
+}
+
+// SchemaVersion returns the version of the latest migration applied to the
+// database, following the same rules as goose.
+func SchemaVersion() (int64, error) {
+	rows, err := db.Raw(fmt.Sprintf("SELECT version_id, is_applied FROM %s ORDER BY id DESC", migrationsTable)).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	skipped := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		var applied bool
+		err = rows.Scan(&version, &applied)
+		if err != nil {
+			return 0, err
+		}
+		if skipped[version] {
+			continue
+		}
+		if applied {
+			return version, nil
+		}
+		skipped[version] = true
+	}
+	return 0, rows.Err()
+}
+
+// BackupTables returns the names of the tables holding Gophish's data.
+func BackupTables() ([]string, error) {
+	var query string
+	switch DatabaseName() {
+	case "sqlite3":
+		query = "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+	default:
+		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() ORDER BY table_name"
+	}
+	rows, err := db.Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		if name != migrationsTable {
+			tables = append(tables, name)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// tableColumns returns the names of the columns in the table.
+func tableColumns(tx *gorm.DB, table string) ([]string, error) {
+	rows, err := tx.Raw(fmt.Sprintf("SELECT * FROM %s LIMIT 0", tx.Dialect().Quote(table))).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// DumpTable calls fn with each row in the table. The columns are the same for
+// every row.
+func DumpTable(table string, fn func(columns []string, values []interface{}) error) error {
+	rows, err := db.Raw(fmt.Sprintf("SELECT * FROM %s", db.Dialect().Quote(table))).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		err = rows.Scan(ptrs...)
+		if err != nil {
+			return err
+		}
+		err = fn(columns, values)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DatabaseRestore replaces the contents of the database within a single
+// transaction, so that a failed restore leaves the database untouched.
+type DatabaseRestore struct {
+	tx      *gorm.DB
+	columns map[string]map[string]bool
+}
+
+// BeginDatabaseRestore starts a restore, removing every row from the tables
+// returned by BackupTables.
+func BeginDatabaseRestore() (*DatabaseRestore, error) {
+	tables, err := BackupTables()
+	if err != nil {
+		return nil, err
+	}
+	r := &DatabaseRestore{
+		tx:      db.Begin(),
+		columns: map[string]map[string]bool{},
+	}
+	for _, table := range tables {
+		columns, err := tableColumns(r.tx, table)
+		if err != nil {
+			r.Rollback()
+			return nil, err
+		}
+		r.columns[table] = map[string]bool{}
+		for _, c := range columns {
+			r.columns[table][c] = true
+		}
+		err = r.tx.Exec(fmt.Sprintf("DELETE FROM %s", r.tx.Dialect().Quote(table))).Error
+		if err != nil {
+			r.Rollback()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Insert inserts a row into the table. The table and columns must exist in
+// the database, since they come from the backup being restored.
+func (r *DatabaseRestore) Insert(table string, columns []string, values []interface{}) error {
+	known, ok := r.columns[table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	if len(columns) != len(values) {
+		return fmt.Errorf("expected %d values for table %s, got %d", len(columns), table, len(values))
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		if !known[c] {
+			return fmt.Errorf("unknown column %s in table %s", c, table)
+		}
+		quoted[i] = r.tx.Dialect().Quote(c)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.tx.Dialect().Quote(table), strings.Join(quoted, ","), placeholders)
+	return r.tx.Exec(query, values...).Error
+}
+
+// Commit completes the restore.
+func (r *DatabaseRestore) Commit() error {
+	return r.tx.Commit().Error
+}
+
+// Rollback abandons the restore, leaving the database as it was.
+func (r *DatabaseRestore) Rollback() error {
+	err := r.tx.Rollback().Error
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+// dumpedRow is a row read from the database by DumpTable
+type dumpedRow struct {
+	table   string
+	columns []string
+	values  []interface{}
+}
+
+func dumpDatabase(c *check.C) []dumpedRow {
+	tables, err := BackupTables()
+	c.Assert(err, check.Equals, nil)
+	rows := []dumpedRow{}
+	for _, t := range tables {
+		c.Assert(t, check.Not(check.Equals), migrationsTable)
+		err = DumpTable(t, func(columns []string, values []interface{}) error {
+			rows = append(rows, dumpedRow{table: t, columns: columns, values: values})
+			return nil
+		})
+		c.Assert(err, check.Equals, nil)
+	}
+	return rows
+}
+
+func (s *ModelsSuite) TestSchemaVersion(c *check.C) {
+	version, err := SchemaVersion()
+	c.Assert(err, check.Equals, nil)
+	c.Assert(version > 0, check.Equals, true)
+}
+
+func (s *ModelsSuite) TestDatabaseRestore(c *check.C) {
+	p := Page{Name: "Original", HTML: "<html></html>", UserId: 1}
+	c.Assert(PostPage(&p), check.Equals, nil)
+	rows := dumpDatabase(c)
+	var page dumpedRow
+	for _, row := range rows {
+		if row.table == "pages" {
+			page = row
+		}
+	}
+
+	// A failed restore leaves the database untouched
+	r, err := BeginDatabaseRestore()
+	c.Assert(err, check.Equals, nil)
+	c.Assert(r.Insert("pages", append(page.columns, "bogus"), append(page.values, 1)), check.Not(check.Equals), nil)
+	c.Assert(r.Insert("bogus", page.columns, page.values), check.Not(check.Equals), nil)
+	c.Assert(r.Rollback(), check.Equals, nil)
+	_, err = GetPage(p.Id, 1)
+	c.Assert(err, check.Equals, nil)
+
+	// A successful restore replaces the existing rows
+	c.Assert(PostPage(&Page{Name: "Extra", HTML: "<html></html>", UserId: 1}), check.Equals, nil)
+	r, err = BeginDatabaseRestore()
+	c.Assert(err, check.Equals, nil)
+	for _, row := range rows {
+		c.Assert(r.Insert(row.table, row.columns, row.values), check.Equals, nil)
+	}
+	c.Assert(r.Commit(), check.Equals, nil)
+	ps, err := GetPages(1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(ps, check.HasLen, 1)
+	c.Assert(ps[0].Name, check.Equals, "Original")
+	c.Assert(ps[0].Id, check.Equals, p.Id)
+	_, err = GetUser(1)
+	c.Assert(err, check.Equals, nil)
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultConflictWindowDays is how many days apart campaigns sharing
+// recipients must run to avoid being reported as conflicting, unless another
+// window is given.
+const DefaultConflictWindowDays = 7
+
+// ErrInvalidCalendarRange is thrown when a calendar's end is before its
+// start.
+var ErrInvalidCalendarRange = errors.New("The calendar's end must be after its start")
+
+// ErrInvalidConflictWindow is thrown when the conflict window is negative.
+var ErrInvalidConflictWindow = errors.New("The conflict window can't be negative")
+
+// CalendarEntry is a campaign shown on the calendar. Campaigns run from their
+// launch date until they're completed or, if they're still running, until
+// their emails are sent.
+type CalendarEntry struct {
+	Id         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner,omitempty"`
+	Status     string    `json:"status"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Recipients int64     `json:"recipients"`
+}
+
+// CalendarConflict warns that two campaigns send to the same recipients
+// within the conflict window of each other.
+type CalendarConflict struct {
+	CampaignIds      []int64  `json:"campaign_ids"`
+	Campaigns        []string `json:"campaigns"`
+	SharedRecipients int64    `json:"shared_recipients"`
+	// GapHours is how long passes between the end of the first campaign and
+	// the start of the second. Overlapping campaigns have no gap.
+	GapHours float64 `json:"gap_hours"`
+}
+
+// Calendar lists the campaigns running between its start and end, along with
+// the conflicts between them.
+type Calendar struct {
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	WindowDays int                `json:"window_days"`
+	Campaigns  []CalendarEntry    `json:"campaigns"`
+	Conflicts  []CalendarConflict `json:"conflicts"`
+}
+
+// GetCampaignCalendar returns the calendar of the campaigns running between
+// the start and end, warning of campaigns which share recipients and run
+// within the given number of days of each other. If all is set, every user's
+// campaigns are included, so that teams can see each other's campaigns.
+// Otherwise only the given user's campaigns are included.
+func GetCampaignCalendar(uid int64, all bool, start time.Time, end time.Time, windowDays int) (Calendar, error) {
+	cal := Calendar{
+		Start:      start.UTC(),
+		End:        end.UTC(),
+		WindowDays: windowDays,
+		Campaigns:  []CalendarEntry{},
+		Conflicts:  []CalendarConflict{},
+	}
+	if !end.After(start) {
+		return cal, ErrInvalidCalendarRange
+	}
+	if windowDays < 0 {
+		return cal, ErrInvalidConflictWindow
+	}
+	window := time.Duration(windowDays) * 24 * time.Hour
+	cs := []Campaign{}
+	query := db.Select("id, user_id, name, status, created_date, launch_date, send_by_date, completed_date").
+		Order("launch_date asc, id asc")
+	if !all {
+		query = query.Where("user_id=?", uid)
+	}
+	// Campaigns which started after the calendar ends can be skipped
+	// immediately. The end of a campaign depends on its status, so the
+	// campaigns which ended before the calendar starts are skipped below.
+	err := query.Where("launch_date <= ?", cal.End).Find(&cs).Error
+	if err != nil {
+		return cal, err
+	}
+	owners := map[int64]string{}
+	for _, c := range cs {
+		e := newCalendarEntry(c)
+		if e.End.Before(cal.Start) {
+			continue
+		}
+		err = db.Model(&Result{}).Where("campaign_id=?", c.Id).Count(&e.Recipients).Error
+		if err != nil {
+			return cal, err
+		}
+		if all {
+			if _, ok := owners[c.UserId]; !ok {
+				u, err := GetUser(c.UserId)
+				if err != nil {
+					return cal, err
+				}
+				owners[c.UserId] = u.Username
+			}
+			e.Owner = owners[c.UserId]
+		}
+		cal.Campaigns = append(cal.Campaigns, e)
+	}
+	for i, a := range cal.Campaigns {
+		for _, b := range cal.Campaigns[i+1:] {
+			gap := calendarGap(a, b)
+			if gap > window {
+				continue
+			}
+			shared, err := sharedRecipients(a.Id, b.Id)
+			if err != nil {
+				return cal, err
+			}
+			if shared == 0 {
+				continue
+			}
+			cal.Conflicts = append(cal.Conflicts, CalendarConflict{
+				CampaignIds:      []int64{a.Id, b.Id},
+				Campaigns:        []string{a.Name, b.Name},
+				SharedRecipients: shared,
+				GapHours:         gap.Hours(),
+			})
+		}
+	}
+	return cal, nil
+}
+
+// newCalendarEntry returns the calendar entry for the campaign.
+func newCalendarEntry(c Campaign) CalendarEntry {
+	e := CalendarEntry{
+		Id:     c.Id,
+		Name:   c.Name,
+		Status: c.Status,
+		Start:  c.LaunchDate,
+		End:    c.LaunchDate,
+	}
+	if e.Start.IsZero() {
+		e.Start = c.CreatedDate
+		e.End = c.CreatedDate
+	}
+	switch {
+	case c.Status == CampaignComplete && !c.CompletedDate.IsZero():
+		e.End = c.CompletedDate
+	case c.SendByDate.After(e.End):
+		e.End = c.SendByDate
+	}
+	return e
+}
+
+// calendarGap returns how long passes between the end of one campaign and
+// the start of the other, or zero if they overlap.
+func calendarGap(a, b CalendarEntry) time.Duration {
+	if b.Start.After(a.End) {
+		return b.Start.Sub(a.End)
+	}
+	if a.Start.After(b.End) {
+		return a.Start.Sub(b.End)
+	}
+	return 0
+}
+
+// sharedRecipients returns the number of email addresses sent to by both
+// campaigns.
+func sharedRecipients(a, b int64) (int64, error) {
+	var count int64
+	err := db.Table("results").
+		Where("campaign_id=? and email in (?)", a, db.Table("results").Select("email").Where("campaign_id=?", b).QueryExpr()).
+		Count(&count).Error
+	return count, err
+}
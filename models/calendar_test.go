@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignCalendar(ch *check.C) {
+	base := s.createCampaignDependencies(ch)
+	now := time.Now().UTC()
+
+	first := base
+	first.Name = "First"
+	ch.Assert(PostCampaign(&first, first.UserId), check.Equals, nil)
+	second := base
+	second.Name = "Second"
+	second.LaunchDate = now.Add(72 * time.Hour)
+	ch.Assert(PostCampaign(&second, second.UserId), check.Equals, nil)
+	later := base
+	later.Name = "Later"
+	later.LaunchDate = now.Add(30 * 24 * time.Hour)
+	ch.Assert(PostCampaign(&later, later.UserId), check.Equals, nil)
+
+	cal, err := GetCampaignCalendar(1, false, now.Add(-time.Hour), now.Add(60*24*time.Hour), DefaultConflictWindowDays)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cal.Campaigns), check.Equals, 3)
+	ch.Assert(cal.Campaigns[0].Name, check.Equals, "First")
+	ch.Assert(cal.Campaigns[0].Recipients, check.Equals, int64(4))
+	ch.Assert(cal.Campaigns[0].Owner, check.Equals, "")
+
+	// Only the campaigns within a week of each other conflict
+	ch.Assert(len(cal.Conflicts), check.Equals, 1)
+	ch.Assert(cal.Conflicts[0].CampaignIds, check.DeepEquals, []int64{first.Id, second.Id})
+	ch.Assert(cal.Conflicts[0].SharedRecipients, check.Equals, int64(4))
+	ch.Assert(cal.Conflicts[0].GapHours > 71, check.Equals, true)
+
+	// A shorter window has no conflicts, and the calendar's range limits the
+	// campaigns shown
+	cal, err = GetCampaignCalendar(1, true, now.Add(-time.Hour), now.Add(7*24*time.Hour), 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cal.Campaigns), check.Equals, 2)
+	ch.Assert(cal.Campaigns[0].Owner, check.Equals, "admin")
+	ch.Assert(len(cal.Conflicts), check.Equals, 0)
+
+	// Other users' campaigns are only included when every campaign is
+	cal, err = GetCampaignCalendar(2, false, now.Add(-time.Hour), now.Add(60*24*time.Hour), DefaultConflictWindowDays)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cal.Campaigns), check.Equals, 0)
+
+	_, err = GetCampaignCalendar(1, false, now, now.Add(-time.Hour), DefaultConflictWindowDays)
+	ch.Assert(err, check.Equals, ErrInvalidCalendarRange)
+	_, err = GetCampaignCalendar(1, false, now, now.Add(time.Hour), -1)
+	ch.Assert(err, check.Equals, ErrInvalidConflictWindow)
+}
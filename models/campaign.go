@@ -1,12 +1,14 @@
 package models
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"net/url"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
-	"github.com/gophish/gophish/webhook"
+	"github.com/gophish/gophish/tracing"
 	"github.com/jinzhu/gorm"
 	"github.com/sirupsen/logrus"
 )
@@ -20,6 +22,7 @@ type Campaign struct {
 	LaunchDate    time.Time `json:"launch_date"`
 	SendByDate    time.Time `json:"send_by_date"`
 	CompletedDate time.Time `json:"completed_date"`
+	ArchivedDate  time.Time `json:"archived_date"`
 	TemplateId    int64     `json:"-"`
 	Template      Template  `json:"template"`
 	PageId        int64     `json:"-"`
@@ -31,15 +34,100 @@ type Campaign struct {
 	SMTPId        int64     `json:"-"`
 	SMTP          SMTP      `json:"smtp"`
 	URL           string    `json:"url"`
+	Anonymize     bool      `json:"anonymize"`
+	// AutoCompleteDays completes the campaign this many days after it's
+	// launched.
+	AutoCompleteDays int `json:"auto_complete_days"`
+	// AutoCompleteQuietHours completes the campaign once every email has
+	// been sent and there haven't been any events for this many hours.
+	AutoCompleteQuietHours int `json:"auto_complete_quiet_hours"`
+	// AutoCompleteClickPercent completes the campaign once at least this
+	// percentage of recipients have clicked the link.
+	AutoCompleteClickPercent int `json:"auto_complete_click_percent"`
+	// Modifiers are the message modifiers applied to the campaign's emails.
+	Modifiers []CampaignModifier `json:"modifiers" gorm:"-"`
+	// MaxDuplicateEvents caps the number of click and submission events
+	// stored for each recipient. Later events are only counted. A value of
+	// zero stores every event.
+	MaxDuplicateEvents int `json:"max_duplicate_events"`
+	// NotFoundPageId is the landing page served in place of a 404 response
+	// for the campaign, such as for blocked requests or once it's completed.
+	NotFoundPageId int64 `json:"not_found_page_id"`
+	// Priority determines the order in which the worker sends queued emails,
+	// so that urgent campaigns aren't held up by large campaigns.
+	Priority int `json:"priority"`
+	// ShortURL is the base URL of the campaign's short links, such as
+	// https://go.example.com. If it's set, {{.URL}} is the recipient's short
+	// link, which redirects to their full link.
+	ShortURL string `json:"short_url"`
+	// Redirects are templates of trusted open redirects which the
+	// recipient's link is wrapped in, innermost first. Each is given the
+	// link to wrap as {{.URL}}.
+	Redirects []string `json:"redirects" gorm:"-"`
+	// RawRedirects is the JSON encoding of the redirects, as stored.
+	RawRedirects string `json:"-" gorm:"column:redirects"`
+	// SourceCampaignId is the campaign this campaign was cloned from. Clones
+	// of clones keep the original campaign's id, so that each wave of a
+	// campaign can be found together.
+	SourceCampaignId int64 `json:"source_campaign_id"`
+	// Simulate saves the campaign's emails instead of sending them. Emails
+	// are otherwise built, throttled and recorded as if they were sent.
+	Simulate bool `json:"simulate"`
+	// IncludeTags and ExcludeTags are comma separated lists of target tags.
+	// If IncludeTags is set, only targets with one of the tags are sent the
+	// campaign, and targets with any of the ExcludeTags are skipped.
+	IncludeTags string `json:"include_tags"`
+	ExcludeTags string `json:"exclude_tags"`
+	// ApprovedBy is the user who approved the campaign, if it was sent to
+	// targets with one of the configured approval tags.
+	ApprovedBy int64 `json:"approved_by,omitempty"`
+	// SamplePercent sends the campaign to a random sample of this percentage
+	// of the targets in its groups. SampleSeed seeds the sample, so that it
+	// can be reproduced, and SamplePopulation records how many targets it was
+	// taken from.
+	SamplePercent    int   `json:"sample_percent"`
+	SampleSeed       int64 `json:"sample_seed"`
+	SamplePopulation int   `json:"sample_population"`
+	// ExcludeRecentDays skips targets who were sent, or are scheduled to be
+	// sent, any of the owner's campaigns in the last this many days when it's
+	// created. Administrators' campaigns skip targets of any user's campaigns.
+	ExcludeRecentDays int `json:"exclude_recent_days"`
+	// Waves split the campaign's emails into named waves, each with its own
+	// launch date and template.
+	Waves []CampaignWave `json:"waves,omitempty" gorm:"-"`
+	// Sequence are the reminders or variants sent to recipients who haven't
+	// opened or clicked the campaign's email, in order.
+	Sequence []SequenceStep `json:"sequence,omitempty" gorm:"-"`
+	// AllowlistToken is the secret sent in the AllowlistHeader of the
+	// campaign's emails, which mail gateways match to allowlist them.
+	AllowlistToken EncryptedString `json:"-"`
 }
 
 // CampaignResults is a struct representing the results from a campaign
 type CampaignResults struct {
-	Id      int64    `json:"id"`
-	Name    string   `json:"name"`
-	Status  string   `json:"status"`
-	Results []Result `json:"results,omitempty"`
-	Events  []Event  `json:"timeline,omitempty"`
+	Id           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	ArchivedDate time.Time `json:"-"`
+	Results      []Result  `json:"results,omitempty"`
+	Events       []Event   `json:"timeline,omitempty"`
+	// MailClients counts the recipients of the campaign using each mail
+	// client identified from their opens and clicks.
+	MailClients map[string]int64 `json:"mail_clients,omitempty" gorm:"-"`
+}
+
+// CampaignResultsDelta contains the results and events for a campaign which
+// changed after a given time or event. The LastEventId and Timestamp should
+// be provided when requesting the next delta.
+type CampaignResultsDelta struct {
+	Id           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	ArchivedDate time.Time `json:"-"`
+	Results      []Result  `json:"results"`
+	Events       []Event   `json:"timeline"`
+	LastEventId  int64     `json:"last_event_id"`
+	Timestamp    time.Time `json:"timestamp"`
 }
 
 // CampaignSummaries is a struct representing the overview of campaigns
@@ -58,6 +146,8 @@ type CampaignSummary struct {
 	Status        string        `json:"status"`
 	Name          string        `json:"name"`
 	Stats         CampaignStats `json:"stats"`
+	// SourceCampaignId is the campaign this campaign was cloned from.
+	SourceCampaignId int64 `json:"source_campaign_id"`
 }
 
 // CampaignStats is a struct representing the statistics for a single campaign
@@ -68,18 +158,74 @@ type CampaignStats struct {
 	ClickedLink   int64 `json:"clicked"`
 	SubmittedData int64 `json:"submitted_data"`
 	EmailReported int64 `json:"email_reported"`
+	Forwarded     int64 `json:"forwarded"`
+	AutoReplied   int64 `json:"auto_replied"`
+	Unsubscribed  int64 `json:"unsubscribed"`
 	Error         int64 `json:"error"`
+	// MachineOpened counts the recipients whose only opens were made by mail
+	// privacy proxies, and who haven't done anything since. HumanOpened is
+	// the estimate of the recipients who really opened the email, which
+	// leaves them out.
+	MachineOpened int64 `json:"machine_opened"`
+	HumanOpened   int64 `json:"human_opened"`
 }
 
 // Event contains the fields for an event
 // that occurs during the campaign
 type Event struct {
-	Id         int64     `json:"-"`
-	CampaignId int64     `json:"campaign_id"`
-	Email      string    `json:"email"`
-	Time       time.Time `json:"time"`
-	Message    string    `json:"message"`
-	Details    string    `json:"details"`
+	Id         int64           `json:"-"`
+	CampaignId int64           `json:"campaign_id"`
+	Email      string          `json:"email"`
+	Time       time.Time       `json:"time"`
+	Message    string          `json:"message"`
+	Details    EncryptedString `json:"details"`
+	// Tags are added by event processors, as a comma separated list.
+	Tags string `json:"tags,omitempty"`
+	// RequestId is the ID of the request which caused the event, which is
+	// sent along with its webhooks.
+	RequestId string `json:"-" gorm:"-"`
+}
+
+// eventV1 is the format of events in version 1 of the schema.
+type eventV1 struct {
+	CampaignId int64           `json:"campaign_id"`
+	Email      string          `json:"email"`
+	Time       time.Time       `json:"time"`
+	Message    string          `json:"message"`
+	Details    EncryptedString `json:"details"`
+}
+
+// ForSchema returns the event in the format of the given schema version.
+// Events added in later versions are nil in earlier ones.
+func (e Event) ForSchema(version int) interface{} {
+	switch e.Message {
+	case EventForwarded, EventAutoReplied, EventUnsubscribed:
+		if version < 3 {
+			return nil
+		}
+	case EventBlocked:
+		if version < 4 {
+			return nil
+		}
+	case EventLinkFetched:
+		if version < 5 {
+			return nil
+		}
+	case EventShortLink:
+		if version < 6 {
+			return nil
+		}
+	}
+	if version < 2 {
+		return eventV1{
+			CampaignId: e.CampaignId,
+			Email:      e.Email,
+			Time:       e.Time,
+			Message:    e.Message,
+			Details:    e.Details,
+		}
+	}
+	return e
 }
 
 // EventDetails is a struct that wraps common attributes we want to store
@@ -87,12 +233,22 @@ type Event struct {
 type EventDetails struct {
 	Payload url.Values        `json:"payload"`
 	Browser map[string]string `json:"browser"`
+	Request *EventRequest     `json:"-"`
+	// RequestId is the ID of the request the event was recorded for.
+	RequestId string `json:"-"`
+	// PasswordChecks replace the submitted passwords for pages which score
+	// passwords instead of capturing them.
+	PasswordChecks []PasswordCheck `json:"password_checks,omitempty"`
+	// passwords are the scored passwords, which are only used to check for
+	// honeytokens and are never stored.
+	passwords url.Values
 }
 
 // EventError is a struct that wraps an error that occurs when sending an
 // email to a recipient
 type EventError struct {
-	Error string `json:"error"`
+	Error      string `json:"error"`
+	Transcript string `json:"transcript,omitempty"`
 }
 
 // ErrCampaignNameNotSpecified indicates there was no template given by the user
@@ -126,6 +282,18 @@ var ErrSMTPNotFound = errors.New("Sending profile not found")
 // launch date
 var ErrInvalidSendByDate = errors.New("The launch date must be before the \"send emails by\" date")
 
+// ErrInvalidAutoComplete indicates that the automatic completion rules of a
+// campaign are out of range
+var ErrInvalidAutoComplete = errors.New("The automatic completion rules can't be negative, and the click percentage can't be more than 100")
+
+// ErrInvalidMaxDuplicateEvents indicates that the cap on duplicate events is
+// negative
+var ErrInvalidMaxDuplicateEvents = errors.New("The maximum number of duplicate events can't be negative")
+
+// ErrEventSuppressed indicates that an event processor suppressed the event,
+// so it wasn't stored
+var ErrEventSuppressed = errors.New("Event suppressed by an event processor")
+
 // RecipientParameter is the URL parameter that points to the result ID for a recipient.
 const RecipientParameter = "rid"
 
@@ -144,8 +312,36 @@ func (c *Campaign) Validate() error {
 		return ErrSMTPNotSpecified
 	case !c.SendByDate.IsZero() && !c.LaunchDate.IsZero() && c.SendByDate.Before(c.LaunchDate):
 		return ErrInvalidSendByDate
+	case c.AutoCompleteDays < 0 || c.AutoCompleteQuietHours < 0 || c.AutoCompleteClickPercent < 0 || c.AutoCompleteClickPercent > 100:
+		return ErrInvalidAutoComplete
+	case c.MaxDuplicateEvents < 0:
+		return ErrInvalidMaxDuplicateEvents
+	case !validPriority(c.Priority):
+		return ErrInvalidPriority
+	case !validShortURL(c.ShortURL):
+		return ErrInvalidShortURL
+	case !validSamplePercent(c.SamplePercent):
+		return ErrInvalidSamplePercent
+	case c.ExcludeRecentDays < 0:
+		return ErrInvalidExcludeRecentDays
+	case c.Anonymize && anonymizeKey == nil:
+		return ErrNoAnonymizeKey
 	}
-	return nil
+	c.IncludeTags = normalizeTags(c.IncludeTags)
+	c.ExcludeTags = normalizeTags(c.ExcludeTags)
+	err := c.validateModifiers()
+	if err != nil {
+		return err
+	}
+	err = c.validateWaves()
+	if err != nil {
+		return err
+	}
+	err = c.validateSequence()
+	if err != nil {
+		return err
+	}
+	return c.validateRedirects()
 }
 
 // UpdateStatus changes the campaign status appropriately
@@ -154,26 +350,24 @@ func (c *Campaign) UpdateStatus(s string) error {
 	return db.Table("campaigns").Where("id=?", c.Id).Update("status", s).Error
 }
 
-// AddEvent creates a new campaign event in the database
+// AddEvent creates a new campaign event in the database. The event is first
+// passed to the registered event processors, which may suppress it, in which
+// case ErrEventSuppressed is returned.
 func AddEvent(e *Event, campaignID int64) error {
 	e.CampaignId = campaignID
 	e.Time = time.Now().UTC()
 
-	whs, err := GetActiveWebhooks()
-	if err == nil {
-		whEndPoints := []webhook.EndPoint{}
-		for _, wh := range whs {
-			whEndPoints = append(whEndPoints, webhook.EndPoint{
-				URL:    wh.URL,
-				Secret: wh.Secret,
-			})
-		}
-		webhook.SendAll(whEndPoints, e)
-	} else {
-		log.Errorf("error getting active webhooks: %v", err)
+	if !processEvent(e) {
+		return ErrEventSuppressed
 	}
+	sendWebhooks(e)
 
-	return db.Save(e).Error
+	err := db.Save(e).Error
+	if err != nil {
+		return err
+	}
+	publishEvent(*e)
+	return nil
 }
 
 // getDetails retrieves the related attributes of the campaign
@@ -186,7 +380,7 @@ func (c *Campaign) getDetails() error {
 		log.Warnf("%s: results not found for campaign", err)
 		return err
 	}
-	err = db.Model(c).Related(&c.Events).Error
+	err = db.Table(eventsTable(c.ArchivedDate)).Where("campaign_id=?", c.Id).Find(&c.Events).Error
 	if err != nil {
 		log.Warnf("%s: events not found for campaign", err)
 		return err
@@ -199,6 +393,8 @@ func (c *Campaign) getDetails() error {
 		c.Template = Template{Name: "[Deleted]"}
 		log.Warnf("%s: template not found for campaign", err)
 	}
+	// Attachment content kept in object storage isn't loaded, since campaign
+	// details are polled by the dashboard.
 	err = db.Where("template_id=?", c.Template.Id).Find(&c.Template.Attachments).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		log.Warn(err)
@@ -226,7 +422,15 @@ func (c *Campaign) getDetails() error {
 		log.Warn(err)
 		return err
 	}
-	return nil
+	err = c.getWaves()
+	if err != nil {
+		return err
+	}
+	err = c.getSequence()
+	if err != nil {
+		return err
+	}
+	return c.getModifiers()
 }
 
 // getBaseURL returns the Campaign's configured URL.
@@ -243,13 +447,19 @@ func (c *Campaign) getFromAddress() string {
 
 // generateSendDate creates a sendDate
 func (c *Campaign) generateSendDate(idx int, totalRecipients int) time.Time {
+	return spreadSendDate(c.LaunchDate, c.SendByDate, idx, totalRecipients)
+}
+
+// spreadSendDate returns the send date of the email at the given index when
+// the emails are spread evenly between the start and end of a send window.
+func spreadSendDate(start time.Time, end time.Time, idx int, totalRecipients int) time.Time {
 	// If no send date is specified, just return the launch date
-	if c.SendByDate.IsZero() || c.SendByDate.Equal(c.LaunchDate) {
-		return c.LaunchDate
+	if end.IsZero() || end.Equal(start) {
+		return start
 	}
 	// Otherwise, we can calculate the range of minutes to send emails
 	// (since we only poll once per minute)
-	totalMinutes := c.SendByDate.Sub(c.LaunchDate).Minutes()
+	totalMinutes := end.Sub(start).Minutes()
 
 	// Next, we can determine how many minutes should elapse between emails
 	minutesPerEmail := totalMinutes / float64(totalRecipients)
@@ -259,15 +469,21 @@ func (c *Campaign) generateSendDate(idx int, totalRecipients int) time.Time {
 
 	// Finally, we can just add this offset to the launch date to determine
 	// when the email should be sent
-	return c.LaunchDate.Add(time.Duration(offset) * time.Minute)
+	return start.Add(time.Duration(offset) * time.Minute)
 }
 
 // getCampaignStats returns a CampaignStats object for the campaign with the given campaign ID.
 // It also backfills numbers as appropriate with a running total, so that the values are aggregated.
 func getCampaignStats(cid int64) (CampaignStats, error) {
+	return getResultStats(reportingDB().Table("results").Where("campaign_id = ?", cid))
+}
+
+// getResultStats returns a CampaignStats object for the results matched by
+// the query, backfilled in the same way as getCampaignStats.
+func getResultStats(query *gorm.DB) (CampaignStats, error) {
 	s := CampaignStats{}
-	query := db.Table("results").Where("campaign_id = ?", cid)
-	err := query.Count(&s.Total).Error
+	// Reminders sent by a follow-up sequence aren't new recipients
+	err := query.Where("sequence_step = ?", 0).Count(&s.Total).Error
 	if err != nil {
 		return s, err
 	}
@@ -283,6 +499,18 @@ func getCampaignStats(cid int64) (CampaignStats, error) {
 	if err != nil {
 		return s, err
 	}
+	err = query.Where("forwarded=?", true).Count(&s.Forwarded).Error
+	if err != nil {
+		return s, err
+	}
+	err = query.Where("auto_replied=?", true).Count(&s.AutoReplied).Error
+	if err != nil {
+		return s, err
+	}
+	err = query.Where("unsubscribed=?", true).Count(&s.Unsubscribed).Error
+	if err != nil {
+		return s, err
+	}
 	// Every submitted data event implies they clicked the link
 	s.ClickedLink += s.SubmittedData
 	err = query.Where("status=?", EventOpened).Count(&s.OpenedEmail).Error
@@ -298,13 +526,41 @@ func getCampaignStats(cid int64) (CampaignStats, error) {
 	// Every opened email event implies the email was sent
 	s.EmailsSent += s.OpenedEmail
 	err = query.Where("status=?", Error).Count(&s.Error).Error
+	if err != nil {
+		return s, err
+	}
+	err = query.Where("status=? AND machine_opened=? AND human_opened=?", EventOpened, true, false).Count(&s.MachineOpened).Error
+	s.HumanOpened = s.OpenedEmail - s.MachineOpened
 	return s, err
 }
 
 // GetCampaigns returns the campaigns owned by the given user.
 func GetCampaigns(uid int64) ([]Campaign, error) {
+	cs, _, err := ListCampaigns(uid, ListOptions{})
+	return cs, err
+}
+
+// campaignListFields are the fields campaigns can be sorted and filtered by
+var campaignListFields = listFields{
+	"id":             "id",
+	"name":           "name",
+	"status":         "status",
+	"created_date":   "created_date",
+	"launch_date":    "launch_date",
+	"completed_date": "completed_date",
+	// Filtering by the source campaign finds each wave of a campaign
+	"source_campaign_id": "source_campaign_id",
+}
+
+// ListCampaigns returns the campaigns owned by the given user, filtered,
+// sorted, and paginated using the given options.
+func ListCampaigns(uid int64, opts ListOptions) ([]Campaign, PageInfo, error) {
 	cs := []Campaign{}
-	err := db.Model(&User{Id: uid}).Related(&cs).Error
+	query, total, err := opts.apply(db.Table("campaigns").Where("user_id=?", uid), campaignListFields)
+	if err != nil {
+		return cs, PageInfo{}, err
+	}
+	err = query.Find(&cs).Error
 	if err != nil {
 		log.Error(err)
 	}
@@ -314,40 +570,51 @@ func GetCampaigns(uid int64) ([]Campaign, error) {
 			log.Error(err)
 		}
 	}
-	return cs, err
+	return cs, opts.pageInfo(&cs, total, campaignListFields), err
 }
 
 // GetCampaignSummaries gets the summary objects for all the campaigns
 // owned by the current user
 func GetCampaignSummaries(uid int64) (CampaignSummaries, error) {
+	overview, _, err := ListCampaignSummaries(uid, ListOptions{})
+	return overview, err
+}
+
+// ListCampaignSummaries gets the summary objects for the campaigns owned by
+// the current user, filtered, sorted, and paginated using the given options.
+// The total is the number of campaigns matching the filters.
+func ListCampaignSummaries(uid int64, opts ListOptions) (CampaignSummaries, PageInfo, error) {
 	overview := CampaignSummaries{}
 	cs := []CampaignSummary{}
 	// Get the basic campaign information
-	query := db.Table("campaigns").Where("user_id = ?", uid)
-	query = query.Select("id, name, created_date, launch_date, send_by_date, completed_date, status")
-	err := query.Scan(&cs).Error
+	query, total, err := opts.apply(reportingDB().Table("campaigns").Where("user_id = ?", uid), campaignListFields)
+	if err != nil {
+		return overview, PageInfo{}, err
+	}
+	query = query.Select("id, name, created_date, launch_date, send_by_date, completed_date, status, source_campaign_id")
+	err = query.Scan(&cs).Error
 	if err != nil {
 		log.Error(err)
-		return overview, err
+		return overview, PageInfo{}, err
 	}
 	for i := range cs {
 		s, err := getCampaignStats(cs[i].Id)
 		if err != nil {
 			log.Error(err)
-			return overview, err
+			return overview, PageInfo{}, err
 		}
 		cs[i].Stats = s
 	}
-	overview.Total = int64(len(cs))
+	overview.Total = total
 	overview.Campaigns = cs
-	return overview, nil
+	return overview, opts.pageInfo(&cs, total, campaignListFields), nil
 }
 
 // GetCampaignSummary gets the summary object for a campaign specified by the campaign ID
 func GetCampaignSummary(id int64, uid int64) (CampaignSummary, error) {
 	cs := CampaignSummary{}
-	query := db.Table("campaigns").Where("user_id = ? AND id = ?", uid, id)
-	query = query.Select("id, name, created_date, launch_date, send_by_date, completed_date, status")
+	query := reportingDB().Table("campaigns").Where("user_id = ? AND id = ?", uid, id)
+	query = query.Select("id, name, created_date, launch_date, send_by_date, completed_date, status, source_campaign_id")
 	err := query.Scan(&cs).Error
 	if err != nil {
 		log.Error(err)
@@ -383,15 +650,28 @@ func GetCampaignMailContext(id int64, uid int64) (Campaign, error) {
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return c, err
 	}
-	err = db.Table("templates").Where("id=?", c.TemplateId).Find(&c.Template).Error
+	c.Template, err = getMailTemplate(c.TemplateId)
 	if err != nil {
 		return c, err
 	}
-	err = db.Where("template_id=?", c.Template.Id).Find(&c.Template.Attachments).Error
+	err = c.getModifiers()
+	return c, err
+}
+
+// getMailTemplate returns the template with the given id, along with the
+// attachments and translations needed to send it.
+func getMailTemplate(id int64) (Template, error) {
+	t := Template{}
+	err := db.Table("templates").Where("id=?", id).Find(&t).Error
+	if err != nil {
+		return t, err
+	}
+	err = db.Where("template_id=?", t.Id).Find(&t.Attachments).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
-		return c, err
+		return t, err
 	}
-	return c, nil
+	err = t.getTranslations()
+	return t, err
 }
 
 // GetCampaign returns the campaign, if it exists, specified by the given id and user_id.
@@ -408,26 +688,231 @@ func GetCampaign(id int64, uid int64) (Campaign, error) {
 
 // GetCampaignResults returns just the campaign results for the given campaign
 func GetCampaignResults(id int64, uid int64) (CampaignResults, error) {
+	cr, _, err := ListCampaignResults(id, uid, ListOptions{})
+	return cr, err
+}
+
+// resultListFields are the fields campaign results can be sorted and
+// filtered by. Results can also be filtered by a single tag.
+var resultListFields = listFields{
+	"id":             "id",
+	"campaign_id":    "campaign_id",
+	"email":          "email",
+	"first_name":     "first_name",
+	"last_name":      "last_name",
+	"position":       "position",
+	"language":       "language",
+	"status":         "status",
+	"send_date":      "send_date",
+	"modified_date":  "modified_date",
+	"reported":       "reported",
+	"forwarded":      "forwarded",
+	"auto_replied":   "auto_replied",
+	"unsubscribed":   "unsubscribed",
+	"mail_client":    "mail_client",
+	"machine_opened": "machine_opened",
+	"click_count":    "click_count",
+	"submit_count":   "submit_count",
+	"human_opened":   "human_opened",
+}
+
+// ListCampaignResults returns the campaign results for the given campaign,
+// filtered, sorted, and paginated using the given options. When the results
+// are paginated, only the events for the results in the page are returned,
+// along with the events for the campaign itself.
+func ListCampaignResults(id int64, uid int64, opts ListOptions) (CampaignResults, PageInfo, error) {
 	cr := CampaignResults{}
-	err := db.Table("campaigns").Where("id=? and user_id=?", id, uid).Find(&cr).Error
+	err := reportingDB().Table("campaigns").Where("id=? and user_id=?", id, uid).Find(&cr).Error
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"campaign_id": id,
 			"error":       err,
 		}).Error(err)
-		return cr, err
+		return cr, PageInfo{}, err
 	}
-	err = db.Table("results").Where("campaign_id=? and user_id=?", cr.Id, uid).Find(&cr.Results).Error
+	filtered := len(opts.Filters) > 0
+	query := reportingDB().Table("results").Where("campaign_id=? and user_id=?", cr.Id, uid)
+	query, opts = filterTag(query, opts)
+	query, total, err := opts.apply(query, resultListFields)
+	if err != nil {
+		return cr, PageInfo{}, err
+	}
+	err = query.Find(&cr.Results).Error
 	if err != nil {
 		log.Errorf("%s: results not found for campaign", err)
-		return cr, err
+		return cr, PageInfo{}, err
+	}
+	query = reportingDB().Table(eventsTable(cr.ArchivedDate)).Where("campaign_id=?", cr.Id)
+	if opts.paginated() || filtered {
+		emails := make([]string, len(cr.Results))
+		for i, r := range cr.Results {
+			emails[i] = r.Email
+		}
+		query = query.Where("email IN (?) OR email = ?", emails, "")
 	}
-	err = db.Table("events").Where("campaign_id=?", cr.Id).Find(&cr.Events).Error
+	err = query.Find(&cr.Events).Error
 	if err != nil {
 		log.Errorf("%s: events not found for campaign", err)
-		return cr, err
+		return cr, PageInfo{}, err
 	}
-	return cr, err
+	cr.MailClients, err = getMailClientStats(cr.Id)
+	if err != nil {
+		return cr, PageInfo{}, err
+	}
+	return cr, opts.pageInfo(&cr.Results, total, resultListFields), err
+}
+
+// EachCampaignResult calls the function with each of the campaign's results
+// matching the options' filters, in the options' sort order. Results are read
+// from the database one at a time, so that large campaigns can be exported
+// without loading every result at once. The options' limit and cursor are
+// ignored.
+func EachCampaignResult(id int64, uid int64, opts ListOptions, fn func(Result) error) error {
+	c := Campaign{}
+	err := db.Select("id").Where("id=? and user_id=?", id, uid).First(&c).Error
+	if err != nil {
+		return err
+	}
+	opts.Limit = 0
+	opts.Cursor = ""
+	if opts.Sort == "" {
+		opts.Sort = "id"
+	}
+	query := db.Table("results").Where("campaign_id=? and user_id=?", c.Id, uid)
+	query, opts = filterTag(query, opts)
+	query, _, err = opts.apply(query, resultListFields)
+	if err != nil {
+		return err
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r := Result{}
+		err = db.ScanRows(rows, &r)
+		if err != nil {
+			return err
+		}
+		err = fn(r)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// eventListFields are the fields events can be sorted and filtered by
+var eventListFields = listFields{
+	"id":          "id",
+	"campaign_id": "campaign_id",
+	"email":       "email",
+	"message":     "message",
+	"time":        "time",
+}
+
+// ListEvents returns the events for the campaigns owned by the given user,
+// filtered, sorted, and paginated using the given options.
+func ListEvents(uid int64, opts ListOptions) ([]Event, PageInfo, error) {
+	es := []Event{}
+	query := db.Table(allEventsTable(uid))
+	query, total, err := opts.apply(query, eventListFields)
+	if err != nil {
+		return es, PageInfo{}, err
+	}
+	err = query.Find(&es).Error
+	if err != nil {
+		log.Error(err)
+		return es, PageInfo{}, err
+	}
+	return es, opts.pageInfo(&es, total, eventListFields), nil
+}
+
+// ListResults returns the results for the campaigns owned by the given user,
+// filtered, sorted, and paginated using the given options.
+func ListResults(uid int64, opts ListOptions) ([]Result, PageInfo, error) {
+	rs := []Result{}
+	query, opts := filterTag(db.Table("results").Where("user_id=?", uid), opts)
+	query, total, err := opts.apply(query, resultListFields)
+	if err != nil {
+		return rs, PageInfo{}, err
+	}
+	err = query.Find(&rs).Error
+	if err != nil {
+		log.Error(err)
+		return rs, PageInfo{}, err
+	}
+	return rs, opts.pageInfo(&rs, total, resultListFields), nil
+}
+
+// GetCampaignResultsDelta returns the results and events for the given
+// campaign which changed since the given event id or, if no event id is
+// provided, since the given time. If neither is provided, every result and
+// event is returned.
+//
+// A result is considered changed if it was modified, or if it has a new event
+// which didn't change its status.
+func GetCampaignResultsDelta(id int64, uid int64, since time.Time, sinceEventId int64) (CampaignResultsDelta, error) {
+	cd := CampaignResultsDelta{
+		Results:     []Result{},
+		Events:      []Event{},
+		LastEventId: sinceEventId,
+		Timestamp:   time.Now().UTC(),
+	}
+	err := reportingDB().Table("campaigns").Where("id=? and user_id=?", id, uid).Find(&cd).Error
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"campaign_id": id,
+			"error":       err,
+		}).Error(err)
+		return cd, err
+	}
+	// Events are compared by id when possible, since multiple events can
+	// occur at the same time.
+	table := eventsTable(cd.ArchivedDate)
+	query := reportingDB().Table(table).Where("campaign_id=?", cd.Id)
+	if sinceEventId > 0 {
+		last := Event{}
+		err = reportingDB().Table(table).Where("id=? and campaign_id=?", sinceEventId, cd.Id).Find(&last).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			log.Error(err)
+			return cd, err
+		}
+		since = last.Time
+		query = query.Where("id > ?", sinceEventId)
+	} else if !since.IsZero() {
+		query = query.Where("time > ?", since)
+	}
+	err = query.Order("id asc").Find(&cd.Events).Error
+	if err != nil {
+		log.Error(err)
+		return cd, err
+	}
+	emails := []string{}
+	for _, e := range cd.Events {
+		emails = append(emails, e.Email)
+		cd.LastEventId = e.Id
+	}
+	if cd.LastEventId == 0 {
+		var lastId sql.NullInt64
+		err = reportingDB().Table(table).Where("campaign_id=?", cd.Id).Select("max(id)").Row().Scan(&lastId)
+		if err != nil {
+			log.Error(err)
+			return cd, err
+		}
+		cd.LastEventId = lastId.Int64
+	}
+	query = reportingDB().Table("results").Where("campaign_id=? and user_id=?", cd.Id, uid)
+	if !since.IsZero() {
+		query = query.Where("modified_date > ? OR email IN (?)", since, emails)
+	}
+	err = query.Find(&cd.Results).Error
+	if err != nil {
+		log.Error(err)
+		return cd, err
+	}
+	return cd, nil
 }
 
 // GetQueuedCampaigns returns the campaigns that are queued up for this given minute
@@ -450,7 +935,22 @@ func GetQueuedCampaigns(t time.Time) ([]Campaign, error) {
 
 // PostCampaign inserts a campaign and all associated records into the database.
 func PostCampaign(c *Campaign, uid int64) error {
-	err := c.Validate()
+	return PostCampaignContext(context.Background(), c, uid)
+}
+
+// PostCampaignContext inserts a campaign like PostCampaign, tracing its
+// creation as a child of the span held by the context.
+func PostCampaignContext(ctx context.Context, c *Campaign, uid int64) (err error) {
+	ctx, span := tracing.Start(ctx, "campaign.create")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	err = c.applyGroupDefaults(uid)
+	if err != nil {
+		return err
+	}
+	err = c.Validate()
 	if err != nil {
 		return err
 	}
@@ -470,6 +970,10 @@ func PostCampaign(c *Campaign, uid int64) error {
 	if c.LaunchDate.Before(c.CreatedDate) || c.LaunchDate.Equal(c.CreatedDate) {
 		c.Status = CampaignInProgress
 	}
+	c.AllowlistToken, err = newAllowlistToken()
+	if err != nil {
+		return err
+	}
 	// Check to make sure all the groups already exist
 	// Also, later we'll need to know the total number of recipients (counting
 	// duplicates is ok for now), so we'll do that here to save a loop.
@@ -485,7 +989,29 @@ func PostCampaign(c *Campaign, uid int64) error {
 			log.Error(err)
 			return err
 		}
-		totalRecipients += len(c.Groups[i].Targets)
+		c.Groups[i].applyDefaultLanguage()
+		if suppressed := c.Groups[i].removeSuppressedTargets(); suppressed > 0 {
+			log.WithFields(logrus.Fields{
+				"group":      g.Name,
+				"suppressed": suppressed,
+			}).Info("Skipping targets who are on leave or have exited")
+		}
+		c.filterTargets(&c.Groups[i])
+	}
+	excluded, err := c.excludeRecentRecipients()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if excluded > 0 {
+		log.WithFields(logrus.Fields{
+			"excluded": excluded,
+			"days":     c.ExcludeRecentDays,
+		}).Info("Skipping targets who were sent a recent campaign")
+	}
+	c.sampleTargets()
+	for _, g := range c.Groups {
+		totalRecipients += len(g.Targets)
 	}
 	// Check to make sure the template exists
 	t, err := GetTemplateByName(c.Template.Name, uid)
@@ -500,6 +1026,23 @@ func PostCampaign(c *Campaign, uid int64) error {
 	}
 	c.Template = t
 	c.TemplateId = t.Id
+	err = c.resolveWaves(uid)
+	if err != nil {
+		return err
+	}
+	err = c.resolveSequence(uid)
+	if err != nil {
+		return err
+	}
+	// Campaigns sent to targets with an approval tag wait for another
+	// administrator to approve them
+	for _, g := range c.Groups {
+		for _, t := range g.Targets {
+			if t.requiresApproval() {
+				c.Status = CampaignPending
+			}
+		}
+	}
 	// Check to make sure the page exists
 	p, err := GetPageByName(c.Page.Name, uid)
 	if err == gorm.ErrRecordNotFound {
@@ -513,6 +1056,10 @@ func PostCampaign(c *Campaign, uid int64) error {
 	}
 	c.Page = p
 	c.PageId = p.Id
+	err = c.validateNotFoundPage(uid)
+	if err != nil {
+		return err
+	}
 	// Check to make sure the sending profile exists
 	s, err := GetSMTPByName(c.SMTP.Name, uid)
 	if err == gorm.ErrRecordNotFound {
@@ -532,30 +1079,48 @@ func PostCampaign(c *Campaign, uid int64) error {
 		log.Error(err)
 		return err
 	}
+	err = c.saveModifiers()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = c.saveWaves()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = c.saveSequence()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	err = AddEvent(&Event{Message: "Campaign Created"}, c.Id)
+	if err != nil && err != ErrEventSuppressed {
+		log.Error(err)
+	}
+	err = createHoneytoken(c)
 	if err != nil {
 		log.Error(err)
 	}
-	// Insert all the results
+	// Build the results, removing duplicates - we should only send emails
+	// to unique email addresses.
 	resultMap := make(map[string]bool)
-	recipientIndex := 0
-	tx := db.Begin()
+	results := []Result{}
+	processing := []bool{}
 	for _, g := range c.Groups {
-		// Insert a result for each target in the group
 		for _, t := range g.Targets {
-			// Remove duplicate results - we should only
-			// send emails to unique email addresses.
 			if _, ok := resultMap[t.Email]; ok {
 				continue
 			}
 			resultMap[t.Email] = true
-			sendDate := c.generateSendDate(recipientIndex, totalRecipients)
-			r := &Result{
+			sendDate := c.generateSendDate(len(results), totalRecipients)
+			r := Result{
 				BaseRecipient: BaseRecipient{
 					Email:     t.Email,
 					Position:  t.Position,
 					FirstName: t.FirstName,
 					LastName:  t.LastName,
+					Language:  t.Language,
 				},
 				Status:       StatusScheduled,
 				CampaignId:   c.Id,
@@ -563,50 +1128,57 @@ func PostCampaign(c *Campaign, uid int64) error {
 				SendDate:     sendDate,
 				Reported:     false,
 				ModifiedDate: c.CreatedDate,
+				TargetTags:   t.Tags,
 			}
-			err = r.GenerateId(tx)
-			if err != nil {
-				log.Error(err)
-				tx.Rollback()
-				return err
-			}
-			processing := false
-			if r.SendDate.Before(c.CreatedDate) || r.SendDate.Equal(c.CreatedDate) {
+			p := false
+			if c.Status != CampaignPending && !r.SendDate.After(c.CreatedDate) {
 				r.Status = StatusSending
-				processing = true
-			}
-			err = tx.Save(r).Error
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"email": t.Email,
-				}).Errorf("error creating result: %v", err)
-				tx.Rollback()
-				return err
+				p = true
 			}
-			c.Results = append(c.Results, *r)
+			results = append(results, r)
+			processing = append(processing, p)
+		}
+	}
+	if len(c.Waves) > 0 {
+		processing = c.assignWaves(results)
+	}
+	span.SetAttribute("campaign_id", c.Id)
+	span.SetAttribute("recipients", len(results))
+	// Insert the results and maillogs in batches, so that large campaigns
+	// don't hold a single transaction open while they're created.
+	setLaunchProgress(c.Id, len(results), 0)
+	defer clearLaunchProgress(c.Id)
+	for start := 0; start < len(results); start += LaunchBatchSize {
+		end := start + LaunchBatchSize
+		if end > len(results) {
+			end = len(results)
+		}
+		_, batchSpan := tracing.Start(ctx, "campaign.create_results")
+		batchSpan.SetAttribute("offset", start)
+		batchSpan.SetAttribute("count", end-start)
+		err = generateResultIds(results[start:end])
+		if err == nil {
+			err = insertResults(results[start:end], processing[start:end])
+		}
+		batchSpan.SetError(err)
+		batchSpan.End()
+		if err != nil {
 			log.WithFields(logrus.Fields{
-				"email":     r.Email,
-				"send_date": sendDate,
-			}).Debug("creating maillog")
-			m := &MailLog{
-				UserId:     c.UserId,
-				CampaignId: c.Id,
-				RId:        r.RId,
-				SendDate:   sendDate,
-				Processing: processing,
-			}
-			err = tx.Save(m).Error
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"email": t.Email,
-				}).Errorf("error creating maillog entry: %v", err)
-				tx.Rollback()
-				return err
-			}
-			recipientIndex++
+				"campaign_id": c.Id,
+			}).Errorf("error creating results: %v", err)
+			// Remove the partially created campaign
+			DeleteCampaign(c.Id)
+			return err
 		}
+		setLaunchProgress(c.Id, len(results), end)
+		log.WithFields(logrus.Fields{
+			"campaign_id": c.Id,
+			"created":     end,
+			"total":       len(results),
+		}).Debug("created results")
 	}
-	return tx.Commit().Error
+	c.Results = results
+	return nil
 }
 
 //DeleteCampaign deletes the specified campaign
@@ -625,11 +1197,46 @@ func DeleteCampaign(id int64) error {
 		log.Error(err)
 		return err
 	}
+	err = db.Exec("DELETE FROM archived_events WHERE campaign_id=?", id).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	err = db.Where("campaign_id=?", id).Delete(&MailLog{}).Error
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	err = db.Where("campaign_id=?", id).Delete(&EventRequest{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("campaign_id=?", id).Delete(&HoneytokenSighting{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("campaign_id=?", id).Delete(&Honeytoken{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("campaign_id=?", id).Delete(&CampaignModifier{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("campaign_id=?", id).Delete(&CampaignWave{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("campaign_id=?", id).Delete(&SequenceStep{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	// Delete the campaign
 	err = db.Delete(&Campaign{Id: id}).Error
 	if err != nil {
@@ -638,6 +1245,22 @@ func DeleteCampaign(id int64) error {
 	return err
 }
 
+// DeleteCampaigns deletes the campaigns owned by the given user which match
+// the filters, returning the number of campaigns deleted.
+func DeleteCampaigns(uid int64, filters map[string]string) (int, error) {
+	ids, err := ListOptions{Filters: filters}.matchingIds(db.Table("campaigns").Where("user_id=?", uid), campaignListFields)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		err = DeleteCampaign(id)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
 // CompleteCampaign effectively "ends" a campaign.
 // Any future emails clicked will return a simple "404" page.
 func CompleteCampaign(id int64, uid int64) error {
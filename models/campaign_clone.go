@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CloneRequest is a request to create a new campaign from an existing one.
+// The template, landing page, and settings of the campaign are copied, while
+// the fields given in the request replace the campaign's.
+type CloneRequest struct {
+	// Name defaults to the campaign's name followed by "(copy)".
+	Name string `json:"name"`
+	// Groups are the names of the groups the clone is sent to. Since
+	// campaigns don't keep the groups they were sent to, at least one group
+	// is needed.
+	Groups []string `json:"groups"`
+	// SMTP is the name of the sending profile, which defaults to the
+	// campaign's.
+	SMTP string `json:"smtp"`
+	// URL defaults to the campaign's URL.
+	URL string `json:"url"`
+	// LaunchDate defaults to launching immediately. If the campaign spread
+	// its emails until a send by date, the clone spreads its emails over
+	// the same length of time from its launch date, unless a send by date
+	// is given.
+	LaunchDate time.Time `json:"launch_date"`
+	SendByDate time.Time `json:"send_by_date"`
+}
+
+// CloneCampaign creates a new campaign from the one with the given id,
+// changing the fields given in the request. The clone is linked to the
+// campaign by its SourceCampaignId, so that waves of a campaign can be
+// reported on together.
+func CloneCampaign(ctx context.Context, id int64, uid int64, req CloneRequest) (Campaign, error) {
+	src, err := GetCampaignMailContext(id, uid)
+	if err != nil {
+		return Campaign{}, err
+	}
+	err = src.getWaves()
+	if err != nil {
+		return Campaign{}, err
+	}
+	err = src.getSequence()
+	if err != nil {
+		return Campaign{}, err
+	}
+	err = db.Table("pages").Where("id=?", src.PageId).Find(&src.Page).Error
+	if err == gorm.ErrRecordNotFound {
+		return Campaign{}, ErrPageNotFound
+	} else if err != nil {
+		return Campaign{}, err
+	}
+	c := Campaign{
+		Name:                     req.Name,
+		Template:                 Template{Name: src.Template.Name},
+		Page:                     Page{Name: src.Page.Name},
+		SMTP:                     SMTP{Name: src.SMTP.Name},
+		URL:                      src.URL,
+		Anonymize:                src.Anonymize,
+		AutoCompleteDays:         src.AutoCompleteDays,
+		AutoCompleteQuietHours:   src.AutoCompleteQuietHours,
+		AutoCompleteClickPercent: src.AutoCompleteClickPercent,
+		Modifiers:                src.Modifiers,
+		MaxDuplicateEvents:       src.MaxDuplicateEvents,
+		NotFoundPageId:           src.NotFoundPageId,
+		Priority:                 src.Priority,
+		ShortURL:                 src.ShortURL,
+		Redirects:                src.Redirects,
+		SourceCampaignId:         src.Id,
+		Simulate:                 src.Simulate,
+		IncludeTags:              src.IncludeTags,
+		ExcludeTags:              src.ExcludeTags,
+		SamplePercent:            src.SamplePercent,
+		ExcludeRecentDays:        src.ExcludeRecentDays,
+		LaunchDate:               req.LaunchDate,
+		SendByDate:               req.SendByDate,
+	}
+	// Clones of clones are linked to the original campaign
+	if src.SourceCampaignId != 0 {
+		c.SourceCampaignId = src.SourceCampaignId
+	}
+	if c.Name == "" {
+		c.Name = fmt.Sprintf("%s (copy)", src.Name)
+	}
+	for _, name := range req.Groups {
+		c.Groups = append(c.Groups, Group{Name: name})
+	}
+	if req.SMTP != "" {
+		c.SMTP.Name = req.SMTP
+	}
+	if req.URL != "" {
+		c.URL = req.URL
+	}
+	if c.SendByDate.IsZero() && !src.SendByDate.IsZero() {
+		launch := c.LaunchDate
+		if launch.IsZero() {
+			launch = time.Now().UTC()
+			c.LaunchDate = launch
+		}
+		c.SendByDate = launch.Add(src.SendByDate.Sub(src.LaunchDate))
+	}
+	// Waves launch at the same times relative to the clone's launch date
+	if len(src.Waves) > 0 {
+		if c.LaunchDate.IsZero() {
+			c.LaunchDate = time.Now().UTC()
+		}
+		cloneWaves(&src, &c)
+	}
+	cloneSequence(&src, &c)
+	err = PostCampaignContext(ctx, &c, uid)
+	return c, err
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCloneCampaign(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.URL = "https://phish.example.com"
+	c.LaunchDate = time.Now().UTC().Add(time.Hour)
+	c.SendByDate = c.LaunchDate.Add(2 * time.Hour)
+	c.AutoCompleteDays = 7
+	c.Priority = PriorityHigh
+	c.Modifiers = []CampaignModifier{{Name: "boundaries", Options: map[string]string{"prefix": "----=_Part_"}}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	other := Group{Name: "Second Wave", UserId: 1}
+	other.Targets = []Target{{BaseRecipient: BaseRecipient{Email: "wave@example.com"}}}
+	ch.Assert(PostGroup(&other), check.Equals, nil)
+	smtp := SMTP{Name: "Second Profile", UserId: 1, Host: "mail.example.com", FromAddress: "it@example.com"}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+
+	// The groups have to be given, since campaigns don't keep them
+	_, err := CloneCampaign(context.Background(), c.Id, c.UserId, CloneRequest{})
+	ch.Assert(err, check.Equals, ErrGroupNotSpecified)
+
+	launch := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	clone, err := CloneCampaign(context.Background(), c.Id, c.UserId, CloneRequest{
+		Groups:     []string{other.Name},
+		SMTP:       smtp.Name,
+		URL:        "https://wave.example.com",
+		LaunchDate: launch,
+	})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(clone.Id, check.Not(check.Equals), c.Id)
+	ch.Assert(clone.Name, check.Equals, "Test campaign (copy)")
+	ch.Assert(clone.SourceCampaignId, check.Equals, c.Id)
+	ch.Assert(clone.TemplateId, check.Equals, c.TemplateId)
+	ch.Assert(clone.PageId, check.Equals, c.PageId)
+	ch.Assert(clone.SMTPId, check.Equals, smtp.Id)
+	ch.Assert(clone.URL, check.Equals, "https://wave.example.com")
+	ch.Assert(clone.Status, check.Equals, CampaignQueued)
+	ch.Assert(clone.LaunchDate.Equal(launch), check.Equals, true)
+	ch.Assert(clone.SendByDate.Equal(launch.Add(2*time.Hour)), check.Equals, true)
+	ch.Assert(clone.AutoCompleteDays, check.Equals, 7)
+	ch.Assert(clone.Priority, check.Equals, PriorityHigh)
+	ch.Assert(clone.Results, check.HasLen, 1)
+	ch.Assert(clone.Results[0].Email, check.Equals, "wave@example.com")
+	got, err := GetCampaignMailContext(clone.Id, clone.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Modifiers, check.HasLen, 1)
+	ch.Assert(got.Modifiers[0].Options["prefix"], check.Equals, "----=_Part_")
+
+	// Clones of clones are linked to the original campaign, and the waves
+	// can be listed together
+	wave, err := CloneCampaign(context.Background(), clone.Id, clone.UserId, CloneRequest{
+		Name:   "Third wave",
+		Groups: []string{other.Name},
+	})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(wave.Name, check.Equals, "Third wave")
+	ch.Assert(wave.SourceCampaignId, check.Equals, c.Id)
+	ch.Assert(wave.SMTPId, check.Equals, smtp.Id)
+	ch.Assert(wave.Status, check.Equals, CampaignInProgress)
+	ch.Assert(wave.SendByDate.Sub(wave.LaunchDate), check.Equals, 2*time.Hour)
+	summaries, _, err := ListCampaignSummaries(c.UserId, ListOptions{Filters: map[string]string{"source_campaign_id": strconv.FormatInt(c.Id, 10)}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(summaries.Campaigns, check.HasLen, 2)
+	ch.Assert(summaries.Campaigns[0].SourceCampaignId, check.Equals, c.Id)
+
+	_, err = CloneCampaign(context.Background(), c.Id, 2, CloneRequest{Groups: []string{other.Name}})
+	ch.Assert(err, check.NotNil)
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gophish/gomail"
+	"github.com/gophish/gophish/mailer"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrUnknownModifier is returned when a campaign enables a message modifier
+// which isn't registered.
+var ErrUnknownModifier = errors.New("Unknown message modifier")
+
+// CampaignModifier enables a message modifier for the emails of a campaign.
+// Modifiers are applied in the order they're given.
+type CampaignModifier struct {
+	Id         int64             `json:"-"`
+	CampaignId int64             `json:"-"`
+	Name       string            `json:"name"`
+	Options    map[string]string `json:"options,omitempty" gorm:"-"`
+	// RawOptions is the JSON encoding of the options, as stored.
+	RawOptions string `json:"-" gorm:"column:options"`
+}
+
+// Validate ensures the modifier is registered, and that it accepts its
+// options.
+func (cm *CampaignModifier) Validate() error {
+	m, ok := mailer.GetModifier(cm.Name)
+	if !ok {
+		return fmt.Errorf("%s: %s", ErrUnknownModifier, cm.Name)
+	}
+	if v, ok := m.(mailer.OptionsValidator); ok {
+		err := v.ValidateOptions(cm.Options)
+		if err != nil {
+			return fmt.Errorf("Invalid options for message modifier %s: %s", cm.Name, err)
+		}
+	}
+	return nil
+}
+
+// BeforeSave encodes the options for storing.
+func (cm *CampaignModifier) BeforeSave() error {
+	b, err := json.Marshal(cm.Options)
+	if err != nil {
+		return err
+	}
+	cm.RawOptions = string(b)
+	return nil
+}
+
+// AfterFind decodes the stored options.
+func (cm *CampaignModifier) AfterFind() error {
+	cm.Options = map[string]string{}
+	if cm.RawOptions == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(cm.RawOptions), &cm.Options)
+}
+
+// validateModifiers checks each of the campaign's message modifiers.
+func (c *Campaign) validateModifiers() error {
+	for i := range c.Modifiers {
+		err := c.Modifiers[i].Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getModifiers loads the message modifiers of the campaign, in the order
+// they were given.
+func (c *Campaign) getModifiers() error {
+	err := db.Where("campaign_id=?", c.Id).Order("id asc").Find(&c.Modifiers).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if len(c.Modifiers) == 0 {
+		c.Modifiers = make([]CampaignModifier, 0)
+	}
+	return nil
+}
+
+// saveModifiers stores the message modifiers of the campaign.
+func (c *Campaign) saveModifiers() error {
+	for i := range c.Modifiers {
+		c.Modifiers[i].Id = 0
+		c.Modifiers[i].CampaignId = c.Id
+		err := db.Save(&c.Modifiers[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyModifiers applies the campaign's message modifiers to a generated
+// message sent to the recipient.
+func applyModifiers(cms []CampaignModifier, msg *gomail.Message, recipient string) error {
+	for _, cm := range cms {
+		m, ok := mailer.GetModifier(cm.Name)
+		if !ok {
+			return fmt.Errorf("%s: %s", ErrUnknownModifier, cm.Name)
+		}
+		err := m.Modify(msg, recipient, cm.Options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRawModifiers applies the campaign's message modifiers which change
+// the written message.
+func applyRawModifiers(cms []CampaignModifier, raw []byte) ([]byte, error) {
+	for _, cm := range cms {
+		m, ok := mailer.GetModifier(cm.Name)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", ErrUnknownModifier, cm.Name)
+		}
+		rm, ok := m.(mailer.RawModifier)
+		if !ok {
+			continue
+		}
+		var err error
+		raw, err = rm.ModifyRaw(raw, cm.Options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
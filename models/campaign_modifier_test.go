@@ -0,0 +1,58 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignModifierValidation(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Modifiers = []CampaignModifier{{Name: "unknown"}}
+	err := PostCampaign(&c, c.UserId)
+	ch.Assert(err, check.ErrorMatches, ErrUnknownModifier.Error()+".*")
+
+	c.Modifiers = []CampaignModifier{{Name: "boundaries", Options: map[string]string{"prefix": "has space"}}}
+	err = PostCampaign(&c, c.UserId)
+	ch.Assert(err, check.ErrorMatches, "Invalid options for message modifier boundaries.*")
+}
+
+func (s *ModelsSuite) TestCampaignModifiers(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Modifiers = []CampaignModifier{
+		{Name: "headers", Options: map[string]string{"X-Priority": "3"}},
+		{Name: "header_order", Options: map[string]string{"order": "X-Priority"}},
+	}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	// The modifiers are stored with the campaign, in order
+	got, err := GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(got.Modifiers), check.Equals, 2)
+	ch.Assert(got.Modifiers[0].Name, check.Equals, "headers")
+	ch.Assert(got.Modifiers[0].Options, check.DeepEquals, map[string]string{"X-Priority": "3"})
+	ch.Assert(got.Modifiers[1].Name, check.Equals, "header_order")
+
+	// And are applied to the generated emails
+	e := s.emailFromFirstMailLog(c, ch)
+	ch.Assert(e.Headers.Get("X-Priority"), check.Equals, "3")
+
+	m := &MailLog{}
+	err = db.Where("r_id=?", c.Results[0].RId).Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+	msg := gomail.NewMessage()
+	ch.Assert(m.Generate(msg), check.Equals, nil)
+	buf := &bytes.Buffer{}
+	_, err = msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+	raw, err := m.RewriteMessage(buf.Bytes())
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.HasPrefix(string(raw), "X-Priority: 3\r\n"), check.Equals, true)
+
+	ch.Assert(DeleteCampaign(c.Id), check.Equals, nil)
+	var count int
+	db.Model(&CampaignModifier{}).Where("campaign_id=?", c.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExcludeRecentDays indicates that the number of days in which
+// targets are excluded if they were sent another campaign is negative.
+var ErrInvalidExcludeRecentDays = errors.New("The number of days to exclude recent recipients for can't be negative")
+
+// getRecentRecipients returns the lowercased email addresses sent, or
+// scheduled to be sent, any of the user's campaigns since the given time. If
+// allUsers is set, every user's campaigns are included.
+// Recipients of simulated campaigns weren't sent any emails, so they aren't
+// included. Recipients of campaigns in privacy mode are only included by
+// their pseudonyms.
+func getRecentRecipients(uid int64, since time.Time, allUsers bool) (map[string]bool, error) {
+	emails := []string{}
+	query := db.Table("results").
+		Joins("join campaigns c ON c.id = results.campaign_id").
+		Where("results.send_date >= ? AND (c.simulate IS NULL OR c.simulate = ?)", since, false)
+	if !allUsers {
+		query = query.Where("c.user_id = ?", uid)
+	}
+	err := query.Pluck("DISTINCT results.email", &emails).Error
+	if err != nil {
+		return nil, err
+	}
+	recent := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		recent[strings.ToLower(email)] = true
+	}
+	return recent, nil
+}
+
+// excludeRecentRecipients removes the targets who were sent another campaign
+// in the campaign's ExcludeRecentDays from its groups, returning how many
+// were removed.
+//
+// Only the owner's campaigns are checked, so that users can't tell who other
+// teams have targeted. Campaigns created by administrators are checked
+// against every user's campaigns.
+func (c *Campaign) excludeRecentRecipients() (int, error) {
+	if c.ExcludeRecentDays == 0 {
+		return 0, nil
+	}
+	u, err := GetUser(c.UserId)
+	if err != nil {
+		return 0, err
+	}
+	allUsers, err := u.HasPermission(PermissionModifySystem)
+	if err != nil {
+		return 0, err
+	}
+	since := time.Now().UTC().AddDate(0, 0, -c.ExcludeRecentDays)
+	recent, err := getRecentRecipients(c.UserId, since, allUsers)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for i, g := range c.Groups {
+		targets := make([]Target, 0, len(g.Targets))
+		for _, t := range g.Targets {
+			if recent[strings.ToLower(t.Email)] || containsPseudonym(recent, t.Email) {
+				removed++
+				continue
+			}
+			targets = append(targets, t)
+		}
+		c.Groups[i].Targets = targets
+	}
+	return removed, nil
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestExcludeRecentRecipients(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	c.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	// Recipients of simulated campaigns weren't sent any emails
+	sg := Group{Name: "Simulated Group", UserId: c.UserId}
+	sg.Targets = []Target{{BaseRecipient: BaseRecipient{Email: "simulated@example.com"}}}
+	ch.Assert(PostGroup(&sg), check.Equals, nil)
+	sim := Campaign{Name: "Simulated", Template: c.Template, Page: c.Page, SMTP: c.SMTP, Simulate: true}
+	sim.Groups = []Group{{Name: sg.Name}}
+	ch.Assert(PostCampaign(&sim, c.UserId), check.Equals, nil)
+
+	// Other users' campaigns are included for administrators
+	other := Campaign{Name: "Other User", UserId: 2, Status: CampaignInProgress}
+	ch.Assert(db.Save(&other).Error, check.Equals, nil)
+	r := Result{CampaignId: other.Id, UserId: 2, RId: "other", SendDate: time.Now().UTC()}
+	r.Email = "other@example.com"
+	ch.Assert(db.Save(&r).Error, check.Equals, nil)
+
+	// Recipients of campaigns in privacy mode are matched by their pseudonyms
+	private := Campaign{Name: "Private", UserId: 2, Status: CampaignInProgress, Anonymize: true}
+	ch.Assert(db.Save(&private).Error, check.Equals, nil)
+	r = Result{CampaignId: private.Id, UserId: 2, RId: "private", SendDate: time.Now().UTC()}
+	r.Email = AnonymizeEmail("private@example.com")
+	ch.Assert(db.Save(&r).Error, check.Equals, nil)
+
+	g.Targets = append(g.Targets,
+		Target{BaseRecipient: BaseRecipient{Email: "new@example.com"}},
+		Target{BaseRecipient: BaseRecipient{Email: "simulated@example.com"}},
+		Target{BaseRecipient: BaseRecipient{Email: "OTHER@example.com"}},
+		Target{BaseRecipient: BaseRecipient{Email: "Private@example.com"}},
+	)
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+
+	c2 := Campaign{Name: "Exclude Recent", Template: c.Template, Page: c.Page, SMTP: c.SMTP, ExcludeRecentDays: -1}
+	c2.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c2, c.UserId), check.Equals, ErrInvalidExcludeRecentDays)
+	c2.ExcludeRecentDays = 30
+	ch.Assert(PostCampaign(&c2, c.UserId), check.Equals, nil)
+	emails := []string{}
+	for _, r := range c2.Results {
+		emails = append(emails, r.Email)
+	}
+	ch.Assert(emails, check.DeepEquals, []string{"new@example.com", "simulated@example.com"})
+
+	// Other users only have their own campaigns checked
+	since := time.Now().UTC().AddDate(0, 0, -30)
+	recent, err := getRecentRecipients(2, since, false)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(recent, check.DeepEquals, map[string]bool{
+		"other@example.com":                   true,
+		AnonymizeEmail("private@example.com"): true,
+	})
+	recent, err = getRecentRecipients(3, since, false)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(recent), check.Equals, 0)
+
+	// Without the option, every target is sent the campaign
+	c3 := Campaign{Name: "Everyone", Template: c.Template, Page: c.Page, SMTP: c.SMTP}
+	c3.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c3, c.UserId), check.Equals, nil)
+	ch.Assert(len(c3.Results), check.Equals, len(g.Targets))
+}
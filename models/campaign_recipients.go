@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCampaignNotRunning is returned when adding recipients to a campaign
+// which has been completed or archived.
+var ErrCampaignNotRunning = errors.New("Recipients can only be added to campaigns which haven't been completed")
+
+// AddRecipientsRequest is a request to add recipients to a campaign which
+// has already been launched.
+type AddRecipientsRequest struct {
+	// Groups are the groups, by name, whose targets are added.
+	Groups []Group `json:"groups"`
+	// Targets are added individually, without needing to be in a group.
+	Targets []Target `json:"targets"`
+}
+
+// AddRecipientsResult is the outcome of adding recipients to a campaign.
+type AddRecipientsResult struct {
+	// Skipped is the number of recipients who were already in the campaign,
+	// who are on leave or have exited, or whose tags the campaign excludes.
+	Skipped int      `json:"skipped"`
+	Results []Result `json:"results"`
+}
+
+// AddCampaignRecipients adds recipients to a running campaign, so that late
+// additions are reported alongside everyone else. The new emails are spread
+// over what's left of the campaign's send window, or sent straight away if
+// the window has passed. Recipients already in the campaign are skipped.
+func AddCampaignRecipients(cid int64, uid int64, req AddRecipientsRequest) (AddRecipientsResult, error) {
+	ar := AddRecipientsResult{Results: []Result{}}
+	c := Campaign{}
+	err := db.Where("id = ? AND user_id = ?", cid, uid).Find(&c).Error
+	if err != nil {
+		return ar, err
+	}
+	if c.Status == CampaignComplete || !c.ArchivedDate.IsZero() {
+		return ar, ErrCampaignNotRunning
+	}
+	targets := append([]Target{}, req.Targets...)
+	for _, g := range req.Groups {
+		group, err := GetGroupByName(g.Name, uid)
+		if err == gorm.ErrRecordNotFound {
+			log.WithFields(logrus.Fields{
+				"group": g.Name,
+			}).Error("Group does not exist")
+			return ar, ErrGroupNotFound
+		} else if err != nil {
+			log.Error(err)
+			return ar, err
+		}
+		group.applyDefaultLanguage()
+		targets = append(targets, group.Targets...)
+	}
+	if len(targets) == 0 {
+		return ar, ErrNoTargetsSpecified
+	}
+	existing := []string{}
+	err = db.Table("results").Where("campaign_id = ?", cid).Pluck("email", &existing).Error
+	if err != nil {
+		return ar, err
+	}
+	// Results in privacy mode campaigns only have the recipient's pseudonym
+	// once their email has been sent, so targets are checked against both.
+	seen := make(map[string]bool, len(existing))
+	for _, email := range existing {
+		seen[strings.ToLower(email)] = true
+	}
+	added := []Target{}
+	for _, t := range targets {
+		if t.Email == "" {
+			return ar, ErrEmailNotSpecified
+		}
+		email := strings.ToLower(t.Email)
+		if seen[email] || containsPseudonym(seen, email) || t.Suppressed() || !c.includesTarget(t) {
+			ar.Skipped++
+			continue
+		}
+		if t.requiresApproval() && c.Status != CampaignPending && c.ApprovedBy == 0 {
+			return ar, ErrApprovalRequired
+		}
+		t.Tags = normalizeTags(t.Tags)
+		seen[email] = true
+		added = append(added, t)
+	}
+
+	// The new emails are sent in what's left of the send window
+	now := time.Now().UTC()
+	start := c.LaunchDate
+	if start.Before(now) {
+		start = now
+	}
+	end := c.SendByDate
+	if end.Before(start) {
+		end = start
+	}
+	results := make([]Result, len(added))
+	processing := make([]bool, len(added))
+	for i, t := range added {
+		results[i] = Result{
+			BaseRecipient: BaseRecipient{
+				Email:     t.Email,
+				Position:  t.Position,
+				FirstName: t.FirstName,
+				LastName:  t.LastName,
+				Language:  t.Language,
+			},
+			Status:       StatusScheduled,
+			CampaignId:   c.Id,
+			UserId:       c.UserId,
+			SendDate:     spreadSendDate(start, end, i, len(added)),
+			ModifiedDate: now,
+			TargetTags:   t.Tags,
+		}
+		if !results[i].SendDate.After(now) && c.Status != CampaignPending {
+			results[i].Status = StatusSending
+		}
+	}
+	for s := 0; s < len(results); s += LaunchBatchSize {
+		e := s + LaunchBatchSize
+		if e > len(results) {
+			e = len(results)
+		}
+		err = generateResultIds(results[s:e])
+		if err == nil {
+			err = insertResults(results[s:e], processing[s:e])
+		}
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"campaign_id": c.Id,
+			}).Errorf("error adding recipients: %v", err)
+			return ar, err
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"campaign_id": c.Id,
+		"added":       len(results),
+		"skipped":     ar.Skipped,
+	}).Info("Added recipients to campaign")
+	ar.Results = results
+	return ar, nil
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAddCampaignRecipients(ch *check.C) {
+	c := s.createCampaign(ch)
+	req := AddRecipientsRequest{Targets: []Target{
+		{BaseRecipient: BaseRecipient{Email: "test1@example.com"}},
+		{BaseRecipient: BaseRecipient{Email: "late@example.com", FirstName: "Late"}},
+	}}
+	ar, err := AddCampaignRecipients(c.Id, c.UserId, req)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ar.Skipped, check.Equals, 1)
+	ch.Assert(len(ar.Results), check.Equals, 1)
+	ch.Assert(ar.Results[0].Status, check.Equals, StatusSending)
+
+	r, err := GetResult(ar.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.FirstName, check.Equals, "Late")
+	m := &MailLog{}
+	err = db.Where("r_id=?", r.RId).Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(m.CampaignId, check.Equals, c.Id)
+	ch.Assert(m.Processing, check.Equals, false)
+
+	c, err = GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(c.Results), check.Equals, 5)
+}
+
+func (s *ModelsSuite) TestAddCampaignRecipientsSendWindow(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.SendByDate = time.Now().UTC().Add(2 * time.Hour)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	group := Group{Name: "Late Group", UserId: c.UserId, Targets: []Target{
+		{BaseRecipient: BaseRecipient{Email: "late1@example.com"}},
+		{BaseRecipient: BaseRecipient{Email: "late2@example.com"}},
+	}}
+	ch.Assert(PostGroup(&group), check.Equals, nil)
+	ar, err := AddCampaignRecipients(c.Id, c.UserId, AddRecipientsRequest{Groups: []Group{{Name: "Late Group"}}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ar.Results), check.Equals, 2)
+	// The emails are spread over the rest of the send window
+	ch.Assert(ar.Results[0].Status, check.Equals, StatusSending)
+	ch.Assert(ar.Results[1].Status, check.Equals, StatusScheduled)
+	ch.Assert(ar.Results[1].SendDate.After(time.Now().Add(time.Hour-2*time.Minute)), check.Equals, true)
+	ch.Assert(ar.Results[1].SendDate.Before(c.SendByDate), check.Equals, true)
+
+	_, err = AddCampaignRecipients(c.Id, c.UserId, AddRecipientsRequest{Groups: []Group{{Name: "Missing"}}})
+	ch.Assert(err, check.Equals, ErrGroupNotFound)
+	_, err = AddCampaignRecipients(c.Id, c.UserId, AddRecipientsRequest{})
+	ch.Assert(err, check.Equals, ErrNoTargetsSpecified)
+}
+
+func (s *ModelsSuite) TestAddCampaignRecipientsCompleted(ch *check.C) {
+	c := s.createCampaign(ch)
+	ch.Assert(CompleteCampaign(c.Id, c.UserId), check.Equals, nil)
+	req := AddRecipientsRequest{Targets: []Target{{BaseRecipient: BaseRecipient{Email: "late@example.com"}}}}
+	_, err := AddCampaignRecipients(c.Id, c.UserId, req)
+	ch.Assert(err, check.Equals, ErrCampaignNotRunning)
+}
+
+func (s *ModelsSuite) TestAddCampaignRecipientsDuplicates(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Anonymize = true
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	sent, err := GetResult(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(sent.HandleEmailSent(), check.Equals, nil)
+
+	// Recipients already in the campaign are skipped regardless of case,
+	// even once their addresses have been anonymized
+	req := AddRecipientsRequest{Targets: []Target{
+		{BaseRecipient: BaseRecipient{Email: strings.ToUpper(c.Results[0].Email)}},
+		{BaseRecipient: BaseRecipient{Email: strings.ToUpper(c.Results[1].Email)}},
+		{BaseRecipient: BaseRecipient{Email: "late@example.com"}},
+		{BaseRecipient: BaseRecipient{Email: "Late@Example.com"}},
+	}}
+	ar, err := AddCampaignRecipients(c.Id, c.UserId, req)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ar.Skipped, check.Equals, 3)
+	ch.Assert(len(ar.Results), check.Equals, 1)
+	ch.Assert(ar.Results[0].Email, check.Equals, "late@example.com")
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// ErrInvalidSamplePercent indicates that the percentage of targets to sample
+// isn't between 0 and 100.
+var ErrInvalidSamplePercent = errors.New("The sample percentage must be between 0 and 100")
+
+// validSamplePercent returns whether the percentage of targets to sample is
+// valid. Zero sends the campaign to every target.
+func validSamplePercent(percent int) bool {
+	return percent >= 0 && percent <= 100
+}
+
+// sampleSize returns how many of the population are sampled, rounding up so
+// that small groups are still sampled.
+func sampleSize(population, percent int) int {
+	return (population*percent + 99) / 100
+}
+
+// sampleTargets removes the targets which aren't in the campaign's random
+// sample from its groups. Targets are sampled by email address, so that
+// targets in several groups are only counted once, and the same seed and
+// targets always give the same sample. If the campaign doesn't have a seed,
+// one is generated and recorded with the size of the sampled population.
+func (c *Campaign) sampleTargets() {
+	if c.SamplePercent == 0 {
+		return
+	}
+	if c.SampleSeed == 0 {
+		c.SampleSeed = time.Now().UnixNano()
+	}
+	seen := map[string]bool{}
+	emails := []string{}
+	for _, g := range c.Groups {
+		for _, t := range g.Targets {
+			if !seen[t.Email] {
+				seen[t.Email] = true
+				emails = append(emails, t.Email)
+			}
+		}
+	}
+	sort.Strings(emails)
+	c.SamplePopulation = len(emails)
+	r := rand.New(rand.NewSource(c.SampleSeed))
+	r.Shuffle(len(emails), func(i, j int) {
+		emails[i], emails[j] = emails[j], emails[i]
+	})
+	sampled := map[string]bool{}
+	for _, email := range emails[:sampleSize(len(emails), c.SamplePercent)] {
+		sampled[email] = true
+	}
+	for i, g := range c.Groups {
+		targets := make([]Target, 0, len(g.Targets))
+		for _, t := range g.Targets {
+			if sampled[t.Email] {
+				targets = append(targets, t)
+			}
+		}
+		c.Groups[i].Targets = targets
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignSample(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	for i := 0; i < 20; i++ {
+		g.Targets = append(g.Targets, Target{BaseRecipient: BaseRecipient{Email: fmt.Sprintf("sample%d@example.com", i)}})
+	}
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+	population := len(g.Targets)
+
+	c.Groups = []Group{{Name: g.Name}}
+	c.SamplePercent = 101
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidSamplePercent)
+
+	// The sample is rounded up and recorded with its seed
+	c.SamplePercent = 25
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.SampleSeed, check.Not(check.Equals), int64(0))
+	ch.Assert(c.SamplePopulation, check.Equals, population)
+	ch.Assert(len(c.Results), check.Equals, (population+3)/4)
+	got, err := GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.SampleSeed, check.Equals, c.SampleSeed)
+	ch.Assert(len(got.Results), check.Equals, len(c.Results))
+
+	// The same seed gives the same sample, even if the group is listed twice
+	c2 := Campaign{Name: "Same Sample", Template: c.Template, Page: c.Page, SMTP: c.SMTP}
+	c2.Groups = []Group{{Name: g.Name}, {Name: g.Name}}
+	c2.SamplePercent = c.SamplePercent
+	c2.SampleSeed = c.SampleSeed
+	ch.Assert(PostCampaign(&c2, c.UserId), check.Equals, nil)
+	ch.Assert(c2.SamplePopulation, check.Equals, population)
+	ch.Assert(len(c2.Results), check.Equals, len(c.Results))
+	for i := range c.Results {
+		ch.Assert(c2.Results[i].Email, check.Equals, c.Results[i].Email)
+	}
+}
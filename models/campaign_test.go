@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jinzhu/gorm"
 	check "gopkg.in/check.v1"
 )
 
@@ -131,6 +132,27 @@ func (s *ModelsSuite) TestDeleteCampaignAlsoDeletesMailLogs(c *check.C) {
 	c.Assert(len(ms), check.Equals, 0)
 }
 
+func (s *ModelsSuite) TestDeleteCampaigns(c *check.C) {
+	campaign := s.createCampaign(c)
+	_, err := DeleteCampaigns(campaign.UserId, map[string]string{})
+	c.Assert(err, check.Equals, ErrNoFilterSpecified)
+	_, err = DeleteCampaigns(campaign.UserId, map[string]string{"smtp_id": "1"})
+	c.Assert(err, check.Equals, ErrInvalidFilterField)
+
+	n, err := DeleteCampaigns(campaign.UserId, map[string]string{"status": CampaignComplete})
+	c.Assert(err, check.Equals, nil)
+	c.Assert(n, check.Equals, 0)
+
+	n, err = DeleteCampaigns(campaign.UserId, map[string]string{"status": campaign.Status})
+	c.Assert(err, check.Equals, nil)
+	c.Assert(n, check.Equals, 1)
+	_, err = GetCampaign(campaign.Id, campaign.UserId)
+	c.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+	ms, err := GetMailLogsByCampaign(campaign.Id)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(ms), check.Equals, 0)
+}
+
 func (s *ModelsSuite) TestCompleteCampaignAlsoDeletesMailLogs(c *check.C) {
 	campaign := s.createCampaign(c)
 	ms, err := GetMailLogsByCampaign(campaign.Id)
@@ -152,6 +174,36 @@ func (s *ModelsSuite) TestCampaignGetResults(c *check.C) {
 	c.Assert(len(campaign.Results), check.Equals, len(got.Results))
 }
 
+func (s *ModelsSuite) TestCampaignGetResultsDelta(c *check.C) {
+	campaign := s.createCampaign(c)
+	delta, err := GetCampaignResultsDelta(campaign.Id, campaign.UserId, time.Time{}, 0)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(delta.Results), check.Equals, len(campaign.Results))
+	c.Assert(len(delta.Events), check.Not(check.Equals), 0)
+	c.Assert(delta.LastEventId, check.Not(check.Equals), int64(0))
+
+	// Nothing has changed yet
+	next, err := GetCampaignResultsDelta(campaign.Id, campaign.UserId, time.Time{}, delta.LastEventId)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(next.Events), check.Equals, 0)
+	c.Assert(len(next.Results), check.Equals, 0)
+	c.Assert(next.LastEventId, check.Equals, delta.LastEventId)
+
+	result := campaign.Results[0]
+	c.Assert(result.HandleClickedLink(EventDetails{}), check.Equals, nil)
+	next, err = GetCampaignResultsDelta(campaign.Id, campaign.UserId, time.Time{}, delta.LastEventId)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(next.Events), check.Equals, 1)
+	c.Assert(next.Events[0].Message, check.Equals, EventClicked)
+	c.Assert(len(next.Results), check.Equals, 1)
+	c.Assert(next.Results[0].Email, check.Equals, result.Email)
+	c.Assert(next.LastEventId > delta.LastEventId, check.Equals, true)
+
+	// Campaigns owned by other users can't be retrieved
+	_, err = GetCampaignResultsDelta(campaign.Id, campaign.UserId+1, time.Time{}, 0)
+	c.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+}
+
 func setupCampaignDependencies(b *testing.B, size int) {
 	group := Group{Name: "Test Group"}
 	// Create a large group of 5000 members
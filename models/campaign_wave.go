@@ -0,0 +1,381 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrWaveNameNotSpecified is returned when a campaign wave doesn't have a
+// name.
+var ErrWaveNameNotSpecified = errors.New("Campaign waves need a name")
+
+// ErrDuplicateWave is returned when a campaign has more than one wave with
+// the same name.
+var ErrDuplicateWave = errors.New("Campaign waves must have unique names")
+
+// ErrInvalidWavePercent is returned when the percentages of recipients sent
+// each wave aren't between 0 and 100, or don't add up to 100.
+var ErrInvalidWavePercent = errors.New("The percentages of recipients sent each wave must add up to 100")
+
+// ErrWaveSourceNotFound is returned when a follow-up wave isn't sent to the
+// non-clickers of an earlier wave.
+var ErrWaveSourceNotFound = errors.New("Follow-up waves must be sent to the non-clickers of an earlier wave")
+
+// ErrInvalidWaveLaunchDate is returned when a wave launches before the
+// campaign, or a follow-up wave launches before its earlier wave has been
+// sent.
+var ErrInvalidWaveLaunchDate = errors.New("Waves can't launch before the campaign, and follow-up waves can't launch before their earlier wave has been sent")
+
+// ErrWaveAnonymized is returned when a campaign running in privacy mode has a
+// follow-up wave, since the recipients of the earlier wave are anonymized
+// once their emails are sent.
+var ErrWaveAnonymized = errors.New("Campaigns running in privacy mode can't have follow-up waves")
+
+// CampaignWave is a named wave of a campaign's emails, with its own launch
+// date and template. Waves either split the campaign's recipients between
+// them, or follow up on an earlier wave by sending to its recipients who
+// didn't click the link.
+type CampaignWave struct {
+	Id         int64  `json:"id"`
+	CampaignId int64  `json:"-"`
+	Name       string `json:"name"`
+	// Template defaults to the campaign's template.
+	TemplateId int64    `json:"-"`
+	Template   Template `json:"template" gorm:"-"`
+	// LaunchDate defaults to the campaign's launch date. If SendByDate is
+	// set, the wave's emails are spread out until then.
+	LaunchDate time.Time `json:"launch_date"`
+	SendByDate time.Time `json:"send_by_date"`
+	// Percent is the percentage of the campaign's recipients sent the wave.
+	// If none of the waves have a percentage, the recipients are split
+	// evenly between them.
+	Percent int `json:"percent"`
+	// NonClickersOf is the name of the earlier wave this wave follows up
+	// on. Once it launches, the wave is sent to the recipients of the
+	// earlier wave who were sent the email, but didn't click the link.
+	NonClickersOf string `json:"non_clickers_of"`
+	// Launched is set once the wave's results have been created.
+	Launched bool `json:"launched"`
+	// Stats are the wave's results, which are only included when the
+	// waves are requested on their own.
+	Stats *CampaignStats `json:"stats,omitempty" gorm:"-"`
+}
+
+// splitsRecipients returns whether the wave is sent to a share of the
+// campaign's recipients, rather than following up on an earlier wave.
+func (w *CampaignWave) splitsRecipients() bool {
+	return w.NonClickersOf == ""
+}
+
+// validateWaves checks the names, percentages and follow-ups of the
+// campaign's waves.
+func (c *Campaign) validateWaves() error {
+	waves := map[string]CampaignWave{}
+	total := 0
+	for _, w := range c.Waves {
+		switch {
+		case w.Name == "":
+			return ErrWaveNameNotSpecified
+		case w.Percent < 0 || w.Percent > 100:
+			return ErrInvalidWavePercent
+		case !w.splitsRecipients() && w.Percent != 0:
+			return ErrInvalidWavePercent
+		case !w.SendByDate.IsZero() && !w.LaunchDate.IsZero() && w.SendByDate.Before(w.LaunchDate):
+			return ErrInvalidSendByDate
+		}
+		if _, ok := waves[w.Name]; ok {
+			return ErrDuplicateWave
+		}
+		if !w.splitsRecipients() {
+			if c.Anonymize {
+				return ErrWaveAnonymized
+			}
+			if _, ok := waves[w.NonClickersOf]; !ok {
+				return ErrWaveSourceNotFound
+			}
+		}
+		waves[w.Name] = w
+		total += w.Percent
+	}
+	if total != 0 && total != 100 {
+		return ErrInvalidWavePercent
+	}
+	return nil
+}
+
+// resolveWaves looks up the templates of the campaign's waves, and defaults
+// their launch dates to the campaign's.
+func (c *Campaign) resolveWaves(uid int64) error {
+	sent := map[string]time.Time{}
+	for i := range c.Waves {
+		w := &c.Waves[i]
+		if w.Template.Name == "" || w.Template.Name == c.Template.Name {
+			w.Template = c.Template
+		} else {
+			t, err := GetTemplateByName(w.Template.Name, uid)
+			if err == gorm.ErrRecordNotFound {
+				log.WithFields(logrus.Fields{
+					"template": w.Template.Name,
+					"wave":     w.Name,
+				}).Error("Template does not exist")
+				return ErrTemplateNotFound
+			} else if err != nil {
+				return err
+			}
+			w.Template = t
+		}
+		w.TemplateId = w.Template.Id
+		if w.LaunchDate.IsZero() {
+			w.LaunchDate = c.LaunchDate
+		}
+		w.LaunchDate = w.LaunchDate.UTC()
+		if !w.SendByDate.IsZero() {
+			w.SendByDate = w.SendByDate.UTC()
+		}
+		if w.LaunchDate.Before(c.LaunchDate) {
+			return ErrInvalidWaveLaunchDate
+		}
+		if !w.splitsRecipients() && w.LaunchDate.Before(sent[w.NonClickersOf]) {
+			return ErrInvalidWaveLaunchDate
+		}
+		sent[w.Name] = w.LaunchDate
+		if !w.SendByDate.IsZero() {
+			sent[w.Name] = w.SendByDate
+		}
+		w.Launched = w.splitsRecipients()
+	}
+	return nil
+}
+
+// assignWaves splits the results between the waves which split the
+// campaign's recipients, in the order the results were created, and
+// schedules each result's email for its wave. It returns which of the
+// results are sent immediately.
+func (c *Campaign) assignWaves(results []Result) []bool {
+	split := []CampaignWave{}
+	weights := []int{}
+	total := 0
+	for _, w := range c.Waves {
+		if w.splitsRecipients() {
+			split = append(split, w)
+			weights = append(weights, w.Percent)
+			total += w.Percent
+		}
+	}
+	if total == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+	processing := make([]bool, len(results))
+	start, cumulative := 0, 0
+	for i, w := range split {
+		cumulative += weights[i]
+		end := len(results) * cumulative / total
+		for j := start; j < end; j++ {
+			r := &results[j]
+			r.Wave = w.Name
+			r.TemplateId = w.TemplateId
+			r.SendDate = spreadSendDate(w.LaunchDate, w.SendByDate, j-start, end-start)
+			r.Status = StatusScheduled
+			if c.Status != CampaignPending && !r.SendDate.After(c.CreatedDate) {
+				r.Status = StatusSending
+				processing[j] = true
+			}
+		}
+		start = end
+	}
+	return processing
+}
+
+// saveWaves stores the waves of the campaign.
+func (c *Campaign) saveWaves() error {
+	for i := range c.Waves {
+		c.Waves[i].Id = 0
+		c.Waves[i].CampaignId = c.Id
+		err := db.Save(&c.Waves[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getWaves loads the waves of the campaign, in the order they were given,
+// along with the names of their templates.
+func (c *Campaign) getWaves() error {
+	c.Waves = []CampaignWave{}
+	err := db.Where("campaign_id=?", c.Id).Order("id asc").Find(&c.Waves).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	for i := range c.Waves {
+		w := &c.Waves[i]
+		err = db.Table("templates").Select("id, name").Where("id=?", w.TemplateId).Find(&w.Template).Error
+		if err == gorm.ErrRecordNotFound {
+			w.Template = Template{Name: "[Deleted]"}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCampaignWaves returns the waves of the campaign owned by the given
+// user, along with the stats of each wave's results.
+func GetCampaignWaves(cid int64, uid int64) ([]CampaignWave, error) {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id").Where("id=? and user_id=?", cid, uid).Find(&c).Error
+	if err != nil {
+		return nil, err
+	}
+	err = c.getWaves()
+	if err != nil {
+		return nil, err
+	}
+	for i := range c.Waves {
+		stats, err := getResultStats(reportingDB().Table("results").Where("campaign_id = ? AND wave = ?", c.Id, c.Waves[i].Name))
+		if err != nil {
+			return nil, err
+		}
+		c.Waves[i].Stats = &stats
+	}
+	return c.Waves, nil
+}
+
+// LaunchDueWaves launches the follow-up waves of campaigns in progress which
+// are due to launch by the given time.
+func LaunchDueWaves(t time.Time) error {
+	ws := []CampaignWave{}
+	err := db.Table("campaign_waves").Select("campaign_waves.*").
+		Joins("join campaigns c ON c.id = campaign_waves.campaign_id").
+		Where("campaign_waves.launched = ? AND campaign_waves.launch_date <= ? AND c.status = ?", false, t.UTC(), CampaignInProgress).
+		Order("campaign_waves.id asc").
+		Find(&ws).Error
+	if err != nil {
+		return err
+	}
+	for _, w := range ws {
+		err = launchWave(w, t.UTC())
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"campaign_id": w.CampaignId,
+				"wave":        w.Name,
+			}).Errorf("error launching wave: %v", err)
+		}
+	}
+	return nil
+}
+
+// launchWave creates the results of a follow-up wave for the recipients of
+// its earlier wave who were sent the email, but didn't click the link. If
+// the results can't all be created, the wave is launched again on the next
+// tick for the recipients who are still missing.
+func launchWave(w CampaignWave, now time.Time) error {
+	// The wave is marked as launched first, so that it's only launched once
+	// if several instances are processing campaigns.
+	query := db.Model(&CampaignWave{}).Where("id = ? AND launched = ?", w.Id, false).Update("launched", true)
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil
+	}
+	created, err := createWaveResults(w, now)
+	if err != nil {
+		resetErr := db.Model(&CampaignWave{}).Where("id = ?", w.Id).Update("launched", false).Error
+		if resetErr != nil {
+			log.Error(resetErr)
+		}
+		return err
+	}
+	details, err := json.Marshal(map[string]interface{}{
+		"wave":       w.Name,
+		"recipients": created,
+	})
+	if err != nil {
+		return err
+	}
+	err = AddEvent(&Event{Message: "Wave Launched", Details: EncryptedString(details)}, w.CampaignId)
+	if err != nil && err != ErrEventSuppressed {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"campaign_id": w.CampaignId,
+		"wave":        w.Name,
+		"recipients":  created,
+	}).Info("Launched campaign wave")
+	return nil
+}
+
+// createWaveResults creates the results of a follow-up wave, skipping any
+// recipients who already have one, and returns how many were created. Only
+// the earlier wave's own results are followed up on, rather than the
+// reminders of their sequences, and recipients who clicked a reminder are
+// skipped.
+func createWaveResults(w CampaignWave, now time.Time) (int, error) {
+	launched := db.Table("results").Select("email").Where("campaign_id = ? AND wave = ?", w.CampaignId, w.Name).SubQuery()
+	sent := []Result{}
+	err := db.Where("campaign_id = ? AND wave = ? AND sequence_step = ? AND status IN (?)", w.CampaignId, w.NonClickersOf, 0, []string{EventSent, EventOpened}).
+		Where("r_id NOT IN ?", clickedSequenceRoots(w.CampaignId)).
+		Where("email NOT IN ?", launched).
+		Order("id asc").
+		Find(&sent).Error
+	if err != nil {
+		return 0, err
+	}
+	results := make([]Result, len(sent))
+	processing := make([]bool, len(sent))
+	for i, s := range sent {
+		results[i] = Result{
+			BaseRecipient: s.BaseRecipient,
+			Status:        StatusScheduled,
+			CampaignId:    s.CampaignId,
+			UserId:        s.UserId,
+			SendDate:      spreadSendDate(w.LaunchDate, w.SendByDate, i, len(sent)),
+			ModifiedDate:  now,
+			TargetTags:    s.TargetTags,
+			Wave:          w.Name,
+			TemplateId:    w.TemplateId,
+		}
+	}
+	for s := 0; s < len(results); s += LaunchBatchSize {
+		e := s + LaunchBatchSize
+		if e > len(results) {
+			e = len(results)
+		}
+		err = generateResultIds(results[s:e])
+		if err == nil {
+			err = insertResults(results[s:e], processing[s:e])
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(results), nil
+}
+
+// cloneWaves copies the waves of the source campaign to the clone, moving
+// their launch and send by dates by the same amount as the campaign's.
+func cloneWaves(src *Campaign, c *Campaign) {
+	offset := c.LaunchDate.Sub(src.LaunchDate)
+	for _, w := range src.Waves {
+		clone := CampaignWave{
+			Name:          w.Name,
+			Template:      Template{Name: w.Template.Name},
+			LaunchDate:    w.LaunchDate.Add(offset),
+			Percent:       w.Percent,
+			NonClickersOf: w.NonClickersOf,
+		}
+		if !w.SendByDate.IsZero() {
+			clone.SendByDate = w.SendByDate.Add(offset)
+		}
+		c.Waves = append(c.Waves, clone)
+	}
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignWaves(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	t := Template{Name: "Wave Template", Subject: "Wave", Text: "Wave", UserId: c.UserId}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	c.Groups = []Group{{Name: g.Name}}
+
+	// Waves are validated before the campaign is created
+	c.Waves = []CampaignWave{{Name: "first", Percent: 50}, {Name: "second", Percent: 40}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidWavePercent)
+	c.Waves = []CampaignWave{{Name: "first"}, {Name: "first"}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrDuplicateWave)
+	c.Waves = []CampaignWave{{Name: "first"}, {Name: "retry", NonClickersOf: "second"}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrWaveSourceNotFound)
+
+	later := time.Now().UTC().Add(time.Hour)
+	c.Waves = []CampaignWave{
+		{Name: "first", Percent: 50},
+		{Name: "second", Percent: 50, Template: Template{Name: t.Name}, LaunchDate: later},
+		{Name: "retry", NonClickersOf: "first", Template: Template{Name: t.Name}, LaunchDate: later},
+	}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	waves := map[string][]Result{}
+	for _, r := range c.Results {
+		waves[r.Wave] = append(waves[r.Wave], r)
+	}
+	ch.Assert(len(waves["first"]), check.Equals, 2)
+	ch.Assert(len(waves["second"]), check.Equals, 2)
+	ch.Assert(waves["second"][0].SendDate.Equal(later), check.Equals, true)
+
+	// Each wave's emails are sent with its template
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	for _, m := range ms {
+		mc, err := GetMailLogContext(m)
+		ch.Assert(err, check.Equals, nil)
+		r, err := GetResult(m.RId)
+		ch.Assert(err, check.Equals, nil)
+		if r.Wave == "second" {
+			ch.Assert(mc.Template.Name, check.Equals, t.Name)
+		} else {
+			ch.Assert(mc.Template.Name, check.Equals, c.Template.Name)
+		}
+	}
+
+	// Follow-up waves are sent to the non-clickers of the earlier wave once
+	// they launch
+	clicked, sent := waves["first"][0], waves["first"][1]
+	ch.Assert(db.Model(&clicked).Update("status", EventClicked).Error, check.Equals, nil)
+	ch.Assert(db.Model(&sent).Update("status", EventSent).Error, check.Equals, nil)
+	ch.Assert(LaunchDueWaves(time.Now()), check.Equals, nil)
+	ch.Assert(LaunchDueWaves(later), check.Equals, nil)
+	ch.Assert(LaunchDueWaves(later), check.Equals, nil)
+	// Waves launched again after failing partway only create the missing
+	// results
+	ch.Assert(db.Model(&CampaignWave{}).Where("campaign_id = ? AND name = ?", c.Id, "retry").Update("launched", false).Error, check.Equals, nil)
+	ch.Assert(LaunchDueWaves(later), check.Equals, nil)
+	retried := []Result{}
+	ch.Assert(db.Where("campaign_id = ? AND wave = ?", c.Id, "retry").Find(&retried).Error, check.Equals, nil)
+	ch.Assert(len(retried), check.Equals, 1)
+	ch.Assert(retried[0].Email, check.Equals, sent.Email)
+
+	ws, err := GetCampaignWaves(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ws), check.Equals, 3)
+	ch.Assert(ws[0].Stats.ClickedLink, check.Equals, int64(1))
+	ch.Assert(ws[0].Stats.EmailsSent, check.Equals, int64(2))
+	ch.Assert(ws[1].Template.Name, check.Equals, t.Name)
+	ch.Assert(ws[2].Launched, check.Equals, true)
+	ch.Assert(ws[2].Stats.Total, check.Equals, int64(1))
+
+	// Clones keep the waves, launching at the same times relative to the
+	// clone's launch date
+	launch := time.Now().UTC().Add(24 * time.Hour)
+	clone, err := CloneCampaign(context.Background(), c.Id, c.UserId, CloneRequest{Groups: []string{g.Name}, LaunchDate: launch})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(clone.Waves), check.Equals, 3)
+	offset := clone.Waves[1].LaunchDate.Sub(clone.LaunchDate) - later.Sub(c.LaunchDate)
+	ch.Assert(offset < time.Second && offset > -time.Second, check.Equals, true)
+}
+
+func (s *ModelsSuite) TestCampaignWavesPending(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	t := Template{Name: "Wave Template", Subject: "Wave", Text: "Wave", UserId: c.UserId}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	c.Groups = []Group{{Name: g.Name}}
+	later := time.Now().UTC().Add(time.Hour)
+	c.Waves = []CampaignWave{
+		{Name: "first", Template: Template{Name: t.Name}},
+		{Name: "retry", NonClickersOf: "first", LaunchDate: later},
+	}
+
+	// The earlier wave's recipients are anonymized once they're sent, so
+	// follow-up waves can't be used in privacy mode
+	c.Anonymize = true
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrWaveAnonymized)
+	c.Anonymize = false
+	c.AutoCompleteQuietHours = 1
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	// Failed emails are re-sent with their wave's template
+	m := &MailLog{}
+	ch.Assert(db.Where("r_id=?", c.Results[0].RId).Find(m).Error, check.Equals, nil)
+	ch.Assert(m.Error(errors.New("550 mailbox unavailable")), check.Equals, nil)
+	rr, err := ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rr.Queued, check.Equals, 1)
+	m = &MailLog{}
+	ch.Assert(db.Where("r_id=?", c.Results[0].RId).Find(m).Error, check.Equals, nil)
+	ch.Assert(m.TemplateId, check.Equals, t.Id)
+
+	// Campaigns aren't completed while a follow-up wave is still to launch
+	ch.Assert(db.Where("campaign_id=?", c.Id).Delete(&MailLog{}).Error, check.Equals, nil)
+	reason, err := c.autoCompleteReason(later.Add(2 * time.Hour))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(reason, check.Equals, "")
+	ch.Assert(db.Model(&CampaignWave{}).Where("campaign_id=?", c.Id).Update("launched", true).Error, check.Equals, nil)
+	reason, err = c.autoCompleteReason(later.Add(2 * time.Hour))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(reason, check.Not(check.Equals), "")
+}
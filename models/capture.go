@@ -0,0 +1,88 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidCapturedData is returned when the data sent by the capture script
+// isn't valid JSON.
+var ErrInvalidCapturedData = errors.New("The captured data must be JSON")
+
+// capturedDataKey is the key used for captured data which isn't an object
+const capturedDataKey = "data"
+
+// ParseCapturedData flattens the JSON payload sent by the capture script into
+// the same form as submitted form data. The keys of nested objects are joined
+// with dots, and arrays are stored as repeated values.
+func ParseCapturedData(body []byte) (url.Values, error) {
+	var data interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	err := dec.Decode(&data)
+	if err != nil {
+		return nil, ErrInvalidCapturedData
+	}
+	values := url.Values{}
+	if _, ok := data.(map[string]interface{}); ok {
+		flattenCapturedData(values, "", data)
+	} else {
+		flattenCapturedData(values, capturedDataKey, data)
+	}
+	return values, nil
+}
+
+func flattenCapturedData(values url.Values, key string, data interface{}) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if key != "" {
+				k = key + "." + k
+			}
+			flattenCapturedData(values, k, child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			flattenCapturedData(values, key, child)
+		}
+	case nil:
+		values.Add(key, "")
+	case string:
+		values.Add(key, v)
+	case json.Number:
+		values.Add(key, v.String())
+	case bool:
+		if v {
+			values.Add(key, "true")
+		} else {
+			values.Add(key, "false")
+		}
+	}
+}
+
+// isPasswordField returns whether the captured field looks like it holds a
+// password, using the last part of its key.
+func isPasswordField(key string) bool {
+	name := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	return strings.Contains(name, "pass") || strings.Contains(name, "pwd")
+}
+
+// FilterCapturedData removes the captured fields which the page isn't
+// configured to capture, in the same way as the names of form inputs are
+// removed when the page is saved.
+func (p *Page) FilterCapturedData(values url.Values) url.Values {
+	filtered := url.Values{}
+	if !p.CaptureCredentials {
+		return filtered
+	}
+	for k, v := range values {
+		if !p.CapturePasswords && isPasswordField(k) {
+			continue
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"net/url"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestParseCapturedData(c *check.C) {
+	body := `{"user": {"email": "foo@example.com", "remember": true}, "password": "secret", "codes": [1, 2], "token": null}`
+	values, err := ParseCapturedData([]byte(body))
+	c.Assert(err, check.Equals, nil)
+	c.Assert(values, check.DeepEquals, url.Values{
+		"user.email":    {"foo@example.com"},
+		"user.remember": {"true"},
+		"password":      {"secret"},
+		"codes":         {"1", "2"},
+		"token":         {""},
+	})
+
+	values, err = ParseCapturedData([]byte(`"username=foo"`))
+	c.Assert(err, check.Equals, nil)
+	c.Assert(values, check.DeepEquals, url.Values{"data": {"username=foo"}})
+
+	_, err = ParseCapturedData([]byte(`username=foo`))
+	c.Assert(err, check.Equals, ErrInvalidCapturedData)
+}
+
+func (s *ModelsSuite) TestFilterCapturedData(c *check.C) {
+	values := url.Values{
+		"user.email":    {"foo@example.com"},
+		"user.Password": {"secret"},
+		"pwd":           {"secret"},
+	}
+	p := Page{}
+	c.Assert(p.FilterCapturedData(values), check.DeepEquals, url.Values{})
+
+	p.CaptureCredentials = true
+	c.Assert(p.FilterCapturedData(values), check.DeepEquals, url.Values{
+		"user.email": {"foo@example.com"},
+	})
+
+	p.CapturePasswords = true
+	c.Assert(p.FilterCapturedData(values), check.DeepEquals, values)
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrComparisonCampaigns is returned when fewer than two campaigns, or a
+// campaign and the baseline, are given to compare.
+var ErrComparisonCampaigns = errors.New("At least two campaigns, or a campaign and the baseline, are needed for a comparison")
+
+// SignificanceLevel is the p-value below which a difference is considered
+// statistically significant.
+const SignificanceLevel = 0.05
+
+// comparisonMetrics are the compared metrics, along with the count used for
+// each.
+var comparisonMetrics = []struct {
+	name  string
+	count func(CampaignStats) int64
+}{
+	{"opened", func(s CampaignStats) int64 { return s.OpenedEmail }},
+	{"clicked", func(s CampaignStats) int64 { return s.ClickedLink }},
+	{"submitted_data", func(s CampaignStats) int64 { return s.SubmittedData }},
+	{"email_reported", func(s CampaignStats) int64 { return s.EmailReported }},
+	{"human_opened", func(s CampaignStats) int64 { return s.HumanOpened }},
+}
+
+// ComparedCampaign is a campaign, or the baseline, in a comparison. Rates
+// are the proportions of the sent emails which reached each metric.
+type ComparedCampaign struct {
+	Id    int64              `json:"id"`
+	Name  string             `json:"name"`
+	Stats CampaignStats      `json:"stats"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// MetricComparison compares a metric between the reference, which is the
+// baseline or the first campaign, and another campaign. The significance is
+// found using a two-proportion z-test.
+type MetricComparison struct {
+	Metric        string  `json:"metric"`
+	CampaignId    int64   `json:"campaign_id"`
+	ReferenceRate float64 `json:"reference_rate"`
+	Rate          float64 `json:"rate"`
+	Difference    float64 `json:"difference"`
+	ZScore        float64 `json:"z_score"`
+	PValue        float64 `json:"p_value"`
+	Significant   bool    `json:"significant"`
+}
+
+// CampaignComparison compares the metrics of campaigns with a reference.
+type CampaignComparison struct {
+	// Reference is the id of the campaign the others are compared to, or 0
+	// if they're compared to the baseline
+	Reference   int64              `json:"reference"`
+	Campaigns   []ComparedCampaign `json:"campaigns"`
+	Baseline    *ComparedCampaign  `json:"baseline,omitempty"`
+	Comparisons []MetricComparison `json:"comparisons"`
+}
+
+// CompareCampaigns compares the campaigns with the given ids, which must be
+// owned by the given user. If baseline is set, the campaigns are compared to
+// the user's other completed campaigns. Otherwise, they're compared to the
+// first campaign.
+func CompareCampaigns(ids []int64, baseline bool, uid int64) (CampaignComparison, error) {
+	cc := CampaignComparison{Campaigns: []ComparedCampaign{}, Comparisons: []MetricComparison{}}
+	if len(ids) == 0 || (len(ids) < 2 && !baseline) {
+		return cc, ErrComparisonCampaigns
+	}
+	for _, id := range ids {
+		cs, err := GetCampaignSummary(id, uid)
+		if err != nil {
+			return cc, err
+		}
+		cc.Campaigns = append(cc.Campaigns, newComparedCampaign(cs.Id, cs.Name, cs.Stats))
+	}
+	reference := cc.Campaigns[0]
+	compared := cc.Campaigns[1:]
+	if baseline {
+		b, err := getBaselineStats(ids, uid)
+		if err != nil {
+			return cc, err
+		}
+		bc := newComparedCampaign(0, "Baseline", b)
+		cc.Baseline = &bc
+		reference = bc
+		compared = cc.Campaigns
+	}
+	cc.Reference = reference.Id
+	for _, c := range compared {
+		for _, m := range comparisonMetrics {
+			cc.Comparisons = append(cc.Comparisons, compareProportions(m.name, c.Id,
+				m.count(reference.Stats), reference.Stats.EmailsSent, m.count(c.Stats), c.Stats.EmailsSent))
+		}
+	}
+	return cc, nil
+}
+
+// getBaselineStats returns the combined statistics of the user's completed
+// campaigns, other than the excluded ones.
+func getBaselineStats(exclude []int64, uid int64) (CampaignStats, error) {
+	total := CampaignStats{}
+	cids := []int64{}
+	err := reportingDB().Table("campaigns").Where("user_id=? AND status=? AND id NOT IN (?)", uid, CampaignComplete, exclude).Pluck("id", &cids).Error
+	if err != nil {
+		return total, err
+	}
+	for _, cid := range cids {
+		s, err := getCampaignStats(cid)
+		if err != nil {
+			return total, err
+		}
+		total.Total += s.Total
+		total.EmailsSent += s.EmailsSent
+		total.OpenedEmail += s.OpenedEmail
+		total.ClickedLink += s.ClickedLink
+		total.SubmittedData += s.SubmittedData
+		total.EmailReported += s.EmailReported
+		total.Forwarded += s.Forwarded
+		total.AutoReplied += s.AutoReplied
+		total.Unsubscribed += s.Unsubscribed
+		total.Error += s.Error
+		total.MachineOpened += s.MachineOpened
+		total.HumanOpened += s.HumanOpened
+	}
+	return total, nil
+}
+
+func newComparedCampaign(id int64, name string, s CampaignStats) ComparedCampaign {
+	c := ComparedCampaign{Id: id, Name: name, Stats: s, Rates: map[string]float64{}}
+	for _, m := range comparisonMetrics {
+		c.Rates[m.name] = rate(m.count(s), s.EmailsSent)
+	}
+	return c
+}
+
+func rate(count, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// compareProportions compares the proportion x2/n2 with the reference
+// proportion x1/n1 using a two-proportion z-test. If either sample is empty,
+// or neither proportion varies, the difference isn't significant.
+func compareProportions(metric string, cid int64, x1, n1, x2, n2 int64) MetricComparison {
+	p1, p2 := rate(x1, n1), rate(x2, n2)
+	mc := MetricComparison{
+		Metric:        metric,
+		CampaignId:    cid,
+		ReferenceRate: p1,
+		Rate:          p2,
+		Difference:    p2 - p1,
+		PValue:        1,
+	}
+	if n1 == 0 || n2 == 0 {
+		return mc
+	}
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return mc
+	}
+	mc.ZScore = (p2 - p1) / se
+	mc.PValue = math.Erfc(math.Abs(mc.ZScore) / math.Sqrt2)
+	mc.Significant = mc.PValue < SignificanceLevel
+	return mc
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"math"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCompareProportions(ch *check.C) {
+	// 30/100 clicked before training, and 15/100 after
+	mc := compareProportions("clicked", 2, 30, 100, 15, 100)
+	ch.Assert(mc.ReferenceRate, check.Equals, 0.3)
+	ch.Assert(mc.Rate, check.Equals, 0.15)
+	ch.Assert(math.Abs(mc.ZScore+2.5399) < 0.001, check.Equals, true, check.Commentf("z = %f", mc.ZScore))
+	ch.Assert(math.Abs(mc.PValue-0.0111) < 0.001, check.Equals, true, check.Commentf("p = %f", mc.PValue))
+	ch.Assert(mc.Significant, check.Equals, true)
+
+	mc = compareProportions("clicked", 2, 30, 100, 27, 100)
+	ch.Assert(mc.Significant, check.Equals, false)
+
+	// Empty campaigns and metrics which never vary aren't significant
+	mc = compareProportions("clicked", 2, 30, 100, 0, 0)
+	ch.Assert(mc.PValue, check.Equals, 1.0)
+	ch.Assert(mc.Significant, check.Equals, false)
+	mc = compareProportions("clicked", 2, 0, 100, 0, 100)
+	ch.Assert(mc.Significant, check.Equals, false)
+}
+
+func (s *ModelsSuite) TestCompareCampaigns(ch *check.C) {
+	first := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&first, first.UserId), check.Equals, nil)
+	for _, r := range first.Results {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+	ch.Assert(first.Results[0].HandleClickedLink(EventDetails{}), check.Equals, nil)
+	ch.Assert(CompleteCampaign(first.Id, first.UserId), check.Equals, nil)
+
+	second := s.createCampaignDependencies(ch)
+	second.Name = "Second Campaign"
+	ch.Assert(PostCampaign(&second, second.UserId), check.Equals, nil)
+	for _, r := range second.Results {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+
+	_, err := CompareCampaigns([]int64{first.Id}, false, first.UserId)
+	ch.Assert(err, check.Equals, ErrComparisonCampaigns)
+
+	cc, err := CompareCampaigns([]int64{first.Id, second.Id}, false, first.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(cc.Reference, check.Equals, first.Id)
+	ch.Assert(len(cc.Campaigns), check.Equals, 2)
+	ch.Assert(len(cc.Comparisons), check.Equals, len(comparisonMetrics))
+	total := float64(len(first.Results))
+	ch.Assert(cc.Campaigns[0].Rates["clicked"], check.Equals, 1/total)
+	ch.Assert(cc.Comparisons[1].Metric, check.Equals, "clicked")
+	ch.Assert(cc.Comparisons[1].Difference, check.Equals, -1/total)
+
+	// The completed campaign is the baseline for the second one
+	cc, err = CompareCampaigns([]int64{second.Id}, true, first.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(cc.Reference, check.Equals, int64(0))
+	ch.Assert(cc.Baseline.Stats.ClickedLink, check.Equals, int64(1))
+	ch.Assert(cc.Comparisons[1].CampaignId, check.Equals, second.Id)
+}
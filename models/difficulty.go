@@ -0,0 +1,171 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gophish/gophish/config"
+)
+
+// The premise alignment of a template, which is how well it fits the
+// recipients' work and circumstances
+const (
+	PremiseLow    = "low"
+	PremiseMedium = "medium"
+	PremiseHigh   = "high"
+)
+
+// The difficulty ratings of the NIST Phish Scale, from the easiest emails to
+// detect to the hardest
+const (
+	DifficultyLeast           = "least"
+	DifficultyModerateToLeast = "moderate_to_least"
+	DifficultyModerate        = "moderate"
+	DifficultyVery            = "very"
+)
+
+// ErrInvalidPremiseAlignment is thrown when a template's premise alignment
+// isn't low, medium, or high
+var ErrInvalidPremiseAlignment = errors.New("The premise alignment must be low, medium, or high")
+
+// ErrInvalidCueCount is thrown when a template's cue count is negative
+var ErrInvalidCueCount = errors.New("The cue count can't be negative")
+
+// difficultyRatings maps the cue category, from few to many cues, and the
+// premise alignment to the difficulty rating.
+var difficultyRatings = [3]map[string]string{
+	{PremiseHigh: DifficultyVery, PremiseMedium: DifficultyVery, PremiseLow: DifficultyModerate},
+	{PremiseHigh: DifficultyVery, PremiseMedium: DifficultyModerate, PremiseLow: DifficultyModerateToLeast},
+	{PremiseHigh: DifficultyModerate, PremiseMedium: DifficultyModerateToLeast, PremiseLow: DifficultyLeast},
+}
+
+// rateDifficulty returns the difficulty of detecting an email with the given
+// number of cues and premise alignment. Emails which haven't been rated have
+// no difficulty.
+func rateDifficulty(cues int, premise string) string {
+	if cues == 0 || premise == "" {
+		return ""
+	}
+	few, many := config.DefaultFewCues, config.DefaultManyCues
+	if conf != nil {
+		few, many = conf.PhishScale.Thresholds()
+	}
+	category := 1
+	switch {
+	case cues <= few:
+		category = 0
+	case cues >= many:
+		category = 2
+	}
+	return difficultyRatings[category][premise]
+}
+
+// validateDifficulty checks the template's difficulty metadata, normalizing
+// the premise alignment and setting its difficulty.
+func (t *Template) validateDifficulty() error {
+	t.PremiseAlignment = strings.ToLower(strings.TrimSpace(t.PremiseAlignment))
+	switch t.PremiseAlignment {
+	case "", PremiseLow, PremiseMedium, PremiseHigh:
+	default:
+		return ErrInvalidPremiseAlignment
+	}
+	if t.CueCount < 0 {
+		return ErrInvalidCueCount
+	}
+	t.Difficulty = rateDifficulty(t.CueCount, t.PremiseAlignment)
+	return nil
+}
+
+// AfterFind sets the difficulty of templates loaded from the database.
+func (t *Template) AfterFind() error {
+	t.Difficulty = rateDifficulty(t.CueCount, t.PremiseAlignment)
+	return nil
+}
+
+// DifficultyStats are the combined statistics of the campaigns sent using
+// templates with the same difficulty.
+type DifficultyStats struct {
+	Difficulty    string  `json:"difficulty"`
+	Campaigns     int     `json:"campaigns"`
+	EmailsSent    int64   `json:"sent"`
+	ClickedLink   int64   `json:"clicked"`
+	SubmittedData int64   `json:"submitted_data"`
+	EmailReported int64   `json:"email_reported"`
+	ClickRate     float64 `json:"click_rate"`
+}
+
+// CampaignDifficulty is the click rate of a campaign, normalized by the
+// difficulty of its template. The normalized click rate is the campaign's
+// click rate divided by that of every campaign with the same difficulty, so
+// that 1 is typical for emails that difficult to detect.
+type CampaignDifficulty struct {
+	Id                  int64   `json:"id"`
+	Name                string  `json:"name"`
+	TemplateId          int64   `json:"template_id"`
+	Difficulty          string  `json:"difficulty"`
+	EmailsSent          int64   `json:"sent"`
+	ClickRate           float64 `json:"click_rate"`
+	NormalizedClickRate float64 `json:"normalized_click_rate"`
+}
+
+// DifficultyReport compares the click rates of campaigns while accounting for
+// how difficult their emails were to detect. Campaigns using templates which
+// haven't been rated are left out.
+type DifficultyReport struct {
+	Difficulties []DifficultyStats    `json:"difficulties"`
+	Campaigns    []CampaignDifficulty `json:"campaigns"`
+}
+
+// GetDifficultyReport returns the difficulty report for the campaigns owned
+// by the given user. The difficulty of each campaign is that of its template
+// when the report is generated.
+func GetDifficultyReport(uid int64) (DifficultyReport, error) {
+	report := DifficultyReport{Difficulties: []DifficultyStats{}, Campaigns: []CampaignDifficulty{}}
+	cs := []Campaign{}
+	err := reportingDB().Table("campaigns").Select("id, name, template_id").Where("user_id=?", uid).Order("id asc").Find(&cs).Error
+	if err != nil {
+		return report, err
+	}
+	stats := map[string]*DifficultyStats{}
+	for _, c := range cs {
+		t := Template{}
+		err = reportingDB().Where("id=?", c.TemplateId).First(&t).Error
+		if err != nil || t.Difficulty == "" {
+			continue
+		}
+		s, err := getCampaignStats(c.Id)
+		if err != nil {
+			return report, err
+		}
+		ds, ok := stats[t.Difficulty]
+		if !ok {
+			ds = &DifficultyStats{Difficulty: t.Difficulty}
+			stats[t.Difficulty] = ds
+		}
+		ds.Campaigns++
+		ds.EmailsSent += s.EmailsSent
+		ds.ClickedLink += s.ClickedLink
+		ds.SubmittedData += s.SubmittedData
+		ds.EmailReported += s.EmailReported
+		report.Campaigns = append(report.Campaigns, CampaignDifficulty{
+			Id:         c.Id,
+			Name:       c.Name,
+			TemplateId: c.TemplateId,
+			Difficulty: t.Difficulty,
+			EmailsSent: s.EmailsSent,
+			ClickRate:  rate(s.ClickedLink, s.EmailsSent),
+		})
+	}
+	for _, d := range []string{DifficultyLeast, DifficultyModerateToLeast, DifficultyModerate, DifficultyVery} {
+		if ds, ok := stats[d]; ok {
+			ds.ClickRate = rate(ds.ClickedLink, ds.EmailsSent)
+			report.Difficulties = append(report.Difficulties, *ds)
+		}
+	}
+	for i, c := range report.Campaigns {
+		if typical := stats[c.Difficulty].ClickRate; typical > 0 {
+			report.Campaigns[i].NormalizedClickRate = c.ClickRate / typical
+		}
+	}
+	return report, nil
+}
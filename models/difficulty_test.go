@@ -0,0 +1,87 @@
+package models
+
+import (
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestRateDifficulty(ch *check.C) {
+	tests := []struct {
+		cues     int
+		premise  string
+		expected string
+	}{
+		{cues: 0, premise: PremiseHigh, expected: ""},
+		{cues: 5, premise: "", expected: ""},
+		{cues: 8, premise: PremiseHigh, expected: DifficultyVery},
+		{cues: 8, premise: PremiseLow, expected: DifficultyModerate},
+		{cues: 9, premise: PremiseMedium, expected: DifficultyModerate},
+		{cues: 14, premise: PremiseLow, expected: DifficultyModerateToLeast},
+		{cues: 15, premise: PremiseHigh, expected: DifficultyModerate},
+		{cues: 20, premise: PremiseLow, expected: DifficultyLeast},
+	}
+	for _, tc := range tests {
+		ch.Assert(rateDifficulty(tc.cues, tc.premise), check.Equals, tc.expected, check.Commentf("%d cues, %q premise", tc.cues, tc.premise))
+	}
+}
+
+func (s *ModelsSuite) TestTemplateDifficulty(ch *check.C) {
+	t := Template{Name: "Rated Template", Text: "Text", UserId: 1, CueCount: 3, PremiseAlignment: " High "}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	ch.Assert(t.PremiseAlignment, check.Equals, PremiseHigh)
+	ch.Assert(t.Difficulty, check.Equals, DifficultyVery)
+
+	found, err := GetTemplate(t.Id, t.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(found.Difficulty, check.Equals, DifficultyVery)
+
+	t.PremiseAlignment = "certain"
+	ch.Assert(PutTemplate(&t), check.Equals, ErrInvalidPremiseAlignment)
+	t.PremiseAlignment = PremiseLow
+	t.CueCount = -1
+	ch.Assert(PutTemplate(&t), check.Equals, ErrInvalidCueCount)
+}
+
+func (s *ModelsSuite) TestGetDifficultyReport(ch *check.C) {
+	rated := s.createCampaignDependencies(ch)
+	rated.Template.Name = "Very Difficult Template"
+	rated.Template.CueCount = 2
+	rated.Template.PremiseAlignment = PremiseHigh
+	ch.Assert(PutTemplate(&rated.Template), check.Equals, nil)
+	ch.Assert(PostCampaign(&rated, rated.UserId), check.Equals, nil)
+	for _, r := range rated.Results {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+	ch.Assert(rated.Results[0].HandleClickedLink(EventDetails{}), check.Equals, nil)
+
+	second := s.createCampaignDependencies(ch)
+	second.Name = "Second Campaign"
+	second.Template = rated.Template
+	ch.Assert(PostCampaign(&second, second.UserId), check.Equals, nil)
+	for _, r := range second.Results {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+	for _, r := range second.Results[:3] {
+		ch.Assert(r.HandleClickedLink(EventDetails{}), check.Equals, nil)
+	}
+
+	// Campaigns using unrated templates are left out
+	unrated := s.createCampaign(ch)
+
+	report, err := GetDifficultyReport(rated.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(report.Difficulties), check.Equals, 1)
+	ds := report.Difficulties[0]
+	ch.Assert(ds.Difficulty, check.Equals, DifficultyVery)
+	ch.Assert(ds.Campaigns, check.Equals, 2)
+	ch.Assert(ds.EmailsSent, check.Equals, int64(8))
+	ch.Assert(ds.ClickedLink, check.Equals, int64(4))
+	ch.Assert(ds.ClickRate, check.Equals, 0.5)
+
+	ch.Assert(len(report.Campaigns), check.Equals, 2)
+	for _, c := range report.Campaigns {
+		ch.Assert(c.Id, check.Not(check.Equals), unrated.Id)
+	}
+	ch.Assert(report.Campaigns[0].ClickRate, check.Equals, 0.25)
+	ch.Assert(report.Campaigns[0].NormalizedClickRate, check.Equals, 0.5)
+	ch.Assert(report.Campaigns[1].NormalizedClickRate, check.Equals, 1.5)
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/gophish/gophish/secrets"
+	"github.com/toorop/go-dkim"
+)
+
+// ErrDKIMIncomplete is thrown when a sending profile has some, but not all,
+// of the settings needed to sign emails with DKIM.
+var ErrDKIMIncomplete = errors.New("DKIM signing needs a domain, a selector and a private key")
+
+// ErrInvalidDKIMKey is thrown when a sending profile's DKIM private key isn't
+// a PEM encoded RSA key.
+var ErrInvalidDKIMKey = errors.New("The DKIM private key must be a PEM encoded RSA key")
+
+// dkimHeaders are the headers covered by the DKIM signature, if they're in
+// the email.
+var dkimHeaders = []string{"from", "to", "cc", "subject", "date", "message-id", "reply-to", "mime-version", "content-type"}
+
+// parseDKIMKey parses a PEM encoded PKCS #1 or PKCS #8 RSA private key.
+func parseDKIMKey(key string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, ErrInvalidDKIMKey
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidDKIMKey
+	}
+	rk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidDKIMKey
+	}
+	return rk, nil
+}
+
+// validateDKIM checks the sending profile's DKIM settings. The private key
+// may reference a secret, which the profile's owner must be allowed to use.
+func (s *SMTP) validateDKIM() error {
+	if s.DKIMDomain == "" && s.DKIMSelector == "" && s.DKIMPrivateKey == "" {
+		return nil
+	}
+	if s.DKIMDomain == "" || s.DKIMSelector == "" || s.DKIMPrivateKey == "" {
+		return ErrDKIMIncomplete
+	}
+	if secrets.IsReference(string(s.DKIMPrivateKey)) {
+		return validateSecret(string(s.DKIMPrivateKey), s.UserId)
+	}
+	_, err := parseDKIMKey(string(s.DKIMPrivateKey))
+	return err
+}
+
+// signMessage signs the message with the sending profile's DKIM key, if it
+// has one. The key is resolved each time, so that rotated keys are picked
+// up. This must be the last change made to the message.
+func (s *SMTP) signMessage(raw []byte) ([]byte, error) {
+	if s.DKIMPrivateKey == "" {
+		return raw, nil
+	}
+	key, err := ResolveSecret(string(s.DKIMPrivateKey), s.UserId)
+	if err != nil {
+		return nil, err
+	}
+	// The key is checked first, since the signer assumes PKCS #8 keys are
+	// RSA keys
+	_, err = parseDKIMKey(key)
+	if err != nil {
+		return nil, err
+	}
+	opts := dkim.NewSigOptions()
+	opts.PrivateKey = []byte(key)
+	opts.Domain = s.DKIMDomain
+	opts.Selector = s.DKIMSelector
+	opts.Canonicalization = "relaxed/relaxed"
+	opts.Headers = append([]string{}, dkimHeaders...)
+	err = dkim.Sign(&raw, opts)
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,474 @@
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/deliverability"
+	"github.com/gophish/gophish/dnsprovider"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/reputation"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// The DNS statuses of a domain
+const (
+	DomainDNSUnknown = "unknown"
+	DomainResolves   = "resolves"
+	// DomainNoRecords is the status of domains which exist, but don't have
+	// any address records.
+	DomainNoRecords = "no_records"
+	DomainNotFound  = "not_found"
+	DomainDNSError  = "error"
+)
+
+// DomainCheckTimeout limits how long the checks of a single domain can take.
+const DomainCheckTimeout = time.Minute
+
+// DomainReputationChecker looks up the reputation of the domains in the
+// inventory. If it's nil, only the DNS, blocklist, and certificate checks are
+// made.
+var DomainReputationChecker reputation.Checker
+
+// fetchCertificate returns the certificate served for the domain over HTTPS,
+// along with whether it's trusted.
+var fetchCertificate = func(domain string) (*x509.Certificate, bool, error) {
+	d := &net.Dialer{Timeout: DomainCheckTimeout / 4}
+	// The certificate is verified below, so that the details of untrusted
+	// certificates, such as expired ones, can still be shown.
+	conn, err := tls.DialWithDialer(d, "tcp", net.JoinHostPort(domain, "443"), &tls.Config{
+		ServerName:         domain,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, false, errors.New("no certificate was served")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{DNSName: domain, Intermediates: intermediates})
+	return certs[0], err == nil, nil
+}
+
+// domainName matches a domain name, which needs at least two labels.
+var domainName = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9][a-z0-9-]{0,61}[a-z0-9]$`)
+
+// ErrInvalidDomain is thrown when a domain in the inventory isn't a valid
+// domain name.
+var ErrInvalidDomain = errors.New("Invalid domain name")
+
+// ErrDomainExists is thrown when a domain is already in the user's
+// inventory.
+var ErrDomainExists = errors.New("The domain is already in the inventory")
+
+// Domain is a domain used to send campaign emails or host landing pages. The
+// registration details are entered by the user, while the rest is found by
+// the periodic checks of the domain.
+type Domain struct {
+	Id         int64     `json:"id"`
+	UserId     int64     `json:"-"`
+	Name       string    `json:"name"`
+	Registrar  string    `json:"registrar"`
+	ExpiryDate time.Time `json:"expiry_date"`
+	Notes      string    `json:"notes"`
+	// DNSStatus is whether the domain resolves, and DeliverabilityScore is
+	// the score of its SPF, DKIM, and DMARC records, from 0 to 100.
+	DNSStatus           string `json:"dns_status"`
+	DeliverabilityScore int    `json:"deliverability_score"`
+	// Blocklisted is whether the domain is listed by a domain blocklist,
+	// such as the Spamhaus DBL.
+	Blocklisted bool `json:"blocklisted"`
+	// Categories are the comma separated categories vendors have given the
+	// domain, and Malicious and Suspicious are the number which detected it.
+	Categories string `json:"categories"`
+	Malicious  int    `json:"malicious"`
+	Suspicious int    `json:"suspicious"`
+	// Burned is whether the domain has been blocklisted or detected as
+	// malicious, which is likely to block campaigns using it.
+	Burned     bool      `json:"burned"`
+	BurnedDate time.Time `json:"burned_date"`
+	// The certificate served for the domain over HTTPS
+	CertificateIssuer string    `json:"certificate_issuer"`
+	CertificateExpiry time.Time `json:"certificate_expiry"`
+	CertificateValid  bool      `json:"certificate_valid"`
+	// CheckError lists the checks which couldn't be made.
+	CheckError      string    `json:"check_error"`
+	LastCheckedDate time.Time `json:"last_checked_date"`
+	CreatedDate     time.Time `json:"created_date"`
+	ModifiedDate    time.Time `json:"modified_date"`
+	// DNSProvider is the provider used to create the domain's DNS records
+	// in the DNSZone, which defaults to the domain. The records point to
+	// the IP address and to the host of the sending profile given by the
+	// SMTPId, along with the DKIM key and DMARC policy.
+	DNSProvider   string `json:"dns_provider"`
+	DNSZone       string `json:"dns_zone"`
+	IP            string `json:"ip"`
+	SMTPId        int64  `json:"smtp_id" gorm:"column:smtp_id"`
+	DKIMSelector  string `json:"dkim_selector"`
+	DKIMPublicKey string `json:"dkim_public_key"`
+	DMARCPolicy   string `json:"dmarc_policy"`
+	// DNSRecords are the records which were created, so that they can be
+	// removed.
+	DNSRecords         []dnsprovider.Record `json:"dns_records" gorm:"-"`
+	RawDNSRecords      string               `json:"-" gorm:"column:dns_records"`
+	DNSProvisionedDate time.Time            `json:"dns_provisioned_date"`
+	DNSError           string               `json:"dns_error"`
+	// SendingProfiles and Campaigns are the sending profiles sending from
+	// the domain, and the active campaigns using it for their emails or
+	// landing pages.
+	SendingProfiles []DomainUsage `json:"sending_profiles" gorm:"-"`
+	Campaigns       []DomainUsage `json:"campaigns" gorm:"-"`
+}
+
+// DomainUsage is a sending profile or campaign using a domain.
+type DomainUsage struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// DomainBurnedAlert is sent to the webhooks when a domain is burned, along
+// with the active campaigns using it.
+type DomainBurnedAlert struct {
+	DomainId    int64         `json:"domain_id"`
+	Domain      string        `json:"domain"`
+	Blocklisted bool          `json:"blocklisted"`
+	Malicious   int           `json:"malicious"`
+	Categories  string        `json:"categories"`
+	Campaigns   []DomainUsage `json:"campaigns"`
+	Time        time.Time     `json:"time"`
+}
+
+// WebhookType returns the type of the payload sent to webhooks.
+func (DomainBurnedAlert) WebhookType() string {
+	return "domain_burned"
+}
+
+// Validate normalizes the domain name and ensures it's valid.
+func (d *Domain) Validate() error {
+	d.Name = strings.TrimSuffix(strings.ToLower(asciiDomain(strings.TrimSpace(d.Name))), ".")
+	if !domainName.MatchString(d.Name) {
+		return ErrInvalidDomain
+	}
+	var count int
+	err := db.Model(&Domain{}).Where("user_id=? and name=? and id<>?", d.UserId, d.Name, d.Id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDomainExists
+	}
+	return d.validateDNS()
+}
+
+// matchesDomain returns whether the host is the domain, or a subdomain of
+// it.
+func matchesDomain(host string, domain string) bool {
+	host = strings.TrimSuffix(strings.ToLower(asciiDomain(host)), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// addressDomain returns the domain of an email address, which may include a
+// display name.
+func addressDomain(address string) string {
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		address = strings.TrimSuffix(address[i+1:], ">")
+	}
+	return address
+}
+
+// getUsage loads the sending profiles and active campaigns using the domain.
+func (d *Domain) getUsage() error {
+	d.SendingProfiles = []DomainUsage{}
+	d.Campaigns = []DomainUsage{}
+	ss := []SMTP{}
+	err := db.Select("id, name, from_address").Where("user_id=?", d.UserId).Find(&ss).Error
+	if err != nil {
+		return err
+	}
+	sending := map[int64]bool{}
+	for _, s := range ss {
+		if matchesDomain(addressDomain(s.FromAddress), d.Name) {
+			sending[s.Id] = true
+			d.SendingProfiles = append(d.SendingProfiles, DomainUsage{Id: s.Id, Name: s.Name})
+		}
+	}
+	cs := []Campaign{}
+	err = db.Select("id, name, url, smtp_id").
+		Where("user_id=? and status in (?)", d.UserId, []string{CampaignCreated, CampaignQueued, CampaignInProgress, CampaignEmailsSent}).
+		Order("id asc").Find(&cs).Error
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		u, err := url.Parse(c.URL)
+		if sending[c.SMTPId] || (err == nil && matchesDomain(u.Hostname(), d.Name)) {
+			d.Campaigns = append(d.Campaigns, DomainUsage{Id: c.Id, Name: c.Name})
+		}
+	}
+	return nil
+}
+
+// GetDomains returns the domains in the user's inventory.
+func GetDomains(uid int64) ([]Domain, error) {
+	ds := []Domain{}
+	err := db.Where("user_id=?", uid).Order("name asc").Find(&ds).Error
+	if err != nil {
+		return ds, err
+	}
+	for i := range ds {
+		err = ds[i].getUsage()
+		if err != nil {
+			return ds, err
+		}
+	}
+	return ds, nil
+}
+
+// GetDomain returns the domain with the given id, if it's in the user's
+// inventory.
+func GetDomain(id int64, uid int64) (Domain, error) {
+	d := Domain{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&d).Error
+	if err != nil {
+		return d, err
+	}
+	err = d.getUsage()
+	return d, err
+}
+
+// PostDomain adds a domain to the inventory, creating its DNS records if it
+// has a DNS provider. It's checked by the worker the next time the domains
+// are checked.
+func PostDomain(d *Domain) error {
+	d.Id = 0
+	err := d.Validate()
+	if err != nil {
+		return err
+	}
+	d.DNSStatus = DomainDNSUnknown
+	d.DNSRecords = []dnsprovider.Record{}
+	d.DNSProvisionedDate = time.Time{}
+	d.CreatedDate = time.Now().UTC()
+	d.ModifiedDate = d.CreatedDate
+	err = db.Save(d).Error
+	if err != nil {
+		return err
+	}
+	err = d.syncDNS()
+	if err != nil {
+		return err
+	}
+	return d.getUsage()
+}
+
+// PutDomain edits the registration details and DNS settings of a domain,
+// updating its DNS records. The results of its checks are kept, unless the
+// domain is renamed.
+func PutDomain(d *Domain) error {
+	existing, err := GetDomain(d.Id, d.UserId)
+	if err != nil {
+		return err
+	}
+	err = d.Validate()
+	if err != nil {
+		return err
+	}
+	// The records are removed from the old zone when they'll be created
+	// elsewhere, or not at all.
+	if existing.DNSProvisioned() && (existing.Name != d.Name || existing.DNSProvider != d.DNSProvider || existing.zone() != d.zone()) {
+		err = existing.teardownDNS()
+		if err != nil {
+			return err
+		}
+	}
+	if existing.Name != d.Name {
+		existing = Domain{
+			Id:          existing.Id,
+			UserId:      existing.UserId,
+			Name:        d.Name,
+			DNSStatus:   DomainDNSUnknown,
+			DNSRecords:  existing.DNSRecords,
+			CreatedDate: existing.CreatedDate,
+		}
+	}
+	existing.Registrar = d.Registrar
+	existing.ExpiryDate = d.ExpiryDate
+	existing.Notes = d.Notes
+	existing.DNSProvider = d.DNSProvider
+	existing.DNSZone = d.DNSZone
+	existing.IP = d.IP
+	existing.SMTPId = d.SMTPId
+	existing.DKIMSelector = d.DKIMSelector
+	existing.DKIMPublicKey = d.DKIMPublicKey
+	existing.DMARCPolicy = d.DMARCPolicy
+	existing.ModifiedDate = time.Now().UTC()
+	err = db.Save(&existing).Error
+	if err != nil {
+		return err
+	}
+	*d = existing
+	err = d.syncDNS()
+	if err != nil {
+		return err
+	}
+	return d.getUsage()
+}
+
+// DeleteDomain removes a domain from the inventory, along with the DNS
+// records created for it.
+func DeleteDomain(id int64, uid int64) error {
+	d, err := GetDomain(id, uid)
+	if err != nil {
+		return err
+	}
+	if d.DNSProvisioned() {
+		err = d.teardownDNS()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"domain": d.Name,
+			}).Errorf("error removing DNS records: %v", err)
+		}
+	}
+	return db.Where("id=? and user_id=?", id, uid).Delete(&Domain{}).Error
+}
+
+// Check checks the domain's DNS records, blocklistings, reputation and
+// certificate, saving the results. If the domain has been newly burned, the
+// webhooks are notified along with the active campaigns using it.
+func (d *Domain) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, DomainCheckTimeout)
+	defer cancel()
+	wasBurned := d.Burned
+	errs := []string{}
+
+	d.DNSStatus = DomainResolves
+	_, err := PreflightChecker.Resolver.LookupHost(ctx, d.Name)
+	if err != nil {
+		d.DNSStatus = DomainDNSError
+		if de, ok := err.(*net.DNSError); ok && de.IsNotFound {
+			d.DNSStatus = DomainNotFound
+			mxs, mxErr := PreflightChecker.Resolver.LookupMX(ctx, d.Name)
+			if mxErr == nil && len(mxs) > 0 {
+				d.DNSStatus = DomainNoRecords
+			}
+		} else {
+			errs = append(errs, "dns: "+err.Error())
+		}
+	}
+
+	report := PreflightChecker.Check(ctx, d.Name, nil, nil)
+	d.DeliverabilityScore = report.Score
+	d.Blocklisted = false
+	for _, c := range report.Checks {
+		if c.Name == "blocklist" && c.Status == deliverability.StatusFail {
+			d.Blocklisted = true
+		}
+	}
+
+	if DomainReputationChecker != nil {
+		r, err := DomainReputationChecker.Check(ctx, d.Name)
+		if err != nil {
+			errs = append(errs, "reputation: "+err.Error())
+		} else {
+			d.Categories = strings.Join(r.Categories, ",")
+			d.Malicious = r.Malicious
+			d.Suspicious = r.Suspicious
+		}
+	}
+
+	d.CertificateIssuer = ""
+	d.CertificateExpiry = time.Time{}
+	d.CertificateValid = false
+	if d.DNSStatus == DomainResolves {
+		cert, valid, err := fetchCertificate(d.Name)
+		if err != nil {
+			errs = append(errs, "certificate: "+err.Error())
+		} else {
+			d.CertificateIssuer = cert.Issuer.CommonName
+			d.CertificateExpiry = cert.NotAfter.UTC()
+			d.CertificateValid = valid
+		}
+	}
+
+	d.Burned = d.Blocklisted || d.Malicious > 0
+	d.LastCheckedDate = time.Now().UTC()
+	d.CheckError = strings.Join(errs, "; ")
+	if !d.Burned {
+		d.BurnedDate = time.Time{}
+	} else if !wasBurned {
+		d.BurnedDate = d.LastCheckedDate
+	}
+	err = db.Model(d).Updates(map[string]interface{}{
+		"dns_status":           d.DNSStatus,
+		"deliverability_score": d.DeliverabilityScore,
+		"blocklisted":          d.Blocklisted,
+		"categories":           d.Categories,
+		"malicious":            d.Malicious,
+		"suspicious":           d.Suspicious,
+		"burned":               d.Burned,
+		"burned_date":          d.BurnedDate,
+		"certificate_issuer":   d.CertificateIssuer,
+		"certificate_expiry":   d.CertificateExpiry,
+		"certificate_valid":    d.CertificateValid,
+		"check_error":          d.CheckError,
+		"last_checked_date":    d.LastCheckedDate,
+	}).Error
+	if err != nil {
+		return err
+	}
+	err = d.getUsage()
+	if err != nil {
+		return err
+	}
+	if d.Burned && !wasBurned {
+		log.WithFields(logrus.Fields{
+			"domain":    d.Name,
+			"campaigns": len(d.Campaigns),
+		}).Warn("domain has been burned")
+		sendWebhooks(DomainBurnedAlert{
+			DomainId:    d.Id,
+			Domain:      d.Name,
+			Blocklisted: d.Blocklisted,
+			Malicious:   d.Malicious,
+			Categories:  d.Categories,
+			Campaigns:   d.Campaigns,
+			Time:        d.LastCheckedDate,
+		})
+	}
+	return nil
+}
+
+// CheckDomains checks each domain in the inventory.
+func CheckDomains(ctx context.Context) error {
+	ds := []Domain{}
+	err := db.Find(&ds).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	for i := range ds {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err = ds[i].Check(ctx)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"domain": ds[i].Name,
+			}).Error(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/dnsprovider"
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// The DMARC policies a domain can publish
+const (
+	DMARCNone       = "none"
+	DMARCQuarantine = "quarantine"
+	DMARCReject     = "reject"
+)
+
+// DNSProviders are the configured DNS providers, by name, used to create the
+// DNS records of the domains in the inventory.
+var DNSProviders = map[string]dnsprovider.Provider{}
+
+// ErrUnknownDNSProvider is thrown when a domain uses a DNS provider which
+// isn't configured.
+var ErrUnknownDNSProvider = errors.New("The DNS provider isn't configured")
+
+// ErrInvalidDomainIP is thrown when a domain's IP address isn't valid.
+var ErrInvalidDomainIP = errors.New("Invalid IP address")
+
+// ErrInvalidDMARCPolicy is thrown when a domain's DMARC policy isn't one of
+// none, quarantine, or reject.
+var ErrInvalidDMARCPolicy = errors.New("The DMARC policy must be none, quarantine, or reject")
+
+// ErrInvalidDNSZone is thrown when the domain isn't in its DNS zone.
+var ErrInvalidDNSZone = errors.New("The domain must be in its DNS zone")
+
+// ErrDKIMSelectorRequired is thrown when a domain has a DKIM key without a
+// selector.
+var ErrDKIMSelectorRequired = errors.New("A DKIM selector is required to publish a DKIM key")
+
+// BeforeSave encodes the DNS records for storing.
+func (d *Domain) BeforeSave() error {
+	if d.DNSRecords == nil {
+		d.DNSRecords = []dnsprovider.Record{}
+	}
+	b, err := json.Marshal(d.DNSRecords)
+	if err != nil {
+		return err
+	}
+	d.RawDNSRecords = string(b)
+	return nil
+}
+
+// AfterFind decodes the stored DNS records.
+func (d *Domain) AfterFind() error {
+	d.DNSRecords = []dnsprovider.Record{}
+	if d.RawDNSRecords == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(d.RawDNSRecords), &d.DNSRecords)
+}
+
+// validateDNS normalizes the domain's DNS settings and ensures they're valid.
+func (d *Domain) validateDNS() error {
+	d.DNSProvider = strings.ToLower(strings.TrimSpace(d.DNSProvider))
+	d.DNSZone = strings.TrimSuffix(strings.ToLower(asciiDomain(strings.TrimSpace(d.DNSZone))), ".")
+	d.IP = strings.TrimSpace(d.IP)
+	d.DKIMSelector = strings.ToLower(strings.TrimSpace(d.DKIMSelector))
+	d.DKIMPublicKey = strings.Join(strings.Fields(d.DKIMPublicKey), "")
+	d.DMARCPolicy = strings.ToLower(strings.TrimSpace(d.DMARCPolicy))
+	if d.DNSProvider == "" {
+		return nil
+	}
+	if _, ok := DNSProviders[d.DNSProvider]; !ok {
+		return ErrUnknownDNSProvider
+	}
+	if d.DNSZone != "" && !matchesDomain(d.Name, d.DNSZone) {
+		return ErrInvalidDNSZone
+	}
+	if d.IP != "" && net.ParseIP(d.IP) == nil {
+		return ErrInvalidDomainIP
+	}
+	if d.DKIMPublicKey != "" && d.DKIMSelector == "" {
+		return ErrDKIMSelectorRequired
+	}
+	switch d.DMARCPolicy {
+	case "":
+		d.DMARCPolicy = DMARCNone
+	case DMARCNone, DMARCQuarantine, DMARCReject:
+	default:
+		return ErrInvalidDMARCPolicy
+	}
+	if d.SMTPId != 0 {
+		_, err := GetSMTP(d.SMTPId, d.UserId)
+		if err != nil {
+			return ErrSMTPNotFound
+		}
+	}
+	return nil
+}
+
+// zone returns the DNS zone the domain's records are created in.
+func (d *Domain) zone() string {
+	if d.DNSZone != "" {
+		return d.DNSZone
+	}
+	return d.Name
+}
+
+// DNSProvisioned returns whether records have been created for the domain.
+func (d *Domain) DNSProvisioned() bool {
+	return len(d.DNSRecords) > 0
+}
+
+// DNSPlan returns the records the domain should have. The A or AAAA record
+// points to the domain's IP address, while the MX and SPF records allow the
+// host of its sending profile to send the domain's emails.
+func (d *Domain) DNSPlan() ([]dnsprovider.Record, error) {
+	records := []dnsprovider.Record{}
+	ip := net.ParseIP(d.IP)
+	if ip != nil {
+		typ := "A"
+		if ip.To4() == nil {
+			typ = "AAAA"
+		}
+		records = append(records, dnsprovider.Record{Type: typ, Name: d.Name, Value: ip.String()})
+	}
+	if d.SMTPId != 0 {
+		s, err := GetSMTP(d.SMTPId, d.UserId)
+		if err != nil {
+			return records, err
+		}
+		host := s.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.ToLower(host), ".")
+		spf := []string{"v=spf1"}
+		if ip != nil {
+			if ip.To4() != nil {
+				spf = append(spf, "ip4:"+ip.String())
+			} else {
+				spf = append(spf, "ip6:"+ip.String())
+			}
+		}
+		// Sending profiles can use an IP address as their host, which can't
+		// be an MX record.
+		if hostIP := net.ParseIP(host); hostIP != nil {
+			if hostIP.To4() != nil {
+				spf = append(spf, "ip4:"+hostIP.String())
+			} else {
+				spf = append(spf, "ip6:"+hostIP.String())
+			}
+		} else {
+			records = append(records, dnsprovider.Record{Type: "MX", Name: d.Name, Value: host, Priority: 10})
+			spf = append(spf, "a:"+host)
+		}
+		spf = append(spf, "~all")
+		records = append(records, dnsprovider.Record{Type: "TXT", Name: d.Name, Value: strings.Join(spf, " ")})
+	}
+	if d.DKIMPublicKey != "" {
+		records = append(records, dnsprovider.Record{
+			Type:  "TXT",
+			Name:  d.DKIMSelector + "._domainkey." + d.Name,
+			Value: "v=DKIM1; k=rsa; p=" + d.DKIMPublicKey,
+		})
+	}
+	policy := d.DMARCPolicy
+	if policy == "" {
+		policy = DMARCNone
+	}
+	records = append(records, dnsprovider.Record{Type: "TXT", Name: "_dmarc." + d.Name, Value: "v=DMARC1; p=" + policy})
+	for i := range records {
+		records[i].TTL = dnsprovider.DefaultTTL
+	}
+	return records, nil
+}
+
+// ProvisionDNS creates the domain's records using its DNS provider. Records
+// created previously which are no longer planned, such as the MX record of a
+// removed sending profile, are removed.
+func (d *Domain) ProvisionDNS(ctx context.Context) error {
+	p, ok := DNSProviders[d.DNSProvider]
+	if !ok {
+		return ErrUnknownDNSProvider
+	}
+	records, err := d.DNSPlan()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, DomainCheckTimeout)
+	defer cancel()
+	err = p.Upsert(ctx, d.zone(), records)
+	if err != nil {
+		return err
+	}
+	stale := []dnsprovider.Record{}
+	for _, old := range d.DNSRecords {
+		replaced := false
+		for _, r := range records {
+			if r.Replaces(old) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			stale = append(stale, old)
+		}
+	}
+	if len(stale) > 0 {
+		err = p.Delete(ctx, d.zone(), stale)
+		if err != nil {
+			return err
+		}
+	}
+	d.DNSRecords = records
+	d.DNSProvisionedDate = time.Now().UTC()
+	d.DNSError = ""
+	return d.saveDNS()
+}
+
+// TeardownDNS removes the records created for the domain.
+func (d *Domain) TeardownDNS(ctx context.Context) error {
+	if !d.DNSProvisioned() {
+		return nil
+	}
+	p, ok := DNSProviders[d.DNSProvider]
+	if !ok {
+		return ErrUnknownDNSProvider
+	}
+	ctx, cancel := context.WithTimeout(ctx, DomainCheckTimeout)
+	defer cancel()
+	err := p.Delete(ctx, d.zone(), d.DNSRecords)
+	if err != nil {
+		return err
+	}
+	d.DNSRecords = []dnsprovider.Record{}
+	d.DNSProvisionedDate = time.Time{}
+	d.DNSError = ""
+	return d.saveDNS()
+}
+
+// teardownDNS removes the domain's records, saving the error if they can't
+// be removed.
+func (d *Domain) teardownDNS() error {
+	err := d.TeardownDNS(context.Background())
+	if err != nil {
+		d.DNSError = err.Error()
+		if saveErr := d.saveDNS(); saveErr != nil {
+			return saveErr
+		}
+	}
+	return err
+}
+
+// syncDNS creates the records of domains which have a DNS provider. Since
+// the domain is saved either way, errors from the provider are saved with the
+// domain rather than returned, so that provisioning can be retried.
+func (d *Domain) syncDNS() error {
+	if d.DNSProvider == "" {
+		return nil
+	}
+	err := d.ProvisionDNS(context.Background())
+	if err == nil {
+		return nil
+	}
+	log.WithFields(logrus.Fields{
+		"domain":   d.Name,
+		"provider": d.DNSProvider,
+	}).Errorf("error provisioning DNS records: %v", err)
+	d.DNSError = err.Error()
+	return d.saveDNS()
+}
+
+// saveDNS saves the records created for the domain.
+func (d *Domain) saveDNS() error {
+	err := d.BeforeSave()
+	if err != nil {
+		return err
+	}
+	// The table is updated directly, since the dns_records column would
+	// otherwise be mistaken for the decoded records.
+	return db.Table("domains").Where("id=?", d.Id).Updates(map[string]interface{}{
+		"dns_records":          d.RawDNSRecords,
+		"dns_provisioned_date": d.DNSProvisionedDate,
+		"dns_error":            d.DNSError,
+	}).Error
+}
+
+// teardownCampaignDomains removes the DNS records of the domains used by an
+// archived campaign, unless they're still used by an active campaign.
+func teardownCampaignDomains(cid int64) error {
+	c := Campaign{}
+	err := db.Select("id, user_id, url, smtp_id").Where("id=?", cid).First(&c).Error
+	if err != nil {
+		return err
+	}
+	from := ""
+	s := SMTP{}
+	if db.Select("from_address").Where("id=?", c.SMTPId).First(&s).Error == nil {
+		from = s.FromAddress
+	}
+	ds := []Domain{}
+	err = db.Where("user_id=? and dns_provider<>''", c.UserId).Find(&ds).Error
+	if err != nil {
+		return err
+	}
+	for i := range ds {
+		d := &ds[i]
+		u, urlErr := url.Parse(c.URL)
+		usedBy := matchesDomain(addressDomain(from), d.Name) || (urlErr == nil && matchesDomain(u.Hostname(), d.Name))
+		if !usedBy || !d.DNSProvisioned() {
+			continue
+		}
+		err = d.getUsage()
+		if err != nil {
+			return err
+		}
+		if len(d.Campaigns) > 0 {
+			continue
+		}
+		err = d.teardownDNS()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"domain":      d.Name,
+				"campaign_id": cid,
+			}).Errorf("error removing DNS records: %v", err)
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"domain":      d.Name,
+			"campaign_id": cid,
+		}).Info("Removed the DNS records of an archived campaign's domain")
+	}
+	return nil
+}
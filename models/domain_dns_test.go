@@ -0,0 +1,169 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gophish/gophish/dnsprovider"
+	check "gopkg.in/check.v1"
+)
+
+// fakeDNSProvider is a dnsprovider.Provider which keeps the records of each
+// zone in memory, failing with err if it's set.
+type fakeDNSProvider struct {
+	zones map[string][]dnsprovider.Record
+	err   error
+}
+
+func (p *fakeDNSProvider) Upsert(ctx context.Context, zone string, records []dnsprovider.Record) error {
+	if p.err != nil {
+		return p.err
+	}
+	for _, r := range records {
+		kept := []dnsprovider.Record{}
+		for _, existing := range p.zones[zone] {
+			if !r.Replaces(existing) {
+				kept = append(kept, existing)
+			}
+		}
+		p.zones[zone] = append(kept, r)
+	}
+	return nil
+}
+
+func (p *fakeDNSProvider) Delete(ctx context.Context, zone string, records []dnsprovider.Record) error {
+	if p.err != nil {
+		return p.err
+	}
+	for _, r := range records {
+		kept := []dnsprovider.Record{}
+		for _, existing := range p.zones[zone] {
+			if existing != r {
+				kept = append(kept, existing)
+			}
+		}
+		p.zones[zone] = kept
+	}
+	return nil
+}
+
+// useFakeDNSProvider configures a fake DNS provider named "fake", which is
+// removed once the test is torn down.
+func useFakeDNSProvider() *fakeDNSProvider {
+	p := &fakeDNSProvider{zones: map[string][]dnsprovider.Record{}}
+	DNSProviders = map[string]dnsprovider.Provider{"fake": p}
+	return p
+}
+
+func (s *ModelsSuite) TestDomainDNSValidation(ch *check.C) {
+	useFakeDNSProvider()
+
+	d := Domain{UserId: 1, Name: "example.com", DNSProvider: "unknown"}
+	ch.Assert(PostDomain(&d), check.Equals, ErrUnknownDNSProvider)
+	d.DNSProvider = "Fake"
+	d.IP = "not an ip"
+	ch.Assert(PostDomain(&d), check.Equals, ErrInvalidDomainIP)
+	d.IP = "192.0.2.1"
+	d.DNSZone = "example.org"
+	ch.Assert(PostDomain(&d), check.Equals, ErrInvalidDNSZone)
+	d.DNSZone = ""
+	d.DMARCPolicy = "monitor"
+	ch.Assert(PostDomain(&d), check.Equals, ErrInvalidDMARCPolicy)
+	d.DMARCPolicy = ""
+	d.DKIMPublicKey = "MIGf"
+	ch.Assert(PostDomain(&d), check.Equals, ErrDKIMSelectorRequired)
+	d.DKIMSelector = "s1"
+	d.SMTPId = 1000
+	ch.Assert(PostDomain(&d), check.Equals, ErrSMTPNotFound)
+	d.SMTPId = 0
+	ch.Assert(PostDomain(&d), check.Equals, nil)
+	ch.Assert(d.DNSProvider, check.Equals, "fake")
+	ch.Assert(d.DMARCPolicy, check.Equals, DMARCNone)
+}
+
+func (s *ModelsSuite) TestDomainDNSProvisioning(ch *check.C) {
+	p := useFakeDNSProvider()
+
+	// The test sending profile's host is example.com
+	c := s.createCampaign(ch)
+	d := Domain{
+		UserId:        c.UserId,
+		Name:          "test.com",
+		DNSProvider:   "fake",
+		IP:            "192.0.2.1",
+		SMTPId:        c.SMTPId,
+		DKIMSelector:  "s1",
+		DKIMPublicKey: "MIGf MA0G",
+		DMARCPolicy:   DMARCQuarantine,
+	}
+	ch.Assert(PostDomain(&d), check.Equals, nil)
+	expected := []dnsprovider.Record{
+		{Type: "A", Name: "test.com", Value: "192.0.2.1", TTL: dnsprovider.DefaultTTL},
+		{Type: "MX", Name: "test.com", Value: "example.com", Priority: 10, TTL: dnsprovider.DefaultTTL},
+		{Type: "TXT", Name: "test.com", Value: "v=spf1 ip4:192.0.2.1 a:example.com ~all", TTL: dnsprovider.DefaultTTL},
+		{Type: "TXT", Name: "s1._domainkey.test.com", Value: "v=DKIM1; k=rsa; p=MIGfMA0G", TTL: dnsprovider.DefaultTTL},
+		{Type: "TXT", Name: "_dmarc.test.com", Value: "v=DMARC1; p=quarantine", TTL: dnsprovider.DefaultTTL},
+	}
+	ch.Assert(d.DNSRecords, check.DeepEquals, expected)
+	ch.Assert(p.zones["test.com"], check.DeepEquals, expected)
+	got, err := GetDomain(d.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.DNSRecords, check.DeepEquals, expected)
+	ch.Assert(got.DNSProvisionedDate.IsZero(), check.Equals, false)
+
+	// Records which are no longer needed are removed, while the others are
+	// updated
+	got.IP = ""
+	got.DKIMPublicKey = ""
+	got.DMARCPolicy = DMARCReject
+	ch.Assert(PutDomain(&got), check.Equals, nil)
+	expected = []dnsprovider.Record{
+		{Type: "MX", Name: "test.com", Value: "example.com", Priority: 10, TTL: dnsprovider.DefaultTTL},
+		{Type: "TXT", Name: "test.com", Value: "v=spf1 a:example.com ~all", TTL: dnsprovider.DefaultTTL},
+		{Type: "TXT", Name: "_dmarc.test.com", Value: "v=DMARC1; p=reject", TTL: dnsprovider.DefaultTTL},
+	}
+	ch.Assert(got.DNSRecords, check.DeepEquals, expected)
+	ch.Assert(p.zones["test.com"], check.DeepEquals, expected)
+
+	// Provider errors don't prevent the domain from being saved
+	p.err = errors.New("provider unavailable")
+	got.DMARCPolicy = DMARCNone
+	ch.Assert(PutDomain(&got), check.Equals, nil)
+	got, err = GetDomain(d.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.DNSError, check.Equals, "provider unavailable")
+	ch.Assert(got.DNSRecords, check.DeepEquals, expected)
+	p.err = nil
+
+	// Archiving the campaign removes the records of the domains it used
+	ch.Assert(db.Model(&c).Updates(map[string]interface{}{"status": CampaignComplete, "completed_date": time.Now().UTC()}).Error, check.Equals, nil)
+	ch.Assert(archiveCampaign(c.Id), check.Equals, nil)
+	got, err = GetDomain(d.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.DNSProvisioned(), check.Equals, false)
+	ch.Assert(got.DNSError, check.Equals, "")
+	ch.Assert(len(p.zones["test.com"]), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestDomainDNSActiveCampaign(ch *check.C) {
+	p := useFakeDNSProvider()
+
+	c := s.createCampaign(ch)
+	active := s.createCampaign(ch)
+	d := Domain{UserId: c.UserId, Name: "test.com", DNSProvider: "fake"}
+	ch.Assert(PostDomain(&d), check.Equals, nil)
+	ch.Assert(len(p.zones["test.com"]), check.Equals, 1)
+
+	// The records are kept while another campaign uses the domain
+	ch.Assert(db.Model(&c).Updates(map[string]interface{}{"status": CampaignComplete, "completed_date": time.Now().UTC()}).Error, check.Equals, nil)
+	ch.Assert(archiveCampaign(c.Id), check.Equals, nil)
+	got, err := GetDomain(d.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.DNSProvisioned(), check.Equals, true)
+	ch.Assert(got.Campaigns, check.DeepEquals, []DomainUsage{{Id: active.Id, Name: active.Name}})
+
+	// Deleting the domain removes them
+	ch.Assert(DeleteDomain(d.Id, c.UserId), check.Equals, nil)
+	ch.Assert(len(p.zones["test.com"]), check.Equals, 0)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"time"
+
+	"github.com/gophish/gophish/deliverability"
+	"github.com/gophish/gophish/reputation"
+	check "gopkg.in/check.v1"
+)
+
+// domainResolver is a deliverability.Resolver where only test.com resolves,
+// and it's listed by the blocklist if listed is set.
+type domainResolver struct {
+	emptyResolver
+	listed bool
+}
+
+func (r domainResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if host == "test.com" || (r.listed && host == "test.com.dbl.example.com") {
+		return []string{"127.0.0.2"}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// staticReputation is a reputation.Checker which returns the same report for
+// every domain.
+type staticReputation struct {
+	report reputation.Report
+}
+
+func (sr staticReputation) Check(ctx context.Context, domain string) (*reputation.Report, error) {
+	r := sr.report
+	return &r, nil
+}
+
+func (s *ModelsSuite) TestDomainValidation(ch *check.C) {
+	d := Domain{UserId: 1, Name: "not a domain"}
+	ch.Assert(PostDomain(&d), check.Equals, ErrInvalidDomain)
+	d.Name = "localhost"
+	ch.Assert(PostDomain(&d), check.Equals, ErrInvalidDomain)
+	d.Name = " Example.COM. "
+	ch.Assert(PostDomain(&d), check.Equals, nil)
+	ch.Assert(d.Name, check.Equals, "example.com")
+	ch.Assert(d.DNSStatus, check.Equals, DomainDNSUnknown)
+
+	dup := Domain{UserId: 1, Name: "example.com"}
+	ch.Assert(PostDomain(&dup), check.Equals, ErrDomainExists)
+	dup.UserId = 2
+	ch.Assert(PostDomain(&dup), check.Equals, nil)
+
+	d.Registrar = "Namecheap"
+	ch.Assert(PutDomain(&d), check.Equals, nil)
+	got, err := GetDomain(d.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Registrar, check.Equals, "Namecheap")
+	_, err = GetDomain(d.Id, 2)
+	ch.Assert(err, check.NotNil)
+}
+
+func (s *ModelsSuite) TestDomainCheck(ch *check.C) {
+	origChecker, origReputation, origFetch := PreflightChecker, DomainReputationChecker, fetchCertificate
+	defer func() {
+		PreflightChecker, DomainReputationChecker, fetchCertificate = origChecker, origReputation, origFetch
+	}()
+	resolver := &domainResolver{}
+	PreflightChecker = &deliverability.Checker{Resolver: resolver, DomainBlocklists: []string{"dbl.example.com"}}
+	DomainReputationChecker = staticReputation{reputation.Report{Categories: []string{"business"}}}
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetchCertificate = func(domain string) (*x509.Certificate, bool, error) {
+		return &x509.Certificate{Issuer: pkix.Name{CommonName: "Test CA"}, NotAfter: expiry}, true, nil
+	}
+
+	// The test sending profile sends from test.com
+	c := s.createCampaign(ch)
+	d := Domain{UserId: c.UserId, Name: "test.com"}
+	ch.Assert(PostDomain(&d), check.Equals, nil)
+	ch.Assert(len(d.SendingProfiles), check.Equals, 1)
+	ch.Assert(d.Campaigns, check.DeepEquals, []DomainUsage{{Id: c.Id, Name: c.Name}})
+
+	ch.Assert(d.Check(context.Background()), check.Equals, nil)
+	got, err := GetDomain(d.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.DNSStatus, check.Equals, DomainResolves)
+	ch.Assert(got.Categories, check.Equals, "business")
+	ch.Assert(got.CertificateIssuer, check.Equals, "Test CA")
+	ch.Assert(got.CertificateExpiry.Equal(expiry), check.Equals, true)
+	ch.Assert(got.Burned, check.Equals, false)
+	ch.Assert(got.LastCheckedDate.IsZero(), check.Equals, false)
+
+	// Blocklisting the domain burns it
+	resolver.listed = true
+	ch.Assert(got.Check(context.Background()), check.Equals, nil)
+	ch.Assert(got.Blocklisted, check.Equals, true)
+	ch.Assert(got.Burned, check.Equals, true)
+	burned := got.BurnedDate
+	ch.Assert(burned.IsZero(), check.Equals, false)
+
+	// And it stays burned from when it was first found
+	ch.Assert(CheckDomains(context.Background()), check.Equals, nil)
+	got, err = GetDomain(d.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Burned, check.Equals, true)
+	ch.Assert(got.BurnedDate.Equal(burned), check.Equals, true)
+
+	// Unknown domains aren't checked for certificates
+	d = Domain{UserId: c.UserId, Name: "unknown.example.com"}
+	ch.Assert(PostDomain(&d), check.Equals, nil)
+	ch.Assert(d.Check(context.Background()), check.Equals, nil)
+	ch.Assert(d.DNSStatus, check.Equals, DomainNotFound)
+	ch.Assert(d.CertificateIssuer, check.Equals, "")
+	ch.Assert(len(d.Campaigns), check.Equals, 0)
+}
@@ -1,14 +1,11 @@
 package models
 
 import (
-	"encoding/base64"
 	"fmt"
 	"io"
 	"net/mail"
-	"strings"
 
 	"github.com/gophish/gomail"
-	"github.com/gophish/gophish/config"
 	log "github.com/gophish/gophish/logger"
 	"github.com/gophish/gophish/mailer"
 )
@@ -34,6 +31,7 @@ type EmailRequest struct {
 	ErrorChan   chan (error) `json:"-" gorm:"-"`
 	RId         string       `json:"id"`
 	FromAddress string       `json:"-"`
+	Transcript  []string     `json:"-" gorm:"-"`
 	BaseRecipient
 }
 
@@ -70,6 +68,12 @@ func (s *EmailRequest) Error(err error) error {
 	return nil
 }
 
+// SetTranscript keeps the SMTP conversation so that it can be shown when the
+// test email fails.
+func (s *EmailRequest) SetTranscript(transcript []string) {
+	s.Transcript = transcript
+}
+
 // Success returns nil on the ErrorChan to indicate that the email was sent
 // successfully.
 func (s *EmailRequest) Success() error {
@@ -77,6 +81,18 @@ func (s *EmailRequest) Success() error {
 	return nil
 }
 
+// MarkInFlight is a no-op, since test emails aren't retried.
+func (s *EmailRequest) MarkInFlight() error {
+	return nil
+}
+
+// Unlock returns mailer.ErrStopped on the ErrorChan, since the email wasn't
+// sent.
+func (s *EmailRequest) Unlock() error {
+	s.ErrorChan <- mailer.ErrStopped
+	return nil
+}
+
 // PostEmailRequest stores a SendTestEmailRequest in the database.
 func PostEmailRequest(s *EmailRequest) error {
 	// Generate an ID to be used in the underlying Result object
@@ -99,11 +115,15 @@ func GetEmailRequestByResultId(id string) (EmailRequest, error) {
 // Generate fills in the details of a gomail.Message with the contents
 // from the SendTestEmailRequest.
 func (s *EmailRequest) Generate(msg *gomail.Message) error {
+	t := s.Template.Localize(s.Language)
 	f, err := mail.ParseAddress(s.FromAddress)
 	if err != nil {
 		return err
 	}
-	msg.SetAddressHeader("From", f.Address, f.Name)
+	if t.FromName != "" {
+		f.Name = t.FromName
+	}
+	msg.SetAddressHeader("From", asciiAddress(f.Address), f.Name)
 
 	ptx, err := NewPhishingTemplateContext(s, s.BaseRecipient, s.RId)
 	if err != nil {
@@ -116,8 +136,11 @@ func (s *EmailRequest) Generate(msg *gomail.Message) error {
 	}
 	s.URL = url
 
-	// Add the transparency headers
-	msg.SetHeader("X-Mailer", config.ServerName)
+	// Add the transparency headers, and the Message-Id header
+	err = s.SMTP.setMessageHeaders(msg)
+	if err != nil {
+		return err
+	}
 	if conf.ContactAddress != "" {
 		msg.SetHeader("X-Gophish-Contact", conf.ContactAddress)
 	}
@@ -138,42 +161,84 @@ func (s *EmailRequest) Generate(msg *gomail.Message) error {
 		msg.SetHeader(key, value)
 	}
 
+	// The template's Reply-To address takes precedence over any set by the
+	// sending profile
+	if t.ReplyTo != "" {
+		rt, err := mail.ParseAddress(t.ReplyTo)
+		if err != nil {
+			return err
+		}
+		msg.SetAddressHeader("Reply-To", asciiAddress(rt.Address), rt.Name)
+	}
+
+	// Thread replies refer to a fake prior message, which is quoted in
+	// the body
+	var prior *priorMessage
+	if t.Thread {
+		prior, err = t.priorMessage(ptx)
+		if err != nil {
+			return err
+		}
+		msg.SetHeader("In-Reply-To", prior.MessageID)
+		msg.SetHeader("References", prior.MessageID)
+	}
+
 	// Parse remaining templates
-	subject, err := ExecuteTemplate(s.Template.Subject, ptx)
+	subject, err := ExecuteTemplate(t.Subject, ptx)
 	if err != nil {
 		log.Error(err)
 	}
+	if prior != nil {
+		subject = threadSubject(subject)
+	}
 	// don't set the Subject header if it is blank
 	if subject != "" {
 		msg.SetHeader("Subject", subject)
 	}
 
 	msg.SetHeader("To", s.FormatAddress())
-	if s.Template.Text != "" {
-		text, err := ExecuteTemplate(s.Template.Text, ptx)
+	if t.Text != "" {
+		text, err := ExecuteTemplate(t.Text, ptx)
 		if err != nil {
 			log.Error(err)
 		}
-		msg.SetBody("text/plain", text)
+		msg.SetBody("text/plain", prior.quoteText(text))
 	}
-	if s.Template.HTML != "" {
-		html, err := ExecuteTemplate(s.Template.HTML, ptx)
+	if t.HTML != "" {
+		html, err := ExecuteTemplate(t.HTML, ptx)
 		if err != nil {
 			log.Error(err)
 		}
-		if s.Template.Text == "" {
-			msg.SetBody("text/html", html)
-		} else {
+		html = prior.quoteHTML(html)
+		switch {
+		case t.Text != "":
 			msg.AddAlternative("text/html", html)
+		case t.GenerateText:
+			text, err := htmlToText(html)
+			if err != nil {
+				log.Error(err)
+			}
+			msg.SetBody("text/plain", text)
+			msg.AddAlternative("text/html", html)
+		default:
+			msg.SetBody("text/html", html)
 		}
 	}
 	// Attach the files
-	for _, a := range s.Template.Attachments {
-		msg.Attach(func(a Attachment) (string, gomail.FileSetting, gomail.FileSetting) {
+	for _, a := range t.Attachments {
+		attach := msg.Attach
+		if a.Inline {
+			attach = msg.Embed
+		}
+		attach(func(a Attachment) (string, gomail.FileSetting, gomail.FileSetting) {
 			h := map[string][]string{"Content-ID": {fmt.Sprintf("<%s>", a.Name)}}
 			return a.Name, gomail.SetCopyFunc(func(w io.Writer) error {
-				decoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(a.Content))
-				_, err = io.Copy(w, decoder)
+				content, err := a.Open()
+				if err != nil {
+					return err
+				}
+				defer content.Close()
+				_, err = io.Copy(w, content)
 				return err
 			}), gomail.SetHeader(h)
 		}(a))
@@ -182,7 +247,27 @@ func (s *EmailRequest) Generate(msg *gomail.Message) error {
 	return nil
 }
 
-// GetDialer returns the mailer.Dialer for the underlying SMTP object
+// RewriteMessage applies the sending profile's boundary and header order
+// settings to the generated message, then signs it with the sending
+// profile's DKIM key.
+func (s *EmailRequest) RewriteMessage(raw []byte) ([]byte, error) {
+	raw, err := s.SMTP.RewriteMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.SMTP.signMessage(raw)
+}
+
+// EnvelopeFrom returns the envelope sender set by the template.
+func (s *EmailRequest) EnvelopeFrom() string {
+	return s.Template.envelopeFrom()
+}
+
+// GetDialer returns the mailer.Dialer for the underlying SMTP object. If
+// simulated sending is enabled, test emails are saved rather than sent.
 func (s *EmailRequest) GetDialer() (mailer.Dialer, error) {
+	if simulatedSendingEnabled() {
+		return simulatedDialer("test-emails", &s.SMTP), nil
+	}
 	return s.SMTP.GetDialer()
 }
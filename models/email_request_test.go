@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gophish/gomail"
 	"github.com/gophish/gophish/config"
@@ -225,3 +226,79 @@ func (s *ModelsSuite) TestPostSendTestEmailRequest(ch *check.C) {
 	ch.Assert(got.RId, check.Equals, req.RId)
 	ch.Assert(got.Email, check.Equals, req.Email)
 }
+
+func (s *ModelsSuite) TestEmailRequestSenderOverrides(ch *check.C) {
+	req := &EmailRequest{
+		SMTP: SMTP{
+			FromAddress: "Profile <profile@example.com>",
+			Headers:     []Header{Header{Key: "Reply-To", Value: "profile@example.com"}},
+		},
+		FromAddress: "Profile <profile@example.com>",
+		Template: Template{
+			Subject:        "Test",
+			Text:           "{{.From}}",
+			FromName:       "IT Helpdesk",
+			EnvelopeSender: "bounces@example.com",
+			ReplyTo:        "Helpdesk <helpdesk@example.org>",
+		},
+		BaseRecipient: BaseRecipient{Email: "to@example.com"},
+	}
+	msg := gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	ch.Assert(msg.GetHeader("From"), check.DeepEquals, []string{`"IT Helpdesk" <profile@example.com>`})
+	ch.Assert(msg.GetHeader("Reply-To"), check.DeepEquals, []string{`"Helpdesk" <helpdesk@example.org>`})
+	ch.Assert(req.EnvelopeFrom(), check.Equals, "bounces@example.com")
+
+	req.Template.EnvelopeSender = ""
+	ch.Assert(req.EnvelopeFrom(), check.Equals, "")
+}
+
+func (s *ModelsSuite) TestTemplateSenderDomains(ch *check.C) {
+	defer func() { conf.SenderDomains = nil }()
+	t := Template{Name: "Sender Template", Text: "Text", ReplyTo: "helpdesk@mail.example.org"}
+	ch.Assert(t.Validate(), check.Equals, nil)
+
+	conf.SenderDomains = []string{"example.org"}
+	ch.Assert(t.Validate(), check.Equals, nil)
+	t.EnvelopeSender = "bounces@example.com"
+	ch.Assert(t.Validate(), check.Equals, ErrSenderDomainNotAllowed)
+	t.EnvelopeSender = "not an address"
+	ch.Assert(t.Validate(), check.Not(check.Equals), nil)
+}
+
+func (s *ModelsSuite) TestEmailRequestThread(ch *check.C) {
+	req := &EmailRequest{
+		SMTP:        SMTP{FromAddress: "from@example.com"},
+		FromAddress: "from@example.com",
+		Template: Template{
+			Subject:    "Invoice",
+			Text:       "See the attached invoice.",
+			HTML:       "<html><body><p>See the attached invoice.</p></body></html>",
+			Thread:     true,
+			ThreadBody: "Hi,\nCan you send me the invoice, {{.FirstName}}?",
+		},
+		BaseRecipient: BaseRecipient{Email: "to@example.com", FirstName: "First", LastName: "Last"},
+		RId:           "1234567",
+	}
+	msg := gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	ch.Assert(msg.GetHeader("Subject"), check.DeepEquals, []string{"Re: Invoice"})
+	ids := msg.GetHeader("In-Reply-To")
+	ch.Assert(len(ids), check.Equals, 1)
+	ch.Assert(strings.HasSuffix(ids[0], "@example.com>"), check.Equals, true)
+	ch.Assert(msg.GetHeader("References"), check.DeepEquals, ids)
+
+	got := &bytes.Buffer{}
+	_, err := msg.WriteTo(got)
+	ch.Assert(err, check.Equals, nil)
+	e, err := email.NewEmailFromReader(got)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(e.Text), check.Matches, `(?s)See the attached invoice\.\r?\n\r?\nOn .*, "First Last" <to@example.com> wrote:\r?\n> Hi,\r?\n> Can you send me the invoice, First\?\r?\n`)
+	ch.Assert(strings.Contains(string(e.HTML), "<blockquote"), check.Equals, true)
+	ch.Assert(strings.Contains(string(e.HTML), "Can you send me the invoice, First?</blockquote></div></body>"), check.Equals, true)
+
+	// Generating the email again replies to the same message
+	msg = gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	ch.Assert(msg.GetHeader("In-Reply-To"), check.DeepEquals, ids)
+}
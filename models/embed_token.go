@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/auth"
+)
+
+// EmbedTokenPrefix starts every embed token, so that they can't be mistaken
+// for API keys.
+const EmbedTokenPrefix = "gpe_"
+
+// embedTokenUseInterval is how often the last use of an embed token is
+// saved, since dashboards poll frequently.
+const embedTokenUseInterval = time.Minute
+
+// ErrEmbedTokenNotFound is thrown when an embed token doesn't exist.
+var ErrEmbedTokenNotFound = errors.New("Embed token not found")
+
+// EmbedToken is a read-only token which only grants access to the aggregate
+// statistics of a user's campaigns. Unlike API keys, they can be embedded in
+// internal dashboards, such as Grafana, without exposing the rest of the API.
+//
+// Only the hash of the token is stored, so the token is only available when
+// it's created.
+type EmbedToken struct {
+	Id          int64     `json:"id"`
+	UserId      int64     `json:"-"`
+	Name        string    `json:"name"`
+	TokenHash   string    `json:"-"`
+	Token       string    `json:"token,omitempty" gorm:"-"`
+	CreatedDate time.Time `json:"created_date"`
+	LastUsed    time.Time `json:"last_used"`
+}
+
+// EmbedStats are the aggregate statistics of a user's campaigns.
+type EmbedStats struct {
+	Campaigns       int64         `json:"campaigns"`
+	ActiveCampaigns int64         `json:"active_campaigns"`
+	Stats           CampaignStats `json:"stats"`
+}
+
+// Validate ensures the embed token has a name.
+func (t *EmbedToken) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return ErrNameNotSpecified
+	}
+	return nil
+}
+
+// GetEmbedTokens returns the embed tokens owned by the given user.
+func GetEmbedTokens(uid int64) ([]EmbedToken, error) {
+	ts := []EmbedToken{}
+	err := db.Where("user_id=?", uid).Order("id").Find(&ts).Error
+	return ts, err
+}
+
+// PostEmbedToken creates a new embed token, setting its token.
+func PostEmbedToken(t *EmbedToken) error {
+	err := t.Validate()
+	if err != nil {
+		return err
+	}
+	t.Token = EmbedTokenPrefix + auth.GenerateSecureKey(auth.APIKeyLength)
+	t.TokenHash = hashAPIKey(t.Token)
+	t.CreatedDate = time.Now().UTC()
+	return db.Save(t).Error
+}
+
+// DeleteEmbedToken deletes the embed token owned by the given user, revoking
+// it.
+func DeleteEmbedToken(id int64, uid int64) error {
+	t := EmbedToken{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&t).Error
+	if err != nil {
+		return ErrEmbedTokenNotFound
+	}
+	return db.Where("id=?", t.Id).Delete(&EmbedToken{}).Error
+}
+
+// GetEmbedTokenByToken returns the embed token with the given token,
+// recording that it was used.
+func GetEmbedTokenByToken(token string) (EmbedToken, error) {
+	t := EmbedToken{}
+	if !strings.HasPrefix(token, EmbedTokenPrefix) {
+		return t, ErrEmbedTokenNotFound
+	}
+	err := db.Where("token_hash=?", hashAPIKey(token)).First(&t).Error
+	if err != nil {
+		return t, ErrEmbedTokenNotFound
+	}
+	now := time.Now().UTC()
+	if now.Sub(t.LastUsed) >= embedTokenUseInterval {
+		t.LastUsed = now
+		err = db.Model(&EmbedToken{}).Where("id=?", t.Id).Update("last_used", now).Error
+	}
+	return t, err
+}
+
+// GetEmbedStats returns the aggregate statistics of the user's campaigns.
+func GetEmbedStats(uid int64) (EmbedStats, error) {
+	es := EmbedStats{}
+	summaries, err := GetCampaignSummaries(uid)
+	if err != nil {
+		return es, err
+	}
+	es.Campaigns = summaries.Total
+	for _, c := range summaries.Campaigns {
+		if c.Status != CampaignComplete {
+			es.ActiveCampaigns++
+		}
+		s := c.Stats
+		es.Stats.Total += s.Total
+		es.Stats.EmailsSent += s.EmailsSent
+		es.Stats.OpenedEmail += s.OpenedEmail
+		es.Stats.ClickedLink += s.ClickedLink
+		es.Stats.SubmittedData += s.SubmittedData
+		es.Stats.EmailReported += s.EmailReported
+		es.Stats.Forwarded += s.Forwarded
+		es.Stats.AutoReplied += s.AutoReplied
+		es.Stats.Unsubscribed += s.Unsubscribed
+		es.Stats.Error += s.Error
+		es.Stats.MachineOpened += s.MachineOpened
+		es.Stats.HumanOpened += s.HumanOpened
+	}
+	return es, nil
+}
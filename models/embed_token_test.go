@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestEmbedToken(c *check.C) {
+	t := EmbedToken{UserId: 1}
+	c.Assert(PostEmbedToken(&t), check.Equals, ErrNameNotSpecified)
+
+	t.Name = "SOC wallboard"
+	c.Assert(PostEmbedToken(&t), check.Equals, nil)
+	c.Assert(strings.HasPrefix(t.Token, EmbedTokenPrefix), check.Equals, true)
+	c.Assert(t.TokenHash, check.Not(check.Equals), t.Token)
+
+	got, err := GetEmbedTokenByToken(t.Token)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(got.Id, check.Equals, t.Id)
+	c.Assert(got.Token, check.Equals, "")
+	c.Assert(got.LastUsed.IsZero(), check.Equals, false)
+
+	// API keys aren't embed tokens
+	u, err := GetUser(1)
+	c.Assert(err, check.Equals, nil)
+	_, err = GetEmbedTokenByToken(string(u.ApiKey))
+	c.Assert(err, check.Equals, ErrEmbedTokenNotFound)
+
+	c.Assert(DeleteEmbedToken(t.Id, 2), check.Equals, ErrEmbedTokenNotFound)
+	c.Assert(DeleteEmbedToken(t.Id, 1), check.Equals, nil)
+	_, err = GetEmbedTokenByToken(t.Token)
+	c.Assert(err, check.Equals, ErrEmbedTokenNotFound)
+}
+
+func (s *ModelsSuite) TestGetEmbedStats(c *check.C) {
+	campaign := s.createCampaign(c)
+	es, err := GetEmbedStats(campaign.UserId)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(es.Campaigns, check.Equals, int64(1))
+	c.Assert(es.ActiveCampaigns, check.Equals, int64(1))
+	c.Assert(es.Stats.Total, check.Equals, int64(len(campaign.Results)))
+}
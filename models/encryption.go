@@ -0,0 +1,261 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/encryption"
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// EncryptionKeyEnv is the environment variable that specifies the key used
+// to encrypt sensitive data in the database. This takes precedence over the
+// key set in the config.
+const EncryptionKeyEnv = "GOPHISH_ENCRYPTION_KEY"
+
+// reencryptBatchSize is the number of rows loaded at a time when encrypting
+// existing data.
+const reencryptBatchSize = 500
+
+// keyring holds the keys used to encrypt sensitive columns. If no key is
+// configured, this is nil and values are stored unencrypted.
+var keyring *encryption.Keyring
+
+// EncryptedString is a string which is transparently encrypted when it's
+// written to the database, and decrypted when it's read back. Values stored
+// before encryption was enabled are read as-is.
+//
+// Without a key, values which look encrypted are escaped when they're stored.
+// Values stored before they were escaped, such as captured data starting
+// with the encryption prefix, can't be decrypted and are read as plaintext.
+type EncryptedString string
+
+// Value implements the driver.Valuer interface, encrypting the string with
+// the configured key.
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	return keyring.Encrypt(string(s))
+}
+
+// Scan implements the sql.Scanner interface, decrypting the stored value.
+func (s *EncryptedString) Scan(src interface{}) error {
+	var stored string
+	switch v := src.(type) {
+	case nil:
+		stored = ""
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported type for encrypted string: %T", src)
+	}
+	plaintext, err := keyring.Decrypt(stored)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("Unable to decrypt value, reading it as plaintext")
+		plaintext = stored
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// hashAPIKey returns the hash of an API key used to look up its user. API
+// keys are random, so an unsalted hash is sufficient and lets the key itself
+// be stored encrypted.
+func hashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// loadKeyring returns the keyring for the configured encryption keys, or nil
+// if no key is configured.
+func loadKeyring(c config.Encryption) (*encryption.Keyring, error) {
+	encoded := c.Key
+	if c.KeyFile != "" {
+		b, err := ioutil.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(b)
+	}
+	if envKey := os.Getenv(EncryptionKeyEnv); envKey != "" {
+		encoded = envKey
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := encryption.ParseKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	previous := [][]byte{}
+	for _, p := range c.PreviousKeys {
+		pk, err := encryption.ParseKey(p)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, pk)
+	}
+	return encryption.NewKeyring(key, previous...)
+}
+
+// encryptedColumn is a database column holding an EncryptedString, along
+// with the integer primary key of its table.
+type encryptedColumn struct {
+	table  string
+	key    string
+	column string
+}
+
+var encryptedColumns = []encryptedColumn{
+	{"events", "id", "details"},
+	{"archived_events", "id", "details"},
+	{"event_requests", "id", "headers"},
+	{"event_requests", "id", "body"},
+	{"smtp", "id", "password"},
+	{"smtp", "id", "proxy"},
+	{"smtp", "id", "dkim_private_key"},
+	{"imap", "user_id", "password"},
+	{"imap", "user_id", "oauth2_client_secret"},
+	{"imap", "user_id", "oauth2_refresh_token"},
+	{"users", "id", "api_key"},
+	{"honeytokens", "id", "password"},
+	{"signing_keys", "id", "value"},
+}
+
+// encryptExistingData encrypts any values in the sensitive columns which are
+// either unencrypted or were encrypted with a previous key. This is run at
+// startup so that enabling encryption, or rotating the key, applies to data
+// that was already stored.
+func encryptExistingData() error {
+	for _, ec := range encryptedColumns {
+		count, err := ec.reencrypt()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.WithFields(logrus.Fields{
+				"table":  ec.table,
+				"column": ec.column,
+				"rows":   count,
+			}).Info("Encrypted existing data")
+		}
+	}
+	return nil
+}
+
+// reencrypt encrypts the values in the column with the current key, returning
+// the number of rows updated. Rows are loaded in batches, since the number of
+// events can be large. Values which can't be decrypted are left as they are,
+// so that they can still be recovered if the key they need is added.
+func (ec encryptedColumn) reencrypt() (int64, error) {
+	type row struct {
+		id    int64
+		value sql.NullString
+	}
+	var count, last int64
+	for {
+		rows, err := db.Table(ec.table).Select(ec.key+", "+ec.column).
+			Where(ec.key+" > ?", last).Order(ec.key + " asc").
+			Limit(reencryptBatchSize).Rows()
+		if err != nil {
+			return count, err
+		}
+		batch := []row{}
+		for rows.Next() {
+			r := row{}
+			err = rows.Scan(&r.id, &r.value)
+			if err != nil {
+				rows.Close()
+				return count, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if len(batch) == 0 {
+			return count, nil
+		}
+		tx := db.Begin()
+		for _, r := range batch {
+			last = r.id
+			if !r.value.Valid || r.value.String == "" || keyring.IsCurrent(r.value.String) {
+				continue
+			}
+			plaintext, err := keyring.Decrypt(r.value.String)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"table":  ec.table,
+					"column": ec.column,
+					ec.key:   r.id,
+					"error":  err,
+				}).Warn("Unable to decrypt existing data, leaving it unchanged")
+				continue
+			}
+			encrypted, err := keyring.Encrypt(plaintext)
+			if err != nil {
+				tx.Rollback()
+				return count, err
+			}
+			err = tx.Table(ec.table).Where(ec.key+" = ?", r.id).UpdateColumn(ec.column, encrypted).Error
+			if err != nil {
+				tx.Rollback()
+				return count, err
+			}
+			count++
+		}
+		err = tx.Commit().Error
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// setAPIKeyHashes fills in the API key hash for users created before API
+// keys were looked up by their hash.
+func setAPIKeyHashes() error {
+	us := []User{}
+	err := db.Where("api_key_hash IS NULL OR api_key_hash = ?", "").Find(&us).Error
+	if err != nil {
+		return err
+	}
+	for _, u := range us {
+		err = db.Model(&u).UpdateColumn("api_key_hash", hashAPIKey(string(u.ApiKey))).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupEncryption loads the configured encryption keys and brings the
+// existing data up to date with them.
+func setupEncryption(c *config.Config) error {
+	var err error
+	keyring, err = loadKeyring(c.Encryption)
+	if err != nil {
+		return err
+	}
+	anonymizeKey, err = loadAnonymizeKey(c.Encryption)
+	if err != nil {
+		return err
+	}
+	err = setAPIKeyHashes()
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return nil
+	}
+	return encryptExistingData()
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gophish/gophish/encryption"
+	"gopkg.in/check.v1"
+)
+
+func newTestKeyring(ch *check.C, keys ...byte) *encryption.Keyring {
+	previous := [][]byte{}
+	for _, b := range keys[1:] {
+		previous = append(previous, bytes.Repeat([]byte{b}, encryption.KeySize))
+	}
+	k, err := encryption.NewKeyring(bytes.Repeat([]byte{keys[0]}, encryption.KeySize), previous...)
+	ch.Assert(err, check.Equals, nil)
+	return k
+}
+
+// restoreKeyring disables encryption again, rewriting the admin user so that
+// it can be read by later tests.
+func restoreKeyring(ch *check.C) {
+	u, err := GetUser(1)
+	ch.Assert(err, check.Equals, nil)
+	keyring = nil
+	ch.Assert(PutUser(&u), check.Equals, nil)
+}
+
+func rawColumn(ch *check.C, table string, column string, id int64) string {
+	var raw string
+	err := db.Table(table).Select(column).Where("id=?", id).Row().Scan(&raw)
+	ch.Assert(err, check.Equals, nil)
+	return raw
+}
+
+func (s *ModelsSuite) TestEncryptedColumns(ch *check.C) {
+	keyring = newTestKeyring(ch, 1)
+	defer restoreKeyring(ch)
+
+	smtp := SMTP{
+		Name:        "Test SMTP",
+		Host:        "1.1.1.1:25",
+		FromAddress: "Foo Bar <foo@example.com>",
+		Password:    "smtp-password",
+		UserId:      1,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+	raw := rawColumn(ch, "smtp", "password", smtp.Id)
+	ch.Assert(encryption.IsEncrypted(raw), check.Equals, true)
+	ch.Assert(strings.Contains(raw, "smtp-password"), check.Equals, false)
+	got, err := GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(got.Password), check.Equals, "smtp-password")
+
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	details := EventDetails{
+		Payload: map[string][]string{"password": []string{"secret"}},
+		Request: &EventRequest{Method: "POST", Body: "password=secret"},
+	}
+	ch.Assert(result.HandleFormSubmit(details), check.Equals, nil)
+	e := Event{}
+	ch.Assert(db.Where("campaign_id=? and message=?", campaign.Id, EventDataSubmit).First(&e).Error, check.Equals, nil)
+	ch.Assert(strings.Contains(string(e.Details), "secret"), check.Equals, true)
+	raw = rawColumn(ch, "events", "details", e.Id)
+	ch.Assert(encryption.IsEncrypted(raw), check.Equals, true)
+
+	ers, err := GetEventRequests(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ers), check.Equals, 1)
+	ch.Assert(string(ers[0].Body), check.Equals, "password=secret")
+	raw = rawColumn(ch, "event_requests", "body", ers[0].Id)
+	ch.Assert(encryption.IsEncrypted(raw), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestEncryptExistingData(ch *check.C) {
+	smtp := SMTP{
+		Name:        "Test SMTP",
+		Host:        "1.1.1.1:25",
+		FromAddress: "Foo Bar <foo@example.com>",
+		Password:    "smtp-password",
+		UserId:      1,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+	ch.Assert(rawColumn(ch, "smtp", "password", smtp.Id), check.Equals, "smtp-password")
+	u, err := GetUser(1)
+	ch.Assert(err, check.Equals, nil)
+
+	// Enabling encryption should encrypt the existing values
+	keyring = newTestKeyring(ch, 1)
+	defer restoreKeyring(ch)
+	ch.Assert(encryptExistingData(), check.Equals, nil)
+	raw := rawColumn(ch, "smtp", "password", smtp.Id)
+	ch.Assert(keyring.IsCurrent(raw), check.Equals, true)
+	ch.Assert(encryption.IsEncrypted(rawColumn(ch, "users", "api_key", 1)), check.Equals, true)
+
+	// Rotating the key should re-encrypt the values with the new key
+	keyring = newTestKeyring(ch, 2, 1)
+	ch.Assert(keyring.IsCurrent(raw), check.Equals, false)
+	ch.Assert(encryptExistingData(), check.Equals, nil)
+	raw = rawColumn(ch, "smtp", "password", smtp.Id)
+	ch.Assert(keyring.IsCurrent(raw), check.Equals, true)
+
+	got, err := GetSMTP(smtp.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(got.Password), check.Equals, "smtp-password")
+
+	// Users should still be found by their API key
+	found, err := GetUserByAPIKey(string(u.ApiKey))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(found.Id, check.Equals, u.Id)
+}
+
+func (s *ModelsSuite) TestEncryptedPrefixPlaintext(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	body := encryption.Prefix + "abcd1234:Zm9vYmFyYmF6cXV4MTIzNDU2"
+	details := EventDetails{
+		Payload: map[string][]string{"username": []string{"foo"}},
+		Request: &EventRequest{Method: "POST", Body: EncryptedString(body)},
+	}
+	ch.Assert(result.HandleFormSubmit(details), check.Equals, nil)
+
+	// Without a key, captured data which looks encrypted is escaped
+	ers, err := GetEventRequests(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ers), check.Equals, 1)
+	ch.Assert(string(ers[0].Body), check.Equals, body)
+	ch.Assert(rawColumn(ch, "event_requests", "body", ers[0].Id), check.Equals, encryption.PlainPrefix+body)
+
+	// Values stored before they were escaped are read as plaintext
+	err = db.Table("event_requests").Where("id=?", ers[0].Id).UpdateColumn("body", body).Error
+	ch.Assert(err, check.Equals, nil)
+	ers, err = GetEventRequests(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(ers[0].Body), check.Equals, body)
+
+	// and don't stop encryption from being enabled
+	keyring = newTestKeyring(ch, 1)
+	defer restoreKeyring(ch)
+	ch.Assert(encryptExistingData(), check.Equals, nil)
+	ers, err = GetEventRequests(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(ers[0].Body), check.Equals, body)
+}
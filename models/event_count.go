@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// countedEvents are the events counted for each result, along with the
+// prefix of the result's columns which count them.
+var countedEvents = map[string]string{
+	EventClicked:    "click",
+	EventDataSubmit: "submit",
+}
+
+// countEvent counts the event for the result, returning whether it should
+// be stored. Events are no longer stored once the recipient has reached the
+// campaign's cap on duplicate events.
+func (r *Result) countEvent(status string) (bool, error) {
+	prefix, ok := countedEvents[status]
+	if !ok || r.Id == 0 {
+		return true, nil
+	}
+	now := time.Now().UTC()
+	// The count is incremented in the database, since the same recipient's
+	// events are often handled concurrently.
+	err := db.Table("results").Where("id=?", r.Id).UpdateColumns(map[string]interface{}{
+		prefix + "_count":           gorm.Expr(prefix + "_count + 1"),
+		"first_" + prefix + "_date": gorm.Expr("COALESCE(first_"+prefix+"_date, ?)", now),
+		"last_" + prefix + "_date":  now,
+	}).Error
+	if err != nil {
+		return false, err
+	}
+	counts := Result{}
+	err = db.Table("results").Select("click_count, first_click_date, last_click_date, submit_count, first_submit_date, last_submit_date").
+		Where("id=?", r.Id).Scan(&counts).Error
+	if err != nil {
+		return false, err
+	}
+	r.ClickCount, r.FirstClickDate, r.LastClickDate = counts.ClickCount, counts.FirstClickDate, counts.LastClickDate
+	r.SubmitCount, r.FirstSubmitDate, r.LastSubmitDate = counts.SubmitCount, counts.FirstSubmitDate, counts.LastSubmitDate
+	max := campaignMaxDuplicateEvents(r.CampaignId)
+	count := r.ClickCount
+	if status == EventDataSubmit {
+		count = r.SubmitCount
+	}
+	return max == 0 || count <= max, nil
+}
+
+// campaignMaxDuplicateEvents returns the campaign's cap on duplicate events.
+func campaignMaxDuplicateEvents(cid int64) int {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("max_duplicate_events").Where("id=?", cid).Find(&c).Error
+	if err != nil {
+		return 0
+	}
+	return c.MaxDuplicateEvents
+}
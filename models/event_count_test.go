@@ -0,0 +1,51 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestEventCounts(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.MaxDuplicateEvents = -1
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidMaxDuplicateEvents)
+
+	c.MaxDuplicateEvents = 2
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	r, err := GetResult(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.ClickCount, check.Equals, 0)
+	ch.Assert(r.FirstClickDate, check.IsNil)
+
+	for i := 0; i < 5; i++ {
+		ch.Assert(r.HandleClickedLink(EventDetails{}), check.Equals, nil)
+	}
+	ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+
+	// Every event is counted, but only the first are stored
+	got, err := GetResult(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.ClickCount, check.Equals, 5)
+	ch.Assert(got.SubmitCount, check.Equals, 1)
+	ch.Assert(got.Status, check.Equals, EventDataSubmit)
+	ch.Assert(got.FirstClickDate, check.NotNil)
+	ch.Assert(got.LastClickDate.Before(*got.FirstClickDate), check.Equals, false)
+	ch.Assert(got.FirstSubmitDate, check.NotNil)
+
+	var count int
+	db.Model(&Event{}).Where("campaign_id=? and email=? and message=?", c.Id, r.Email, EventClicked).Count(&count)
+	ch.Assert(count, check.Equals, 2)
+	db.Model(&Event{}).Where("campaign_id=? and email=? and message=?", c.Id, r.Email, EventDataSubmit).Count(&count)
+	ch.Assert(count, check.Equals, 1)
+}
+
+func (s *ModelsSuite) TestEventCountsUncapped(ch *check.C) {
+	c := s.createCampaign(ch)
+	r := c.Results[0]
+	for i := 0; i < 3; i++ {
+		ch.Assert(r.HandleClickedLink(EventDetails{}), check.Equals, nil)
+	}
+	ch.Assert(r.ClickCount, check.Equals, 3)
+	var count int
+	db.Model(&Event{}).Where("campaign_id=? and email=? and message=?", c.Id, r.Email, EventClicked).Count(&count)
+	ch.Assert(count, check.Equals, 3)
+}
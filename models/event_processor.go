@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// EventProcessor receives each campaign event before it's stored, and can
+// change it, such as to enrich its details or add tags. Returning false
+// suppresses the event, so that it isn't stored or sent to webhooks. The
+// recipient's result is still updated.
+//
+// Processors can be compiled into Gophish and registered, or loaded from Go
+// plugins using the plugins package.
+type EventProcessor interface {
+	ProcessEvent(e *Event) (bool, error)
+}
+
+// registeredProcessor is an event processor along with the name it was
+// registered with.
+type registeredProcessor struct {
+	name      string
+	processor EventProcessor
+}
+
+var eventProcessors = struct {
+	sync.RWMutex
+	ps []registeredProcessor
+}{}
+
+// RegisterEventProcessor adds an event processor. Processors are run in the
+// order they're registered.
+func RegisterEventProcessor(name string, p EventProcessor) {
+	eventProcessors.Lock()
+	defer eventProcessors.Unlock()
+	eventProcessors.ps = append(eventProcessors.ps, registeredProcessor{name: name, processor: p})
+}
+
+// EventProcessors returns the names of the registered event processors, in
+// the order they're run.
+func EventProcessors() []string {
+	eventProcessors.RLock()
+	defer eventProcessors.RUnlock()
+	names := make([]string, len(eventProcessors.ps))
+	for i, p := range eventProcessors.ps {
+		names[i] = p.name
+	}
+	return names
+}
+
+// processEvent runs the event through each registered processor, returning
+// whether it should be stored. A processor which fails is skipped, since a
+// broken plugin shouldn't cause events to be lost.
+func processEvent(e *Event) bool {
+	eventProcessors.RLock()
+	defer eventProcessors.RUnlock()
+	for _, p := range eventProcessors.ps {
+		keep, err := p.processor.ProcessEvent(e)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"processor":   p.name,
+				"campaign_id": e.CampaignId,
+			}).Errorf("error processing event: %v", err)
+			continue
+		}
+		if !keep {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTag adds a tag to the event, if it doesn't already have it. Tags are
+// stored as a comma separated list, so they can't contain commas.
+func (e *Event) AddTag(tag string) {
+	tag = strings.TrimSpace(strings.Replace(tag, ",", "", -1))
+	if tag == "" || e.HasTag(tag) {
+		return
+	}
+	if e.Tags != "" {
+		e.Tags += ","
+	}
+	e.Tags += tag
+}
+
+// HasTag returns whether the event has the given tag.
+func (e *Event) HasTag(tag string) bool {
+	for _, t := range strings.Split(e.Tags, ",") {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
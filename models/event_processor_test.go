@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+
+	"gopkg.in/check.v1"
+)
+
+// eventProcessorFunc lets a function be used as an EventProcessor
+type eventProcessorFunc func(e *Event) (bool, error)
+
+func (f eventProcessorFunc) ProcessEvent(e *Event) (bool, error) {
+	return f(e)
+}
+
+// withEventProcessors registers the processors, returning a function which
+// removes them.
+func withEventProcessors(ps ...EventProcessor) func() {
+	for _, p := range ps {
+		RegisterEventProcessor("test", p)
+	}
+	return func() {
+		eventProcessors.Lock()
+		eventProcessors.ps = nil
+		eventProcessors.Unlock()
+	}
+}
+
+func (s *ModelsSuite) TestEventProcessors(ch *check.C) {
+	defer withEventProcessors(
+		// A failing processor is skipped
+		eventProcessorFunc(func(e *Event) (bool, error) {
+			return false, errors.New("broken processor")
+		}),
+		eventProcessorFunc(func(e *Event) (bool, error) {
+			e.AddTag("vip")
+			e.AddTag("vip")
+			return e.Message != EventOpened, nil
+		}),
+	)()
+	c := s.createCampaign(ch)
+	r := c.Results[0]
+
+	ch.Assert(r.HandleClickedLink(EventDetails{}), check.Equals, nil)
+	ch.Assert(r.HandleEmailOpened(EventDetails{}), check.Equals, nil)
+
+	events := []Event{}
+	err := db.Where("campaign_id=? AND email=?", c.Id, r.Email).Find(&events).Error
+	ch.Assert(err, check.Equals, nil)
+	// The opened event was suppressed, but the result is still updated
+	ch.Assert(len(events), check.Equals, 1)
+	ch.Assert(events[0].Message, check.Equals, EventClicked)
+	ch.Assert(events[0].Tags, check.Equals, "vip")
+	ch.Assert(events[0].HasTag("vip"), check.Equals, true)
+	got, err := GetResult(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Status, check.Equals, EventClicked)
+}
+
+func (s *ModelsSuite) TestEventTags(ch *check.C) {
+	e := Event{}
+	e.AddTag("first")
+	e.AddTag(" second,tag ")
+	e.AddTag("")
+	ch.Assert(e.Tags, check.Equals, "first,secondtag")
+	ch.Assert(e.HasTag("secondtag"), check.Equals, true)
+	ch.Assert(e.HasTag("second"), check.Equals, false)
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// DefaultMaxRequestBodySize is the number of bytes of a request body that are
+// stored when no limit is configured for the phishing server.
+const DefaultMaxRequestBodySize int64 = 64 * 1024
+
+// MaxRequestHeaderSize is the maximum number of bytes of request headers that
+// are stored for a single request.
+const MaxRequestHeaderSize = 16 * 1024
+
+// EventRequest contains the raw HTTP request received from a recipient when
+// they clicked a link or submitted data. This is stored separately from the
+// event details since it can be much larger than the summary we keep in the
+// timeline.
+type EventRequest struct {
+	Id          int64           `json:"id"`
+	CampaignId  int64           `json:"campaign_id"`
+	EventId     int64           `json:"event_id"`
+	Email       string          `json:"email"`
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	Host        string          `json:"host"`
+	Proto       string          `json:"proto"`
+	Headers     EncryptedString `json:"headers"`
+	Body        EncryptedString `json:"body"`
+	Truncated   bool            `json:"truncated"`
+	CreatedDate time.Time       `json:"created_date"`
+
+	// header is the request's headers, kept so that they can be filtered
+	// once the page the request was made to is known
+	header http.Header
+}
+
+// sensitiveHeaders are the request headers which carry credentials. They're
+// only stored for pages which store passwords.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// NewEventRequest captures the provided HTTP request, storing at most
+// maxBodySize bytes of the request body. If maxBodySize is zero,
+// DefaultMaxRequestBodySize is used.
+//
+// The request body is restored after it's read so that later handlers are
+// still able to parse the submitted form. The captured request holds
+// everything that was sent, so it should be passed through
+// Page.FilterEventRequest before it's stored.
+func NewEventRequest(r *http.Request, maxBodySize int64) (*EventRequest, error) {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxRequestBodySize
+	}
+	er := &EventRequest{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		Host:        r.Host,
+		Proto:       r.Proto,
+		CreatedDate: time.Now().UTC(),
+		header:      r.Header.Clone(),
+	}
+	err := er.setHeaders(er.header)
+	if err != nil {
+		return nil, err
+	}
+	if r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return er, nil
+	}
+	// We read one byte past the limit so we know whether or not the body
+	// was truncated.
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if int64(len(body)) > maxBodySize {
+		body = body[:maxBodySize]
+		er.Truncated = true
+	}
+	er.Body = EncryptedString(body)
+	return er, nil
+}
+
+// setHeaders stores the headers, truncating them if they're larger than
+// MaxRequestHeaderSize.
+func (er *EventRequest) setHeaders(h http.Header) error {
+	headers := &bytes.Buffer{}
+	err := h.Write(headers)
+	if err != nil {
+		return err
+	}
+	if headers.Len() > MaxRequestHeaderSize {
+		headers.Truncate(MaxRequestHeaderSize)
+		er.Truncated = true
+	}
+	er.Headers = EncryptedString(headers.String())
+	return nil
+}
+
+// storesPasswords returns whether the page stores the passwords submitted to
+// it. Pages which score passwords need them submitted, but never store them.
+func (p *Page) storesPasswords() bool {
+	return p.CaptureCredentials && p.CapturePasswords && !p.ScorePasswords
+}
+
+// FilterEventRequest returns the captured request with the data the page
+// isn't configured to store removed. Unless the page stores passwords, the
+// headers which carry credentials are removed, and form and JSON bodies are
+// filtered in the same way as the submitted data. Other bodies can't be
+// filtered, so they're left out.
+func (p *Page) FilterEventRequest(er *EventRequest) *EventRequest {
+	if er == nil || p.storesPasswords() {
+		return er
+	}
+	filtered := *er
+	h := er.header.Clone()
+	for _, k := range sensitiveHeaders {
+		h.Del(k)
+	}
+	err := filtered.setHeaders(h)
+	if err != nil {
+		log.Error(err)
+		filtered.Headers = ""
+	}
+	filtered.Body = p.filterEventRequestBody(er)
+	return &filtered
+}
+
+// filterEventRequestBody returns the captured body with the fields the page
+// doesn't store removed, or an empty body if it can't be filtered.
+func (p *Page) filterEventRequestBody(er *EventRequest) EncryptedString {
+	if er.Body == "" {
+		return ""
+	}
+	fp := *p
+	if fp.ScorePasswords {
+		fp.CapturePasswords = false
+	}
+	mediaType, _, _ := mime.ParseMediaType(er.header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(er.Body))
+		if err != nil {
+			return ""
+		}
+		return EncryptedString(fp.FilterCapturedData(values).Encode())
+	case "application/json":
+		values, err := ParseCapturedData([]byte(er.Body))
+		if err != nil {
+			return ""
+		}
+		body, err := json.Marshal(fp.FilterCapturedData(values))
+		if err != nil {
+			return ""
+		}
+		return EncryptedString(body)
+	}
+	return ""
+}
+
+// saveEventRequest stores the captured request, associating it with the
+// given event.
+func saveEventRequest(er *EventRequest, e *Event) error {
+	er.EventId = e.Id
+	er.CampaignId = e.CampaignId
+	er.Email = e.Email
+	err := db.Save(er).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// GetEventRequests returns the captured requests for the campaign with the
+// given id, provided it's owned by the given user.
+func GetEventRequests(cid int64, uid int64) ([]EventRequest, error) {
+	ers := []EventRequest{}
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id").Where("id=? and user_id=?", cid, uid).Find(&c).Error
+	if err != nil {
+		return ers, err
+	}
+	err = db.Where("campaign_id=?", c.Id).Order("id asc").Find(&ers).Error
+	return ers, err
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestNewEventRequestTruncatesBody(c *check.C) {
+	body := "username=foo&password=" + strings.Repeat("a", 100)
+	req := httptest.NewRequest(http.MethodPost, "/?rid=1234567", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "gophish-test")
+
+	er, err := NewEventRequest(req, 20)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(er.Method, check.Equals, http.MethodPost)
+	c.Assert(er.Truncated, check.Equals, true)
+	c.Assert(string(er.Body), check.Equals, body[:20])
+	c.Assert(strings.Contains(string(er.Headers), "User-Agent: gophish-test"), check.Equals, true)
+
+	// The full body should still be available to the form parser
+	c.Assert(req.ParseForm(), check.Equals, nil)
+	c.Assert(req.Form.Get("username"), check.Equals, "foo")
+	c.Assert(req.Form.Get("password"), check.Equals, strings.Repeat("a", 100))
+}
+
+func (s *ModelsSuite) TestHandleFormSubmitStoresRequest(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("username=foo"))
+	er, err := NewEventRequest(req, 0)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(er.Truncated, check.Equals, false)
+
+	err = result.HandleFormSubmit(EventDetails{Request: er})
+	ch.Assert(err, check.Equals, nil)
+
+	ers, err := GetEventRequests(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ers), check.Equals, 1)
+	ch.Assert(ers[0].Email, check.Equals, result.Email)
+	ch.Assert(string(ers[0].Body), check.Equals, "username=foo")
+	ch.Assert(ers[0].EventId, check.Not(check.Equals), int64(0))
+
+	// Deleting the campaign should remove the captured requests
+	ch.Assert(DeleteCampaign(campaign.Id), check.Equals, nil)
+	count := 0
+	db.Model(&EventRequest{}).Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestFilterEventRequest(c *check.C) {
+	body := "username=foo&password=hunter2"
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+	er, err := NewEventRequest(req, 0)
+	c.Assert(err, check.Equals, nil)
+
+	// Pages which store passwords store the whole request
+	p := Page{CaptureCredentials: true, CapturePasswords: true}
+	c.Assert(p.FilterEventRequest(er), check.Equals, er)
+
+	// Pages which only score passwords never store them
+	p.ScorePasswords = true
+	filtered := p.FilterEventRequest(er)
+	c.Assert(string(filtered.Body), check.Equals, "username=foo")
+	c.Assert(strings.Contains(string(filtered.Headers), "Authorization"), check.Equals, false)
+
+	// Nothing is stored for pages which don't capture credentials
+	p = Page{}
+	c.Assert(string(p.FilterEventRequest(er).Body), check.Equals, "")
+
+	// Bodies which can't be filtered are left out
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+	er, err = NewEventRequest(req, 0)
+	c.Assert(err, check.Equals, nil)
+	p = Page{CaptureCredentials: true}
+	c.Assert(string(p.FilterEventRequest(er).Body), check.Equals, "")
+}
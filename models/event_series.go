@@ -0,0 +1,159 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// The ways event series can be grouped
+const (
+	SeriesByType     = "type"
+	SeriesByCampaign = "campaign"
+)
+
+// MaxSeriesPoints is the most intervals an event series is split into. Longer
+// intervals are used for time ranges which would need more.
+const MaxSeriesPoints = 1000
+
+// DefaultSeriesRange is the time range counted when none is given.
+const DefaultSeriesRange = 24 * time.Hour
+
+// ErrInvalidSeriesGroup is thrown when event series are grouped by something
+// other than the event type or campaign.
+var ErrInvalidSeriesGroup = errors.New("Event series must be grouped by type or campaign")
+
+// ErrInvalidSeriesRange is thrown when an event series ends before it starts.
+var ErrInvalidSeriesRange = errors.New("The time range must end after it starts")
+
+// EventSeriesOptions select the events counted by event series.
+type EventSeriesOptions struct {
+	From     time.Time
+	To       time.Time
+	Interval time.Duration
+	// CampaignId only counts the events of a single campaign, if set.
+	CampaignId int64
+	// GroupBy is either SeriesByType or SeriesByCampaign.
+	GroupBy string
+}
+
+// EventSeries counts the events of a single type or campaign during each
+// interval of a time range.
+type EventSeries struct {
+	Name       string             `json:"name"`
+	CampaignId int64              `json:"campaign_id,omitempty"`
+	Points     []EventSeriesPoint `json:"points"`
+}
+
+// EventSeriesPoint is the number of events during the interval starting at
+// the given time.
+type EventSeriesPoint struct {
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count"`
+}
+
+// normalize fills in the defaults of the options and ensures they're valid.
+func (o *EventSeriesOptions) normalize() error {
+	switch o.GroupBy {
+	case "":
+		o.GroupBy = SeriesByType
+	case SeriesByType, SeriesByCampaign:
+	default:
+		return ErrInvalidSeriesGroup
+	}
+	if o.To.IsZero() {
+		o.To = time.Now().UTC()
+	}
+	if o.From.IsZero() {
+		o.From = o.To.Add(-DefaultSeriesRange)
+	}
+	o.From, o.To = o.From.UTC(), o.To.UTC()
+	if !o.To.After(o.From) {
+		return ErrInvalidSeriesRange
+	}
+	span := o.To.Sub(o.From)
+	if o.Interval < time.Second {
+		o.Interval = span / 100
+	}
+	if min := span / MaxSeriesPoints; o.Interval < min {
+		o.Interval = min
+	}
+	o.Interval = o.Interval.Round(time.Second)
+	if o.Interval < time.Second {
+		o.Interval = time.Second
+	}
+	o.From = o.From.Truncate(o.Interval)
+	return nil
+}
+
+// GetEventSeries counts the events of the user's recipients during each
+// interval of the time range, grouped by the type of event or by campaign.
+// Every series has a point for each interval, including those without any
+// events.
+func GetEventSeries(uid int64, opts EventSeriesOptions) ([]EventSeries, error) {
+	series := []EventSeries{}
+	err := opts.normalize()
+	if err != nil {
+		return series, err
+	}
+	query := reportingDB().Table(allEventsTable(uid)).Select("campaign_id, message, time").
+		Where("email <> '' AND time >= ? AND time < ?", opts.From, opts.To)
+	if opts.CampaignId != 0 {
+		query = query.Where("campaign_id=?", opts.CampaignId)
+	}
+	events := []struct {
+		CampaignId int64
+		Message    string
+		Time       time.Time
+	}{}
+	err = query.Scan(&events).Error
+	if err != nil {
+		return series, err
+	}
+	names := map[int64]string{}
+	if opts.GroupBy == SeriesByCampaign {
+		cs := []Campaign{}
+		err = reportingDB().Table("campaigns").Select("id, name").Where("user_id=?", uid).Scan(&cs).Error
+		if err != nil {
+			return series, err
+		}
+		for _, c := range cs {
+			names[c.Id] = c.Name
+		}
+	}
+	points := int((opts.To.Sub(opts.From) + opts.Interval - 1) / opts.Interval)
+	// Series are keyed by the event type, or by the campaign's id, since
+	// campaigns can share a name.
+	type seriesKey struct {
+		name string
+		cid  int64
+	}
+	counts := map[seriesKey][]int64{}
+	for _, e := range events {
+		key := seriesKey{name: e.Message}
+		if opts.GroupBy == SeriesByCampaign {
+			key = seriesKey{name: names[e.CampaignId], cid: e.CampaignId}
+		}
+		if counts[key] == nil {
+			counts[key] = make([]int64, points)
+		}
+		i := int(e.Time.UTC().Sub(opts.From) / opts.Interval)
+		if i >= 0 && i < points {
+			counts[key][i]++
+		}
+	}
+	for key, c := range counts {
+		s := EventSeries{Name: key.name, CampaignId: key.cid, Points: make([]EventSeriesPoint, points)}
+		for i := range c {
+			s.Points[i] = EventSeriesPoint{Time: opts.From.Add(time.Duration(i) * opts.Interval), Count: c[i]}
+		}
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Name == series[j].Name {
+			return series[i].CampaignId < series[j].CampaignId
+		}
+		return series[i].Name < series[j].Name
+	})
+	return series, nil
+}
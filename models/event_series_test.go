@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestGetEventSeries(ch *check.C) {
+	c := s.createCampaign(ch)
+	start := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	for _, e := range []Event{
+		{Message: EventSent, Time: start.Add(5 * time.Minute)},
+		{Message: EventSent, Time: start.Add(10 * time.Minute)},
+		{Message: EventOpened, Time: start.Add(70 * time.Minute)},
+		{Message: EventClicked, Time: start.Add(125 * time.Minute)},
+		// Events outside of the range aren't counted
+		{Message: EventClicked, Time: start.Add(-time.Minute)},
+		{Message: EventClicked, Time: start.Add(3 * time.Hour)},
+	} {
+		e.CampaignId = c.Id
+		e.Email = c.Results[0].Email
+		ch.Assert(db.Save(&e).Error, check.Equals, nil)
+	}
+	opts := EventSeriesOptions{
+		From:       start,
+		To:         start.Add(3 * time.Hour),
+		Interval:   time.Hour,
+		CampaignId: c.Id,
+	}
+	series, err := GetEventSeries(c.UserId, opts)
+	ch.Assert(err, check.Equals, nil)
+	counts := map[string][]int64{}
+	for _, s := range series {
+		ch.Assert(len(s.Points), check.Equals, 3)
+		ch.Assert(s.Points[1].Time.Equal(start.Add(time.Hour)), check.Equals, true)
+		for _, p := range s.Points {
+			counts[s.Name] = append(counts[s.Name], p.Count)
+		}
+	}
+	ch.Assert(counts, check.DeepEquals, map[string][]int64{
+		EventSent:    {2, 0, 0},
+		EventOpened:  {0, 1, 0},
+		EventClicked: {0, 0, 1},
+	})
+
+	opts.GroupBy = SeriesByCampaign
+	series, err = GetEventSeries(c.UserId, opts)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(series), check.Equals, 1)
+	ch.Assert(series[0].Name, check.Equals, c.Name)
+	ch.Assert(series[0].CampaignId, check.Equals, c.Id)
+	ch.Assert(series[0].Points[0].Count, check.Equals, int64(2))
+
+	opts.GroupBy = "template"
+	_, err = GetEventSeries(c.UserId, opts)
+	ch.Assert(err, check.Equals, ErrInvalidSeriesGroup)
+
+	_, err = GetEventSeries(c.UserId, EventSeriesOptions{From: start, To: start})
+	ch.Assert(err, check.Equals, ErrInvalidSeriesRange)
+}
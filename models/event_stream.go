@@ -0,0 +1,102 @@
+package models
+
+import (
+	"sync"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// EventStreamBufferSize is the number of events buffered for each subscriber.
+// If a subscriber falls further behind than this, its subscription is closed
+// so that it can reconnect and catch up on the events it missed.
+const EventStreamBufferSize = 256
+
+// EventSubscription receives campaign events as they're added. Events are
+// received on C, which is closed if the subscription falls too far behind.
+type EventSubscription struct {
+	C          <-chan Event
+	c          chan Event
+	userId     int64
+	campaignId int64
+}
+
+var eventSubscriptions = struct {
+	sync.Mutex
+	subs map[*EventSubscription]bool
+}{subs: make(map[*EventSubscription]bool)}
+
+// SubscribeEvents returns a subscription to the events for the campaigns
+// owned by the given user. If a campaign id is provided, only the events for
+// that campaign are received.
+func SubscribeEvents(uid int64, cid int64) *EventSubscription {
+	c := make(chan Event, EventStreamBufferSize)
+	s := &EventSubscription{
+		C:          c,
+		c:          c,
+		userId:     uid,
+		campaignId: cid,
+	}
+	eventSubscriptions.Lock()
+	eventSubscriptions.subs[s] = true
+	eventSubscriptions.Unlock()
+	return s
+}
+
+// Close stops the subscription from receiving any more events.
+func (s *EventSubscription) Close() {
+	eventSubscriptions.Lock()
+	defer eventSubscriptions.Unlock()
+	s.close()
+}
+
+// close removes the subscription. The caller must hold the lock.
+func (s *EventSubscription) close() {
+	if eventSubscriptions.subs[s] {
+		delete(eventSubscriptions.subs, s)
+		close(s.c)
+	}
+}
+
+// publishEvent sends the event to any matching subscriptions.
+func publishEvent(e Event) {
+	eventSubscriptions.Lock()
+	empty := len(eventSubscriptions.subs) == 0
+	eventSubscriptions.Unlock()
+	if empty {
+		return
+	}
+	c := Campaign{}
+	err := db.Table("campaigns").Select("user_id").Where("id=?", e.CampaignId).Find(&c).Error
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	eventSubscriptions.Lock()
+	defer eventSubscriptions.Unlock()
+	for s := range eventSubscriptions.subs {
+		if s.userId != c.UserId || (s.campaignId != 0 && s.campaignId != e.CampaignId) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			log.Warnf("event subscription for user %d fell behind, closing", s.userId)
+			s.close()
+		}
+	}
+}
+
+// GetEventsSince returns the events with an id greater than the given id for
+// the campaigns owned by the given user. If a campaign id is provided, only
+// the events for that campaign are returned. This lets subscribers catch up
+// on events they missed while disconnected.
+func GetEventsSince(uid int64, cid int64, id int64) ([]Event, error) {
+	es := []Event{}
+	query := db.Table("events").Where("id > ?", id).
+		Where("campaign_id IN (SELECT id FROM campaigns WHERE user_id = ?)", uid)
+	if cid != 0 {
+		query = query.Where("campaign_id = ?", cid)
+	}
+	err := query.Order("id asc").Find(&es).Error
+	return es, err
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/mailer"
+)
+
+// The formats of the Message-ID header which sending profiles can use
+const (
+	// MessageIdDefault is Gophish's format, which includes the time and the
+	// process id.
+	MessageIdDefault = ""
+	// MessageIdUUID is a random UUID, as used by many webmail services.
+	MessageIdUUID = "uuid"
+	// MessageIdRandom is a string of random letters and digits.
+	MessageIdRandom = "random"
+)
+
+// ErrInvalidMessageIdFormat is returned when a sending profile has an
+// unknown Message-ID format.
+var ErrInvalidMessageIdFormat = errors.New("Invalid Message-ID format")
+
+// ErrInvalidMessageIdDomain is returned when a sending profile's Message-ID
+// domain isn't a valid domain.
+var ErrInvalidMessageIdDomain = errors.New("Invalid Message-ID domain")
+
+// ErrInvalidBoundaryPrefix is returned when a sending profile's MIME boundary
+// prefix contains characters which aren't allowed in boundaries, or is too
+// long.
+var ErrInvalidBoundaryPrefix = fmt.Errorf("The boundary prefix can only contain letters, digits and the characters '()+_,-./:=? and must be at most %d characters", mailer.MaxBoundaryPrefix)
+
+// ErrInvalidHeaderOrder is returned when a sending profile's header order
+// contains invalid header names.
+var ErrInvalidHeaderOrder = errors.New("The header order must be a comma separated list of header names")
+
+var messageIdDomain = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// validateFingerprint checks the settings which change the sending profile's
+// message headers and structure.
+func (s *SMTP) validateFingerprint() error {
+	switch s.MessageIdFormat {
+	case MessageIdDefault, MessageIdUUID, MessageIdRandom:
+	default:
+		return ErrInvalidMessageIdFormat
+	}
+	if s.MessageIdDomain != "" && !messageIdDomain.MatchString(s.MessageIdDomain) {
+		return ErrInvalidMessageIdDomain
+	}
+	if !mailer.ValidBoundaryPrefix(s.BoundaryPrefix) {
+		return ErrInvalidBoundaryPrefix
+	}
+	for _, name := range s.headerOrder() {
+		if !mailer.ValidHeaderName(name) {
+			return ErrInvalidHeaderOrder
+		}
+	}
+	return nil
+}
+
+// headerOrder returns the names of the headers which are written first, in
+// order.
+func (s *SMTP) headerOrder() []string {
+	names := []string{}
+	for _, name := range strings.Split(s.HeaderOrder, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// setMessageHeaders sets the X-Mailer and Message-Id headers, following the
+// sending profile's settings.
+func (s *SMTP) setMessageHeaders(msg *gomail.Message) error {
+	if !s.RemoveMailerHeader {
+		msg.SetHeader("X-Mailer", config.ServerName)
+	}
+	messageID, err := s.messageID()
+	if err != nil {
+		return err
+	}
+	msg.SetHeader("Message-Id", messageID)
+	return nil
+}
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	// Set the version (4) and variant bits
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// messageID returns a new Message-ID in the sending profile's format.
+func (s *SMTP) messageID() (string, error) {
+	if s.MessageIdFormat == MessageIdDefault {
+		id, err := generateMessageID()
+		if err != nil || s.MessageIdDomain == "" {
+			return id, err
+		}
+		return id[:strings.LastIndex(id, "@")+1] + s.MessageIdDomain + ">", nil
+	}
+	domain := s.MessageIdDomain
+	if domain == "" {
+		h, err := os.Hostname()
+		if err != nil {
+			h = "localhost.localdomain"
+		}
+		domain = h
+	}
+	var local string
+	switch s.MessageIdFormat {
+	case MessageIdUUID:
+		id, err := NewUUID()
+		if err != nil {
+			return "", err
+		}
+		local = id
+	case MessageIdRandom:
+		r, err := randomString(32, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789")
+		if err != nil {
+			return "", err
+		}
+		local = r
+	}
+	return fmt.Sprintf("<%s@%s>", local, domain), nil
+}
+
+// RewriteMessage changes the MIME boundaries and the order of the headers of
+// a generated message, following the sending profile's settings.
+func (s *SMTP) RewriteMessage(raw []byte) ([]byte, error) {
+	if s.BoundaryPrefix != "" {
+		var err error
+		raw, err = mailer.RewriteBoundaries(raw, s.BoundaryPrefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if order := s.headerOrder(); len(order) > 0 {
+		raw = mailer.ReorderHeaders(raw, order)
+	}
+	return raw, nil
+}
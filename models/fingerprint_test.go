@@ -0,0 +1,91 @@
+package models
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"github.com/jordan-wright/email"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestSMTPValidateFingerprint(ch *check.C) {
+	smtp := SMTP{Host: "example.com:25", FromAddress: "foo@example.com"}
+	smtp.MessageIdFormat = "unknown"
+	ch.Assert(smtp.Validate(), check.Equals, ErrInvalidMessageIdFormat)
+	smtp.MessageIdFormat = MessageIdUUID
+	smtp.MessageIdDomain = "mail.example.com>"
+	ch.Assert(smtp.Validate(), check.Equals, ErrInvalidMessageIdDomain)
+	smtp.MessageIdDomain = "mail.example.com"
+	smtp.BoundaryPrefix = "has space"
+	ch.Assert(smtp.Validate(), check.Equals, ErrInvalidBoundaryPrefix)
+	smtp.BoundaryPrefix = "----=_NextPart_"
+	smtp.HeaderOrder = "From, To Address"
+	ch.Assert(smtp.Validate(), check.Equals, ErrInvalidHeaderOrder)
+	smtp.HeaderOrder = "From, To"
+	ch.Assert(smtp.Validate(), check.Equals, nil)
+}
+
+func (s *ModelsSuite) TestSMTPMessageID(ch *check.C) {
+	tests := []struct {
+		format   string
+		domain   string
+		expected *regexp.Regexp
+	}{
+		{MessageIdDefault, "", regexp.MustCompile(`^<\d+\.\d+\.\d+@[^>]+>$`)},
+		{MessageIdDefault, "mail.example.com", regexp.MustCompile(`^<\d+\.\d+\.\d+@mail\.example\.com>$`)},
+		{MessageIdUUID, "mail.example.com", regexp.MustCompile(`^<[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}@mail\.example\.com>$`)},
+		{MessageIdRandom, "mail.example.com", regexp.MustCompile(`^<[A-Za-z0-9]{32}@mail\.example\.com>$`)},
+	}
+	for _, test := range tests {
+		smtp := SMTP{MessageIdFormat: test.format, MessageIdDomain: test.domain}
+		id, err := smtp.messageID()
+		ch.Assert(err, check.Equals, nil)
+		ch.Assert(test.expected.MatchString(id), check.Equals, true, check.Commentf("unexpected Message-ID %s", id))
+	}
+}
+
+func (s *ModelsSuite) TestSMTPRewriteMessage(ch *check.C) {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", "foo@example.com")
+	msg.SetHeader("To", "bar@example.com")
+	msg.SetHeader("Subject", "Hello")
+	msg.SetBody("text/plain", "Text")
+	msg.AddAlternative("text/html", "<p>HTML</p>")
+	buf := &bytes.Buffer{}
+	_, err := msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+
+	smtp := SMTP{BoundaryPrefix: "----=_NextPart_", HeaderOrder: "Subject, From"}
+	raw, err := smtp.RewriteMessage(buf.Bytes())
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.HasPrefix(string(raw), "Subject: Hello\r\nFrom: foo@example.com\r\n"), check.Equals, true)
+	ch.Assert(strings.Contains(string(raw), `boundary="----=_NextPart_`), check.Equals, true)
+
+	// The rewritten message is still parsed correctly
+	got, err := email.NewEmailFromReader(bytes.NewReader(raw))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(got.Text), check.Equals, "Text")
+	ch.Assert(string(got.HTML), check.Equals, "<p>HTML</p>")
+	ch.Assert(got.To, check.DeepEquals, []string{"bar@example.com"})
+}
+
+func (s *ModelsSuite) TestMailLogGenerateFingerprint(ch *check.C) {
+	smtp := SMTP{
+		Name:               "Fingerprint SMTP",
+		Host:               "1.1.1.1:25",
+		FromAddress:        "Foo Bar <foo@example.com>",
+		UserId:             1,
+		MessageIdFormat:    MessageIdRandom,
+		MessageIdDomain:    "mail.example.com",
+		RemoveMailerHeader: true,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+	campaign := s.createCampaignDependencies(ch)
+	campaign.SMTP = smtp
+	ch.Assert(PostCampaign(&campaign, campaign.UserId), check.Equals, nil)
+	got := s.emailFromFirstMailLog(campaign, ch)
+	ch.Assert(got.Headers.Get("X-Mailer"), check.Equals, "")
+	ch.Assert(strings.HasSuffix(got.Headers.Get("Message-Id"), "@mail.example.com>"), check.Equals, true)
+}
@@ -17,8 +17,17 @@ type Group struct {
 	Id           int64     `json:"id"`
 	UserId       int64     `json:"-"`
 	Name         string    `json:"name"`
+	ExternalId   string    `json:"external_id,omitempty"`
 	ModifiedDate time.Time `json:"modified_date"`
 	Targets      []Target  `json:"targets" sql:"-"`
+	// DefaultSMTPId and DefaultPageId are the sending profile and landing
+	// page used by campaigns sent to the group which don't choose their own.
+	DefaultSMTPId int64 `json:"default_smtp_id"`
+	DefaultPageId int64 `json:"default_page_id"`
+	// DefaultLanguage is the language of targets in the group who don't
+	// have their own, which chooses the template and landing page
+	// translations they're sent.
+	DefaultLanguage string `json:"default_language"`
 }
 
 // GroupSummaries is a struct representing the overview of Groups.
@@ -48,6 +57,11 @@ type GroupTarget struct {
 type Target struct {
 	Id int64 `json:"-"`
 	BaseRecipient
+	// Status is the target's lifecycle state, such as TargetOnLeave.
+	Status string `json:"status"`
+	// Tags are a comma separated list of tags, such as "vip,finance",
+	// which campaigns can include or exclude targets by.
+	Tags string `json:"tags"`
 }
 
 // BaseRecipient contains the fields for a single recipient. This is the base
@@ -57,15 +71,18 @@ type BaseRecipient struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Position  string `json:"position"`
+	// Language is the recipient's preferred language, such as "fr" or
+	// "pt-BR", used to choose localized templates and landing pages.
+	Language string `json:"language"`
 }
 
 // FormatAddress returns the email address to use in the "To" header of the email
 func (r *BaseRecipient) FormatAddress() string {
-	addr := r.Email
+	addr := asciiAddress(r.Email)
 	if r.FirstName != "" && r.LastName != "" {
 		a := &mail.Address{
 			Name:    fmt.Sprintf("%s %s", r.FirstName, r.LastName),
-			Address: r.Email,
+			Address: addr,
 		}
 		addr = a.String()
 	}
@@ -74,11 +91,11 @@ func (r *BaseRecipient) FormatAddress() string {
 
 // FormatAddress returns the email address to use in the "To" header of the email
 func (t *Target) FormatAddress() string {
-	addr := t.Email
+	addr := asciiAddress(t.Email)
 	if t.FirstName != "" && t.LastName != "" {
 		a := &mail.Address{
 			Name:    fmt.Sprintf("%s %s", t.FirstName, t.LastName),
-			Address: t.Email,
+			Address: addr,
 		}
 		addr = a.String()
 	}
@@ -102,16 +119,49 @@ func (g *Group) Validate() error {
 	case len(g.Targets) == 0:
 		return ErrNoTargetsSpecified
 	}
+	if err := validateLanguage(g.DefaultLanguage); err != nil {
+		return err
+	}
+	g.DefaultLanguage = normalizeLanguage(g.DefaultLanguage)
+	for i := range g.Targets {
+		t := &g.Targets[i]
+		if err := validateLanguage(t.Language); err != nil {
+			return fmt.Errorf("%s: %s", err, t.Email)
+		}
+		t.Language = normalizeLanguage(t.Language)
+		if !validTargetStatus(t.Status) {
+			return fmt.Errorf("%s: %s", ErrInvalidTargetStatus, t.Email)
+		}
+		t.Tags = normalizeTags(t.Tags)
+	}
 	return nil
 }
 
 // GetGroups returns the groups owned by the given user.
 func GetGroups(uid int64) ([]Group, error) {
+	gs, _, err := ListGroups(uid, ListOptions{})
+	return gs, err
+}
+
+// groupListFields are the fields groups can be sorted and filtered by
+var groupListFields = listFields{
+	"id":            "id",
+	"name":          "name",
+	"modified_date": "modified_date",
+}
+
+// ListGroups returns the groups owned by the given user, filtered, sorted,
+// and paginated using the given options.
+func ListGroups(uid int64, opts ListOptions) ([]Group, PageInfo, error) {
 	gs := []Group{}
-	err := db.Where("user_id=?", uid).Find(&gs).Error
+	query, total, err := opts.apply(db.Table("groups").Where("user_id=?", uid), groupListFields)
+	if err != nil {
+		return gs, PageInfo{}, err
+	}
+	err = query.Find(&gs).Error
 	if err != nil {
 		log.Error(err)
-		return gs, err
+		return gs, PageInfo{}, err
 	}
 	for i := range gs {
 		gs[i].Targets, err = GetTargets(gs[i].Id)
@@ -119,28 +169,39 @@ func GetGroups(uid int64) ([]Group, error) {
 			log.Error(err)
 		}
 	}
-	return gs, nil
+	return gs, opts.pageInfo(&gs, total, groupListFields), nil
 }
 
 // GetGroupSummaries returns the summaries for the groups
 // created by the given uid.
 func GetGroupSummaries(uid int64) (GroupSummaries, error) {
+	gs, _, err := ListGroupSummaries(uid, ListOptions{})
+	return gs, err
+}
+
+// ListGroupSummaries returns the summaries for the groups created by the
+// given uid, filtered, sorted, and paginated using the given options. The
+// total is the number of groups matching the filters.
+func ListGroupSummaries(uid int64, opts ListOptions) (GroupSummaries, PageInfo, error) {
 	gs := GroupSummaries{}
-	query := db.Table("groups").Where("user_id=?", uid)
-	err := query.Select("id, name, modified_date").Scan(&gs.Groups).Error
+	query, total, err := opts.apply(db.Table("groups").Where("user_id=?", uid), groupListFields)
+	if err != nil {
+		return gs, PageInfo{}, err
+	}
+	err = query.Select("id, name, modified_date").Scan(&gs.Groups).Error
 	if err != nil {
 		log.Error(err)
-		return gs, err
+		return gs, PageInfo{}, err
 	}
 	for i := range gs.Groups {
 		query = db.Table("group_targets").Where("group_id=?", gs.Groups[i].Id)
 		err = query.Count(&gs.Groups[i].NumTargets).Error
 		if err != nil {
-			return gs, err
+			return gs, PageInfo{}, err
 		}
 	}
-	gs.Total = int64(len(gs.Groups))
-	return gs, nil
+	gs.Total = total
+	return gs, opts.pageInfo(&gs.Groups, total, groupListFields), nil
 }
 
 // GetGroup returns the group, if it exists, specified by the given id and user_id.
@@ -195,6 +256,12 @@ func PostGroup(g *Group) error {
 	if err := g.Validate(); err != nil {
 		return err
 	}
+	if err := g.validateDefaults(); err != nil {
+		return err
+	}
+	if err := validateExternalId("groups", g.ExternalId, g.UserId, g.Id); err != nil {
+		return err
+	}
 	// Insert the group into the DB
 	tx := db.Begin()
 	err := tx.Save(g).Error
@@ -225,6 +292,12 @@ func PutGroup(g *Group) error {
 	if err := g.Validate(); err != nil {
 		return err
 	}
+	if err := g.validateDefaults(); err != nil {
+		return err
+	}
+	if err := validateExternalId("groups", g.ExternalId, g.UserId, g.Id); err != nil {
+		return err
+	}
 	// Fetch group's existing targets from database.
 	ts, err := GetTargets(g.Id)
 	if err != nil {
@@ -319,6 +392,7 @@ func insertTargetIntoGroup(tx *gorm.DB, t Target, gid int64) error {
 		}).Error("Invalid email")
 		return err
 	}
+	t.Tags = normalizeTags(t.Tags)
 	err := tx.Where(t).FirstOrCreate(&t).Error
 	if err != nil {
 		log.WithFields(logrus.Fields{
@@ -346,6 +420,12 @@ func UpdateTarget(tx *gorm.DB, target Target) error {
 		"first_name": target.FirstName,
 		"last_name":  target.LastName,
 		"position":   target.Position,
+		"language":   target.Language,
+		"tags":       normalizeTags(target.Tags),
+	}
+	// Targets keep their status unless a new one is given
+	if target.Status != "" {
+		targetInfo["status"] = target.Status
 	}
 	err := tx.Model(&target).Where("id = ?", target.Id).Updates(targetInfo).Error
 	if err != nil {
@@ -359,6 +439,121 @@ func UpdateTarget(tx *gorm.DB, target Target) error {
 // GetTargets performs a many-to-many select to get all the Targets for a Group
 func GetTargets(gid int64) ([]Target, error) {
 	ts := []Target{}
-	err := db.Table("targets").Select("targets.id, targets.email, targets.first_name, targets.last_name, targets.position").Joins("left join group_targets gt ON targets.id = gt.target_id").Where("gt.group_id=?", gid).Scan(&ts).Error
+	err := db.Table("targets").Select("targets.id, targets.email, targets.first_name, targets.last_name, targets.position, targets.language, targets.status, targets.tags").Joins("left join group_targets gt ON targets.id = gt.target_id").Where("gt.group_id=?", gid).Scan(&ts).Error
 	return ts, err
 }
+
+// BulkTargetResult describes the changes made by a bulk operation on the
+// targets in a group. Emails which weren't found in the group when updating
+// or removing targets are listed in NotFound.
+type BulkTargetResult struct {
+	Added    int      `json:"added"`
+	Updated  int      `json:"updated"`
+	Removed  int      `json:"removed"`
+	NotFound []string `json:"not_found"`
+}
+
+// AddTargets adds the targets to the group owned by the given user, without
+// replacing the rest of the group. Targets already in the group are updated.
+func AddTargets(gid int64, uid int64, ts []Target) (BulkTargetResult, error) {
+	return modifyTargets(gid, uid, ts, func(tx *gorm.DB, t Target, existing map[string]int64, result *BulkTargetResult) error {
+		if id, ok := existing[t.Email]; ok {
+			t.Id = id
+			result.Updated++
+			return UpdateTarget(tx, t)
+		}
+		result.Added++
+		return insertTargetIntoGroup(tx, t, gid)
+	})
+}
+
+// UpdateTargets updates the details of the targets in the group owned by the
+// given user, matching targets by email address.
+func UpdateTargets(gid int64, uid int64, ts []Target) (BulkTargetResult, error) {
+	return modifyTargets(gid, uid, ts, func(tx *gorm.DB, t Target, existing map[string]int64, result *BulkTargetResult) error {
+		id, ok := existing[t.Email]
+		if !ok {
+			result.NotFound = append(result.NotFound, t.Email)
+			return nil
+		}
+		t.Id = id
+		result.Updated++
+		return UpdateTarget(tx, t)
+	})
+}
+
+// RemoveTargets removes the targets with the given email addresses from the
+// group owned by the given user.
+func RemoveTargets(gid int64, uid int64, ts []Target) (BulkTargetResult, error) {
+	return modifyTargets(gid, uid, ts, func(tx *gorm.DB, t Target, existing map[string]int64, result *BulkTargetResult) error {
+		id, ok := existing[t.Email]
+		if !ok {
+			result.NotFound = append(result.NotFound, t.Email)
+			return nil
+		}
+		result.Removed++
+		return tx.Where("group_id=? and target_id=?", gid, id).Delete(&GroupTarget{}).Error
+	})
+}
+
+// modifyTargets applies the operation to each of the targets in a single
+// transaction. If a target appears more than once, only the last occurrence
+// is used.
+func modifyTargets(gid int64, uid int64, ts []Target, op func(*gorm.DB, Target, map[string]int64, *BulkTargetResult) error) (BulkTargetResult, error) {
+	result := BulkTargetResult{NotFound: []string{}}
+	if len(ts) == 0 {
+		return result, ErrNoTargetsSpecified
+	}
+	g := Group{}
+	err := db.Where("user_id=? and id=?", uid, gid).Find(&g).Error
+	if err != nil {
+		return result, err
+	}
+	// Only the last occurrence of each email is kept, in its original order
+	last := make(map[string]int, len(ts))
+	for i, t := range ts {
+		if t.Email == "" {
+			return result, ErrEmailNotSpecified
+		}
+		if !validTargetStatus(t.Status) {
+			return result, ErrInvalidTargetStatus
+		}
+		last[t.Email] = i
+	}
+	existingTargets, err := GetTargets(gid)
+	if err != nil {
+		log.Error(err)
+		return result, err
+	}
+	existing := make(map[string]int64, len(existingTargets))
+	for _, t := range existingTargets {
+		existing[t.Email] = t.Id
+	}
+	tx := db.Begin()
+	for i, t := range ts {
+		if last[t.Email] != i {
+			continue
+		}
+		err = op(tx, t, existing, &result)
+		if err != nil {
+			tx.Rollback()
+			log.WithFields(logrus.Fields{
+				"group_id": gid,
+				"email":    t.Email,
+			}).Error(err)
+			return BulkTargetResult{NotFound: []string{}}, err
+		}
+	}
+	err = tx.Model(&g).Update("modified_date", time.Now().UTC()).Error
+	if err != nil {
+		tx.Rollback()
+		log.Error(err)
+		return BulkTargetResult{NotFound: []string{}}, err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		tx.Rollback()
+		return BulkTargetResult{NotFound: []string{}}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrGroupDefaultsConflict is thrown when a campaign inherits its sending
+// profile or landing page from groups whose defaults differ
+var ErrGroupDefaultsConflict = errors.New("The campaign's groups have different default sending profiles or landing pages")
+
+// validateDefaults ensures the group's default sending profile and landing
+// page belong to the group's owner.
+func (g *Group) validateDefaults() error {
+	if g.DefaultSMTPId != 0 {
+		count := 0
+		err := db.Table("smtp").Where("user_id=? and id=?", g.UserId, g.DefaultSMTPId).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrSMTPNotFound
+		}
+	}
+	if g.DefaultPageId != 0 {
+		count := 0
+		err := db.Table("pages").Where("user_id=? and id=?", g.UserId, g.DefaultPageId).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrPageNotFound
+		}
+	}
+	return nil
+}
+
+// applyGroupDefaults fills in the campaign's sending profile and landing page
+// from the defaults of its groups, if the campaign doesn't choose its own.
+// Groups without a default are ignored, but the groups which have one must
+// agree.
+func (c *Campaign) applyGroupDefaults(uid int64) error {
+	if c.SMTP.Name != "" && c.Page.Name != "" {
+		return nil
+	}
+	var smtpId, pageId int64
+	for _, g := range c.Groups {
+		group := Group{}
+		err := db.Where("user_id=? and name=?", uid, g.Name).Find(&group).Error
+		if err == gorm.ErrRecordNotFound {
+			// Missing groups are reported when the campaign is created
+			continue
+		} else if err != nil {
+			return err
+		}
+		if c.SMTP.Name == "" && group.DefaultSMTPId != 0 {
+			if smtpId != 0 && smtpId != group.DefaultSMTPId {
+				return ErrGroupDefaultsConflict
+			}
+			smtpId = group.DefaultSMTPId
+		}
+		if c.Page.Name == "" && group.DefaultPageId != 0 {
+			if pageId != 0 && pageId != group.DefaultPageId {
+				return ErrGroupDefaultsConflict
+			}
+			pageId = group.DefaultPageId
+		}
+	}
+	if smtpId != 0 {
+		s := SMTP{}
+		err := db.Where("user_id=? and id=?", uid, smtpId).Find(&s).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		c.SMTP.Name = s.Name
+	}
+	if pageId != 0 {
+		p := Page{}
+		err := db.Where("user_id=? and id=?", uid, pageId).Find(&p).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		c.Page.Name = p.Name
+	}
+	return nil
+}
+
+// applyDefaultLanguage gives the group's default language to its targets
+// which don't have their own.
+func (g *Group) applyDefaultLanguage() {
+	for i := range g.Targets {
+		if g.Targets[i].Language == "" {
+			g.Targets[i].Language = g.DefaultLanguage
+		}
+	}
+}
+
+// clearGroupDefaults removes the deleted sending profile or landing page from
+// the defaults of the user's groups.
+func clearGroupDefaults(column string, id int64, uid int64) error {
+	return db.Model(&Group{}).Where("user_id=? and "+column+"=?", uid, id).Update(column, 0).Error
+}
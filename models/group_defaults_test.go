@@ -0,0 +1,57 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestGroupDefaults(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	g.DefaultSMTPId = c.SMTP.Id + 100
+	ch.Assert(PutGroup(&g), check.Equals, ErrSMTPNotFound)
+	g.DefaultSMTPId = c.SMTP.Id
+	g.DefaultPageId = c.Page.Id + 100
+	ch.Assert(PutGroup(&g), check.Equals, ErrPageNotFound)
+	g.DefaultPageId = c.Page.Id
+	g.DefaultLanguage = "not a language!"
+	ch.Assert(PutGroup(&g), check.Equals, ErrInvalidLanguage)
+	g.DefaultLanguage = "FR_ca"
+	g.Targets[0].Language = "de"
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+	ch.Assert(g.DefaultLanguage, check.Equals, "fr-ca")
+
+	// Campaigns which don't choose a sending profile or landing page use the
+	// group's, and targets without a language use the group's
+	c.SMTP = SMTP{}
+	c.Page = Page{}
+	c.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.SMTPId, check.Equals, g.DefaultSMTPId)
+	ch.Assert(c.PageId, check.Equals, g.DefaultPageId)
+	languages := map[string]string{}
+	for _, r := range c.Results {
+		languages[r.Email] = r.Language
+	}
+	ch.Assert(languages[g.Targets[0].Email], check.Equals, "de")
+	ch.Assert(languages[g.Targets[1].Email], check.Equals, "fr-ca")
+
+	// Groups with different defaults conflict
+	other := SMTP{Name: "Other SMTP", UserId: 1, Host: "example.org", FromAddress: "test@example.org"}
+	ch.Assert(PostSMTP(&other), check.Equals, nil)
+	og := Group{Name: "Other Group", UserId: 1, DefaultSMTPId: other.Id}
+	og.Targets = []Target{{BaseRecipient: BaseRecipient{Email: "other@example.com"}}}
+	ch.Assert(PostGroup(&og), check.Equals, nil)
+	c2 := Campaign{Name: "Conflicting", Template: Template{Name: c.Template.Name}}
+	c2.Groups = []Group{{Name: g.Name}, {Name: og.Name}}
+	ch.Assert(PostCampaign(&c2, c.UserId), check.Equals, ErrGroupDefaultsConflict)
+	// Choosing a sending profile resolves the conflict
+	c2.SMTP = SMTP{Name: other.Name}
+	ch.Assert(PostCampaign(&c2, c.UserId), check.Equals, nil)
+	ch.Assert(c2.PageId, check.Equals, g.DefaultPageId)
+
+	// Deleting the sending profile removes it from the group's defaults
+	ch.Assert(DeleteSMTP(other.Id, 1), check.Equals, nil)
+	og, err := GetGroup(og.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(og.DefaultSMTPId, check.Equals, int64(0))
+}
@@ -174,6 +174,64 @@ func (s *ModelsSuite) TestPutGroupEmptyAttribute(c *check.C) {
 	c.Assert(targets[1].LastName, check.Equals, "Example")
 }
 
+func (s *ModelsSuite) TestBulkModifyTargets(c *check.C) {
+	group := Group{Name: "Test Group"}
+	group.Targets = []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "test1@example.com", FirstName: "First", LastName: "Example"}},
+		Target{BaseRecipient: BaseRecipient{Email: "test2@example.com", FirstName: "Second", LastName: "Example"}},
+	}
+	group.UserId = 1
+	c.Assert(PostGroup(&group), check.Equals, nil)
+
+	// Adding an existing target updates it, rather than duplicating it.
+	result, err := AddTargets(group.Id, 1, []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "test2@example.com", FirstName: "Updated"}},
+		Target{BaseRecipient: BaseRecipient{Email: "test3@example.com", FirstName: "Third"}},
+	})
+	c.Assert(err, check.Equals, nil)
+	c.Assert(result.Added, check.Equals, 1)
+	c.Assert(result.Updated, check.Equals, 1)
+	targets, _ := GetTargets(group.Id)
+	c.Assert(len(targets), check.Equals, 3)
+	c.Assert(targets[1].FirstName, check.Equals, "Updated")
+	c.Assert(targets[2].Email, check.Equals, "test3@example.com")
+
+	result, err = UpdateTargets(group.Id, 1, []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "test1@example.com", Position: "Manager"}},
+		Target{BaseRecipient: BaseRecipient{Email: "missing@example.com"}},
+	})
+	c.Assert(err, check.Equals, nil)
+	c.Assert(result.Updated, check.Equals, 1)
+	c.Assert(result.NotFound, check.DeepEquals, []string{"missing@example.com"})
+	targets, _ = GetTargets(group.Id)
+	c.Assert(targets[0].Position, check.Equals, "Manager")
+
+	result, err = RemoveTargets(group.Id, 1, []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "test1@example.com"}},
+		Target{BaseRecipient: BaseRecipient{Email: "test1@example.com"}},
+	})
+	c.Assert(err, check.Equals, nil)
+	c.Assert(result.Removed, check.Equals, 1)
+	targets, _ = GetTargets(group.Id)
+	c.Assert(len(targets), check.Equals, 2)
+	c.Assert(targets[0].Email, check.Equals, "test2@example.com")
+
+	// Other users can't modify the group
+	_, err = AddTargets(group.Id, 2, []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "test4@example.com"}},
+	})
+	c.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+
+	// Invalid targets roll back the whole operation
+	_, err = AddTargets(group.Id, 1, []Target{
+		Target{BaseRecipient: BaseRecipient{Email: "test4@example.com"}},
+		Target{BaseRecipient: BaseRecipient{Email: "invalid"}},
+	})
+	c.Assert(err, check.NotNil)
+	targets, _ = GetTargets(group.Id)
+	c.Assert(len(targets), check.Equals, 2)
+}
+
 func benchmarkPostGroup(b *testing.B, iter, size int) {
 	b.StopTimer()
 	g := &Group{
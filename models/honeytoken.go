@@ -0,0 +1,240 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// HoneytokenSourceLandingPage is the source of sightings from credentials
+// submitted to a landing page.
+const HoneytokenSourceLandingPage = "landing_page"
+
+// ErrHoneytokenNotFound is returned when a sighting is reported for a
+// username which isn't a honeytoken.
+var ErrHoneytokenNotFound = errors.New("Honeytoken not found")
+
+// ErrHoneytokenSourceNotSpecified is returned when a sighting is reported
+// without saying where the credentials were seen.
+var ErrHoneytokenSourceNotSpecified = errors.New("The source of the sighting must be specified")
+
+// Honeytoken is a set of credentials which are unique to a campaign. They
+// can be planted in the campaign's emails and landing page using the
+// {{.HoneytokenUsername}} and {{.HoneytokenPassword}} template variables.
+// Since they don't belong to anybody, any later use of them means that the
+// lure has been picked up by someone other than its recipients, such as an
+// attacker testing harvested credentials.
+type Honeytoken struct {
+	Id          int64                `json:"id"`
+	CampaignId  int64                `json:"campaign_id"`
+	UserId      int64                `json:"-"`
+	Username    string               `json:"username"`
+	Password    EncryptedString      `json:"password"`
+	CreatedDate time.Time            `json:"created_date"`
+	Sightings   []HoneytokenSighting `json:"sightings" gorm:"-"`
+}
+
+// HoneytokenSighting records a honeytoken being used.
+type HoneytokenSighting struct {
+	Id           int64  `json:"id"`
+	HoneytokenId int64  `json:"honeytoken_id"`
+	CampaignId   int64  `json:"campaign_id"`
+	UserId       int64  `json:"-"`
+	Username     string `json:"username"`
+	// Source is where the credentials were seen, which is either the landing
+	// page or the name of the external system reporting them.
+	Source string `json:"source"`
+	// PasswordMatched is set when the honeytoken's password was seen along
+	// with its username.
+	PasswordMatched bool      `json:"password_matched"`
+	Address         string    `json:"address,omitempty"`
+	Details         string    `json:"details,omitempty"`
+	SeenDate        time.Time `json:"seen_date"`
+}
+
+// HoneytokenReport is a sighting of a honeytoken reported by an external
+// system, such as a SIEM or an identity provider.
+type HoneytokenReport struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Source   string `json:"source"`
+	Address  string `json:"address"`
+	Details  string `json:"details"`
+}
+
+// WebhookType returns the type of the payload sent to webhooks.
+func (HoneytokenSighting) WebhookType() string {
+	return "honeytoken_sighting"
+}
+
+const (
+	honeytokenUsernameChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	honeytokenPasswordChars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!#$%*"
+)
+
+// randomString returns a random string of n characters from the alphabet.
+func randomString(n int, alphabet string) (string, error) {
+	k := make([]byte, n)
+	for i := range k {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		k[i] = alphabet[idx.Int64()]
+	}
+	return string(k), nil
+}
+
+// createHoneytoken generates the honeytoken for a new campaign. The
+// username looks like a service account, so that it's plausible in lures
+// which appear to leak credentials.
+func createHoneytoken(c *Campaign) error {
+	username, err := randomString(8, honeytokenUsernameChars)
+	if err != nil {
+		return err
+	}
+	password, err := randomString(16, honeytokenPasswordChars)
+	if err != nil {
+		return err
+	}
+	ht := Honeytoken{
+		CampaignId:  c.Id,
+		UserId:      c.UserId,
+		Username:    "svc-" + username,
+		Password:    EncryptedString(password),
+		CreatedDate: time.Now().UTC(),
+	}
+	return db.Save(&ht).Error
+}
+
+// GetHoneytokens returns the user's honeytokens along with their sightings.
+// If a campaign id is given, only that campaign's honeytoken is returned.
+func GetHoneytokens(uid int64, cid int64) ([]Honeytoken, error) {
+	hts := []Honeytoken{}
+	query := db.Where("user_id=?", uid)
+	if cid != 0 {
+		query = query.Where("campaign_id=?", cid)
+	}
+	err := query.Order("id asc").Find(&hts).Error
+	if err != nil {
+		log.Error(err)
+		return hts, err
+	}
+	for i := range hts {
+		hts[i].Sightings = []HoneytokenSighting{}
+		err = db.Where("honeytoken_id=?", hts[i].Id).Order("seen_date asc").Find(&hts[i].Sightings).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			log.Error(err)
+			return hts, err
+		}
+	}
+	return hts, nil
+}
+
+// getHoneytoken returns the campaign's honeytoken, which is empty if the
+// campaign doesn't have one.
+func (c *Campaign) getHoneytoken() Honeytoken {
+	ht := Honeytoken{}
+	err := db.Where("campaign_id=?", c.Id).First(&ht).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(err)
+	}
+	return ht
+}
+
+// ReportHoneytokenSighting records a sighting of the user's honeytoken
+// reported by an external system, notifying the webhooks.
+func ReportHoneytokenSighting(uid int64, report HoneytokenReport) (HoneytokenSighting, error) {
+	if report.Source == "" {
+		return HoneytokenSighting{}, ErrHoneytokenSourceNotSpecified
+	}
+	ht := Honeytoken{}
+	err := db.Where("user_id=? and username=?", uid, strings.ToLower(strings.TrimSpace(report.Username))).First(&ht).Error
+	if err == gorm.ErrRecordNotFound {
+		return HoneytokenSighting{}, ErrHoneytokenNotFound
+	}
+	if err != nil {
+		return HoneytokenSighting{}, err
+	}
+	s := HoneytokenSighting{
+		Source:          report.Source,
+		PasswordMatched: report.Password != "" && report.Password == string(ht.Password),
+		Address:         report.Address,
+		Details:         report.Details,
+	}
+	err = ht.recordSighting(&s)
+	return s, err
+}
+
+// recordSighting saves the sighting of the honeytoken and notifies the
+// webhooks.
+func (ht *Honeytoken) recordSighting(s *HoneytokenSighting) error {
+	s.HoneytokenId = ht.Id
+	s.CampaignId = ht.CampaignId
+	s.UserId = ht.UserId
+	s.Username = ht.Username
+	s.SeenDate = time.Now().UTC()
+	err := db.Save(s).Error
+	if err != nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"campaign_id": ht.CampaignId,
+		"source":      s.Source,
+	}).Warnf("honeytoken %s was used", ht.Username)
+	sendWebhooks(*s)
+	return nil
+}
+
+// checkHoneytokens records a sighting for each of the user's honeytokens
+// whose username was submitted to the landing page.
+func (r *Result) checkHoneytokens(details EventDetails) error {
+	if len(details.Payload) == 0 && len(details.passwords) == 0 {
+		return nil
+	}
+	hts := []Honeytoken{}
+	err := db.Where("user_id=?", r.UserId).Find(&hts).Error
+	if err != nil {
+		return err
+	}
+	for i := range hts {
+		usernameMatched, passwordMatched := false, false
+		for _, payload := range []url.Values{details.Payload, details.passwords} {
+			for k, vs := range payload {
+				if k == RecipientParameter {
+					continue
+				}
+				for _, v := range vs {
+					v = strings.TrimSpace(v)
+					usernameMatched = usernameMatched || strings.EqualFold(v, hts[i].Username)
+					passwordMatched = passwordMatched || v == string(hts[i].Password)
+				}
+			}
+		}
+		if !usernameMatched {
+			continue
+		}
+		s := HoneytokenSighting{
+			Source:          HoneytokenSourceLandingPage,
+			PasswordMatched: passwordMatched,
+			Details:         fmt.Sprintf("Submitted to the landing page of campaign %d", r.CampaignId),
+		}
+		// The address isn't stored for campaigns in privacy mode
+		if !isCampaignAnonymized(r.CampaignId) {
+			s.Address = details.Browser["address"]
+		}
+		err = hts[i].recordSighting(&s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
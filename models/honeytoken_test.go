@@ -0,0 +1,73 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestHoneytoken(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Template.Text = "username: {{.HoneytokenUsername}} password: {{.HoneytokenPassword}}"
+	ch.Assert(PutTemplate(&c.Template), check.Equals, nil)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	hts, err := GetHoneytokens(c.UserId, c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(hts), check.Equals, 1)
+	ht := hts[0]
+	ch.Assert(strings.HasPrefix(ht.Username, "svc-"), check.Equals, true)
+	ch.Assert(len(ht.Password), check.Equals, 16)
+	ch.Assert(ht.Sightings, check.DeepEquals, []HoneytokenSighting{})
+
+	// The honeytoken is available to the campaign's templates
+	r := c.Results[0]
+	ptx, err := NewPhishingTemplateContext(&c, r.BaseRecipient, r.RId)
+	ch.Assert(err, check.Equals, nil)
+	text, err := ExecuteTemplate(c.Template.Text, ptx)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(text, check.Equals, "username: "+ht.Username+" password: "+string(ht.Password))
+
+	// Submitting the credentials to the landing page records a sighting
+	details := EventDetails{
+		Payload: url.Values{
+			RecipientParameter: {r.RId},
+			"username":         {strings.ToUpper(ht.Username)},
+			"password":         {string(ht.Password)},
+		},
+		Browser: map[string]string{"address": "203.0.113.1"},
+	}
+	ch.Assert(r.HandleFormSubmit(details), check.Equals, nil)
+	// Other submissions don't
+	details.Payload["username"] = []string{"someone@example.com"}
+	ch.Assert(r.HandleFormSubmit(details), check.Equals, nil)
+
+	hts, err = GetHoneytokens(c.UserId, 0)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(hts[0].Sightings), check.Equals, 1)
+	sighting := hts[0].Sightings[0]
+	ch.Assert(sighting.Source, check.Equals, HoneytokenSourceLandingPage)
+	ch.Assert(sighting.CampaignId, check.Equals, c.Id)
+	ch.Assert(sighting.PasswordMatched, check.Equals, true)
+	ch.Assert(sighting.Address, check.Equals, "203.0.113.1")
+
+	// External systems can report sightings too
+	sighting, err = ReportHoneytokenSighting(c.UserId, HoneytokenReport{Username: ht.Username, Source: "siem", Address: "198.51.100.7"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(sighting.HoneytokenId, check.Equals, ht.Id)
+	ch.Assert(sighting.PasswordMatched, check.Equals, false)
+	_, err = ReportHoneytokenSighting(c.UserId, HoneytokenReport{Username: "svc-unknown", Source: "siem"})
+	ch.Assert(err, check.Equals, ErrHoneytokenNotFound)
+	_, err = ReportHoneytokenSighting(c.UserId, HoneytokenReport{Username: ht.Username})
+	ch.Assert(err, check.Equals, ErrHoneytokenSourceNotSpecified)
+
+	hts, err = GetHoneytokens(c.UserId, c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(hts[0].Sightings), check.Equals, 2)
+
+	ch.Assert(DeleteCampaign(c.Id), check.Equals, nil)
+	hts, err = GetHoneytokens(c.UserId, 0)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(hts), check.Equals, 0)
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// isASCII returns whether the string only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// asciiDomain returns the ASCII (punycode) form of an internationalized
+// domain name, which is how it has to be written in headers, SMTP commands,
+// and links. Domains which can't be converted are returned unchanged.
+func asciiDomain(domain string) string {
+	if isASCII(domain) {
+		return domain
+	}
+	a, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return domain
+	}
+	return a
+}
+
+// asciiAddress returns the email address with its domain in ASCII form. The
+// local part is left alone, since it can only be sent to servers supporting
+// SMTPUTF8.
+func asciiAddress(address string) string {
+	i := strings.LastIndex(address, "@")
+	if i == -1 {
+		return address
+	}
+	return address[:i+1] + asciiDomain(address[i+1:])
+}
+
+// asciiHost returns the host of a URL, which may include a port, in ASCII
+// form.
+func asciiHost(host string) string {
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return asciiDomain(host)
+	}
+	return net.JoinHostPort(asciiDomain(h), port)
+}
+
+// addressWarnings returns warnings about an internationalized address which
+// may not be delivered or shown as expected. The kind describes the address,
+// such as "sender".
+func addressWarnings(kind string, address string) []string {
+	warnings := []string{}
+	i := strings.LastIndex(address, "@")
+	if i == -1 {
+		return warnings
+	}
+	if !isASCII(address[:i]) {
+		warnings = append(warnings, fmt.Sprintf("The %s address %s has non-ASCII characters before the @, which most mail servers don't accept", kind, address))
+	}
+	return append(warnings, domainWarnings(kind, address[i+1:])...)
+}
+
+// domainWarnings returns warnings about an internationalized domain.
+func domainWarnings(kind string, domain string) []string {
+	if isASCII(domain) {
+		return []string{}
+	}
+	a, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return []string{fmt.Sprintf("The %s domain %s isn't a valid internationalized domain name: %v", kind, domain, err)}
+	}
+	return []string{fmt.Sprintf("The %s domain %s is sent as %s", kind, domain, a)}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"github.com/jordan-wright/email"
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestASCIIAddress(ch *check.C) {
+	ch.Assert(asciiAddress("foo@example.com"), check.Equals, "foo@example.com")
+	ch.Assert(asciiAddress("foo@bücher.example"), check.Equals, "foo@xn--bcher-kva.example")
+	ch.Assert(asciiAddress("zoë@bücher.example"), check.Equals, "zoë@xn--bcher-kva.example")
+	ch.Assert(asciiAddress("not an address"), check.Equals, "not an address")
+	ch.Assert(asciiHost("bücher.example:8080"), check.Equals, "xn--bcher-kva.example:8080")
+	ch.Assert(asciiHost("[::1]:8080"), check.Equals, "[::1]:8080")
+}
+
+func (s *ModelsSuite) TestEmailRequestInternationalized(ch *check.C) {
+	req := &EmailRequest{
+		FromAddress: "Jöhn Dœ 🎣 <john@bücher.example>",
+		URL:         "http://bücher.example/login",
+		RId:         "1234567",
+		Template: Template{
+			Subject: "Ünïcode 🎣 for {{.FirstName}}",
+			Text:    "{{.URL}}",
+		},
+		BaseRecipient: BaseRecipient{Email: "zoe@exämple.com", FirstName: "Zoë", LastName: "Ünï"},
+	}
+	msg := gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	buf := &bytes.Buffer{}
+	_, err := msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+	// Every header is encoded, so the raw email is ASCII
+	raw := buf.String()
+	headers := raw[:strings.Index(raw, "\r\n\r\n")]
+	ch.Assert(isASCII(headers), check.Equals, true)
+
+	got, err := email.NewEmailFromReader(buf)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Ünïcode 🎣 for Zoë")
+	ch.Assert(got.From, check.Equals, "Jöhn Dœ 🎣 <john@xn--bcher-kva.example>")
+	ch.Assert(got.To, check.DeepEquals, []string{"Zoë Ünï <zoe@xn--exmple-cua.com>"})
+	ch.Assert(string(got.Text), check.Equals, "http://xn--bcher-kva.example/login?rid=1234567")
+}
+
+func (s *ModelsSuite) TestPreviewWarnings(ch *check.C) {
+	t := Template{Name: "Warning Template", Text: "{{.URL}}", UserId: 1}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	req := SampleEmailRequest{
+		FromAddress:   "john@bücher.example",
+		URL:           "http://bad_domain-ü.example",
+		BaseRecipient: BaseRecipient{Email: "zoë@example.com"},
+	}
+	p, err := PreviewSampleEmail(t, req, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(p.Warnings, check.HasLen, 3)
+	ch.Assert(p.Warnings[0], check.Equals, "The sender domain bücher.example is sent as xn--bcher-kva.example")
+	ch.Assert(strings.HasPrefix(p.Warnings[1], "The recipient address zoë@example.com has non-ASCII characters"), check.Equals, true)
+	ch.Assert(strings.HasPrefix(p.Warnings[2], "The link domain bad_domain-ü.example isn't a valid internationalized domain name"), check.Equals, true)
+}
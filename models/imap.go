@@ -3,9 +3,13 @@ package models
 import (
 	"errors"
 	"net"
+	"net/mail"
+	"net/url"
+	"strings"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
 )
 
 const DefaultIMAPFolder = "INBOX"
@@ -14,22 +18,44 @@ const DefaultIMAPFreq = 60 // Every 60 seconds
 // IMAP contains the attributes needed to handle logging into an IMAP server to check
 // for reported emails
 type IMAP struct {
-	UserId                      int64     `json:"-" gorm:"column:user_id"`
-	Enabled                     bool      `json:"enabled"`
-	Host                        string    `json:"host"`
-	Port                        uint16    `json:"port,string,omitempty"`
-	Username                    string    `json:"username"`
-	Password                    string    `json:"password"`
-	TLS                         bool      `json:"tls"`
-	IgnoreCertErrors            bool      `json:"ignore_cert_errors"`
-	Folder                      string    `json:"folder"`
-	RestrictDomain              string    `json:"restrict_domain"`
-	DeleteReportedCampaignEmail bool      `json:"delete_reported_campaign_email"`
-	LastLogin                   time.Time `json:"last_login,omitempty"`
-	ModifiedDate                time.Time `json:"modified_date"`
-	IMAPFreq                    uint32    `json:"imap_freq,string,omitempty"`
+	UserId                      int64           `json:"-" gorm:"column:user_id"`
+	Enabled                     bool            `json:"enabled"`
+	Host                        string          `json:"host"`
+	Port                        uint16          `json:"port,string,omitempty"`
+	Username                    string          `json:"username"`
+	Password                    EncryptedString `json:"password"`
+	TLS                         bool            `json:"tls"`
+	IgnoreCertErrors            bool            `json:"ignore_cert_errors"`
+	Folder                      string          `json:"folder"`
+	RestrictDomain              string          `json:"restrict_domain"`
+	DeleteReportedCampaignEmail bool            `json:"delete_reported_campaign_email"`
+	LastLogin                   time.Time       `json:"last_login,omitempty"`
+	ModifiedDate                time.Time       `json:"modified_date"`
+	IMAPFreq                    uint32          `json:"imap_freq,string,omitempty"`
+	// ForwardAddresses is a comma separated list of addresses, such as a
+	// helpdesk, which the mailbox receives copies of. Campaign emails sent to
+	// them are recorded as forwards rather than reports.
+	ForwardAddresses string `json:"forward_addresses"`
+	// AuthMethod is how Gophish logs into the mailbox. OAuth2 access tokens,
+	// which Microsoft 365 requires, are requested from the OAuth2TokenURL
+	// either using the client credentials flow, or using the refresh token
+	// obtained by authorizing Gophish through the device code flow.
+	AuthMethod         string          `json:"auth_method"`
+	OAuth2TokenURL     string          `json:"oauth2_token_url" gorm:"column:oauth2_token_url"`
+	OAuth2DeviceURL    string          `json:"oauth2_device_url" gorm:"column:oauth2_device_url"`
+	OAuth2ClientID     string          `json:"oauth2_client_id" gorm:"column:oauth2_client_id"`
+	OAuth2ClientSecret EncryptedString `json:"oauth2_client_secret" gorm:"column:oauth2_client_secret"`
+	OAuth2Scope        string          `json:"oauth2_scope" gorm:"column:oauth2_scope"`
+	OAuth2RefreshToken EncryptedString `json:"-" gorm:"column:oauth2_refresh_token"`
 }
 
+// The methods used to log into IMAP mailboxes
+const (
+	IMAPAuthPassword          = "password"
+	IMAPAuthClientCredentials = "oauth2_client_credentials"
+	IMAPAuthDeviceCode        = "oauth2_device_code"
+)
+
 // ErrIMAPHostNotSpecified is thrown when there is no Host specified
 // in the IMAP configuration
 var ErrIMAPHostNotSpecified = errors.New("No IMAP Host specified")
@@ -52,10 +78,34 @@ var ErrIMAPUsernameNotSpecified = errors.New("No Username specified")
 // in the IMAP configuration
 var ErrIMAPPasswordNotSpecified = errors.New("No Password specified")
 
+// ErrInvalidIMAPAuthMethod is thrown when the authentication method isn't
+// supported
+var ErrInvalidIMAPAuthMethod = errors.New("Invalid authentication method")
+
+// ErrIMAPOAuth2TokenURL is thrown when the OAuth2 token URL is missing or
+// invalid
+var ErrIMAPOAuth2TokenURL = errors.New("Invalid OAuth2 token URL")
+
+// ErrIMAPOAuth2DeviceURL is thrown when the OAuth2 device authorization URL
+// is missing or invalid
+var ErrIMAPOAuth2DeviceURL = errors.New("Invalid OAuth2 device authorization URL")
+
+// ErrIMAPOAuth2ClientIDNotSpecified is thrown when there is no OAuth2 client
+// id specified
+var ErrIMAPOAuth2ClientIDNotSpecified = errors.New("No OAuth2 client ID specified")
+
+// ErrIMAPOAuth2ClientSecretNotSpecified is thrown when the client credentials
+// flow is used without a client secret
+var ErrIMAPOAuth2ClientSecretNotSpecified = errors.New("No OAuth2 client secret specified")
+
 // ErrInvalidIMAPFreq is thrown when the frequency for polling the
 // IMAP server is invalid
 var ErrInvalidIMAPFreq = errors.New("Invalid polling frequency")
 
+// ErrInvalidForwardAddresses is thrown when the forwarding addresses can't be
+// parsed
+var ErrInvalidForwardAddresses = errors.New("Invalid forwarding addresses")
+
 // TableName specifies the database tablename for Gorm to use
 func (im IMAP) TableName() string {
 	return "imap"
@@ -70,8 +120,9 @@ func (im *IMAP) Validate() error {
 		return ErrIMAPPortNotSpecified
 	case im.Username == "":
 		return ErrIMAPUsernameNotSpecified
-	case im.Password == "":
-		return ErrIMAPPasswordNotSpecified
+	}
+	if err := im.validateAuth(); err != nil {
+		return err
 	}
 
 	// Set the default value for Folder
@@ -91,6 +142,18 @@ func (im *IMAP) Validate() error {
 		return ErrInvalidIMAPPort
 	}
 
+	if _, err := im.ForwardAddressList(); err != nil {
+		return ErrInvalidForwardAddresses
+	}
+
+	// Make sure any referenced secret can be resolved
+	for _, secret := range []EncryptedString{im.Password, im.OAuth2ClientSecret} {
+		err = validateSecret(string(secret), im.UserId)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Make sure the polling frequency is between every 30 seconds and every year
 	// If not set it to the default
 	if im.IMAPFreq < 30 || im.IMAPFreq > 31540000 {
@@ -100,6 +163,84 @@ func (im *IMAP) Validate() error {
 	return nil
 }
 
+// validateAuth checks the settings needed by the authentication method.
+func (im *IMAP) validateAuth() error {
+	if im.AuthMethod == "" {
+		im.AuthMethod = IMAPAuthPassword
+	}
+	switch im.AuthMethod {
+	case IMAPAuthPassword:
+		if im.Password == "" {
+			return ErrIMAPPasswordNotSpecified
+		}
+		return nil
+	case IMAPAuthClientCredentials:
+		if im.OAuth2ClientSecret == "" {
+			return ErrIMAPOAuth2ClientSecretNotSpecified
+		}
+	case IMAPAuthDeviceCode:
+		if !validOAuth2URL(im.OAuth2DeviceURL) {
+			return ErrIMAPOAuth2DeviceURL
+		}
+	default:
+		return ErrInvalidIMAPAuthMethod
+	}
+	if !validOAuth2URL(im.OAuth2TokenURL) {
+		return ErrIMAPOAuth2TokenURL
+	}
+	if im.OAuth2ClientID == "" {
+		return ErrIMAPOAuth2ClientIDNotSpecified
+	}
+	return nil
+}
+
+func validOAuth2URL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
+}
+
+// LoadRefreshToken copies the OAuth2 refresh token from the user's saved
+// settings, as long as they use the same OAuth2 client. The token is never
+// sent to the browser, so it's missing from settings sent to the API.
+func (im *IMAP) LoadRefreshToken(uid int64) error {
+	if im.AuthMethod != IMAPAuthDeviceCode || im.OAuth2RefreshToken != "" {
+		return nil
+	}
+	saved := IMAP{}
+	err := db.Where("user_id=?", uid).First(&saved).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if saved.AuthMethod == im.AuthMethod && saved.OAuth2TokenURL == im.OAuth2TokenURL && saved.OAuth2ClientID == im.OAuth2ClientID {
+		im.OAuth2RefreshToken = saved.OAuth2RefreshToken
+	}
+	return nil
+}
+
+// SetIMAPRefreshToken saves the OAuth2 refresh token for the user's mailbox.
+func SetIMAPRefreshToken(uid int64, token string) error {
+	return db.Model(&IMAP{}).Where("user_id=?", uid).Update("oauth2_refresh_token", EncryptedString(token)).Error
+}
+
+// ForwardAddressList returns the lowercased forwarding addresses.
+func (im *IMAP) ForwardAddressList() ([]string, error) {
+	addrs := []string{}
+	if strings.TrimSpace(im.ForwardAddresses) == "" {
+		return addrs, nil
+	}
+	parsed, err := mail.ParseAddressList(im.ForwardAddresses)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range parsed {
+		addrs = append(addrs, strings.ToLower(a.Address))
+	}
+	return addrs, nil
+}
+
 // GetIMAP returns the IMAP server owned by the given user.
 func GetIMAP(uid int64) ([]IMAP, error) {
 	im := []IMAP{}
@@ -115,12 +256,19 @@ func GetIMAP(uid int64) ([]IMAP, error) {
 
 // PostIMAP updates IMAP settings for a user in the database.
 func PostIMAP(im *IMAP, uid int64) error {
+	im.UserId = uid
 	err := im.Validate()
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 
+	err = im.LoadRefreshToken(uid)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
 	// Delete old entry. TODO: Save settings and if fails to Save below replace with original
 	err = DeleteIMAP(uid)
 	if err != nil {
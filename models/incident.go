@@ -0,0 +1,396 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// The incident response platforms reported emails can be pushed to
+const (
+	IncidentTheHive = "thehive"
+	IncidentMISP    = "misp"
+)
+
+// The types of observables extracted from reported emails
+const (
+	ObservableURL      = "url"
+	ObservableDomain   = "domain"
+	ObservableIP       = "ip"
+	ObservableMail     = "mail"
+	ObservableSubject  = "mail_subject"
+	ObservableFilename = "filename"
+	ObservableHash     = "hash"
+)
+
+// DefaultIncidentSeverity is the severity of the alerts pushed by connectors
+// which don't set one, on TheHive's scale of 1 (low) to 4 (critical).
+const DefaultIncidentSeverity = 2
+
+var incidentClient = restrictedClient(NotificationTimeout)
+
+// ErrIncidentType is thrown when an incident connector has an unknown type.
+var ErrIncidentType = errors.New("The type must be thehive or misp")
+
+// ErrIncidentURL is thrown when an incident connector doesn't have an http
+// or https URL.
+var ErrIncidentURL = errors.New("The URL must be an http or https URL")
+
+// ErrIncidentAPIKey is thrown when an incident connector doesn't have an API
+// key.
+var ErrIncidentAPIKey = errors.New("An API key must be specified")
+
+// ErrIncidentSeverity is thrown when an incident connector's severity or TLP
+// is out of range.
+var ErrIncidentSeverity = errors.New("The severity must be between 1 and 4, and the TLP between 0 and 3")
+
+// ErrIncidentReports is thrown when an incident connector doesn't push any
+// reports.
+var ErrIncidentReports = errors.New("The connector must push reported simulations, real phishing emails, or both")
+
+// ErrIncidentConnectorNotFound is thrown when an incident connector doesn't
+// exist.
+var ErrIncidentConnectorNotFound = errors.New("Incident connector not found")
+
+// IncidentConnector pushes the emails reported to the user's monitored
+// mailbox to an incident response platform, as an alert in TheHive or an
+// event in MISP. Connectors can push reported simulations, real phishing
+// emails which aren't part of a campaign, or both.
+//
+// Alerts pushed to TheHive can be analyzed by its Cortex analyzers like any
+// other alert.
+type IncidentConnector struct {
+	Id     int64           `json:"id"`
+	UserId int64           `json:"-"`
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	URL    string          `json:"url"`
+	APIKey EncryptedString `json:"api_key" gorm:"column:api_key"`
+	// Severity uses TheHive's scale of 1 (low) to 4 (critical), and is
+	// converted to MISP's threat levels.
+	Severity int `json:"severity"`
+	// TLP is the Traffic Light Protocol level, from 0 (white) to 3 (red).
+	TLP int `json:"tlp" gorm:"column:tlp"`
+	// Tags is a comma separated list of tags added to the alerts.
+	Tags         string    `json:"tags"`
+	Simulations  bool      `json:"simulations"`
+	RealPhish    bool      `json:"real_phish"`
+	Enabled      bool      `json:"enabled"`
+	CreatedDate  time.Time `json:"created_date"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// Observable is an indicator extracted from a reported email, such as a URL
+// it links to or the hash of an attachment.
+type Observable struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ReportedEmail is an email reported to the monitored mailbox. Reported
+// simulations have the result id and campaign of the recipient who
+// reported them.
+type ReportedEmail struct {
+	Reporter    string
+	From        string
+	Subject     string
+	MessageId   string
+	Date        time.Time
+	RId         string
+	CampaignId  int64
+	Campaign    string
+	Observables []Observable
+}
+
+// IsSimulation returns whether the reported email was sent by a campaign.
+func (re ReportedEmail) IsSimulation() bool {
+	return re.RId != ""
+}
+
+// sourceRef returns the reference which identifies the report in TheHive.
+// Every report gets its own alert, even when several recipients report the
+// same email.
+func (re ReportedEmail) sourceRef() string {
+	h := sha256.Sum256([]byte(re.Reporter + "\n" + re.MessageId + "\n" + re.Subject + "\n" + re.Date.String()))
+	return hex.EncodeToString(h[:8])
+}
+
+// title returns the title of the alert or event pushed for the report.
+func (re ReportedEmail) title() string {
+	if re.IsSimulation() {
+		return fmt.Sprintf("Phishing simulation reported by %s: %s", re.Reporter, re.Subject)
+	}
+	return fmt.Sprintf("Suspicious email reported by %s: %s", re.Reporter, re.Subject)
+}
+
+// description returns the description of the alert or event pushed for the
+// report.
+func (re ReportedEmail) description() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Reporter: %s\nFrom: %s\nSubject: %s\n", re.Reporter, re.From, re.Subject)
+	if re.MessageId != "" {
+		fmt.Fprintf(b, "Message-ID: %s\n", re.MessageId)
+	}
+	if !re.Date.IsZero() {
+		fmt.Fprintf(b, "Date: %s\n", re.Date.UTC().Format(time.RFC1123Z))
+	}
+	if re.IsSimulation() {
+		fmt.Fprintf(b, "\nThis email was sent by the Gophish campaign %s (id %d), and was reported by the recipient with result id %s.\n",
+			re.Campaign, re.CampaignId, re.RId)
+	}
+	return b.String()
+}
+
+// Validate ensures the connector has a known type, a URL and an API key, and
+// that it pushes some reports. Connectors without a severity use
+// DefaultIncidentSeverity.
+func (ic *IncidentConnector) Validate() error {
+	if ic.Name == "" {
+		return ErrNameNotSpecified
+	}
+	if ic.Type != IncidentTheHive && ic.Type != IncidentMISP {
+		return ErrIncidentType
+	}
+	if !strings.HasPrefix(ic.URL, "https://") && !strings.HasPrefix(ic.URL, "http://") {
+		return ErrIncidentURL
+	}
+	ic.URL = strings.TrimRight(ic.URL, "/")
+	if ic.APIKey == "" {
+		return ErrIncidentAPIKey
+	}
+	if ic.Severity == 0 {
+		ic.Severity = DefaultIncidentSeverity
+	}
+	if ic.Severity < 1 || ic.Severity > 4 || ic.TLP < 0 || ic.TLP > 3 {
+		return ErrIncidentSeverity
+	}
+	if !ic.Simulations && !ic.RealPhish {
+		return ErrIncidentReports
+	}
+	return nil
+}
+
+// GetIncidentConnectors returns the incident connectors owned by the given
+// user.
+func GetIncidentConnectors(uid int64) ([]IncidentConnector, error) {
+	ics := []IncidentConnector{}
+	err := db.Where("user_id=?", uid).Order("id asc").Find(&ics).Error
+	return ics, err
+}
+
+// GetIncidentConnector returns the incident connector with the given id, if
+// it's owned by the given user.
+func GetIncidentConnector(id int64, uid int64) (IncidentConnector, error) {
+	ic := IncidentConnector{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&ic).Error
+	if err != nil {
+		return ic, ErrIncidentConnectorNotFound
+	}
+	return ic, nil
+}
+
+// PostIncidentConnector creates a new incident connector.
+func PostIncidentConnector(ic *IncidentConnector) error {
+	err := ic.Validate()
+	if err != nil {
+		return err
+	}
+	ic.Id = 0
+	ic.CreatedDate = time.Now().UTC()
+	ic.ModifiedDate = ic.CreatedDate
+	return db.Save(ic).Error
+}
+
+// PutIncidentConnector edits an existing incident connector.
+func PutIncidentConnector(ic *IncidentConnector) error {
+	existing, err := GetIncidentConnector(ic.Id, ic.UserId)
+	if err != nil {
+		return err
+	}
+	err = ic.Validate()
+	if err != nil {
+		return err
+	}
+	ic.CreatedDate = existing.CreatedDate
+	ic.ModifiedDate = time.Now().UTC()
+	return db.Save(ic).Error
+}
+
+// DeleteIncidentConnector deletes the incident connector with the given id.
+func DeleteIncidentConnector(id int64, uid int64) error {
+	_, err := GetIncidentConnector(id, uid)
+	if err != nil {
+		return err
+	}
+	return db.Where("id=? and user_id=?", id, uid).Delete(&IncidentConnector{}).Error
+}
+
+// PushReportedEmail pushes the email reported to the user's mailbox to their
+// enabled incident connectors which accept it. Every connector is tried, and
+// the last error is returned.
+func PushReportedEmail(uid int64, re ReportedEmail) error {
+	column := "real_phish"
+	if re.IsSimulation() {
+		column = "simulations"
+	}
+	ics := []IncidentConnector{}
+	err := db.Where("user_id=? and enabled=? and "+column+"=?", uid, true, true).Find(&ics).Error
+	if err != nil {
+		return err
+	}
+	for _, ic := range ics {
+		perr := ic.push(re)
+		if perr != nil {
+			log.WithFields(logrus.Fields{
+				"connector_id": ic.Id,
+				"reporter":     re.Reporter,
+			}).Errorf("error pushing reported email: %v", perr)
+			err = perr
+		}
+	}
+	return err
+}
+
+// tags returns the connector's tags, along with the tags describing the
+// report.
+func (ic IncidentConnector) tags(re ReportedEmail) []string {
+	tags := []string{"gophish"}
+	if re.IsSimulation() {
+		tags = append(tags, "gophish:simulation", fmt.Sprintf("gophish:campaign=%d", re.CampaignId))
+	} else {
+		tags = append(tags, "gophish:reported")
+	}
+	for _, t := range strings.Split(ic.Tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// push sends the report to the connector's platform.
+func (ic IncidentConnector) push(re ReportedEmail) error {
+	switch ic.Type {
+	case IncidentTheHive:
+		artifacts := []map[string]interface{}{}
+		for _, o := range re.Observables {
+			artifacts = append(artifacts, map[string]interface{}{
+				"dataType": o.Type,
+				"data":     o.Value,
+				"tlp":      ic.TLP,
+				"ioc":      !re.IsSimulation(),
+			})
+		}
+		alert := map[string]interface{}{
+			"type":        "phishing-report",
+			"source":      "gophish",
+			"sourceRef":   re.sourceRef(),
+			"title":       re.title(),
+			"description": re.description(),
+			"severity":    ic.Severity,
+			"tlp":         ic.TLP,
+			"tags":        ic.tags(re),
+			"artifacts":   artifacts,
+		}
+		return ic.post("/api/alert", "Bearer "+string(ic.APIKey), alert)
+	case IncidentMISP:
+		attributes := []map[string]interface{}{}
+		for _, o := range re.Observables {
+			t, category := mispAttributeType(o.Type)
+			attributes = append(attributes, map[string]interface{}{
+				"type":     t,
+				"category": category,
+				"value":    o.Value,
+				"to_ids":   !re.IsSimulation(),
+			})
+		}
+		tags := []map[string]string{{"name": []string{"tlp:white", "tlp:green", "tlp:amber", "tlp:red"}[ic.TLP]}}
+		for _, t := range ic.tags(re) {
+			tags = append(tags, map[string]string{"name": t})
+		}
+		date := re.Date
+		if date.IsZero() {
+			date = time.Now().UTC()
+		}
+		event := map[string]interface{}{
+			"info": re.title(),
+			"date": date.Format("2006-01-02"),
+			// MISP's threat levels run from 1 (high) to 3 (low)
+			"threat_level_id": fmt.Sprint(mispThreatLevel(ic.Severity)),
+			"analysis":        "0",
+			"distribution":    "0",
+			"Attribute":       attributes,
+			"Tag":             tags,
+		}
+		return ic.post("/events", string(ic.APIKey), map[string]interface{}{"Event": event})
+	}
+	return ErrIncidentType
+}
+
+// mispThreatLevel converts a severity on TheHive's scale to MISP's threat
+// levels.
+func mispThreatLevel(severity int) int {
+	switch {
+	case severity >= 3:
+		return 1
+	case severity == 2:
+		return 2
+	}
+	return 3
+}
+
+// mispAttributeType returns the MISP attribute type and category of the
+// observable type.
+func mispAttributeType(t string) (string, string) {
+	switch t {
+	case ObservableURL:
+		return "url", "Network activity"
+	case ObservableDomain:
+		return "domain", "Network activity"
+	case ObservableIP:
+		return "ip-src", "Network activity"
+	case ObservableMail:
+		return "email-src", "Payload delivery"
+	case ObservableSubject:
+		return "email-subject", "Payload delivery"
+	case ObservableFilename:
+		return "email-attachment", "Payload delivery"
+	case ObservableHash:
+		return "sha256", "Payload delivery"
+	}
+	return "text", "Other"
+}
+
+// post sends the body to the connector's API, authorized by the given
+// header.
+func (ic IncidentConnector) post(path string, authorization string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", ic.URL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", authorization)
+	resp, err := incidentClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, ic.URL)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"gopkg.in/check.v1"
+)
+
+// incidentRecorder is a TheHive or MISP API which records the alerts and
+// events pushed to it.
+type incidentRecorder struct {
+	sync.Mutex
+	authorization []string
+	paths         []string
+	bodies        []map[string]interface{}
+}
+
+func (ir *incidentRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&body)
+	ir.Lock()
+	ir.authorization = append(ir.authorization, r.Header.Get("Authorization"))
+	ir.paths = append(ir.paths, r.URL.Path)
+	ir.bodies = append(ir.bodies, body)
+	ir.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *ModelsSuite) TestIncidentConnectorValidation(ch *check.C) {
+	ic := IncidentConnector{UserId: 1, Name: "TheHive", Type: "qradar", URL: "https://thehive.example.com/", APIKey: "key", Simulations: true}
+	ch.Assert(PostIncidentConnector(&ic), check.Equals, ErrIncidentType)
+	ic.Type = IncidentTheHive
+	ic.URL = "thehive.example.com"
+	ch.Assert(PostIncidentConnector(&ic), check.Equals, ErrIncidentURL)
+	ic.URL = "https://thehive.example.com/"
+	ic.APIKey = ""
+	ch.Assert(PostIncidentConnector(&ic), check.Equals, ErrIncidentAPIKey)
+	ic.APIKey = "key"
+	ic.TLP = 4
+	ch.Assert(PostIncidentConnector(&ic), check.Equals, ErrIncidentSeverity)
+	ic.TLP = 2
+	ic.Simulations = false
+	ch.Assert(PostIncidentConnector(&ic), check.Equals, ErrIncidentReports)
+	ic.RealPhish = true
+	ch.Assert(PostIncidentConnector(&ic), check.Equals, nil)
+
+	got, err := GetIncidentConnector(ic.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.URL, check.Equals, "https://thehive.example.com")
+	ch.Assert(got.Severity, check.Equals, DefaultIncidentSeverity)
+	ch.Assert(string(got.APIKey), check.Equals, "key")
+
+	// Connectors are only visible to their owner
+	_, err = GetIncidentConnector(ic.Id, 2)
+	ch.Assert(err, check.Equals, ErrIncidentConnectorNotFound)
+	ch.Assert(DeleteIncidentConnector(ic.Id, 1), check.Equals, nil)
+}
+
+func (s *ModelsSuite) TestPushReportedEmail(ch *check.C) {
+	ir := &incidentRecorder{}
+	ts := httptest.NewServer(ir)
+	defer ts.Close()
+
+	thehive := IncidentConnector{UserId: 1, Name: "TheHive", Type: IncidentTheHive, URL: ts.URL, APIKey: "hive-key",
+		Severity: 3, TLP: 2, Tags: "phishing, soc", Simulations: true, RealPhish: true, Enabled: true}
+	ch.Assert(PostIncidentConnector(&thehive), check.Equals, nil)
+	misp := IncidentConnector{UserId: 1, Name: "MISP", Type: IncidentMISP, URL: ts.URL, APIKey: "misp-key",
+		TLP: 1, RealPhish: true, Enabled: true}
+	ch.Assert(PostIncidentConnector(&misp), check.Equals, nil)
+
+	observables := []Observable{
+		{Type: ObservableMail, Value: "attacker@example.net"},
+		{Type: ObservableURL, Value: "https://login.example.net/"},
+	}
+	re := ReportedEmail{Reporter: "victim@example.com", From: "Attacker <attacker@example.net>", Subject: "Reset your password",
+		Observables: observables}
+	ch.Assert(PushReportedEmail(1, re), check.Equals, nil)
+
+	// Real phishing emails are pushed to both platforms as IOCs
+	ch.Assert(ir.paths, check.DeepEquals, []string{"/api/alert", "/events"})
+	ch.Assert(ir.authorization, check.DeepEquals, []string{"Bearer hive-key", "misp-key"})
+	alert := ir.bodies[0]
+	ch.Assert(alert["title"], check.Equals, "Suspicious email reported by victim@example.com: Reset your password")
+	ch.Assert(alert["severity"], check.Equals, float64(3))
+	ch.Assert(alert["tags"], check.DeepEquals, []interface{}{"gophish", "gophish:reported", "phishing", "soc"})
+	artifacts := alert["artifacts"].([]interface{})
+	ch.Assert(len(artifacts), check.Equals, 2)
+	ch.Assert(artifacts[1], check.DeepEquals, map[string]interface{}{"dataType": "url", "data": "https://login.example.net/", "tlp": float64(2), "ioc": true})
+	event := ir.bodies[1]["Event"].(map[string]interface{})
+	ch.Assert(event["threat_level_id"], check.Equals, "2")
+	attributes := event["Attribute"].([]interface{})
+	ch.Assert(attributes[0], check.DeepEquals, map[string]interface{}{"type": "email-src", "category": "Payload delivery", "value": "attacker@example.net", "to_ids": true})
+	ch.Assert(event["Tag"].([]interface{})[0], check.DeepEquals, map[string]interface{}{"name": "tlp:green"})
+
+	// Simulations are only pushed to connectors which accept them, and
+	// aren't marked as IOCs
+	re.RId = "abc1234"
+	re.CampaignId = 5
+	re.Campaign = "Password reset"
+	ch.Assert(PushReportedEmail(1, re), check.Equals, nil)
+	ch.Assert(ir.paths, check.DeepEquals, []string{"/api/alert", "/events", "/api/alert"})
+	alert = ir.bodies[2]
+	ch.Assert(alert["title"], check.Equals, "Phishing simulation reported by victim@example.com: Reset your password")
+	ch.Assert(alert["tags"], check.DeepEquals, []interface{}{"gophish", "gophish:simulation", "gophish:campaign=5", "phishing", "soc"})
+	ch.Assert(alert["artifacts"].([]interface{})[0].(map[string]interface{})["ioc"], check.Equals, false)
+
+	// Other users' connectors aren't used
+	ch.Assert(PushReportedEmail(2, re), check.Equals, nil)
+	ch.Assert(len(ir.paths), check.Equals, 3)
+}
+
+func (s *ModelsSuite) TestIncidentConnectorRestricted(ch *check.C) {
+	ic := IncidentConnector{Type: IncidentTheHive, URL: "http://169.254.169.254"}
+	err := ic.post("/api/alert", "Bearer key", map[string]string{})
+	ch.Assert(err, check.NotNil)
+	ch.Assert(strings.Contains(err.Error(), "upstream connection denied"), check.Equals, true)
+}
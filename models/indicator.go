@@ -0,0 +1,174 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// The types of indicators generated by campaigns
+const (
+	IndicatorDomain    = "domain"
+	IndicatorIP        = "ip"
+	IndicatorEmail     = "email"
+	IndicatorURL       = "url"
+	IndicatorHash      = "sha256"
+	IndicatorMessageId = "message_id"
+)
+
+// CampaignIndicator is an indicator generated by a campaign, such as the
+// domain its emails are sent from or the hash of an attachment. Indicators
+// which are different for each recipient have the recipient's result id.
+type CampaignIndicator struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	RId         string `json:"rid,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+}
+
+// indicatorSet collects the indicators of a campaign, ignoring duplicates.
+type indicatorSet struct {
+	indicators []CampaignIndicator
+	seen       map[string]bool
+}
+
+func (s *indicatorSet) add(i CampaignIndicator) {
+	if i.Value == "" {
+		return
+	}
+	key := i.Type + "\n" + i.Value
+	if s.seen[key] {
+		return
+	}
+	s.seen[key] = true
+	s.indicators = append(s.indicators, i)
+}
+
+// addHost adds the host as a domain or IP address indicator.
+func (s *indicatorSet) addHost(host string, description string) {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if ip := net.ParseIP(host); ip != nil {
+		s.add(CampaignIndicator{Type: IndicatorIP, Value: ip.String(), Description: description})
+		return
+	}
+	s.add(CampaignIndicator{Type: IndicatorDomain, Value: host, Description: description})
+}
+
+// addAddress adds the email address and its domain as indicators.
+func (s *indicatorSet) addAddress(address string, description string) {
+	a, err := mail.ParseAddress(address)
+	if err != nil {
+		return
+	}
+	s.add(CampaignIndicator{Type: IndicatorEmail, Value: strings.ToLower(a.Address), Description: description})
+	s.addHost(a.Address[strings.LastIndex(a.Address, "@")+1:], description+" domain")
+}
+
+// GetCampaignIndicators returns the indicators generated by the campaign,
+// so that they can be allowlisted or hunted for. These include the sending
+// addresses, server and domains, each recipient's links and Message-ID, and
+// the hashes of the attachments. Attachments which are the same for every
+// recipient have a single hash, while templated attachments have a hash for
+// each recipient.
+func GetCampaignIndicators(id int64, uid int64) ([]CampaignIndicator, error) {
+	c, err := GetCampaignMailContext(id, uid)
+	if err != nil {
+		return nil, err
+	}
+	results := []Result{}
+	err = db.Where("campaign_id=?", c.Id).Order("id asc").Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	s := &indicatorSet{seen: map[string]bool{}}
+	s.addAddress(c.SMTP.FromAddress, "Sender address")
+	if envelopeFrom := c.Template.envelopeFrom(); envelopeFrom != "" {
+		s.addAddress(envelopeFrom, "Envelope sender")
+	}
+	if c.Template.ReplyTo != "" {
+		s.addAddress(c.Template.ReplyTo, "Reply-To address")
+	}
+	if host, _, err := net.SplitHostPort(c.SMTP.Host); err == nil {
+		s.addHost(host, "Sending server")
+	} else {
+		s.addHost(c.SMTP.Host, "Sending server")
+	}
+	if c.SMTP.MessageIdDomain != "" {
+		s.addHost(c.SMTP.MessageIdDomain, "Message-ID domain")
+	}
+	// The hashes of each attachment in the order they were first rendered,
+	// and the result id of the first recipient they were rendered for
+	hashes := make([][]string, len(c.Template.Attachments))
+	firstRIds := make([]map[string]string, len(c.Template.Attachments))
+	for i := range firstRIds {
+		firstRIds[i] = map[string]string{}
+	}
+	for _, r := range results {
+		ptx, err := NewPhishingTemplateContext(&c, r.BaseRecipient, r.RId)
+		if err != nil {
+			return nil, err
+		}
+		if u, err := url.Parse(ptx.LongURL); err == nil {
+			s.addHost(u.Hostname(), "Phishing server")
+		}
+		s.add(CampaignIndicator{Type: IndicatorURL, Value: ptx.LongURL, Description: "Phishing link", RId: r.RId})
+		if c.ShortURL != "" {
+			link, err := shortLink(c.ShortURL, r.RId)
+			if err != nil {
+				return nil, err
+			}
+			if u, err := url.Parse(link); err == nil {
+				s.addHost(u.Hostname(), "Short link server")
+			}
+			s.add(CampaignIndicator{Type: IndicatorURL, Value: link, Description: "Short link", RId: r.RId})
+		}
+		if len(c.Redirects) > 0 {
+			s.add(CampaignIndicator{Type: IndicatorURL, Value: ptx.URL, Description: "Open redirect link", RId: r.RId})
+		}
+		s.add(CampaignIndicator{Type: IndicatorURL, Value: ptx.TrackingURL, Description: "Tracking image", RId: r.RId})
+		s.add(CampaignIndicator{Type: IndicatorMessageId, Value: r.MessageId, Description: "Message-ID", RId: r.RId})
+		for i := range c.Template.Attachments {
+			a := &c.Template.Attachments[i]
+			hash, err := attachmentHash(a, ptx)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := firstRIds[i][hash]; !ok {
+				firstRIds[i][hash] = r.RId
+				hashes[i] = append(hashes[i], hash)
+			}
+		}
+	}
+	for i, a := range c.Template.Attachments {
+		for _, hash := range hashes[i] {
+			ci := CampaignIndicator{Type: IndicatorHash, Value: hash, Description: "Attachment", Filename: a.Name}
+			if len(hashes[i]) > 1 {
+				ci.RId = firstRIds[i][hash]
+			}
+			s.add(ci)
+		}
+	}
+	return s.indicators, nil
+}
+
+// attachmentHash returns the SHA-256 hash of the attachment rendered for a
+// recipient.
+func attachmentHash(a *Attachment, ptx PhishingTemplateContext) (string, error) {
+	content, err := a.ApplyTemplate(ptx)
+	if err != nil {
+		return "", fmt.Errorf("error rendering attachment %s: %v", a.Name, err)
+	}
+	defer content.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, content)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
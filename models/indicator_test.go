@@ -0,0 +1,76 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/gophish/gomail"
+	"gopkg.in/check.v1"
+)
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func (s *ModelsSuite) TestCampaignIndicators(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Template.ReplyTo = "Help Desk <help@reply.example.net>"
+	c.Template.Attachments = []Attachment{
+		{Name: "policy.txt", Type: "text/plain", Content: base64.StdEncoding.EncodeToString([]byte("Read the policy"))},
+		{Name: "note.txt", Type: "text/plain", Content: base64.StdEncoding.EncodeToString([]byte("Hi {{.FirstName}}"))},
+	}
+	ch.Assert(PutTemplate(&c.Template), check.Equals, nil)
+	defer DeleteTemplate(c.Template.Id, c.UserId)
+	c.SMTP.Host = "203.0.113.5:587"
+	c.SMTP.MessageIdDomain = "mail.example.net"
+	ch.Assert(PutSMTP(&c.SMTP), check.Equals, nil)
+	c.URL = "https://phish.example.net"
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	// Sending an email records its Message-ID
+	m := &MailLog{}
+	ch.Assert(db.Where("r_id=?", c.Results[0].RId).First(m).Error, check.Equals, nil)
+	msg := gomail.NewMessage()
+	ch.Assert(m.Generate(msg), check.Equals, nil)
+	ch.Assert(m.Success(), check.Equals, nil)
+	messageId := msg.GetHeader("Message-Id")[0]
+	r, err := GetResult(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.MessageId, check.Equals, messageId)
+
+	is, err := GetCampaignIndicators(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	counts := map[string]int{}
+	found := map[string]CampaignIndicator{}
+	for _, i := range is {
+		counts[i.Type]++
+		found[i.Type+" "+i.Value] = i
+	}
+	ch.Assert(found[IndicatorEmail+" test@test.com"].Description, check.Equals, "Sender address")
+	ch.Assert(found[IndicatorDomain+" test.com"].Description, check.Equals, "Sender address domain")
+	ch.Assert(found[IndicatorEmail+" help@reply.example.net"].Description, check.Equals, "Reply-To address")
+	ch.Assert(found[IndicatorIP+" 203.0.113.5"].Description, check.Equals, "Sending server")
+	ch.Assert(found[IndicatorDomain+" mail.example.net"].Description, check.Equals, "Message-ID domain")
+	ch.Assert(found[IndicatorDomain+" phish.example.net"].Description, check.Equals, "Phishing server")
+	ch.Assert(found[IndicatorMessageId+" "+messageId].RId, check.Equals, r.RId)
+	ch.Assert(counts[IndicatorMessageId], check.Equals, 1)
+
+	// Each recipient has a link and tracking image
+	ch.Assert(counts[IndicatorURL], check.Equals, 2*len(c.Results))
+	ch.Assert(found[IndicatorURL+" https://phish.example.net?rid="+r.RId].RId, check.Equals, r.RId)
+
+	// The static attachment has a single hash, while the templated one has
+	// a hash for each of its variants
+	policy := found[IndicatorHash+" "+sha256Hex("Read the policy")]
+	ch.Assert(policy.Filename, check.Equals, "policy.txt")
+	ch.Assert(policy.RId, check.Equals, "")
+	ch.Assert(found[IndicatorHash+" "+sha256Hex("Hi First")].RId, check.Equals, c.Results[0].RId)
+	ch.Assert(found[IndicatorHash+" "+sha256Hex("Hi Second")].Filename, check.Equals, "note.txt")
+	ch.Assert(counts[IndicatorHash], check.Equals, 3)
+
+	// Campaigns are only visible to their owner
+	_, err = GetCampaignIndicators(c.Id, 2)
+	ch.Assert(err, check.NotNil)
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidInlineImage is returned when an inline image isn't an image.
+var ErrInvalidInlineImage = errors.New("Inline images must be image files")
+
+// ErrInvalidInlineImageName is returned when the name of an inline image
+// can't be used as a Content-ID.
+var ErrInvalidInlineImageName = errors.New("Inline image names may only contain letters, numbers, dots, dashes, and underscores")
+
+// inlineImageName matches the names which can be used as a Content-ID
+var inlineImageName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// InlineImage is an image embedded in a template's emails. The HTML of the
+// template references it using its CID, such as <img src="cid:logo.png">.
+type InlineImage struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	CID  string `json:"cid"`
+}
+
+// InlineImages returns the template's inline images.
+func (t *Template) InlineImages() []InlineImage {
+	images := []InlineImage{}
+	for _, a := range t.Attachments {
+		if a.Inline {
+			images = append(images, InlineImage{Name: a.Name, Type: a.Type, CID: "cid:" + a.Name})
+		}
+	}
+	return images
+}
+
+// AddInlineImage adds an image to the template which is embedded in its
+// emails, replacing any attachment with the same name.
+func AddInlineImage(tid int64, uid int64, name string, content []byte) (InlineImage, error) {
+	if !inlineImageName.MatchString(name) {
+		return InlineImage{}, ErrInvalidInlineImageName
+	}
+	contentType := http.DetectContentType(content)
+	if !strings.HasPrefix(contentType, "image/") {
+		return InlineImage{}, ErrInvalidInlineImage
+	}
+	t, err := GetTemplate(tid, uid)
+	if err != nil {
+		return InlineImage{}, err
+	}
+	a := Attachment{
+		Name:    name,
+		Type:    contentType,
+		Content: base64.StdEncoding.EncodeToString(content),
+		Inline:  true,
+	}
+	attachments := []Attachment{}
+	for _, existing := range t.Attachments {
+		if existing.Name != name {
+			attachments = append(attachments, existing)
+		}
+	}
+	t.Attachments = append(attachments, a)
+	t.ModifiedDate = time.Now().UTC()
+	err = PutTemplate(&t)
+	if err != nil {
+		return InlineImage{}, err
+	}
+	return InlineImage{Name: a.Name, Type: a.Type, CID: "cid:" + a.Name}, nil
+}
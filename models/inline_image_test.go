@@ -0,0 +1,68 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+
+	"github.com/gophish/gomail"
+	check "gopkg.in/check.v1"
+)
+
+// testPNG is a 1x1 transparent PNG
+var testPNG, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=")
+
+func (s *ModelsSuite) TestAddInlineImage(ch *check.C) {
+	t := Template{
+		Name:   "Inline Template",
+		HTML:   `<img src="cid:logo.png">`,
+		UserId: 1,
+		Attachments: []Attachment{
+			Attachment{Name: "notes.txt", Type: "text/plain", Content: base64.StdEncoding.EncodeToString([]byte("hello"))},
+		},
+	}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	defer DeleteTemplate(t.Id, 1)
+
+	_, err := AddInlineImage(t.Id, 1, "my logo.png", testPNG)
+	ch.Assert(err, check.Equals, ErrInvalidInlineImageName)
+	_, err = AddInlineImage(t.Id, 1, "logo.png", []byte("not an image"))
+	ch.Assert(err, check.Equals, ErrInvalidInlineImage)
+
+	image, err := AddInlineImage(t.Id, 1, "logo.png", testPNG)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(image, check.DeepEquals, InlineImage{Name: "logo.png", Type: "image/png", CID: "cid:logo.png"})
+
+	// Uploading an image with the same name replaces it
+	_, err = AddInlineImage(t.Id, 1, "logo.png", testPNG)
+	ch.Assert(err, check.Equals, nil)
+	t, err = GetTemplate(t.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(t.Attachments, check.HasLen, 2)
+	ch.Assert(t.InlineImages(), check.DeepEquals, []InlineImage{image})
+
+	// Inline images are embedded, while other attachments are attached
+	req := &EmailRequest{
+		FromAddress:   "from@example.com",
+		Template:      t,
+		BaseRecipient: BaseRecipient{Email: "to@example.com"},
+	}
+	msg := gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	buf := &bytes.Buffer{}
+	_, err = msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+	raw := buf.String()
+	ch.Assert(strings.Contains(raw, "multipart/related"), check.Equals, true)
+	ch.Assert(strings.Contains(raw, "Content-Disposition: inline; filename=\"logo.png\""), check.Equals, true)
+	ch.Assert(strings.Contains(raw, "Content-ID: <logo.png>"), check.Equals, true)
+	ch.Assert(strings.Contains(raw, "Content-Disposition: attachment; filename=\"notes.txt\""), check.Equals, true)
+
+	// The preview lists the inline images alongside the attachments
+	p, err := PreviewSampleEmail(t, SampleEmailRequest{FromAddress: "from@example.com"}, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(p.Attachments, check.DeepEquals, []AttachmentPreview{
+		AttachmentPreview{Name: "notes.txt", Type: "text/plain", Size: 5},
+		AttachmentPreview{Name: "logo.png", Type: "image/png", Size: len(testPNG), Inline: true},
+	})
+}
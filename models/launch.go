@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LaunchBatchSize is the number of recipients created in each transaction
+// when a campaign is created. Committing in batches keeps large campaigns
+// from holding a single transaction open, and lets progress be reported.
+const LaunchBatchSize = 500
+
+// maxInsertParams is the maximum number of parameters used in a single
+// INSERT statement. This is the lowest limit of the supported databases.
+const maxInsertParams = 999
+
+var resultColumns = []string{"campaign_id", "user_id", "r_id", "status", "ip", "latitude", "longitude", "send_date", "reported", "modified_date", "email", "first_name", "last_name", "position", "language", "tags", "notes", "target_tags", "wave", "sequence_r_id", "sequence_step", "template_id"}
+
+var mailLogColumns = []string{"user_id", "campaign_id", "r_id", "send_date", "send_attempt", "processing", "in_flight", "template_id"}
+
+// LaunchProgress reports how many of a campaign's results have been created.
+type LaunchProgress struct {
+	CampaignId int64 `json:"campaign_id"`
+	Total      int   `json:"total"`
+	Created    int   `json:"created"`
+	Complete   bool  `json:"complete"`
+}
+
+// launches tracks the progress of the campaigns currently being created.
+var launches = struct {
+	sync.Mutex
+	progress map[int64]*LaunchProgress
+}{progress: map[int64]*LaunchProgress{}}
+
+func setLaunchProgress(cid int64, total int, created int) {
+	launches.Lock()
+	defer launches.Unlock()
+	launches.progress[cid] = &LaunchProgress{CampaignId: cid, Total: total, Created: created}
+}
+
+func clearLaunchProgress(cid int64) {
+	launches.Lock()
+	defer launches.Unlock()
+	delete(launches.progress, cid)
+}
+
+// GetLaunchProgress returns the progress of creating the results for the
+// campaign with the given id, provided it's owned by the given user. Progress
+// is tracked by the instance creating the campaign, so other instances report
+// the results created so far as complete.
+func GetLaunchProgress(cid int64, uid int64) (LaunchProgress, error) {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id").Where("id=? and user_id=?", cid, uid).Find(&c).Error
+	if err != nil {
+		return LaunchProgress{}, err
+	}
+	launches.Lock()
+	p, ok := launches.progress[c.Id]
+	launches.Unlock()
+	if ok {
+		return *p, nil
+	}
+	count := 0
+	err = db.Table("results").Where("campaign_id=?", c.Id).Count(&count).Error
+	if err != nil {
+		return LaunchProgress{}, err
+	}
+	return LaunchProgress{CampaignId: c.Id, Total: count, Created: count, Complete: true}, nil
+}
+
+// generateResultIds sets a unique RId on each of the results, checking for
+// collisions with existing results using a single query per attempt.
+func generateResultIds(rs []Result) error {
+	pending := make([]int, len(rs))
+	for i := range rs {
+		pending[i] = i
+	}
+	seen := map[string]bool{}
+	for len(pending) > 0 {
+		rids := []string{}
+		for _, i := range pending {
+			rid, err := generateResultId()
+			if err != nil {
+				return err
+			}
+			rs[i].RId = rid
+			rids = append(rids, rid)
+		}
+		existing := []string{}
+		err := db.Table("results").Where("r_id IN (?)", rids).Pluck("r_id", &existing).Error
+		if err != nil {
+			return err
+		}
+		for _, rid := range existing {
+			seen[rid] = true
+		}
+		retry := []int{}
+		for _, i := range pending {
+			if seen[rs[i].RId] {
+				retry = append(retry, i)
+				continue
+			}
+			seen[rs[i].RId] = true
+		}
+		pending = retry
+	}
+	return nil
+}
+
+// insertResults inserts the results and their maillogs in a single
+// transaction, setting the id of each result.
+func insertResults(rs []Result, processing []bool) error {
+	resultRows := make([][]interface{}, len(rs))
+	mailLogRows := make([][]interface{}, len(rs))
+	rids := make([]string, len(rs))
+	for i, r := range rs {
+		resultRows[i] = []interface{}{r.CampaignId, r.UserId, r.RId, r.Status, r.IP, r.Latitude, r.Longitude, r.SendDate, r.Reported, r.ModifiedDate, r.Email, r.FirstName, r.LastName, r.Position, r.Language, r.Tags, r.Notes, r.TargetTags, r.Wave, r.SequenceRId, r.SequenceStep, r.TemplateId}
+		mailLogRows[i] = []interface{}{r.UserId, r.CampaignId, r.RId, r.SendDate, 0, processing[i], false, r.TemplateId}
+		rids[i] = r.RId
+	}
+	tx := db.Begin()
+	err := insertRows(tx.CommonDB(), "results", resultColumns, resultRows)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = insertRows(tx.CommonDB(), "mail_logs", mailLogColumns, mailLogRows)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	ids := []struct {
+		Id  int64
+		RId string
+	}{}
+	err = tx.Table("results").Select("id, r_id").Where("r_id IN (?)", rids).Scan(&ids).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return err
+	}
+	byRId := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		byRId[id.RId] = id.Id
+	}
+	for i := range rs {
+		rs[i].Id = byRId[rs[i].RId]
+	}
+	return nil
+}
+
+// insertRows inserts the rows using multi-row INSERT statements. The
+// statement for a full set of rows is prepared once and reused, so only the
+// final, shorter statement is prepared separately.
+func insertRows(conn gorm.SQLCommon, table string, columns []string, rows [][]interface{}) error {
+	perStatement := maxInsertParams / len(columns)
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+	insertQuery := func(n int) string {
+		values := make([]string, n)
+		for i := range values {
+			values[i] = placeholder
+		}
+		return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(values, ", ")
+	}
+	var stmt *sql.Stmt
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+	for start := 0; start < len(rows); start += perStatement {
+		end := start + perStatement
+		if end > len(rows) {
+			end = len(rows)
+		}
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			args = append(args, row...)
+		}
+		var err error
+		if end-start < perStatement {
+			_, err = conn.Exec(insertQuery(end-start), args...)
+		} else {
+			if stmt == nil {
+				stmt, err = conn.Prepare(insertQuery(perStatement))
+				if err != nil {
+					return err
+				}
+			}
+			_, err = stmt.Exec(args...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPostCampaignBatches(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	total := LaunchBatchSize*2 + 10
+	group := Group{Name: "Large Group", UserId: c.UserId}
+	for i := 0; i < total; i++ {
+		group.Targets = append(group.Targets, Target{BaseRecipient: BaseRecipient{Email: fmt.Sprintf("target%d@example.com", i)}})
+	}
+	ch.Assert(PostGroup(&group), check.Equals, nil)
+	// Recipients in both groups should only be sent a single email
+	c.Groups = append(c.Groups, group, c.Groups[0])
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+
+	expected := total + 4
+	ch.Assert(len(c.Results), check.Equals, expected)
+	rids := map[string]bool{}
+	for _, r := range c.Results {
+		ch.Assert(r.Id, check.Not(check.Equals), int64(0))
+		rids[r.RId] = true
+	}
+	ch.Assert(len(rids), check.Equals, expected)
+
+	// The returned results can be updated like results loaded from the
+	// database.
+	r := c.Results[len(c.Results)-1]
+	ch.Assert(r.HandleEmailOpened(EventDetails{}), check.Equals, nil)
+	got, err := GetResult(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Id, check.Equals, r.Id)
+	ch.Assert(got.Status, check.Equals, EventOpened)
+
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, expected)
+
+	p, err := GetLaunchProgress(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(p, check.Equals, LaunchProgress{CampaignId: c.Id, Total: expected, Created: expected, Complete: true})
+	_, err = GetLaunchProgress(c.Id, c.UserId+1)
+	ch.Assert(err, check.NotNil)
+}
+
+func (s *ModelsSuite) TestGetLaunchProgress(ch *check.C) {
+	c := s.createCampaign(ch)
+	setLaunchProgress(c.Id, 10, 5)
+	defer clearLaunchProgress(c.Id)
+	p, err := GetLaunchProgress(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(p, check.Equals, LaunchProgress{CampaignId: c.Id, Total: 10, Created: 5})
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Lease is a named lock held by a single Gophish instance until it expires.
+// Leases let multiple instances share a database while making sure that only
+// one of them performs work such as sending campaign emails.
+type Lease struct {
+	Name      string    `json:"name" gorm:"primary_key"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AcquireLease acquires or renews the named lease for the holder, returning
+// whether the holder has the lease. The lease can only be acquired if it's
+// free, has expired, or is already held by the holder.
+func AcquireLease(name string, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+	err := db.Model(&Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": expires}).Error
+	if err != nil {
+		return false, err
+	}
+	// The number of updated rows isn't reliable, since renewing the lease
+	// within the same second doesn't change it in databases which don't
+	// store fractional seconds, so we check who holds the lease instead.
+	l := Lease{}
+	err = db.Where("name = ?", name).First(&l).Error
+	if err == gorm.ErrRecordNotFound {
+		err = db.Create(&Lease{Name: name, Holder: holder, ExpiresAt: expires}).Error
+		if err != nil {
+			// Another instance may have created the lease first
+			if db.Where("name = ?", name).First(&l).Error == nil {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return l.Holder == holder && !l.ExpiresAt.Before(now), nil
+}
+
+// ReleaseLease releases the named lease if it's held by the holder, so that
+// another instance can acquire it without waiting for it to expire.
+func ReleaseLease(name string, holder string) error {
+	return db.Where("name = ? AND holder = ?", name, holder).Delete(&Lease{}).Error
+}
+
+// GetLease returns the named lease.
+func GetLease(name string) (Lease, error) {
+	l := Lease{}
+	err := db.Where("name = ?", name).First(&l).Error
+	return l, err
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAcquireLease(c *check.C) {
+	ok, err := AcquireLease("test", "first", time.Minute)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(ok, check.Equals, true)
+
+	// The lease can be renewed by its holder, but not taken by another
+	ok, err = AcquireLease("test", "first", time.Minute)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(ok, check.Equals, true)
+	ok, err = AcquireLease("test", "second", time.Minute)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(ok, check.Equals, false)
+
+	// Once the lease expires, it can be taken over
+	db.Model(&Lease{}).Where("name = ?", "test").Update("expires_at", time.Now().UTC().Add(-time.Second))
+	ok, err = AcquireLease("test", "second", time.Minute)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(ok, check.Equals, true)
+	l, err := GetLease("test")
+	c.Assert(err, check.Equals, nil)
+	c.Assert(l.Holder, check.Equals, "second")
+
+	// Only the holder can release the lease
+	c.Assert(ReleaseLease("test", "first"), check.Equals, nil)
+	ok, _ = AcquireLease("test", "first", time.Minute)
+	c.Assert(ok, check.Equals, false)
+	c.Assert(ReleaseLease("test", "second"), check.Equals, nil)
+	ok, _ = AcquireLease("test", "first", time.Minute)
+	c.Assert(ok, check.Equals, true)
+}
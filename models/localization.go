@@ -0,0 +1,255 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrInvalidLanguage is thrown when a recipient or translation has a
+// language which isn't a valid language tag, such as "fr" or "pt-BR".
+var ErrInvalidLanguage = errors.New("Invalid language tag")
+
+// ErrDuplicateTranslation is thrown when a template or landing page has more
+// than one translation for the same language.
+var ErrDuplicateTranslation = errors.New("Only one translation is allowed for each language")
+
+var languageTag = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{1,8})*$`)
+
+// normalizeLanguage returns the language tag in lowercase, using hyphens
+// to separate its subtags.
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(language), "_", "-", -1))
+}
+
+// validateLanguage ensures the language is a valid language tag, allowing
+// recipients without a language.
+func validateLanguage(language string) error {
+	if language != "" && !languageTag.MatchString(normalizeLanguage(language)) {
+		return ErrInvalidLanguage
+	}
+	return nil
+}
+
+// baseLanguage returns the primary subtag of the language, such as "pt" for
+// "pt-br".
+func baseLanguage(language string) string {
+	return strings.SplitN(language, "-", 2)[0]
+}
+
+// matchLanguage returns the index of the language which best matches the
+// recipient's language, or -1 if none do. An exact match is preferred,
+// followed by the recipient's base language, followed by any regional
+// variant of the base language.
+func matchLanguage(language string, available []string) int {
+	language = normalizeLanguage(language)
+	if language == "" {
+		return -1
+	}
+	base := baseLanguage(language)
+	best, bestScore := -1, 0
+	for i, a := range available {
+		a = normalizeLanguage(a)
+		score := 0
+		switch {
+		case a == language:
+			score = 3
+		case a == base:
+			score = 2
+		case baseLanguage(a) == base:
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// TemplateTranslation is a localized version of a template, sent to
+// recipients whose language matches. An empty subject uses the template's
+// subject, and an empty body uses the template's text and HTML.
+type TemplateTranslation struct {
+	Id         int64  `json:"-"`
+	TemplateId int64  `json:"-"`
+	Language   string `json:"language"`
+	Subject    string `json:"subject"`
+	Text       string `json:"text"`
+	HTML       string `json:"html" gorm:"column:html"`
+}
+
+// PageTranslation is a localized version of a landing page, shown to
+// recipients whose language matches. An empty HTML or redirect URL uses the
+// page's, so that a translation can only change the education page
+// recipients are redirected to.
+type PageTranslation struct {
+	Id          int64  `json:"-"`
+	PageId      int64  `json:"-"`
+	Language    string `json:"language"`
+	HTML        string `json:"html" gorm:"column:html"`
+	RedirectURL string `json:"redirect_url" gorm:"column:redirect_url"`
+}
+
+// validateTranslationLanguages normalizes the languages of translations,
+// ensuring each is valid and used once.
+func validateTranslationLanguages(languages []*string) error {
+	seen := map[string]bool{}
+	for _, l := range languages {
+		*l = normalizeLanguage(*l)
+		if *l == "" || !languageTag.MatchString(*l) {
+			return ErrInvalidLanguage
+		}
+		if seen[*l] {
+			return ErrDuplicateTranslation
+		}
+		seen[*l] = true
+	}
+	return nil
+}
+
+// validateTranslations ensures each of the template's translations has a
+// unique language and valid template fields.
+func (t *Template) validateTranslations() error {
+	languages := make([]*string, len(t.Translations))
+	for i := range t.Translations {
+		tt := &t.Translations[i]
+		languages[i] = &tt.Language
+		for _, s := range []string{tt.Subject, tt.Text, tt.HTML} {
+			if err := ValidateTemplate(s); err != nil {
+				return err
+			}
+		}
+	}
+	return validateTranslationLanguages(languages)
+}
+
+// getTranslations loads the translations of the template.
+func (t *Template) getTranslations() error {
+	t.Translations = []TemplateTranslation{}
+	err := db.Where("template_id=?", t.Id).Order("id asc").Find(&t.Translations).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	return err
+}
+
+// saveTranslations replaces the stored translations of the template.
+func (t *Template) saveTranslations() error {
+	err := db.Where("template_id=?", t.Id).Delete(&TemplateTranslation{}).Error
+	if err != nil {
+		return err
+	}
+	for i := range t.Translations {
+		t.Translations[i].Id = 0
+		t.Translations[i].TemplateId = t.Id
+		err = db.Save(&t.Translations[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Localize returns the template using the translation which best matches the
+// language. The template is returned unchanged if no translation matches.
+func (t Template) Localize(language string) Template {
+	languages := make([]string, len(t.Translations))
+	for i, tt := range t.Translations {
+		languages[i] = tt.Language
+	}
+	i := matchLanguage(language, languages)
+	if i == -1 {
+		return t
+	}
+	tt := t.Translations[i]
+	if tt.Subject != "" {
+		t.Subject = tt.Subject
+	}
+	// The text and HTML are replaced together, so that emails don't mix
+	// languages.
+	if tt.Text != "" || tt.HTML != "" {
+		t.Text = tt.Text
+		t.HTML = tt.HTML
+	}
+	return t
+}
+
+// validateTranslations ensures each of the page's translations has a unique
+// language and valid template fields, handling their captured credentials
+// like the page's own HTML.
+func (p *Page) validateTranslations() error {
+	languages := make([]*string, len(p.Translations))
+	for i := range p.Translations {
+		pt := &p.Translations[i]
+		languages[i] = &pt.Language
+		if err := ValidateTemplate(pt.HTML); err != nil {
+			return err
+		}
+		if err := ValidateTemplate(pt.RedirectURL); err != nil {
+			return err
+		}
+		if pt.HTML == "" {
+			continue
+		}
+		translated := Page{
+			HTML:               pt.HTML,
+			CaptureCredentials: p.CaptureCredentials,
+			CapturePasswords:   p.CapturePasswords,
+		}
+		if err := translated.parseHTML(); err != nil {
+			return err
+		}
+		pt.HTML = translated.HTML
+	}
+	return validateTranslationLanguages(languages)
+}
+
+// getTranslations loads the translations of the page.
+func (p *Page) getTranslations() error {
+	p.Translations = []PageTranslation{}
+	err := db.Where("page_id=?", p.Id).Order("id asc").Find(&p.Translations).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	return err
+}
+
+// saveTranslations replaces the stored translations of the page.
+func (p *Page) saveTranslations() error {
+	err := db.Where("page_id=?", p.Id).Delete(&PageTranslation{}).Error
+	if err != nil {
+		return err
+	}
+	for i := range p.Translations {
+		p.Translations[i].Id = 0
+		p.Translations[i].PageId = p.Id
+		err = db.Save(&p.Translations[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Localize returns the page using the translation which best matches the
+// language. The page is returned unchanged if no translation matches.
+func (p Page) Localize(language string) Page {
+	languages := make([]string, len(p.Translations))
+	for i, pt := range p.Translations {
+		languages[i] = pt.Language
+	}
+	i := matchLanguage(language, languages)
+	if i == -1 {
+		return p
+	}
+	pt := p.Translations[i]
+	if pt.HTML != "" {
+		p.HTML = pt.HTML
+	}
+	if pt.RedirectURL != "" {
+		p.RedirectURL = pt.RedirectURL
+	}
+	return p
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"bytes"
+
+	"github.com/gophish/gomail"
+	"github.com/jordan-wright/email"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestMatchLanguage(ch *check.C) {
+	available := []string{"fr", "pt-BR", "de-de"}
+	tests := []struct {
+		language string
+		expected int
+	}{
+		{"", -1},
+		{"en", -1},
+		{"fr", 0},
+		{"fr-CA", 0},
+		{"pt_BR", 1},
+		{"pt-PT", 1},
+		{"DE", 2},
+	}
+	for _, test := range tests {
+		ch.Assert(matchLanguage(test.language, available), check.Equals, test.expected, check.Commentf("language %q", test.language))
+	}
+	// Exact matches are preferred over the base language
+	ch.Assert(matchLanguage("fr-ca", []string{"fr", "fr-CA"}), check.Equals, 1)
+}
+
+func (s *ModelsSuite) TestTemplateTranslationValidation(ch *check.C) {
+	t := Template{Name: "Translated", Text: "Text", UserId: 1}
+	t.Translations = []TemplateTranslation{{Language: "not a language", Subject: "Sujet"}}
+	ch.Assert(PostTemplate(&t), check.Equals, ErrInvalidLanguage)
+
+	t.Translations = []TemplateTranslation{{Language: "fr"}, {Language: "FR"}}
+	ch.Assert(PostTemplate(&t), check.Equals, ErrDuplicateTranslation)
+
+	t.Translations = []TemplateTranslation{{Language: "fr", Text: "{{.Unclosed"}}
+	ch.Assert(PostTemplate(&t), check.NotNil)
+
+	t.Translations = []TemplateTranslation{{Language: "pt_BR", Subject: "Assunto"}}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	got, err := GetTemplate(t.Id, t.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(got.Translations), check.Equals, 1)
+	ch.Assert(got.Translations[0].Language, check.Equals, "pt-br")
+
+	ch.Assert(DeleteTemplate(t.Id, t.UserId), check.Equals, nil)
+	var count int
+	db.Model(&TemplateTranslation{}).Where("template_id=?", t.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestLocalizedEmails(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	err := db.Model(&Target{}).Where("email=?", "test1@example.com").Update("language", "fr-CA").Error
+	ch.Assert(err, check.Equals, nil)
+	c.Template.Translations = []TemplateTranslation{{
+		Language: "fr",
+		Subject:  "{{.FirstName}} - Sujet",
+		Text:     "{{.RId}} - Texte",
+	}}
+	ch.Assert(PutTemplate(&c.Template), check.Equals, nil)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.Results[0].Language, check.Equals, "fr-CA")
+
+	// The matching translation is used, without the template's HTML
+	e := s.emailFromFirstMailLog(c, ch)
+	ch.Assert(e.Subject, check.Equals, "First - Sujet")
+	ch.Assert(string(e.Text), check.Equals, c.Results[0].RId+" - Texte")
+	ch.Assert(len(e.HTML), check.Equals, 0)
+
+	// Recipients without a matching language get the template
+	m := &MailLog{}
+	err = db.Where("r_id=?", c.Results[1].RId).Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+	msg := gomail.NewMessage()
+	ch.Assert(m.Generate(msg), check.Equals, nil)
+	buf := &bytes.Buffer{}
+	_, err = msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+	got, err := email.NewEmailFromReader(buf)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, c.Results[1].RId+" - Subject")
+}
+
+func (s *ModelsSuite) TestPageTranslations(ch *check.C) {
+	p := Page{Name: "Translated", HTML: "<html><body>Hello</body></html>", RedirectURL: "https://example.com/education", UserId: 1}
+	p.Translations = []PageTranslation{
+		{Language: "fr", HTML: `<html><body><form><input name="username"/></form></body></html>`},
+		{Language: "de", RedirectURL: "https://example.com/schulung"},
+	}
+	ch.Assert(PostPage(&p), check.Equals, nil)
+
+	got, err := GetPage(p.Id, p.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(got.Translations), check.Equals, 2)
+	// Translations are handled like the page's HTML, so credentials aren't
+	// captured unless the page does
+	ch.Assert(got.Translations[0].HTML, check.Not(check.Matches), `.*name="username".*`)
+
+	fr := got.Localize("fr-FR")
+	ch.Assert(fr.HTML, check.Equals, got.Translations[0].HTML)
+	ch.Assert(fr.RedirectURL, check.Equals, p.RedirectURL)
+
+	de := got.Localize("de")
+	ch.Assert(de.HTML, check.Equals, got.HTML)
+	ch.Assert(de.RedirectURL, check.Equals, "https://example.com/schulung")
+
+	ch.Assert(got.Localize("es").HTML, check.Equals, got.HTML)
+
+	ch.Assert(DeletePage(p.Id, p.UserId), check.Equals, nil)
+	var count int
+	db.Model(&PageTranslation{}).Where("page_id=?", p.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestTargetLanguage(ch *check.C) {
+	g := Group{Name: "Languages", UserId: 1}
+	g.Targets = []Target{{BaseRecipient: BaseRecipient{Email: "test1@example.com", Language: "not a language"}}}
+	ch.Assert(PostGroup(&g), check.ErrorMatches, ErrInvalidLanguage.Error()+".*")
+
+	g.Targets[0].Language = "pt_BR"
+	ch.Assert(PostGroup(&g), check.Equals, nil)
+	ts, err := GetTargets(g.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ts[0].Language, check.Equals, "pt-br")
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gophish/gophish/proxyranges"
+)
+
+// The mail clients which can be identified from the requests for the
+// tracking image and links
+const (
+	MailClientOutlook     = "Outlook"
+	MailClientOWA         = "Outlook on the web"
+	MailClientGmail       = "Gmail"
+	MailClientGmailProxy  = "Gmail image proxy"
+	MailClientYahooProxy  = "Yahoo image proxy"
+	MailClientAppleMail   = "Apple Mail"
+	MailClientAppleMPP    = "Apple Mail Privacy Protection"
+	MailClientThunderbird = "Thunderbird"
+)
+
+// appleProxyAgent is the user agent sent by Apple's Mail Privacy Protection
+// proxy, which fetches remote images when emails are delivered rather than
+// when they're read.
+const appleProxyAgent = "mozilla/5.0"
+
+// webmailReferers map the hosts of webmail clients to the client. Browsers
+// usually send them as the referer when links or images are loaded from
+// emails read on the web.
+var webmailReferers = map[string]string{
+	"outlook.office.com":    MailClientOWA,
+	"outlook.office365.com": MailClientOWA,
+	"outlook.live.com":      MailClientOWA,
+	"mail.google.com":       MailClientGmail,
+}
+
+// DetectMailClient identifies the mail client which loaded the tracking
+// image or a link, using the request's user agent and referer. Requests made
+// by image proxies identify the proxy rather than the client behind it, since
+// the proxy decides when the image is loaded. An empty string is returned if
+// the client can't be identified, such as for links opened in a browser.
+func DetectMailClient(ua, referer string) string {
+	lower := strings.ToLower(strings.TrimSpace(ua))
+	switch {
+	case strings.Contains(lower, "googleimageproxy"):
+		return MailClientGmailProxy
+	case strings.Contains(lower, "yahoomailproxy"):
+		return MailClientYahooProxy
+	case lower == appleProxyAgent:
+		return MailClientAppleMPP
+	case strings.Contains(lower, "microsoft outlook") || strings.Contains(lower, "ms-office") || strings.Contains(lower, "msoffice"):
+		return MailClientOutlook
+	case strings.Contains(lower, "thunderbird/"):
+		return MailClientThunderbird
+	}
+	if u, err := url.Parse(referer); err == nil && u.Host != "" {
+		if client, ok := webmailReferers[strings.ToLower(u.Hostname())]; ok {
+			return client
+		}
+	}
+	// Apple Mail renders emails using WebKit without identifying itself as
+	// Safari
+	if strings.Contains(lower, "applewebkit") && strings.Contains(lower, "mac os x") &&
+		!strings.Contains(lower, "safari") && !strings.Contains(lower, "version/") {
+		return MailClientAppleMail
+	}
+	return ""
+}
+
+// proxyClients are the mail clients which are image proxies, rather than
+// the recipient's client
+var proxyClients = map[string]bool{
+	MailClientGmailProxy: true,
+	MailClientYahooProxy: true,
+	MailClientAppleMPP:   true,
+}
+
+// flagMachineOpen marks the details of an open made by a mail privacy proxy,
+// as identified by its user agent or address. Proxies may load the tracking
+// image as soon as the email is delivered, so these opens don't show that the
+// email was read.
+func (d EventDetails) flagMachineOpen() EventDetails {
+	machine := proxyClients[d.Browser["mail-client"]] || proxyranges.Default.Contains(net.ParseIP(d.Browser["address"]))
+	if !machine {
+		return d
+	}
+	browser := map[string]string{"machine-open": "true"}
+	for k, v := range d.Browser {
+		browser[k] = v
+	}
+	d.Browser = browser
+	return d
+}
+
+// updateMailClient records the mail client found in the event details, if
+// any, against the result.
+func (r *Result) updateMailClient(details interface{}) error {
+	d, ok := details.(EventDetails)
+	if !ok {
+		return nil
+	}
+	client := d.Browser["mail-client"]
+	if client == "" || client == r.MailClient {
+		return nil
+	}
+	r.MailClient = client
+	return db.Model(&Result{}).Where("id=?", r.Id).Update("mail_client", client).Error
+}
+
+// getMailClientStats returns the number of recipients of the campaign using
+// each mail client.
+func getMailClientStats(cid int64) (map[string]int64, error) {
+	rows, err := reportingDB().Table("results").Select("mail_client, count(*)").
+		Where("campaign_id=? and mail_client<>?", cid, "").Group("mail_client").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clients := map[string]int64{}
+	for rows.Next() {
+		var client string
+		var count int64
+		if err := rows.Scan(&client, &count); err != nil {
+			return nil, err
+		}
+		clients[client] = count
+	}
+	return clients, rows.Err()
+}
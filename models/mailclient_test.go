@@ -0,0 +1,92 @@
+package models
+
+import (
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestDetectMailClient(ch *check.C) {
+	tests := []struct {
+		ua       string
+		referer  string
+		expected string
+	}{
+		{ua: "Mozilla/5.0 (Windows NT 5.1; rv:11.0) Gecko Firefox/11.0 (via ggpht.com GoogleImageProxy)", expected: MailClientGmailProxy},
+		{ua: "YahooMailProxy; https://help.yahoo.com/kb/yahoo-mail-proxy-SLN28749.html", expected: MailClientYahooProxy},
+		{ua: "Mozilla/5.0", expected: MailClientAppleMPP},
+		{ua: "Microsoft Office/16.0 (Windows NT 10.0; Microsoft Outlook 16.0.13901; Pro)", expected: MailClientOutlook},
+		{ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:78.0) Gecko/20100101 Thunderbird/78.10.0", expected: MailClientThunderbird},
+		{ua: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko)", expected: MailClientAppleMail},
+		{ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.93 Safari/537.36", referer: "https://outlook.office.com/", expected: MailClientOWA},
+		{ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.93 Safari/537.36", referer: "https://mail.google.com/mail/u/0/", expected: MailClientGmail},
+		{ua: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1 Safari/605.1.15", expected: ""},
+		{ua: "", expected: ""},
+	}
+	for _, tc := range tests {
+		ch.Assert(DetectMailClient(tc.ua, tc.referer), check.Equals, tc.expected, check.Commentf("user agent %q", tc.ua))
+	}
+}
+
+func (s *ModelsSuite) TestResultMailClient(ch *check.C) {
+	c := s.createCampaign(ch)
+	for _, r := range c.Results {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+	r := c.Results[0]
+	details := EventDetails{Browser: map[string]string{"mail-client": MailClientOutlook}}
+	ch.Assert(r.HandleEmailOpened(details), check.Equals, nil)
+	// Clicks from a browser don't replace the mail client
+	ch.Assert(r.HandleClickedLink(EventDetails{Browser: map[string]string{}}), check.Equals, nil)
+
+	found, err := GetResult(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(found.MailClient, check.Equals, MailClientOutlook)
+
+	r = c.Results[1]
+	details.Browser["mail-client"] = MailClientAppleMPP
+	ch.Assert(r.HandleEmailOpened(details), check.Equals, nil)
+
+	cr, err := GetCampaignResults(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(cr.MailClients, check.DeepEquals, map[string]int64{MailClientOutlook: 1, MailClientAppleMPP: 1})
+}
+
+func (s *ModelsSuite) TestMachineOpens(ch *check.C) {
+	c := s.createCampaign(ch)
+	for _, r := range c.Results {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+	// Opened by Apple Mail Privacy Protection, identified by its user agent
+	proxied := EventDetails{Browser: map[string]string{"mail-client": MailClientAppleMPP}}
+	ch.Assert(c.Results[0].HandleEmailOpened(proxied), check.Equals, nil)
+	// Opened through the Gmail image proxy's address
+	proxied = EventDetails{Browser: map[string]string{"address": "66.249.84.1"}}
+	ch.Assert(c.Results[1].HandleEmailOpened(proxied), check.Equals, nil)
+	// Opened by a proxy, and later by the recipient
+	ch.Assert(c.Results[2].HandleEmailOpened(proxied), check.Equals, nil)
+	ch.Assert(c.Results[2].HandleEmailOpened(EventDetails{}), check.Equals, nil)
+	// Opened by a proxy, but then clicked, which only a person does
+	ch.Assert(c.Results[3].HandleEmailOpened(proxied), check.Equals, nil)
+	ch.Assert(c.Results[3].HandleClickedLink(EventDetails{}), check.Equals, nil)
+
+	r, err := GetResult(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.MachineOpened, check.Equals, true)
+	ch.Assert(r.HumanOpened, check.Equals, false)
+	r, err = GetResult(c.Results[2].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.MachineOpened, check.Equals, true)
+	ch.Assert(r.HumanOpened, check.Equals, true)
+
+	stats, err := getCampaignStats(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.OpenedEmail, check.Equals, int64(4))
+	ch.Assert(stats.MachineOpened, check.Equals, int64(2))
+	ch.Assert(stats.HumanOpened, check.Equals, int64(2))
+
+	es := []Event{}
+	ch.Assert(db.Where("campaign_id=? AND message=?", c.Id, EventOpened).Order("id asc").Find(&es).Error, check.Equals, nil)
+	ch.Assert(strings.Contains(string(es[0].Details), `"machine-open":"true"`), check.Equals, true)
+	ch.Assert(strings.Contains(string(es[3].Details), "machine-open"), check.Equals, false)
+}
@@ -9,12 +9,13 @@ import (
 	"math/big"
 	"net/mail"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gophish/gomail"
-	"github.com/gophish/gophish/config"
 	log "github.com/gophish/gophish/logger"
 	"github.com/gophish/gophish/mailer"
+	"github.com/jinzhu/gorm"
 )
 
 // MaxSendAttempts set to 8 since we exponentially backoff after each failed send
@@ -35,8 +36,27 @@ type MailLog struct {
 	SendDate    time.Time `json:"send_date"`
 	SendAttempt int       `json:"send_attempt"`
 	Processing  bool      `json:"-"`
+	InFlight    bool      `json:"-"`
+	// Transcript is the SMTP conversation from the most recent failed
+	// attempt to send the email.
+	Transcript string `json:"transcript,omitempty"`
+	// SMTPId is the sending profile used instead of the campaign's, such as
+	// when re-sending failed emails using a different profile.
+	SMTPId int64 `json:"-"`
+	// TemplateId is the template used instead of the campaign's, such as
+	// for recipients of a campaign wave with its own template.
+	TemplateId int64 `json:"-"`
 
 	cachedCampaign *Campaign
+	envelopeFrom   string
+	// generateFailed is set when the email couldn't be generated, so that
+	// the failure isn't blamed on the sending profile.
+	generateFailed bool
+	smtp           *SMTP
+	modifiers      []CampaignModifier
+	// messageId is the Message-ID of the generated email, which is saved
+	// with the result once it's sent.
+	messageId string
 }
 
 // GenerateMailLog creates a new maillog for the given campaign and
@@ -60,20 +80,24 @@ func (m *MailLog) Backoff(reason error) error {
 	if err != nil {
 		return err
 	}
+	if m.pauseSending(reason) {
+		return m.Unlock()
+	}
 	if m.SendAttempt == MaxSendAttempts {
-		r.HandleEmailError(ErrMaxSendAttempts)
+		r.handleEmailError(ErrMaxSendAttempts, m.Transcript)
 		return ErrMaxSendAttempts
 	}
 	// Add an error, since we had to backoff because of a
 	// temporary error of some sort during the SMTP transaction
 	m.SendAttempt++
+	m.InFlight = false
 	backoffDuration := math.Pow(2, float64(m.SendAttempt))
 	m.SendDate = m.SendDate.Add(time.Minute * time.Duration(backoffDuration))
 	err = db.Save(m).Error
 	if err != nil {
 		return err
 	}
-	err = r.HandleEmailBackoff(reason, m.SendDate)
+	err = r.handleEmailBackoff(reason, m.SendDate, m.Transcript)
 	if err != nil {
 		return err
 	}
@@ -84,9 +108,19 @@ func (m *MailLog) Backoff(reason error) error {
 // Unlock removes the processing flag so the maillog can be processed again
 func (m *MailLog) Unlock() error {
 	m.Processing = false
+	m.InFlight = false
 	return db.Save(&m).Error
 }
 
+// MarkInFlight records that the email is about to be handed to the SMTP
+// server. If Gophish stops before the result of sending the email is saved,
+// the flag tells RecoverMailLogs that the email may have been delivered.
+func (m *MailLog) MarkInFlight() error {
+	m.InFlight = true
+	m.Transcript = ""
+	return db.Model(m).UpdateColumn("in_flight", true).Error
+}
+
 // Lock sets the processing flag so that other processes cannot modify the maillog
 func (m *MailLog) Lock() error {
 	m.Processing = true
@@ -102,7 +136,10 @@ func (m *MailLog) Error(e error) error {
 		log.Warn(err)
 		return err
 	}
-	err = r.HandleEmailError(e)
+	if m.pauseSending(e) {
+		return m.Unlock()
+	}
+	err = r.handleEmailError(e, m.Transcript)
 	if err != nil {
 		log.Warn(err)
 		return err
@@ -122,23 +159,114 @@ func (m *MailLog) Success() error {
 	if err != nil {
 		return err
 	}
+	if m.messageId != "" {
+		err = db.Model(&Result{}).Where("id=?", r.Id).Update("message_id", m.messageId).Error
+		if err != nil {
+			return err
+		}
+	}
+	recordSendSuccess(m.sendingProfileId())
 	err = db.Delete(m).Error
 	return err
 }
 
+// sendingProfileId returns the id of the sending profile the email is sent
+// with.
+func (m *MailLog) sendingProfileId() int64 {
+	if m.SMTPId != 0 {
+		return m.SMTPId
+	}
+	if m.cachedCampaign != nil {
+		return m.cachedCampaign.SMTP.Id
+	}
+	c := Campaign{}
+	err := db.Table("campaigns").Select("smtp_id").Where("id=?", m.CampaignId).Find(&c).Error
+	if err != nil {
+		log.Error(err)
+	}
+	return c.SMTPId
+}
+
+// pauseSending records the failure against the email's sending profile, and
+// returns whether the profile's circuit breaker has tripped. If it has, the
+// email is left queued to be sent once the profile recovers, rather than
+// failing.
+func (m *MailLog) pauseSending(e error) bool {
+	sid := m.sendingProfileId()
+	if e != ErrSendingProfilePaused && !m.generateFailed {
+		recordSendFailure(sid, e)
+	}
+	return SendingProfilePaused(sid)
+}
+
+// SetTranscript records the SMTP conversation of a failed attempt to send the
+// email. It's saved along with the maillog when the email is retried, and
+// kept with the sending error event if the email can't be sent.
+func (m *MailLog) SetTranscript(transcript []string) {
+	m.Transcript = strings.Join(transcript, "\n")
+}
+
+// EnvelopeFrom returns the envelope sender set by the campaign's template.
+func (m *MailLog) EnvelopeFrom() string {
+	return m.envelopeFrom
+}
+
+// RewriteMessage applies the sending profile's boundary and header order
+// settings, and the campaign's message modifiers, to the generated message,
+// then signs it with the sending profile's DKIM key.
+func (m *MailLog) RewriteMessage(raw []byte) ([]byte, error) {
+	if m.smtp != nil {
+		var err error
+		raw, err = m.smtp.RewriteMessage(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	raw, err := applyRawModifiers(m.modifiers, raw)
+	if err != nil || m.smtp == nil {
+		return raw, err
+	}
+	return m.smtp.signMessage(raw)
+}
+
 // GetDialer returns a dialer based on the maillog campaign's SMTP configuration
 func (m *MailLog) GetDialer() (mailer.Dialer, error) {
 	c := m.cachedCampaign
 	if c == nil {
-		campaign, err := GetCampaignMailContext(m.CampaignId, m.UserId)
+		campaign, err := GetMailLogContext(m)
 		if err != nil {
 			return nil, err
 		}
 		c = &campaign
 	}
+	if c.Simulate || simulatedSendingEnabled() {
+		return simulatedDialer(fmt.Sprintf("campaign-%d", c.Id), &c.SMTP), nil
+	}
 	return c.SMTP.GetDialer()
 }
 
+// GetMailLogContext returns the campaign mail context used to send the
+// maillog's email. If the maillog has its own sending profile or template,
+// they're used in place of the campaign's.
+func GetMailLogContext(m *MailLog) (Campaign, error) {
+	c, err := GetCampaignMailContext(m.CampaignId, m.UserId)
+	if err != nil {
+		return c, err
+	}
+	if m.TemplateId != 0 && m.TemplateId != c.TemplateId {
+		c.Template, err = getMailTemplate(m.TemplateId)
+		if err != nil {
+			return c, err
+		}
+		c.TemplateId = m.TemplateId
+	}
+	if m.SMTPId == 0 || m.SMTPId == c.SMTPId {
+		return c, nil
+	}
+	c.SMTP, err = GetSMTP(m.SMTPId, m.UserId)
+	return c, err
+}
+
 // CacheCampaign allows bulk-mail workers to cache the otherwise expensive
 // campaign lookup operation by providing a pointer to the campaign here.
 func (m *MailLog) CacheCampaign(campaign *Campaign) error {
@@ -154,42 +282,67 @@ func (m *MailLog) CacheCampaign(campaign *Campaign) error {
 // the maillog. We accept the gomail.Message as an argument so that the caller
 // can choose to re-use the message across recipients.
 func (m *MailLog) Generate(msg *gomail.Message) error {
+	if SendingProfilePaused(m.sendingProfileId()) {
+		return ErrSendingProfilePaused
+	}
+	err := m.generate(msg)
+	m.generateFailed = err != nil
+	return err
+}
+
+// generate fills in the message for Generate.
+func (m *MailLog) generate(msg *gomail.Message) error {
 	r, err := GetResult(m.RId)
 	if err != nil {
 		return err
 	}
 	c := m.cachedCampaign
 	if c == nil {
-		campaign, err := GetCampaignMailContext(m.CampaignId, m.UserId)
+		campaign, err := GetMailLogContext(m)
 		if err != nil {
 			return err
 		}
 		c = &campaign
 	}
+	// The template is localized on a copy of the campaign, since it may be
+	// cached and shared between recipients.
+	if len(c.Template.Translations) > 0 {
+		localized := *c
+		localized.Template = c.Template.Localize(r.Language)
+		c = &localized
+	}
 
 	f, err := mail.ParseAddress(c.SMTP.FromAddress)
 	if err != nil {
 		return err
 	}
-	msg.SetAddressHeader("From", f.Address, f.Name)
+	if c.Template.FromName != "" {
+		f.Name = c.Template.FromName
+	}
+	m.envelopeFrom = c.Template.envelopeFrom()
+	msg.SetAddressHeader("From", asciiAddress(f.Address), f.Name)
 
 	ptx, err := NewPhishingTemplateContext(c, r.BaseRecipient, r.RId)
 	if err != nil {
 		return err
 	}
 
-	// Add the transparency headers
-	msg.SetHeader("X-Mailer", config.ServerName)
+	// Add the transparency headers, and the Message-Id header as described
+	// in RFC 2822
+	err = c.SMTP.setMessageHeaders(msg)
+	if err != nil {
+		return err
+	}
+	if ids := msg.GetHeader("Message-Id"); len(ids) > 0 {
+		m.messageId = ids[0]
+	}
 	if conf.ContactAddress != "" {
 		msg.SetHeader("X-Gophish-Contact", conf.ContactAddress)
 	}
-
-	// Add Message-Id header as described in RFC 2822.
-	messageID, err := m.generateMessageID()
-	if err != nil {
-		return err
+	if c.AllowlistToken != "" {
+		msg.SetHeader(AllowlistHeader, string(c.AllowlistToken))
 	}
-	msg.SetHeader("Message-Id", messageID)
+	m.smtp = &c.SMTP
 
 	// Parse the customHeader templates
 	for _, header := range c.SMTP.Headers {
@@ -207,12 +360,37 @@ func (m *MailLog) Generate(msg *gomail.Message) error {
 		msg.SetHeader(key, value)
 	}
 
+	// The template's Reply-To address takes precedence over any set by the
+	// sending profile
+	if c.Template.ReplyTo != "" {
+		rt, err := mail.ParseAddress(c.Template.ReplyTo)
+		if err != nil {
+			return err
+		}
+		msg.SetAddressHeader("Reply-To", asciiAddress(rt.Address), rt.Name)
+	}
+
+	// Thread replies refer to a fake prior message, which is quoted in
+	// the body
+	var prior *priorMessage
+	if c.Template.Thread {
+		prior, err = c.Template.priorMessage(ptx)
+		if err != nil {
+			return err
+		}
+		msg.SetHeader("In-Reply-To", prior.MessageID)
+		msg.SetHeader("References", prior.MessageID)
+	}
+
 	// Parse remaining templates
 	subject, err := ExecuteTemplate(c.Template.Subject, ptx)
 
 	if err != nil {
 		log.Warn(err)
 	}
+	if prior != nil {
+		subject = threadSubject(subject)
+	}
 	// don't set Subject header if the subject is empty
 	if subject != "" {
 		msg.SetHeader("Subject", subject)
@@ -224,43 +402,75 @@ func (m *MailLog) Generate(msg *gomail.Message) error {
 		if err != nil {
 			log.Warn(err)
 		}
-		msg.SetBody("text/plain", text)
+		msg.SetBody("text/plain", prior.quoteText(text))
 	}
 	if c.Template.HTML != "" {
 		html, err := ExecuteTemplate(c.Template.HTML, ptx)
 		if err != nil {
 			log.Warn(err)
 		}
-		if c.Template.Text == "" {
-			msg.SetBody("text/html", html)
-		} else {
+		html = prior.quoteHTML(html)
+		switch {
+		case c.Template.Text != "":
+			msg.AddAlternative("text/html", html)
+		case c.Template.GenerateText:
+			text, err := htmlToText(html)
+			if err != nil {
+				log.Warn(err)
+			}
+			msg.SetBody("text/plain", text)
 			msg.AddAlternative("text/html", html)
+		default:
+			msg.SetBody("text/html", html)
 		}
 	}
 	// Attach the files
 	for i, _ := range c.Template.Attachments {
 		a := &c.Template.Attachments[i]
-		msg.Attach(func(a *Attachment) (string, gomail.FileSetting, gomail.FileSetting) {
+		attach := msg.Attach
+		if a.Inline {
+			attach = msg.Embed
+		}
+		attach(func(a *Attachment) (string, gomail.FileSetting, gomail.FileSetting) {
 			h := map[string][]string{"Content-ID": {fmt.Sprintf("<%s>", a.Name)}}
 			return a.Name, gomail.SetCopyFunc(func(w io.Writer) error {
 				content, err := a.ApplyTemplate(ptx)
 				if err != nil {
 					return err
 				}
+				defer content.Close()
 				_, err = io.Copy(w, content)
 				return err
 			}), gomail.SetHeader(h)
 		}(a))
 	}
 
-	return nil
+	// Apply the campaign's message modifiers last, so they can change
+	// anything set above
+	m.modifiers = c.Modifiers
+	return applyModifiers(c.Modifiers, msg, r.Email)
 }
 
 // GetQueuedMailLogs returns the mail logs that are queued up for the given minute.
 func GetQueuedMailLogs(t time.Time) ([]*MailLog, error) {
+	return GetQueuedMailLogsBatch(t, 0)
+}
+
+// GetQueuedMailLogsBatch returns at most limit of the mail logs that are
+// queued up for the given minute, starting with those of the campaigns with
+// the highest priority, then those which have waited the longest. If limit
+// is zero, every queued mail log is returned.
+func GetQueuedMailLogsBatch(t time.Time, limit int) ([]*MailLog, error) {
 	ms := []*MailLog{}
-	err := db.Where("send_date <= ? AND processing = ?", t, false).
-		Find(&ms).Error
+	query := db.Table("mail_logs").Select("mail_logs.*").
+		Joins("left join campaigns on campaigns.id = mail_logs.campaign_id").
+		Where("mail_logs.send_date <= ? AND mail_logs.processing = ?", t, false).
+		Where("campaigns.status IS NULL OR campaigns.status <> ?", CampaignPending).
+		Order("campaigns.priority desc, mail_logs.send_date asc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&ms).Error
 	if err != nil {
 		log.Warn(err)
 	}
@@ -274,6 +484,19 @@ func GetMailLogsByCampaign(cid int64) ([]*MailLog, error) {
 	return ms, err
 }
 
+// GetCampaignMailLogs returns the emails waiting to be sent for a campaign
+// owned by the given user, including the transcripts of any failed attempts.
+func GetCampaignMailLogs(cid int64, uid int64) ([]*MailLog, error) {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id").Where("id=? and user_id=?", cid, uid).Find(&c).Error
+	if err != nil {
+		return nil, err
+	}
+	ms := []*MailLog{}
+	err = db.Where("campaign_id = ?", c.Id).Order("send_date asc").Find(&ms).Error
+	return ms, err
+}
+
 // LockMailLogs locks or unlocks a slice of maillogs for processing.
 func LockMailLogs(ms []*MailLog, lock bool) error {
 	tx := db.Begin()
@@ -296,6 +519,32 @@ func UnlockAllMailLogs() error {
 	return db.Model(&MailLog{}).Update("processing", false).Error
 }
 
+// RecoverMailLogs prepares the maillogs left over from when Gophish was last
+// stopped to be processed again. Maillogs which were locked but not yet sent
+// are unlocked so that they're retried.
+//
+// Maillogs which were in the middle of being sent may have been delivered, so
+// they're marked as sent rather than risking sending the email twice.
+func RecoverMailLogs() error {
+	ms := []*MailLog{}
+	err := db.Where("in_flight = ?", true).Find(&ms).Error
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		log.Warnf("The email for result %s was being sent when Gophish stopped. Marking it as sent to avoid sending it twice.", m.RId)
+		err = m.Success()
+		// The campaign may have been deleted in the meantime
+		if err == gorm.ErrRecordNotFound {
+			err = db.Delete(m).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return UnlockAllMailLogs()
+}
+
 var maxBigInt = big.NewInt(math.MaxInt64)
 
 // generateMessageID generates and returns a string suitable for an RFC 2822
@@ -307,7 +556,7 @@ var maxBigInt = big.NewInt(math.MaxInt64)
 // - The calling PID
 // - A cryptographically random int64
 // - The sending hostname
-func (m *MailLog) generateMessageID() (string, error) {
+func generateMessageID() (string, error) {
 	t := time.Now().UnixNano()
 	pid := os.Getpid()
 	rint, err := rand.Int(rand.Reader, maxBigInt)
@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"math"
 	"net/textproto"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gophish/gophish/config"
 
 	"github.com/gophish/gomail"
+	"github.com/jinzhu/gorm"
 	"github.com/jordan-wright/email"
 	"gopkg.in/check.v1"
 )
@@ -63,6 +65,21 @@ func (s *ModelsSuite) TestGetQueuedMailLogs(ch *check.C) {
 	}
 }
 
+func (s *ModelsSuite) TestGetQueuedMailLogsBatch(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	ms, err := GetMailLogsByCampaign(campaign.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms) > 1, check.Equals, true)
+	err = LockMailLogs(ms, false)
+	ch.Assert(err, check.Equals, nil)
+	ms, err = GetQueuedMailLogsBatch(campaign.LaunchDate, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, 1)
+	ms, err = GetQueuedMailLogsBatch(campaign.LaunchDate, 0)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, len(campaign.Results))
+}
+
 func (s *ModelsSuite) TestMailLogBackoff(ch *check.C) {
 	campaign := s.createCampaign(ch)
 	result := campaign.Results[0]
@@ -141,7 +158,7 @@ func (s *ModelsSuite) TestMailLogError(ch *check.C) {
 		Email:      result.Email,
 		Message:    EventSendingError,
 		CampaignId: campaign.Id,
-		Details:    string(ej),
+		Details:    EncryptedString(ej),
 		Time:       gotEvent.Time,
 	}
 	ch.Assert(gotEvent, check.DeepEquals, expectedEvent)
@@ -151,6 +168,48 @@ func (s *ModelsSuite) TestMailLogError(ch *check.C) {
 	ch.Assert(len(ms), check.Equals, len(campaign.Results)-1)
 }
 
+func (s *ModelsSuite) TestMailLogTranscript(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	ms, err := GetMailLogsByCampaign(campaign.Id)
+	ch.Assert(err, check.Equals, nil)
+	m := ms[0]
+	transcript := []string{"C: RCPT TO:<foo@example.com>", "S: 450 Mailbox busy"}
+
+	// The transcript is saved with the maillog while the email is retried
+	m.SetTranscript(transcript)
+	err = m.Backoff(&textproto.Error{Code: 450, Msg: "Mailbox busy"})
+	ch.Assert(err, check.Equals, nil)
+	ms, err = GetCampaignMailLogs(campaign.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	found := false
+	for _, got := range ms {
+		if got.RId == m.RId {
+			found = true
+			ch.Assert(got.Transcript, check.Equals, strings.Join(transcript, "\n"))
+		}
+	}
+	ch.Assert(found, check.Equals, true)
+	_, err = GetCampaignMailLogs(campaign.Id, 2)
+	ch.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+
+	// Starting another attempt clears the previous transcript
+	err = m.MarkInFlight()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(m.Transcript, check.Equals, "")
+
+	// The transcript is kept with the error event once the maillog is deleted
+	m.SetTranscript(transcript)
+	err = m.Error(&textproto.Error{Code: 550, Msg: "No such user"})
+	ch.Assert(err, check.Equals, nil)
+	campaign, err = GetCampaign(campaign.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	last := campaign.Events[len(campaign.Events)-1]
+	details := EventError{}
+	err = json.Unmarshal([]byte(last.Details), &details)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(details.Transcript, check.Equals, strings.Join(transcript, "\n"))
+}
+
 func (s *ModelsSuite) TestMailLogSuccess(ch *check.C) {
 	campaign := s.createCampaign(ch)
 	result := campaign.Results[0]
@@ -282,6 +341,30 @@ func (s *ModelsSuite) TestUnlockAllMailLogs(ch *check.C) {
 	}
 }
 
+func (s *ModelsSuite) TestRecoverMailLogs(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	ms, err := GetMailLogsByCampaign(campaign.Id)
+	ch.Assert(err, check.Equals, nil)
+	inFlight := ms[0]
+	ch.Assert(inFlight.MarkInFlight(), check.Equals, nil)
+
+	err = RecoverMailLogs()
+	ch.Assert(err, check.Equals, nil)
+
+	// The email which may have been delivered isn't sent again
+	result, err := GetResult(inFlight.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Status, check.Equals, EventSent)
+	remaining, err := GetMailLogsByCampaign(campaign.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(remaining), check.Equals, len(ms)-1)
+	for _, m := range remaining {
+		ch.Assert(m.RId, check.Not(check.Equals), inFlight.RId)
+		ch.Assert(m.Processing, check.Equals, false)
+		ch.Assert(m.InFlight, check.Equals, false)
+	}
+}
+
 func (s *ModelsSuite) TestURLTemplateRendering(ch *check.C) {
 	template := Template{
 		Name:    "URLTemplate",
@@ -45,6 +45,7 @@ const (
 	CampaignCreated    string = "Created"
 	CampaignEmailsSent string = "Emails Sent"
 	CampaignComplete   string = "Completed"
+	CampaignPending    string = "Pending Approval"
 	EventSent          string = "Email Sent"
 	EventSendingError  string = "Error Sending Email"
 	EventOpened        string = "Email Opened"
@@ -52,6 +53,12 @@ const (
 	EventDataSubmit    string = "Submitted Data"
 	EventReported      string = "Email Reported"
 	EventProxyRequest  string = "Proxied request"
+	EventForwarded     string = "Email Forwarded"
+	EventAutoReplied   string = "Auto Reply"
+	EventUnsubscribed  string = "Unsubscribed"
+	EventBlocked       string = "Blocked Request"
+	EventLinkFetched   string = "Link Fetched"
+	EventShortLink     string = "Clicked Short Link"
 	StatusSuccess      string = "Success"
 	StatusQueued       string = "Queued"
 	StatusSending      string = "Sending"
@@ -170,9 +177,13 @@ func Setup(c *config.Config) error {
 	}
 
 	// Open our database connection
+	dsn := conf.DBPath
+	if conf.DBName == "sqlite3" {
+		dsn = sqliteDSN(conf.DBPath, conf.SQLite)
+	}
 	i := 0
 	for {
-		db, err = gorm.Open(conf.DBName, conf.DBPath)
+		db, err = gorm.Open(conf.DBName, dsn)
 		if err == nil {
 			break
 		}
@@ -186,7 +197,11 @@ func Setup(c *config.Config) error {
 	}
 	db.LogMode(false)
 	db.SetLogger(log.Logger)
-	db.DB().SetMaxOpenConns(1)
+	if conf.DBName == "sqlite3" {
+		setupSQLite(conf.DBPath, conf.SQLite)
+	} else {
+		db.DB().SetMaxOpenConns(1)
+	}
 	if err != nil {
 		log.Error(err)
 		return err
@@ -197,6 +212,19 @@ func Setup(c *config.Config) error {
 		log.Error(err)
 		return err
 	}
+	setupReplica(conf)
+	// Encrypt any existing sensitive data with the configured key
+	err = setupEncryption(conf)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	// Move any attachments stored in the database to object storage
+	err = setupObjectStorage(conf.ObjectStorage)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	// Create the admin user if it doesn't exist
 	var userCount int64
 	var adminUser User
@@ -215,9 +243,9 @@ func Setup(c *config.Config) error {
 		}
 
 		if envToken := os.Getenv(InitialAdminApiToken); envToken != "" {
-			adminUser.ApiKey = envToken
+			adminUser.ApiKey = EncryptedString(envToken)
 		} else {
-			adminUser.ApiKey = auth.GenerateSecureKey(auth.APIKeyLength)
+			adminUser.ApiKey = EncryptedString(auth.GenerateSecureKey(auth.APIKeyLength))
 		}
 
 		err = db.Save(&adminUser).Error
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/dnsprovider"
 	"gopkg.in/check.v1"
 )
 
@@ -16,11 +17,16 @@ type ModelsSuite struct {
 
 var _ = check.Suite(&ModelsSuite{})
 
+// testAnonymizeKey is the key used to pseudonymize the recipients of
+// campaigns in privacy mode.
+const testAnonymizeKey = "0101010101010101010101010101010101010101010101010101010101010101"
+
 func (s *ModelsSuite) SetUpSuite(c *check.C) {
 	conf := &config.Config{
 		DBName:         "sqlite3",
 		DBPath:         ":memory:",
 		MigrationsPath: "../db/db_sqlite3/migrations/",
+		Encryption:     config.Encryption{AnonymizeKey: testAnonymizeKey},
 	}
 	s.config = conf
 	err := Setup(conf)
@@ -40,6 +46,34 @@ func (s *ModelsSuite) TearDownTest(c *check.C) {
 	db.Delete(Result{})
 	db.Delete(MailLog{})
 	db.Delete(Campaign{})
+	db.Delete(EventRequest{})
+	db.Delete(CampaignPurge{})
+	db.Delete(Lease{})
+	db.Delete(SigningKey{})
+	db.Delete(PageAccessRule{})
+	db.Delete(Honeytoken{})
+	db.Delete(HoneytokenSighting{})
+	db.Delete(SMTPHealth{})
+	db.Delete(CampaignModifier{})
+	db.Delete(CampaignWave{})
+	db.Delete(SequenceStep{})
+	db.Delete(Webhook{})
+	db.Delete(WebhookSecret{})
+	db.Delete(OrgMember{})
+	db.Delete(TemplateTranslation{})
+	db.Delete(PageTranslation{})
+	db.Delete(NotificationRule{})
+	db.Delete(NotificationLog{})
+	db.Delete(Domain{})
+	db.Delete(PasswordHistory{})
+	db.Delete(AdminSession{})
+	db.Delete(EmbedToken{})
+	db.Delete(TicketConnector{})
+	db.Delete(TicketLog{})
+	db.Delete(IncidentConnector{})
+	db.Delete(ReportedMessage{})
+	DNSProviders = map[string]dnsprovider.Provider{}
+	db.Exec("DELETE FROM archived_events")
 
 	// Reset users table to default state.
 	db.Not("id", 1).Delete(User{})
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// NotFoundPageRequest sets the landing page a campaign serves in place of
+// the phishing server's 404 response.
+type NotFoundPageRequest struct {
+	PageId int64 `json:"page_id"`
+}
+
+// validateNotFoundPage ensures the campaign's not found page belongs to the
+// user.
+func (c *Campaign) validateNotFoundPage(uid int64) error {
+	if c.NotFoundPageId == 0 {
+		return nil
+	}
+	var count int
+	err := db.Model(&Page{}).Where("id=? and user_id=?", c.NotFoundPageId, uid).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPageNotFound
+	}
+	return nil
+}
+
+// SetCampaignNotFoundPage changes the landing page served in place of the
+// 404 response for the campaign's requests, so that it can be changed once
+// the campaign is completed. A page id of zero restores the 404 response.
+func SetCampaignNotFoundPage(id int64, uid int64, pageId int64) error {
+	c := Campaign{Id: id, NotFoundPageId: pageId}
+	var count int
+	err := db.Model(&Campaign{}).Where("id=? and user_id=?", id, uid).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	err = c.validateNotFoundPage(uid)
+	if err != nil {
+		return err
+	}
+	return db.Table("campaigns").Where("id=?", id).UpdateColumn("not_found_page_id", pageId).Error
+}
+
+// GetNotFoundPage returns the page to serve in place of a 404 response to a
+// request for the given recipient id and host. Requests with a recipient id
+// use the page of its campaign, which covers completed campaigns and blocked
+// requests. Requests without a valid recipient id use the page of the latest
+// campaign whose URL is on the host, so that the host stays plausible once
+// campaigns have ended. gorm.ErrRecordNotFound is returned if no campaign
+// has a page.
+func GetNotFoundPage(rid string, host string) (Page, error) {
+	c := Campaign{}
+	if rid != "" {
+		rs := Result{}
+		err := db.Select("campaign_id").Where("r_id=?", rid).First(&rs).Error
+		if err == nil {
+			err = db.Select("id, user_id, not_found_page_id").Where("id=?", rs.CampaignId).First(&c).Error
+		}
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return Page{}, err
+		}
+	}
+	if c.NotFoundPageId == 0 && host != "" {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		cs := []Campaign{}
+		err := db.Select("id, user_id, url, not_found_page_id").Where("not_found_page_id > 0").
+			Order("id desc").Find(&cs).Error
+		if err != nil {
+			return Page{}, err
+		}
+		for _, candidate := range cs {
+			u, err := url.Parse(candidate.URL)
+			if err == nil && strings.EqualFold(u.Hostname(), host) {
+				c = candidate
+				break
+			}
+		}
+	}
+	if c.NotFoundPageId == 0 {
+		return Page{}, gorm.ErrRecordNotFound
+	}
+	return GetPage(c.NotFoundPageId, c.UserId)
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestNotFoundPage(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	page := Page{Name: "Parked", HTML: "<html>Parked domain</html>", UserId: c.UserId}
+	ch.Assert(PostPage(&page), check.Equals, nil)
+
+	c.URL = "https://login.example.com/path"
+	c.NotFoundPageId = 1000
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrPageNotFound)
+	c.NotFoundPageId = page.Id
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	rid := c.Results[0].RId
+
+	got, err := GetNotFoundPage(rid, "")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.HTML, check.Equals, page.HTML)
+	got, err = GetNotFoundPage("unknown", "LOGIN.example.com:443")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Id, check.Equals, page.Id)
+	_, err = GetNotFoundPage("unknown", "other.example.com")
+	ch.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+
+	// The page can be removed once the campaign is launched
+	ch.Assert(SetCampaignNotFoundPage(c.Id, c.UserId, 1000), check.Equals, ErrPageNotFound)
+	ch.Assert(SetCampaignNotFoundPage(c.Id, 2, page.Id), check.Equals, gorm.ErrRecordNotFound)
+	ch.Assert(SetCampaignNotFoundPage(c.Id, c.UserId, 0), check.Equals, nil)
+	_, err = GetNotFoundPage(rid, "login.example.com")
+	ch.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,451 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gophish/gomail"
+	"github.com/gophish/gophish/dialer"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/webhook"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// The triggers of notification rules
+const (
+	// NotifyOnEvent notifies when a recipient has the rule's event, such as
+	// submitting data.
+	NotifyOnEvent = "event"
+	// NotifyOnErrorRate notifies when the percentage of a campaign's emails
+	// which couldn't be sent exceeds the rule's threshold.
+	NotifyOnErrorRate = "error_rate"
+	// NotifyOnComplete notifies when a campaign is completed.
+	NotifyOnComplete = "campaign_complete"
+)
+
+// The channels notifications can be sent to
+const (
+	NotificationEmail   = "email"
+	NotificationSlack   = "slack"
+	NotificationWebhook = "webhook"
+)
+
+// NotificationTimeout is how long Slack and webhook notifications can take
+// to send.
+const NotificationTimeout = 10 * time.Second
+
+// notificationBatchSize is the largest number of events evaluated at once.
+const notificationBatchSize = 500
+
+var notificationClient = restrictedClient(NotificationTimeout)
+
+// restrictedClient returns an HTTP client which connects using the
+// restricted dialer, so that the URLs users configure can't reach the hosts
+// it blocks. The dialer is created for each connection, since the allowed
+// hosts and proxy are configured after the client is.
+func restrictedClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d, err := dialer.ProxyDialer("")
+				if err != nil {
+					return nil, err
+				}
+				return d.DialContext(ctx, network, address)
+			},
+		},
+	}
+}
+
+// ErrNotificationTrigger is thrown when a notification rule has an unknown
+// trigger, or an event trigger without an event.
+var ErrNotificationTrigger = errors.New("The trigger must be event, error_rate, or campaign_complete, and event triggers need an event")
+
+// ErrNotificationThreshold is thrown when the error rate threshold of a
+// notification rule isn't a percentage.
+var ErrNotificationThreshold = errors.New("The error rate threshold must be a percentage")
+
+// ErrNotificationChannel is thrown when a notification rule has an unknown
+// channel.
+var ErrNotificationChannel = errors.New("The channel must be email, slack, or webhook")
+
+// ErrNotificationTarget is thrown when a notification rule's target doesn't
+// suit its channel.
+var ErrNotificationTarget = errors.New("Email notifications need an email address and a sending profile, and Slack and webhook notifications need an http or https URL")
+
+// NotificationRule notifies the user through a channel, such as Slack, when
+// its trigger happens in one of their campaigns. Rules with a campaign id
+// only apply to that campaign.
+//
+// Event rules can be limited to results with a tag, such as "vip", and notify
+// once for each recipient. Error rate and completion rules notify once for
+// each campaign.
+type NotificationRule struct {
+	Id           int64     `json:"id"`
+	UserId       int64     `json:"-"`
+	Name         string    `json:"name"`
+	CampaignId   int64     `json:"campaign_id"`
+	Trigger      string    `json:"trigger" gorm:"column:trigger_type"`
+	Event        string    `json:"event"`
+	Tag          string    `json:"tag"`
+	Threshold    float64   `json:"threshold"`
+	Channel      string    `json:"channel"`
+	Target       string    `json:"target"`
+	SMTPId       int64     `json:"smtp_id"`
+	Enabled      bool      `json:"enabled"`
+	CreatedDate  time.Time `json:"created_date"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// NotificationLog records a notification sent for a rule, which prevents the
+// same notification from being sent again.
+type NotificationLog struct {
+	Id         int64
+	RuleId     int64
+	CampaignId int64
+	Email      string
+	Message    string
+	Error      string
+	SentDate   time.Time
+}
+
+// Notification is the message sent when a notification rule is triggered.
+type Notification struct {
+	RuleId     int64     `json:"rule_id"`
+	Rule       string    `json:"rule"`
+	CampaignId int64     `json:"campaign_id"`
+	Campaign   string    `json:"campaign"`
+	Email      string    `json:"email,omitempty"`
+	Message    string    `json:"message"`
+	Time       time.Time `json:"time"`
+}
+
+// WebhookType returns the type of the payload sent to webhooks.
+func (Notification) WebhookType() string {
+	return "notification"
+}
+
+// Validate ensures the rule has a known trigger and channel, and a target
+// which suits its channel.
+func (nr *NotificationRule) Validate() error {
+	if nr.Name == "" {
+		return ErrNameNotSpecified
+	}
+	switch nr.Trigger {
+	case NotifyOnEvent:
+		if nr.Event == "" {
+			return ErrNotificationTrigger
+		}
+	case NotifyOnErrorRate:
+		if nr.Threshold < 0 || nr.Threshold > 100 {
+			return ErrNotificationThreshold
+		}
+	case NotifyOnComplete:
+	default:
+		return ErrNotificationTrigger
+	}
+	switch nr.Channel {
+	case NotificationEmail:
+		if _, err := mail.ParseAddress(nr.Target); err != nil || nr.SMTPId == 0 {
+			return ErrNotificationTarget
+		}
+		if _, err := GetSMTP(nr.SMTPId, nr.UserId); err != nil {
+			return ErrNotificationTarget
+		}
+	case NotificationSlack, NotificationWebhook:
+		if !strings.HasPrefix(nr.Target, "https://") && !strings.HasPrefix(nr.Target, "http://") {
+			return ErrNotificationTarget
+		}
+	default:
+		return ErrNotificationChannel
+	}
+	return nil
+}
+
+// GetNotificationRules returns the notification rules owned by the given
+// user.
+func GetNotificationRules(uid int64) ([]NotificationRule, error) {
+	nrs := []NotificationRule{}
+	err := db.Where("user_id=?", uid).Order("id asc").Find(&nrs).Error
+	return nrs, err
+}
+
+// GetNotificationRule returns the notification rule with the given id, if
+// it's owned by the given user.
+func GetNotificationRule(id int64, uid int64) (NotificationRule, error) {
+	nr := NotificationRule{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&nr).Error
+	return nr, err
+}
+
+// PostNotificationRule creates a new notification rule.
+func PostNotificationRule(nr *NotificationRule) error {
+	err := nr.Validate()
+	if err != nil {
+		return err
+	}
+	nr.Id = 0
+	nr.CreatedDate = time.Now().UTC()
+	nr.ModifiedDate = nr.CreatedDate
+	return db.Save(nr).Error
+}
+
+// PutNotificationRule edits an existing notification rule.
+func PutNotificationRule(nr *NotificationRule) error {
+	existing, err := GetNotificationRule(nr.Id, nr.UserId)
+	if err != nil {
+		return err
+	}
+	err = nr.Validate()
+	if err != nil {
+		return err
+	}
+	nr.CreatedDate = existing.CreatedDate
+	nr.ModifiedDate = time.Now().UTC()
+	return db.Save(nr).Error
+}
+
+// DeleteNotificationRule deletes the notification rule with the given id,
+// along with the record of its notifications.
+func DeleteNotificationRule(id int64, uid int64) error {
+	_, err := GetNotificationRule(id, uid)
+	if err != nil {
+		return err
+	}
+	err = db.Where("rule_id=?", id).Delete(&NotificationLog{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Where("id=? and user_id=?", id, uid).Delete(&NotificationRule{}).Error
+}
+
+// LastEventId returns the id of the newest event, so that notifications are
+// only evaluated for the events added after it.
+func LastEventId() (int64, error) {
+	e := Event{}
+	err := db.Select("id").Order("id desc").First(&e).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	return e.Id, err
+}
+
+// EvaluateNotifications evaluates the notification rules against the events
+// added after the given event id, returning the id of the last event
+// evaluated. Completed campaigns are also checked.
+func EvaluateNotifications(after int64) (int64, error) {
+	es := []Event{}
+	err := db.Where("id > ?", after).Order("id asc").Limit(notificationBatchSize).Find(&es).Error
+	if err != nil {
+		return after, err
+	}
+	for _, e := range es {
+		err = evaluateEventRules(e)
+		if err != nil {
+			log.Error(err)
+		}
+		after = e.Id
+	}
+	return after, notifyCompletedCampaigns()
+}
+
+// campaignRules returns the enabled notification rules with the trigger
+// which apply to the campaign.
+func campaignRules(c Campaign, trigger string) ([]NotificationRule, error) {
+	nrs := []NotificationRule{}
+	err := db.Where("user_id=? and enabled=? and trigger_type=? and campaign_id in (?)", c.UserId, true, trigger, []int64{0, c.Id}).
+		Find(&nrs).Error
+	return nrs, err
+}
+
+// evaluateEventRules sends the notifications triggered by the event, and
+// opens the tickets it causes.
+func evaluateEventRules(e Event) error {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id, user_id, name").Where("id=?", e.CampaignId).Find(&c).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = evaluateTicketConnectors(e, c)
+	if err != nil {
+		log.Error(err)
+	}
+	nrs, err := campaignRules(c, NotifyOnEvent)
+	if err != nil {
+		return err
+	}
+	for _, nr := range nrs {
+		if nr.Event != e.Message || e.Email == "" {
+			continue
+		}
+		if nr.Tag != "" {
+			r := Result{}
+			err = db.Select("tags").Where("campaign_id=? and email=?", c.Id, e.Email).First(&r).Error
+			if err != nil || !r.HasTag(nr.Tag) {
+				continue
+			}
+		}
+		nr.notify(c, e.Email, fmt.Sprintf("%s: %s in campaign %s", e.Message, e.Email, c.Name))
+	}
+	if e.Message != EventSendingError && e.Message != EventSent {
+		return nil
+	}
+	nrs, err = campaignRules(c, NotifyOnErrorRate)
+	if err != nil || len(nrs) == 0 {
+		return err
+	}
+	rate, err := campaignErrorRate(c.Id)
+	if err != nil {
+		return err
+	}
+	for _, nr := range nrs {
+		if rate > nr.Threshold {
+			nr.notify(c, "", fmt.Sprintf("%.1f%% of the emails in campaign %s couldn't be sent", rate, c.Name))
+		}
+	}
+	return nil
+}
+
+// campaignErrorRate returns the percentage of the campaign's emails which
+// have been sent, or failed to send, that couldn't be sent.
+func campaignErrorRate(cid int64) (float64, error) {
+	var attempted, failed int
+	err := db.Table("results").Where("campaign_id=? and status not in (?)", cid, []string{StatusScheduled, StatusSending, StatusRetry, StatusQueued}).
+		Count(&attempted).Error
+	if err != nil || attempted == 0 {
+		return 0, err
+	}
+	err = db.Table("results").Where("campaign_id=? and status=?", cid, Error).Count(&failed).Error
+	if err != nil {
+		return 0, err
+	}
+	return float64(failed) * 100 / float64(attempted), nil
+}
+
+// notifyCompletedCampaigns sends the notifications for the campaigns
+// completed since their rules were created.
+func notifyCompletedCampaigns() error {
+	nrs := []NotificationRule{}
+	err := db.Where("enabled=? and trigger_type=?", true, NotifyOnComplete).Find(&nrs).Error
+	if err != nil {
+		return err
+	}
+	for _, nr := range nrs {
+		query := db.Table("campaigns").Select("id, user_id, name").
+			Where("user_id=? and status=? and completed_date >= ?", nr.UserId, CampaignComplete, nr.CreatedDate).
+			Where("id not in ?", db.Table("notification_logs").Select("campaign_id").Where("rule_id=?", nr.Id).SubQuery())
+		if nr.CampaignId != 0 {
+			query = query.Where("id=?", nr.CampaignId)
+		}
+		cs := []Campaign{}
+		err = query.Find(&cs).Error
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			s, err := getCampaignStats(c.Id)
+			if err != nil {
+				return err
+			}
+			nr.notify(c, "", fmt.Sprintf("Campaign %s completed: %d of %d recipients clicked the link, and %d submitted data",
+				c.Name, s.ClickedLink+s.SubmittedData, s.Total, s.SubmittedData))
+		}
+	}
+	return nil
+}
+
+// notify sends the notification, unless the rule has already notified for
+// the campaign and recipient.
+func (nr NotificationRule) notify(c Campaign, email string, message string) {
+	var count int
+	db.Model(&NotificationLog{}).Where("rule_id=? and campaign_id=? and email=?", nr.Id, c.Id, email).Count(&count)
+	if count > 0 {
+		return
+	}
+	n := Notification{
+		RuleId:     nr.Id,
+		Rule:       nr.Name,
+		CampaignId: c.Id,
+		Campaign:   c.Name,
+		Email:      email,
+		Message:    message,
+		Time:       time.Now().UTC(),
+	}
+	nl := NotificationLog{RuleId: nr.Id, CampaignId: c.Id, Email: email, Message: message, SentDate: n.Time}
+	if err := nr.send(n); err != nil {
+		log.WithFields(logrus.Fields{
+			"rule_id":     nr.Id,
+			"campaign_id": c.Id,
+		}).Errorf("error sending notification: %v", err)
+		nl.Error = err.Error()
+	}
+	if err := db.Save(&nl).Error; err != nil {
+		log.Error(err)
+	}
+}
+
+// send delivers the notification through the rule's channel.
+func (nr NotificationRule) send(n Notification) error {
+	switch nr.Channel {
+	case NotificationEmail:
+		return nr.sendEmail(n)
+	case NotificationSlack:
+		body, err := json.Marshal(map[string]string{"text": n.Message})
+		if err != nil {
+			return err
+		}
+		resp, err := notificationClient.Post(nr.Target, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("unexpected status %d from Slack", resp.StatusCode)
+		}
+		return nil
+	case NotificationWebhook:
+		return webhook.Send(webhook.EndPoint{URL: nr.Target}, n)
+	}
+	return ErrNotificationChannel
+}
+
+// sendEmail emails the notification using the rule's sending profile.
+func (nr NotificationRule) sendEmail(n Notification) error {
+	s, err := GetSMTP(nr.SMTPId, nr.UserId)
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(s.FromAddress)
+	if err != nil {
+		return err
+	}
+	d, err := s.GetDialer()
+	if err != nil {
+		return err
+	}
+	sender, err := d.Dial()
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	msg := gomail.NewMessage()
+	msg.SetAddressHeader("From", asciiAddress(from.Address), from.Name)
+	msg.SetHeader("To", asciiAddress(nr.Target))
+	msg.SetHeader("Subject", fmt.Sprintf("Gophish notification: %s", nr.Name))
+	msg.SetDateHeader("Date", n.Time)
+	msg.SetBody("text/plain", n.Message)
+	return gomail.Send(sender, msg)
+}
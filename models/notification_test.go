@@ -0,0 +1,148 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"gopkg.in/check.v1"
+)
+
+// slackRecorder is a Slack incoming webhook which records the messages it
+// receives.
+type slackRecorder struct {
+	sync.Mutex
+	messages []string
+}
+
+func (sr *slackRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := map[string]string{}
+	json.NewDecoder(r.Body).Decode(&body)
+	sr.Lock()
+	sr.messages = append(sr.messages, body["text"])
+	sr.Unlock()
+}
+
+func (s *ModelsSuite) TestNotificationRuleValidation(ch *check.C) {
+	nr := NotificationRule{UserId: 1, Name: "Test", Trigger: "unknown", Channel: NotificationSlack, Target: "https://example.com"}
+	ch.Assert(PostNotificationRule(&nr), check.Equals, ErrNotificationTrigger)
+	nr.Trigger = NotifyOnEvent
+	ch.Assert(PostNotificationRule(&nr), check.Equals, ErrNotificationTrigger)
+	nr.Trigger = NotifyOnErrorRate
+	nr.Threshold = 101
+	ch.Assert(PostNotificationRule(&nr), check.Equals, ErrNotificationThreshold)
+	nr.Threshold = 5
+	nr.Channel = "pager"
+	ch.Assert(PostNotificationRule(&nr), check.Equals, ErrNotificationChannel)
+	nr.Channel = NotificationWebhook
+	nr.Target = "example.com"
+	ch.Assert(PostNotificationRule(&nr), check.Equals, ErrNotificationTarget)
+	nr.Channel = NotificationEmail
+	nr.Target = "admin@example.com"
+	ch.Assert(PostNotificationRule(&nr), check.Equals, ErrNotificationTarget)
+	nr.Channel = NotificationSlack
+	nr.Target = "https://example.com"
+	ch.Assert(PostNotificationRule(&nr), check.Equals, nil)
+
+	// Rules are only visible to their owner
+	_, err := GetNotificationRule(nr.Id, 2)
+	ch.Assert(err, check.NotNil)
+	nrs, err := GetNotificationRules(1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(nrs), check.Equals, 1)
+	ch.Assert(nrs[0].Trigger, check.Equals, NotifyOnErrorRate)
+}
+
+func (s *ModelsSuite) TestEventNotifications(ch *check.C) {
+	sr := &slackRecorder{}
+	ts := httptest.NewServer(sr)
+	defer ts.Close()
+
+	after, err := LastEventId()
+	ch.Assert(err, check.Equals, nil)
+	c := s.createCampaign(ch)
+	nr := NotificationRule{UserId: c.UserId, Name: "VIP submitted", Trigger: NotifyOnEvent, Event: EventDataSubmit,
+		Tag: "vip", Channel: NotificationSlack, Target: ts.URL, Enabled: true}
+	ch.Assert(PostNotificationRule(&nr), check.Equals, nil)
+
+	_, err = AnnotateResult(c.Id, c.Results[0].RId, c.UserId, ResultAnnotation{Tags: []string{"vip"}})
+	ch.Assert(err, check.Equals, nil)
+	for _, r := range c.Results[:2] {
+		r, err := GetResult(r.RId)
+		ch.Assert(err, check.Equals, nil)
+		ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+		ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+	}
+	after, err = EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+
+	// Only the tagged recipient is notified, and only once
+	ch.Assert(sr.messages, check.DeepEquals, []string{
+		EventDataSubmit + ": " + c.Results[0].Email + " in campaign " + c.Name,
+	})
+	var count int
+	db.Model(&NotificationLog{}).Where("rule_id=?", nr.Id).Count(&count)
+	ch.Assert(count, check.Equals, 1)
+
+	// Events which have been evaluated aren't evaluated again
+	latest, err := EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(latest, check.Equals, after)
+
+	ch.Assert(DeleteNotificationRule(nr.Id, c.UserId), check.Equals, nil)
+	db.Model(&NotificationLog{}).Where("rule_id=?", nr.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestErrorRateAndCompletionNotifications(ch *check.C) {
+	sr := &slackRecorder{}
+	ts := httptest.NewServer(sr)
+	defer ts.Close()
+
+	after, err := LastEventId()
+	ch.Assert(err, check.Equals, nil)
+	c := s.createCampaign(ch)
+	rate := NotificationRule{UserId: c.UserId, Name: "Errors", CampaignId: c.Id, Trigger: NotifyOnErrorRate, Threshold: 30,
+		Channel: NotificationSlack, Target: ts.URL, Enabled: true}
+	ch.Assert(PostNotificationRule(&rate), check.Equals, nil)
+	complete := NotificationRule{UserId: c.UserId, Name: "Completed", Trigger: NotifyOnComplete,
+		Channel: NotificationSlack, Target: ts.URL, Enabled: true}
+	ch.Assert(PostNotificationRule(&complete), check.Equals, nil)
+	disabled := NotificationRule{UserId: c.UserId, Name: "Disabled", Trigger: NotifyOnComplete,
+		Channel: NotificationSlack, Target: ts.URL}
+	ch.Assert(PostNotificationRule(&disabled), check.Equals, nil)
+
+	// 1 of 4 emails failing is below the threshold
+	ch.Assert(c.Results[0].HandleEmailError(errors.New("rejected")), check.Equals, nil)
+	for _, r := range c.Results[1:] {
+		ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	}
+	after, err = EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(sr.messages), check.Equals, 0)
+
+	// 2 of 4 is above it
+	ch.Assert(c.Results[1].HandleEmailError(errors.New("rejected")), check.Equals, nil)
+	ch.Assert(CompleteCampaign(c.Id, c.UserId), check.Equals, nil)
+	after, err = EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(sr.messages, check.DeepEquals, []string{
+		"50.0% of the emails in campaign " + c.Name + " couldn't be sent",
+		"Campaign " + c.Name + " completed: 0 of 4 recipients clicked the link, and 0 submitted data",
+	})
+
+	// Completed campaigns are only notified once
+	_, err = EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(sr.messages), check.Equals, 2)
+}
+
+func (s *ModelsSuite) TestSlackNotificationRestricted(ch *check.C) {
+	nr := NotificationRule{Channel: NotificationSlack, Target: "http://169.254.169.254/latest/meta-data/"}
+	err := nr.send(Notification{Message: "test"})
+	ch.Assert(err, check.NotNil)
+	ch.Assert(strings.Contains(err.Error(), "upstream connection denied"), check.Equals, true)
+}
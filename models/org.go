@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// UnassignedDepartment is the department that results are rolled up into
+// when their recipient isn't in the org chart, or has no department.
+const UnassignedDepartment = "Unassigned"
+
+// ErrOrgMemberEmail is returned when importing an org chart member without an
+// email address.
+var ErrOrgMemberEmail = errors.New("Org chart members need an email address")
+
+// OrgMember is a person in a user's org chart, identified by their email
+// address, along with their department and their manager's email address.
+type OrgMember struct {
+	Id           int64     `json:"-"`
+	UserId       int64     `json:"-"`
+	Email        string    `json:"email"`
+	Department   string    `json:"department"`
+	ManagerEmail string    `json:"manager_email"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// OrgRollup is the results of the recipients in a department, or in a
+// manager's reporting chain. Rates are the fraction of the emails sent.
+type OrgRollup struct {
+	Name          string  `json:"name"`
+	Total         int64   `json:"total"`
+	EmailsSent    int64   `json:"sent"`
+	ClickedLink   int64   `json:"clicked"`
+	SubmittedData int64   `json:"submitted_data"`
+	EmailReported int64   `json:"email_reported"`
+	ClickRate     float64 `json:"click_rate"`
+	SubmitRate    float64 `json:"submit_rate"`
+	ReportRate    float64 `json:"report_rate"`
+}
+
+// add counts the result in the rollup.
+func (o *OrgRollup) add(r Result) {
+	o.Total++
+	switch r.Status {
+	case EventDataSubmit:
+		o.SubmittedData++
+		fallthrough
+	case EventClicked:
+		o.ClickedLink++
+		fallthrough
+	case EventOpened, EventSent:
+		o.EmailsSent++
+	}
+	if r.Reported {
+		o.EmailReported++
+	}
+}
+
+// setRates calculates the rates from the counts.
+func (o *OrgRollup) setRates() {
+	if o.EmailsSent == 0 {
+		return
+	}
+	sent := float64(o.EmailsSent)
+	o.ClickRate = float64(o.ClickedLink) / sent
+	o.SubmitRate = float64(o.SubmittedData) / sent
+	o.ReportRate = float64(o.EmailReported) / sent
+}
+
+// GetOrgChart returns the members of the user's org chart.
+func GetOrgChart(uid int64) ([]OrgMember, error) {
+	ms := []OrgMember{}
+	err := db.Where("user_id=?", uid).Order("email asc").Find(&ms).Error
+	return ms, err
+}
+
+// ImportOrgChart replaces the user's org chart with the given members.
+// Members listed more than once keep their last entry.
+func ImportOrgChart(uid int64, ms []OrgMember) error {
+	members := map[string]OrgMember{}
+	emails := []string{}
+	for _, m := range ms {
+		m.Email = strings.ToLower(strings.TrimSpace(m.Email))
+		m.ManagerEmail = strings.ToLower(strings.TrimSpace(m.ManagerEmail))
+		m.Department = strings.TrimSpace(m.Department)
+		if m.Email == "" {
+			return ErrOrgMemberEmail
+		}
+		if _, ok := members[m.Email]; !ok {
+			emails = append(emails, m.Email)
+		}
+		members[m.Email] = m
+	}
+	tx := db.Begin()
+	err := tx.Where("user_id=?", uid).Delete(&OrgMember{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	now := time.Now().UTC()
+	for _, email := range emails {
+		m := members[email]
+		m.Id = 0
+		m.UserId = uid
+		m.ModifiedDate = now
+		err = tx.Save(&m).Error
+		if err != nil {
+			log.Error(err)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// getOrgResults returns the results of the user's campaigns, or of the given
+// campaigns if there are any, along with the user's org chart by email.
+func getOrgResults(uid int64, cids []int64) ([]Result, map[string]OrgMember, error) {
+	rs := []Result{}
+	query := db.Where("user_id=?", uid)
+	if len(cids) > 0 {
+		query = query.Where("campaign_id IN (?)", cids)
+	}
+	err := query.Find(&rs).Error
+	if err != nil {
+		return rs, nil, err
+	}
+	ms, err := GetOrgChart(uid)
+	if err != nil {
+		return rs, nil, err
+	}
+	members := make(map[string]OrgMember, len(ms))
+	for _, m := range ms {
+		members[m.Email] = m
+	}
+	return rs, members, nil
+}
+
+// sortedRollups returns the rollups with their rates set, sorted by name.
+func sortedRollups(rollups map[string]*OrgRollup) []OrgRollup {
+	sorted := make([]OrgRollup, 0, len(rollups))
+	for _, o := range rollups {
+		o.setRates()
+		sorted = append(sorted, *o)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// GetDepartmentRollup returns the results of the user's campaigns by the
+// department of each recipient. If campaign ids are given, only the results
+// of those campaigns are included.
+func GetDepartmentRollup(uid int64, cids []int64) ([]OrgRollup, error) {
+	rs, members, err := getOrgResults(uid, cids)
+	if err != nil {
+		return nil, err
+	}
+	rollups := map[string]*OrgRollup{}
+	for _, r := range rs {
+		department := members[strings.ToLower(r.Email)].Department
+		if department == "" {
+			department = UnassignedDepartment
+		}
+		if _, ok := rollups[department]; !ok {
+			rollups[department] = &OrgRollup{Name: department}
+		}
+		rollups[department].add(r)
+	}
+	return sortedRollups(rollups), nil
+}
+
+// GetManagerRollup returns the results of the user's campaigns by manager,
+// named by their email address. Each manager's rollup includes everyone who
+// reports to them, directly or through other managers. If campaign ids are
+// given, only the results of those campaigns are included.
+func GetManagerRollup(uid int64, cids []int64) ([]OrgRollup, error) {
+	rs, members, err := getOrgResults(uid, cids)
+	if err != nil {
+		return nil, err
+	}
+	rollups := map[string]*OrgRollup{}
+	for _, r := range rs {
+		// Cycles in the org chart are ignored by visiting each manager once
+		seen := map[string]bool{}
+		manager := members[strings.ToLower(r.Email)].ManagerEmail
+		for manager != "" && !seen[manager] {
+			seen[manager] = true
+			if _, ok := rollups[manager]; !ok {
+				rollups[manager] = &OrgRollup{Name: manager}
+			}
+			rollups[manager].add(r)
+			manager = members[manager].ManagerEmail
+		}
+	}
+	return sortedRollups(rollups), nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+// createOrgCampaign creates a campaign whose recipients have the given
+// statuses, along with an org chart:
+//
+//	ceo@example.com
+//	└── test1@example.com (Engineering)
+//	    ├── test2@example.com (Engineering)
+//	    └── test3@example.com (Sales)
+//
+// test4@example.com isn't in the org chart.
+func (s *ModelsSuite) createOrgCampaign(ch *check.C) Campaign {
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	statuses := map[string]string{
+		"test1@example.com": EventDataSubmit,
+		"test2@example.com": EventClicked,
+		"test3@example.com": EventSent,
+		"test4@example.com": EventOpened,
+	}
+	for email, status := range statuses {
+		err := db.Model(&Result{}).Where("campaign_id=? AND email=?", c.Id, email).
+			Updates(map[string]interface{}{"status": status, "reported": email == "test3@example.com"}).Error
+		ch.Assert(err, check.Equals, nil)
+	}
+	err := ImportOrgChart(c.UserId, []OrgMember{
+		{Email: "CEO@example.com"},
+		{Email: "test1@example.com", Department: "Engineering", ManagerEmail: "ceo@example.com"},
+		{Email: "test2@example.com", Department: "Engineering", ManagerEmail: "TEST1@example.com"},
+		{Email: "test3@example.com", Department: "Sales", ManagerEmail: "test1@example.com"},
+	})
+	ch.Assert(err, check.Equals, nil)
+	return c
+}
+
+func (s *ModelsSuite) TestImportOrgChart(ch *check.C) {
+	err := ImportOrgChart(1, []OrgMember{{Email: "foo@example.com"}, {Department: "Sales"}})
+	ch.Assert(err, check.Equals, ErrOrgMemberEmail)
+
+	err = ImportOrgChart(1, []OrgMember{
+		{Email: "foo@example.com", Department: "Sales"},
+		{Email: "Foo@example.com", Department: "Engineering"},
+	})
+	ch.Assert(err, check.Equals, nil)
+	ms, err := GetOrgChart(1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, 1)
+	ch.Assert(ms[0].Email, check.Equals, "foo@example.com")
+	ch.Assert(ms[0].Department, check.Equals, "Engineering")
+
+	// Importing replaces the org chart
+	err = ImportOrgChart(1, []OrgMember{{Email: "bar@example.com"}})
+	ch.Assert(err, check.Equals, nil)
+	ms, err = GetOrgChart(1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, 1)
+	ch.Assert(ms[0].Email, check.Equals, "bar@example.com")
+}
+
+func (s *ModelsSuite) TestDepartmentRollup(ch *check.C) {
+	c := s.createOrgCampaign(ch)
+	rollups, err := GetDepartmentRollup(c.UserId, nil)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rollups, check.DeepEquals, []OrgRollup{
+		{Name: "Engineering", Total: 2, EmailsSent: 2, ClickedLink: 2, SubmittedData: 1, ClickRate: 1, SubmitRate: 0.5},
+		{Name: "Sales", Total: 1, EmailsSent: 1, EmailReported: 1, ReportRate: 1},
+		{Name: UnassignedDepartment, Total: 1, EmailsSent: 1},
+	})
+
+	// Only the results of the given campaigns are included
+	rollups, err = GetDepartmentRollup(c.UserId, []int64{c.Id + 1})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rollups), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestManagerRollup(ch *check.C) {
+	c := s.createOrgCampaign(ch)
+	rollups, err := GetManagerRollup(c.UserId, []int64{c.Id})
+	ch.Assert(err, check.Equals, nil)
+	// The CEO's rollup includes their indirect reports
+	ch.Assert(rollups, check.DeepEquals, []OrgRollup{
+		{Name: "ceo@example.com", Total: 3, EmailsSent: 3, ClickedLink: 2, SubmittedData: 1, EmailReported: 1,
+			ClickRate: 2.0 / 3, SubmitRate: 1.0 / 3, ReportRate: 1.0 / 3},
+		{Name: "test1@example.com", Total: 2, EmailsSent: 2, ClickedLink: 1, EmailReported: 1, ClickRate: 0.5, ReportRate: 0.5},
+	})
+
+	// Cycles in the org chart don't count results more than once
+	err = ImportOrgChart(c.UserId, []OrgMember{
+		{Email: "test1@example.com", ManagerEmail: "test2@example.com"},
+		{Email: "test2@example.com", ManagerEmail: "test1@example.com"},
+	})
+	ch.Assert(err, check.Equals, nil)
+	rollups, err = GetManagerRollup(c.UserId, nil)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rollups), check.Equals, 2)
+	ch.Assert(rollups[0].Total, check.Equals, int64(2))
+	ch.Assert(rollups[1].Total, check.Equals, int64(2))
+}
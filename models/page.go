@@ -11,14 +11,36 @@ import (
 
 // Page contains the fields used for a Page model
 type Page struct {
-	Id                 int64     `json:"id" gorm:"column:id; primary_key:yes"`
-	UserId             int64     `json:"-" gorm:"column:user_id"`
-	Name               string    `json:"name"`
-	HTML               string    `json:"html" gorm:"column:html"`
-	CaptureCredentials bool      `json:"capture_credentials" gorm:"column:capture_credentials"`
-	CapturePasswords   bool      `json:"capture_passwords" gorm:"column:capture_passwords"`
-	RedirectURL        string    `json:"redirect_url" gorm:"column:redirect_url"`
-	ModifiedDate       time.Time `json:"modified_date"`
+	Id                 int64            `json:"id" gorm:"column:id; primary_key:yes"`
+	UserId             int64            `json:"-" gorm:"column:user_id"`
+	Name               string           `json:"name"`
+	ExternalId         string           `json:"external_id,omitempty"`
+	HTML               string           `json:"html" gorm:"column:html"`
+	CaptureCredentials bool             `json:"capture_credentials" gorm:"column:capture_credentials"`
+	CapturePasswords   bool             `json:"capture_passwords" gorm:"column:capture_passwords"`
+	RedirectURL        string           `json:"redirect_url" gorm:"column:redirect_url"`
+	CaptureScript      bool             `json:"capture_script" gorm:"column:capture_script"`
+	ScorePasswords     bool             `json:"score_passwords" gorm:"column:score_passwords"`
+	DecoyHTML          string           `json:"decoy_html" gorm:"column:decoy_html"`
+	ClickChallenge     bool             `json:"click_challenge" gorm:"column:click_challenge"`
+	AccessRules        []PageAccessRule `json:"access_rules"`
+	ModifiedDate       time.Time        `json:"modified_date"`
+	// Translations are localized versions of the page, shown to recipients
+	// based on their language.
+	Translations []PageTranslation `json:"translations" gorm:"-"`
+	// FormSteps is the number of times the page's form is submitted before
+	// the recipient is redirected, for pages which ask for the username and
+	// password separately. Each step shows the page again with the next
+	// {{.FormStep}}.
+	FormSteps int `json:"form_steps" gorm:"column:form_steps"`
+	// RejectFirstSubmit shows the page again the first time its last step
+	// is submitted, with the SubmitError as {{.FormError}}, as if the
+	// password was wrong.
+	RejectFirstSubmit bool   `json:"reject_first_submit" gorm:"column:reject_first_submit"`
+	SubmitError       string `json:"submit_error" gorm:"column:submit_error"`
+	// SubmitDelay shows a loading spinner for this many seconds when the
+	// page's form is submitted.
+	SubmitDelay int `json:"submit_delay" gorm:"column:submit_delay"`
 }
 
 // ErrPageNameNotSpecified is thrown if the name of the landing page is blank.
@@ -74,6 +96,10 @@ func (p *Page) Validate() error {
 	if p.Name == "" {
 		return ErrPageNameNotSpecified
 	}
+	// Passwords need to be submitted to be scored, but are never stored
+	if p.ScorePasswords {
+		p.CapturePasswords = true
+	}
 	// If the user specifies to capture passwords,
 	// we automatically capture credentials
 	if p.CapturePasswords && !p.CaptureCredentials {
@@ -85,24 +111,78 @@ func (p *Page) Validate() error {
 	if err := ValidateTemplate(p.RedirectURL); err != nil {
 		return err
 	}
+	if err := ValidateTemplate(p.DecoyHTML); err != nil {
+		return err
+	}
+	if err := p.validateForm(); err != nil {
+		return err
+	}
+	for i := range p.AccessRules {
+		if err := p.AccessRules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.validateTranslations(); err != nil {
+		return err
+	}
 	return p.parseHTML()
 }
 
 // GetPages returns the pages owned by the given user.
 func GetPages(uid int64) ([]Page, error) {
+	ps, _, err := ListPages(uid, ListOptions{})
+	return ps, err
+}
+
+// pageListFields are the fields landing pages can be sorted and filtered by
+var pageListFields = listFields{
+	"id":            "id",
+	"name":          "name",
+	"modified_date": "modified_date",
+}
+
+// ListPages returns the pages owned by the given user, filtered, sorted, and
+// paginated using the given options.
+func ListPages(uid int64, opts ListOptions) ([]Page, PageInfo, error) {
 	ps := []Page{}
-	err := db.Where("user_id=?", uid).Find(&ps).Error
+	query, total, err := opts.apply(db.Table("pages").Where("user_id=?", uid), pageListFields)
+	if err != nil {
+		return ps, PageInfo{}, err
+	}
+	err = query.Find(&ps).Error
 	if err != nil {
 		log.Error(err)
-		return ps, err
+		return ps, PageInfo{}, err
 	}
-	return ps, err
+	for i := range ps {
+		err = ps[i].getAccessRules()
+		if err != nil {
+			log.Error(err)
+			return ps, PageInfo{}, err
+		}
+		err = ps[i].getTranslations()
+		if err != nil {
+			log.Error(err)
+			return ps, PageInfo{}, err
+		}
+	}
+	return ps, opts.pageInfo(&ps, total, pageListFields), err
 }
 
 // GetPage returns the page, if it exists, specified by the given id and user_id.
 func GetPage(id int64, uid int64) (Page, error) {
 	p := Page{}
 	err := db.Where("user_id=? and id=?", uid, id).Find(&p).Error
+	if err != nil {
+		log.Error(err)
+		return p, err
+	}
+	err = p.getAccessRules()
+	if err != nil {
+		log.Error(err)
+		return p, err
+	}
+	err = p.getTranslations()
 	if err != nil {
 		log.Error(err)
 	}
@@ -113,6 +193,16 @@ func GetPage(id int64, uid int64) (Page, error) {
 func GetPageByName(n string, uid int64) (Page, error) {
 	p := Page{}
 	err := db.Where("user_id=? and name=?", uid, n).Find(&p).Error
+	if err != nil {
+		log.Error(err)
+		return p, err
+	}
+	err = p.getAccessRules()
+	if err != nil {
+		log.Error(err)
+		return p, err
+	}
+	err = p.getTranslations()
 	if err != nil {
 		log.Error(err)
 	}
@@ -126,8 +216,22 @@ func PostPage(p *Page) error {
 		log.Error(err)
 		return err
 	}
+	err = validateExternalId("pages", p.ExternalId, p.UserId, p.Id)
+	if err != nil {
+		return err
+	}
 	// Insert into the DB
 	err = db.Save(p).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = p.saveAccessRules()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = p.saveTranslations()
 	if err != nil {
 		log.Error(err)
 	}
@@ -141,7 +245,21 @@ func PutPage(p *Page) error {
 	if err != nil {
 		return err
 	}
+	err = validateExternalId("pages", p.ExternalId, p.UserId, p.Id)
+	if err != nil {
+		return err
+	}
 	err = db.Where("id=?", p.Id).Save(p).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = p.saveAccessRules()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = p.saveTranslations()
 	if err != nil {
 		log.Error(err)
 	}
@@ -151,7 +269,22 @@ func PutPage(p *Page) error {
 // DeletePage deletes an existing page in the database.
 // An error is returned if a page with the given user id and page id is not found.
 func DeletePage(id int64, uid int64) error {
-	err := db.Where("user_id=?", uid).Delete(Page{Id: id}).Error
+	err := db.Where("page_id=?", id).Delete(&PageAccessRule{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("page_id=?", id).Delete(&PageTranslation{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = clearGroupDefaults("default_page_id", id, uid)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = db.Where("user_id=?", uid).Delete(Page{Id: id}).Error
 	if err != nil {
 		log.Error(err)
 	}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Fields of a request which landing page access rules match against
+const (
+	AccessFieldUserAgent = "user_agent"
+	AccessFieldReferer   = "referer"
+)
+
+// Actions taken when a landing page access rule matches a request
+const (
+	AccessAllow = "allow"
+	AccessBlock = "block"
+)
+
+// ErrInvalidAccessField is returned when an access rule matches against an
+// unknown field.
+var ErrInvalidAccessField = errors.New("Access rules must match the user_agent or referer field")
+
+// ErrInvalidAccessAction is returned when an access rule has an unknown
+// action.
+var ErrInvalidAccessAction = errors.New("The action of an access rule must be allow or block")
+
+// ErrInvalidAccessPattern is returned when the pattern of an access rule
+// isn't a valid regular expression.
+var ErrInvalidAccessPattern = errors.New("The pattern of an access rule must be a valid regular expression")
+
+// PageAccessRule decides whether a request is served the landing page, or
+// the decoy page instead. The pattern is a case-insensitive regular
+// expression matched against the request's user agent or referer.
+type PageAccessRule struct {
+	Id      int64  `json:"-"`
+	PageId  int64  `json:"-"`
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+// String describes the rule, for storing on the events of the requests it
+// matches.
+func (a PageAccessRule) String() string {
+	return fmt.Sprintf("%s if %s matches %s", a.Action, a.Field, a.Pattern)
+}
+
+// Validate ensures the access rule can be evaluated.
+func (a *PageAccessRule) Validate() error {
+	switch {
+	case a.Field != AccessFieldUserAgent && a.Field != AccessFieldReferer:
+		return ErrInvalidAccessField
+	case a.Action != AccessAllow && a.Action != AccessBlock:
+		return ErrInvalidAccessAction
+	}
+	if _, err := regexp.Compile("(?i)" + a.Pattern); err != nil {
+		return ErrInvalidAccessPattern
+	}
+	return nil
+}
+
+// matches returns whether the rule matches the request's user agent or
+// referer.
+func (a PageAccessRule) matches(userAgent, referer string) bool {
+	value := userAgent
+	if a.Field == AccessFieldReferer {
+		value = referer
+	}
+	re, err := regexp.Compile("(?i)" + a.Pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(value)
+}
+
+// CheckAccess returns whether a request with the given user agent and
+// referer is served the landing page, along with the rule which decided it.
+// The first matching rule is used. When none match, the request is blocked
+// if the page has any allow rules, since those list the only requests which
+// should see the page, and allowed otherwise.
+func (p *Page) CheckAccess(userAgent, referer string) (bool, *PageAccessRule) {
+	allowList := false
+	for i, rule := range p.AccessRules {
+		if rule.matches(userAgent, referer) {
+			return rule.Action == AccessAllow, &p.AccessRules[i]
+		}
+		if rule.Action == AccessAllow {
+			allowList = true
+		}
+	}
+	return !allowList, nil
+}
+
+// getAccessRules loads the access rules of the page, in the order they were
+// given.
+func (p *Page) getAccessRules() error {
+	err := db.Where("page_id=?", p.Id).Order("id asc").Find(&p.AccessRules).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if len(p.AccessRules) == 0 {
+		p.AccessRules = make([]PageAccessRule, 0)
+	}
+	return nil
+}
+
+// saveAccessRules replaces the stored access rules of the page.
+func (p *Page) saveAccessRules() error {
+	err := db.Where("page_id=?", p.Id).Delete(&PageAccessRule{}).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	for i := range p.AccessRules {
+		p.AccessRules[i].Id = 0
+		p.AccessRules[i].PageId = p.Id
+		err = db.Save(&p.AccessRules[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
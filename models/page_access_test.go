@@ -0,0 +1,67 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPageAccessRules(c *check.C) {
+	p := Page{
+		Name:      "Access Page",
+		HTML:      "<html>Test</html>",
+		DecoyHTML: "<html>Nothing to see here</html>",
+		UserId:    1,
+		AccessRules: []PageAccessRule{
+			{Field: AccessFieldUserAgent, Pattern: "curl|python-requests", Action: AccessBlock},
+			{Field: AccessFieldReferer, Pattern: `^https://mail\.example\.com/`, Action: AccessAllow},
+		},
+	}
+	c.Assert(PostPage(&p), check.Equals, nil)
+
+	// Rules are loaded in the order they were given
+	got, err := GetPage(p.Id, p.UserId)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(got.AccessRules), check.Equals, 2)
+	c.Assert(got.AccessRules[0].Pattern, check.Equals, "curl|python-requests")
+	c.Assert(got.AccessRules[1].Action, check.Equals, AccessAllow)
+
+	allowed, rule := got.CheckAccess("Python-Requests/2.25", "https://mail.example.com/")
+	c.Assert(allowed, check.Equals, false)
+	c.Assert(rule.String(), check.Equals, "block if user_agent matches curl|python-requests")
+
+	allowed, rule = got.CheckAccess("Mozilla/5.0", "https://mail.example.com/inbox")
+	c.Assert(allowed, check.Equals, true)
+	c.Assert(rule.Field, check.Equals, AccessFieldReferer)
+
+	// Requests matching no rules are blocked when there are allow rules
+	allowed, rule = got.CheckAccess("Mozilla/5.0", "")
+	c.Assert(allowed, check.Equals, false)
+	c.Assert(rule, check.IsNil)
+
+	// Updating the page replaces its rules
+	got.AccessRules = got.AccessRules[:1]
+	c.Assert(PutPage(&got), check.Equals, nil)
+	got, err = GetPage(p.Id, p.UserId)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(len(got.AccessRules), check.Equals, 1)
+	allowed, rule = got.CheckAccess("Mozilla/5.0", "")
+	c.Assert(allowed, check.Equals, true)
+	c.Assert(rule, check.IsNil)
+
+	c.Assert(DeletePage(p.Id, p.UserId), check.Equals, nil)
+	rules := []PageAccessRule{}
+	c.Assert(db.Where("page_id=?", p.Id).Find(&rules).Error, check.Equals, nil)
+	c.Assert(len(rules), check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestPageAccessRuleValidation(c *check.C) {
+	rules := map[PageAccessRule]error{
+		{Field: "ip", Pattern: "10.0.0.1", Action: AccessBlock}:              ErrInvalidAccessField,
+		{Field: AccessFieldUserAgent, Pattern: "curl", Action: "redirect"}:   ErrInvalidAccessAction,
+		{Field: AccessFieldUserAgent, Pattern: "curl(", Action: AccessBlock}: ErrInvalidAccessPattern,
+		{Field: AccessFieldReferer, Pattern: "", Action: AccessAllow}:        nil,
+	}
+	for rule, expected := range rules {
+		p := Page{Name: "Invalid Access Page", AccessRules: []PageAccessRule{rule}}
+		c.Assert(p.Validate(), check.Equals, expected)
+	}
+}
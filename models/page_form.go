@@ -0,0 +1,68 @@
+package models
+
+import "fmt"
+
+// DefaultSubmitError is shown as the {{.FormError}} of pages which reject the
+// first submission without a SubmitError of their own.
+const DefaultSubmitError = "Your account or password is incorrect. Please try again."
+
+// MaxFormSteps is the largest number of steps a page's form can have.
+const MaxFormSteps = 10
+
+// MaxSubmitDelay is the longest a page's loading spinner can be shown for,
+// in seconds.
+const MaxSubmitDelay = 30
+
+// ErrInvalidFormSteps is thrown when a page's form has a negative number of
+// steps, or more than MaxFormSteps.
+var ErrInvalidFormSteps = fmt.Errorf("Landing page forms can have up to %d steps", MaxFormSteps)
+
+// ErrInvalidSubmitDelay is thrown when a page's loading spinner is shown for
+// a negative number of seconds, or more than MaxSubmitDelay.
+var ErrInvalidSubmitDelay = fmt.Errorf("Landing page loading spinners can be shown for up to %d seconds", MaxSubmitDelay)
+
+// FormState is the state of a recipient's progress through a landing page's
+// form, based on how many times they've submitted it.
+type FormState struct {
+	// Step is the step of the form shown to the recipient, starting at 1.
+	Step int
+	// Rejected is set when the recipient's submission is rejected, so that
+	// the page's SubmitError is shown.
+	Rejected bool
+	// Complete is set once every step has been submitted, so that the
+	// recipient is sent on to the page's redirect URL.
+	Complete bool
+}
+
+// validateForm checks the page's form steps and loading spinner, and
+// defaults the error shown for rejected submissions.
+func (p *Page) validateForm() error {
+	switch {
+	case p.FormSteps < 0 || p.FormSteps > MaxFormSteps:
+		return ErrInvalidFormSteps
+	case p.SubmitDelay < 0 || p.SubmitDelay > MaxSubmitDelay:
+		return ErrInvalidSubmitDelay
+	}
+	if p.RejectFirstSubmit && p.SubmitError == "" {
+		p.SubmitError = DefaultSubmitError
+	}
+	return ValidateTemplate(p.SubmitError)
+}
+
+// FormState returns the state of the page's form after the recipient has
+// submitted it the given number of times. Each submission moves on to the
+// next step, except for the first submission of the last step if the page
+// rejects it.
+func (p *Page) FormState(submissions int) FormState {
+	steps := p.FormSteps
+	if steps == 0 {
+		steps = 1
+	}
+	switch {
+	case submissions < steps:
+		return FormState{Step: submissions + 1}
+	case submissions == steps && p.RejectFirstSubmit:
+		return FormState{Step: steps, Rejected: true}
+	}
+	return FormState{Step: steps, Complete: true}
+}
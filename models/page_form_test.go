@@ -0,0 +1,30 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPageFormState(c *check.C) {
+	p := Page{Name: "Form Page", HTML: "<html></html>", UserId: 1}
+	p.FormSteps = MaxFormSteps + 1
+	c.Assert(PostPage(&p), check.Equals, ErrInvalidFormSteps)
+	p.FormSteps = 2
+	p.SubmitDelay = -1
+	c.Assert(PostPage(&p), check.Equals, ErrInvalidSubmitDelay)
+	p.SubmitDelay = 3
+	p.RejectFirstSubmit = true
+	c.Assert(PostPage(&p), check.Equals, nil)
+	c.Assert(p.SubmitError, check.Equals, DefaultSubmitError)
+
+	// Each submission moves on to the next step, apart from the first
+	// submission of the last step
+	c.Assert(p.FormState(0), check.Equals, FormState{Step: 1})
+	c.Assert(p.FormState(1), check.Equals, FormState{Step: 2})
+	c.Assert(p.FormState(2), check.Equals, FormState{Step: 2, Rejected: true})
+	c.Assert(p.FormState(3), check.Equals, FormState{Step: 2, Complete: true})
+	c.Assert(p.FormState(4), check.Equals, FormState{Step: 2, Complete: true})
+
+	// Pages without any of the options complete on the first submission
+	plain := Page{}
+	c.Assert(plain.FormState(1), check.Equals, FormState{Step: 1, Complete: true})
+}
@@ -0,0 +1,222 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MaxPageSize is the largest number of items which can be requested in a
+// single page.
+const MaxPageSize = 1000
+
+// ErrInvalidSortField is thrown when the requested sort field isn't supported
+// for the list being requested.
+var ErrInvalidSortField = errors.New("Invalid sort field")
+
+// ErrInvalidFilterField is thrown when the requested filter field isn't
+// supported for the list being requested.
+var ErrInvalidFilterField = errors.New("Invalid filter field")
+
+// ErrInvalidCursor is thrown when the provided cursor can't be decoded.
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// ErrInvalidLimit is thrown when the requested page size is out of range.
+var ErrInvalidLimit = fmt.Errorf("Limit must be between 1 and %d", MaxPageSize)
+
+// ErrNoFilterSpecified is thrown when a bulk operation is requested without
+// any filters, which would otherwise apply to every item.
+var ErrNoFilterSpecified = errors.New("At least one filter must be specified")
+
+// ListOptions are the options used to filter, sort, and paginate a list of
+// items. The zero value returns every item, as lists did before pagination
+// was supported.
+type ListOptions struct {
+	// Limit is the maximum number of items to return. Zero returns all items.
+	Limit int
+	// Cursor is the NextCursor returned with the previous page.
+	Cursor string
+	// Sort is the field to sort by. A leading "-" sorts in descending order.
+	Sort string
+	// Filters maps fields to the value they must be equal to.
+	Filters map[string]string
+}
+
+// PageInfo describes the page of items which was returned.
+type PageInfo struct {
+	// Total is the number of items matching the filters across all pages.
+	Total int64 `json:"total"`
+	// NextCursor is used to request the next page. It's empty on the last
+	// page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listFields maps the field names accepted for sorting and filtering to their
+// database columns.
+type listFields map[string]string
+
+// cursor is the decoded form of ListOptions.Cursor. It holds the sort value
+// and id of the last item on the previous page.
+type cursor struct {
+	Id    int64       `json:"id"`
+	Value interface{} `json:"v"`
+	Time  bool        `json:"t,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	if t, ok := c.Value.(time.Time); ok {
+		c.Value = t.UTC().Format(time.RFC3339Nano)
+		c.Time = true
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	c := cursor{}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.UseNumber()
+	err = d.Decode(&c)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	switch v := c.Value.(type) {
+	case json.Number:
+		c.Value, err = v.Int64()
+		if err != nil {
+			c.Value, err = v.Float64()
+		}
+	case string:
+		if c.Time {
+			c.Value, err = time.Parse(time.RFC3339Nano, v)
+		}
+	}
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// sortColumn returns the column to sort by and whether the sort is
+// descending.
+func (o ListOptions) sortColumn(fields listFields) (string, bool, error) {
+	if o.Sort == "" {
+		return "id", false, nil
+	}
+	desc := strings.HasPrefix(o.Sort, "-")
+	column, ok := fields[strings.TrimPrefix(o.Sort, "-")]
+	if !ok {
+		return "", false, ErrInvalidSortField
+	}
+	return column, desc, nil
+}
+
+// paginated returns whether sorting or pagination was requested. If not, the
+// list is returned in its original order.
+func (o ListOptions) paginated() bool {
+	return o.Limit != 0 || o.Cursor != "" || o.Sort != ""
+}
+
+// apply filters, sorts, and paginates the query, returning the query along
+// with the total number of items matching the filters.
+func (o ListOptions) apply(query *gorm.DB, fields listFields) (*gorm.DB, int64, error) {
+	var total int64
+	if o.Limit < 0 || o.Limit > MaxPageSize {
+		return query, total, ErrInvalidLimit
+	}
+	for field, value := range o.Filters {
+		column, ok := fields[field]
+		if !ok {
+			return query, total, ErrInvalidFilterField
+		}
+		query = query.Where(fmt.Sprintf("%s = ?", column), value)
+	}
+	err := query.Count(&total).Error
+	if err != nil {
+		return query, total, err
+	}
+	if !o.paginated() {
+		return query, total, nil
+	}
+	column, desc, err := o.sortColumn(fields)
+	if err != nil {
+		return query, total, err
+	}
+	id := "id"
+	op, dir := ">", "asc"
+	if desc {
+		op, dir = "<", "desc"
+	}
+	if o.Cursor != "" {
+		c, err := decodeCursor(o.Cursor)
+		if err != nil {
+			return query, total, err
+		}
+		if column == id {
+			query = query.Where(fmt.Sprintf("%s %s ?", id, op), c.Id)
+		} else {
+			query = query.Where(fmt.Sprintf("(%s %s ?) OR (%s = ? AND %s %s ?)", column, op, column, id, op), c.Value, c.Value, c.Id)
+		}
+	}
+	if column == id {
+		query = query.Order(fmt.Sprintf("%s %s", id, dir))
+	} else {
+		query = query.Order(fmt.Sprintf("%s %s, %s %s", column, dir, id, dir))
+	}
+	if o.Limit > 0 {
+		query = query.Limit(o.Limit)
+	}
+	return query, total, nil
+}
+
+// matchingIds returns the ids of the items matching the filters. At least one
+// filter is required, so that bulk operations can't accidentally apply to
+// every item.
+func (o ListOptions) matchingIds(query *gorm.DB, fields listFields) ([]int64, error) {
+	ids := []int64{}
+	if len(o.Filters) == 0 {
+		return ids, ErrNoFilterSpecified
+	}
+	query, _, err := ListOptions{Filters: o.Filters}.apply(query, fields)
+	if err != nil {
+		return ids, err
+	}
+	err = query.Pluck("id", &ids).Error
+	return ids, err
+}
+
+// pageInfo returns the page information for the items returned by a query
+// which had the options applied. The items must be a slice of structs with
+// an Id field.
+func (o ListOptions) pageInfo(items interface{}, total int64, fields listFields) PageInfo {
+	page := PageInfo{Total: total}
+	v := reflect.Indirect(reflect.ValueOf(items))
+	if o.Limit == 0 || v.Len() < o.Limit {
+		return page
+	}
+	column, _, err := o.sortColumn(fields)
+	if err != nil {
+		return page
+	}
+	last := v.Index(v.Len() - 1).Addr().Interface()
+	scope := db.NewScope(last)
+	c := cursor{}
+	if f, ok := scope.FieldByName("id"); ok {
+		c.Id = f.Field.Int()
+	}
+	if f, ok := scope.FieldByName(column); ok {
+		c.Value = f.Field.Interface()
+	}
+	page.NextCursor = encodeCursor(c)
+	return page
+}
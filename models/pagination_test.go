@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) createPages(ch *check.C, n int) {
+	for i := 0; i < n; i++ {
+		p := Page{
+			Name:         fmt.Sprintf("Page %d", i),
+			HTML:         "<html>Test</html>",
+			UserId:       1,
+			ModifiedDate: time.Now().UTC().Add(time.Duration(i) * time.Minute),
+		}
+		ch.Assert(PostPage(&p), check.Equals, nil)
+	}
+}
+
+func (s *ModelsSuite) TestListPagesDefault(ch *check.C) {
+	s.createPages(ch, 5)
+	ps, page, err := ListPages(1, ListOptions{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ps), check.Equals, 5)
+	ch.Assert(page.Total, check.Equals, int64(5))
+	ch.Assert(page.NextCursor, check.Equals, "")
+}
+
+func (s *ModelsSuite) TestListPagesCursor(ch *check.C) {
+	s.createPages(ch, 5)
+	for _, sort := range []string{"-name", "-modified_date"} {
+		names := []string{}
+		opts := ListOptions{Limit: 2, Sort: sort}
+		for i := 0; i < 5; i++ {
+			ps, page, err := ListPages(1, opts)
+			ch.Assert(err, check.Equals, nil)
+			ch.Assert(page.Total, check.Equals, int64(5))
+			for _, p := range ps {
+				names = append(names, p.Name)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		ch.Assert(names, check.DeepEquals, []string{"Page 4", "Page 3", "Page 2", "Page 1", "Page 0"})
+	}
+}
+
+func (s *ModelsSuite) TestListPagesFilter(ch *check.C) {
+	s.createPages(ch, 3)
+	ps, page, err := ListPages(1, ListOptions{Filters: map[string]string{"name": "Page 1"}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ps), check.Equals, 1)
+	ch.Assert(ps[0].Name, check.Equals, "Page 1")
+	ch.Assert(page.Total, check.Equals, int64(1))
+}
+
+func (s *ModelsSuite) TestListInvalidOptions(ch *check.C) {
+	_, _, err := ListPages(1, ListOptions{Sort: "html"})
+	ch.Assert(err, check.Equals, ErrInvalidSortField)
+	_, _, err = ListPages(1, ListOptions{Filters: map[string]string{"user_id": "2"}})
+	ch.Assert(err, check.Equals, ErrInvalidFilterField)
+	_, _, err = ListPages(1, ListOptions{Limit: 1, Cursor: "invalid!"})
+	ch.Assert(err, check.Equals, ErrInvalidCursor)
+	_, _, err = ListPages(1, ListOptions{Limit: MaxPageSize + 1})
+	ch.Assert(err, check.Equals, ErrInvalidLimit)
+}
+
+func (s *ModelsSuite) TestListCampaignResultsPaginated(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	cr, page, err := ListCampaignResults(campaign.Id, campaign.UserId, ListOptions{Limit: 1, Sort: "email"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cr.Results), check.Equals, 1)
+	ch.Assert(cr.Results[0].Email, check.Equals, "test1@example.com")
+	ch.Assert(page.Total, check.Equals, int64(len(campaign.Results)))
+	ch.Assert(page.NextCursor, check.Not(check.Equals), "")
+	for _, e := range cr.Events {
+		if e.Email != "" {
+			ch.Assert(e.Email, check.Equals, cr.Results[0].Email)
+		}
+	}
+
+	cr, _, err = ListCampaignResults(campaign.Id, campaign.UserId, ListOptions{Limit: 1, Sort: "email", Cursor: page.NextCursor})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cr.Results), check.Equals, 1)
+	ch.Assert(cr.Results[0].Email, check.Equals, "test2@example.com")
+}
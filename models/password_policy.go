@@ -0,0 +1,275 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/gophish/gophish/config"
+)
+
+// The policy violations a scored password can have
+const (
+	PasswordTooShort      = "too_short"
+	PasswordMissingUpper  = "missing_upper"
+	PasswordMissingLower  = "missing_lower"
+	PasswordMissingDigit  = "missing_digit"
+	PasswordMissingSymbol = "missing_symbol"
+	PasswordCommon        = "common"
+	PasswordPersonalInfo  = "personal_info"
+	PasswordBelowMinScore = "below_min_score"
+)
+
+// minHintLength is the shortest personal information or common word which is
+// looked for in passwords.
+const minHintLength = 3
+
+// sequenceCharacterWeight is how much of a character is counted for each
+// character which repeats or continues a sequence.
+const sequenceCharacterWeight = 0.25
+
+// commonPasswords are passwords, or words in passwords, which are among the
+// first to be guessed.
+var commonPasswords = []string{
+	"password", "passw0rd", "p@ssword", "p@ssw0rd", "welcome", "letmein",
+	"qwerty", "asdfgh", "zxcvbn", "123456", "111111", "abc123", "iloveyou",
+	"admin", "login", "master", "monkey", "dragon", "football", "baseball",
+	"sunshine", "princess", "shadow", "trustno1", "changeme", "secret",
+	"spring", "summer", "autumn", "winter", "january", "february", "march",
+	"april", "june", "july", "august", "september", "october", "november",
+	"december", "company",
+}
+
+// scoreThresholds are the estimated bits of entropy needed for each score
+// above zero.
+var scoreThresholds = []float64{28, 36, 50, 64}
+
+// PasswordCheck is the result of checking a password submitted to a landing
+// page against the password policy. Only the check is stored, and never the
+// password itself.
+type PasswordCheck struct {
+	Field      string   `json:"field"`
+	Score      int      `json:"score"`
+	Violations []string `json:"violations"`
+}
+
+// Weak returns whether the password violated the password policy.
+func (pc PasswordCheck) Weak() bool {
+	return len(pc.Violations) > 0
+}
+
+// ScorePassword estimates how hard the password is to guess, from 0 (easily
+// guessed) to 4. Common words and the personal information in hints, such as
+// the recipient's name, are assumed to be guessed first.
+func ScorePassword(password string, hints ...string) int {
+	lower := strings.ToLower(password)
+	words := append(append([]string{}, hints...), commonPasswords...)
+	for _, word := range words {
+		word = strings.ToLower(word)
+		if len(word) < minHintLength {
+			continue
+		}
+		// Each guessable word only counts as a single character
+		lower = strings.Replace(lower, word, "\x00", -1)
+	}
+	bits := 0.0
+	if charset := charsetSize(password); charset > 0 {
+		bits = effectiveLength(lower) * math.Log2(float64(charset))
+	}
+	score := 0
+	for _, threshold := range scoreThresholds {
+		if bits >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+// charsetSize returns the number of characters an attacker would need to
+// try for each character of the password.
+func charsetSize(password string) int {
+	size := 0
+	var upper, lower, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{upper, 26}, {lower, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// effectiveLength returns the length of the password, counting characters
+// which repeat or continue a sequence, such as "aaa" or "123", as a fraction
+// of a character.
+func effectiveLength(password string) float64 {
+	length := 0.0
+	var previous rune
+	for i, r := range password {
+		if i > 0 && (r == previous || r == previous+1 || r == previous-1) {
+			length += sequenceCharacterWeight
+		} else {
+			length++
+		}
+		previous = r
+	}
+	return length
+}
+
+// CheckPassword scores the password and checks it against the policy. The
+// hints are personal information the password shouldn't contain.
+func CheckPassword(password string, policy config.PasswordPolicy, hints ...string) PasswordCheck {
+	minLength, minScore := policy.Minimums()
+	pc := PasswordCheck{Score: ScorePassword(password, hints...), Violations: []string{}}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	normalized := strings.ToLower(password)
+	common := false
+	for _, word := range commonPasswords {
+		common = common || strings.Contains(normalized, word)
+	}
+	personal := false
+	for _, hint := range hints {
+		hint = strings.ToLower(hint)
+		personal = personal || (len(hint) >= minHintLength && strings.Contains(normalized, hint))
+	}
+	for _, v := range []struct {
+		violated  bool
+		violation string
+	}{
+		{len([]rune(password)) < minLength, PasswordTooShort},
+		{policy.RequireUpper && !upper, PasswordMissingUpper},
+		{policy.RequireLower && !lower, PasswordMissingLower},
+		{policy.RequireDigit && !digit, PasswordMissingDigit},
+		{policy.RequireSymbol && !symbol, PasswordMissingSymbol},
+		{common, PasswordCommon},
+		{personal, PasswordPersonalInfo},
+		{pc.Score < minScore, PasswordBelowMinScore},
+	} {
+		if v.violated {
+			pc.Violations = append(pc.Violations, v.violation)
+		}
+	}
+	return pc
+}
+
+// passwordHints returns the recipient's personal information which their
+// password shouldn't contain.
+func passwordHints(r BaseRecipient) []string {
+	hints := []string{r.FirstName, r.LastName}
+	if i := strings.Index(r.Email, "@"); i > 0 {
+		hints = append(hints, r.Email[:i])
+	}
+	return hints
+}
+
+// ScoreCapturedPasswords replaces the passwords submitted to pages which
+// score passwords with their checks against the password policy, so that the
+// passwords themselves aren't stored. The raw request isn't stored either,
+// since it contains the passwords.
+func (p *Page) ScoreCapturedPasswords(r BaseRecipient, d EventDetails) EventDetails {
+	if !p.ScorePasswords || len(d.Payload) == 0 {
+		return d
+	}
+	scored := EventDetails{
+		Payload:   url.Values{},
+		Browser:   d.Browser,
+		RequestId: d.RequestId,
+		passwords: url.Values{},
+	}
+	for k, vs := range d.Payload {
+		if !isPasswordField(k) {
+			scored.Payload[k] = vs
+			continue
+		}
+		// The passwords are kept in memory to check for honeytokens
+		scored.passwords[k] = vs
+		for _, v := range vs {
+			pc := CheckPassword(v, conf.PasswordPolicy, passwordHints(r)...)
+			pc.Field = k
+			scored.PasswordChecks = append(scored.PasswordChecks, pc)
+		}
+	}
+	return scored
+}
+
+// PasswordStats summarizes the checks of the passwords submitted to a
+// campaign's landing page. Scores holds the number of passwords with each
+// score.
+type PasswordStats struct {
+	Scored         int            `json:"scored"`
+	Weak           int            `json:"weak"`
+	WeakRecipients int            `json:"weak_recipients"`
+	Scores         []int          `json:"scores"`
+	Violations     map[string]int `json:"violations"`
+}
+
+// GetPasswordStats returns the password stats of the campaign with the given
+// id, provided it's owned by the given user.
+func GetPasswordStats(cid int64, uid int64) (PasswordStats, error) {
+	ps := PasswordStats{
+		Scores:     make([]int, config.MaxPasswordScore+1),
+		Violations: map[string]int{},
+	}
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id, archived_date").Where("id=? and user_id=?", cid, uid).First(&c).Error
+	if err != nil {
+		return ps, err
+	}
+	es := []Event{}
+	err = db.Table(eventsTable(c.ArchivedDate)).Where("campaign_id=? and message=?", cid, EventDataSubmit).Find(&es).Error
+	if err != nil {
+		return ps, err
+	}
+	weak := map[string]bool{}
+	for _, e := range es {
+		details := EventDetails{}
+		if e.Details == "" || json.Unmarshal([]byte(e.Details), &details) != nil {
+			continue
+		}
+		for _, pc := range details.PasswordChecks {
+			ps.Scored++
+			if pc.Score >= 0 && pc.Score < len(ps.Scores) {
+				ps.Scores[pc.Score]++
+			}
+			for _, v := range pc.Violations {
+				ps.Violations[v]++
+			}
+			if pc.Weak() {
+				ps.Weak++
+				weak[e.Email] = true
+			}
+		}
+	}
+	ps.WeakRecipients = len(weak)
+	return ps, nil
+}
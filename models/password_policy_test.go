@@ -0,0 +1,87 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gophish/gophish/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestScorePassword(ch *check.C) {
+	tests := []struct {
+		password string
+		score    int
+	}{
+		{"", 0},
+		{"password1", 0},
+		{"abcdefgh", 0},
+		{"Summer2021!", 1},
+		{"qwhzkxpa", 2},
+		{"Kx9#mP2q", 3},
+		{"correct horse battery staple", 4},
+	}
+	for _, test := range tests {
+		ch.Assert(ScorePassword(test.password), check.Equals, test.score, check.Commentf("password %q", test.password))
+	}
+	// Personal information is guessed first
+	ch.Assert(ScorePassword("jdoe!Example", "jdoe", "Example") < ScorePassword("jdoe!Example"), check.Equals, true)
+}
+
+func (s *ModelsSuite) TestCheckPassword(ch *check.C) {
+	pc := CheckPassword("password", config.PasswordPolicy{RequireDigit: true})
+	ch.Assert(pc.Weak(), check.Equals, true)
+	ch.Assert(pc.Violations, check.DeepEquals, []string{PasswordMissingDigit, PasswordCommon, PasswordBelowMinScore})
+
+	pc = CheckPassword("Jane-Fx8#qLm2", config.PasswordPolicy{}, "Jane")
+	ch.Assert(pc.Violations, check.DeepEquals, []string{PasswordPersonalInfo})
+
+	pc = CheckPassword("Kx9#mP2qT!v7", config.PasswordPolicy{MinLength: 14, RequireUpper: true, RequireSymbol: true})
+	ch.Assert(pc.Violations, check.DeepEquals, []string{PasswordTooShort})
+
+	pc = CheckPassword("Kx9#mP2qT!v7", config.PasswordPolicy{})
+	ch.Assert(pc.Weak(), check.Equals, false)
+}
+
+func (s *ModelsSuite) TestScoreCapturedPasswords(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	p := Page{ScorePasswords: true}
+	r := campaign.Results[0]
+	d := EventDetails{
+		Payload:   url.Values{"username": {r.Email}, "password": {"password"}, RecipientParameter: {r.RId}},
+		Browser:   map[string]string{},
+		Request:   &EventRequest{},
+		RequestId: "scored-request",
+	}
+	scored := p.ScoreCapturedPasswords(r.BaseRecipient, d)
+	ch.Assert(scored.Payload.Get("password"), check.Equals, "")
+	ch.Assert(scored.Payload.Get("username"), check.Equals, r.Email)
+	ch.Assert(scored.Request, check.IsNil)
+	ch.Assert(scored.RequestId, check.Equals, "scored-request")
+	ch.Assert(len(scored.PasswordChecks), check.Equals, 1)
+	ch.Assert(scored.PasswordChecks[0].Field, check.Equals, "password")
+	ch.Assert(r.HandleFormSubmit(scored), check.Equals, nil)
+
+	// The password is never stored
+	e := Event{}
+	err := db.Where("campaign_id=? and message=?", campaign.Id, EventDataSubmit).First(&e).Error
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(strings.Contains(string(e.Details), `"password"`), check.Equals, true)
+	ch.Assert(strings.Contains(string(e.Details), `["password"]`), check.Equals, false)
+
+	strong := campaign.Results[1]
+	d.Payload = url.Values{"password": {"Kx9#mP2qT!v7"}}
+	ch.Assert(strong.HandleFormSubmit(p.ScoreCapturedPasswords(strong.BaseRecipient, d)), check.Equals, nil)
+
+	ps, err := GetPasswordStats(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ps.Scored, check.Equals, 2)
+	ch.Assert(ps.Weak, check.Equals, 1)
+	ch.Assert(ps.WeakRecipients, check.Equals, 1)
+	ch.Assert(ps.Scores, check.DeepEquals, []int{1, 0, 0, 0, 1})
+	ch.Assert(ps.Violations[PasswordCommon], check.Equals, 1)
+
+	// Pages which don't score passwords are unchanged
+	p.ScorePasswords = false
+	ch.Assert(p.ScoreCapturedPasswords(r.BaseRecipient, d).Payload.Get("password"), check.Equals, "Kx9#mP2qT!v7")
+}
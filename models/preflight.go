@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gophish/gomail"
+	"github.com/gophish/gophish/deliverability"
+	"github.com/gophish/gophish/dialer"
+)
+
+// ErrInvalidPreflightIP is returned when the sending address given for a
+// pre-flight check isn't an IP address.
+var ErrInvalidPreflightIP = errors.New("The sending address must be an IP address")
+
+// PreflightChecker runs the DNS checks for pre-flight requests. It can be
+// replaced in tests.
+var PreflightChecker = deliverability.NewChecker()
+
+// PreflightRequest is a request to check the deliverability of emails sent
+// using a sending profile.
+type PreflightRequest struct {
+	// Domain is the sending domain to check. It defaults to the domain of
+	// the profile's from address.
+	Domain string `json:"domain"`
+	// IP is the address emails are delivered from. It defaults to the
+	// profile's source address, or else the addresses of its SMTP server.
+	// When sending through a relay, this should be the relay's outbound
+	// address, since that's what recipients see.
+	IP string `json:"ip"`
+	// DKIMSelectors are the DKIM selectors to look up.
+	DKIMSelectors []string `json:"dkim_selectors"`
+	// SeedAddress, if set, is sent a probe email using the profile.
+	SeedAddress string `json:"seed_address"`
+}
+
+// Preflight checks the deliverability of emails sent using the sending
+// profile, optionally sending a probe email to a seed mailbox.
+func (s *SMTP) Preflight(ctx context.Context, req PreflightRequest) (*deliverability.Report, error) {
+	domain := req.Domain
+	if domain == "" {
+		from, err := mail.ParseAddress(s.FromAddress)
+		if err != nil {
+			return nil, err
+		}
+		domain = from.Address[strings.LastIndex(from.Address, "@")+1:]
+	}
+	if req.SeedAddress != "" {
+		_, err := mail.ParseAddress(req.SeedAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ips, err := s.preflightIPs(ctx, req.IP)
+	if err != nil {
+		return nil, err
+	}
+	report := PreflightChecker.Check(ctx, domain, ips, req.DKIMSelectors)
+	if req.SeedAddress != "" {
+		check := deliverability.Check{Name: "probe", Weight: 20}
+		err = s.sendProbe(req.SeedAddress)
+		if err != nil {
+			check.Status = deliverability.StatusFail
+			check.Message = fmt.Sprintf("Error sending the probe email to %s: %v", req.SeedAddress, err)
+		} else {
+			check.Status = deliverability.StatusPass
+			check.Message = fmt.Sprintf("The probe email was accepted for delivery to %s. Check the seed mailbox to see whether it was delivered to the inbox.", req.SeedAddress)
+		}
+		report.Add(check)
+	}
+	return report, nil
+}
+
+// preflightIPs returns the addresses emails are sent from.
+func (s *SMTP) preflightIPs(ctx context.Context, ip string) ([]net.IP, error) {
+	if ip != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, ErrInvalidPreflightIP
+		}
+		return []net.IP{parsed}, nil
+	}
+	source, err := dialer.ParseSourceAddress(s.SourceAddress)
+	if err != nil {
+		return nil, err
+	}
+	if source != nil {
+		return []net.IP{source.IP}, nil
+	}
+	host := strings.Split(s.Host, ":")[0]
+	if parsed := net.ParseIP(host); parsed != nil {
+		return []net.IP{parsed}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := []net.IP{}
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// sendProbe sends a plain email to the seed address using the sending
+// profile.
+func (s *SMTP) sendProbe(to string) error {
+	from, err := mail.ParseAddress(s.FromAddress)
+	if err != nil {
+		return err
+	}
+	d, err := s.GetDialer()
+	if err != nil {
+		return err
+	}
+	sender, err := d.Dial()
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	msg := gomail.NewMessage()
+	msg.SetAddressHeader("From", asciiAddress(from.Address), from.Name)
+	msg.SetHeader("To", asciiAddress(to))
+	msg.SetHeader("Subject", "Deliverability check")
+	msg.SetDateHeader("Date", time.Now().UTC())
+	msg.SetBody("text/plain", "This email was sent to check the deliverability of a sending profile.")
+	return gomail.Send(sender, msg)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"net"
+
+	"github.com/gophish/gophish/deliverability"
+	check "gopkg.in/check.v1"
+)
+
+// emptyResolver is a deliverability.Resolver for which no records exist.
+type emptyResolver struct{}
+
+func (emptyResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (emptyResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (emptyResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+func (emptyResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (s *ModelsSuite) TestSMTPPreflight(ch *check.C) {
+	orig := PreflightChecker
+	defer func() { PreflightChecker = orig }()
+	PreflightChecker = &deliverability.Checker{Resolver: emptyResolver{}}
+
+	smtp := SMTP{
+		Host:        "127.0.0.1:1",
+		FromAddress: "Foo Bar <foo@example.com>",
+	}
+	_, err := smtp.Preflight(context.Background(), PreflightRequest{IP: "not an ip"})
+	ch.Assert(err, check.Equals, ErrInvalidPreflightIP)
+
+	report, err := smtp.Preflight(context.Background(), PreflightRequest{IP: "192.0.2.1", SeedAddress: "seed@example.com"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Domain, check.Equals, "example.com")
+	ch.Assert(report.IPs, check.DeepEquals, []string{"192.0.2.1"})
+	statuses := map[string]deliverability.Status{}
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	ch.Assert(statuses, check.DeepEquals, map[string]deliverability.Status{
+		"spf":   deliverability.StatusFail,
+		"dkim":  deliverability.StatusWarn,
+		"dmarc": deliverability.StatusWarn,
+		"rdns":  deliverability.StatusFail,
+		"probe": deliverability.StatusFail,
+	})
+	ch.Assert(report.Score, check.Equals, 100-20-10-7-10-20)
+
+	// The profile's source address is checked when no address is given
+	smtp.SourceAddress = "127.0.0.2"
+	report, err = smtp.Preflight(context.Background(), PreflightRequest{Domain: "example.org"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(report.Domain, check.Equals, "example.org")
+	ch.Assert(report.IPs, check.DeepEquals, []string{"127.0.0.2"})
+}
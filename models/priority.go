@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// The priority levels of a campaign. Queued emails are sent starting with
+// the campaigns with the highest priority, then by their send date.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+	PriorityUrgent = 2
+)
+
+// ErrInvalidPriority is thrown when a campaign's priority isn't one of the
+// priority levels.
+var ErrInvalidPriority = errors.New("The priority must be between -1 (low) and 2 (urgent)")
+
+// PriorityRequest changes the priority of a campaign.
+type PriorityRequest struct {
+	Priority int `json:"priority"`
+}
+
+func validPriority(p int) bool {
+	return p >= PriorityLow && p <= PriorityUrgent
+}
+
+// SetCampaignPriority changes the priority of a campaign, which takes effect
+// for the emails which haven't been sent yet. This lets a large campaign
+// which is already running be deprioritized in favor of an urgent one.
+func SetCampaignPriority(id int64, uid int64, priority int) error {
+	if !validPriority(priority) {
+		return ErrInvalidPriority
+	}
+	var count int
+	err := db.Model(&Campaign{}).Where("id=? and user_id=?", id, uid).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Table("campaigns").Where("id=?", id).UpdateColumn("priority", priority).Error
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignPriorityValidation(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Priority = PriorityUrgent + 1
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidPriority)
+	c.Priority = PriorityLow - 1
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidPriority)
+}
+
+func (s *ModelsSuite) TestSetCampaignPriority(ch *check.C) {
+	normal := s.createCampaign(ch)
+	urgent := s.createCampaign(ch)
+	for _, c := range []Campaign{normal, urgent} {
+		ms, err := GetMailLogsByCampaign(c.Id)
+		ch.Assert(err, check.Equals, nil)
+		ch.Assert(LockMailLogs(ms, false), check.Equals, nil)
+	}
+
+	ch.Assert(SetCampaignPriority(urgent.Id, urgent.UserId, PriorityUrgent+1), check.Equals, ErrInvalidPriority)
+	ch.Assert(SetCampaignPriority(urgent.Id, urgent.UserId+1, PriorityUrgent), check.Equals, gorm.ErrRecordNotFound)
+	ch.Assert(SetCampaignPriority(urgent.Id, urgent.UserId, PriorityUrgent), check.Equals, nil)
+	got, err := GetCampaign(urgent.Id, urgent.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Priority, check.Equals, PriorityUrgent)
+
+	// The urgent campaign's emails are sent first, even though the other
+	// campaign's emails have been waiting as long.
+	ms, err := GetQueuedMailLogsBatch(urgent.LaunchDate, len(urgent.Results))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, len(urgent.Results))
+	for _, m := range ms {
+		ch.Assert(m.CampaignId, check.Equals, urgent.Id)
+	}
+}
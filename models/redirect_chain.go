@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redirectValidationURL is the link wrapped by open redirects when they're
+// validated.
+const redirectValidationURL = "http://example.com?rid=123456"
+
+// ErrInvalidRedirect is thrown when a campaign's open redirect doesn't render
+// as an http or https URL.
+var ErrInvalidRedirect = errors.New("Each open redirect must be an http or https URL")
+
+// ErrRedirectMissingURL is thrown when a campaign's open redirect doesn't
+// include the link it redirects to.
+var ErrRedirectMissingURL = errors.New("Each open redirect must include the link it redirects to, such as {{urlquery .URL}}")
+
+// ErrRedirectNotAllowed is thrown when a campaign's open redirect isn't on one
+// of the configured open redirect domains.
+var ErrRedirectNotAllowed = errors.New("Open redirects can only use the domains in the open_redirect_domains setting")
+
+// redirectContext is implemented by template contexts which wrap the
+// recipient's link in open redirects.
+type redirectContext interface {
+	getRedirects() []string
+}
+
+// getRedirects returns the open redirects the campaign's links are wrapped
+// in, innermost first.
+func (c *Campaign) getRedirects() []string {
+	return c.Redirects
+}
+
+// BeforeSave encodes the campaign's open redirects for storing.
+func (c *Campaign) BeforeSave() error {
+	if len(c.Redirects) == 0 {
+		c.RawRedirects = ""
+		return nil
+	}
+	b, err := json.Marshal(c.Redirects)
+	if err != nil {
+		return err
+	}
+	c.RawRedirects = string(b)
+	return nil
+}
+
+// AfterFind decodes the campaign's stored open redirects.
+func (c *Campaign) AfterFind() error {
+	c.Redirects = []string{}
+	if c.RawRedirects == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(c.RawRedirects), &c.Redirects)
+}
+
+// redirectDomainAllowed returns whether the host is one of the configured
+// open redirect domains, or a subdomain of one.
+func redirectDomainAllowed(host string) bool {
+	if conf == nil {
+		return false
+	}
+	host = strings.ToLower(asciiDomain(host))
+	for _, allowed := range conf.OpenRedirectDomains {
+		allowed = strings.ToLower(asciiDomain(allowed))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedirects checks that each of the campaign's open redirects is an
+// allowed URL which includes the link it wraps.
+func (c *Campaign) validateRedirects() error {
+	ptx := PhishingTemplateContext{RId: "123456"}
+	for _, redirect := range c.Redirects {
+		ptx.URL = redirectValidationURL
+		wrapped, err := ExecuteTemplate(redirect, ptx)
+		if err != nil {
+			return err
+		}
+		u, err := url.Parse(wrapped)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return ErrInvalidRedirect
+		}
+		if !strings.Contains(wrapped, redirectValidationURL) &&
+			!strings.Contains(wrapped, url.QueryEscape(redirectValidationURL)) &&
+			!strings.Contains(wrapped, url.PathEscape(redirectValidationURL)) {
+			return ErrRedirectMissingURL
+		}
+		if !redirectDomainAllowed(u.Hostname()) {
+			return ErrRedirectNotAllowed
+		}
+	}
+	return nil
+}
+
+// wrapRedirects wraps the recipient's link in each of the open redirects in
+// turn, so that the outermost redirect is the first hop and the recipient's
+// link is the last.
+func wrapRedirects(redirects []string, ptx PhishingTemplateContext) (string, error) {
+	link := ptx.URL
+	for _, redirect := range redirects {
+		ptx.URL = link
+		wrapped, err := ExecuteTemplate(redirect, ptx)
+		if err != nil {
+			return "", fmt.Errorf("error rendering open redirect: %v", err)
+		}
+		link = wrapped
+	}
+	return link, nil
+}
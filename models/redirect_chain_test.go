@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignRedirects(ch *check.C) {
+	defer func() { conf.OpenRedirectDomains = nil }()
+	c := s.createCampaignDependencies(ch)
+	c.URL = "https://phish.example.com"
+	c.Redirects = []string{"https://www.example.org/url?q={{urlquery .URL}}"}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrRedirectNotAllowed)
+
+	conf.OpenRedirectDomains = []string{"example.org", "example.net"}
+	for _, redirect := range []string{"ftp://www.example.org/{{.URL}}", "www.example.org/{{.URL}}"} {
+		c.Redirects = []string{redirect}
+		ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidRedirect)
+	}
+	c.Redirects = []string{"https://www.example.org/url?q=https://example.com"}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrRedirectMissingURL)
+	c.Redirects = []string{"https://www.example.com/url?q={{urlquery .URL}}"}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrRedirectNotAllowed)
+
+	// Redirects are applied innermost first, so the last one is the first
+	// hop
+	c.Redirects = []string{
+		"https://www.example.org/url?q={{urlquery .URL}}",
+		"https://login.example.net/logout?next={{urlquery .URL}}",
+	}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	got, err := GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Redirects, check.DeepEquals, c.Redirects)
+
+	r := got.Results[0]
+	ptx, err := NewPhishingTemplateContext(&got, r.BaseRecipient, r.RId)
+	ch.Assert(err, check.Equals, nil)
+	link := fmt.Sprintf("https://phish.example.com?%s=%s", RecipientParameter, r.RId)
+	ch.Assert(ptx.LongURL, check.Equals, link)
+	inner := "https://www.example.org/url?q=" + url.QueryEscape(link)
+	ch.Assert(ptx.URL, check.Equals, "https://login.example.net/logout?next="+url.QueryEscape(inner))
+
+	// Short links are wrapped instead of the full link
+	got.ShortURL = "https://go.example.com"
+	ptx, err = NewPhishingTemplateContext(&got, r.BaseRecipient, r.RId)
+	ch.Assert(err, check.Equals, nil)
+	inner = "https://www.example.org/url?q=" + url.QueryEscape("https://go.example.com/"+r.RId)
+	ch.Assert(ptx.URL, check.Equals, "https://login.example.net/logout?next="+url.QueryEscape(inner))
+
+	is, err := GetCampaignIndicators(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	found := map[string]string{}
+	for _, i := range is {
+		found[i.Value] = i.Description
+	}
+	ch.Assert(found[link], check.Equals, "Phishing link")
+	ch.Assert(found["https://login.example.net/logout?next="+url.QueryEscape("https://www.example.org/url?q="+url.QueryEscape(link))], check.Equals, "Open redirect link")
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"github.com/gophish/gophish/config"
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// replica is the read-only replica of the database used for reporting
+// queries, if one is configured.
+var replica *gorm.DB
+
+// setupReplica connects to the read replica configured by db_replica_path,
+// so that heavy reporting queries don't contend with the worker's writes to
+// the database. Migrations aren't run against the replica, since they're
+// replicated from the database. If the replica can't be reached, reports
+// are read from the database instead.
+func setupReplica(c *config.Config) {
+	if replica != nil {
+		replica.Close()
+		replica = nil
+	}
+	if c.DBReplicaPath == "" {
+		return
+	}
+	dsn := c.DBReplicaPath
+	if c.DBName == "sqlite3" {
+		dsn = sqliteDSN(c.DBReplicaPath, c.SQLite)
+	}
+	r, err := gorm.Open(c.DBName, dsn)
+	if err != nil {
+		log.Warnf("error connecting to the read replica, reading reports from the database instead: %v", err)
+		return
+	}
+	err = r.DB().Ping()
+	if err != nil {
+		r.Close()
+		log.Warnf("error connecting to the read replica, reading reports from the database instead: %v", err)
+		return
+	}
+	r.LogMode(false)
+	r.SetLogger(log.Logger)
+	replica = r
+	log.Info("Reading reports from the read replica")
+}
+
+// reportingDB returns the database used for reporting queries, which is the
+// read replica if one is configured. Reports may lag slightly behind the
+// database while the replica catches up.
+func reportingDB() *gorm.DB {
+	if replica != nil {
+		return replica
+	}
+	return db
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/gophish/gophish/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestReportingDBFallback(ch *check.C) {
+	ch.Assert(replica, check.IsNil)
+	ch.Assert(reportingDB(), check.Equals, db)
+	c := s.createCampaign(ch)
+	summary, err := GetCampaignSummary(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(summary.Stats.Total, check.Equals, int64(len(c.Results)))
+}
+
+func (s *ModelsSuite) TestReportingDBReplica(ch *check.C) {
+	c := s.createCampaign(ch)
+	// The replica is an empty database, so reports read from it fail, while
+	// the campaign itself is still read from the database.
+	setupReplica(&config.Config{DBName: "sqlite3", DBReplicaPath: ":memory:"})
+	defer setupReplica(&config.Config{})
+	ch.Assert(replica, check.NotNil)
+	ch.Assert(reportingDB(), check.Equals, replica)
+	_, err := GetCampaignSummary(c.Id, c.UserId)
+	ch.Assert(err, check.NotNil)
+	_, err = GetCampaignResults(c.Id, c.UserId)
+	ch.Assert(err, check.NotNil)
+	_, err = GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+}
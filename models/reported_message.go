@@ -0,0 +1,190 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// The classifications given to reported messages, based on how many signs of
+// phishing they have
+const (
+	ReportClassLikelyPhish = "likely_phish"
+	ReportClassSuspicious  = "suspicious"
+	ReportClassLowRisk     = "low_risk"
+)
+
+// The triage statuses of reported messages
+const (
+	ReportStatusNew           = "new"
+	ReportStatusConfirmed     = "confirmed"
+	ReportStatusFalsePositive = "false_positive"
+)
+
+// ErrReportedMessageNotFound is thrown when a reported message doesn't exist.
+var ErrReportedMessageNotFound = errors.New("Reported message not found")
+
+// ErrReportStatus is thrown when a reported message is given an unknown
+// triage status.
+var ErrReportStatus = errors.New("The status must be new, confirmed, or false_positive")
+
+// ReportedMessage is an email reported to the monitored mailbox which wasn't
+// sent by a campaign, such as a real phishing email. Reported messages are
+// classified when they're received, and can then be triaged by setting their
+// status.
+type ReportedMessage struct {
+	Id           int64     `json:"id"`
+	UserId       int64     `json:"-"`
+	Reporter     string    `json:"reporter"`
+	From         string    `json:"from" gorm:"column:from_address"`
+	Subject      string    `json:"subject"`
+	MessageId    string    `json:"message_id"`
+	SentDate     time.Time `json:"sent_date"`
+	ReportedDate time.Time `json:"reported_date"`
+	// Headers are the headers of the reported message, one per line.
+	Headers        EncryptedString `json:"headers"`
+	Classification string          `json:"classification"`
+	Score          int             `json:"score"`
+	// Reasons explain the signs of phishing which contributed to the score.
+	Reasons     []string     `json:"reasons" gorm:"-"`
+	Observables []Observable `json:"observables" gorm:"-"`
+	// RawReasons and RawObservables are the JSON encodings of the reasons
+	// and observables, as stored.
+	RawReasons     string    `json:"-" gorm:"column:reasons"`
+	RawObservables string    `json:"-" gorm:"column:observables"`
+	Status         string    `json:"status"`
+	Notes          string    `json:"notes"`
+	ModifiedDate   time.Time `json:"modified_date"`
+}
+
+// ReportedMessageTriage is a request to triage a reported message.
+type ReportedMessageTriage struct {
+	Status string `json:"status"`
+	Notes  string `json:"notes"`
+}
+
+// ReportedMessageFilter selects the reported messages which are listed.
+// Empty fields match every message.
+type ReportedMessageFilter struct {
+	Status         string
+	Classification string
+}
+
+// WebhookType returns the type of the payload sent to webhooks.
+func (ReportedMessage) WebhookType() string {
+	return "reported_message"
+}
+
+// ReportedEmail returns the report pushed to incident connectors for the
+// message.
+func (rm *ReportedMessage) ReportedEmail() ReportedEmail {
+	return ReportedEmail{
+		Reporter:    rm.Reporter,
+		From:        rm.From,
+		Subject:     rm.Subject,
+		MessageId:   rm.MessageId,
+		Date:        rm.SentDate,
+		Observables: rm.Observables,
+	}
+}
+
+// BeforeSave encodes the reasons and observables for storing.
+func (rm *ReportedMessage) BeforeSave() error {
+	b, err := json.Marshal(rm.Reasons)
+	if err != nil {
+		return err
+	}
+	rm.RawReasons = string(b)
+	b, err = json.Marshal(rm.Observables)
+	if err != nil {
+		return err
+	}
+	rm.RawObservables = string(b)
+	return nil
+}
+
+// AfterFind decodes the stored reasons and observables.
+func (rm *ReportedMessage) AfterFind() error {
+	rm.Reasons = []string{}
+	rm.Observables = []Observable{}
+	if rm.RawReasons != "" {
+		err := json.Unmarshal([]byte(rm.RawReasons), &rm.Reasons)
+		if err != nil {
+			return err
+		}
+	}
+	if rm.RawObservables == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(rm.RawObservables), &rm.Observables)
+}
+
+// PostReportedMessage records a message reported to the user's mailbox, and
+// sends it to the active webhooks.
+func PostReportedMessage(rm *ReportedMessage) error {
+	rm.Id = 0
+	rm.Status = ReportStatusNew
+	if rm.ReportedDate.IsZero() {
+		rm.ReportedDate = time.Now().UTC()
+	}
+	rm.ModifiedDate = rm.ReportedDate
+	err := db.Save(rm).Error
+	if err != nil {
+		return err
+	}
+	sendWebhooks(*rm)
+	return nil
+}
+
+// GetReportedMessages returns the messages reported to the user's mailbox
+// which match the filter, most recently reported first.
+func GetReportedMessages(uid int64, f ReportedMessageFilter) ([]ReportedMessage, error) {
+	rms := []ReportedMessage{}
+	query := db.Where("user_id=?", uid)
+	if f.Status != "" {
+		query = query.Where("status=?", f.Status)
+	}
+	if f.Classification != "" {
+		query = query.Where("classification=?", f.Classification)
+	}
+	err := query.Order("reported_date desc, id desc").Find(&rms).Error
+	return rms, err
+}
+
+// GetReportedMessage returns the reported message with the given id, if it
+// was reported to the given user's mailbox.
+func GetReportedMessage(id int64, uid int64) (ReportedMessage, error) {
+	rm := ReportedMessage{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&rm).Error
+	if err != nil {
+		return rm, ErrReportedMessageNotFound
+	}
+	return rm, nil
+}
+
+// TriageReportedMessage sets the status and notes of the reported message.
+func TriageReportedMessage(id int64, uid int64, t ReportedMessageTriage) (ReportedMessage, error) {
+	rm, err := GetReportedMessage(id, uid)
+	if err != nil {
+		return rm, err
+	}
+	switch t.Status {
+	case ReportStatusNew, ReportStatusConfirmed, ReportStatusFalsePositive:
+	default:
+		return rm, ErrReportStatus
+	}
+	rm.Status = t.Status
+	rm.Notes = t.Notes
+	rm.ModifiedDate = time.Now().UTC()
+	err = db.Save(&rm).Error
+	return rm, err
+}
+
+// DeleteReportedMessage deletes the reported message with the given id.
+func DeleteReportedMessage(id int64, uid int64) error {
+	_, err := GetReportedMessage(id, uid)
+	if err != nil {
+		return err
+	}
+	return db.Where("id=? and user_id=?", id, uid).Delete(&ReportedMessage{}).Error
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestReportedMessages(ch *check.C) {
+	observables := []Observable{{Type: ObservableURL, Value: "http://198.51.100.7/"}}
+	phish := ReportedMessage{UserId: 1, Reporter: "victim@example.com", From: "attacker@example.net", Subject: "Urgent",
+		Classification: ReportClassLikelyPhish, Score: 6, Reasons: []string{"DMARC check failed"}, Observables: observables}
+	ch.Assert(PostReportedMessage(&phish), check.Equals, nil)
+	ch.Assert(phish.Status, check.Equals, ReportStatusNew)
+	newsletter := ReportedMessage{UserId: 1, Reporter: "victim@example.com", From: "news@example.org", Subject: "Weekly news",
+		Classification: ReportClassLowRisk}
+	ch.Assert(PostReportedMessage(&newsletter), check.Equals, nil)
+
+	// The reasons and observables are stored with the message
+	got, err := GetReportedMessage(phish.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Reasons, check.DeepEquals, phish.Reasons)
+	ch.Assert(got.Observables, check.DeepEquals, observables)
+	ch.Assert(got.ReportedEmail().Observables, check.DeepEquals, observables)
+
+	rms, err := GetReportedMessages(1, ReportedMessageFilter{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rms), check.Equals, 2)
+	rms, err = GetReportedMessages(1, ReportedMessageFilter{Classification: ReportClassLikelyPhish})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rms), check.Equals, 1)
+	ch.Assert(rms[0].Id, check.Equals, phish.Id)
+
+	// Triaging sets the status, which can be filtered on
+	_, err = TriageReportedMessage(newsletter.Id, 1, ReportedMessageTriage{Status: "ignored"})
+	ch.Assert(err, check.Equals, ErrReportStatus)
+	rm, err := TriageReportedMessage(newsletter.Id, 1, ReportedMessageTriage{Status: ReportStatusFalsePositive, Notes: "Marketing email"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rm.Notes, check.Equals, "Marketing email")
+	rms, err = GetReportedMessages(1, ReportedMessageFilter{Status: ReportStatusNew})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rms), check.Equals, 1)
+	ch.Assert(rms[0].Id, check.Equals, phish.Id)
+
+	// Messages are only visible to the owner of the mailbox
+	_, err = GetReportedMessage(phish.Id, 2)
+	ch.Assert(err, check.Equals, ErrReportedMessageNotFound)
+	ch.Assert(DeleteReportedMessage(phish.Id, 2), check.Equals, ErrReportedMessageNotFound)
+	ch.Assert(DeleteReportedMessage(phish.Id, 1), check.Equals, nil)
+	_, err = GetReportedMessage(phish.Id, 1)
+	ch.Assert(err, check.Equals, ErrReportedMessageNotFound)
+}
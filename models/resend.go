@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCampaignArchived is returned when re-sending emails for an archived
+// campaign, since its results can no longer be updated.
+var ErrCampaignArchived = errors.New("Emails can't be re-sent for archived campaigns")
+
+// ErrCampaignAnonymized is returned when re-sending emails for a campaign
+// running in privacy mode, since the recipients of failed emails have
+// already been anonymized.
+var ErrCampaignAnonymized = errors.New("Emails can't be re-sent for campaigns running in privacy mode")
+
+// ResendRequest is a request to re-send the emails which failed to send in a
+// campaign.
+type ResendRequest struct {
+	// SMTP is the name of the sending profile to re-send the emails with. It
+	// defaults to the campaign's sending profile.
+	SMTP string `json:"smtp"`
+}
+
+// ResendResult is the outcome of re-sending failed emails.
+type ResendResult struct {
+	Queued int `json:"queued"`
+}
+
+// ResendFailedEmails queues the emails which failed to send in the campaign
+// to be sent again. The results keep their ids and events, so any links in
+// the re-sent emails are tracked against the original results. Completed
+// campaigns are re-opened so that the new emails are tracked.
+func ResendFailedEmails(cid int64, uid int64, req ResendRequest) (ResendResult, error) {
+	rr := ResendResult{}
+	c := Campaign{}
+	err := db.Where("id = ? AND user_id = ?", cid, uid).Find(&c).Error
+	if err != nil {
+		return rr, err
+	}
+	if !c.ArchivedDate.IsZero() {
+		return rr, ErrCampaignArchived
+	}
+	if c.Anonymize {
+		return rr, ErrCampaignAnonymized
+	}
+	var smtpId int64
+	if req.SMTP != "" {
+		s, err := GetSMTPByName(req.SMTP, uid)
+		if err == gorm.ErrRecordNotFound {
+			return rr, ErrSMTPNotFound
+		} else if err != nil {
+			return rr, err
+		}
+		smtpId = s.Id
+	}
+	rs := []Result{}
+	err = db.Where("campaign_id = ? AND status = ?", cid, Error).Find(&rs).Error
+	if err != nil {
+		return rr, err
+	}
+	if len(rs) == 0 {
+		return rr, nil
+	}
+	now := time.Now().UTC()
+	tx := db.Begin()
+	for _, r := range rs {
+		// Skip any results which are already queued to be re-sent
+		count := 0
+		err = tx.Model(&MailLog{}).Where("r_id = ?", r.RId).Count(&count).Error
+		if err != nil {
+			tx.Rollback()
+			return rr, err
+		}
+		if count > 0 {
+			continue
+		}
+		m := &MailLog{
+			UserId:     uid,
+			CampaignId: cid,
+			RId:        r.RId,
+			SendDate:   now,
+			SMTPId:     smtpId,
+			TemplateId: r.TemplateId,
+		}
+		err = tx.Save(m).Error
+		if err != nil {
+			tx.Rollback()
+			return rr, err
+		}
+		err = tx.Table("results").Where("id = ?", r.Id).Updates(map[string]interface{}{
+			"status":        StatusSending,
+			"modified_date": now,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return rr, err
+		}
+		rr.Queued++
+	}
+	if rr.Queued > 0 && c.Status == CampaignComplete {
+		err = tx.Table("campaigns").Where("id = ?", cid).Updates(map[string]interface{}{
+			"status":         CampaignInProgress,
+			"completed_date": time.Time{},
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return rr, err
+		}
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return rr, err
+	}
+	log.WithFields(logrus.Fields{
+		"campaign_id": cid,
+		"num_emails":  rr.Queued,
+	}).Info("Re-sending failed emails")
+	return rr, nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+// failFirstMailLog marks the email to the campaign's first result as failed.
+func failFirstMailLog(ch *check.C, c Campaign) Result {
+	m := &MailLog{}
+	err := db.Where("r_id=?", c.Results[0].RId).Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+	err = m.Error(errors.New("550 mailbox unavailable"))
+	ch.Assert(err, check.Equals, nil)
+	r, err := GetResult(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Status, check.Equals, Error)
+	return r
+}
+
+func (s *ModelsSuite) TestResendFailedEmails(ch *check.C) {
+	c := s.createCampaign(ch)
+	failed := failFirstMailLog(ch, c)
+	// The other emails are sent successfully
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	for _, m := range ms {
+		ch.Assert(m.Success(), check.Equals, nil)
+	}
+
+	rr, err := ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rr.Queued, check.Equals, 1)
+
+	ms, err = GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, 1)
+	ch.Assert(ms[0].RId, check.Equals, failed.RId)
+	ch.Assert(ms[0].SMTPId, check.Equals, int64(0))
+
+	r, err := GetResult(failed.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Status, check.Equals, StatusSending)
+
+	// The existing events are kept
+	c, err = GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(c.Events[1].Message, check.Equals, EventSendingError)
+
+	// Re-sending again doesn't queue the email twice
+	ms[0].Error(errors.New("550 mailbox unavailable"))
+	rr, err = ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rr.Queued, check.Equals, 1)
+	rr, err = ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rr.Queued, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestResendFailedEmailsProfile(ch *check.C) {
+	c := s.createCampaign(ch)
+	failed := failFirstMailLog(ch, c)
+
+	_, err := ResendFailedEmails(c.Id, c.UserId, ResendRequest{SMTP: "Missing"})
+	ch.Assert(err, check.Equals, ErrSMTPNotFound)
+
+	smtp := SMTP{Name: "Backup", UserId: c.UserId, Host: "backup.example.com:25", FromAddress: "backup@example.com"}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+	rr, err := ResendFailedEmails(c.Id, c.UserId, ResendRequest{SMTP: "Backup"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rr.Queued, check.Equals, 1)
+
+	m := &MailLog{}
+	err = db.Where("r_id=?", failed.RId).Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(m.SMTPId, check.Equals, smtp.Id)
+
+	// The email is sent using the backup profile
+	mc, err := GetMailLogContext(m)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(mc.SMTP.Host, check.Equals, "backup.example.com:25")
+	email := s.emailFromFirstMailLog(c, ch)
+	ch.Assert(email.From, check.Equals, "backup@example.com")
+}
+
+func (s *ModelsSuite) TestResendFailedEmailsCompleted(ch *check.C) {
+	c := s.createCampaign(ch)
+	failFirstMailLog(ch, c)
+	ch.Assert(CompleteCampaign(c.Id, c.UserId), check.Equals, nil)
+
+	rr, err := ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rr.Queued, check.Equals, 1)
+	c, err = GetCampaign(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(c.Status, check.Equals, CampaignInProgress)
+	ch.Assert(c.CompletedDate.IsZero(), check.Equals, true)
+
+	// Archived campaigns can't be re-sent
+	err = db.Table("campaigns").Where("id=?", c.Id).Update("archived_date", time.Now().UTC()).Error
+	ch.Assert(err, check.Equals, nil)
+	_, err = ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, ErrCampaignArchived)
+}
+
+func (s *ModelsSuite) TestResendFailedEmailsAnonymized(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.Anonymize = true
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	failed := failFirstMailLog(ch, c)
+	ch.Assert(failed.Email, check.Equals, AnonymizeEmail(c.Results[0].Email))
+
+	// The failed recipients can't be re-sent, since their addresses are gone
+	_, err := ResendFailedEmails(c.Id, c.UserId, ResendRequest{})
+	ch.Assert(err, check.Equals, ErrCampaignAnonymized)
+	r, err := GetResult(failed.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Status, check.Equals, Error)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"math/big"
 	"net"
+	"strings"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
@@ -12,6 +13,9 @@ import (
 	"github.com/oschwald/maxminddb-golang"
 )
 
+// geoDBPath is the path of the database used to geolocate IP addresses
+const geoDBPath = "static/db/geolite2-city.mmdb"
+
 type mmCity struct {
 	GeoPoint mmGeoPoint `maxminddb:"location"`
 }
@@ -34,20 +38,82 @@ type Result struct {
 	Longitude    float64   `json:"longitude"`
 	SendDate     time.Time `json:"send_date"`
 	Reported     bool      `json:"reported" sql:"not null"`
-	ModifiedDate time.Time `json:"modified_date"`
+	Forwarded    bool      `json:"forwarded" sql:"not null"`
+	AutoReplied  bool      `json:"auto_replied" sql:"not null"`
+	Unsubscribed bool      `json:"unsubscribed" sql:"not null"`
+	MailClient   string    `json:"mail_client"`
+	// MachineOpened and HumanOpened record whether the email was opened by
+	// a mail privacy proxy, and whether it was opened any other way.
+	MachineOpened bool      `json:"machine_opened" sql:"not null"`
+	HumanOpened   bool      `json:"human_opened" sql:"not null"`
+	ModifiedDate  time.Time `json:"modified_date"`
+	// ClickCount and SubmitCount are the number of times the recipient
+	// clicked the link and submitted data, including the events which
+	// weren't stored because of the campaign's cap on duplicate events.
+	ClickCount      int        `json:"click_count"`
+	FirstClickDate  *time.Time `json:"first_click_date"`
+	LastClickDate   *time.Time `json:"last_click_date"`
+	SubmitCount     int        `json:"submit_count"`
+	FirstSubmitDate *time.Time `json:"first_submit_date"`
+	LastSubmitDate  *time.Time `json:"last_submit_date"`
+	// Tags and Notes are added by analysts. Tags are stored as a comma
+	// separated list.
+	Tags  string `json:"tags"`
+	Notes string `json:"notes"`
+	// TargetTags are the tags the recipient had when they were added to
+	// the campaign, as a comma separated list.
+	TargetTags string `json:"target_tags"`
+	// MessageId is the Message-ID header of the email sent to the
+	// recipient.
+	MessageId string `json:"message_id,omitempty"`
+	// Wave is the name of the campaign wave the recipient was sent.
+	Wave string `json:"wave,omitempty"`
+	// SequenceRId is the id of the result which started the recipient's
+	// follow-up sequence, and SequenceStep is the step of the sequence the
+	// recipient was sent. Both are empty for the campaign's first email.
+	SequenceRId  string `json:"sequence_id,omitempty"`
+	SequenceStep int    `json:"sequence_step,omitempty"`
+	// TemplateId is the template the recipient's wave or sequence step is
+	// sent with, if it isn't the campaign's. It's saved with the result so
+	// that failed emails are re-sent with the same template.
+	TemplateId int64 `json:"-"`
 	BaseRecipient
 }
 
 func (r *Result) createEvent(status string, details interface{}) (*Event, error) {
 	e := &Event{Email: r.Email, Message: status}
+	if d, ok := details.(EventDetails); ok {
+		e.RequestId = d.RequestId
+	}
+	store, err := r.countEvent(status)
+	if err != nil {
+		return nil, err
+	}
+	if !store {
+		e.CampaignId = r.CampaignId
+		e.Time = time.Now().UTC()
+		return e, nil
+	}
+	// Campaigns running in privacy mode only store pseudonymized events
+	if isCampaignAnonymized(r.CampaignId) {
+		e.Email = AnonymizeEmail(r.Email)
+		details = anonymizeDetails(details)
+	}
 	if details != nil {
 		dj, err := json.Marshal(details)
 		if err != nil {
 			return nil, err
 		}
-		e.Details = string(dj)
+		e.Details = EncryptedString(dj)
+	}
+	suppressed := AddEvent(e, r.CampaignId) == ErrEventSuppressed
+	if err := r.updateMailClient(details); err != nil {
+		log.Error(err)
+	}
+	// Store the raw request separately if one was captured
+	if d, ok := details.(EventDetails); ok && d.Request != nil && !suppressed {
+		saveEventRequest(d.Request, e)
 	}
-	AddEvent(e, r.CampaignId)
 	return e, nil
 }
 
@@ -61,25 +127,43 @@ func (r *Result) HandleEmailSent() error {
 	r.SendDate = event.Time
 	r.Status = EventSent
 	r.ModifiedDate = event.Time
+	// Now that the email has been sent, we no longer need to know who the
+	// recipient is for campaigns running in privacy mode.
+	if isCampaignAnonymized(r.CampaignId) {
+		r.anonymize()
+	}
 	return db.Save(r).Error
 }
 
 // HandleEmailError updates a Result to indicate that there was an error when
 // attempting to send the email to the remote SMTP server.
 func (r *Result) HandleEmailError(err error) error {
-	event, err := r.createEvent(EventSendingError, EventError{Error: err.Error()})
+	return r.handleEmailError(err, "")
+}
+
+// handleEmailError records the error along with the SMTP transcript, if there
+// is one.
+func (r *Result) handleEmailError(err error, transcript string) error {
+	event, err := r.createEvent(EventSendingError, EventError{Error: err.Error(), Transcript: transcript})
 	if err != nil {
 		return err
 	}
 	r.Status = Error
 	r.ModifiedDate = event.Time
+	if isCampaignAnonymized(r.CampaignId) {
+		r.anonymize()
+	}
 	return db.Save(r).Error
 }
 
 // HandleEmailBackoff updates a Result to indicate that the email received a
 // temporary error and needs to be retried
 func (r *Result) HandleEmailBackoff(err error, sendDate time.Time) error {
-	event, err := r.createEvent(EventSendingError, EventError{Error: err.Error()})
+	return r.handleEmailBackoff(err, sendDate, "")
+}
+
+func (r *Result) handleEmailBackoff(err error, sendDate time.Time, transcript string) error {
+	event, err := r.createEvent(EventSendingError, EventError{Error: err.Error(), Transcript: transcript})
 	if err != nil {
 		return err
 	}
@@ -92,17 +176,22 @@ func (r *Result) HandleEmailBackoff(err error, sendDate time.Time) error {
 // HandleEmailOpened updates a Result in the case where the recipient opened the
 // email.
 func (r *Result) HandleEmailOpened(details EventDetails) error {
+	details = details.flagMachineOpen()
 	event, err := r.createEvent(EventOpened, details)
 	if err != nil {
 		return err
 	}
+	if details.Browser["machine-open"] == "true" {
+		r.MachineOpened = true
+	} else {
+		r.HumanOpened = true
+	}
 	// Don't update the status if the user already clicked the link
 	// or submitted data to the campaign
-	if r.Status == EventClicked || r.Status == EventDataSubmit {
-		return nil
+	if r.Status != EventClicked && r.Status != EventDataSubmit {
+		r.Status = EventOpened
+		r.ModifiedDate = event.Time
 	}
-	r.Status = EventOpened
-	r.ModifiedDate = event.Time
 	return db.Save(r).Error
 }
 
@@ -130,6 +219,9 @@ func (r *Result) HandleFormSubmit(details EventDetails) error {
 	if err != nil {
 		return err
 	}
+	if err := r.checkHoneytokens(details); err != nil {
+		log.Error(err)
+	}
 	r.Status = EventDataSubmit
 	r.ModifiedDate = event.Time
 	return db.Save(r).Error
@@ -147,11 +239,72 @@ func (r *Result) HandleEmailReport(details EventDetails) error {
 	return db.Save(r).Error
 }
 
+// HandleEmailForwarded updates a Result in the case where the recipient
+// forwarded the email to one of the monitored forwarding addresses, such as a
+// helpdesk.
+func (r *Result) HandleEmailForwarded(details EventDetails) error {
+	event, err := r.createEvent(EventForwarded, details)
+	if err != nil {
+		return err
+	}
+	r.Forwarded = true
+	r.ModifiedDate = event.Time
+	return db.Save(r).Error
+}
+
+// HandleAutoReply updates a Result in the case where the recipient's mailbox
+// sent an automatic reply, such as an out of office message.
+func (r *Result) HandleAutoReply(details EventDetails) error {
+	event, err := r.createEvent(EventAutoReplied, details)
+	if err != nil {
+		return err
+	}
+	r.AutoReplied = true
+	r.ModifiedDate = event.Time
+	return db.Save(r).Error
+}
+
+// HandleUnsubscribe updates a Result in the case where the recipient clicked
+// the unsubscribe link in the email.
+func (r *Result) HandleUnsubscribe(details EventDetails) error {
+	event, err := r.createEvent(EventUnsubscribed, details)
+	if err != nil {
+		return err
+	}
+	r.Unsubscribed = true
+	r.ModifiedDate = event.Time
+	return db.Save(r).Error
+}
+
+// HandleBlockedRequest records a request for the landing page which was
+// blocked by one of its access rules. The result's status isn't changed,
+// since the request most likely didn't come from the recipient.
+func (r *Result) HandleBlockedRequest(details EventDetails) error {
+	_, err := r.createEvent(EventBlocked, details)
+	return err
+}
+
+// HandleLinkFetched records a request for the landing page which didn't pass
+// its click challenge, such as an email gateway fetching the link to scan it.
+// The result's status isn't changed until the challenge is passed.
+func (r *Result) HandleLinkFetched(details EventDetails) error {
+	_, err := r.createEvent(EventLinkFetched, details)
+	return err
+}
+
+// HandleShortLinkClicked records a request for the recipient's short link,
+// which redirects to their full link. The result's status is changed once
+// the full link is requested.
+func (r *Result) HandleShortLinkClicked(details EventDetails) error {
+	_, err := r.createEvent(EventShortLink, details)
+	return err
+}
+
 // UpdateGeo updates the latitude and longitude of the result in
 // the database given an IP address
 func (r *Result) UpdateGeo(addr string) error {
 	// Open a connection to the maxmind db
-	mmdb, err := maxminddb.Open("static/db/geolite2-city.mmdb")
+	mmdb, err := maxminddb.Open(geoDBPath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -208,3 +361,18 @@ func GetResult(rid string) (Result, error) {
 	err := db.Where("r_id=?", rid).First(&r).Error
 	return r, err
 }
+
+// GetLatestResultByEmail returns the most recently sent result for the email
+// address in the user's running campaigns. It's used to find the result for
+// emails which don't contain a result id, such as automatic replies.
+func GetLatestResultByEmail(uid int64, email string) (Result, error) {
+	r := Result{}
+	err := db.Table("results").
+		Joins("JOIN campaigns ON campaigns.id = results.campaign_id").
+		Where("results.user_id=? AND LOWER(results.email)=? AND campaigns.status=?", uid, strings.ToLower(email), CampaignInProgress).
+		Where("results.status NOT IN (?)", []string{StatusScheduled, StatusQueued, StatusSending}).
+		Order("results.send_date desc").
+		Select("results.*").
+		First(&r).Error
+	return r, err
+}
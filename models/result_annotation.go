@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MaxResultNotes is the longest analyst notes allowed on a result.
+const MaxResultNotes = 10000
+
+// ErrResultNotesTooLong is returned when a result's notes are longer than
+// MaxResultNotes.
+var ErrResultNotesTooLong = errors.New("Notes can't be longer than 10000 characters")
+
+// ResultAnnotation is the tags and notes an analyst gives a result, such as
+// to mark it as a false positive or escalated.
+type ResultAnnotation struct {
+	Tags  []string `json:"tags"`
+	Notes string   `json:"notes"`
+}
+
+// joinTags returns the tags as a comma separated list, removing commas,
+// empty tags and duplicates.
+func joinTags(tags []string) string {
+	seen := map[string]bool{}
+	joined := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.Replace(tag, ",", "", -1))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		joined = append(joined, tag)
+	}
+	return strings.Join(joined, ",")
+}
+
+// AnnotateResult replaces the tags and notes of the result with the given
+// RId in the campaign.
+func AnnotateResult(cid int64, rid string, uid int64, a ResultAnnotation) (Result, error) {
+	r := Result{}
+	if len(a.Notes) > MaxResultNotes {
+		return r, ErrResultNotesTooLong
+	}
+	err := db.Where("campaign_id=? AND r_id=? AND user_id=?", cid, rid, uid).First(&r).Error
+	if err != nil {
+		return r, err
+	}
+	r.Tags = joinTags(a.Tags)
+	r.Notes = a.Notes
+	r.ModifiedDate = time.Now().UTC()
+	err = db.Model(&r).Updates(map[string]interface{}{
+		"tags":          r.Tags,
+		"notes":         r.Notes,
+		"modified_date": r.ModifiedDate,
+	}).Error
+	return r, err
+}
+
+// HasTag returns whether an analyst has given the result the tag.
+func (r *Result) HasTag(tag string) bool {
+	for _, t := range strings.Split(r.Tags, ",") {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// likeEscaper escapes the wildcards in LIKE patterns, using "!" as the escape
+// character since it's supported by each database.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// filterTag removes the "tag" filter from the options, returning the query
+// limited to the results which have the tag. Tags are stored as a comma
+// separated list, so they can't be filtered by equality like other fields.
+func filterTag(query *gorm.DB, opts ListOptions) (*gorm.DB, ListOptions) {
+	tag, ok := opts.Filters["tag"]
+	if !ok {
+		return query, opts
+	}
+	filters := make(map[string]string, len(opts.Filters))
+	for k, v := range opts.Filters {
+		if k != "tag" {
+			filters[k] = v
+		}
+	}
+	opts.Filters = filters
+	pattern := likeEscaper.Replace(tag)
+	query = query.Where("tags = ? OR tags LIKE ? ESCAPE '!' OR tags LIKE ? ESCAPE '!' OR tags LIKE ? ESCAPE '!'",
+		tag, pattern+",%", "%,"+pattern, "%,"+pattern+",%")
+	return query, opts
+}
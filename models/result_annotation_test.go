@@ -0,0 +1,67 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAnnotateResult(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	rid := c.Results[0].RId
+
+	r, err := AnnotateResult(c.Id, rid, c.UserId, ResultAnnotation{
+		Tags:  []string{"VIP", " false positive ", "VIP", "", "a,b"},
+		Notes: "Reported to the help desk",
+	})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Tags, check.Equals, "VIP,false positive,ab")
+
+	cr, err := GetCampaignResults(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	for _, got := range cr.Results {
+		if got.RId == rid {
+			ch.Assert(got.Tags, check.Equals, "VIP,false positive,ab")
+			ch.Assert(got.Notes, check.Equals, "Reported to the help desk")
+		}
+	}
+
+	_, err = AnnotateResult(c.Id, rid, c.UserId, ResultAnnotation{Notes: strings.Repeat("a", MaxResultNotes+1)})
+	ch.Assert(err, check.Equals, ErrResultNotesTooLong)
+	_, err = AnnotateResult(c.Id, rid, c.UserId+1, ResultAnnotation{})
+	ch.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+}
+
+func (s *ModelsSuite) TestFilterResultsByTag(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	tags := [][]string{{"VIP"}, {"escalated", "VIP"}, {"VIPs"}, {"V_P"}}
+	for i, t := range tags {
+		_, err := AnnotateResult(c.Id, c.Results[i].RId, c.UserId, ResultAnnotation{Tags: t})
+		ch.Assert(err, check.Equals, nil)
+	}
+
+	rs, page, err := ListResults(c.UserId, ListOptions{Filters: map[string]string{"tag": "VIP"}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(page.Total, check.Equals, int64(2))
+	ch.Assert(rs[0].RId, check.Equals, c.Results[0].RId)
+	ch.Assert(rs[1].RId, check.Equals, c.Results[1].RId)
+
+	// Wildcards in the tag are matched literally
+	rs, _, err = ListResults(c.UserId, ListOptions{Filters: map[string]string{"tag": "V_P"}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rs), check.Equals, 1)
+	ch.Assert(rs[0].RId, check.Equals, c.Results[3].RId)
+
+	// Only the events of the matching results are returned
+	cr, _, err := ListCampaignResults(c.Id, c.UserId, ListOptions{Filters: map[string]string{"tag": "escalated", "status": c.Results[1].Status}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cr.Results), check.Equals, 1)
+	for _, e := range cr.Events {
+		if e.Email != "" {
+			ch.Assert(e.Email, check.Equals, c.Results[1].Email)
+		}
+	}
+}
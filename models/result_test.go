@@ -3,8 +3,10 @@ package models
 import (
 	"net/mail"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/jinzhu/gorm"
 	"gopkg.in/check.v1"
 )
 
@@ -118,3 +120,91 @@ func (s *ModelsSuite) TestDuplicateResults(ch *check.C) {
 	ch.Assert(c.Results[0].Email, check.Equals, group.Targets[0].Email)
 	ch.Assert(c.Results[1].Email, check.Equals, group.Targets[2].Email)
 }
+
+func (s *ModelsSuite) TestResultMailboxEvents(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.UpdateStatus(CampaignInProgress), check.Equals, nil)
+
+	// Results aren't matched by email address until the email is sent
+	r := c.Results[0]
+	_, err := GetLatestResultByEmail(c.UserId, strings.ToUpper(r.Email))
+	ch.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+	ch.Assert(r.HandleEmailSent(), check.Equals, nil)
+	got, err := GetLatestResultByEmail(c.UserId, strings.ToUpper(r.Email))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.RId, check.Equals, r.RId)
+
+	ch.Assert(got.HandleAutoReply(EventDetails{}), check.Equals, nil)
+	ch.Assert(got.HandleEmailForwarded(EventDetails{}), check.Equals, nil)
+	ch.Assert(got.HandleUnsubscribe(EventDetails{}), check.Equals, nil)
+	got, err = GetResult(r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Status, check.Equals, EventSent)
+	ch.Assert(got.AutoReplied, check.Equals, true)
+	ch.Assert(got.Forwarded, check.Equals, true)
+	ch.Assert(got.Unsubscribed, check.Equals, true)
+
+	stats, err := getCampaignStats(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.AutoReplied, check.Equals, int64(1))
+	ch.Assert(stats.Forwarded, check.Equals, int64(1))
+	ch.Assert(stats.Unsubscribed, check.Equals, int64(1))
+	ch.Assert(stats.EmailReported, check.Equals, int64(0))
+
+	// The new events aren't sent to consumers of older schema versions
+	e := Event{Message: EventUnsubscribed}
+	ch.Assert(e.ForSchema(2), check.IsNil)
+	ch.Assert(e.ForSchema(3), check.DeepEquals, e)
+}
+
+func (s *ModelsSuite) TestIMAPForwardAddresses(ch *check.C) {
+	im := IMAP{ForwardAddresses: "Helpdesk <HelpDesk@example.com>, security@example.com"}
+	addrs, err := im.ForwardAddressList()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(addrs, check.DeepEquals, []string{"helpdesk@example.com", "security@example.com"})
+
+	im.ForwardAddresses = "not an address"
+	_, err = im.ForwardAddressList()
+	ch.Assert(err, check.Not(check.Equals), nil)
+}
+
+func (s *ModelsSuite) TestIMAPAuthMethod(ch *check.C) {
+	im := IMAP{Host: "127.0.0.1", Port: 993, Username: "phishing@example.com", Password: "password", UserId: 1}
+	ch.Assert(im.Validate(), check.Equals, nil)
+	ch.Assert(im.AuthMethod, check.Equals, IMAPAuthPassword)
+
+	im = IMAP{Host: "127.0.0.1", Port: 993, Username: "phishing@example.com", UserId: 1, AuthMethod: IMAPAuthClientCredentials}
+	ch.Assert(im.Validate(), check.Equals, ErrIMAPOAuth2ClientSecretNotSpecified)
+	im.OAuth2ClientSecret = "secret"
+	ch.Assert(im.Validate(), check.Equals, ErrIMAPOAuth2TokenURL)
+	im.OAuth2TokenURL = "https://login.microsoftonline.com/tenant/oauth2/v2.0/token"
+	ch.Assert(im.Validate(), check.Equals, ErrIMAPOAuth2ClientIDNotSpecified)
+	im.OAuth2ClientID = "client"
+	ch.Assert(im.Validate(), check.Equals, nil)
+
+	im.AuthMethod = IMAPAuthDeviceCode
+	ch.Assert(im.Validate(), check.Equals, ErrIMAPOAuth2DeviceURL)
+	im.OAuth2DeviceURL = "https://login.microsoftonline.com/tenant/oauth2/v2.0/devicecode"
+	ch.Assert(PostIMAP(&im, im.UserId), check.Equals, nil)
+	ch.Assert(SetIMAPRefreshToken(im.UserId, "refresh"), check.Equals, nil)
+
+	// The refresh token is kept when the settings are saved again
+	im = IMAP{Host: "127.0.0.1", Port: 993, Username: "phishing@example.com", UserId: 1, AuthMethod: IMAPAuthDeviceCode,
+		OAuth2TokenURL: im.OAuth2TokenURL, OAuth2DeviceURL: im.OAuth2DeviceURL, OAuth2ClientID: "client"}
+	ch.Assert(PostIMAP(&im, im.UserId), check.Equals, nil)
+	ims, err := GetIMAP(im.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(ims[0].OAuth2RefreshToken), check.Equals, "refresh")
+
+	// but not when the client changes
+	im.OAuth2RefreshToken = ""
+	im.OAuth2ClientID = "other"
+	ch.Assert(PostIMAP(&im, im.UserId), check.Equals, nil)
+	ims, err = GetIMAP(im.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(ims[0].OAuth2RefreshToken), check.Equals, "")
+
+	im.AuthMethod = "kerberos"
+	ch.Assert(im.Validate(), check.Equals, ErrInvalidIMAPAuthMethod)
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// PurgeExpiredData removes campaign data which is older than the retention
+// periods configured in the data_retention section of the config. Purging
+// event details keeps the events themselves so that campaign statistics are
+// unaffected. Completed campaigns older than the archive period are also
+// archived.
+func PurgeExpiredData(t time.Time) error {
+	if conf == nil {
+		return nil
+	}
+	retention := conf.DataRetention
+	if retention.EventDetailsDays > 0 {
+		cutoff := t.UTC().AddDate(0, 0, -retention.EventDetailsDays)
+		err := purgeEventDetails(cutoff)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	if retention.MailLogsDays > 0 {
+		cutoff := t.UTC().AddDate(0, 0, -retention.MailLogsDays)
+		err := purgeMailLogs(cutoff)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	if retention.CampaignDays > 0 {
+		cutoff := t.UTC().AddDate(0, 0, -retention.CampaignDays)
+		err := purgeCompletedCampaigns(cutoff)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	if retention.ArchiveDays > 0 {
+		cutoff := t.UTC().AddDate(0, 0, -retention.ArchiveDays)
+		err := archiveCompletedCampaigns(cutoff)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeEventDetails clears the details and removes any captured requests for
+// events which occurred before the cutoff.
+func purgeEventDetails(cutoff time.Time) error {
+	query := db.Table("events").Where("time < ? AND details <> ?", cutoff, "")
+	result := query.UpdateColumn("details", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	archived := db.Table("archived_events").Where("time < ? AND details <> ?", cutoff, "").UpdateColumn("details", "")
+	if archived.Error != nil {
+		return archived.Error
+	}
+	result.RowsAffected += archived.RowsAffected
+	err := db.Where("created_date < ?", cutoff).Delete(&EventRequest{}).Error
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected > 0 {
+		log.WithFields(logrus.Fields{
+			"events": result.RowsAffected,
+			"cutoff": cutoff,
+		}).Info("Purged expired event details")
+	}
+	return nil
+}
+
+// purgeMailLogs removes maillogs which were scheduled to be sent before the
+// cutoff, and aren't currently being processed.
+func purgeMailLogs(cutoff time.Time) error {
+	result := db.Where("send_date < ? AND processing = ?", cutoff, false).Delete(&MailLog{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.WithFields(logrus.Fields{
+			"maillogs": result.RowsAffected,
+			"cutoff":   cutoff,
+		}).Info("Purged expired maillogs")
+	}
+	return nil
+}
+
+// RetentionPurgeReason is the reason recorded when campaign data is purged by
+// the scheduled retention job.
+const RetentionPurgeReason = "Data retention period expired"
+
+// CampaignPurge is an audit record created each time the sensitive data for a
+// campaign is purged. A UserId of zero indicates the purge was performed by
+// the scheduled retention job.
+type CampaignPurge struct {
+	Id             int64     `json:"id"`
+	CampaignId     int64     `json:"campaign_id"`
+	UserId         int64     `json:"user_id"`
+	Reason         string    `json:"reason"`
+	EventsPurged   int64     `json:"events_purged"`
+	RequestsPurged int64     `json:"requests_purged"`
+	PurgedDate     time.Time `json:"purged_date"`
+}
+
+// GetCampaignPurges returns the purge audit records for the campaign with the
+// given id, provided it's owned by the given user.
+func GetCampaignPurges(cid int64, uid int64) ([]CampaignPurge, error) {
+	cps := []CampaignPurge{}
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id").Where("id=? and user_id=?", cid, uid).Find(&c).Error
+	if err != nil {
+		return cps, err
+	}
+	err = db.Where("campaign_id=?", c.Id).Order("purged_date asc").Find(&cps).Error
+	return cps, err
+}
+
+// PurgeCampaignData irreversibly removes the captured credentials, event
+// details, and raw requests for the campaign with the given id. The events
+// and results themselves are kept, so the campaign statistics are unchanged.
+//
+// An audit record of the purge is stored and returned.
+func PurgeCampaignData(cid int64, uid int64, reason string) (CampaignPurge, error) {
+	c := Campaign{}
+	err := db.Table("campaigns").Select("id").Where("id=? and user_id=?", cid, uid).Find(&c).Error
+	if err != nil {
+		return CampaignPurge{}, err
+	}
+	return purgeCampaignData(c.Id, uid, reason)
+}
+
+func purgeCampaignData(cid int64, uid int64, reason string) (CampaignPurge, error) {
+	cp := CampaignPurge{
+		CampaignId: cid,
+		UserId:     uid,
+		Reason:     reason,
+		PurgedDate: time.Now().UTC(),
+	}
+	// Beginning the transaction waits for sqlite's write lock, which can
+	// time out while other writes are in progress.
+	tx := db.Begin()
+	if tx.Error != nil {
+		log.Error(tx.Error)
+		return cp, tx.Error
+	}
+	// Ensure SQLite overwrites the deleted content rather than leaving it in
+	// free pages of the database file. The setting applies to the
+	// connection, so it's made using the transaction's connection.
+	if conf != nil && conf.DBName == "sqlite3" {
+		err := tx.Exec("PRAGMA secure_delete = ON").Error
+		if err != nil {
+			tx.Rollback()
+			log.Error(err)
+			return cp, err
+		}
+	}
+	result := tx.Table("events").Where("campaign_id=? AND details <> ?", cid, "").UpdateColumn("details", "")
+	if result.Error != nil {
+		tx.Rollback()
+		log.Error(result.Error)
+		return cp, result.Error
+	}
+	cp.EventsPurged = result.RowsAffected
+	result = tx.Table("archived_events").Where("campaign_id=? AND details <> ?", cid, "").UpdateColumn("details", "")
+	if result.Error != nil {
+		tx.Rollback()
+		log.Error(result.Error)
+		return cp, result.Error
+	}
+	cp.EventsPurged += result.RowsAffected
+	result = tx.Where("campaign_id=?", cid).Delete(&EventRequest{})
+	if result.Error != nil {
+		tx.Rollback()
+		log.Error(result.Error)
+		return cp, result.Error
+	}
+	cp.RequestsPurged = result.RowsAffected
+	err := tx.Save(&cp).Error
+	if err != nil {
+		tx.Rollback()
+		log.Error(err)
+		return cp, err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		log.Error(err)
+		return cp, err
+	}
+	log.WithFields(logrus.Fields{
+		"campaign_id":     cid,
+		"user_id":         uid,
+		"events_purged":   cp.EventsPurged,
+		"requests_purged": cp.RequestsPurged,
+	}).Info("Purged campaign data")
+	return cp, nil
+}
+
+// purgeCompletedCampaigns purges the data for campaigns that were completed
+// before the cutoff and haven't been purged since.
+func purgeCompletedCampaigns(cutoff time.Time) error {
+	cs := []Campaign{}
+	err := db.Table("campaigns").Select("id").
+		Where("status = ? AND completed_date < ?", CampaignComplete, cutoff).
+		Where("id NOT IN (SELECT campaign_id FROM campaign_purges WHERE purged_date >= campaigns.completed_date)").
+		Find(&cs).Error
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		_, err = purgeCampaignData(c.Id, 0, RetentionPurgeReason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestPurgeExpiredData(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	ch.Assert(result.HandleClickedLink(EventDetails{Browser: map[string]string{"address": "127.0.0.1"}}), check.Equals, nil)
+
+	// Move every event back in time so that it falls outside the retention
+	// period.
+	past := time.Now().UTC().AddDate(0, 0, -10)
+	ch.Assert(db.Table("events").Where("campaign_id=?", campaign.Id).UpdateColumn("time", past).Error, check.Equals, nil)
+
+	original := conf.DataRetention
+	defer func() { conf.DataRetention = original }()
+
+	// Nothing should be purged if retention is disabled
+	conf.DataRetention = config.DataRetention{}
+	ch.Assert(PurgeExpiredData(time.Now()), check.Equals, nil)
+	count := 0
+	db.Table("events").Where("campaign_id=? and details <> ?", campaign.Id, "").Count(&count)
+	ch.Assert(count, check.Not(check.Equals), 0)
+
+	conf.DataRetention = config.DataRetention{EventDetailsDays: 7, MailLogsDays: 7}
+	ch.Assert(PurgeExpiredData(time.Now()), check.Equals, nil)
+	db.Table("events").Where("campaign_id=? and details <> ?", campaign.Id, "").Count(&count)
+	ch.Assert(count, check.Equals, 0)
+
+	// The events themselves should be kept so stats are unaffected
+	db.Table("events").Where("campaign_id=?", campaign.Id).Count(&count)
+	ch.Assert(count, check.Not(check.Equals), 0)
+
+	// The maillogs were scheduled at launch, which is within the retention
+	// period, so they should remain.
+	ms, err := GetMailLogsByCampaign(campaign.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, len(campaign.Results))
+}
+
+func (s *ModelsSuite) TestPurgeCampaignData(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	result := campaign.Results[0]
+	details := EventDetails{
+		Payload: map[string][]string{"password": []string{"secret"}},
+		Request: &EventRequest{Method: "POST", Body: "password=secret"},
+	}
+	ch.Assert(result.HandleFormSubmit(details), check.Equals, nil)
+	before, err := GetCampaignSummary(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+
+	// Campaigns owned by other users can't be purged
+	_, err = PurgeCampaignData(campaign.Id, campaign.UserId+1, "")
+	ch.Assert(err, check.NotNil)
+
+	cp, err := PurgeCampaignData(campaign.Id, campaign.UserId, "Works council request")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(cp.RequestsPurged, check.Equals, int64(1))
+	ch.Assert(cp.EventsPurged > 0, check.Equals, true)
+
+	count := 0
+	db.Table("events").Where("campaign_id=? and details <> ?", campaign.Id, "").Count(&count)
+	ch.Assert(count, check.Equals, 0)
+	ers, err := GetEventRequests(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ers), check.Equals, 0)
+
+	// The aggregate stats should be unaffected
+	after, err := GetCampaignSummary(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(after.Stats, check.Equals, before.Stats)
+
+	cps, err := GetCampaignPurges(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cps), check.Equals, 1)
+	ch.Assert(cps[0].Reason, check.Equals, "Works council request")
+}
+
+func (s *ModelsSuite) TestPurgeCompletedCampaigns(ch *check.C) {
+	campaign := s.createCampaign(ch)
+	ch.Assert(CompleteCampaign(campaign.Id, campaign.UserId), check.Equals, nil)
+	past := time.Now().UTC().AddDate(0, 0, -31)
+	ch.Assert(db.Table("campaigns").Where("id=?", campaign.Id).UpdateColumn("completed_date", past).Error, check.Equals, nil)
+
+	original := conf.DataRetention
+	defer func() { conf.DataRetention = original }()
+	conf.DataRetention = config.DataRetention{CampaignDays: 30}
+
+	ch.Assert(PurgeExpiredData(time.Now()), check.Equals, nil)
+	cps, err := GetCampaignPurges(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cps), check.Equals, 1)
+	ch.Assert(cps[0].UserId, check.Equals, int64(0))
+	ch.Assert(cps[0].Reason, check.Equals, RetentionPurgeReason)
+
+	// Campaigns should only be purged once by the retention job
+	ch.Assert(PurgeExpiredData(time.Now()), check.Equals, nil)
+	cps, err = GetCampaignPurges(campaign.Id, campaign.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(cps), check.Equals, 1)
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+
+	"github.com/gophish/gomail"
+	"github.com/jordan-wright/email"
+)
+
+// SampleRecipient is the recipient used when rendering a template if no
+// recipient is given.
+var SampleRecipient = BaseRecipient{
+	FirstName: "John",
+	LastName:  "Doe",
+	Email:     "jdoe@example.com",
+	Position:  "Employee",
+}
+
+// SampleEmailRequest describes how to render a template as it would be sent
+// to a recipient. Every field is optional.
+type SampleEmailRequest struct {
+	// SMTPId is the sending profile whose from address and custom headers
+	// are used.
+	SMTPId int64 `json:"smtp_id"`
+	// FromAddress overrides the sending profile's from address.
+	FromAddress string `json:"from_address"`
+	// URL is the phishing URL used for the {{.URL}} template variable.
+	URL string `json:"url"`
+	BaseRecipient
+}
+
+// RenderSampleEmail renders the template as a raw email, including its
+// headers, for the recipient in the request. Tracking links use a preview
+// result id, so clicks on them aren't recorded against a campaign.
+func RenderSampleEmail(t Template, req SampleEmailRequest, uid int64) ([]byte, error) {
+	s, err := newSampleEmail(t, req, uid)
+	if err != nil {
+		return nil, err
+	}
+	return s.render()
+}
+
+// newSampleEmail returns the request used to render a sample email,
+// filling in the defaults.
+func newSampleEmail(t Template, req SampleEmailRequest, uid int64) (*EmailRequest, error) {
+	s := &EmailRequest{
+		Template:      t,
+		TemplateId:    t.Id,
+		URL:           req.URL,
+		UserId:        uid,
+		FromAddress:   req.FromAddress,
+		BaseRecipient: req.BaseRecipient,
+	}
+	if s.Email == "" {
+		s.BaseRecipient = SampleRecipient
+	}
+	if req.SMTPId != 0 {
+		smtp, err := GetSMTP(req.SMTPId, uid)
+		if err != nil {
+			return nil, err
+		}
+		s.SMTP = smtp
+		if s.FromAddress == "" {
+			s.FromAddress = smtp.FromAddress
+		}
+	}
+	if s.FromAddress == "" {
+		return nil, ErrFromAddressNotSpecified
+	}
+	rid, err := generateResultId()
+	if err != nil {
+		return nil, err
+	}
+	s.RId = fmt.Sprintf("%s%s", PreviewPrefix, rid)
+	return s, nil
+}
+
+// render returns the raw email, including its headers.
+func (s *EmailRequest) render() ([]byte, error) {
+	msg := gomail.NewMessage()
+	err := s.Generate(msg)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	_, err = msg.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+	return s.RewriteMessage(buf.Bytes())
+}
+
+// EmailPreview is a template rendered as the recipient would receive it.
+type EmailPreview struct {
+	Subject string   `json:"subject"`
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	// Headers are the remaining headers of the email, such as the
+	// Message-Id and any custom headers from the sending profile.
+	Headers     map[string][]string `json:"headers"`
+	Text        string              `json:"text"`
+	HTML        string              `json:"html"`
+	Attachments []AttachmentPreview `json:"attachments"`
+	// Warnings describe internationalized addresses and links which may
+	// not be delivered or shown as expected.
+	Warnings []string `json:"warnings"`
+}
+
+// AttachmentPreview describes an attachment of a rendered email.
+type AttachmentPreview struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Size   int    `json:"size"`
+	Inline bool   `json:"inline"`
+}
+
+// PreviewSampleEmail renders the template for the recipient in the request,
+// returning the parts of the email.
+func PreviewSampleEmail(t Template, req SampleEmailRequest, uid int64) (*EmailPreview, error) {
+	s, err := newSampleEmail(t, req, uid)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := s.render()
+	if err != nil {
+		return nil, err
+	}
+	e, err := email.NewEmailFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	p := &EmailPreview{
+		Subject:     e.Subject,
+		From:        e.From,
+		To:          e.To,
+		Headers:     e.Headers,
+		Text:        string(e.Text),
+		HTML:        string(e.HTML),
+		Attachments: []AttachmentPreview{},
+		Warnings:    s.warnings(),
+	}
+	for _, a := range e.Attachments {
+		p.Attachments = append(p.Attachments, AttachmentPreview{
+			Name: a.Filename,
+			Type: a.Header.Get("Content-Type"),
+			Size: len(a.Content),
+		})
+	}
+	// The parsed email only contains the attached files, so the inline
+	// images are described using the template.
+	for _, a := range t.Attachments {
+		if !a.Inline {
+			continue
+		}
+		content, err := a.Open()
+		if err != nil {
+			return nil, err
+		}
+		size, err := io.Copy(ioutil.Discard, content)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+		p.Attachments = append(p.Attachments, AttachmentPreview{
+			Name:   a.Name,
+			Type:   a.Type,
+			Size:   int(size),
+			Inline: true,
+		})
+	}
+	return p, nil
+}
+
+// warnings returns warnings about the internationalized addresses and links
+// in the email.
+func (s *EmailRequest) warnings() []string {
+	warnings := []string{}
+	if from, err := mail.ParseAddress(s.FromAddress); err == nil {
+		warnings = append(warnings, addressWarnings("sender", from.Address)...)
+	}
+	warnings = append(warnings, addressWarnings("recipient", s.Email)...)
+	if u, err := url.Parse(s.URL); err == nil {
+		warnings = append(warnings, domainWarnings("link", u.Hostname())...)
+	}
+	return warnings
+}
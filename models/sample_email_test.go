@@ -0,0 +1,87 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+
+	"github.com/gophish/gophish/config"
+	"github.com/jordan-wright/email"
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestRenderSampleEmail(ch *check.C) {
+	t := Template{
+		Name:    "Sample Template",
+		Subject: "Hello {{.FirstName}}",
+		Text:    "{{.URL}} {{.Email}}",
+		HTML:    "<a href=\"{{.URL}}\">{{.FirstName}} {{.LastName}}</a>",
+		UserId:  1,
+	}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+
+	_, err := RenderSampleEmail(t, SampleEmailRequest{}, 1)
+	ch.Assert(err, check.Equals, ErrFromAddressNotSpecified)
+
+	smtp := SMTP{
+		Name:        "Sample Profile",
+		Host:        "127.0.0.1:25",
+		FromAddress: "Sender <sender@example.com>",
+		Headers:     []Header{Header{Key: "X-Sample", Value: "{{.Email}}"}},
+		UserId:      1,
+	}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+
+	raw, err := RenderSampleEmail(t, SampleEmailRequest{SMTPId: smtp.Id, URL: "http://example.com"}, 1)
+	ch.Assert(err, check.Equals, nil)
+	got, err := email.NewEmailFromReader(bytes.NewReader(raw))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Hello John")
+	ch.Assert(got.From, check.Equals, "\"Sender\" <sender@example.com>")
+	ch.Assert(got.Headers.Get("X-Sample"), check.Equals, SampleRecipient.Email)
+	ch.Assert(got.Headers.Get("Message-Id"), check.Not(check.Equals), "")
+	ch.Assert(strings.HasPrefix(string(got.Text), "http://example.com?rid="+PreviewPrefix), check.Equals, true)
+
+	// The recipient and from address can be overridden
+	req := SampleEmailRequest{
+		SMTPId:        smtp.Id,
+		FromAddress:   "other@example.com",
+		BaseRecipient: BaseRecipient{FirstName: "Jane", Email: "jane@example.com"},
+	}
+	raw, err = RenderSampleEmail(t, req, 1)
+	ch.Assert(err, check.Equals, nil)
+	got, err = email.NewEmailFromReader(bytes.NewReader(raw))
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Subject, check.Equals, "Hello Jane")
+	ch.Assert(got.From, check.Equals, "other@example.com")
+	ch.Assert(got.To, check.DeepEquals, []string{"jane@example.com"})
+}
+
+func (s *ModelsSuite) TestPreviewSampleEmail(ch *check.C) {
+	t := Template{
+		Name:    "Preview Template",
+		Subject: "Hello {{.FirstName}}",
+		Text:    "Text for {{.Email}}",
+		HTML:    "<p>HTML for {{.Email}}</p>",
+		Attachments: []Attachment{
+			Attachment{Name: "notes.txt", Type: "text/plain", Content: base64.StdEncoding.EncodeToString([]byte("hello"))},
+		},
+		UserId: 1,
+	}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	defer DeleteTemplate(t.Id, 1)
+
+	req := SampleEmailRequest{FromAddress: "sender@example.com"}
+	p, err := PreviewSampleEmail(t, req, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(p.Subject, check.Equals, "Hello John")
+	ch.Assert(p.From, check.Equals, "sender@example.com")
+	ch.Assert(p.To, check.DeepEquals, []string{"\"John Doe\" <jdoe@example.com>"})
+	ch.Assert(p.Text, check.Equals, "Text for jdoe@example.com")
+	ch.Assert(p.HTML, check.Equals, "<p>HTML for jdoe@example.com</p>")
+	ch.Assert(p.Headers["X-Mailer"], check.DeepEquals, []string{config.ServerName})
+	ch.Assert(p.Attachments, check.HasLen, 1)
+	ch.Assert(p.Attachments[0].Name, check.Equals, "notes.txt")
+	ch.Assert(p.Attachments[0].Size, check.Equals, 5)
+	ch.Assert(p.Attachments[0].Type, check.Equals, "text/plain")
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gophish/gophish/secrets"
+)
+
+// ErrSecretReferenceNotAllowed is thrown when a user references a secret
+// outside of their own prefix.
+var ErrSecretReferenceNotAllowed = errors.New("Secrets can only be referenced under users/<id>/, where <id> is your user id, unless you're an administrator")
+
+// SecretPrefix returns the prefix of the secrets the user can reference.
+// Administrators can reference any secret.
+func SecretPrefix(uid int64) string {
+	return fmt.Sprintf("users/%d/", uid)
+}
+
+// checkSecretReference checks that the user is allowed to use the
+// referenced secret, so that users can't have another team's secrets sent to
+// a server they control.
+func checkSecretReference(s string, uid int64) error {
+	ref, err := secrets.ParseReference(s)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(ref.Name, SecretPrefix(uid)) {
+		return nil
+	}
+	u, err := GetUser(uid)
+	if err != nil {
+		return ErrSecretReferenceNotAllowed
+	}
+	admin, err := u.HasPermission(PermissionModifySystem)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return ErrSecretReferenceNotAllowed
+	}
+	return nil
+}
+
+// validateSecret checks that the value can be resolved by the user if it
+// references a secret.
+func validateSecret(s string, uid int64) error {
+	if !secrets.IsReference(s) {
+		return nil
+	}
+	err := secrets.Validate(s)
+	if err != nil {
+		return err
+	}
+	return checkSecretReference(s, uid)
+}
+
+// ResolveSecret returns the value of the secret referenced by the user.
+// Values which aren't references are returned unchanged.
+func ResolveSecret(s string, uid int64) (string, error) {
+	if secrets.IsReference(s) {
+		err := checkSecretReference(s, uid)
+		if err != nil {
+			return "", err
+		}
+	}
+	return secrets.Resolve(s)
+}
@@ -0,0 +1,277 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// The conditions under which a sequence step is sent. Sequences stop for
+// recipients who click the link in any of the sequence's emails.
+const (
+	// SequenceNotOpened sends the step to recipients who haven't opened any
+	// of the sequence's emails. Opens by mail privacy proxies aren't
+	// counted.
+	SequenceNotOpened = "not_opened"
+	// SequenceNotClicked sends the step to recipients who haven't clicked
+	// the link in any of the sequence's emails.
+	SequenceNotClicked = "not_clicked"
+)
+
+// ErrInvalidSequenceCondition is returned when a sequence step's condition
+// isn't one of the supported conditions.
+var ErrInvalidSequenceCondition = errors.New("Sequence steps must be sent to recipients who haven't opened or haven't clicked")
+
+// ErrInvalidSequenceDelay is returned when a sequence step isn't sent at
+// least a day after the previous email.
+var ErrInvalidSequenceDelay = errors.New("Sequence steps must be sent at least a day after the previous email")
+
+// ErrSequenceAnonymized is returned when a campaign running in privacy mode
+// has a sequence, since its recipients are anonymized once their first email
+// is sent.
+var ErrSequenceAnonymized = errors.New("Campaigns running in privacy mode can't have follow-up sequences")
+
+// SequenceStep is a reminder or variant of a campaign's email, which is sent
+// to recipients who haven't acted on the previous email in the sequence
+// within a number of days.
+type SequenceStep struct {
+	Id         int64 `json:"id"`
+	CampaignId int64 `json:"-"`
+	// Step is the position of the step in the sequence, starting at 1 for
+	// the first email after the campaign's.
+	Step int `json:"step"`
+	// Template defaults to the campaign's template.
+	TemplateId int64    `json:"-"`
+	Template   Template `json:"template" gorm:"-"`
+	// DelayDays is the number of days after the previous email was sent
+	// that the step is sent.
+	DelayDays int `json:"delay_days"`
+	// Condition defaults to SequenceNotOpened. It's stored as send_condition,
+	// since condition is reserved in MySQL.
+	Condition string `json:"condition" gorm:"column:send_condition"`
+}
+
+// validateSequence checks the delay and condition of each of the campaign's
+// sequence steps.
+func (c *Campaign) validateSequence() error {
+	if len(c.Sequence) > 0 && c.Anonymize {
+		return ErrSequenceAnonymized
+	}
+	for i := range c.Sequence {
+		s := &c.Sequence[i]
+		if s.Condition == "" {
+			s.Condition = SequenceNotOpened
+		}
+		switch {
+		case s.Condition != SequenceNotOpened && s.Condition != SequenceNotClicked:
+			return ErrInvalidSequenceCondition
+		case s.DelayDays < 1:
+			return ErrInvalidSequenceDelay
+		}
+		s.Step = i + 1
+	}
+	return nil
+}
+
+// resolveSequence looks up the templates of the campaign's sequence steps.
+func (c *Campaign) resolveSequence(uid int64) error {
+	for i := range c.Sequence {
+		s := &c.Sequence[i]
+		if s.Template.Name == "" || s.Template.Name == c.Template.Name {
+			s.Template = c.Template
+		} else {
+			t, err := GetTemplateByName(s.Template.Name, uid)
+			if err == gorm.ErrRecordNotFound {
+				log.WithFields(logrus.Fields{
+					"template": s.Template.Name,
+					"step":     s.Step,
+				}).Error("Template does not exist")
+				return ErrTemplateNotFound
+			} else if err != nil {
+				return err
+			}
+			s.Template = t
+		}
+		s.TemplateId = s.Template.Id
+	}
+	return nil
+}
+
+// saveSequence stores the sequence steps of the campaign.
+func (c *Campaign) saveSequence() error {
+	for i := range c.Sequence {
+		c.Sequence[i].Id = 0
+		c.Sequence[i].CampaignId = c.Id
+		err := db.Save(&c.Sequence[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSequence loads the sequence steps of the campaign, along with the names
+// of their templates.
+func (c *Campaign) getSequence() error {
+	c.Sequence = []SequenceStep{}
+	err := db.Where("campaign_id=?", c.Id).Order("step asc").Find(&c.Sequence).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	for i := range c.Sequence {
+		s := &c.Sequence[i]
+		err = db.Table("templates").Select("id, name").Where("id=?", s.TemplateId).Find(&s.Template).Error
+		if err == gorm.ErrRecordNotFound {
+			s.Template = Template{Name: "[Deleted]"}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sequenceRoot returns the RId of the result which started the result's
+// sequence.
+func (r *Result) sequenceRoot() string {
+	if r.SequenceRId != "" {
+		return r.SequenceRId
+	}
+	return r.RId
+}
+
+// ProcessSequences sends the sequence steps which are due by the given time
+// for each campaign in progress.
+func ProcessSequences(t time.Time) error {
+	cids := []int64{}
+	err := db.Table("sequence_steps").
+		Joins("join campaigns c ON c.id = sequence_steps.campaign_id").
+		Where("c.status = ?", CampaignInProgress).
+		Pluck("DISTINCT sequence_steps.campaign_id", &cids).Error
+	if err != nil {
+		return err
+	}
+	for _, cid := range cids {
+		err = processSequence(cid, t.UTC())
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"campaign_id": cid,
+			}).Errorf("error processing sequence: %v", err)
+		}
+	}
+	return nil
+}
+
+// sequenceRoots returns a subquery of the RIds of the results which started
+// each of the campaign's sequences which have a result matching the
+// condition.
+func sequenceRoots(cid int64, cond string, args ...interface{}) *gorm.SqlExpr {
+	return db.Table("results").
+		Select("CASE WHEN sequence_step = 0 THEN r_id ELSE sequence_r_id END").
+		Where("campaign_id = ?", cid).
+		Where(cond, args...).
+		SubQuery()
+}
+
+// clickedSequenceRoots returns a subquery of the RIds of the results which
+// started each of the campaign's sequences in which the recipient clicked the
+// link.
+func clickedSequenceRoots(cid int64) *gorm.SqlExpr {
+	return sequenceRoots(cid, "status IN (?)", []string{EventClicked, EventDataSubmit})
+}
+
+// processSequence creates the results of the campaign's sequence steps for
+// the recipients whose previous email was sent long enough ago, and who
+// haven't opened or clicked any of the sequence's emails, as required by
+// the step. Each result is linked to the result which started its sequence.
+func processSequence(cid int64, now time.Time) error {
+	steps := []SequenceStep{}
+	err := db.Where("campaign_id=?", cid).Order("step asc").Find(&steps).Error
+	if err != nil {
+		return err
+	}
+	created := 0
+	for _, s := range steps {
+		n, err := processSequenceStep(s, now)
+		created += n
+		if err != nil {
+			return err
+		}
+	}
+	if created > 0 {
+		log.WithFields(logrus.Fields{
+			"campaign_id": cid,
+			"recipients":  created,
+		}).Info("Sending campaign sequence steps")
+	}
+	return nil
+}
+
+// processSequenceStep creates the results of the sequence step for the
+// recipients who are due it, returning how many were created.
+func processSequenceStep(s SequenceStep, now time.Time) (int, error) {
+	// The first step follows up on the campaign's own results, which are
+	// the roots of their sequences
+	root := "sequence_r_id"
+	if s.Step == 1 {
+		root = "r_id"
+	}
+	due := now.AddDate(0, 0, -s.DelayDays)
+	query := db.Where("campaign_id = ? AND sequence_step = ? AND status IN (?) AND send_date <= ?",
+		s.CampaignId, s.Step-1, []string{EventSent, EventOpened}, due).
+		Where(root+" NOT IN ?", clickedSequenceRoots(s.CampaignId)).
+		Where(root+" NOT IN ?", sequenceRoots(s.CampaignId, "sequence_step >= ?", s.Step))
+	if s.Condition == SequenceNotOpened {
+		query = query.Where(root+" NOT IN ?", sequenceRoots(s.CampaignId, "human_opened = ?", true))
+	}
+	rs := []Result{}
+	err := query.Order("id asc").Find(&rs).Error
+	if err != nil {
+		return 0, err
+	}
+	results := make([]Result, len(rs))
+	for i, r := range rs {
+		results[i] = Result{
+			BaseRecipient: r.BaseRecipient,
+			Status:        StatusSending,
+			CampaignId:    r.CampaignId,
+			UserId:        r.UserId,
+			SendDate:      now,
+			ModifiedDate:  now,
+			TargetTags:    r.TargetTags,
+			Wave:          r.Wave,
+			SequenceRId:   r.sequenceRoot(),
+			SequenceStep:  s.Step,
+			TemplateId:    s.TemplateId,
+		}
+	}
+	processing := make([]bool, len(results))
+	for i := 0; i < len(results); i += LaunchBatchSize {
+		e := i + LaunchBatchSize
+		if e > len(results) {
+			e = len(results)
+		}
+		err = generateResultIds(results[i:e])
+		if err == nil {
+			err = insertResults(results[i:e], processing[i:e])
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(results), nil
+}
+
+// cloneSequence copies the sequence steps of the source campaign to the
+// clone.
+func cloneSequence(src *Campaign, c *Campaign) {
+	for _, s := range src.Sequence {
+		c.Sequence = append(c.Sequence, SequenceStep{
+			Template:  Template{Name: s.Template.Name},
+			DelayDays: s.DelayDays,
+			Condition: s.Condition,
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestSequence(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	t := Template{Name: "Reminder", Subject: "Reminder", Text: "Reminder", UserId: c.UserId}
+	ch.Assert(PostTemplate(&t), check.Equals, nil)
+	c.Groups = []Group{{Name: g.Name}}
+
+	c.Sequence = []SequenceStep{{DelayDays: 0}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidSequenceDelay)
+	c.Sequence = []SequenceStep{{DelayDays: 1, Condition: "ignored"}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidSequenceCondition)
+	c.Sequence = []SequenceStep{{DelayDays: 1}}
+	c.Anonymize = true
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrSequenceAnonymized)
+	c.Anonymize = false
+	c.Sequence = []SequenceStep{
+		{DelayDays: 2, Template: Template{Name: t.Name}},
+		{DelayDays: 3, Condition: SequenceNotClicked},
+	}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.Sequence[0].Condition, check.Equals, SequenceNotOpened)
+
+	// One recipient clicks, one opens, one only has their email opened by a
+	// mail privacy proxy, and one doesn't do anything
+	sent := time.Now().UTC()
+	rs := map[string]Result{}
+	for _, r := range c.Results {
+		rs[r.Email] = r
+		ch.Assert(db.Model(&r).Updates(map[string]interface{}{"status": EventSent, "send_date": sent}).Error, check.Equals, nil)
+	}
+	clicker, opener, proxied, ignorer := rs[g.Targets[0].Email], rs[g.Targets[1].Email], rs[g.Targets[2].Email], rs[g.Targets[3].Email]
+	ch.Assert(db.Model(&clicker).Update("status", EventClicked).Error, check.Equals, nil)
+	ch.Assert(db.Model(&opener).Updates(map[string]interface{}{"status": EventOpened, "human_opened": true}).Error, check.Equals, nil)
+	ch.Assert(db.Model(&proxied).Updates(map[string]interface{}{"status": EventOpened, "machine_opened": true}).Error, check.Equals, nil)
+
+	// Nothing is sent until the delay has passed, and each step is only
+	// sent once
+	ch.Assert(ProcessSequences(sent.AddDate(0, 0, 1)), check.Equals, nil)
+	ch.Assert(ProcessSequences(sent.AddDate(0, 0, 2)), check.Equals, nil)
+	ch.Assert(ProcessSequences(sent.AddDate(0, 0, 2)), check.Equals, nil)
+	steps := []Result{}
+	ch.Assert(db.Where("campaign_id = ? AND sequence_step = ?", c.Id, 1).Order("email asc").Find(&steps).Error, check.Equals, nil)
+	ch.Assert(len(steps), check.Equals, 2)
+	ch.Assert(steps[0].Email, check.Equals, proxied.Email)
+	ch.Assert(steps[0].SequenceRId, check.Equals, proxied.RId)
+	ch.Assert(steps[1].Email, check.Equals, ignorer.Email)
+	m := MailLog{}
+	ch.Assert(db.Where("r_id = ?", steps[0].RId).Find(&m).Error, check.Equals, nil)
+	ch.Assert(m.TemplateId, check.Equals, t.Id)
+	// Reminders aren't counted as new recipients
+	stats, err := getCampaignStats(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(stats.Total, check.Equals, int64(len(c.Results)))
+
+	// Clicking the reminder stops the sequence, while the next step is sent
+	// to recipients who haven't clicked
+	reminded := sent.AddDate(0, 0, 2)
+	ch.Assert(db.Model(&steps[0]).Updates(map[string]interface{}{"status": EventClicked, "send_date": reminded}).Error, check.Equals, nil)
+	ch.Assert(db.Model(&steps[1]).Updates(map[string]interface{}{"status": EventOpened, "human_opened": true, "send_date": reminded}).Error, check.Equals, nil)
+	ch.Assert(ProcessSequences(reminded.AddDate(0, 0, 3)), check.Equals, nil)
+	steps = []Result{}
+	ch.Assert(db.Where("campaign_id = ? AND sequence_step = ?", c.Id, 2).Find(&steps).Error, check.Equals, nil)
+	ch.Assert(len(steps), check.Equals, 1)
+	ch.Assert(steps[0].Email, check.Equals, ignorer.Email)
+	ch.Assert(steps[0].SequenceRId, check.Equals, ignorer.RId)
+}
+
+func (s *ModelsSuite) TestSequenceWaves(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	c.Groups = []Group{{Name: g.Name}}
+	later := time.Now().UTC().AddDate(0, 0, 3)
+	c.Waves = []CampaignWave{
+		{Name: "first"},
+		{Name: "retry", NonClickersOf: "first", LaunchDate: later},
+	}
+	c.Sequence = []SequenceStep{{DelayDays: 1, Condition: SequenceNotClicked}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	sent := time.Now().UTC()
+	for _, r := range c.Results {
+		ch.Assert(db.Model(&r).Updates(map[string]interface{}{"status": EventSent, "send_date": sent}).Error, check.Equals, nil)
+	}
+	ch.Assert(ProcessSequences(sent.AddDate(0, 0, 1)), check.Equals, nil)
+	reminders := []Result{}
+	ch.Assert(db.Where("campaign_id = ? AND sequence_step = ?", c.Id, 1).Order("id asc").Find(&reminders).Error, check.Equals, nil)
+	ch.Assert(len(reminders), check.Equals, len(c.Results))
+	for _, r := range reminders {
+		ch.Assert(db.Model(&r).Update("status", EventSent).Error, check.Equals, nil)
+	}
+	ch.Assert(db.Model(&reminders[0]).Update("status", EventClicked).Error, check.Equals, nil)
+
+	// The follow-up wave is sent once to each recipient who hasn't clicked
+	// the original email or its reminder
+	ch.Assert(LaunchDueWaves(later), check.Equals, nil)
+	retried := []Result{}
+	ch.Assert(db.Where("campaign_id = ? AND wave = ?", c.Id, "retry").Find(&retried).Error, check.Equals, nil)
+	ch.Assert(len(retried), check.Equals, len(c.Results)-1)
+	for _, r := range retried {
+		ch.Assert(r.Email, check.Not(check.Equals), reminders[0].Email)
+	}
+}
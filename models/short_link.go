@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrInvalidShortURL is thrown when a campaign's short URL isn't an http or
+// https URL.
+var ErrInvalidShortURL = errors.New("The short URL must be an http or https URL, such as https://go.example.com")
+
+// ErrShortLinkNotFound is thrown when a request isn't for a campaign's short
+// link.
+var ErrShortLinkNotFound = errors.New("Short link not found")
+
+// shortURLContext is implemented by template contexts which can shorten the
+// recipient's link.
+type shortURLContext interface {
+	getShortURL() string
+}
+
+// getShortURL returns the base URL of the campaign's short links, which is
+// empty if they aren't used.
+func (c *Campaign) getShortURL() string {
+	return c.ShortURL
+}
+
+func validShortURL(s string) bool {
+	if s == "" {
+		return true
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// shortLink returns the recipient's short link under the base URL.
+func shortLink(base string, rid string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	u.Host = asciiHost(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + rid
+	return u.String(), nil
+}
+
+// GetShortLinkResult returns the result whose short link was requested,
+// given the host and path of the request. The result's id is the last
+// element of the path, which must otherwise match the campaign's short URL.
+func GetShortLinkResult(host string, p string) (Result, error) {
+	rid := path.Base(p)
+	if rid == "." || rid == "/" {
+		return Result{}, ErrShortLinkNotFound
+	}
+	r, err := GetResult(rid)
+	if err != nil {
+		return r, ErrShortLinkNotFound
+	}
+	c := Campaign{}
+	err = db.Table("campaigns").Select("short_url").Where("id=?", r.CampaignId).Find(&c).Error
+	if err != nil || c.ShortURL == "" {
+		return r, ErrShortLinkNotFound
+	}
+	link, err := shortLink(c.ShortURL, rid)
+	if err != nil {
+		return r, ErrShortLinkNotFound
+	}
+	u, _ := url.Parse(link)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if !strings.EqualFold(u.Hostname(), asciiDomain(host)) || u.Path != strings.TrimRight(p, "/") {
+		return r, ErrShortLinkNotFound
+	}
+	return r, nil
+}
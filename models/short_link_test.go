@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestCampaignShortLinks(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	c.URL = "https://phish.example.com/login"
+	c.ShortURL = "ftp://go.example.com"
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, ErrInvalidShortURL)
+	c.ShortURL = "https://go.example.com/"
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	r := c.Results[0]
+
+	ptx, err := NewPhishingTemplateContext(&c, r.BaseRecipient, r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ptx.URL, check.Equals, "https://go.example.com/"+r.RId)
+	ch.Assert(ptx.LongURL, check.Equals, fmt.Sprintf("https://phish.example.com/login?%s=%s", RecipientParameter, r.RId))
+
+	got, err := GetShortLinkResult("GO.example.com:443", "/"+r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.Id, check.Equals, r.Id)
+	_, err = GetShortLinkResult("phish.example.com", "/"+r.RId)
+	ch.Assert(err, check.Equals, ErrShortLinkNotFound)
+	_, err = GetShortLinkResult("go.example.com", "/login/"+r.RId)
+	ch.Assert(err, check.Equals, ErrShortLinkNotFound)
+	_, err = GetShortLinkResult("go.example.com", "/")
+	ch.Assert(err, check.Equals, ErrShortLinkNotFound)
+
+	// Short links are exported as indicators and allowlisted
+	is, err := GetCampaignIndicators(c.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	found := map[string]string{}
+	for _, i := range is {
+		found[i.Value] = i.Description
+	}
+	ch.Assert(found[ptx.URL], check.Equals, "Short link")
+	ch.Assert(found[ptx.LongURL], check.Equals, "Phishing link")
+	ch.Assert(found["go.example.com"], check.Equals, "Short link server")
+
+	// Campaigns without a short URL use the full link
+	c.ShortURL = ""
+	ptx, err = NewPhishingTemplateContext(&c, r.BaseRecipient, r.RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ptx.URL, check.Equals, ptx.LongURL)
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// signingKeySize is the size, in bytes, of generated signing keys.
+const signingKeySize = 32
+
+// SigningKey is a random key used to sign values, such as the answers to
+// click challenges. Keys are generated the first time they're needed and
+// stored in the database, so that every instance sharing the database signs
+// values with the same key.
+type SigningKey struct {
+	Id          int64           `json:"-"`
+	Name        string          `json:"name"`
+	Value       EncryptedString `json:"-"`
+	CreatedDate time.Time       `json:"created_date"`
+}
+
+// GetSigningKey returns the named signing key, generating it if it doesn't
+// exist yet.
+func GetSigningKey(name string) ([]byte, error) {
+	k := SigningKey{}
+	err := db.Where("name = ?", name).First(&k).Error
+	if err == gorm.ErrRecordNotFound {
+		b := make([]byte, signingKeySize)
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		k = SigningKey{
+			Name:        name,
+			Value:       EncryptedString(base64.StdEncoding.EncodeToString(b)),
+			CreatedDate: time.Now().UTC(),
+		}
+		err = db.Create(&k).Error
+		if err != nil {
+			// Another instance may have created the key first
+			err = db.Where("name = ?", name).First(&k).Error
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(string(k.Value))
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/gophish/gophish/encryption"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestGetSigningKey(ch *check.C) {
+	k, err := GetSigningKey("test")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(k), check.Equals, signingKeySize)
+
+	// The key is generated once and then shared
+	again, err := GetSigningKey("test")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(again, check.DeepEquals, k)
+
+	other, err := GetSigningKey("other")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(other, check.Not(check.DeepEquals), k)
+}
+
+func (s *ModelsSuite) TestSigningKeyEncrypted(ch *check.C) {
+	keyring = newTestKeyring(ch, 1)
+	defer restoreKeyring(ch)
+	k, err := GetSigningKey("test")
+	ch.Assert(err, check.Equals, nil)
+
+	sk := SigningKey{}
+	ch.Assert(db.Where("name = ?", "test").First(&sk).Error, check.Equals, nil)
+	ch.Assert(encryption.IsEncrypted(rawColumn(ch, "signing_keys", "value", sk.Id)), check.Equals, true)
+	again, err := GetSigningKey("test")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(again, check.DeepEquals, k)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/gophish/gophish/mailer"
+	"github.com/gophish/gophish/storage"
+)
+
+// SimulatedPrefix is the prefix of the keys simulated emails are saved with.
+const SimulatedPrefix = "simulated/"
+
+// DefaultSimulatedPath is the directory simulated emails are saved to if
+// object storage isn't configured and no path is set.
+const DefaultSimulatedPath = "simulated_mail"
+
+// unsafeFilenameChars are replaced in the recipient addresses used to name
+// simulated emails.
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9@._+-]`)
+
+// simulatedSendingEnabled returns whether every email is simulated rather
+// than sent.
+func simulatedSendingEnabled() bool {
+	return conf != nil && conf.SimulatedSending.Enabled
+}
+
+// simulatedStore returns where simulated emails are saved.
+func simulatedStore() storage.Store {
+	if objectStore != nil {
+		return objectStore
+	}
+	dir := DefaultSimulatedPath
+	if conf != nil && conf.SimulatedSending.Path != "" {
+		dir = conf.SimulatedSending.Path
+	}
+	return storage.NewDirStore(dir)
+}
+
+// simulatedDialer returns a dialer which saves emails under the given
+// directory instead of sending them with the sending profile. The profile's
+// connection limit still applies, so emails are sent as quickly as they
+// would have been.
+func simulatedDialer(dir string, s *SMTP) mailer.Dialer {
+	return &mailer.SimulatedDialer{
+		Key:            fmt.Sprintf("%s\x00%d", dir, s.Id),
+		MaxConnections: s.MaxConnections,
+		Save: func(from string, to []string, msg []byte) error {
+			recipient := "unknown"
+			if len(to) > 0 {
+				recipient = unsafeFilenameChars.ReplaceAllString(to[0], "_")
+			}
+			name := fmt.Sprintf("%s-%s.eml", recipient, time.Now().UTC().Format("20060102T150405.000000000"))
+			return simulatedStore().Put(SimulatedPrefix+path.Join(dir, name), msg, "message/rfc822")
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"github.com/gophish/gophish/mailer"
+	"github.com/gophish/gophish/storage"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestSimulatedSending(ch *check.C) {
+	store := storage.NewMemoryStore()
+	objectStore = store
+	defer func() { objectStore = nil }()
+
+	c := s.createCampaignDependencies(ch)
+	c.Simulate = true
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.Simulate, check.Equals, true)
+
+	m := &MailLog{}
+	err := db.Where("campaign_id=?", c.Id).First(m).Error
+	ch.Assert(err, check.Equals, nil)
+	d, err := m.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	_, ok := d.(*mailer.SimulatedDialer)
+	ch.Assert(ok, check.Equals, true)
+
+	msg := gomail.NewMessage()
+	ch.Assert(m.Generate(msg), check.Equals, nil)
+	sender, err := d.Dial()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(sender.Send("from@example.com", []string{"foo@example.com"}, msg), check.Equals, nil)
+
+	ch.Assert(len(store.Objects), check.Equals, 1)
+	for key := range store.Objects {
+		prefix := fmt.Sprintf("%scampaign-%d/foo@example.com-", SimulatedPrefix, c.Id)
+		ch.Assert(strings.HasPrefix(key, prefix), check.Equals, true)
+		ch.Assert(strings.HasSuffix(key, ".eml"), check.Equals, true)
+		r, err := store.Get(key)
+		ch.Assert(err, check.Equals, nil)
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		ch.Assert(bytes.Contains(content, []byte(AllowlistHeader+": "+string(c.AllowlistToken))), check.Equals, true)
+	}
+
+	// Campaigns which aren't simulated are sent, unless simulated sending
+	// is enabled for every campaign
+	err = db.Model(&Campaign{}).Where("id=?", c.Id).Update("simulate", false).Error
+	ch.Assert(err, check.Equals, nil)
+	m.cachedCampaign = nil
+	d, err = m.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	_, ok = d.(*mailer.SimulatedDialer)
+	ch.Assert(ok, check.Equals, false)
+
+	conf.SimulatedSending.Enabled = true
+	defer func() { conf.SimulatedSending.Enabled = false }()
+	d, err = m.GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	_, ok = d.(*mailer.SimulatedDialer)
+	ch.Assert(ok, check.Equals, true)
+	d, err = (&EmailRequest{SMTP: c.SMTP}).GetDialer()
+	ch.Assert(err, check.Equals, nil)
+	_, ok = d.(*mailer.SimulatedDialer)
+	ch.Assert(ok, check.Equals, true)
+}
@@ -1,47 +1,95 @@
 package models
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"net/mail"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/gophish/gomail"
 	"github.com/gophish/gophish/dialer"
 	log "github.com/gophish/gophish/logger"
 	"github.com/gophish/gophish/mailer"
 	"github.com/jinzhu/gorm"
 )
 
-// Dialer is a wrapper around a standard gomail.Dialer in order
-// to implement the mailer.Dialer interface. This allows us to better
-// separate the mailer package as opposed to forcing a connection
-// between mailer and gomail.
+// Dialer is a wrapper around a mailer.SMTPDialer which implements the
+// mailer.PooledDialer interface, allowing connections for the sending profile
+// to be reused.
 type Dialer struct {
-	*gomail.Dialer
+	Dialer        *mailer.SMTPDialer
+	proxy         string
+	sourceAddress string
 }
 
-// Dial wraps the gomail dialer's Dial command
+// Dial wraps the SMTP dialer's Dial command
 func (d *Dialer) Dial() (mailer.Sender, error) {
 	return d.Dialer.Dial()
 }
 
+// PoolKey returns the key identifying connections made by the dialer.
+func (d *Dialer) PoolKey() string {
+	if d.proxy == "" && d.sourceAddress == "" {
+		return d.Dialer.PoolKey()
+	}
+	// Connections made through a proxy, or from a particular source address,
+	// are only shared with dialers using the same route to the server.
+	h := sha256.Sum256([]byte(d.Dialer.PoolKey() + "\x00" + d.proxy + "\x00" + d.sourceAddress))
+	return hex.EncodeToString(h[:])
+}
+
+// ConnectionLimit returns the maximum number of connections for the sending
+// profile.
+func (d *Dialer) ConnectionLimit() int {
+	return d.Dialer.ConnectionLimit()
+}
+
 // SMTP contains the attributes needed to handle the sending of campaign emails
 type SMTP struct {
-	Id               int64     `json:"id" gorm:"column:id; primary_key:yes"`
-	UserId           int64     `json:"-" gorm:"column:user_id"`
-	Interface        string    `json:"interface_type" gorm:"column:interface_type"`
-	Name             string    `json:"name"`
-	Host             string    `json:"host"`
-	Username         string    `json:"username,omitempty"`
-	Password         string    `json:"password,omitempty"`
-	FromAddress      string    `json:"from_address"`
-	IgnoreCertErrors bool      `json:"ignore_cert_errors"`
-	Headers          []Header  `json:"headers"`
-	ModifiedDate     time.Time `json:"modified_date"`
+	Id               int64           `json:"id" gorm:"column:id; primary_key:yes"`
+	UserId           int64           `json:"-" gorm:"column:user_id"`
+	Interface        string          `json:"interface_type" gorm:"column:interface_type"`
+	Name             string          `json:"name"`
+	ExternalId       string          `json:"external_id,omitempty"`
+	Host             string          `json:"host"`
+	Username         string          `json:"username,omitempty"`
+	Password         EncryptedString `json:"password,omitempty"`
+	FromAddress      string          `json:"from_address"`
+	IgnoreCertErrors bool            `json:"ignore_cert_errors"`
+	MaxConnections   int             `json:"max_connections"`
+	Proxy            EncryptedString `json:"proxy,omitempty"`
+	SourceAddress    string          `json:"source_address,omitempty"`
+	// Path is the directory emails are written to by Maildir and Directory
+	// profiles, relative to the configured file sending path.
+	Path string `json:"path,omitempty"`
+	// HealthCheckAddress, if set, is sent a probe email by each health
+	// check, rather than only connecting to the server.
+	HealthCheckAddress string `json:"health_check_address,omitempty"`
+	// MessageIdFormat is the format of the Message-ID header, and
+	// MessageIdDomain the domain used in it instead of the hostname.
+	MessageIdFormat string `json:"message_id_format,omitempty"`
+	MessageIdDomain string `json:"message_id_domain,omitempty"`
+	// RemoveMailerHeader removes the X-Mailer header.
+	RemoveMailerHeader bool `json:"remove_mailer_header"`
+	// BoundaryPrefix, if set, starts each MIME boundary, replacing the
+	// random boundaries which are otherwise used.
+	BoundaryPrefix string `json:"boundary_prefix,omitempty"`
+	// HeaderOrder is a comma separated list of the headers written first,
+	// in order.
+	HeaderOrder string `json:"header_order,omitempty"`
+	// DKIMDomain and DKIMSelector identify the key emails are signed with,
+	// and DKIMPrivateKey is the PEM encoded RSA private key, or a reference
+	// to a secret holding it.
+	DKIMDomain     string          `json:"dkim_domain,omitempty"`
+	DKIMSelector   string          `json:"dkim_selector,omitempty"`
+	DKIMPrivateKey EncryptedString `json:"dkim_private_key,omitempty"`
+	Headers        []Header        `json:"headers"`
+	ModifiedDate   time.Time       `json:"modified_date"`
 }
 
 // Header contains the fields and methods for a sending profile to have
@@ -64,18 +112,72 @@ var ErrHostNotSpecified = errors.New("No SMTP Host specified")
 // ErrInvalidHost indicates that the SMTP server string is invalid
 var ErrInvalidHost = errors.New("Invalid SMTP server address")
 
+// The interface types of sending profiles. SMTP profiles send emails, while
+// Maildir and Directory profiles write them to a local directory.
+const (
+	InterfaceSMTP      = "SMTP"
+	InterfaceMaildir   = "Maildir"
+	InterfaceDirectory = "Directory"
+)
+
+// ErrInvalidInterface indicates that the sending profile's interface type
+// isn't supported
+var ErrInvalidInterface = errors.New("Invalid interface type")
+
+// ErrPathNotSpecified is thrown when a Maildir or Directory profile has no
+// path
+var ErrPathNotSpecified = errors.New("No path specified")
+
+// ErrFileSendingDisabled is thrown when a Maildir or Directory profile is
+// used without a file sending path being configured
+var ErrFileSendingDisabled = errors.New("Maildir and Directory sending profiles aren't enabled")
+
+// ErrInvalidMaxConnections indicates that the maximum number of connections
+// is negative
+var ErrInvalidMaxConnections = errors.New("Maximum connections can't be negative")
+
 // TableName specifies the database tablename for Gorm to use
 func (s SMTP) TableName() string {
 	return "smtp"
 }
 
+// writesFiles returns whether the profile writes emails to a directory
+// rather than sending them.
+func (s *SMTP) writesFiles() bool {
+	return s.Interface == InterfaceMaildir || s.Interface == InterfaceDirectory
+}
+
+// filePath returns the directory a Maildir or Directory profile writes
+// emails to. Paths can't refer to directories outside of the configured
+// file sending path.
+func (s *SMTP) filePath() (string, error) {
+	if conf == nil || conf.FileSendingPath == "" {
+		return "", ErrFileSendingDisabled
+	}
+	if s.Path == "" {
+		return "", ErrPathNotSpecified
+	}
+	return filepath.Join(conf.FileSendingPath, filepath.Clean("/"+s.Path)), nil
+}
+
 // Validate ensures that SMTP configs/connections are valid
 func (s *SMTP) Validate() error {
 	switch {
 	case s.FromAddress == "":
 		return ErrFromAddressNotSpecified
+	case s.Interface != "" && s.Interface != InterfaceSMTP && !s.writesFiles():
+		return ErrInvalidInterface
+	case s.writesFiles():
+		_, err := s.filePath()
+		if err != nil {
+			return err
+		}
+		_, err = mail.ParseAddress(s.FromAddress)
+		return err
 	case s.Host == "":
 		return ErrHostNotSpecified
+	case s.MaxConnections < 0:
+		return ErrInvalidMaxConnections
 	}
 	_, err := mail.ParseAddress(s.FromAddress)
 	if err != nil {
@@ -92,11 +194,40 @@ func (s *SMTP) Validate() error {
 	if err != nil {
 		return ErrInvalidHost
 	}
-	return err
+	_, err = dialer.ParseProxy(string(s.Proxy))
+	if err != nil {
+		return err
+	}
+	_, err = dialer.ParseSourceAddress(s.SourceAddress)
+	if err != nil {
+		return err
+	}
+	err = s.validateFingerprint()
+	if err != nil {
+		return err
+	}
+	if s.HealthCheckAddress != "" {
+		_, err = mail.ParseAddress(s.HealthCheckAddress)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.validateDKIM()
+	if err != nil {
+		return err
+	}
+	return validateSecret(string(s.Password), s.UserId)
 }
 
 // GetDialer returns a dialer for the given SMTP profile
 func (s *SMTP) GetDialer() (mailer.Dialer, error) {
+	if s.writesFiles() {
+		path, err := s.filePath()
+		if err != nil {
+			return nil, err
+		}
+		return &mailer.FileDialer{Path: path, Maildir: s.Interface == InterfaceMaildir}, nil
+	}
 	// Setup the message and dial
 	hp := strings.Split(s.Host, ":")
 	if len(hp) < 2 {
@@ -110,8 +241,29 @@ func (s *SMTP) GetDialer() (mailer.Dialer, error) {
 		log.Error(err)
 		return nil, err
 	}
-	dialer := dialer.Dialer()
-	d := gomail.NewWithDialer(dialer, host, port, s.Username, s.Password)
+	// The password may reference a secret stored in an external secrets
+	// manager, which is resolved each time we connect so that rotated
+	// credentials are picked up.
+	password, err := ResolveSecret(string(s.Password), s.UserId)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	// Profiles without their own proxy use the global proxy, if one is
+	// configured.
+	nd, err := dialer.ProxyDialer(string(s.Proxy))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	nd, err = dialer.BindSourceAddress(nd, s.SourceAddress)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	d := mailer.NewSMTPDialer(host, port, s.Username, password)
+	d.NetDialer = nd
+	d.MaxConnections = s.MaxConnections
 	d.TLSConfig = &tls.Config{
 		ServerName:         host,
 		InsecureSkipVerify: s.IgnoreCertErrors,
@@ -122,25 +274,45 @@ func (s *SMTP) GetDialer() (mailer.Dialer, error) {
 		hostname = "localhost"
 	}
 	d.LocalName = hostname
-	return &Dialer{d}, err
+	return &Dialer{Dialer: d, proxy: string(s.Proxy), sourceAddress: s.SourceAddress}, err
 }
 
 // GetSMTPs returns the SMTPs owned by the given user.
 func GetSMTPs(uid int64) ([]SMTP, error) {
+	ss, _, err := ListSMTPs(uid, ListOptions{})
+	return ss, err
+}
+
+// smtpListFields are the fields sending profiles can be sorted and filtered
+// by
+var smtpListFields = listFields{
+	"id":             "id",
+	"name":           "name",
+	"interface_type": "interface_type",
+	"modified_date":  "modified_date",
+}
+
+// ListSMTPs returns the SMTPs owned by the given user, filtered, sorted, and
+// paginated using the given options.
+func ListSMTPs(uid int64, opts ListOptions) ([]SMTP, PageInfo, error) {
 	ss := []SMTP{}
-	err := db.Where("user_id=?", uid).Find(&ss).Error
+	query, total, err := opts.apply(db.Table("smtp").Where("user_id=?", uid), smtpListFields)
+	if err != nil {
+		return ss, PageInfo{}, err
+	}
+	err = query.Find(&ss).Error
 	if err != nil {
 		log.Error(err)
-		return ss, err
+		return ss, PageInfo{}, err
 	}
 	for i := range ss {
 		err = db.Where("smtp_id=?", ss[i].Id).Find(&ss[i].Headers).Error
 		if err != nil && err != gorm.ErrRecordNotFound {
 			log.Error(err)
-			return ss, err
+			return ss, PageInfo{}, err
 		}
 	}
-	return ss, nil
+	return ss, opts.pageInfo(&ss, total, smtpListFields), nil
 }
 
 // GetSMTP returns the SMTP, if it exists, specified by the given id and user_id.
@@ -181,6 +353,10 @@ func PostSMTP(s *SMTP) error {
 		log.Error(err)
 		return err
 	}
+	err = validateExternalId("smtp", s.ExternalId, s.UserId, s.Id)
+	if err != nil {
+		return err
+	}
 	// Insert into the DB
 	err = db.Save(s).Error
 	if err != nil {
@@ -206,6 +382,10 @@ func PutSMTP(s *SMTP) error {
 		log.Error(err)
 		return err
 	}
+	err = validateExternalId("smtp", s.ExternalId, s.UserId, s.Id)
+	if err != nil {
+		return err
+	}
 	err = db.Where("id=?", s.Id).Save(s).Error
 	if err != nil {
 		log.Error(err)
@@ -237,6 +417,16 @@ func DeleteSMTP(id int64, uid int64) error {
 		log.Error(err)
 		return err
 	}
+	err = db.Where("smtp_id=?", id).Delete(&SMTPHealth{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	err = clearGroupDefaults("default_smtp_id", id, uid)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	err = db.Where("user_id=?", uid).Delete(SMTP{Id: id}).Error
 	if err != nil {
 		log.Error(err)
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/jinzhu/gorm"
+	"github.com/sirupsen/logrus"
+)
+
+// The health statuses of a sending profile
+const (
+	SMTPHealthUnknown = "unknown"
+	SMTPHealthy       = "healthy"
+	SMTPFailing       = "failing"
+	SMTPTripped       = "tripped"
+)
+
+// CircuitBreakerThreshold is the number of emails in a row which can fail to
+// send using a sending profile before its circuit breaker trips, pausing the
+// emails queued to be sent with it.
+var CircuitBreakerThreshold = 10
+
+// ErrSendingProfilePaused is returned when generating an email for a sending
+// profile whose circuit breaker has tripped.
+var ErrSendingProfilePaused = errors.New("Sending is paused because the sending profile has been failing")
+
+// SMTPHealth is the health of a sending profile, from both its periodic
+// health checks and the emails sent with it.
+type SMTPHealth struct {
+	Id     int64  `json:"-"`
+	SMTPId int64  `json:"smtp_id" gorm:"column:smtp_id"`
+	UserId int64  `json:"-"`
+	Status string `json:"status"`
+	// ConsecutiveFailures is the number of emails in a row which failed to
+	// send.
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastCheckedDate     time.Time `json:"last_checked_date"`
+	// TrippedDate is when the circuit breaker tripped, pausing the emails
+	// queued to be sent with the profile.
+	TrippedDate time.Time `json:"tripped_date"`
+}
+
+// TableName specifies the database tablename for Gorm to use
+func (h SMTPHealth) TableName() string {
+	return "smtp_health"
+}
+
+// SMTPHealthAlert is sent to the webhooks when a sending profile's circuit
+// breaker trips, and when the profile recovers.
+type SMTPHealthAlert struct {
+	SMTPId int64     `json:"smtp_id"`
+	Name   string    `json:"name"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// WebhookType returns the type of the payload sent to webhooks.
+func (SMTPHealthAlert) WebhookType() string {
+	return "smtp_health"
+}
+
+// getSMTPHealth returns the stored health of the sending profile, creating
+// it if the profile hasn't been checked yet.
+func getSMTPHealth(s SMTP) (SMTPHealth, error) {
+	h := SMTPHealth{}
+	err := db.Where(SMTPHealth{SMTPId: s.Id}).
+		Attrs(SMTPHealth{UserId: s.UserId, Status: SMTPHealthUnknown}).
+		FirstOrCreate(&h).Error
+	return h, err
+}
+
+// GetSMTPHealth returns the health of the sending profile owned by the given
+// user.
+func GetSMTPHealth(id int64, uid int64) (SMTPHealth, error) {
+	s, err := GetSMTP(id, uid)
+	if err != nil {
+		return SMTPHealth{}, err
+	}
+	return getSMTPHealth(s)
+}
+
+// GetSMTPHealths returns the health of each of the user's sending profiles.
+func GetSMTPHealths(uid int64) ([]SMTPHealth, error) {
+	ss, err := GetSMTPs(uid)
+	if err != nil {
+		return nil, err
+	}
+	hs := []SMTPHealth{}
+	for _, s := range ss {
+		h, err := getSMTPHealth(s)
+		if err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+// CheckHealth connects and authenticates to the sending profile's SMTP
+// server, sending a probe email if the profile has a health check address.
+// A successful check resets the circuit breaker, resuming any paused emails.
+func (s *SMTP) CheckHealth() (SMTPHealth, error) {
+	h, err := getSMTPHealth(*s)
+	if err != nil {
+		return h, err
+	}
+	checkErr := s.checkConnection()
+	if checkErr == nil && s.HealthCheckAddress != "" {
+		checkErr = s.sendProbe(s.HealthCheckAddress)
+	}
+	h.LastCheckedDate = time.Now().UTC()
+	if checkErr != nil {
+		h.LastError = checkErr.Error()
+		// A tripped breaker stays tripped until a check succeeds
+		if h.Status != SMTPTripped {
+			h.Status = SMTPFailing
+		}
+		return h, db.Save(&h).Error
+	}
+	if h.Status == SMTPTripped {
+		log.WithFields(logrus.Fields{
+			"smtp_id": s.Id,
+		}).Info("Sending profile recovered, resuming emails")
+		sendWebhooks(SMTPHealthAlert{SMTPId: s.Id, Name: s.Name, Status: SMTPHealthy, Time: h.LastCheckedDate})
+	}
+	h.Status = SMTPHealthy
+	h.ConsecutiveFailures = 0
+	h.LastError = ""
+	h.TrippedDate = time.Time{}
+	return h, db.Save(&h).Error
+}
+
+// checkConnection dials the SMTP server, which includes authenticating.
+func (s *SMTP) checkConnection() error {
+	d, err := s.GetDialer()
+	if err != nil {
+		return err
+	}
+	sender, err := d.Dial()
+	if err != nil {
+		return err
+	}
+	return sender.Close()
+}
+
+// CheckSMTPHealth runs the health check of every sending profile.
+func CheckSMTPHealth() error {
+	ss := []SMTP{}
+	err := db.Find(&ss).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range ss {
+		err = db.Where("smtp_id=?", s.Id).Find(&s.Headers).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		h, err := s.CheckHealth()
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if h.Status != SMTPHealthy {
+			log.WithFields(logrus.Fields{
+				"smtp_id": s.Id,
+				"status":  h.Status,
+			}).Warn(h.LastError)
+		}
+	}
+	return nil
+}
+
+// SendingProfilePaused returns whether the circuit breaker of the sending
+// profile has tripped.
+func SendingProfilePaused(sid int64) bool {
+	count := 0
+	err := db.Model(&SMTPHealth{}).Where("smtp_id=? AND status=?", sid, SMTPTripped).Count(&count).Error
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return count > 0
+}
+
+// recordSendFailure counts an email which failed to send using the sending
+// profile, tripping its circuit breaker once too many fail in a row.
+func recordSendFailure(sid int64, e error) {
+	s := SMTP{}
+	err := db.Where("id=?", sid).Find(&s).Error
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	h, err := getSMTPHealth(s)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	err = db.Model(&SMTPHealth{}).Where("id=?", h.Id).
+		UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	h.ConsecutiveFailures++
+	if h.Status == SMTPTripped || h.ConsecutiveFailures < CircuitBreakerThreshold {
+		return
+	}
+	// Only one of the failures trips the breaker, even when emails are
+	// sent concurrently.
+	now := time.Now().UTC()
+	res := db.Model(&SMTPHealth{}).Where("id=? AND status<>?", h.Id, SMTPTripped).Updates(map[string]interface{}{
+		"status":       SMTPTripped,
+		"tripped_date": now,
+		"last_error":   e.Error(),
+	})
+	if res.Error != nil {
+		log.Error(res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		return
+	}
+	log.WithFields(logrus.Fields{
+		"smtp_id":  sid,
+		"failures": h.ConsecutiveFailures,
+	}).Warn("Sending profile circuit breaker tripped, pausing emails")
+	sendWebhooks(SMTPHealthAlert{SMTPId: sid, Name: s.Name, Status: SMTPTripped, Error: e.Error(), Time: now})
+}
+
+// recordSendSuccess resets the count of emails which failed to send in a row
+// using the sending profile.
+func recordSendSuccess(sid int64) {
+	err := db.Model(&SMTPHealth{}).Where("smtp_id=? AND consecutive_failures>0 AND status<>?", sid, SMTPTripped).
+		UpdateColumn("consecutive_failures", 0).Error
+	if err != nil {
+		log.Error(err)
+	}
+}
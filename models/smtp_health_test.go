@@ -0,0 +1,148 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"github.com/gophish/gophish/dialer"
+	"gopkg.in/check.v1"
+)
+
+// startHealthySMTPServer starts an SMTP server which accepts connections,
+// returning its address.
+func startHealthySMTPServer(ch *check.C) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	ch.Assert(err, check.Equals, nil)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := textproto.NewReader(bufio.NewReader(conn))
+				fmt.Fprintf(conn, "220 localhost ready\r\n")
+				for {
+					line, err := r.ReadLine()
+					if err != nil {
+						return
+					}
+					if strings.HasPrefix(strings.ToUpper(line), "QUIT") {
+						fmt.Fprintf(conn, "221 bye\r\n")
+						return
+					}
+					fmt.Fprintf(conn, "250 ok\r\n")
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func (s *ModelsSuite) TestCircuitBreaker(ch *check.C) {
+	orig := CircuitBreakerThreshold
+	defer func() { CircuitBreakerThreshold = orig }()
+	CircuitBreakerThreshold = 2
+
+	c := s.createCampaign(ch)
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	sendErr := &textproto.Error{Code: 421, Msg: "Service not available"}
+
+	// The first failure is handled as normal
+	ch.Assert(ms[0].Error(sendErr), check.Equals, nil)
+	r, err := GetResult(ms[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Status, check.Equals, Error)
+	ch.Assert(SendingProfilePaused(c.SMTPId), check.Equals, false)
+
+	// The second trips the breaker, so the email stays queued
+	ch.Assert(ms[1].Error(sendErr), check.Equals, nil)
+	ch.Assert(SendingProfilePaused(c.SMTPId), check.Equals, true)
+	r, err = GetResult(ms[1].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Status, check.Not(check.Equals), Error)
+	m := &MailLog{}
+	err = db.Where("r_id=?", ms[1].RId).Find(m).Error
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(m.Processing, check.Equals, false)
+
+	h, err := GetSMTPHealth(c.SMTPId, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(h.Status, check.Equals, SMTPTripped)
+	ch.Assert(h.LastError, check.Equals, sendErr.Error())
+	ch.Assert(h.TrippedDate.IsZero(), check.Equals, false)
+
+	// Emails for the profile aren't generated until it recovers
+	err = ms[2].Generate(gomail.NewMessage())
+	ch.Assert(err, check.Equals, ErrSendingProfilePaused)
+	ch.Assert(ms[2].Error(err), check.Equals, nil)
+	r, err = GetResult(ms[2].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.Status, check.Not(check.Equals), Error)
+}
+
+func (s *ModelsSuite) TestCircuitBreakerReset(ch *check.C) {
+	orig := CircuitBreakerThreshold
+	defer func() { CircuitBreakerThreshold = orig }()
+	CircuitBreakerThreshold = 2
+
+	c := s.createCampaign(ch)
+	ms, err := GetMailLogsByCampaign(c.Id)
+	ch.Assert(err, check.Equals, nil)
+	sendErr := &textproto.Error{Code: 550, Msg: "Mailbox unavailable"}
+	ch.Assert(ms[0].Error(sendErr), check.Equals, nil)
+	// A successful email resets the count of failures
+	ch.Assert(ms[1].Success(), check.Equals, nil)
+	ch.Assert(ms[2].Error(sendErr), check.Equals, nil)
+	h, err := GetSMTPHealth(c.SMTPId, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(h.ConsecutiveFailures, check.Equals, 1)
+	ch.Assert(h.Status, check.Not(check.Equals), SMTPTripped)
+
+	// Emails which couldn't be generated don't count
+	ms[3].generateFailed = true
+	ch.Assert(ms[3].Error(sendErr), check.Equals, nil)
+	ch.Assert(SendingProfilePaused(c.SMTPId), check.Equals, false)
+}
+
+func (s *ModelsSuite) TestSMTPCheckHealth(ch *check.C) {
+	allowed := dialer.DefaultDialer.AllowedHosts()
+	defer dialer.SetAllowedHosts(allowed)
+	dialer.SetAllowedHosts([]string{"127.0.0.1"})
+
+	smtp := SMTP{Name: "Health", UserId: 1, Host: "127.0.0.1:1", FromAddress: "foo@example.com"}
+	ch.Assert(PostSMTP(&smtp), check.Equals, nil)
+	h, err := smtp.CheckHealth()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(h.Status, check.Equals, SMTPFailing)
+	ch.Assert(h.LastError, check.Not(check.Equals), "")
+
+	// A tripped breaker isn't reset by a failed check
+	err = db.Model(&SMTPHealth{}).Where("id=?", h.Id).Update("status", SMTPTripped).Error
+	ch.Assert(err, check.Equals, nil)
+	h, err = smtp.CheckHealth()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(h.Status, check.Equals, SMTPTripped)
+
+	// A successful check resumes sending
+	addr, stop := startHealthySMTPServer(ch)
+	defer stop()
+	smtp.Host = addr
+	ch.Assert(PutSMTP(&smtp), check.Equals, nil)
+	h, err = smtp.CheckHealth()
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(h.Status, check.Equals, SMTPHealthy)
+	ch.Assert(h.LastError, check.Equals, "")
+	ch.Assert(SendingProfilePaused(smtp.Id), check.Equals, false)
+
+	hs, err := GetSMTPHealths(1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(hs), check.Equals, 1)
+	ch.Assert(hs[0].SMTPId, check.Equals, smtp.Id)
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"io/ioutil"
+	stdlog "log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gophish/gophish/config"
+	"github.com/jinzhu/gorm"
+)
+
+// sqliteWriteLock serializes the writes made to sqlite3 databases. sqlite
+// only allows one writer at a time, so writes from the phishing server and
+// the worker otherwise compete for the database lock during bursts of
+// events, failing with "database is locked" once the busy timeout expires.
+var sqliteWriteLock sync.Mutex
+
+// sqliteWriteLocked is set on the scopes which hold the sqliteWriteLock.
+const sqliteWriteLocked = "gophish:sqlite_write_locked"
+
+// sqliteInMemory returns whether the sqlite3 database is held in memory,
+// which gives each connection its own database.
+func sqliteInMemory(path string) bool {
+	return strings.HasPrefix(path, ":memory:") || strings.Contains(path, "mode=memory")
+}
+
+// sqliteDSN adds the configured journal mode and busy timeout to the path of
+// a sqlite3 database, unless they're already set in the path. Transactions
+// take the write lock when they begin, since sqlite fails transactions which
+// can't upgrade their read lock without waiting for the busy timeout.
+func sqliteDSN(path string, c config.SQLite) string {
+	query := ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		// Leave invalid parameters for the driver to report
+		return path + "?" + query
+	}
+	journal := strings.ToLower(c.JournalMode)
+	if journal == "" {
+		journal = config.DefaultSQLiteJournalMode
+	}
+	timeout := c.BusyTimeoutMs
+	if timeout == 0 {
+		timeout = config.DefaultSQLiteBusyTimeoutMs
+	}
+	if params.Get("_journal_mode") == "" && params.Get("_journal") == "" && !sqliteInMemory(path) {
+		params.Set("_journal_mode", strings.ToUpper(journal))
+	}
+	if params.Get("_busy_timeout") == "" && params.Get("_timeout") == "" {
+		params.Set("_busy_timeout", strconv.Itoa(timeout))
+	}
+	if params.Get("_txlock") == "" {
+		params.Set("_txlock", "immediate")
+	}
+	return path + "?" + params.Encode()
+}
+
+// setupSQLite sizes the connection pool of a sqlite3 database and registers
+// the callbacks which serialize its writes. Databases using the WAL journal
+// mode can be read using several connections while a write is in progress,
+// while other databases are limited to a single connection.
+func setupSQLite(path string, c config.SQLite) {
+	conns := 1
+	journal := strings.ToLower(c.JournalMode)
+	if (journal == "" || journal == "wal") && !sqliteInMemory(path) {
+		conns = c.MaxConnections
+		if conns == 0 {
+			conns = config.DefaultSQLiteMaxConnections
+		}
+	}
+	db.DB().SetMaxOpenConns(conns)
+	// gorm logs each callback as it's registered, so they're registered
+	// quietly.
+	quiet := db.New()
+	quiet.SetLogger(gorm.Logger{LogWriter: stdlog.New(ioutil.Discard, "", 0)})
+	callbacks := quiet.Callback()
+	for _, processor := range []func() *gorm.CallbackProcessor{callbacks.Create, callbacks.Update, callbacks.Delete} {
+		processor().Before("gorm:begin_transaction").Register("gophish:lock_sqlite_writes", lockSQLiteWrites)
+		processor().After("gorm:commit_or_rollback_transaction").Register("gophish:unlock_sqlite_writes", unlockSQLiteWrites)
+	}
+}
+
+// lockSQLiteWrites waits for the writes made by other connections to
+// finish. Writes made in a transaction already hold the database's write
+// lock, so they don't wait.
+func lockSQLiteWrites(scope *gorm.Scope) {
+	if _, ok := scope.SQLDB().(*sql.Tx); ok {
+		return
+	}
+	sqliteWriteLock.Lock()
+	scope.InstanceSet(sqliteWriteLocked, true)
+}
+
+// unlockSQLiteWrites lets the next write proceed.
+func unlockSQLiteWrites(scope *gorm.Scope) {
+	if _, ok := scope.InstanceGet(sqliteWriteLocked); ok {
+		sqliteWriteLock.Unlock()
+	}
+}
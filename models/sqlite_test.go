@@ -0,0 +1,84 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gophish/gophish/config"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestSQLiteDSN(ch *check.C) {
+	tests := []struct {
+		path     string
+		conf     config.SQLite
+		expected string
+	}{
+		{"gophish.db", config.SQLite{}, "gophish.db?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"},
+		{"gophish.db", config.SQLite{JournalMode: "delete", BusyTimeoutMs: 100}, "gophish.db?_busy_timeout=100&_journal_mode=DELETE&_txlock=immediate"},
+		{"gophish.db?_busy_timeout=1&cache=shared", config.SQLite{}, "gophish.db?_busy_timeout=1&_journal_mode=WAL&_txlock=immediate&cache=shared"},
+		{":memory:", config.SQLite{}, ":memory:?_busy_timeout=5000&_txlock=immediate"},
+	}
+	for _, test := range tests {
+		ch.Assert(sqliteDSN(test.path, test.conf), check.Equals, test.expected)
+	}
+}
+
+type concurrentWrite struct {
+	Id    int64
+	Value int
+}
+
+func (s *ModelsSuite) TestSQLiteConcurrentWrites(ch *check.C) {
+	dir, err := ioutil.TempDir("", "gophish-sqlite")
+	ch.Assert(err, check.Equals, nil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gophish.db")
+
+	// The test database is held in memory, so a database file is used
+	// instead while testing.
+	orig := db
+	defer func() { db = orig }()
+	db, err = gorm.Open("sqlite3", sqliteDSN(path, config.SQLite{}))
+	ch.Assert(err, check.Equals, nil)
+	defer db.Close()
+	setupSQLite(path, config.SQLite{})
+	ch.Assert(db.DB().Stats().MaxOpenConnections, check.Equals, config.DefaultSQLiteMaxConnections)
+	var mode string
+	ch.Assert(db.Raw("PRAGMA journal_mode").Row().Scan(&mode), check.Equals, nil)
+	ch.Assert(mode, check.Equals, "wal")
+	ch.Assert(db.AutoMigrate(&concurrentWrite{}).Error, check.Equals, nil)
+
+	writes := 200
+	errs := make(chan error, writes)
+	wg := sync.WaitGroup{}
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				errs <- db.Create(&concurrentWrite{Value: i}).Error
+				return
+			}
+			tx := db.Begin()
+			err := tx.Create(&concurrentWrite{Value: i}).Error
+			if err != nil {
+				tx.Rollback()
+				errs <- err
+				return
+			}
+			errs <- tx.Commit().Error
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ch.Assert(err, check.Equals, nil)
+	}
+	var count int
+	ch.Assert(db.Model(&concurrentWrite{}).Count(&count).Error, check.Equals, nil)
+	ch.Assert(count, check.Equals, writes)
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gophish/gophish/config"
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/storage"
+)
+
+// AttachmentPrefix is the prefix of the keys used to store attachment content
+// in object storage.
+const AttachmentPrefix = "attachments/"
+
+// objectStore holds attachment content when object storage is configured. If
+// it's nil, attachment content is stored in the database.
+var objectStore storage.Store
+
+// ObjectStore returns the configured object storage, or nil if attachments
+// are stored in the database.
+func ObjectStore() storage.Store {
+	return objectStore
+}
+
+// setupObjectStorage configures object storage, moving the content of any
+// attachments still stored in the database into it.
+func setupObjectStorage(c config.ObjectStorage) error {
+	s, err := storage.NewS3Store(c)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		objectStore = nil
+		return nil
+	}
+	objectStore = s
+	return moveAttachmentsToStorage()
+}
+
+// moveAttachmentsToStorage moves attachment content from the database into
+// object storage, one attachment at a time so that large attachments aren't
+// all held in memory.
+func moveAttachmentsToStorage() error {
+	ids := []int64{}
+	err := db.Model(&Attachment{}).Where("storage_key = ? OR storage_key IS NULL", "").Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		log.Infof("Moving %d attachments to object storage", len(ids))
+	}
+	for _, id := range ids {
+		a := Attachment{}
+		err = db.Where("id = ?", id).First(&a).Error
+		if err != nil {
+			return err
+		}
+		err = a.store()
+		if err != nil {
+			return err
+		}
+		err = db.Model(&a).UpdateColumns(map[string]interface{}{"content": "", "storage_key": a.StorageKey}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store uploads the attachment's content to object storage, if it's
+// configured. Objects are keyed by the hash of their content, so attachments
+// shared by templates are only stored once.
+func (a *Attachment) store() error {
+	if objectStore == nil || a.Content == "" {
+		return nil
+	}
+	content, err := base64.StdEncoding.DecodeString(a.Content)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(content)
+	a.StorageKey = AttachmentPrefix + hex.EncodeToString(h[:])
+	err = objectStore.Put(a.StorageKey, content, a.Type)
+	if err != nil {
+		return err
+	}
+	a.Content = ""
+	return nil
+}
+
+// Open returns a reader for the attachment's decoded content, streaming it
+// from object storage if that's where it's stored.
+func (a *Attachment) Open() (io.ReadCloser, error) {
+	if a.StorageKey == "" {
+		return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(a.Content))), nil
+	}
+	if objectStore == nil {
+		return nil, storage.ErrNotFound
+	}
+	return objectStore.Get(a.StorageKey)
+}
+
+// loadContent sets the attachment's base64 encoded content from object
+// storage, so that the API returns attachments the same way wherever they're
+// stored.
+func (a *Attachment) loadContent() error {
+	if a.StorageKey == "" {
+		return nil
+	}
+	r, err := a.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	a.Content = base64.StdEncoding.EncodeToString(content)
+	return nil
+}
+
+// saveAttachment saves the attachment, storing its content in object storage
+// if it's configured. The content is kept on the attachment, so that it's
+// returned the same way it was provided.
+func saveAttachment(a *Attachment) error {
+	content := a.Content
+	err := a.store()
+	if err != nil {
+		return err
+	}
+	err = db.Save(a).Error
+	a.Content = content
+	return err
+}
+
+// loadAttachmentContent loads the content of attachments kept in object
+// storage.
+func loadAttachmentContent(as []Attachment) error {
+	for i := range as {
+		err := as[i].loadContent()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachmentStorageKeys returns the object storage keys of the template's
+// attachments.
+func attachmentStorageKeys(tid int64) ([]string, error) {
+	keys := []string{}
+	if objectStore == nil {
+		return keys, nil
+	}
+	err := db.Model(&Attachment{}).Where("template_id = ? AND storage_key <> ?", tid, "").Pluck("storage_key", &keys).Error
+	return keys, err
+}
+
+// deleteUnusedObjects removes attachment content from object storage once no
+// attachments reference it.
+func deleteUnusedObjects(keys []string) {
+	for _, key := range keys {
+		var count int64
+		err := db.Model(&Attachment{}).Where("storage_key = ?", key).Count(&count).Error
+		if err != nil || count > 0 {
+			continue
+		}
+		err = objectStore.Delete(key)
+		if err != nil {
+			log.Errorf("error deleting %s from object storage: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+
+	"github.com/gophish/gophish/storage"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestAttachmentObjectStorage(c *check.C) {
+	store := storage.NewMemoryStore()
+	objectStore = store
+	defer func() { objectStore = nil }()
+
+	content := base64.StdEncoding.EncodeToString([]byte("attachment content"))
+	first := Template{Name: "Storage Template", Text: "{{.URL}}", UserId: 1,
+		Attachments: []Attachment{{Name: "file.bin", Type: "application/octet-stream", Content: content}}}
+	c.Assert(PostTemplate(&first), check.Equals, nil)
+	c.Assert(first.Attachments[0].Content, check.Equals, content)
+	c.Assert(store.Objects, check.HasLen, 1)
+
+	// The content is kept out of the database, but returned by the API
+	stored := Attachment{}
+	c.Assert(db.Where("template_id = ?", first.Id).First(&stored).Error, check.Equals, nil)
+	c.Assert(stored.Content, check.Equals, "")
+	c.Assert(stored.StorageKey, check.Not(check.Equals), "")
+	t, err := GetTemplate(first.Id, 1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(t.Attachments[0].Content, check.Equals, content)
+
+	// The mailer streams the content from object storage
+	r, err := stored.Open()
+	c.Assert(err, check.Equals, nil)
+	b, _ := ioutil.ReadAll(r)
+	r.Close()
+	c.Assert(string(b), check.Equals, "attachment content")
+
+	// Shared content is only deleted once it's no longer used
+	second := Template{Name: "Storage Template Copy", Text: "{{.URL}}", UserId: 1,
+		Attachments: []Attachment{{Name: "copy.bin", Type: "application/octet-stream", Content: content}}}
+	c.Assert(PostTemplate(&second), check.Equals, nil)
+	c.Assert(store.Objects, check.HasLen, 1)
+	c.Assert(DeleteTemplate(first.Id, 1), check.Equals, nil)
+	c.Assert(store.Objects, check.HasLen, 1)
+	second.Attachments = []Attachment{}
+	c.Assert(PutTemplate(&second), check.Equals, nil)
+	c.Assert(store.Objects, check.HasLen, 0)
+	c.Assert(DeleteTemplate(second.Id, 1), check.Equals, nil)
+}
+
+func (s *ModelsSuite) TestMoveAttachmentsToStorage(c *check.C) {
+	content := base64.StdEncoding.EncodeToString([]byte("attachment content"))
+	t := Template{Name: "Database Template", Text: "{{.URL}}", UserId: 1,
+		Attachments: []Attachment{{Name: "file.bin", Type: "application/octet-stream", Content: content}}}
+	c.Assert(PostTemplate(&t), check.Equals, nil)
+	defer DeleteTemplate(t.Id, 1)
+
+	store := storage.NewMemoryStore()
+	objectStore = store
+	defer func() { objectStore = nil }()
+	c.Assert(moveAttachmentsToStorage(), check.Equals, nil)
+	c.Assert(store.Objects, check.HasLen, 1)
+	stored := Attachment{}
+	c.Assert(db.Where("template_id = ?", t.Id).First(&stored).Error, check.Equals, nil)
+	c.Assert(stored.Content, check.Equals, "")
+	got, err := GetTemplate(t.Id, 1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(got.Attachments[0].Content, check.Equals, content)
+}
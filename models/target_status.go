@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// The lifecycle states of targets. Targets without a status are active.
+// Targets who are on leave or have exited aren't sent campaigns, but their
+// results in earlier campaigns are kept.
+const (
+	TargetActive  = "active"
+	TargetOnLeave = "on_leave"
+	TargetExited  = "exited"
+)
+
+// ErrInvalidTargetStatus is thrown when a target's status isn't one of the
+// lifecycle states
+var ErrInvalidTargetStatus = errors.New("Invalid target status")
+
+// validTargetStatus returns whether the status is a lifecycle state.
+func validTargetStatus(status string) bool {
+	switch status {
+	case "", TargetActive, TargetOnLeave, TargetExited:
+		return true
+	}
+	return false
+}
+
+// Suppressed returns whether campaigns should skip the target.
+func (t *Target) Suppressed() bool {
+	return t.Status == TargetOnLeave || t.Status == TargetExited
+}
+
+// removeSuppressedTargets removes the targets which campaigns should skip
+// from the group, returning how many were removed.
+func (g *Group) removeSuppressedTargets() int {
+	active := make([]Target, 0, len(g.Targets))
+	for _, t := range g.Targets {
+		if !t.Suppressed() {
+			active = append(active, t)
+		}
+	}
+	removed := len(g.Targets) - len(active)
+	g.Targets = active
+	return removed
+}
+
+// TargetStatusRequest sets the status of targets across all of a user's
+// groups, such as when syncing with a directory.
+type TargetStatusRequest struct {
+	Status string   `json:"status"`
+	Emails []string `json:"emails"`
+}
+
+// SetTargetStatus sets the status of the targets with the given email
+// addresses in every group owned by the user. Emails which aren't in any of
+// the user's groups are listed in NotFound.
+func SetTargetStatus(uid int64, req TargetStatusRequest) (BulkTargetResult, error) {
+	result := BulkTargetResult{NotFound: []string{}}
+	if req.Status == "" || !validTargetStatus(req.Status) {
+		return result, ErrInvalidTargetStatus
+	}
+	if len(req.Emails) == 0 {
+		return result, ErrNoTargetsSpecified
+	}
+	rows := []struct {
+		Id      int64
+		Email   string
+		GroupId int64
+	}{}
+	err := db.Table("targets").Select("targets.id, targets.email, gt.group_id").
+		Joins("join group_targets gt ON targets.id = gt.target_id").
+		Joins("join groups g ON g.id = gt.group_id").
+		Where("g.user_id = ? AND targets.email IN (?)", uid, req.Emails).
+		Scan(&rows).Error
+	if err != nil {
+		return result, err
+	}
+	ids := []int64{}
+	groupIds := []int64{}
+	found := map[string]bool{}
+	for _, r := range rows {
+		ids = append(ids, r.Id)
+		groupIds = append(groupIds, r.GroupId)
+		found[r.Email] = true
+	}
+	counted := map[string]bool{}
+	for _, email := range req.Emails {
+		if counted[email] {
+			continue
+		}
+		counted[email] = true
+		if found[email] {
+			result.Updated++
+		} else {
+			result.NotFound = append(result.NotFound, email)
+		}
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	tx := db.Begin()
+	err = tx.Table("targets").Where("id IN (?)", ids).Update("status", req.Status).Error
+	if err != nil {
+		tx.Rollback()
+		return BulkTargetResult{NotFound: []string{}}, err
+	}
+	err = tx.Table("groups").Where("id IN (?)", groupIds).Update("modified_date", time.Now().UTC()).Error
+	if err != nil {
+		tx.Rollback()
+		return BulkTargetResult{NotFound: []string{}}, err
+	}
+	return result, tx.Commit().Error
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestTargetStatus(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	g.Targets[0].Status = "retired"
+	ch.Assert(PutGroup(&g), check.NotNil)
+	g.Targets[0].Status = TargetExited
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+
+	// Targets can be set on leave in every group at once
+	_, err := SetTargetStatus(1, TargetStatusRequest{Status: "retired", Emails: []string{g.Targets[1].Email}})
+	ch.Assert(err, check.Equals, ErrInvalidTargetStatus)
+	result, err := SetTargetStatus(1, TargetStatusRequest{
+		Status: TargetOnLeave,
+		Emails: []string{g.Targets[1].Email, g.Targets[1].Email, "missing@example.com"},
+	})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Updated, check.Equals, 1)
+	ch.Assert(result.NotFound, check.DeepEquals, []string{"missing@example.com"})
+	// Other users' groups aren't changed
+	result, err = SetTargetStatus(2, TargetStatusRequest{Status: TargetActive, Emails: []string{g.Targets[1].Email}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(result.Updated, check.Equals, 0)
+
+	ts, err := GetTargets(g.Id)
+	ch.Assert(err, check.Equals, nil)
+	statuses := map[string]string{}
+	for _, t := range ts {
+		statuses[t.Email] = t.Status
+	}
+	ch.Assert(statuses[g.Targets[0].Email], check.Equals, TargetExited)
+	ch.Assert(statuses[g.Targets[1].Email], check.Equals, TargetOnLeave)
+
+	// Updating a target's details without a status keeps its status
+	_, err = UpdateTargets(g.Id, 1, []Target{{BaseRecipient: BaseRecipient{Email: g.Targets[1].Email, FirstName: "Renamed"}}})
+	ch.Assert(err, check.Equals, nil)
+	ts, _ = GetTargets(g.Id)
+	for _, t := range ts {
+		if t.Email == g.Targets[1].Email {
+			ch.Assert(t.Status, check.Equals, TargetOnLeave)
+		}
+	}
+
+	// Suppressed targets aren't sent the campaign
+	c.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(len(c.Results), check.Equals, len(g.Targets)-2)
+	for _, r := range c.Results {
+		ch.Assert(r.Email, check.Not(check.Equals), g.Targets[0].Email)
+		ch.Assert(r.Email, check.Not(check.Equals), g.Targets[1].Email)
+	}
+
+	// Returning targets are sent later campaigns, while earlier results are
+	// kept
+	_, err = SetTargetStatus(1, TargetStatusRequest{Status: TargetActive, Emails: []string{g.Targets[1].Email}})
+	ch.Assert(err, check.Equals, nil)
+	ar, err := AddCampaignRecipients(c.Id, 1, AddRecipientsRequest{Groups: []Group{{Name: g.Name}}})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ar.Results), check.Equals, 1)
+	ch.Assert(ar.Results[0].Email, check.Equals, g.Targets[1].Email)
+	ch.Assert(ar.Skipped, check.Equals, len(g.Targets)-1)
+}
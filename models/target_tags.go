@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// UntaggedRollup is the rollup of the results whose recipients have no tags.
+const UntaggedRollup = "Untagged"
+
+// ErrCampaignNotPending is returned when approving a campaign which isn't
+// waiting for approval.
+var ErrCampaignNotPending = errors.New("The campaign isn't waiting for approval")
+
+// ErrSelfApproval is returned when a user approves their own campaign.
+var ErrSelfApproval = errors.New("Campaigns must be approved by someone other than their owner")
+
+// ErrApprovalRequired is returned when adding recipients with approval tags
+// to a campaign which wasn't approved.
+var ErrApprovalRequired = errors.New("Recipients with these tags can only be added to approved campaigns")
+
+// normalizeTags returns the comma separated list of tags without empty tags,
+// duplicates or surrounding spaces.
+func normalizeTags(tags string) string {
+	return joinTags(strings.Split(tags, ","))
+}
+
+// splitTags returns the tags in the comma separated list.
+func splitTags(tags string) []string {
+	tags = normalizeTags(tags)
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+// hasAnyTag returns whether the comma separated list has any of the tags,
+// ignoring case.
+func hasAnyTag(tags string, any []string) bool {
+	for _, t := range splitTags(tags) {
+		for _, a := range any {
+			if strings.EqualFold(t, strings.TrimSpace(a)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiresApproval returns whether campaigns sent to the target must be
+// approved first, because it has one of the configured approval tags.
+func (t *Target) requiresApproval() bool {
+	return conf != nil && hasAnyTag(t.Tags, conf.ApprovalTags)
+}
+
+// includesTarget returns whether the campaign is sent to the target, based on
+// the tags it includes and excludes.
+func (c *Campaign) includesTarget(t Target) bool {
+	if c.IncludeTags != "" && !hasAnyTag(t.Tags, splitTags(c.IncludeTags)) {
+		return false
+	}
+	return !hasAnyTag(t.Tags, splitTags(c.ExcludeTags))
+}
+
+// filterTargets removes the targets the campaign isn't sent to because of
+// their tags from the group, returning how many were removed.
+func (c *Campaign) filterTargets(g *Group) int {
+	included := make([]Target, 0, len(g.Targets))
+	for _, t := range g.Targets {
+		if c.includesTarget(t) {
+			included = append(included, t)
+		}
+	}
+	removed := len(g.Targets) - len(included)
+	g.Targets = included
+	return removed
+}
+
+// ApproveCampaign approves the campaign waiting for approval, so that its
+// emails are sent from its launch date. Any user who can modify the system,
+// other than the campaign's owner, can approve it.
+func ApproveCampaign(id int64, approver User) (Campaign, error) {
+	c := Campaign{}
+	err := db.Where("id=?", id).Find(&c).Error
+	if err != nil {
+		return c, err
+	}
+	if c.Status != CampaignPending {
+		return c, ErrCampaignNotPending
+	}
+	if c.UserId == approver.Id {
+		return c, ErrSelfApproval
+	}
+	c.Status = CampaignQueued
+	c.ApprovedBy = approver.Id
+	err = db.Model(&Campaign{}).Where("id=?", c.Id).Updates(map[string]interface{}{
+		"status":      c.Status,
+		"approved_by": c.ApprovedBy,
+	}).Error
+	if err != nil {
+		return c, err
+	}
+	details, err := json.Marshal(map[string]string{"approved_by": approver.Username})
+	if err != nil {
+		return c, err
+	}
+	err = AddEvent(&Event{Message: "Campaign Approved", Details: EncryptedString(details)}, c.Id)
+	if err != nil && err != ErrEventSuppressed {
+		return c, err
+	}
+	return c, nil
+}
+
+// GetTagRollup returns the results of the user's campaigns by the tags their
+// recipients had when the campaigns were launched. Results whose recipients
+// have several tags are counted in each tag's rollup. If campaign ids are
+// given, only the results of those campaigns are included.
+func GetTagRollup(uid int64, cids []int64) ([]OrgRollup, error) {
+	rs := []Result{}
+	query := db.Where("user_id=?", uid)
+	if len(cids) > 0 {
+		query = query.Where("campaign_id IN (?)", cids)
+	}
+	err := query.Find(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	rollups := map[string]*OrgRollup{}
+	for _, r := range rs {
+		tags := splitTags(r.TargetTags)
+		if len(tags) == 0 {
+			tags = []string{UntaggedRollup}
+		}
+		for _, tag := range tags {
+			if _, ok := rollups[tag]; !ok {
+				rollups[tag] = &OrgRollup{Name: tag}
+			}
+			rollups[tag].add(r)
+		}
+	}
+	return sortedRollups(rollups), nil
+}
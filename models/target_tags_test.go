@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestTargetTags(ch *check.C) {
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	g.Targets[0].Tags = " VIP, finance,,VIP"
+	g.Targets[1].Tags = "it-admin"
+	g.Targets[2].Tags = "finance"
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+	ch.Assert(g.Targets[0].Tags, check.Equals, "VIP,finance")
+
+	// Campaigns include and exclude targets by their tags
+	c.Groups = []Group{{Name: g.Name}}
+	c.IncludeTags = "finance, it-admin"
+	c.ExcludeTags = "vip"
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.Status, check.Equals, CampaignInProgress)
+	tags := map[string]string{}
+	for _, r := range c.Results {
+		tags[r.Email] = r.TargetTags
+	}
+	ch.Assert(tags, check.DeepEquals, map[string]string{
+		g.Targets[1].Email: "it-admin",
+		g.Targets[2].Email: "finance",
+	})
+
+	// Results are rolled up by the tags their recipients had
+	rollups, err := GetTagRollup(1, []int64{c.Id})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rollups), check.Equals, 2)
+	ch.Assert(rollups[0].Name, check.Equals, "finance")
+	ch.Assert(rollups[0].Total, check.Equals, int64(1))
+	ch.Assert(rollups[1].Name, check.Equals, "it-admin")
+}
+
+func (s *ModelsSuite) TestCampaignApproval(ch *check.C) {
+	conf.ApprovalTags = []string{"vip", "ceo"}
+	defer func() { conf.ApprovalTags = nil }()
+	c := s.createCampaignDependencies(ch)
+	g := c.Groups[0]
+	g.Targets[0].Tags = "VIP"
+	ch.Assert(PutGroup(&g), check.Equals, nil)
+
+	c.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c, c.UserId), check.Equals, nil)
+	ch.Assert(c.Status, check.Equals, CampaignPending)
+	for _, r := range c.Results {
+		ch.Assert(r.Status, check.Equals, StatusScheduled)
+	}
+	// The emails are held until the campaign is approved
+	ms, err := GetQueuedMailLogs(time.Now().UTC())
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, 0)
+
+	_, err = ApproveCampaign(c.Id, User{Id: c.UserId, Username: "admin"})
+	ch.Assert(err, check.Equals, ErrSelfApproval)
+	approved, err := ApproveCampaign(c.Id, User{Id: c.UserId + 1, Username: "approver"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(approved.Status, check.Equals, CampaignQueued)
+	ch.Assert(approved.ApprovedBy, check.Equals, c.UserId+1)
+	_, err = ApproveCampaign(c.Id, User{Id: c.UserId + 1, Username: "approver"})
+	ch.Assert(err, check.Equals, ErrCampaignNotPending)
+
+	ms, err = GetQueuedMailLogs(time.Now().UTC())
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(ms), check.Equals, len(c.Results))
+
+	// Campaigns which weren't approved can't have VIPs added to them
+	c2 := Campaign{Name: "Without VIPs", Template: c.Template, Page: c.Page, SMTP: c.SMTP, ExcludeTags: "vip"}
+	c2.Groups = []Group{{Name: g.Name}}
+	ch.Assert(PostCampaign(&c2, c.UserId), check.Equals, nil)
+	ch.Assert(c2.Status, check.Equals, CampaignInProgress)
+	ceo := AddRecipientsRequest{
+		Targets: []Target{{BaseRecipient: BaseRecipient{Email: "ceo@example.com"}, Tags: "ceo"}},
+	}
+	_, err = AddCampaignRecipients(c2.Id, c.UserId, ceo)
+	ch.Assert(err, check.Equals, ErrApprovalRequired)
+	ar, err := AddCampaignRecipients(c.Id, c.UserId, ceo)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(ar.Results[0].TargetTags, check.Equals, "ceo")
+}
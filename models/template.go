@@ -2,6 +2,8 @@ package models
 
 import (
 	"errors"
+	"net/mail"
+	"strings"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
@@ -13,11 +15,36 @@ type Template struct {
 	Id           int64        `json:"id" gorm:"column:id; primary_key:yes"`
 	UserId       int64        `json:"-" gorm:"column:user_id"`
 	Name         string       `json:"name"`
+	ExternalId   string       `json:"external_id,omitempty"`
 	Subject      string       `json:"subject"`
 	Text         string       `json:"text"`
 	HTML         string       `json:"html" gorm:"column:html"`
 	ModifiedDate time.Time    `json:"modified_date"`
 	Attachments  []Attachment `json:"attachments"`
+	// GenerateText derives the text part of emails from the HTML when the
+	// template has no text.
+	GenerateText bool `json:"generate_text"`
+	// FromName, EnvelopeSender, and ReplyTo override the sender of emails
+	// independently of the sending profile, so that one profile can be
+	// used for several personas. The FromName only replaces the display
+	// name of the profile's from address.
+	FromName       string `json:"from_name"`
+	EnvelopeSender string `json:"envelope_sender"`
+	ReplyTo        string `json:"reply_to"`
+	// Thread sends emails as replies to a fake prior message from
+	// ThreadFrom, which defaults to the recipient. The ThreadBody is quoted
+	// below the email. Both are templated with the recipient's details.
+	Thread     bool   `json:"thread"`
+	ThreadFrom string `json:"thread_from"`
+	ThreadBody string `json:"thread_body"`
+	// CueCount and PremiseAlignment rate how difficult emails are to detect
+	// using the NIST Phish Scale. The Difficulty is derived from them.
+	CueCount         int    `json:"cue_count"`
+	PremiseAlignment string `json:"premise_alignment"`
+	Difficulty       string `json:"difficulty" gorm:"-"`
+	// Translations are localized versions of the template, sent to
+	// recipients based on their language.
+	Translations []TemplateTranslation `json:"translations" gorm:"-"`
 }
 
 // ErrTemplateNameNotSpecified is thrown when a template name is not specified
@@ -26,6 +53,10 @@ var ErrTemplateNameNotSpecified = errors.New("Template name not specified")
 // ErrTemplateMissingParameter is thrown when a needed parameter is not provided
 var ErrTemplateMissingParameter = errors.New("Need to specify at least plaintext or HTML content")
 
+// ErrSenderDomainNotAllowed is thrown when a template's envelope sender or
+// Reply-To address uses a domain which isn't in the configured sender domains
+var ErrSenderDomainNotAllowed = errors.New("The envelope sender and Reply-To address must use an allowed sender domain")
+
 // Validate checks the given template to make sure values are appropriate and complete
 func (t *Template) Validate() error {
 	switch {
@@ -34,28 +65,99 @@ func (t *Template) Validate() error {
 	case t.Text == "" && t.HTML == "":
 		return ErrTemplateMissingParameter
 	}
+	for _, address := range []string{t.EnvelopeSender, t.ReplyTo} {
+		if address == "" {
+			continue
+		}
+		a, err := mail.ParseAddress(address)
+		if err != nil {
+			return err
+		}
+		if !senderDomainAllowed(a.Address) {
+			return ErrSenderDomainNotAllowed
+		}
+	}
 	if err := ValidateTemplate(t.HTML); err != nil {
 		return err
 	}
 	if err := ValidateTemplate(t.Text); err != nil {
 		return err
 	}
+	if err := ValidateTemplate(t.ThreadFrom); err != nil {
+		return err
+	}
+	if err := ValidateTemplate(t.ThreadBody); err != nil {
+		return err
+	}
+	if err := t.validateDifficulty(); err != nil {
+		return err
+	}
 	for _, a := range t.Attachments {
 		if err := a.Validate(); err != nil {
 			return err
 		}
 	}
+	if err := t.validateTranslations(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// senderDomainAllowed returns whether the address uses one of the configured
+// sender domains, or a subdomain of one.
+func senderDomainAllowed(address string) bool {
+	if conf == nil || len(conf.SenderDomains) == 0 {
+		return true
+	}
+	domain := strings.ToLower(asciiDomain(address[strings.LastIndex(address, "@")+1:]))
+	for _, allowed := range conf.SenderDomains {
+		allowed = strings.ToLower(asciiDomain(allowed))
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// envelopeFrom returns the envelope sender of the template's emails, or an
+// empty string if the from address is used.
+func (t *Template) envelopeFrom() string {
+	if t.EnvelopeSender == "" {
+		return ""
+	}
+	a, err := mail.ParseAddress(t.EnvelopeSender)
+	if err != nil {
+		return ""
+	}
+	return asciiAddress(a.Address)
+}
+
 // GetTemplates returns the templates owned by the given user.
 func GetTemplates(uid int64) ([]Template, error) {
+	ts, _, err := ListTemplates(uid, ListOptions{})
+	return ts, err
+}
+
+// templateListFields are the fields templates can be sorted and filtered by
+var templateListFields = listFields{
+	"id":            "id",
+	"name":          "name",
+	"modified_date": "modified_date",
+}
+
+// ListTemplates returns the templates owned by the given user, filtered,
+// sorted, and paginated using the given options.
+func ListTemplates(uid int64, opts ListOptions) ([]Template, PageInfo, error) {
 	ts := []Template{}
-	err := db.Where("user_id=?", uid).Find(&ts).Error
+	query, total, err := opts.apply(db.Table("templates").Where("user_id=?", uid), templateListFields)
+	if err != nil {
+		return ts, PageInfo{}, err
+	}
+	err = query.Find(&ts).Error
 	if err != nil {
 		log.Error(err)
-		return ts, err
+		return ts, PageInfo{}, err
 	}
 	for i := range ts {
 		// Get Attachments
@@ -65,10 +167,20 @@ func GetTemplates(uid int64) ([]Template, error) {
 		}
 		if err != nil && err != gorm.ErrRecordNotFound {
 			log.Error(err)
-			return ts, err
+			return ts, PageInfo{}, err
+		}
+		err = loadAttachmentContent(ts[i].Attachments)
+		if err != nil {
+			log.Error(err)
+			return ts, PageInfo{}, err
+		}
+		err = ts[i].getTranslations()
+		if err != nil {
+			log.Error(err)
+			return ts, PageInfo{}, err
 		}
 	}
-	return ts, err
+	return ts, opts.pageInfo(&ts, total, templateListFields), err
 }
 
 // GetTemplate returns the template, if it exists, specified by the given id and user_id.
@@ -89,6 +201,12 @@ func GetTemplate(id int64, uid int64) (Template, error) {
 	if err == nil && len(t.Attachments) == 0 {
 		t.Attachments = make([]Attachment, 0)
 	}
+	if err == nil {
+		err = loadAttachmentContent(t.Attachments)
+	}
+	if err == nil {
+		err = t.getTranslations()
+	}
 	return t, err
 }
 
@@ -110,6 +228,12 @@ func GetTemplateByName(n string, uid int64) (Template, error) {
 	if err == nil && len(t.Attachments) == 0 {
 		t.Attachments = make([]Attachment, 0)
 	}
+	if err == nil {
+		err = loadAttachmentContent(t.Attachments)
+	}
+	if err == nil {
+		err = t.getTranslations()
+	}
 	return t, err
 }
 
@@ -119,6 +243,9 @@ func PostTemplate(t *Template) error {
 	if err := t.Validate(); err != nil {
 		return err
 	}
+	if err := validateExternalId("templates", t.ExternalId, t.UserId, t.Id); err != nil {
+		return err
+	}
 	err := db.Save(t).Error
 	if err != nil {
 		log.Error(err)
@@ -128,13 +255,17 @@ func PostTemplate(t *Template) error {
 	// Save every attachment
 	for i := range t.Attachments {
 		t.Attachments[i].TemplateId = t.Id
-		err := db.Save(&t.Attachments[i]).Error
+		err := saveAttachment(&t.Attachments[i])
 		if err != nil {
 			log.Error(err)
 			return err
 		}
 	}
-	return nil
+	err = t.saveTranslations()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
 }
 
 // PutTemplate edits an existing template in the database.
@@ -143,8 +274,16 @@ func PutTemplate(t *Template) error {
 	if err := t.Validate(); err != nil {
 		return err
 	}
+	if err := validateExternalId("templates", t.ExternalId, t.UserId, t.Id); err != nil {
+		return err
+	}
+	keys, err := attachmentStorageKeys(t.Id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	// Delete all attachments, and replace with new ones
-	err := db.Where("template_id=?", t.Id).Delete(&Attachment{}).Error
+	err = db.Where("template_id=?", t.Id).Delete(&Attachment{}).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		log.Error(err)
 		return err
@@ -154,12 +293,13 @@ func PutTemplate(t *Template) error {
 	}
 	for i := range t.Attachments {
 		t.Attachments[i].TemplateId = t.Id
-		err := db.Save(&t.Attachments[i]).Error
+		err := saveAttachment(&t.Attachments[i])
 		if err != nil {
 			log.Error(err)
 			return err
 		}
 	}
+	deleteUnusedObjects(keys)
 
 	// Save final template
 	err = db.Where("id=?", t.Id).Save(t).Error
@@ -167,14 +307,30 @@ func PutTemplate(t *Template) error {
 		log.Error(err)
 		return err
 	}
-	return nil
+	err = t.saveTranslations()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
 }
 
 // DeleteTemplate deletes an existing template in the database.
 // An error is returned if a template with the given user id and template id is not found.
 func DeleteTemplate(id int64, uid int64) error {
+	keys, err := attachmentStorageKeys(id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
 	// Delete attachments
-	err := db.Where("template_id=?", id).Delete(&Attachment{}).Error
+	err = db.Where("template_id=?", id).Delete(&Attachment{}).Error
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	deleteUnusedObjects(keys)
+
+	err = db.Where("template_id=?", id).Delete(&TemplateTranslation{}).Error
 	if err != nil {
 		log.Error(err)
 		return err
@@ -188,3 +344,19 @@ func DeleteTemplate(id int64, uid int64) error {
 	}
 	return nil
 }
+
+// DeleteTemplates deletes the templates owned by the given user which match
+// the filters, returning the number of templates deleted.
+func DeleteTemplates(uid int64, filters map[string]string) (int, error) {
+	ids, err := ListOptions{Filters: filters}.matchingIds(db.Table("templates").Where("user_id=?", uid), templateListFields)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		err = DeleteTemplate(id, uid)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"container/list"
+	"sync"
+	"text/template"
+)
+
+// TemplateCacheSize is the number of parsed templates kept in memory. Every
+// email in a campaign is generated from the same subject, body, and header
+// templates, so parsing them once avoids the dominant cost of large sends.
+const TemplateCacheSize = 512
+
+// templateCache is a least recently used cache of parsed templates, keyed by
+// the template text. Since the key is the text itself, changes to a template
+// are never served from the cache.
+type templateCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type templateCacheEntry struct {
+	text string
+	tmpl *template.Template
+}
+
+func newTemplateCache(size int) *templateCache {
+	return &templateCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+var parsedTemplates = newTemplateCache(TemplateCacheSize)
+
+// parse returns the parsed template for the text, parsing it if it isn't
+// already cached. Parsed templates are safe to execute concurrently.
+func (tc *templateCache) parse(text string) (*template.Template, error) {
+	tc.mu.Lock()
+	if e, ok := tc.entries[text]; ok {
+		tc.order.MoveToFront(e)
+		tc.mu.Unlock()
+		return e.Value.(*templateCacheEntry).tmpl, nil
+	}
+	tc.mu.Unlock()
+	tmpl, err := template.New("template").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if e, ok := tc.entries[text]; ok {
+		tc.order.MoveToFront(e)
+		return e.Value.(*templateCacheEntry).tmpl, nil
+	}
+	tc.entries[text] = tc.order.PushFront(&templateCacheEntry{text: text, tmpl: tmpl})
+	for tc.order.Len() > tc.size {
+		oldest := tc.order.Back()
+		tc.order.Remove(oldest)
+		delete(tc.entries, oldest.Value.(*templateCacheEntry).text)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"text/template"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestTemplateCache(ch *check.C) {
+	tc := newTemplateCache(2)
+	first, err := tc.parse("{{.FirstName}}")
+	ch.Assert(err, check.Equals, nil)
+	again, err := tc.parse("{{.FirstName}}")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(again, check.Equals, first)
+
+	// Parse errors aren't cached
+	_, err = tc.parse("{{.FirstName")
+	ch.Assert(err, check.NotNil)
+	ch.Assert(tc.order.Len(), check.Equals, 1)
+
+	// The least recently used template is evicted once the cache is full
+	_, err = tc.parse("{{.LastName}}")
+	ch.Assert(err, check.Equals, nil)
+	_, err = tc.parse("{{.FirstName}}")
+	ch.Assert(err, check.Equals, nil)
+	_, err = tc.parse("{{.Email}}")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(tc.order.Len(), check.Equals, 2)
+	_, ok := tc.entries["{{.LastName}}"]
+	ch.Assert(ok, check.Equals, false)
+	again, err = tc.parse("{{.FirstName}}")
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(again, check.Equals, first)
+}
+
+func (s *ModelsSuite) TestExecuteTemplateCached(ch *check.C) {
+	text := "Hello {{.FirstName}}"
+	for _, name := range []string{"Alice", "Bob"} {
+		got, err := ExecuteTemplate(text, BaseRecipient{FirstName: name})
+		ch.Assert(err, check.Equals, nil)
+		ch.Assert(got, check.Equals, fmt.Sprintf("Hello %s", name))
+	}
+}
+
+const benchmarkTemplate = `<html><body><p>Hi {{.FirstName}} {{.LastName}},</p>
+<p>Please review the attached document at <a href="{{.URL}}">{{.URL}}</a>.</p>
+{{if .Position}}<p>As {{.Position}}, your approval is required.</p>{{end}}
+<p>Thanks,<br>{{.From}}</p>{{.Tracker}}</body></html>`
+
+func benchmarkContext() PhishingTemplateContext {
+	return PhishingTemplateContext{
+		From:          "IT Support",
+		URL:           "http://example.com/?rid=abc1234",
+		Tracker:       "<img alt='' style='display: none' src='http://example.com/track?rid=abc1234'/>",
+		RId:           "abc1234",
+		BaseRecipient: BaseRecipient{FirstName: "Jane", LastName: "Doe", Position: "Manager"},
+	}
+}
+
+func BenchmarkExecuteTemplate(b *testing.B) {
+	ptx := benchmarkContext()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := ExecuteTemplate(benchmarkTemplate, ptx)
+		if err != nil {
+			b.Fatalf("error executing template: %v", err)
+		}
+	}
+}
+
+func BenchmarkExecuteTemplateUncached(b *testing.B) {
+	ptx := benchmarkContext()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buff := bytes.Buffer{}
+		tmpl, err := template.New("template").Parse(benchmarkTemplate)
+		if err != nil {
+			b.Fatalf("error parsing template: %v", err)
+		}
+		err = tmpl.Execute(&buff, ptx)
+		if err != nil {
+			b.Fatalf("error executing template: %v", err)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"net/mail"
 	"net/url"
 	"path"
-	"text/template"
 )
 
 // TemplateContext is an interface that allows both campaigns and email
@@ -18,15 +17,34 @@ type TemplateContext interface {
 // PhishingTemplateContext is the context that is sent to any template, such
 // as the email or landing page content.
 type PhishingTemplateContext struct {
-	From        string
-	URL         string
-	Tracker     string
-	TrackingURL string
-	RId         string
-	BaseURL     string
+	From           string
+	URL            string
+	Tracker        string
+	TrackingURL    string
+	UnsubscribeURL string
+	RId            string
+	BaseURL        string
+	// HoneytokenUsername and HoneytokenPassword are the campaign's
+	// honeytoken credentials, which are empty for test emails and previews.
+	HoneytokenUsername string
+	HoneytokenPassword string
+	// LongURL is the recipient's full link. It's the same as the URL, unless
+	// the campaign uses short links or open redirects.
+	LongURL string
+	// FormStep is the step of the landing page's form shown to the
+	// recipient, starting at 1. FormError is shown when the page rejects
+	// the recipient's submission. Both are only set for landing pages.
+	FormStep  int
+	FormError string
 	BaseRecipient
 }
 
+// honeytokenContext is implemented by template contexts which have a
+// honeytoken.
+type honeytokenContext interface {
+	getHoneytoken() Honeytoken
+}
+
 // NewPhishingTemplateContext returns a populated PhishingTemplateContext,
 // parsing the correct fields from the provided TemplateContext and recipient.
 func NewPhishingTemplateContext(ctx TemplateContext, r BaseRecipient, rid string) (PhishingTemplateContext, error) {
@@ -43,12 +61,18 @@ func NewPhishingTemplateContext(ctx TemplateContext, r BaseRecipient, rid string
 		return PhishingTemplateContext{}, err
 	}
 
-	// For the base URL, we'll reset the the path and the query
-	// This will create a URL in the form of http://example.com
-	baseURL, err := url.Parse(templateURL)
+	// Internationalized domains are converted to punycode, since they'd
+	// otherwise be percent-encoded and the links wouldn't work.
+	u, err := url.Parse(templateURL)
 	if err != nil {
 		return PhishingTemplateContext{}, err
 	}
+	u.Host = asciiHost(u.Host)
+	templateURL = u.String()
+
+	// For the base URL, we'll reset the the path and the query
+	// This will create a URL in the form of http://example.com
+	baseURL, _ := url.Parse(templateURL)
 	baseURL.Path = ""
 	baseURL.RawQuery = ""
 
@@ -61,22 +85,47 @@ func NewPhishingTemplateContext(ctx TemplateContext, r BaseRecipient, rid string
 	trackingURL.Path = path.Join(trackingURL.Path, "/track")
 	trackingURL.RawQuery = q.Encode()
 
-	return PhishingTemplateContext{
-		BaseRecipient: r,
-		BaseURL:       baseURL.String(),
-		URL:           phishURL.String(),
-		TrackingURL:   trackingURL.String(),
-		Tracker:       "<img alt='' style='display: none' src='" + trackingURL.String() + "'/>",
-		From:          fn,
-		RId:           rid,
-	}, nil
+	unsubscribeURL, _ := url.Parse(templateURL)
+	unsubscribeURL.Path = path.Join(unsubscribeURL.Path, "/unsubscribe")
+	unsubscribeURL.RawQuery = q.Encode()
+
+	ptx := PhishingTemplateContext{
+		BaseRecipient:  r,
+		BaseURL:        baseURL.String(),
+		URL:            phishURL.String(),
+		LongURL:        phishURL.String(),
+		TrackingURL:    trackingURL.String(),
+		UnsubscribeURL: unsubscribeURL.String(),
+		Tracker:        "<img alt='' style='display: none' src='" + trackingURL.String() + "'/>",
+		From:           fn,
+		RId:            rid,
+	}
+	if sc, ok := ctx.(shortURLContext); ok && sc.getShortURL() != "" {
+		ptx.URL, err = shortLink(sc.getShortURL(), rid)
+		if err != nil {
+			return PhishingTemplateContext{}, err
+		}
+	}
+	if rc, ok := ctx.(redirectContext); ok && len(rc.getRedirects()) > 0 {
+		ptx.URL, err = wrapRedirects(rc.getRedirects(), ptx)
+		if err != nil {
+			return PhishingTemplateContext{}, err
+		}
+	}
+	if hc, ok := ctx.(honeytokenContext); ok {
+		ht := hc.getHoneytoken()
+		ptx.HoneytokenUsername = ht.Username
+		ptx.HoneytokenPassword = string(ht.Password)
+	}
+	return ptx, nil
 }
 
 // ExecuteTemplate creates a templated string based on the provided
-// template body and data.
+// template body and data. Parsed templates are cached, so the same text can
+// be executed for each recipient without being parsed again.
 func ExecuteTemplate(text string, data interface{}) (string, error) {
 	buff := bytes.Buffer{}
-	tmpl, err := template.New("template").Parse(text)
+	tmpl, err := parsedTemplates.parse(text)
 	if err != nil {
 		return buff.String(), err
 	}
@@ -33,14 +33,16 @@ func (s *ModelsSuite) TestNewTemplateContext(c *check.C) {
 		FromAddress: "From Address <from@example.com>",
 	}
 	expected := PhishingTemplateContext{
-		URL:           fmt.Sprintf("%s?rid=%s", ctx.URL, r.RId),
-		BaseURL:       ctx.URL,
-		BaseRecipient: r.BaseRecipient,
-		TrackingURL:   fmt.Sprintf("%s/track?rid=%s", ctx.URL, r.RId),
-		From:          "From Address",
-		RId:           r.RId,
+		URL:            fmt.Sprintf("%s?rid=%s", ctx.URL, r.RId),
+		BaseURL:        ctx.URL,
+		BaseRecipient:  r.BaseRecipient,
+		TrackingURL:    fmt.Sprintf("%s/track?rid=%s", ctx.URL, r.RId),
+		UnsubscribeURL: fmt.Sprintf("%s/unsubscribe?rid=%s", ctx.URL, r.RId),
+		From:           "From Address",
+		RId:            r.RId,
 	}
 	expected.Tracker = "<img alt='' style='display: none' src='" + expected.TrackingURL + "'/>"
+	expected.LongURL = expected.URL
 	got, err := NewPhishingTemplateContext(ctx, r.BaseRecipient, r.RId)
 	c.Assert(err, check.Equals, nil)
 	c.Assert(got, check.DeepEquals, expected)
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// skippedElements are the elements whose content isn't shown to recipients
+var skippedElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"title":    true,
+	"noscript": true,
+}
+
+// paragraphElements are separated from the surrounding text by a blank line
+var paragraphElements = map[string]bool{
+	"p":          true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"table":      true,
+	"ul":         true,
+	"ol":         true,
+	"blockquote": true,
+	"pre":        true,
+	"hr":         true,
+}
+
+// lineElements start on a new line
+var lineElements = map[string]bool{
+	"div":     true,
+	"tr":      true,
+	"section": true,
+	"article": true,
+	"header":  true,
+	"footer":  true,
+	"center":  true,
+}
+
+var (
+	horizontalSpace = regexp.MustCompile(`[ \t]+`)
+	blankLines      = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText converts the HTML body of an email to plain text. Links are
+// written as the link text followed by the URL in parentheses, so that
+// tracked links still work from the text part.
+func htmlToText(h string) (string, error) {
+	d, err := goquery.NewDocumentFromReader(strings.NewReader(h))
+	if err != nil {
+		return "", err
+	}
+	b := &strings.Builder{}
+	writeText(b, d.Selection)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(horizontalSpace.ReplaceAllString(line, " "))
+	}
+	text := blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text), nil
+}
+
+// writeText writes the text of each of the selection's child nodes.
+func writeText(b *strings.Builder, s *goquery.Selection) {
+	s.Contents().Each(func(_ int, c *goquery.Selection) {
+		name := goquery.NodeName(c)
+		switch {
+		case name == "#text":
+			b.WriteString(strings.NewReplacer("\n", " ", "\r", " ", "\u00a0", " ").Replace(c.Text()))
+		case skippedElements[name]:
+		case name == "br":
+			b.WriteString("\n")
+		case name == "td" || name == "th":
+			writeText(b, c)
+			b.WriteString(" ")
+		case name == "img":
+			alt, _ := c.Attr("alt")
+			b.WriteString(alt)
+		case name == "a":
+			start := b.Len()
+			writeText(b, c)
+			label := strings.TrimSpace(b.String()[start:])
+			href, _ := c.Attr("href")
+			href = strings.TrimSpace(href)
+			if href != "" && !strings.HasPrefix(href, "#") && href != label && "mailto:"+label != href {
+				b.WriteString(" (" + href + ")")
+			}
+		case name == "li":
+			b.WriteString("\n* ")
+			writeText(b, c)
+		case paragraphElements[name]:
+			b.WriteString("\n\n")
+			writeText(b, c)
+			b.WriteString("\n\n")
+		case lineElements[name]:
+			b.WriteString("\n")
+			writeText(b, c)
+			b.WriteString("\n")
+		default:
+			writeText(b, c)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gophish/gomail"
+	"github.com/jordan-wright/email"
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestHTMLToText(ch *check.C) {
+	h := `<html><head><title>Ignored</title><style>p { color: red; }</style></head>
+<body>
+	<h1>Password   expiry</h1>
+	<p>Hi John,<br>your password expires&nbsp;today.</p>
+	<p>Please <a href="http://example.com/?rid=1234567">reset it</a> now.</p>
+	<ul><li>First</li><li>Second</li></ul>
+	<table><tr><td>Name</td><td>John</td></tr></table>
+	<p><a href="http://example.com">http://example.com</a> <a href="#top">Top</a> <a href="mailto:help@example.com">help@example.com</a></p>
+	<img alt='' src="http://example.com/track?rid=1234567"/>
+	<script>alert(1)</script>
+</body></html>`
+	expected := "Password expiry\n\n" +
+		"Hi John,\nyour password expires today.\n\n" +
+		"Please reset it (http://example.com/?rid=1234567) now.\n\n" +
+		"* First\n* Second\n\n" +
+		"Name John\n\n" +
+		"http://example.com Top help@example.com"
+	text, err := htmlToText(h)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(text, check.Equals, expected)
+}
+
+func (s *ModelsSuite) TestEmailRequestGenerateText(ch *check.C) {
+	req := &EmailRequest{
+		SMTP:        SMTP{FromAddress: "from@example.com"},
+		FromAddress: "from@example.com",
+		URL:         "http://example.com",
+		RId:         "1234567",
+		Template: Template{
+			Subject:      "Test",
+			HTML:         `<p>Please <a href="{{.URL}}">reset your password</a>.</p>`,
+			GenerateText: true,
+		},
+		BaseRecipient: BaseRecipient{Email: "to@example.com"},
+	}
+	msg := gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	buf := &bytes.Buffer{}
+	_, err := msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+	got, err := email.NewEmailFromReader(buf)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(got.Text), check.Equals, "Please reset your password (http://example.com?rid=1234567).")
+	ch.Assert(strings.Contains(string(got.HTML), `href="http://example.com?rid=1234567"`), check.Equals, true)
+
+	// Templates with their own text aren't changed
+	req.Template.Text = "Custom text"
+	msg = gomail.NewMessage()
+	ch.Assert(req.Generate(msg), check.Equals, nil)
+	buf.Reset()
+	_, err = msg.WriteTo(buf)
+	ch.Assert(err, check.Equals, nil)
+	got, err = email.NewEmailFromReader(buf)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(string(got.Text), check.Equals, "Custom text")
+}
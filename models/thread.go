@@ -0,0 +1,94 @@
+package models
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// threadAge is how long before the email the fake prior message appears to
+// have been sent.
+const threadAge = 26 * time.Hour
+
+// priorMessage is the fake message which emails sent as a thread reply to.
+type priorMessage struct {
+	From      string
+	Date      time.Time
+	Text      string
+	MessageID string
+}
+
+// priorMessage returns the fake prior message for the recipient. By default
+// it appears to have been sent by the recipient, so the email looks like a
+// reply to them.
+func (t *Template) priorMessage(ptx PhishingTemplateContext) (*priorMessage, error) {
+	from := ptx.BaseRecipient.FormatAddress()
+	if t.ThreadFrom != "" {
+		f, err := ExecuteTemplate(t.ThreadFrom, ptx)
+		if err != nil {
+			return nil, err
+		}
+		from = f
+	}
+	text, err := ExecuteTemplate(t.ThreadBody, ptx)
+	if err != nil {
+		return nil, err
+	}
+	domain := "localhost.localdomain"
+	if a, err := mail.ParseAddress(from); err == nil {
+		domain = asciiDomain(a.Address[strings.LastIndex(a.Address, "@")+1:])
+	}
+	// The message id is derived from the result id, so that it's the same
+	// each time the email is generated.
+	h := sha256.Sum256([]byte("thread:" + ptx.RId))
+	return &priorMessage{
+		From:      from,
+		Date:      time.Now().Add(-threadAge),
+		Text:      text,
+		MessageID: fmt.Sprintf("<%x@%s>", h[:16], domain),
+	}, nil
+}
+
+// attribution returns the line introducing the quoted message.
+func (p *priorMessage) attribution() string {
+	return fmt.Sprintf("On %s, %s wrote:", p.Date.Format("Mon, Jan 2, 2006 at 3:04 PM"), p.From)
+}
+
+// quoteText appends the prior message to a text body.
+func (p *priorMessage) quoteText(text string) string {
+	if p == nil {
+		return text
+	}
+	lines := strings.Split(strings.TrimRight(p.Text, "\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + strings.TrimRight(line, "\r")
+	}
+	return text + "\n\n" + p.attribution() + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// quoteHTML adds the prior message to an HTML body, before the closing body
+// tag if there is one.
+func (p *priorMessage) quoteHTML(h string) string {
+	if p == nil {
+		return h
+	}
+	quoted := strings.Replace(html.EscapeString(strings.TrimRight(p.Text, "\r\n")), "\n", "<br>\n", -1)
+	quote := "<br><div class=\"gmail_quote\"><div>" + html.EscapeString(p.attribution()) + "</div>" +
+		"<blockquote style=\"margin:0 0 0 .8ex;border-left:1px #ccc solid;padding-left:1ex\">" + quoted + "</blockquote></div>"
+	i := strings.LastIndex(strings.ToLower(h), "</body>")
+	if i == -1 {
+		return h + quote
+	}
+	return h[:i] + quote + h[i:]
+}
+
+// threadSubject returns the subject of a reply.
+func threadSubject(subject string) string {
+	if subject == "" || strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
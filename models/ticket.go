@@ -0,0 +1,429 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// The ticketing systems tickets can be opened in
+const (
+	TicketJira       = "jira"
+	TicketServiceNow = "servicenow"
+)
+
+// DefaultJiraIssueType is the type of the Jira issues opened by connectors
+// which don't set one.
+const DefaultJiraIssueType = "Task"
+
+// DefaultTicketSummary is the summary of tickets opened by connectors which
+// don't map one.
+const DefaultTicketSummary = "{{.Event}}: {{.Email}} in campaign {{.Campaign}}"
+
+var ticketClient = restrictedClient(NotificationTimeout)
+
+// ErrTicketType is thrown when a ticket connector has an unknown type.
+var ErrTicketType = errors.New("The type must be jira or servicenow")
+
+// ErrTicketURL is thrown when a ticket connector doesn't have an http or
+// https URL.
+var ErrTicketURL = errors.New("The URL must be an http or https URL")
+
+// ErrTicketProject is thrown when a ticket connector doesn't have a Jira
+// project or ServiceNow table.
+var ErrTicketProject = errors.New("Jira connectors need a project key, and ServiceNow connectors need a table")
+
+// ErrTicketConnectorNotFound is thrown when a ticket connector doesn't exist.
+var ErrTicketConnectorNotFound = errors.New("Ticket connector not found")
+
+// TicketConnector opens a ticket in Jira or ServiceNow when a recipient of
+// one of the user's campaigns has the connector's event, such as submitting
+// data. Connectors with a campaign id only apply to that campaign, and those
+// with a tag only apply to results with the tag.
+//
+// Fields map the names of ticket fields to templates, which are executed
+// with a TicketContext. Jira tickets are opened in the Project, and
+// ServiceNow records are created in the table named by the Project.
+type TicketConnector struct {
+	Id         int64             `json:"id"`
+	UserId     int64             `json:"-"`
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	URL        string            `json:"url"`
+	Username   string            `json:"username"`
+	Secret     EncryptedString   `json:"secret"`
+	Project    string            `json:"project"`
+	IssueType  string            `json:"issue_type"`
+	CampaignId int64             `json:"campaign_id"`
+	Event      string            `json:"event"`
+	Tag        string            `json:"tag"`
+	Fields     map[string]string `json:"fields" gorm:"-"`
+	// RawFields is the JSON encoding of the fields, as stored.
+	RawFields    string    `json:"-" gorm:"column:fields"`
+	Enabled      bool      `json:"enabled"`
+	CreatedDate  time.Time `json:"created_date"`
+	ModifiedDate time.Time `json:"modified_date"`
+}
+
+// TicketLog records a ticket opened by a connector, which prevents another
+// ticket from being opened for the same recipient.
+type TicketLog struct {
+	Id          int64     `json:"id"`
+	ConnectorId int64     `json:"-"`
+	CampaignId  int64     `json:"campaign_id"`
+	EventId     int64     `json:"-"`
+	Email       string    `json:"email"`
+	TicketId    string    `json:"ticket_id"`
+	TicketURL   string    `json:"ticket_url" gorm:"column:ticket_url"`
+	Error       string    `json:"error,omitempty"`
+	CreatedDate time.Time `json:"created_date"`
+}
+
+// Ticket is the reference to an opened ticket which is added to the details
+// of the event that caused it.
+type Ticket struct {
+	Connector string `json:"connector"`
+	Type      string `json:"type"`
+	Id        string `json:"id"`
+	URL       string `json:"url"`
+}
+
+// TicketContext is the data available to the field templates of ticket
+// connectors.
+type TicketContext struct {
+	Campaign   string
+	CampaignId int64
+	Event      string
+	Time       time.Time
+	Email      string
+	FirstName  string
+	LastName   string
+	Position   string
+	Tags       string
+	Details    string
+}
+
+// BeforeSave encodes the fields for storing.
+func (tc *TicketConnector) BeforeSave() error {
+	b, err := json.Marshal(tc.Fields)
+	if err != nil {
+		return err
+	}
+	tc.RawFields = string(b)
+	return nil
+}
+
+// AfterFind decodes the stored fields.
+func (tc *TicketConnector) AfterFind() error {
+	tc.Fields = map[string]string{}
+	if tc.RawFields == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(tc.RawFields), &tc.Fields)
+}
+
+// Validate ensures the connector has a known type, a URL and a project or
+// table, and that its field templates are valid. Connectors without an event
+// open tickets when recipients submit data.
+func (tc *TicketConnector) Validate() error {
+	if tc.Name == "" {
+		return ErrNameNotSpecified
+	}
+	switch tc.Type {
+	case TicketJira:
+		if tc.IssueType == "" {
+			tc.IssueType = DefaultJiraIssueType
+		}
+	case TicketServiceNow:
+	default:
+		return ErrTicketType
+	}
+	if !strings.HasPrefix(tc.URL, "https://") && !strings.HasPrefix(tc.URL, "http://") {
+		return ErrTicketURL
+	}
+	tc.URL = strings.TrimRight(tc.URL, "/")
+	if tc.Project == "" {
+		return ErrTicketProject
+	}
+	if tc.Event == "" {
+		tc.Event = EventDataSubmit
+	}
+	for name, text := range tc.Fields {
+		_, err := ExecuteTemplate(text, TicketContext{})
+		if err != nil {
+			return fmt.Errorf("Invalid template for field %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// GetTicketConnectors returns the ticket connectors owned by the given user.
+func GetTicketConnectors(uid int64) ([]TicketConnector, error) {
+	tcs := []TicketConnector{}
+	err := db.Where("user_id=?", uid).Order("id asc").Find(&tcs).Error
+	return tcs, err
+}
+
+// GetTicketConnector returns the ticket connector with the given id, if it's
+// owned by the given user.
+func GetTicketConnector(id int64, uid int64) (TicketConnector, error) {
+	tc := TicketConnector{}
+	err := db.Where("id=? and user_id=?", id, uid).First(&tc).Error
+	if err != nil {
+		return tc, ErrTicketConnectorNotFound
+	}
+	return tc, nil
+}
+
+// PostTicketConnector creates a new ticket connector.
+func PostTicketConnector(tc *TicketConnector) error {
+	err := tc.Validate()
+	if err != nil {
+		return err
+	}
+	tc.Id = 0
+	tc.CreatedDate = time.Now().UTC()
+	tc.ModifiedDate = tc.CreatedDate
+	return db.Save(tc).Error
+}
+
+// PutTicketConnector edits an existing ticket connector.
+func PutTicketConnector(tc *TicketConnector) error {
+	existing, err := GetTicketConnector(tc.Id, tc.UserId)
+	if err != nil {
+		return err
+	}
+	err = tc.Validate()
+	if err != nil {
+		return err
+	}
+	tc.CreatedDate = existing.CreatedDate
+	tc.ModifiedDate = time.Now().UTC()
+	return db.Save(tc).Error
+}
+
+// DeleteTicketConnector deletes the ticket connector with the given id,
+// along with the record of its tickets.
+func DeleteTicketConnector(id int64, uid int64) error {
+	_, err := GetTicketConnector(id, uid)
+	if err != nil {
+		return err
+	}
+	err = db.Where("connector_id=?", id).Delete(&TicketLog{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Where("id=? and user_id=?", id, uid).Delete(&TicketConnector{}).Error
+}
+
+// GetTicketLogs returns the tickets opened by the connector, newest first.
+func GetTicketLogs(id int64, uid int64) ([]TicketLog, error) {
+	tls := []TicketLog{}
+	_, err := GetTicketConnector(id, uid)
+	if err != nil {
+		return tls, err
+	}
+	err = db.Where("connector_id=?", id).Order("id desc").Find(&tls).Error
+	return tls, err
+}
+
+// evaluateTicketConnectors opens the tickets caused by the event.
+func evaluateTicketConnectors(e Event, c Campaign) error {
+	if e.Email == "" {
+		return nil
+	}
+	tcs := []TicketConnector{}
+	err := db.Where("user_id=? and enabled=? and event=? and campaign_id in (?)", c.UserId, true, e.Message, []int64{0, c.Id}).
+		Find(&tcs).Error
+	if err != nil || len(tcs) == 0 {
+		return err
+	}
+	r := Result{}
+	err = db.Where("campaign_id=? and email=?", c.Id, e.Email).First(&r).Error
+	if err != nil {
+		return nil
+	}
+	for _, tc := range tcs {
+		if tc.Tag != "" && !r.HasTag(tc.Tag) {
+			continue
+		}
+		tc.open(e, c, r)
+	}
+	return nil
+}
+
+// open opens a ticket for the event, unless the connector has already opened
+// one for the recipient. The ticket is added to the event's details.
+func (tc TicketConnector) open(e Event, c Campaign, r Result) {
+	var count int
+	db.Model(&TicketLog{}).Where("connector_id=? and campaign_id=? and email=?", tc.Id, c.Id, e.Email).Count(&count)
+	if count > 0 {
+		return
+	}
+	ctx := TicketContext{
+		Campaign:   c.Name,
+		CampaignId: c.Id,
+		Event:      e.Message,
+		Time:       e.Time,
+		Email:      e.Email,
+		FirstName:  r.FirstName,
+		LastName:   r.LastName,
+		Position:   r.Position,
+		Tags:       r.Tags,
+		Details:    string(e.Details),
+	}
+	tl := TicketLog{ConnectorId: tc.Id, CampaignId: c.Id, EventId: e.Id, Email: e.Email, CreatedDate: time.Now().UTC()}
+	t, err := tc.create(ctx)
+	if err == nil {
+		tl.TicketId, tl.TicketURL = t.Id, t.URL
+		err = addEventTicket(e.Id, t)
+	}
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"connector_id": tc.Id,
+			"campaign_id":  c.Id,
+		}).Errorf("error opening ticket: %v", err)
+		tl.Error = err.Error()
+	}
+	if err := db.Save(&tl).Error; err != nil {
+		log.Error(err)
+	}
+}
+
+// fields executes the connector's field templates. Values which are JSON
+// objects or arrays, such as Jira's {"name": "High"} priorities, are sent as
+// JSON rather than as strings.
+func (tc TicketConnector) fields(ctx TicketContext, summaryField string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	templates := map[string]string{summaryField: DefaultTicketSummary}
+	for name, text := range tc.Fields {
+		templates[name] = text
+	}
+	for name, text := range templates {
+		value, err := ExecuteTemplate(text, ctx)
+		if err != nil {
+			return fields, err
+		}
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			var raw json.RawMessage
+			if json.Unmarshal([]byte(trimmed), &raw) == nil {
+				fields[name] = raw
+				continue
+			}
+		}
+		fields[name] = value
+	}
+	return fields, nil
+}
+
+// create opens the ticket in the connector's ticketing system.
+func (tc TicketConnector) create(ctx TicketContext) (Ticket, error) {
+	t := Ticket{Connector: tc.Name, Type: tc.Type}
+	switch tc.Type {
+	case TicketJira:
+		fields, err := tc.fields(ctx, "summary")
+		if err != nil {
+			return t, err
+		}
+		fields["project"] = map[string]string{"key": tc.Project}
+		fields["issuetype"] = map[string]string{"name": tc.IssueType}
+		resp := struct {
+			Key string `json:"key"`
+		}{}
+		err = tc.post("/rest/api/2/issue", map[string]interface{}{"fields": fields}, &resp)
+		if err != nil {
+			return t, err
+		}
+		if resp.Key == "" {
+			return t, errors.New("Jira didn't return the key of the issue")
+		}
+		t.Id = resp.Key
+		t.URL = fmt.Sprintf("%s/browse/%s", tc.URL, url.PathEscape(resp.Key))
+		return t, nil
+	case TicketServiceNow:
+		fields, err := tc.fields(ctx, "short_description")
+		if err != nil {
+			return t, err
+		}
+		resp := struct {
+			Result struct {
+				SysId  string `json:"sys_id"`
+				Number string `json:"number"`
+			} `json:"result"`
+		}{}
+		err = tc.post("/api/now/table/"+url.PathEscape(tc.Project), fields, &resp)
+		if err != nil {
+			return t, err
+		}
+		if resp.Result.SysId == "" {
+			return t, errors.New("ServiceNow didn't return the id of the record")
+		}
+		// Tables without a number field are referenced by their sys_id
+		t.Id = resp.Result.Number
+		if t.Id == "" {
+			t.Id = resp.Result.SysId
+		}
+		t.URL = fmt.Sprintf("%s/nav_to.do?uri=%s", tc.URL,
+			url.QueryEscape(fmt.Sprintf("%s.do?sys_id=%s", tc.Project, resp.Result.SysId)))
+		return t, nil
+	}
+	return t, ErrTicketType
+}
+
+// post sends the body to the connector's API, decoding the response into
+// resp.
+func (tc TicketConnector) post(path string, body interface{}, resp interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", tc.URL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(tc.Username, string(tc.Secret))
+	res, err := ticketClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, tc.URL)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// addEventTicket adds the ticket to the details of the event with the given
+// id, so that the event can be cross-referenced with the ticket.
+func addEventTicket(eid int64, t Ticket) error {
+	e := Event{}
+	err := db.Where("id=?", eid).First(&e).Error
+	if err != nil {
+		return err
+	}
+	details := map[string]interface{}{}
+	if e.Details != "" {
+		err = json.Unmarshal([]byte(e.Details), &details)
+		if err != nil {
+			return err
+		}
+	}
+	tickets, _ := details["tickets"].([]interface{})
+	details["tickets"] = append(tickets, t)
+	b, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return db.Model(&Event{}).Where("id=?", eid).Update("details", EncryptedString(b)).Error
+}
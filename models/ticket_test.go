@@ -0,0 +1,167 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"gopkg.in/check.v1"
+)
+
+// ticketRecorder is a Jira or ServiceNow API which records the tickets it's
+// asked to open.
+type ticketRecorder struct {
+	sync.Mutex
+	paths    []string
+	tickets  []map[string]interface{}
+	response string
+}
+
+func (tr *ticketRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+	if user != "gophish" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&body)
+	tr.Lock()
+	tr.paths = append(tr.paths, r.URL.Path)
+	tr.tickets = append(tr.tickets, body)
+	tr.Unlock()
+	w.Write([]byte(tr.response))
+}
+
+func (s *ModelsSuite) TestTicketConnectorValidation(ch *check.C) {
+	tc := TicketConnector{UserId: 1, Name: "Jira", Type: "bugzilla", URL: "https://jira.example.com/", Project: "SEC"}
+	ch.Assert(PostTicketConnector(&tc), check.Equals, ErrTicketType)
+	tc.Type = TicketJira
+	tc.URL = "jira.example.com"
+	ch.Assert(PostTicketConnector(&tc), check.Equals, ErrTicketURL)
+	tc.URL = "https://jira.example.com/"
+	tc.Project = ""
+	ch.Assert(PostTicketConnector(&tc), check.Equals, ErrTicketProject)
+	tc.Project = "SEC"
+	tc.Fields = map[string]string{"description": "{{.Unknown}}"}
+	ch.Assert(PostTicketConnector(&tc), check.NotNil)
+	tc.Fields = map[string]string{"description": "{{.FirstName}} submitted data"}
+	ch.Assert(PostTicketConnector(&tc), check.Equals, nil)
+
+	// Defaults are filled in, and the fields are stored
+	got, err := GetTicketConnector(tc.Id, 1)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.URL, check.Equals, "https://jira.example.com")
+	ch.Assert(got.IssueType, check.Equals, DefaultJiraIssueType)
+	ch.Assert(got.Event, check.Equals, EventDataSubmit)
+	ch.Assert(got.Fields, check.DeepEquals, tc.Fields)
+
+	// Connectors are only visible to their owner
+	_, err = GetTicketConnector(tc.Id, 2)
+	ch.Assert(err, check.Equals, ErrTicketConnectorNotFound)
+}
+
+func (s *ModelsSuite) TestJiraTickets(ch *check.C) {
+	tr := &ticketRecorder{response: `{"id": "10000", "key": "SEC-1"}`}
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+
+	after, err := LastEventId()
+	ch.Assert(err, check.Equals, nil)
+	c := s.createCampaign(ch)
+	tc := TicketConnector{UserId: c.UserId, Name: "Jira", Type: TicketJira, URL: ts.URL, Username: "gophish", Secret: "secret",
+		Project: "SEC", Enabled: true, Fields: map[string]string{
+			"description": "{{.FirstName}} {{.LastName}} submitted data",
+			"priority":    `{"name": "High"}`,
+		}}
+	ch.Assert(PostTicketConnector(&tc), check.Equals, nil)
+
+	r, err := GetResult(c.Results[0].RId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+	ch.Assert(r.HandleFormSubmit(EventDetails{}), check.Equals, nil)
+	_, err = EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+
+	// Only one ticket is opened for the recipient, with the mapped fields
+	ch.Assert(tr.paths, check.DeepEquals, []string{"/rest/api/2/issue"})
+	fields := tr.tickets[0]["fields"].(map[string]interface{})
+	ch.Assert(fields["summary"], check.Equals, EventDataSubmit+": "+r.Email+" in campaign "+c.Name)
+	ch.Assert(fields["description"], check.Equals, r.FirstName+" "+r.LastName+" submitted data")
+	ch.Assert(fields["priority"], check.DeepEquals, map[string]interface{}{"name": "High"})
+	ch.Assert(fields["project"], check.DeepEquals, map[string]interface{}{"key": "SEC"})
+	ch.Assert(fields["issuetype"], check.DeepEquals, map[string]interface{}{"name": DefaultJiraIssueType})
+
+	// The ticket is written back into the first event's details
+	e := Event{}
+	ch.Assert(db.Where("campaign_id=? and email=? and message=?", c.Id, r.Email, EventDataSubmit).Order("id").First(&e).Error, check.Equals, nil)
+	details := struct {
+		Tickets []Ticket `json:"tickets"`
+	}{}
+	ch.Assert(json.Unmarshal([]byte(e.Details), &details), check.Equals, nil)
+	ch.Assert(details.Tickets, check.DeepEquals, []Ticket{{Connector: "Jira", Type: TicketJira, Id: "SEC-1", URL: ts.URL + "/browse/SEC-1"}})
+
+	tls, err := GetTicketLogs(tc.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(tls), check.Equals, 1)
+	ch.Assert(tls[0].TicketId, check.Equals, "SEC-1")
+	ch.Assert(tls[0].Error, check.Equals, "")
+
+	ch.Assert(DeleteTicketConnector(tc.Id, c.UserId), check.Equals, nil)
+	var count int
+	db.Model(&TicketLog{}).Where("connector_id=?", tc.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
+
+func (s *ModelsSuite) TestServiceNowTickets(ch *check.C) {
+	tr := &ticketRecorder{response: `{"result": {"sys_id": "abc123", "number": "INC0010001"}}`}
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+
+	after, err := LastEventId()
+	ch.Assert(err, check.Equals, nil)
+	c := s.createCampaign(ch)
+	tc := TicketConnector{UserId: c.UserId, Name: "ServiceNow", Type: TicketServiceNow, URL: ts.URL, Username: "gophish", Secret: "secret",
+		Project: "incident", Event: EventClicked, Tag: "vip", Enabled: true,
+		Fields: map[string]string{"short_description": "{{.Email}} clicked a link", "urgency": "1"}}
+	ch.Assert(PostTicketConnector(&tc), check.Equals, nil)
+	wrong := TicketConnector{UserId: c.UserId, Name: "Wrong password", Type: TicketServiceNow, URL: ts.URL, Username: "gophish",
+		Secret: "wrong", Project: "incident", Event: EventClicked, Enabled: true}
+	ch.Assert(PostTicketConnector(&wrong), check.Equals, nil)
+
+	_, err = AnnotateResult(c.Id, c.Results[0].RId, c.UserId, ResultAnnotation{Tags: []string{"vip"}})
+	ch.Assert(err, check.Equals, nil)
+	for _, r := range c.Results[:2] {
+		r, err := GetResult(r.RId)
+		ch.Assert(err, check.Equals, nil)
+		ch.Assert(r.HandleClickedLink(EventDetails{}), check.Equals, nil)
+	}
+	_, err = EvaluateNotifications(after)
+	ch.Assert(err, check.Equals, nil)
+
+	// Only the tagged recipient has a ticket
+	ch.Assert(tr.paths, check.DeepEquals, []string{"/api/now/table/incident"})
+	ch.Assert(tr.tickets[0], check.DeepEquals, map[string]interface{}{
+		"short_description": c.Results[0].Email + " clicked a link",
+		"urgency":           "1",
+	})
+	tls, err := GetTicketLogs(tc.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(tls), check.Equals, 1)
+	ch.Assert(tls[0].TicketId, check.Equals, "INC0010001")
+
+	// Errors are recorded, rather than retried
+	tls, err = GetTicketLogs(wrong.Id, c.UserId)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(tls), check.Equals, 2)
+	ch.Assert(tls[0].TicketId, check.Equals, "")
+	ch.Assert(tls[0].Error, check.Not(check.Equals), "")
+}
+
+func (s *ModelsSuite) TestTicketConnectorRestricted(ch *check.C) {
+	tc := TicketConnector{Type: TicketJira, URL: "http://169.254.169.254"}
+	err := tc.post("/rest/api/2/issue", map[string]string{}, nil)
+	ch.Assert(err, check.NotNil)
+	ch.Assert(strings.Contains(err.Error(), "upstream connection denied"), check.Equals, true)
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// TimelineEvent is an event in a recipient's timeline, along with the
+// details needed to follow what happened without decoding the event.
+type TimelineEvent struct {
+	Id      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	// SinceSent is the number of seconds since the email was sent, which
+	// is omitted for events before the email was sent
+	SinceSent *float64 `json:"since_sent,omitempty"`
+	// SincePrevious is the number of seconds since the previous event
+	SincePrevious float64         `json:"since_previous"`
+	IP            string          `json:"ip,omitempty"`
+	Latitude      float64         `json:"latitude,omitempty"`
+	Longitude     float64         `json:"longitude,omitempty"`
+	Device        *Device         `json:"device,omitempty"`
+	UserAgent     string          `json:"user_agent,omitempty"`
+	Details       json.RawMessage `json:"details,omitempty"`
+}
+
+// TimelineMilestones are the number of seconds between the email being sent
+// and the recipient first reaching each stage. Stages which weren't reached
+// are omitted.
+type TimelineMilestones struct {
+	Opened    *float64 `json:"opened,omitempty"`
+	Clicked   *float64 `json:"clicked,omitempty"`
+	Submitted *float64 `json:"submitted_data,omitempty"`
+	Reported  *float64 `json:"reported,omitempty"`
+}
+
+// Timeline is the ordered sequence of events for a single recipient of a
+// campaign.
+type Timeline struct {
+	CampaignId int64              `json:"campaign_id"`
+	Result     Result             `json:"result"`
+	SendDate   *time.Time         `json:"send_date,omitempty"`
+	Milestones TimelineMilestones `json:"milestones"`
+	Events     []TimelineEvent    `json:"events"`
+}
+
+// GetResultTimeline returns the timeline of the result with the given id in
+// the campaign, provided the campaign is owned by the given user.
+func GetResultTimeline(cid int64, rid string, uid int64) (Timeline, error) {
+	c := Campaign{}
+	err := reportingDB().Table("campaigns").Select("id, archived_date").Where("id=? and user_id=?", cid, uid).First(&c).Error
+	if err != nil {
+		return Timeline{}, err
+	}
+	r := Result{}
+	err = reportingDB().Where("campaign_id=? and r_id=?", cid, rid).First(&r).Error
+	if err != nil {
+		return Timeline{}, err
+	}
+	es := []Event{}
+	err = reportingDB().Table(eventsTable(c.ArchivedDate)).Where("campaign_id=? and email=?", cid, r.Email).Order("time asc, id asc").Find(&es).Error
+	if err != nil {
+		return Timeline{}, err
+	}
+	return buildTimeline(r, es), nil
+}
+
+// buildTimeline correlates the recipient's events, in the order they
+// happened.
+func buildTimeline(r Result, es []Event) Timeline {
+	t := Timeline{CampaignId: r.CampaignId, Result: r, Events: []TimelineEvent{}}
+	// The geolocation database is optional, so events just aren't located
+	// if it's missing
+	mmdb, err := maxminddb.Open(geoDBPath)
+	if err == nil {
+		defer mmdb.Close()
+	} else {
+		mmdb = nil
+	}
+	var sent, previous time.Time
+	for _, e := range es {
+		te := TimelineEvent{Id: e.Id, Time: e.Time, Message: e.Message}
+		if e.Message == EventSent {
+			sent = e.Time
+			st := sent
+			t.SendDate = &st
+		}
+		if !sent.IsZero() {
+			te.SinceSent = seconds(e.Time.Sub(sent))
+		}
+		if !previous.IsZero() {
+			te.SincePrevious = e.Time.Sub(previous).Seconds()
+		}
+		previous = e.Time
+		if e.Details != "" {
+			te.Details = json.RawMessage(e.Details)
+			details := EventDetails{}
+			if json.Unmarshal([]byte(e.Details), &details) == nil && details.Browser != nil {
+				te.IP = details.Browser["address"]
+				te.UserAgent = details.Browser["user-agent"]
+				if te.UserAgent != "" {
+					d := ParseUserAgent(te.UserAgent)
+					te.Device = &d
+				}
+				if mmdb != nil && te.IP != "" {
+					te.Latitude, te.Longitude = geoLocate(mmdb, te.IP)
+				}
+			}
+		}
+		t.addMilestone(te)
+		t.Events = append(t.Events, te)
+	}
+	return t
+}
+
+// addMilestone records the first time each stage was reached.
+func (t *Timeline) addMilestone(te TimelineEvent) {
+	if te.SinceSent == nil {
+		return
+	}
+	var m **float64
+	switch te.Message {
+	case EventOpened:
+		m = &t.Milestones.Opened
+	case EventClicked:
+		m = &t.Milestones.Clicked
+	case EventDataSubmit:
+		m = &t.Milestones.Submitted
+	case EventReported:
+		m = &t.Milestones.Reported
+	default:
+		return
+	}
+	if *m == nil {
+		*m = te.SinceSent
+	}
+}
+
+// geoLocate returns the latitude and longitude of the address, or zeros if
+// it can't be found.
+func geoLocate(mmdb *maxminddb.Reader, addr string) (float64, float64) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return 0, 0
+	}
+	var city mmCity
+	if mmdb.Lookup(ip, &city) != nil {
+		return 0, 0
+	}
+	return city.GeoPoint.Latitude, city.GeoPoint.Longitude
+}
+
+func seconds(d time.Duration) *float64 {
+	s := d.Seconds()
+	return &s
+}
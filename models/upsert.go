@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MaxExternalIdLength is the maximum length of an external id.
+const MaxExternalIdLength = 255
+
+// ErrExternalIdInUse is thrown when an external id is already used by another
+// item of the same type.
+var ErrExternalIdInUse = errors.New("External id already in use")
+
+// ErrExternalIdTooLong is thrown when an external id is longer than
+// MaxExternalIdLength.
+var ErrExternalIdTooLong = errors.New("External id is too long")
+
+// ErrNameInUse is thrown when an upsert would give an item the same name as
+// another item which is managed with a different external id.
+var ErrNameInUse = errors.New("Name already in use")
+
+// upsertRow holds the identifying columns of an item being upserted.
+type upsertRow struct {
+	Id         int64
+	Name       string
+	ExternalId string
+}
+
+// validateExternalId checks that no other item in the table owned by the user
+// has the same external id. Items without an external id are always valid.
+func validateExternalId(table string, eid string, uid int64, id int64) error {
+	if eid == "" {
+		return nil
+	}
+	if len(eid) > MaxExternalIdLength {
+		return ErrExternalIdTooLong
+	}
+	var count int64
+	err := db.Table(table).Where("user_id=? and external_id=? and id<>?", uid, eid, id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrExternalIdInUse
+	}
+	return nil
+}
+
+// upsertId returns the id of the item in the table which should be updated by
+// an upsert, or gorm.ErrRecordNotFound if a new item should be created.
+//
+// Items are matched by their external id if one is given, falling back to
+// their name. This lets existing items be brought under management by giving
+// them an external id. An item matched by name is only updated if it doesn't
+// already have a different external id.
+func upsertId(table string, eid string, name string, uid int64) (int64, error) {
+	rows := []upsertRow{}
+	err := db.Table(table).Select("id, name, external_id").
+		Where("user_id=? and (name=? or (external_id=? and external_id<>''))", uid, name, eid).
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var byExternalId, byName *upsertRow
+	for i := range rows {
+		if eid != "" && rows[i].ExternalId == eid {
+			byExternalId = &rows[i]
+		}
+		if rows[i].Name == name && byName == nil {
+			byName = &rows[i]
+		}
+	}
+	switch {
+	case byExternalId != nil:
+		// Renaming an item can't take the name of a different item
+		if byName != nil && byName.Id != byExternalId.Id {
+			return 0, ErrNameInUse
+		}
+		return byExternalId.Id, nil
+	case byName != nil:
+		if byName.ExternalId != "" && byName.ExternalId != eid {
+			return 0, ErrNameInUse
+		}
+		return byName.Id, nil
+	}
+	return 0, gorm.ErrRecordNotFound
+}
+
+// GetTemplateForUpsert returns the existing template which an upsert of the
+// given template should update, or gorm.ErrRecordNotFound if the template
+// should be created.
+func GetTemplateForUpsert(t Template, uid int64) (Template, error) {
+	id, err := upsertId("templates", t.ExternalId, t.Name, uid)
+	if err != nil {
+		return Template{}, err
+	}
+	return GetTemplate(id, uid)
+}
+
+// GetPageForUpsert returns the existing page which an upsert of the given page
+// should update, or gorm.ErrRecordNotFound if the page should be created.
+func GetPageForUpsert(p Page, uid int64) (Page, error) {
+	id, err := upsertId("pages", p.ExternalId, p.Name, uid)
+	if err != nil {
+		return Page{}, err
+	}
+	return GetPage(id, uid)
+}
+
+// GetSMTPForUpsert returns the existing sending profile which an upsert of the
+// given sending profile should update, or gorm.ErrRecordNotFound if the
+// sending profile should be created.
+func GetSMTPForUpsert(s SMTP, uid int64) (SMTP, error) {
+	id, err := upsertId("smtp", s.ExternalId, s.Name, uid)
+	if err != nil {
+		return SMTP{}, err
+	}
+	return GetSMTP(id, uid)
+}
+
+// GetGroupForUpsert returns the existing group which an upsert of the given
+// group should update, or gorm.ErrRecordNotFound if the group should be
+// created.
+func GetGroupForUpsert(g Group, uid int64) (Group, error) {
+	id, err := upsertId("groups", g.ExternalId, g.Name, uid)
+	if err != nil {
+		return Group{}, err
+	}
+	return GetGroup(id, uid)
+}
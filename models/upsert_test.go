@@ -0,0 +1,53 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestGetPageForUpsert(c *check.C) {
+	managed := Page{Name: "Managed", ExternalId: "page-1", UserId: 1}
+	c.Assert(PostPage(&managed), check.Equals, nil)
+	unmanaged := Page{Name: "Unmanaged", UserId: 1}
+	c.Assert(PostPage(&unmanaged), check.Equals, nil)
+
+	// Pages are matched by external id first, even if they've been renamed
+	p, err := GetPageForUpsert(Page{Name: "Renamed", ExternalId: "page-1"}, 1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(p.Id, check.Equals, managed.Id)
+
+	// Pages without an external id are matched by name
+	p, err = GetPageForUpsert(Page{Name: "Unmanaged", ExternalId: "page-2"}, 1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(p.Id, check.Equals, unmanaged.Id)
+
+	// Pages managed with a different external id aren't matched by name
+	_, err = GetPageForUpsert(Page{Name: "Managed", ExternalId: "page-2"}, 1)
+	c.Assert(err, check.Equals, ErrNameInUse)
+
+	// Renaming a page can't take the name of another page
+	_, err = GetPageForUpsert(Page{Name: "Unmanaged", ExternalId: "page-1"}, 1)
+	c.Assert(err, check.Equals, ErrNameInUse)
+
+	_, err = GetPageForUpsert(Page{Name: "New", ExternalId: "page-3"}, 1)
+	c.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+
+	// Pages owned by other users are never matched
+	_, err = GetPageForUpsert(Page{Name: "Managed", ExternalId: "page-1"}, 2)
+	c.Assert(err, check.Equals, gorm.ErrRecordNotFound)
+}
+
+func (s *ModelsSuite) TestExternalIdUnique(c *check.C) {
+	first := Page{Name: "First", ExternalId: "page-1", UserId: 1}
+	c.Assert(PostPage(&first), check.Equals, nil)
+	second := Page{Name: "Second", ExternalId: "page-1", UserId: 1}
+	c.Assert(PostPage(&second), check.Equals, ErrExternalIdInUse)
+
+	// Saving an item keeps its own external id
+	first.HTML = "<html></html>"
+	c.Assert(PutPage(&first), check.Equals, nil)
+
+	// External ids are only unique per user
+	other := Page{Name: "Other", ExternalId: "page-1", UserId: 2}
+	c.Assert(PostPage(&other), check.Equals, nil)
+}
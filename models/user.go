@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/gophish/gophish/auth"
 	log "github.com/gophish/gophish/logger"
 )
 
@@ -14,15 +15,31 @@ var ErrModifyingOnlyAdmin = errors.New("Cannot remove the only administrator")
 
 // User represents the user model for gophish.
 type User struct {
-	Id                     int64     `json:"id"`
-	Username               string    `json:"username" sql:"not null;unique"`
-	Hash                   string    `json:"-"`
-	ApiKey                 string    `json:"api_key" sql:"not null;unique"`
-	Role                   Role      `json:"role" gorm:"association_autoupdate:false;association_autocreate:false"`
-	RoleID                 int64     `json:"-"`
-	PasswordChangeRequired bool      `json:"password_change_required"`
-	AccountLocked          bool      `json:"account_locked"`
-	LastLogin              time.Time `json:"last_login"`
+	Id                     int64           `json:"id"`
+	Username               string          `json:"username" sql:"not null;unique"`
+	Hash                   string          `json:"-"`
+	ApiKey                 EncryptedString `json:"api_key" sql:"not null;unique"`
+	ApiKeyHash             string          `json:"-"`
+	Role                   Role            `json:"role" gorm:"association_autoupdate:false;association_autocreate:false"`
+	RoleID                 int64           `json:"-"`
+	PasswordChangeRequired bool            `json:"password_change_required"`
+	AccountLocked          bool            `json:"account_locked"`
+	LastLogin              time.Time       `json:"last_login"`
+	PasswordChangedDate    time.Time       `json:"password_changed_date"`
+}
+
+// PasswordHistory is a previous password of a user, kept so that it can't be
+// reused while the password policy remembers it.
+type PasswordHistory struct {
+	Id          int64     `json:"-"`
+	UserId      int64     `json:"-"`
+	Hash        string    `json:"-"`
+	CreatedDate time.Time `json:"-"`
+}
+
+// TableName specifies the database tablename for Gorm to use
+func (ph PasswordHistory) TableName() string {
+	return "password_history"
 }
 
 // GetUser returns the user that the given id corresponds to. If no user is found, an
@@ -44,7 +61,7 @@ func GetUsers() ([]User, error) {
 // error is thrown.
 func GetUserByAPIKey(key string) (User, error) {
 	u := User{}
-	err := db.Preload("Role").Where("api_key = ?", key).First(&u).Error
+	err := db.Preload("Role").Where("api_key_hash = ?", hashAPIKey(key)).First(&u).Error
 	return u, err
 }
 
@@ -56,6 +73,73 @@ func GetUserByUsername(username string) (User, error) {
 	return u, err
 }
 
+// BeforeSave sets the hash used to look up the user by their API key, since
+// the API key itself is stored encrypted.
+func (u *User) BeforeSave() error {
+	u.ApiKeyHash = hashAPIKey(string(u.ApiKey))
+	if u.PasswordChangedDate.IsZero() {
+		u.PasswordChangedDate = time.Now().UTC()
+	}
+	return nil
+}
+
+// PasswordExpired returns whether the user's password has expired under the
+// password policy, in which case they must change it before continuing.
+func (u *User) PasswordExpired() bool {
+	return auth.Policy.Expired(u.PasswordChangedDate)
+}
+
+// CheckPasswordHistory returns auth.ErrPasswordInHistory if the password is
+// the user's current password or one of the previous passwords remembered by
+// the password policy.
+func (u *User) CheckPasswordHistory(password string) error {
+	if auth.Policy.HistorySize == 0 {
+		return nil
+	}
+	hashes := []string{u.Hash}
+	phs := []PasswordHistory{}
+	err := db.Where("user_id=?", u.Id).Order("created_date desc, id desc").Limit(auth.Policy.HistorySize).Find(&phs).Error
+	if err != nil {
+		return err
+	}
+	for _, ph := range phs {
+		hashes = append(hashes, ph.Hash)
+	}
+	return auth.CheckPasswordHistory(password, hashes)
+}
+
+// SetPasswordHash changes the user's password to the one with the given hash,
+// remembering the previous password if the password policy keeps a history.
+// The user still needs to be saved using PutUser.
+func (u *User) SetPasswordHash(hash string) error {
+	if u.Id != 0 && u.Hash != "" && auth.Policy.HistorySize > 0 {
+		err := db.Save(&PasswordHistory{
+			UserId:      u.Id,
+			Hash:        u.Hash,
+			CreatedDate: time.Now().UTC(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		// Only the remembered passwords are kept
+		phs := []PasswordHistory{}
+		err = db.Select("id").Where("user_id=?", u.Id).Order("created_date desc, id desc").Find(&phs).Error
+		if err != nil {
+			return err
+		}
+		for i := auth.Policy.HistorySize; i < len(phs); i++ {
+			ph := phs[i]
+			err = db.Where("id=?", ph.Id).Delete(&PasswordHistory{}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	u.Hash = hash
+	u.PasswordChangedDate = time.Now().UTC()
+	return nil
+}
+
 // PutUser updates the given user
 func PutUser(u *User) error {
 	err := db.Save(u).Error
@@ -156,6 +240,67 @@ func DeleteUser(id int64) error {
 			return err
 		}
 	}
+	// Delete the org chart
+	log.Infof("Deleting the org chart for user ID %d", id)
+	err = db.Where("user_id=?", id).Delete(&OrgMember{}).Error
+	if err != nil {
+		return err
+	}
+	// Delete the notification rules
+	log.Infof("Deleting notification rules for user ID %d", id)
+	rules, err := GetNotificationRules(id)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		err = DeleteNotificationRule(rule.Id, id)
+		if err != nil {
+			return err
+		}
+	}
+	// Delete the ticket connectors
+	log.Infof("Deleting ticket connectors for user ID %d", id)
+	connectors, err := GetTicketConnectors(id)
+	if err != nil {
+		return err
+	}
+	for _, tc := range connectors {
+		err = DeleteTicketConnector(tc.Id, id)
+		if err != nil {
+			return err
+		}
+	}
+	// Delete the incident connectors
+	err = db.Where("user_id=?", id).Delete(&IncidentConnector{}).Error
+	if err != nil {
+		return err
+	}
+	// Delete the messages reported to the user's mailbox
+	err = db.Where("user_id=?", id).Delete(&ReportedMessage{}).Error
+	if err != nil {
+		return err
+	}
+	// Delete the domain inventory
+	log.Infof("Deleting domains for user ID %d", id)
+	err = db.Where("user_id=?", id).Delete(&Domain{}).Error
+	if err != nil {
+		return err
+	}
+	// End the user's sessions
+	err = DeleteAdminSessions(id)
+	if err != nil {
+		return err
+	}
+	// Revoke the user's embed tokens
+	err = db.Where("user_id=?", id).Delete(&EmbedToken{}).Error
+	if err != nil {
+		return err
+	}
+	// Delete the password history
+	err = db.Where("user_id=?", id).Delete(&PasswordHistory{}).Error
+	if err != nil {
+		return err
+	}
 	// Finally, delete the user
 	err = db.Where("id=?", id).Delete(&User{}).Error
 	return err
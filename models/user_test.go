@@ -1,6 +1,9 @@
 package models
 
 import (
+	"time"
+
+	"github.com/gophish/gophish/auth"
 	"github.com/jinzhu/gorm"
 	"gopkg.in/check.v1"
 )
@@ -27,7 +30,7 @@ func (s *ModelsSuite) TestGetUserByAPIKeyWithExistingAPIKey(c *check.C) {
 	u, err := GetUser(1)
 	c.Assert(err, check.Equals, nil)
 
-	got, err := GetUserByAPIKey(u.ApiKey)
+	got, err := GetUserByAPIKey(string(u.ApiKey))
 	c.Assert(err, check.Equals, nil)
 	c.Assert(got.Id, check.Equals, u.Id)
 }
@@ -36,7 +39,7 @@ func (s *ModelsSuite) TestGetUserByAPIKeyWithNotExistingAPIKey(c *check.C) {
 	u, err := GetUser(1)
 	c.Assert(err, check.Equals, nil)
 
-	u, err = GetUserByAPIKey(u.ApiKey + "test")
+	u, err = GetUserByAPIKey(string(u.ApiKey) + "test")
 	c.Assert(err, check.Equals, gorm.ErrRecordNotFound)
 	c.Assert(u.Username, check.Equals, "")
 }
@@ -60,7 +63,7 @@ func (s *ModelsSuite) TestPutUser(c *check.C) {
 func (s *ModelsSuite) TestGeneratedAPIKey(c *check.C) {
 	u, err := GetUser(1)
 	c.Assert(err, check.Equals, nil)
-	c.Assert(u.ApiKey, check.Not(check.Equals), "12345678901234567890123456789012")
+	c.Assert(string(u.ApiKey), check.Not(check.Equals), "12345678901234567890123456789012")
 }
 
 func (s *ModelsSuite) verifyRoleCount(c *check.C, roleID, expected int64) {
@@ -103,3 +106,46 @@ func (s *ModelsSuite) TestDeleteLastAdmin(c *check.C) {
 	// Verify that the admin wasn't deleted
 	s.verifyRoleCount(c, role.ID, 1)
 }
+
+func (s *ModelsSuite) TestPasswordHistory(c *check.C) {
+	defer func(p auth.PasswordPolicy) { auth.Policy = p }(auth.Policy)
+	auth.Policy = auth.PasswordPolicy{MinLength: auth.MinPasswordLength, HistorySize: 2}
+
+	u, err := GetUser(1)
+	c.Assert(err, check.Equals, nil)
+	passwords := []string{"password one", "password two", "password three", "password four"}
+	for _, password := range passwords {
+		c.Assert(u.CheckPasswordHistory(password), check.Equals, nil)
+		hash, err := auth.GeneratePasswordHash(password)
+		c.Assert(err, check.Equals, nil)
+		c.Assert(u.SetPasswordHash(hash), check.Equals, nil)
+		c.Assert(PutUser(&u), check.Equals, nil)
+	}
+
+	// The current password and the two before it are remembered
+	for _, password := range passwords[1:] {
+		c.Assert(u.CheckPasswordHistory(password), check.Equals, auth.ErrPasswordInHistory)
+	}
+	c.Assert(u.CheckPasswordHistory(passwords[0]), check.Equals, nil)
+	var count int
+	c.Assert(db.Model(&PasswordHistory{}).Where("user_id=?", u.Id).Count(&count).Error, check.Equals, nil)
+	c.Assert(count, check.Equals, 2)
+}
+
+func (s *ModelsSuite) TestPasswordExpired(c *check.C) {
+	defer func(p auth.PasswordPolicy) { auth.Policy = p }(auth.Policy)
+	u, err := GetUser(1)
+	c.Assert(err, check.Equals, nil)
+	c.Assert(u.PasswordChangedDate.IsZero(), check.Equals, false)
+
+	u.PasswordChangedDate = time.Now().UTC().Add(-48 * time.Hour)
+	c.Assert(u.PasswordExpired(), check.Equals, false)
+
+	auth.Policy = auth.PasswordPolicy{MinLength: auth.MinPasswordLength, Expiry: 24 * time.Hour}
+	c.Assert(u.PasswordExpired(), check.Equals, true)
+
+	hash, err := auth.GeneratePasswordHash("new password")
+	c.Assert(err, check.Equals, nil)
+	c.Assert(u.SetPasswordHash(hash), check.Equals, nil)
+	c.Assert(u.PasswordExpired(), check.Equals, false)
+}
@@ -0,0 +1,104 @@
+package models
+
+import "strings"
+
+// Device types
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// Device describes the device which made a request, as identified by its
+// user agent.
+type Device struct {
+	Type    string `json:"type"`
+	OS      string `json:"os,omitempty"`
+	Browser string `json:"browser,omitempty"`
+}
+
+// botAgents are found in the user agents of automated clients, such as link
+// scanners and scripts
+var botAgents = []string{"bot", "crawler", "spider", "scanner", "preview", "python-requests", "curl/", "wget/", "go-http-client", "java/", "headless"}
+
+// uaMatch maps a token found in a user agent to a name. The first match in
+// the list is used, so more specific tokens come first.
+type uaMatch struct {
+	token string
+	name  string
+}
+
+var uaOperatingSystems = []uaMatch{
+	{"windows phone", "Windows Phone"},
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"cros", "Chrome OS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+var uaBrowsers = []uaMatch{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"msie", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+	{"safari/", "Safari"},
+}
+
+// ParseUserAgent identifies the device which sent a user agent. It's a
+// best effort, since user agents can't be trusted and change over time.
+func ParseUserAgent(ua string) Device {
+	lower := strings.ToLower(ua)
+	if strings.TrimSpace(lower) == "" {
+		return Device{Type: DeviceUnknown}
+	}
+	d := Device{
+		OS:      matchUserAgent(lower, uaOperatingSystems),
+		Browser: matchUserAgent(lower, uaBrowsers),
+	}
+	switch {
+	case matchAny(lower, botAgents):
+		d.Type = DeviceBot
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		d.Type = DeviceTablet
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone") || strings.Contains(lower, "ipod"):
+		d.Type = DeviceMobile
+	case d.OS != "":
+		d.Type = DeviceDesktop
+	default:
+		d.Type = DeviceUnknown
+	}
+	return d
+}
+
+func matchUserAgent(ua string, matches []uaMatch) string {
+	for _, m := range matches {
+		if strings.Contains(ua, m.token) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+func matchAny(ua string, tokens []string) bool {
+	for _, t := range tokens {
+		if strings.Contains(ua, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	check "gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestParseUserAgent(ch *check.C) {
+	tests := map[string]Device{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.93 Safari/537.36":                      {Type: DeviceDesktop, OS: "Windows", Browser: "Chrome"},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.93 Safari/537.36 Edg/90.0.818.56":      {Type: DeviceDesktop, OS: "Windows", Browser: "Edge"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1 Safari/605.1.15":                   {Type: DeviceDesktop, OS: "macOS", Browser: "Safari"},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 14_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1 Mobile/15E148 Safari/604.1": {Type: DeviceMobile, OS: "iOS", Browser: "Safari"},
+		"Mozilla/5.0 (iPad; CPU OS 14_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1 Mobile/15E148 Safari/604.1":          {Type: DeviceTablet, OS: "iOS", Browser: "Safari"},
+		"Mozilla/5.0 (Linux; Android 11; Pixel 5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.91 Mobile Safari/537.36":                {Type: DeviceMobile, OS: "Android", Browser: "Chrome"},
+		"Mozilla/5.0 (X11; Linux x86_64; rv:88.0) Gecko/20100101 Firefox/88.0":                                                                    {Type: DeviceDesktop, OS: "Linux", Browser: "Firefox"},
+		"python-requests/2.25.1":  {Type: DeviceBot},
+		"Googlebot/2.1":           {Type: DeviceBot},
+		"":                        {Type: DeviceUnknown},
+		"SomethingUnrecognizable": {Type: DeviceUnknown},
+	}
+	for ua, expected := range tests {
+		ch.Assert(ParseUserAgent(ua), check.Equals, expected, check.Commentf("user agent %q", ua))
+	}
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
+	"context"
 	"errors"
 
 	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/schema"
+	"github.com/gophish/gophish/webhook"
 )
 
 // Webhook represents the webhook model
@@ -13,6 +16,16 @@ type Webhook struct {
 	URL      string `json:"url"`
 	Secret   string `json:"secret"`
 	IsActive bool   `json:"is_active"`
+	// KeyId identifies the secret in signatures. It's set when the secret
+	// is first rotated.
+	KeyId string `json:"key_id"`
+	// SchemaVersion is the version of the payloads sent to the webhook. It
+	// defaults to the oldest version, so that existing consumers keep
+	// working.
+	SchemaVersion int `json:"schema_version"`
+	// PreviousSecrets are the rotated secrets which are still used to sign
+	// events.
+	PreviousSecrets []WebhookSecret `json:"previous_secrets" gorm:"-"`
 }
 
 // ErrURLNotSpecified indicates there was no URL specified
@@ -25,14 +38,50 @@ var ErrNameNotSpecified = errors.New("Name can't be empty")
 func GetWebhooks() ([]Webhook, error) {
 	whs := []Webhook{}
 	err := db.Find(&whs).Error
-	return whs, err
+	if err != nil {
+		return whs, err
+	}
+	return whs, getPreviousSecrets(whs)
 }
 
 // GetActiveWebhooks returns the active webhooks
 func GetActiveWebhooks() ([]Webhook, error) {
 	whs := []Webhook{}
 	err := db.Where("is_active=?", true).Find(&whs).Error
-	return whs, err
+	if err != nil {
+		return whs, err
+	}
+	return whs, getPreviousSecrets(whs)
+}
+
+// getPreviousSecrets loads the previous secrets of each webhook.
+func getPreviousSecrets(whs []Webhook) error {
+	for i := range whs {
+		err := whs[i].getPreviousSecrets()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendWebhooks sends the payload to the active webhooks. Events are sent with
+// the ID of the request which caused them.
+func sendWebhooks(data interface{}) {
+	ctx := context.Background()
+	if e, ok := data.(*Event); ok {
+		ctx = log.WithRequestID(ctx, e.RequestId)
+	}
+	whs, err := GetActiveWebhooks()
+	if err != nil {
+		log.FromContext(ctx).Errorf("error getting active webhooks: %v", err)
+		return
+	}
+	whEndPoints := []webhook.EndPoint{}
+	for _, wh := range whs {
+		whEndPoints = append(whEndPoints, wh.EndPoint())
+	}
+	webhook.SendAllContext(ctx, whEndPoints, data)
 }
 
 // GetWebhook returns the webhook that the given id corresponds to.
@@ -40,6 +89,10 @@ func GetActiveWebhooks() ([]Webhook, error) {
 func GetWebhook(id int64) (Webhook, error) {
 	wh := Webhook{}
 	err := db.Where("id=?", id).First(&wh).Error
+	if err != nil {
+		return wh, err
+	}
+	err = wh.getPreviousSecrets()
 	return wh, err
 }
 
@@ -50,31 +103,53 @@ func PostWebhook(wh *Webhook) error {
 		log.Error(err)
 		return err
 	}
+	// Key ids are only given to secrets when they're rotated
+	wh.KeyId = ""
 	err = db.Save(wh).Error
 	if err != nil {
 		log.Error(err)
 	}
+	wh.PreviousSecrets = []WebhookSecret{}
 	return err
 }
 
-// PutWebhook edits an existing webhook in the database.
+// PutWebhook edits an existing webhook in the database. The key id can't be
+// changed, but a new one is given if the secret is replaced outside of a
+// rotation.
 func PutWebhook(wh *Webhook) error {
 	err := wh.Validate()
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	existing, err := GetWebhook(wh.Id)
+	if err != nil {
+		return err
+	}
+	wh.KeyId = existing.KeyId
+	if wh.KeyId != "" && wh.Secret != existing.Secret {
+		wh.KeyId = newKeyId()
+	}
 	err = db.Save(wh).Error
-	return err
+	if err != nil {
+		return err
+	}
+	return wh.getPreviousSecrets()
 }
 
 // DeleteWebhook deletes an existing webhook in the database.
 // An error is returned if a webhook with the given id isn't found.
 func DeleteWebhook(id int64) error {
-	err := db.Where("id=?", id).Delete(&Webhook{}).Error
+	err := db.Where("webhook_id=?", id).Delete(&WebhookSecret{}).Error
+	if err != nil {
+		return err
+	}
+	err = db.Where("id=?", id).Delete(&Webhook{}).Error
 	return err
 }
 
+// Validate checks the webhook, setting the default schema version if one
+// isn't given.
 func (wh *Webhook) Validate() error {
 	if wh.URL == "" {
 		return ErrURLNotSpecified
@@ -82,5 +157,18 @@ func (wh *Webhook) Validate() error {
 	if wh.Name == "" {
 		return ErrNameNotSpecified
 	}
-	return nil
+	if wh.SchemaVersion == 0 {
+		wh.SchemaVersion = schema.Oldest
+	}
+	return schema.Validate(wh.SchemaVersion)
+}
+
+// EndPoint returns the endpoint that the webhook's payloads are sent to.
+func (wh *Webhook) EndPoint() webhook.EndPoint {
+	return webhook.EndPoint{
+		URL:           wh.URL,
+		Secret:        wh.Secret,
+		Keys:          wh.keys(),
+		SchemaVersion: wh.SchemaVersion,
+	}
 }
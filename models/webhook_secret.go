@@ -0,0 +1,129 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gophish/gophish/webhook"
+)
+
+// DefaultSecretOverlap is how long a webhook's previous secret is still used
+// to sign events after it's rotated, if no overlap is given.
+const DefaultSecretOverlap = 24 * time.Hour
+
+// ErrInvalidOverlap is returned when rotating a webhook secret with a
+// negative overlap.
+var ErrInvalidOverlap = errors.New("The overlap can't be negative")
+
+// WebhookSecret is a previous secret of a webhook. Events are signed with it
+// as well as the current secret until it expires, so that receivers can
+// update their secret without rejecting events.
+type WebhookSecret struct {
+	Id          int64     `json:"-"`
+	WebhookId   int64     `json:"-"`
+	KeyId       string    `json:"key_id"`
+	Secret      string    `json:"secret"`
+	ExpiresDate time.Time `json:"expires_date"`
+}
+
+// WebhookRotation is a request to rotate a webhook's secret. A new secret is
+// generated if one isn't given.
+type WebhookRotation struct {
+	Secret string `json:"secret,omitempty"`
+	// OverlapHours is how long the current secret is still used after
+	// rotating it. It defaults to DefaultSecretOverlap, and 0 stops using the
+	// secret immediately.
+	OverlapHours *int `json:"overlap_hours,omitempty"`
+}
+
+// newKeyId returns a random ID for a webhook secret.
+func newKeyId() string {
+	k := make([]byte, 8)
+	rand.Read(k)
+	return hex.EncodeToString(k)
+}
+
+// getPreviousSecrets loads the webhook's previous secrets which haven't
+// expired, newest first.
+func (wh *Webhook) getPreviousSecrets() error {
+	wh.PreviousSecrets = []WebhookSecret{}
+	return db.Where("webhook_id=? AND expires_date > ?", wh.Id, time.Now().UTC()).
+		Order("expires_date desc").Find(&wh.PreviousSecrets).Error
+}
+
+// RotateWebhookSecret replaces the secret of the webhook with the given id.
+// The current secret is still used to sign events for the overlap period.
+func RotateWebhookSecret(id int64, rotation WebhookRotation) (Webhook, error) {
+	overlap := DefaultSecretOverlap
+	if rotation.OverlapHours != nil {
+		if *rotation.OverlapHours < 0 {
+			return Webhook{}, ErrInvalidOverlap
+		}
+		overlap = time.Duration(*rotation.OverlapHours) * time.Hour
+	}
+	wh, err := GetWebhook(id)
+	if err != nil {
+		return wh, err
+	}
+	now := time.Now().UTC()
+	tx := db.Begin()
+	// Expired secrets aren't needed anymore
+	err = tx.Where("webhook_id=? AND expires_date <= ?", wh.Id, now).Delete(&WebhookSecret{}).Error
+	if err != nil {
+		tx.Rollback()
+		return wh, err
+	}
+	// Webhooks created before secrets could be rotated don't have a key id,
+	// so the secret is given one for receivers to tell them apart.
+	if wh.KeyId == "" {
+		wh.KeyId = newKeyId()
+	}
+	if overlap > 0 && wh.Secret != "" {
+		previous := WebhookSecret{
+			WebhookId:   wh.Id,
+			KeyId:       wh.KeyId,
+			Secret:      wh.Secret,
+			ExpiresDate: now.Add(overlap),
+		}
+		err = tx.Save(&previous).Error
+		if err != nil {
+			tx.Rollback()
+			return wh, err
+		}
+	}
+	wh.KeyId = newKeyId()
+	wh.Secret = rotation.Secret
+	if wh.Secret == "" {
+		wh.Secret = generateSecureKey()
+	}
+	err = tx.Model(&wh).Updates(map[string]interface{}{"key_id": wh.KeyId, "secret": wh.Secret}).Error
+	if err != nil {
+		tx.Rollback()
+		return wh, err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return wh, err
+	}
+	err = wh.getPreviousSecrets()
+	return wh, err
+}
+
+// keys returns the keys used to sign the webhook's events, with the current
+// secret first. Webhooks whose secret has never been rotated don't have
+// keys, and their events are signed with the secret alone.
+func (wh *Webhook) keys() []webhook.Key {
+	if wh.KeyId == "" {
+		return nil
+	}
+	keys := []webhook.Key{{ID: wh.KeyId, Secret: wh.Secret}}
+	now := time.Now().UTC()
+	for _, s := range wh.PreviousSecrets {
+		if s.ExpiresDate.After(now) {
+			keys = append(keys, webhook.Key{ID: s.KeyId, Secret: s.Secret})
+		}
+	}
+	return keys
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gophish/gophish/webhook"
+	"gopkg.in/check.v1"
+)
+
+func (s *ModelsSuite) TestRotateWebhookSecret(ch *check.C) {
+	wh := Webhook{Name: "Webhook", URL: "https://example.com", Secret: "original"}
+	ch.Assert(PostWebhook(&wh), check.Equals, nil)
+
+	// Webhooks which haven't been rotated sign with their secret alone
+	ch.Assert(wh.EndPoint().Keys, check.IsNil)
+
+	rotated, err := RotateWebhookSecret(wh.Id, WebhookRotation{Secret: "new"})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(rotated.Secret, check.Equals, "new")
+	ch.Assert(rotated.KeyId, check.Not(check.Equals), "")
+	ch.Assert(len(rotated.PreviousSecrets), check.Equals, 1)
+	previous := rotated.PreviousSecrets[0]
+	ch.Assert(previous.Secret, check.Equals, "original")
+	ch.Assert(previous.KeyId, check.Not(check.Equals), rotated.KeyId)
+	ch.Assert(previous.ExpiresDate.After(time.Now().Add(DefaultSecretOverlap-time.Minute)), check.Equals, true)
+
+	// Both secrets are used to sign events, newest first
+	got, err := GetWebhook(wh.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(got.EndPoint().Keys, check.DeepEquals, []webhook.Key{
+		{ID: rotated.KeyId, Secret: "new"},
+		{ID: previous.KeyId, Secret: "original"},
+	})
+
+	// Rotating without an overlap stops using the current secret, and a
+	// secret is generated if one isn't given
+	overlap := 0
+	rotated, err = RotateWebhookSecret(wh.Id, WebhookRotation{OverlapHours: &overlap})
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(rotated.Secret), check.Equals, 64)
+	ch.Assert(len(rotated.PreviousSecrets), check.Equals, 1)
+	ch.Assert(rotated.PreviousSecrets[0].Secret, check.Equals, "original")
+
+	overlap = -1
+	_, err = RotateWebhookSecret(wh.Id, WebhookRotation{OverlapHours: &overlap})
+	ch.Assert(err, check.Equals, ErrInvalidOverlap)
+}
+
+func (s *ModelsSuite) TestWebhookSecretExpiry(ch *check.C) {
+	wh := Webhook{Name: "Webhook", URL: "https://example.com", Secret: "original"}
+	ch.Assert(PostWebhook(&wh), check.Equals, nil)
+	rotated, err := RotateWebhookSecret(wh.Id, WebhookRotation{})
+	ch.Assert(err, check.Equals, nil)
+
+	// Expired secrets aren't used
+	err = db.Model(&WebhookSecret{}).Where("webhook_id=?", wh.Id).
+		Update("expires_date", time.Now().UTC().Add(-time.Minute)).Error
+	ch.Assert(err, check.Equals, nil)
+	got, err := GetWebhook(wh.Id)
+	ch.Assert(err, check.Equals, nil)
+	ch.Assert(len(got.PreviousSecrets), check.Equals, 0)
+	ch.Assert(got.EndPoint().Keys, check.DeepEquals, []webhook.Key{{ID: rotated.KeyId, Secret: rotated.Secret}})
+
+	// Replacing the secret directly gives it a new key id
+	got.Secret = "replaced"
+	ch.Assert(PutWebhook(&got), check.Equals, nil)
+	ch.Assert(got.KeyId, check.Not(check.Equals), rotated.KeyId)
+
+	ch.Assert(DeleteWebhook(wh.Id), check.Equals, nil)
+	var count int
+	db.Model(&WebhookSecret{}).Where("webhook_id=?", wh.Id).Count(&count)
+	ch.Assert(count, check.Equals, 0)
+}
@@ -0,0 +1,69 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+)
+
+type embedded struct {
+	Email string `json:"email"`
+}
+
+type example struct {
+	Id       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Secret   string            `json:"-"`
+	Port     uint16            `json:"port,string,omitempty"`
+	Created  time.Time         `json:"created"`
+	Data     []byte            `json:"data"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Children []example         `json:"children"`
+	Any      interface{}       `json:"any"`
+	private  string
+	embedded
+}
+
+func TestSchemaOf(t *testing.T) {
+	d := New(Info{Title: "Test", Version: "1.0"})
+	s := d.SchemaOf([]example{})
+	if s.Type != "array" || s.Items.Ref != "#/components/schemas/Example" {
+		t.Fatalf("unexpected schema for slice: %#v", s)
+	}
+	e, ok := d.Components.Schemas["Example"]
+	if !ok {
+		t.Fatalf("example schema was not added to the components")
+	}
+	expected := map[string]Schema{
+		"id":      {Type: "integer", Format: "int64"},
+		"name":    {Type: "string"},
+		"port":    {Type: "string"},
+		"created": {Type: "string", Format: "date-time"},
+		"data":    {Type: "string", Format: "byte"},
+		"email":   {Type: "string"},
+		"any":     {},
+	}
+	for name, want := range expected {
+		got, ok := e.Properties[name]
+		if !ok {
+			t.Fatalf("property %s not found", name)
+		}
+		if got.Type != want.Type || got.Format != want.Format {
+			t.Fatalf("unexpected schema for %s. expected %#v got %#v", name, want, got)
+		}
+	}
+	for _, name := range []string{"Secret", "private", "embedded"} {
+		if _, ok := e.Properties[name]; ok {
+			t.Fatalf("unexpected property %s found", name)
+		}
+	}
+	if e.Properties["children"].Items.Ref != "#/components/schemas/Example" {
+		t.Fatalf("unexpected schema for recursive field: %#v", e.Properties["children"])
+	}
+	if e.Properties["labels"].AdditionalProperties.Type != "string" {
+		t.Fatalf("unexpected schema for map: %#v", e.Properties["labels"])
+	}
+	if len(e.Properties) != 10 {
+		t.Fatalf("unexpected number of properties. expected %d got %d", 10, len(e.Properties))
+	}
+}
@@ -0,0 +1,53 @@
+// Command tagger is an example event processor plugin. It tags each event
+// with the domain of the recipient's email address, and suppresses the events
+// of the addresses listed in the TAGGER_IGNORE environment variable, such as
+// those of the people testing a campaign.
+//
+// Build it with:
+//
+//	go build -buildmode=plugin -o tagger.so ./plugins/example/tagger
+//
+// and add the path of tagger.so to the "plugins" list in config.json.
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gophish/gophish/models"
+)
+
+type tagger struct {
+	ignore map[string]bool
+}
+
+// ProcessEvent tags the event with the recipient's domain, and suppresses
+// it if the recipient is ignored.
+func (t *tagger) ProcessEvent(e *models.Event) (bool, error) {
+	email := strings.ToLower(e.Email)
+	if t.ignore[email] {
+		return false, nil
+	}
+	if i := strings.LastIndex(email, "@"); i != -1 {
+		e.AddTag("domain:" + email[i+1:])
+	}
+	return true, nil
+}
+
+// newTagger returns a tagger which ignores the addresses in TAGGER_IGNORE.
+func newTagger() *tagger {
+	t := &tagger{ignore: map[string]bool{}}
+	for _, email := range strings.Split(os.Getenv("TAGGER_IGNORE"), ",") {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if email != "" {
+			t.ignore[email] = true
+		}
+	}
+	return t
+}
+
+// EventProcessor is loaded by Gophish.
+var EventProcessor models.EventProcessor = newTagger()
+
+// main is unused, since the plugin is loaded by Gophish.
+func main() {}
@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"fmt"
+	"path/filepath"
+	"plugin"
+	"strings"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/models"
+)
+
+// Symbol is the name of the variable plugins export their event processor
+// as.
+const Symbol = "EventProcessor"
+
+// Load opens each plugin and registers its event processor, named after the
+// plugin's file. Processors are run in the order the plugins are given.
+func Load(paths []string) error {
+	for _, path := range paths {
+		p, err := lookup(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		models.RegisterEventProcessor(name, p)
+		log.Infof("Loaded event processor plugin %s", name)
+	}
+	return nil
+}
+
+// lookup opens the plugin at the path and returns its event processor.
+func lookup(path string) (models.EventProcessor, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening plugin %s: %v", path, err)
+	}
+	sym, err := p.Lookup(Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error loading plugin %s: %v", path, err)
+	}
+	// Exported variables are looked up as pointers to the variable
+	switch v := sym.(type) {
+	case *models.EventProcessor:
+		return *v, nil
+	case models.EventProcessor:
+		return v, nil
+	}
+	return nil, fmt.Errorf("error loading plugin %s: %s doesn't implement models.EventProcessor", path, Symbol)
+}
@@ -0,0 +1,19 @@
+package plugins
+
+import (
+	"testing"
+)
+
+func TestLoadMissingPlugin(t *testing.T) {
+	err := Load([]string{"testdata/missing.so"})
+	if err == nil {
+		t.Fatalf("expected an error loading a missing plugin")
+	}
+}
+
+func TestLoadNoPlugins(t *testing.T) {
+	err := Load(nil)
+	if err != nil {
+		t.Fatalf("unexpected error loading no plugins: %v", err)
+	}
+}
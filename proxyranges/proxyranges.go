@@ -0,0 +1,239 @@
+package proxyranges
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/dialer"
+	log "github.com/gophish/gophish/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTimeout is how long to wait when fetching a feed
+const DefaultTimeout = time.Minute
+
+// DefaultRefreshInterval is how often feeds are fetched if no interval is
+// configured
+const DefaultRefreshInterval = 24 * time.Hour
+
+// maxFeedSize limits the size of a feed, since Apple's egress ranges alone
+// are tens of megabytes.
+const maxFeedSize = 128 << 20
+
+// builtinRanges are the ranges used by the Gmail image proxy, which are
+// stable enough to detect machine opens without any feeds configured.
+var builtinRanges = []string{
+	"66.102.0.0/20",
+	"66.249.80.0/20",
+	"74.125.0.0/16",
+}
+
+// List is a set of IP ranges which is safe for concurrent use.
+type List struct {
+	mu   sync.RWMutex
+	nets []*net.IPNet
+}
+
+// Default is the list of proxy ranges used to detect machine opens.
+var Default = New()
+
+// New returns a list containing the built in ranges.
+func New() *List {
+	l := &List{}
+	l.Set(nil)
+	return l
+}
+
+// Set replaces the ranges loaded from feeds. The built in ranges are always
+// kept.
+func (l *List) Set(nets []*net.IPNet) {
+	all := make([]*net.IPNet, 0, len(builtinRanges)+len(nets))
+	for _, r := range builtinRanges {
+		_, n, _ := net.ParseCIDR(r)
+		all = append(all, n)
+	}
+	all = append(all, nets...)
+	l.mu.Lock()
+	l.nets = all
+	l.mu.Unlock()
+}
+
+// Len returns the number of ranges in the list.
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.nets)
+}
+
+// Contains returns whether the IP address is in one of the ranges.
+func (l *List) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, n := range l.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update fetches the ranges from each of the feeds, replacing the ranges
+// previously loaded. If any feed can't be fetched, the previous ranges are
+// kept and the error is returned.
+func (l *List) Update(ctx context.Context, client *http.Client, urls []string) error {
+	nets := []*net.IPNet{}
+	for _, u := range urls {
+		n, err := fetch(ctx, client, u)
+		if err != nil {
+			return fmt.Errorf("error fetching proxy ranges from %s: %v", u, err)
+		}
+		nets = append(nets, n...)
+	}
+	l.Set(nets)
+	return nil
+}
+
+// Refresh updates the list from the configured feeds straight away, and
+// then periodically until the context is cancelled.
+func (l *List) Refresh(ctx context.Context, c config.MachineOpens) {
+	if len(c.FeedURLs) == 0 {
+		return
+	}
+	interval := DefaultRefreshInterval
+	if c.RefreshHours > 0 {
+		interval = time.Duration(c.RefreshHours) * time.Hour
+	}
+	client, err := newClient()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	update := func() {
+		err := l.Update(ctx, client, c.FeedURLs)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		log.WithFields(logrus.Fields{
+			"ranges": l.Len(),
+		}).Info("Updated the mail privacy proxy ranges")
+	}
+	update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			update()
+		}
+	}
+}
+
+// newClient returns an HTTP client which connects through the configured
+// proxy, if any.
+func newClient() (*http.Client, error) {
+	d, err := dialer.ProxyDialer("")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout:   DefaultTimeout,
+		Transport: &http.Transport{DialContext: d.DialContext},
+	}, nil
+}
+
+func fetch(ctx context.Context, client *http.Client, u string) ([]*net.IPNet, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return Parse(io.LimitReader(resp.Body, maxFeedSize))
+}
+
+// googleRanges is the format of Google's published IP ranges
+type googleRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+	} `json:"prefixes"`
+}
+
+// Parse reads the ranges from a feed. Single addresses are treated as ranges
+// containing only that address.
+func Parse(r io.Reader) ([]*net.IPNet, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	nets := []*net.IPNet{}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		gr := googleRanges{}
+		if err := json.Unmarshal(trimmed, &gr); err != nil {
+			return nil, err
+		}
+		for _, p := range gr.Prefixes {
+			n, err := parseRange(p.IPv4Prefix + p.IPv6Prefix)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, n)
+		}
+		return nets, nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n, err := parseRange(strings.TrimSpace(strings.Split(line, ",")[0]))
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, s.Err()
+}
+
+func parseRange(r string) (*net.IPNet, error) {
+	if !strings.Contains(r, "/") {
+		ip := net.ParseIP(r)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP range %q", r)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range %q", r)
+	}
+	return n, nil
+}
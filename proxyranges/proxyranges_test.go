@@ -0,0 +1,76 @@
+package proxyranges
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		"csv":   "172.224.224.0/27,GB,GB-EN,London,\n2a02:26f7:b3c0:4000::/64,GB,GB-EN,London,\n",
+		"json":  `{"syncToken": "1", "prefixes": [{"ipv4Prefix": "172.224.224.0/27"}, {"ipv6Prefix": "2a02:26f7:b3c0:4000::/64"}]}`,
+		"plain": "# Proxy ranges\n172.224.224.0/27\n\n2a02:26f7:b3c0:4000::/64\n",
+	}
+	for name, feed := range tests {
+		nets, err := Parse(strings.NewReader(feed))
+		if err != nil {
+			t.Fatalf("error parsing %s feed: %v", name, err)
+		}
+		if len(nets) != 2 {
+			t.Fatalf("unexpected number of ranges in %s feed. expected 2 got %d", name, len(nets))
+		}
+		if !nets[0].Contains(net.ParseIP("172.224.224.10")) || !nets[1].Contains(net.ParseIP("2a02:26f7:b3c0:4000::1")) {
+			t.Fatalf("unexpected ranges parsed from %s feed: %v", name, nets)
+		}
+	}
+	nets, err := Parse(strings.NewReader("192.0.2.1\n"))
+	if err != nil {
+		t.Fatalf("error parsing address: %v", err)
+	}
+	if nets[0].String() != "192.0.2.1/32" {
+		t.Fatalf("unexpected range for address. expected 192.0.2.1/32 got %s", nets[0])
+	}
+	if _, err := Parse(strings.NewReader("not a range\n")); err == nil {
+		t.Fatalf("expected error parsing invalid range")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ranges.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "172.224.224.0/27,GB,GB-EN,London,")
+	}))
+	defer ts.Close()
+
+	l := New()
+	proxy := net.ParseIP("172.224.224.10")
+	if !l.Contains(net.ParseIP("66.249.84.1")) {
+		t.Fatalf("expected the built in ranges to be included")
+	}
+	if l.Contains(proxy) {
+		t.Fatalf("unexpected match for %s before the feed was loaded", proxy)
+	}
+	err := l.Update(context.Background(), ts.Client(), []string{ts.URL + "/ranges.csv"})
+	if err != nil {
+		t.Fatalf("error updating ranges: %v", err)
+	}
+	if !l.Contains(proxy) {
+		t.Fatalf("expected %s to match the feed", proxy)
+	}
+	// Failed updates keep the previous ranges
+	err = l.Update(context.Background(), ts.Client(), []string{ts.URL + "/missing.csv"})
+	if err == nil {
+		t.Fatalf("expected error fetching a missing feed")
+	}
+	if !l.Contains(proxy) {
+		t.Fatalf("expected the previous ranges to be kept")
+	}
+}
@@ -0,0 +1,48 @@
+package reload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+type handler struct {
+	name string
+	fn   func() error
+}
+
+var (
+	mu       sync.Mutex
+	handlers []handler
+)
+
+// Register adds a function which is called whenever Gophish is reloaded.
+func Register(name string, fn func() error) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers = append(handlers, handler{name: name, fn: fn})
+}
+
+// Reload calls each registered function. A failure doesn't stop the other
+// functions from being called, and the errors are returned together.
+func Reload() error {
+	mu.Lock()
+	defer mu.Unlock()
+	errs := []string{}
+	for _, h := range handlers {
+		err := h.fn()
+		if err != nil {
+			log.Errorf("error reloading %s: %v", h.name, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", h.name, err))
+			continue
+		}
+		log.Infof("Reloaded %s", h.name)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
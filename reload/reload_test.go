@@ -0,0 +1,27 @@
+package reload
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	handlers = nil
+	calls := 0
+	Register("first", func() error {
+		calls++
+		return errors.New("invalid certificate")
+	})
+	Register("second", func() error {
+		calls++
+		return nil
+	})
+	err := Reload()
+	if calls != 2 {
+		t.Fatalf("unexpected number of calls. expected %d got %d", 2, calls)
+	}
+	if err == nil || !strings.Contains(err.Error(), "first: invalid certificate") {
+		t.Fatalf("unexpected error reloading: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+// +build !windows
+
+package reload
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// HandleSignals reloads Gophish whenever a SIGHUP is received.
+func HandleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			log.Info("SIGHUP Received... Reloading")
+			Reload()
+		}
+	}()
+}
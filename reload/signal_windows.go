@@ -0,0 +1,5 @@
+package reload
+
+// HandleSignals does nothing on Windows, which doesn't have SIGHUP. Gophish
+// can still be reloaded through the API.
+func HandleSignals() {}
@@ -0,0 +1,48 @@
+package reputation
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gophish/gophish/config"
+)
+
+// DefaultTimeout is how long to wait for a domain's reputation.
+const DefaultTimeout = 30 * time.Second
+
+// Report is the reputation of a domain.
+type Report struct {
+	Source string `json:"source"`
+	// Categories are the categories vendors have given the domain, such as
+	// "business" or "phishing".
+	Categories []string `json:"categories"`
+	// Malicious and Suspicious are the number of vendors which detected the
+	// domain as malicious or suspicious.
+	Malicious  int `json:"malicious"`
+	Suspicious int `json:"suspicious"`
+}
+
+// Burned returns whether the domain has been detected as malicious, so it's
+// likely to be blocked.
+func (r *Report) Burned() bool {
+	return r.Malicious > 0
+}
+
+// Checker looks up the reputation of domains.
+type Checker interface {
+	Check(ctx context.Context, domain string) (*Report, error)
+}
+
+// NewChecker returns the Checker for the configured service. If no service is
+// configured, it returns nil.
+func NewChecker(c config.DomainReputation) (Checker, error) {
+	if c.VirusTotalAPIKey == "" {
+		return nil, nil
+	}
+	return &VirusTotal{
+		URL:    DefaultVirusTotalURL,
+		APIKey: c.VirusTotalAPIKey,
+		Client: &http.Client{Timeout: DefaultTimeout},
+	}, nil
+}
@@ -0,0 +1,70 @@
+package reputation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+)
+
+func TestNewChecker(t *testing.T) {
+	c, err := NewChecker(config.DomainReputation{})
+	if err != nil || c != nil {
+		t.Fatalf("expected no checker, got %v, %v", c, err)
+	}
+	c, err = NewChecker(config.DomainReputation{VirusTotalAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := c.(*VirusTotal); !ok {
+		t.Fatalf("expected a VirusTotal checker, got %T", c)
+	}
+}
+
+func TestVirusTotal(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-apikey") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/domains/burned.example.com":
+			w.Write([]byte(`{"data": {"attributes": {
+				"categories": {"Forcepoint ThreatSeeker": "Phishing", "Sophos": "phishing", "BitDefender": "business"},
+				"last_analysis_stats": {"malicious": 3, "suspicious": 1, "harmless": 60}
+			}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	v := &VirusTotal{URL: ts.URL, APIKey: "key"}
+	report, err := v.Check(context.Background(), "burned.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(report.Categories, []string{"business", "phishing"}) {
+		t.Fatalf("unexpected categories %v", report.Categories)
+	}
+	if report.Malicious != 3 || report.Suspicious != 1 || !report.Burned() {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	report, err = v.Check(context.Background(), "new.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Burned() || len(report.Categories) != 0 {
+		t.Fatalf("expected an empty report for an unknown domain, got %+v", report)
+	}
+
+	v.APIKey = "wrong"
+	_, err = v.Check(context.Background(), "burned.example.com")
+	if err == nil {
+		t.Fatalf("expected an error for an invalid API key")
+	}
+}
@@ -0,0 +1,82 @@
+package reputation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultVirusTotalURL is the base URL of the VirusTotal API.
+const DefaultVirusTotalURL = "https://www.virustotal.com/api/v3"
+
+// VirusTotal looks up domains using the VirusTotal API.
+type VirusTotal struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+type virusTotalResponse struct {
+	Data struct {
+		Attributes struct {
+			Categories        map[string]string `json:"categories"`
+			LastAnalysisStats struct {
+				Malicious  int `json:"malicious"`
+				Suspicious int `json:"suspicious"`
+			} `json:"last_analysis_stats"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// Check looks up the domain's report. Domains VirusTotal hasn't seen have an
+// empty report.
+func (v *VirusTotal) Check(ctx context.Context, domain string) (*Report, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(v.URL, "/")+"/domains/"+url.PathEscape(domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("x-apikey", v.APIKey)
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	report := &Report{Source: "virustotal", Categories: []string{}}
+	if resp.StatusCode == http.StatusNotFound {
+		return report, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("virustotal returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	vr := virusTotalResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&vr)
+	if err != nil {
+		return nil, err
+	}
+	attrs := vr.Data.Attributes
+	report.Malicious = attrs.LastAnalysisStats.Malicious
+	report.Suspicious = attrs.LastAnalysisStats.Suspicious
+	seen := map[string]bool{}
+	for _, category := range attrs.Categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		report.Categories = append(report.Categories, category)
+	}
+	sort.Strings(report.Categories)
+	return report, nil
+}
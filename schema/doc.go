@@ -0,0 +1,56 @@
+/*
+gophish - Open-Source Phishing Framework
+
+The MIT License (MIT)
+
+Copyright (c) 2013 Jordan Wright
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package schema defines the versions of the JSON sent to API clients and
+// webhooks.
+//
+// Consumers choose the version they understand, so that changes to the models
+// don't break them. API clients send the version in the X-Gophish-Schema-Version
+// header, or as the version parameter of an application/vnd.gophish+json
+// Accept header. Webhooks have a schema_version field. When no version is
+// given, the oldest version is used.
+//
+// The versions are:
+//
+// 1: The original format. Webhooks are sent the event itself.
+//
+// 2: Webhooks are sent an envelope with the schema version, the type of the
+// payload, and the payload itself in the data field.
+//
+// 3: Events are sent for forwarded emails, automatic replies, and
+// unsubscribes.
+//
+// 4: Events are sent for landing page requests blocked by access rules.
+//
+// 5: Events are sent for links fetched without passing the landing page's
+// click challenge.
+//
+// 6: Events are sent for requests for recipients' short links.
+//
+// When a model changes in a way that would break consumers, the latest version
+// is incremented and the model implements Versioned to return its previous
+// format to older consumers.
+package schema
@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Oldest is the oldest supported version, which is used when no version
+	// is requested.
+	Oldest = 1
+	// Latest is the current version.
+	Latest = 6
+)
+
+// Header is the HTTP header containing the schema version of a request or
+// response.
+const Header = "X-Gophish-Schema-Version"
+
+// MediaType is the media type which API clients can request a version of
+// using the version parameter, e.g. "application/vnd.gophish+json; version=2".
+const MediaType = "application/vnd.gophish+json"
+
+// ErrUnsupportedVersion is returned when a version isn't supported.
+var ErrUnsupportedVersion = fmt.Errorf("The schema version must be between %d and %d", Oldest, Latest)
+
+// Versioned is implemented by types whose JSON format has changed. ForSchema
+// returns the value in the format of the given version.
+type Versioned interface {
+	ForSchema(version int) interface{}
+}
+
+// Validate returns an error if the version isn't supported.
+func Validate(version int) error {
+	if version < Oldest || version > Latest {
+		return ErrUnsupportedVersion
+	}
+	return nil
+}
+
+// Parse parses a version number, returning Oldest if it's empty.
+func Parse(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Oldest, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, ErrUnsupportedVersion
+	}
+	return v, Validate(v)
+}
+
+// FromAccept returns the version requested by an Accept header, or 0 if it
+// doesn't request one.
+func FromAccept(accept string) (int, error) {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil || mt != MediaType {
+			continue
+		}
+		if _, ok := params["version"]; !ok {
+			return 0, nil
+		}
+		return Parse(params["version"])
+	}
+	return 0, nil
+}
+
+// Convert returns the value in the format of the given version. Values which
+// implement Versioned are converted, as are the elements of slices. Elements
+// which don't exist in the version, which are converted to nil, are left out.
+func Convert(v interface{}, version int) interface{} {
+	if vv, ok := v.(Versioned); ok {
+		return vv.ForSchema(version)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.IsNil() {
+		return v
+	}
+	t := rv.Type().Elem()
+	versioned := reflect.TypeOf((*Versioned)(nil)).Elem()
+	if !t.Implements(versioned) && !reflect.PtrTo(t).Implements(versioned) {
+		return v
+	}
+	converted := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		e := rv.Index(i)
+		if !t.Implements(versioned) {
+			e = e.Addr()
+		}
+		if c := e.Interface().(Versioned).ForSchema(version); c != nil {
+			converted = append(converted, c)
+		}
+	}
+	return converted
+}
@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+type item struct {
+	Name string
+}
+
+func (i item) ForSchema(version int) interface{} {
+	if version < 2 {
+		return i.Name
+	}
+	return i
+}
+
+type pointerItem struct {
+	Name string
+}
+
+func (i *pointerItem) ForSchema(version int) interface{} {
+	if i.Name == "" {
+		return nil
+	}
+	return i.Name
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		err      error
+	}{
+		{input: "", expected: Oldest},
+		{input: "1", expected: 1},
+		{input: " 2 ", expected: 2},
+		{input: "0", expected: 0, err: ErrUnsupportedVersion},
+		{input: "9", expected: 9, err: ErrUnsupportedVersion},
+		{input: "v1", expected: 0, err: ErrUnsupportedVersion},
+	}
+	for _, tc := range tests {
+		got, err := Parse(tc.input)
+		if err != tc.err {
+			t.Fatalf("unexpected error parsing %q. expected %v got %v", tc.input, tc.err, err)
+		}
+		if err == nil && got != tc.expected {
+			t.Fatalf("invalid version parsing %q. expected %d got %d", tc.input, tc.expected, got)
+		}
+	}
+}
+
+func TestFromAccept(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		err      error
+	}{
+		{input: "", expected: 0},
+		{input: "application/json", expected: 0},
+		{input: "application/vnd.gophish+json", expected: 0},
+		{input: "text/html, application/vnd.gophish+json; version=2", expected: 2},
+		{input: "application/vnd.gophish+json; version=9", err: ErrUnsupportedVersion},
+	}
+	for _, tc := range tests {
+		got, err := FromAccept(tc.input)
+		if err != tc.err {
+			t.Fatalf("unexpected error parsing %q. expected %v got %v", tc.input, tc.err, err)
+		}
+		if err == nil && got != tc.expected {
+			t.Fatalf("invalid version parsing %q. expected %d got %d", tc.input, tc.expected, got)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		version  int
+		expected interface{}
+	}{
+		{input: item{Name: "a"}, version: 1, expected: "a"},
+		{input: item{Name: "a"}, version: 2, expected: item{Name: "a"}},
+		{input: []item{{Name: "a"}, {Name: "b"}}, version: 1, expected: []interface{}{"a", "b"}},
+		{input: []pointerItem{{Name: "a"}, {}}, version: 1, expected: []interface{}{"a"}},
+		{input: []string{"a"}, version: 1, expected: []string{"a"}},
+		{input: map[string]string{"a": "b"}, version: 1, expected: map[string]string{"a": "b"}},
+	}
+	for _, tc := range tests {
+		got := Convert(tc.input, tc.version)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("invalid conversion of %#v. expected %#v got %#v", tc.input, tc.expected, got)
+		}
+	}
+}
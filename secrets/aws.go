@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/sigv4"
+)
+
+const (
+	awsService     = "secretsmanager"
+	awsTarget      = "secretsmanager.GetSecretValue"
+	awsContentType = "application/x-amz-json-1.1"
+)
+
+// AWSProvider reads secrets from AWS Secrets Manager. Requests are signed
+// using AWS Signature Version 4.
+type AWSProvider struct {
+	Region          string
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	NamePrefix      string
+	client          *http.Client
+	now             func() time.Time
+}
+
+// NewAWSProvider returns a new AWSProvider, or nil if AWS Secrets Manager
+// isn't configured. Credentials which aren't set in the config are read from
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+// environment variables.
+func NewAWSProvider(c config.AWSSecrets) (*AWSProvider, error) {
+	if c.Region == "" {
+		return nil, nil
+	}
+	p := &AWSProvider{
+		Region:          c.Region,
+		Endpoint:        c.Endpoint,
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		NamePrefix:      c.NamePrefix,
+		client:          &http.Client{Timeout: DefaultTimeout},
+		now:             time.Now,
+	}
+	if p.AccessKeyID == "" && p.SecretAccessKey == "" {
+		p.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		p.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		p.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if p.AccessKeyID == "" || p.SecretAccessKey == "" {
+		return nil, fmt.Errorf("aws secrets require an access key id and secret access key")
+	}
+	if p.Endpoint == "" {
+		p.Endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", awsService, p.Region)
+	}
+	return p, nil
+}
+
+type awsSecretValue struct {
+	SecretString string `json:"SecretString"`
+}
+
+type awsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// GetSecret returns the string value of the secret with the given name,
+// relative to the prefix.
+func (p *AWSProvider) GetSecret(name string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": p.NamePrefix + name})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", p.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", awsContentType)
+	req.Header.Set("X-Amz-Target", awsTarget)
+	sigv4.Sign(req, payload, p.now(), p.Region, awsService, p.AccessKeyID, p.SecretAccessKey, p.SessionToken)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ae := awsError{}
+		json.NewDecoder(resp.Body).Decode(&ae)
+		if strings.HasSuffix(ae.Type, "ResourceNotFoundException") {
+			return "", ErrSecretNotFound
+		}
+		return "", fmt.Errorf("unexpected status code from aws: %d %s %s", resp.StatusCode, ae.Type, ae.Message)
+	}
+	sv := awsSecretValue{}
+	err = json.NewDecoder(resp.Body).Decode(&sv)
+	if err != nil {
+		return "", err
+	}
+	return sv.SecretString, nil
+}
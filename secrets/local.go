@@ -0,0 +1,47 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvProvider reads secrets from environment variables. Only variables
+// starting with the configured prefix can be referenced, so that
+// env://SMTP_PASSWORD reads the variable GOPHISH_SECRET_SMTP_PASSWORD when the
+// prefix is GOPHISH_SECRET_.
+type EnvProvider struct {
+	Prefix string
+}
+
+// GetSecret returns the value of the environment variable
+func (p *EnvProvider) GetSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(p.Prefix + name)
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// FileProvider reads secrets from files in a directory, such as the
+// directories secrets are mounted in by Docker or Kubernetes.
+type FileProvider struct {
+	Directory string
+}
+
+// GetSecret returns the contents of the file, without any trailing newline
+func (p *FileProvider) GetSecret(name string) (string, error) {
+	path := filepath.Join(p.Directory, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(p.Directory)+string(filepath.Separator)) {
+		return "", ErrInvalidReference
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -0,0 +1,259 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophish/gophish/config"
+)
+
+// DefaultCacheTTL is how long resolved secrets are cached when no TTL is
+// configured. Rotated secrets are picked up once the cached value expires.
+const DefaultCacheTTL = 5 * time.Minute
+
+// The schemes used to reference secrets from each provider
+const (
+	SchemeEnv   = "env"
+	SchemeFile  = "file"
+	SchemeVault = "vault"
+	SchemeAWS   = "aws-sm"
+)
+
+var schemes = []string{SchemeEnv, SchemeFile, SchemeVault, SchemeAWS}
+
+// ErrProviderNotConfigured is thrown when a secret references a provider
+// which hasn't been configured
+var ErrProviderNotConfigured = errors.New("The secrets provider for this reference has not been configured")
+
+// ErrInvalidReference is thrown when a secret reference can't be parsed
+var ErrInvalidReference = errors.New("Invalid secret reference")
+
+// ErrSecretNotFound is thrown when the referenced secret doesn't exist
+var ErrSecretNotFound = errors.New("Secret not found")
+
+// ErrFieldNotFound is thrown when the referenced field doesn't exist in the
+// secret
+var ErrFieldNotFound = errors.New("Field not found in secret")
+
+// Provider is an external store that secrets can be read from.
+type Provider interface {
+	// GetSecret returns the value of the secret with the given name, which is
+	// relative to the provider's configured prefix.
+	GetSecret(name string) (string, error)
+}
+
+// Reference is a parsed reference to a secret, in the form
+// scheme://name#field. The field is optional, and selects a single value
+// from a secret containing a JSON object.
+type Reference struct {
+	Scheme string
+	Name   string
+	Field  string
+}
+
+func (r Reference) cacheKey() string {
+	return r.Scheme + "://" + r.Name
+}
+
+// IsReference returns whether or not the value references an external
+// secret rather than being the secret itself.
+func IsReference(s string) bool {
+	for _, scheme := range schemes {
+		if strings.HasPrefix(s, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseReference parses a secret reference. Names may not contain ".."
+// segments, since they could otherwise escape the provider's prefix.
+func ParseReference(s string) (Reference, error) {
+	r := Reference{}
+	if !IsReference(s) {
+		return r, ErrInvalidReference
+	}
+	parts := strings.SplitN(s, "://", 2)
+	r.Scheme = parts[0]
+	r.Name = parts[1]
+	if i := strings.Index(r.Name, "#"); i != -1 {
+		r.Field = r.Name[i+1:]
+		r.Name = r.Name[:i]
+	}
+	if r.Name == "" {
+		return r, ErrInvalidReference
+	}
+	for _, segment := range strings.Split(r.Name, "/") {
+		if segment == ".." {
+			return r, ErrInvalidReference
+		}
+	}
+	return r, nil
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// Resolver resolves secret references using the registered providers,
+// caching the resolved values.
+type Resolver struct {
+	ttl       time.Duration
+	providers map[string]Provider
+	cache     map[string]cachedSecret
+	sync.Mutex
+}
+
+// NewResolver returns a new Resolver which caches secrets for the given
+// duration.
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{
+		ttl:       ttl,
+		providers: make(map[string]Provider),
+		cache:     make(map[string]cachedSecret),
+	}
+}
+
+// Register sets the provider used to resolve references with the given
+// scheme.
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.Lock()
+	defer r.Unlock()
+	r.providers[scheme] = p
+}
+
+// Validate checks that the reference can be parsed and that its provider has
+// been configured, without fetching the secret.
+func (r *Resolver) Validate(s string) error {
+	ref, err := ParseReference(s)
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.providers[ref.Scheme]; !ok {
+		return ErrProviderNotConfigured
+	}
+	return nil
+}
+
+// Resolve returns the value of the referenced secret. Values which aren't
+// references are returned unchanged.
+func (r *Resolver) Resolve(s string) (string, error) {
+	if !IsReference(s) {
+		return s, nil
+	}
+	ref, err := ParseReference(s)
+	if err != nil {
+		return "", err
+	}
+	value, err := r.get(ref)
+	if err != nil {
+		return "", fmt.Errorf("error resolving secret %s: %v", ref.cacheKey(), err)
+	}
+	if ref.Field == "" {
+		return value, nil
+	}
+	fields := map[string]interface{}{}
+	err = json.Unmarshal([]byte(value), &fields)
+	if err != nil {
+		return "", fmt.Errorf("error resolving secret %s: secret is not a JSON object", ref.cacheKey())
+	}
+	field, ok := fields[ref.Field].(string)
+	if !ok {
+		return "", ErrFieldNotFound
+	}
+	return field, nil
+}
+
+func (r *Resolver) get(ref Reference) (string, error) {
+	r.Lock()
+	p, ok := r.providers[ref.Scheme]
+	cached, found := r.cache[ref.cacheKey()]
+	r.Unlock()
+	if !ok {
+		return "", ErrProviderNotConfigured
+	}
+	if found && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+	value, err := p.GetSecret(ref.Name)
+	if err != nil {
+		return "", err
+	}
+	r.Lock()
+	r.cache[ref.cacheKey()] = cachedSecret{
+		value:   value,
+		expires: time.Now().Add(r.ttl),
+	}
+	r.Unlock()
+	return value, nil
+}
+
+// Invalidate removes the referenced secret from the cache so that it's
+// fetched again the next time it's resolved. This should be called when a
+// resolved credential is rejected, since the secret may have been rotated.
+func (r *Resolver) Invalidate(s string) {
+	ref, err := ParseReference(s)
+	if err != nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	delete(r.cache, ref.cacheKey())
+}
+
+var defaultResolver = NewResolver(DefaultCacheTTL)
+
+// Setup configures the default resolver with the providers enabled in the
+// config.
+func Setup(c config.Secrets) error {
+	ttl := DefaultCacheTTL
+	if c.CacheTTL > 0 {
+		ttl = time.Duration(c.CacheTTL) * time.Second
+	}
+	r := NewResolver(ttl)
+	if c.EnvPrefix != "" {
+		r.Register(SchemeEnv, &EnvProvider{Prefix: c.EnvPrefix})
+	}
+	if c.Directory != "" {
+		r.Register(SchemeFile, &FileProvider{Directory: c.Directory})
+	}
+	vault, err := NewVaultProvider(c.Vault)
+	if err != nil {
+		return err
+	}
+	if vault != nil {
+		r.Register(SchemeVault, vault)
+	}
+	aws, err := NewAWSProvider(c.AWS)
+	if err != nil {
+		return err
+	}
+	if aws != nil {
+		r.Register(SchemeAWS, aws)
+	}
+	defaultResolver = r
+	return nil
+}
+
+// Resolve returns the value of the referenced secret using the default
+// resolver. Values which aren't references are returned unchanged.
+func Resolve(s string) (string, error) {
+	return defaultResolver.Resolve(s)
+}
+
+// Validate checks that the reference is valid using the default resolver.
+func Validate(s string) error {
+	return defaultResolver.Validate(s)
+}
+
+// Invalidate removes the referenced secret from the default resolver's cache.
+func Invalidate(s string) {
+	defaultResolver.Invalidate(s)
+}
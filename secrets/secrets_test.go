@@ -0,0 +1,187 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophish/gophish/config"
+)
+
+type countingProvider struct {
+	value string
+	calls int
+}
+
+func (p *countingProvider) GetSecret(name string) (string, error) {
+	p.calls++
+	return p.value, nil
+}
+
+func TestParseReference(t *testing.T) {
+	ref, err := ParseReference("vault://gophish/smtp#password")
+	if err != nil {
+		t.Fatalf("unexpected error parsing reference: %v", err)
+	}
+	expected := Reference{Scheme: SchemeVault, Name: "gophish/smtp", Field: "password"}
+	if ref != expected {
+		t.Fatalf("unexpected reference. expected %#v got %#v", expected, ref)
+	}
+	for _, invalid := range []string{"plaintext", "vault://", "file://../etc/passwd", "aws-sm://a/../b"} {
+		_, err = ParseReference(invalid)
+		if err != ErrInvalidReference {
+			t.Fatalf("unexpected error for %s. expected %v got %v", invalid, ErrInvalidReference, err)
+		}
+	}
+}
+
+func TestResolveCaching(t *testing.T) {
+	r := NewResolver(time.Minute)
+	p := &countingProvider{value: `{"password": "secret"}`}
+	r.Register(SchemeVault, p)
+
+	// Values which aren't references are returned as-is
+	got, err := r.Resolve("plaintext")
+	if err != nil || got != "plaintext" {
+		t.Fatalf("unexpected result resolving plaintext. got %s, %v", got, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err = r.Resolve("vault://smtp#password")
+		if err != nil || got != "secret" {
+			t.Fatalf("unexpected result resolving secret. got %s, %v", got, err)
+		}
+	}
+	if p.calls != 1 {
+		t.Fatalf("expected secret to be cached. got %d calls", p.calls)
+	}
+
+	// Invalidating the secret should fetch the rotated value
+	p.value = `{"password": "rotated"}`
+	r.Invalidate("vault://smtp#password")
+	got, _ = r.Resolve("vault://smtp#password")
+	if got != "rotated" {
+		t.Fatalf("unexpected value after invalidating. expected rotated got %s", got)
+	}
+
+	_, err = r.Resolve("vault://smtp#username")
+	if err != ErrFieldNotFound {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrFieldNotFound, err)
+	}
+	err = r.Validate("aws-sm://smtp")
+	if err != ErrProviderNotConfigured {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrProviderNotConfigured, err)
+	}
+}
+
+func TestLocalProviders(t *testing.T) {
+	os.Setenv("GOPHISH_TEST_SECRET_SMTP", "env-secret")
+	defer os.Unsetenv("GOPHISH_TEST_SECRET_SMTP")
+	dir, err := ioutil.TempDir("", "gophish-secrets")
+	if err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "smtp"), []byte("file-secret\n"), 0600)
+	if err != nil {
+		t.Fatalf("unexpected error writing secret: %v", err)
+	}
+
+	r := NewResolver(time.Minute)
+	r.Register(SchemeEnv, &EnvProvider{Prefix: "GOPHISH_TEST_SECRET_"})
+	r.Register(SchemeFile, &FileProvider{Directory: dir})
+	got, err := r.Resolve("env://SMTP")
+	if err != nil || got != "env-secret" {
+		t.Fatalf("unexpected result resolving env secret. got %s, %v", got, err)
+	}
+	got, err = r.Resolve("file://smtp")
+	if err != nil || got != "file-secret" {
+		t.Fatalf("unexpected result resolving file secret. got %s, %v", got, err)
+	}
+	_, err = r.Resolve("env://MISSING")
+	if err == nil {
+		t.Fatalf("expected error resolving missing secret")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/gophish/smtp":
+			fmt.Fprint(w, `{"data": {"data": {"password": "kv2-secret"}, "metadata": {"version": 1}}}`)
+		case "/v1/kv/gophish/smtp":
+			fmt.Fprint(w, `{"data": {"password": "kv1-secret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	for prefix, expected := range map[string]string{"secret/data/gophish/": "kv2-secret", "kv/gophish/": "kv1-secret"} {
+		p, err := NewVaultProvider(config.VaultSecrets{Address: ts.URL, Token: "token", PathPrefix: prefix})
+		if err != nil {
+			t.Fatalf("unexpected error creating provider: %v", err)
+		}
+		r := NewResolver(time.Minute)
+		r.Register(SchemeVault, p)
+		got, err := r.Resolve("vault://smtp#password")
+		if err != nil || got != expected {
+			t.Fatalf("unexpected result resolving secret. expected %s got %s, %v", expected, got, err)
+		}
+		_, err = p.GetSecret("missing")
+		if err != ErrSecretNotFound {
+			t.Fatalf("unexpected error received. expected %v got %v", ErrSecretNotFound, err)
+		}
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Target") != awsTarget {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["SecretId"] != "gophish/smtp" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "ResourceNotFoundException", "message": "not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Name": "gophish/smtp", "SecretString": "{\"password\": \"aws-secret\"}"}`)
+	}))
+	defer ts.Close()
+
+	p, err := NewAWSProvider(config.AWSSecrets{
+		Region:          "us-east-1",
+		Endpoint:        ts.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		NamePrefix:      "gophish/",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	r := NewResolver(time.Minute)
+	r.Register(SchemeAWS, p)
+	got, err := r.Resolve("aws-sm://smtp#password")
+	if err != nil || got != "aws-secret" {
+		t.Fatalf("unexpected result resolving secret. got %s, %v", got, err)
+	}
+	_, err = p.GetSecret("missing")
+	if err != ErrSecretNotFound {
+		t.Fatalf("unexpected error received. expected %v got %v", ErrSecretNotFound, err)
+	}
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/config"
+)
+
+// DefaultTimeout is the timeout for requests to external secrets managers
+const DefaultTimeout = 10 * time.Second
+
+// VaultProvider reads secrets from a HashiCorp Vault KV secrets engine. Both
+// version 1 and version 2 of the engine are supported. For version 2, the
+// path prefix must include the "data/" segment, e.g. "secret/data/gophish/".
+//
+// Secrets are returned as a JSON object, so a field should be given in the
+// reference, e.g. vault://smtp#password.
+type VaultProvider struct {
+	Address    string
+	Token      string
+	Namespace  string
+	PathPrefix string
+	client     *http.Client
+}
+
+// NewVaultProvider returns a new VaultProvider, or nil if Vault isn't
+// configured.
+func NewVaultProvider(c config.VaultSecrets) (*VaultProvider, error) {
+	p := &VaultProvider{
+		Address:    c.Address,
+		Token:      c.Token,
+		Namespace:  c.Namespace,
+		PathPrefix: c.PathPrefix,
+		client:     &http.Client{Timeout: DefaultTimeout},
+	}
+	if p.Address == "" {
+		p.Address = os.Getenv("VAULT_ADDR")
+	}
+	if p.Token == "" {
+		p.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if p.PathPrefix == "" {
+		return nil, nil
+	}
+	if p.Address == "" || p.Token == "" {
+		return nil, fmt.Errorf("vault secrets require an address and token")
+	}
+	p.Address = strings.TrimRight(p.Address, "/")
+	return p, nil
+}
+
+// vaultResponse is the response returned when reading a secret. For version
+// 2 of the KV engine, the secret is nested inside the data alongside its
+// metadata.
+type vaultResponse struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type vaultKV2Data struct {
+	Data     json.RawMessage `json:"data"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// GetSecret reads the secret at the given path, relative to the prefix
+func (p *VaultProvider) GetSecret(name string) (string, error) {
+	url := fmt.Sprintf("%s/v1/%s%s", p.Address, p.PathPrefix, name)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrSecretNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code from vault: %d", resp.StatusCode)
+	}
+	vr := vaultResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&vr)
+	if err != nil {
+		return "", err
+	}
+	kv2 := vaultKV2Data{}
+	err = json.Unmarshal(vr.Data, &kv2)
+	if err == nil && len(kv2.Data) > 0 && len(kv2.Metadata) > 0 {
+		return string(kv2.Data), nil
+	}
+	return string(vr.Data), nil
+}
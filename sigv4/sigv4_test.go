@@ -0,0 +1,20 @@
+package sigv4
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	// This is the "get-vanilla" case from the AWS Signature Version 4 test
+	// suite.
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	ts := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	Sign(req, []byte{}, ts, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "")
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	got := req.Header.Get("Authorization")
+	if got != expected {
+		t.Fatalf("unexpected authorization header.\nexpected %s\ngot      %s", expected, got)
+	}
+}
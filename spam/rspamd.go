@@ -0,0 +1,80 @@
+package spam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Rspamd scores emails using the checkv2 endpoint of an Rspamd worker.
+type Rspamd struct {
+	// URL is the base URL of the worker, such as http://localhost:11333.
+	URL      string
+	Password string
+	Client   *http.Client
+}
+
+type rspamdSymbol struct {
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+type rspamdResponse struct {
+	Score         float64                 `json:"score"`
+	RequiredScore float64                 `json:"required_score"`
+	Action        string                  `json:"action"`
+	IsSkipped     bool                    `json:"is_skipped"`
+	Symbols       map[string]rspamdSymbol `json:"symbols"`
+}
+
+// Score submits the email to Rspamd.
+func (r *Rspamd) Score(ctx context.Context, msg []byte) (*Result, error) {
+	req, err := http.NewRequest("POST", strings.TrimSuffix(r.URL, "/")+"/checkv2", bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if r.Password != "" {
+		req.Header.Set("Password", r.Password)
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("rspamd returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	rr := rspamdResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&rr)
+	if err != nil {
+		return nil, err
+	}
+	if rr.IsSkipped {
+		return nil, fmt.Errorf("rspamd skipped scoring the email")
+	}
+	result := &Result{
+		Engine:    "rspamd",
+		Score:     rr.Score,
+		Threshold: rr.RequiredScore,
+		IsSpam:    rr.RequiredScore > 0 && rr.Score >= rr.RequiredScore,
+		Action:    rr.Action,
+		Rules:     []Rule{},
+	}
+	for name, symbol := range rr.Symbols {
+		result.Rules = append(result.Rules, Rule{Name: name, Score: symbol.Score, Description: symbol.Description})
+	}
+	result.sortRules()
+	return result, nil
+}
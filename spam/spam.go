@@ -0,0 +1,68 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gophish/gophish/config"
+)
+
+// DefaultTimeout is how long to wait for the spam filter to score an email.
+const DefaultTimeout = 30 * time.Second
+
+// Rule is a rule or symbol which matched the email.
+type Rule struct {
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
+
+// Result is the spam filter's verdict for an email.
+type Result struct {
+	Engine string  `json:"engine"`
+	Score  float64 `json:"score"`
+	// Threshold is the score at which the filter considers the email spam.
+	Threshold float64 `json:"threshold"`
+	IsSpam    bool    `json:"is_spam"`
+	// Action is the action Rspamd would take, such as "add header".
+	Action string `json:"action,omitempty"`
+	Rules  []Rule `json:"rules"`
+}
+
+// sortRules orders the rules with the largest contributions to the score
+// first.
+func (r *Result) sortRules() {
+	sort.SliceStable(r.Rules, func(i, j int) bool {
+		if r.Rules[i].Score != r.Rules[j].Score {
+			return r.Rules[i].Score > r.Rules[j].Score
+		}
+		return r.Rules[i].Name < r.Rules[j].Name
+	})
+}
+
+// Scorer submits emails to a spam filter.
+type Scorer interface {
+	// Score scores the raw email, including its headers.
+	Score(ctx context.Context, msg []byte) (*Result, error)
+}
+
+// NewScorer returns the Scorer for the configured spam filter. If no filter
+// is configured, it returns nil.
+func NewScorer(c config.SpamScoring) (Scorer, error) {
+	switch c.Engine {
+	case "":
+		return nil, nil
+	case "rspamd":
+		return &Rspamd{
+			URL:      "http://" + c.Host,
+			Password: c.Password,
+			Client:   &http.Client{Timeout: DefaultTimeout},
+		}, nil
+	case "spamassassin":
+		return &SpamAssassin{Host: c.Host, Timeout: DefaultTimeout}, nil
+	}
+	return nil, fmt.Errorf("unsupported spam scoring engine %q", c.Engine)
+}
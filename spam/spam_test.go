@@ -0,0 +1,163 @@
+package spam
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+)
+
+var testMessage = []byte("From: foo@example.com\r\nTo: bar@example.com\r\nSubject: Hello\r\n\r\nHello there\r\n")
+
+func TestNewScorer(t *testing.T) {
+	s, err := NewScorer(config.SpamScoring{})
+	if err != nil || s != nil {
+		t.Fatalf("expected no scorer, got %v, %v", s, err)
+	}
+	s, err = NewScorer(config.SpamScoring{Engine: "rspamd", Host: "localhost:11333"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.(*Rspamd); !ok {
+		t.Fatalf("expected an Rspamd scorer, got %T", s)
+	}
+	s, err = NewScorer(config.SpamScoring{Engine: "spamassassin", Host: "localhost:783"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.(*SpamAssassin); !ok {
+		t.Fatalf("expected a SpamAssassin scorer, got %T", s)
+	}
+	_, err = NewScorer(config.SpamScoring{Engine: "spamd"})
+	if err == nil {
+		t.Fatalf("expected an error for an unsupported engine")
+	}
+}
+
+func TestRspamd(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checkv2" || r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Password") != "secret" {
+			http.Error(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != string(testMessage) {
+			http.Error(w, "unexpected message", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{
+			"is_skipped": false,
+			"score": 7.5,
+			"required_score": 15,
+			"action": "add header",
+			"symbols": {
+				"MISSING_MID": {"name": "MISSING_MID", "score": 2.5, "description": "Message id is missing"},
+				"R_SPF_NA": {"name": "R_SPF_NA", "score": 0, "description": "Missing SPF record"},
+				"BAYES_SPAM": {"name": "BAYES_SPAM", "score": 5, "description": "Message probably spam"}
+			}
+		}`)
+	}))
+	defer ts.Close()
+
+	r := &Rspamd{URL: ts.URL, Password: "wrong"}
+	_, err := r.Score(context.Background(), testMessage)
+	if err == nil {
+		t.Fatalf("expected an error with the wrong password")
+	}
+
+	r.Password = "secret"
+	result, err := r.Score(context.Background(), testMessage)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Score != 7.5 || result.Threshold != 15 || result.IsSpam || result.Action != "add header" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	names := []string{}
+	for _, rule := range result.Rules {
+		names = append(names, rule.Name)
+	}
+	expected := []string{"BAYES_SPAM", "MISSING_MID", "R_SPF_NA"}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected rules. expected %v got %v", expected, names)
+	}
+}
+
+const testReport = `Spam detection software, running on the system "localhost",
+has identified this incoming email as possible spam.
+
+Content analysis details:   (6.2 points, 5.0 required)
+
+ pts rule name              description
+---- ---------------------- --------------------------------------------------
+ 1.2 MISSING_MID            Missing Message-Id: header
+ 5.0 URIBL_BLACK            Contains an URL listed in the URIBL blacklist
+                            [URIs: example.com]
+-0.0 NO_RELAYS              Informational: message was not relayed via SMTP
+`
+
+// serveSpamd handles a single REPORT request, responding as spamd would.
+func serveSpamd(l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := textproto.NewReader(bufio.NewReader(conn))
+	line, err := r.ReadLine()
+	if err != nil || line != "REPORT SPAMC/1.5" {
+		fmt.Fprintf(conn, "SPAMD/1.5 76 Bad header line: %s\r\n", line)
+		return
+	}
+	headers, err := r.ReadMIMEHeader()
+	if err != nil {
+		return
+	}
+	length, _ := strconv.Atoi(headers.Get("Content-Length"))
+	msg := make([]byte, length)
+	_, err = io.ReadFull(r.R, msg)
+	if err != nil || string(msg) != string(testMessage) {
+		fmt.Fprint(conn, "SPAMD/1.5 74 EX_NOINPUT\r\n")
+		return
+	}
+	fmt.Fprintf(conn, "SPAMD/1.1 0 EX_OK\r\nContent-length: %d\r\nSpam: True ; 6.2 / 5.0\r\n\r\n%s", len(testReport), testReport)
+}
+
+func TestSpamAssassin(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer l.Close()
+	go serveSpamd(l)
+
+	s := &SpamAssassin{Host: l.Addr().String(), Timeout: DefaultTimeout}
+	result, err := s.Score(context.Background(), testMessage)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Score != 6.2 || result.Threshold != 5 || !result.IsSpam {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	expected := []Rule{
+		{Name: "URIBL_BLACK", Score: 5, Description: "Contains an URL listed in the URIBL blacklist [URIs: example.com]"},
+		{Name: "MISSING_MID", Score: 1.2, Description: "Missing Message-Id: header"},
+		{Name: "NO_RELAYS", Score: 0, Description: "Informational: message was not relayed via SMTP"},
+	}
+	if fmt.Sprint(result.Rules) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected rules. expected %v got %v", expected, result.Rules)
+	}
+}
@@ -0,0 +1,122 @@
+package spam
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpamAssassin scores emails using the REPORT command of spamd.
+type SpamAssassin struct {
+	// Host is the address of spamd, such as localhost:783.
+	Host    string
+	Timeout time.Duration
+}
+
+// spamHeader matches the Spam header of a spamd response, such as
+// "True ; 15.3 / 5.0".
+var spamHeader = regexp.MustCompile(`^(\w+)\s*;\s*(-?[\d.]+)\s*/\s*(-?[\d.]+)$`)
+
+// reportRule matches the first line of a rule in a spamd report, such as
+// " 1.0 MISSING_MID            Missing Message-Id: header".
+var reportRule = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?)\s+(\S+)\s+(.*)$`)
+
+// Score submits the email to spamd.
+func (s *SpamAssassin) Score(ctx context.Context, msg []byte) (*Result, error) {
+	d := &net.Dialer{Timeout: s.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", s.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok && s.Timeout > 0 {
+		deadline, ok = time.Now().Add(s.Timeout), true
+	}
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+	_, err = fmt.Fprintf(conn, "REPORT SPAMC/1.5\r\nContent-length: %d\r\n\r\n", len(msg))
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write(msg)
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.CloseWrite()
+	}
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	status, err := r.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	// The status line looks like "SPAMD/1.1 0 EX_OK"
+	parts := strings.SplitN(status, " ", 3)
+	if len(parts) < 3 || !strings.HasPrefix(parts[0], "SPAMD/") {
+		return nil, fmt.Errorf("unexpected response from spamd: %q", status)
+	}
+	if parts[1] != "0" {
+		return nil, fmt.Errorf("spamd returned an error: %s %s", parts[1], parts[2])
+	}
+	headers, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	m := spamHeader.FindStringSubmatch(strings.TrimSpace(headers.Get("Spam")))
+	if m == nil {
+		return nil, fmt.Errorf("unexpected Spam header from spamd: %q", headers.Get("Spam"))
+	}
+	result := &Result{Engine: "spamassassin", Rules: []Rule{}}
+	result.IsSpam = strings.EqualFold(m[1], "true") || strings.EqualFold(m[1], "yes")
+	result.Score, _ = strconv.ParseFloat(m[2], 64)
+	result.Threshold, _ = strconv.ParseFloat(m[3], 64)
+	report, err := ioutil.ReadAll(r.R)
+	if err != nil {
+		return nil, err
+	}
+	result.Rules = parseReport(string(report))
+	result.sortRules()
+	return result, nil
+}
+
+// parseReport returns the rules listed in the table at the end of a spamd
+// report. Long descriptions are wrapped onto indented lines.
+func parseReport(report string) []Rule {
+	rules := []Rule{}
+	inTable := false
+	for _, line := range strings.Split(report, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !inTable {
+			inTable = strings.HasPrefix(line, "---- ")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := reportRule.FindStringSubmatch(line); m != nil {
+			score, _ := strconv.ParseFloat(m[1], 64)
+			// Informational rules are listed as -0.0
+			if score == 0 {
+				score = 0
+			}
+			rules = append(rules, Rule{Name: m[2], Score: score, Description: strings.TrimSpace(m[3])})
+			continue
+		}
+		if len(rules) > 0 {
+			last := &rules[len(rules)-1]
+			last.Description += " " + strings.TrimSpace(line)
+		}
+	}
+	return rules
+}
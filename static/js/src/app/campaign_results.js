@@ -156,7 +156,7 @@ function deleteCampaign() {
             );
         }
         $('button:contains("OK")').on('click', function () {
-            location.href = '/campaigns'
+            location.href = base_path + '/campaigns'
         })
     })
 }
@@ -204,10 +204,30 @@ function exportAsCSV(scope) {
     exportHTML = $("#exportButton").html()
     var csvScope = null
     var filename = campaign.name + ' - ' + capitalize(scope) + '.csv'
+    // Results are streamed by the server, so large campaigns can be exported.
+    // The API key is sent in a header so that it isn't kept in the URL.
+    if (scope == "results") {
+        $("#exportButton").html('<i class="fa fa-spinner fa-spin"></i>')
+        $.ajax({
+                url: base_path + "/api/campaigns/" + campaign.id + "/export?format=csv",
+                method: "GET",
+                dataType: "text",
+                beforeSend: function (xhr) {
+                    xhr.setRequestHeader('Authorization', 'Bearer ' + user.api_key);
+                }
+            })
+            .success(function (csvString) {
+                saveCSV(csvString, filename)
+            })
+            .error(function () {
+                errorFlash(" Unable to export the results")
+            })
+            .always(function () {
+                $("#exportButton").html(exportHTML)
+            })
+        return
+    }
     switch (scope) {
-        case "results":
-            csvScope = campaign.results
-            break;
         case "events":
             csvScope = campaign.timeline
             break;
@@ -219,6 +239,12 @@ function exportAsCSV(scope) {
     var csvString = Papa.unparse(csvScope, {
         'escapeFormulae': true
     })
+    saveCSV(csvString, filename)
+    $("#exportButton").html(exportHTML)
+}
+
+// Saves the CSV as a file download
+function saveCSV(csvString, filename) {
     var csvData = new Blob([csvString], {
         type: 'text/csv;charset=utf-8;'
     });
@@ -233,7 +259,6 @@ function exportAsCSV(scope) {
         dlLink.click();
         document.body.removeChild(dlLink)
     }
-    $("#exportButton").html(exportHTML)
 }
 
 function replay(event_idx) {
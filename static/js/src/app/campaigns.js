@@ -75,7 +75,7 @@ function launch() {
             );
         }
         $('button:contains("OK")').on('click', function () {
-            window.location = "/campaigns/" + campaign.id.toString()
+            window.location = base_path + "/campaigns/" + campaign.id.toString()
         })
     })
 }
@@ -382,7 +382,7 @@ $(document).ready(function () {
                         escapeHtml(campaign.name),
                         moment(campaign.created_date).format('MMMM Do YYYY, h:mm:ss a'),
                         "<span class=\"label " + label + "\" data-toggle=\"tooltip\" data-placement=\"right\" data-html=\"true\" title=\"" + quickStats + "\">" + campaign.status + "</span>",
-                        "<div class='pull-right'><a class='btn btn-primary' href='/campaigns/" + campaign.id + "' data-toggle='tooltip' data-placement='left' title='View Results'>\
+                        "<div class='pull-right'><a class='btn btn-primary' href='" + base_path + "/campaigns/" + campaign.id + "' data-toggle='tooltip' data-placement='left' title='View Results'>\
                     <i class='fa fa-bar-chart'></i>\
                     </a>\
             <span data-toggle='modal' data-backdrop='static' data-target='#modal'><button class='btn btn-primary' data-toggle='tooltip' data-placement='left' title='Copy Campaign' onclick='copy(" + i + ")'>\
@@ -266,7 +266,7 @@ function generateTimelineChart(campaigns) {
                 point: {
                     events: {
                         click: function (e) {
-                            window.location.href = "/campaigns/" + this.campaign_id
+                            window.location.href = base_path + "/campaigns/" + this.campaign_id
                         }
                     }
                 }
@@ -349,7 +349,7 @@ $(document).ready(function () {
                         campaign.stats.submitted_data,
                         campaign.stats.email_reported,
                         "<span class=\"label " + label + "\" data-toggle=\"tooltip\" data-placement=\"right\" data-html=\"true\" title=\"" + quickStats + "\">" + campaign.status + "</span>",
-                        "<div class='pull-right'><a class='btn btn-primary' href='/campaigns/" + campaign.id + "' data-toggle='tooltip' data-placement='left' title='View Results'>\
+                        "<div class='pull-right'><a class='btn btn-primary' href='" + base_path + "/campaigns/" + campaign.id + "' data-toggle='tooltip' data-placement='left' title='View Results'>\
                     <i class='fa fa-bar-chart'></i>\
                     </a>\
                     <button class='btn btn-danger' onclick='deleteCampaign(" + i + ")' data-toggle='tooltip' data-placement='left' title='Delete Campaign'>\
@@ -37,7 +37,7 @@ function modalError(message) {
 
 function query(endpoint, method, data, async) {
     return $.ajax({
-        url: "/api" + endpoint,
+        url: base_path + "/api" + endpoint,
         async: async,
         method: method,
         data: JSON.stringify(data),
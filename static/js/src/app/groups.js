@@ -87,7 +87,7 @@ function edit(id) {
     }
     // Handle file uploads
     $("#csvupload").fileupload({
-        url: "/api/import/group",
+        url: base_path + "/api/import/group",
         dataType: "json",
         beforeSend: function (xhr) {
             xhr.setRequestHeader('Authorization', 'Bearer ' + user.api_key);
@@ -13,7 +13,7 @@ $(document).ready(function () {
         return false
     })
     $("#settingsForm").submit(function (e) {
-        $.post("/settings", $(this).serialize())
+        $.post(base_path + "/settings", $(this).serialize())
             .done(function (data) {
                 successFlash(data.message)
             })
@@ -139,7 +139,7 @@ const impersonate = (id) => {
     }).then((result) => {
         if (result.value) {
 
-         fetch('/impersonate', {
+         fetch(base_path + '/impersonate', {
                 method: 'post',
                 body: "username=" + user.username + "&csrf_token=" + encodeURIComponent(csrf_token),
                 headers: {
@@ -156,7 +156,7 @@ const impersonate = (id) => {
                         allowOutsideClick: false,
                     }).then((result) => {
                         if (result.value) {
-                            window.location.href = "/"
+                            window.location.href = base_path + "/"
                         }});
                 } else {
                     Swal.fire({
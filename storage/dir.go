@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys which would be stored outside of a
+// DirStore's directory.
+var ErrInvalidKey = errors.New("Invalid object key")
+
+// DirStore is a Store which keeps objects as files in a directory on disk.
+// Slashes in keys are treated as subdirectories.
+type DirStore struct {
+	Path string
+}
+
+// NewDirStore returns a DirStore which keeps objects in the given directory.
+// The directory is created when the first object is stored.
+func NewDirStore(path string) *DirStore {
+	return &DirStore{Path: path}
+}
+
+// filename returns the path of the file holding the object with the key.
+func (d *DirStore) filename(key string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	if clean == string(filepath.Separator) || strings.HasSuffix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(d.Path, clean), nil
+}
+
+// Put writes the content to the object's file, creating its directory if
+// needed.
+func (d *DirStore) Put(key string, content []byte, contentType string) error {
+	filename, err := d.filename(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, content, 0600)
+}
+
+// Get opens the object's file.
+func (d *DirStore) Get(key string) (io.ReadCloser, error) {
+	filename, err := d.filename(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the object's file.
+func (d *DirStore) Delete(key string) error {
+	filename, err := d.filename(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gophish/gophish/config"
+	"github.com/gophish/gophish/sigv4"
+)
+
+// DefaultTimeout is how long to wait for object storage to respond. Object
+// content is streamed, so reading it isn't subject to the timeout.
+const DefaultTimeout = 30 * time.Second
+
+const s3Service = "s3"
+
+// S3Store stores objects in an S3 compatible bucket. Requests are signed
+// using AWS Signature Version 4.
+type S3Store struct {
+	Bucket          string
+	Region          string
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Prefix          string
+	pathStyle       bool
+	client          *http.Client
+	now             func() time.Time
+}
+
+// NewS3Store returns a new S3Store, or nil if object storage isn't
+// configured. Credentials which aren't set in the config are read from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment
+// variables.
+func NewS3Store(c config.ObjectStorage) (*S3Store, error) {
+	if c.Bucket == "" {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = DefaultTimeout
+	s := &S3Store{
+		Bucket:          c.Bucket,
+		Region:          c.Region,
+		Endpoint:        strings.TrimRight(c.Endpoint, "/"),
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		Prefix:          c.Prefix,
+		client:          &http.Client{Transport: transport},
+		now:             time.Now,
+	}
+	if s.AccessKeyID == "" && s.SecretAccessKey == "" {
+		s.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, fmt.Errorf("object storage requires an access key id and secret access key")
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.Bucket, s.Region)
+	} else {
+		s.pathStyle = true
+	}
+	return s, nil
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// objectURL returns the URL of the object with the given key.
+func (s *S3Store) objectURL(key string) string {
+	segments := strings.Split(s.Prefix+key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	path := strings.Join(segments, "/")
+	if s.pathStyle {
+		return fmt.Sprintf("%s/%s/%s", s.Endpoint, url.PathEscape(s.Bucket), path)
+	}
+	return fmt.Sprintf("%s/%s", s.Endpoint, path)
+}
+
+// do sends a signed request for the object, returning an error if the
+// response isn't successful.
+func (s *S3Store) do(method, key string, payload []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(key), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", sigv4.HashPayload(payload))
+	sigv4.Sign(req, payload, s.now(), s.Region, s3Service, s.AccessKeyID, s.SecretAccessKey, s.SessionToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	se := s3Error{}
+	xml.NewDecoder(resp.Body).Decode(&se)
+	return nil, fmt.Errorf("unexpected status code from object storage: %d %s %s", resp.StatusCode, se.Code, se.Message)
+}
+
+// Put uploads the content to the bucket.
+func (s *S3Store) Put(key string, content []byte, contentType string) error {
+	resp, err := s.do("PUT", key, content, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get returns a reader streaming the object from the bucket.
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object from the bucket.
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do("DELETE", key, nil, "")
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
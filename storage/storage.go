@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// ErrNotFound is returned when an object doesn't exist.
+var ErrNotFound = errors.New("Object not found")
+
+// Store stores objects by key.
+type Store interface {
+	// Put stores the content, replacing any existing object with the key.
+	Put(key string, content []byte, contentType string) error
+	// Get returns a reader for the object's content, which must be closed.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the object. Deleting an object which doesn't exist
+	// isn't an error.
+	Delete(key string) error
+}
+
+// MemoryStore is a Store which keeps objects in memory. It's used when
+// testing.
+type MemoryStore struct {
+	mu      sync.Mutex
+	Objects map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{Objects: map[string][]byte{}}
+}
+
+// Put stores a copy of the content.
+func (m *MemoryStore) Put(key string, content []byte, contentType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Objects[key] = append([]byte{}, content...)
+	return nil
+}
+
+// Get returns the content stored with the key.
+func (m *MemoryStore) Get(key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.Objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Delete removes the content stored with the key.
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Objects, key)
+	return nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+)
+
+// fakeS3 is a minimal S3 compatible server using path style requests.
+func fakeS3(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string]string{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			b, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = string(b)
+		case "GET":
+			content, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(content))
+		case "DELETE":
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestS3Store(t *testing.T) {
+	ts := fakeS3(t)
+	defer ts.Close()
+	s, err := NewS3Store(config.ObjectStorage{
+		Bucket:          "gophish",
+		Region:          "us-east-1",
+		Endpoint:        ts.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		Prefix:          "prod/",
+	})
+	if err != nil {
+		t.Fatalf("error creating store: %v", err)
+	}
+	if got := s.objectURL("static/logo image.png"); got != ts.URL+"/gophish/prod/static/logo%20image.png" {
+		t.Fatalf("unexpected object url: %s", got)
+	}
+	err = s.Put("attachments/abc", []byte("content"), "text/plain")
+	if err != nil {
+		t.Fatalf("error putting object: %v", err)
+	}
+	r, err := s.Get("attachments/abc")
+	if err != nil {
+		t.Fatalf("error getting object: %v", err)
+	}
+	b, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(b) != "content" {
+		t.Fatalf("unexpected object content: %s", b)
+	}
+	err = s.Delete("attachments/abc")
+	if err != nil {
+		t.Fatalf("error deleting object: %v", err)
+	}
+	_, err = s.Get("attachments/abc")
+	if err != ErrNotFound {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	s.SecretAccessKey = ""
+	s.AccessKeyID = "invalid"
+	err = s.Put("attachments/abc", []byte("content"), "")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("expected access denied error, got %v", err)
+	}
+}
+
+func TestNewS3Store(t *testing.T) {
+	s, err := NewS3Store(config.ObjectStorage{})
+	if s != nil || err != nil {
+		t.Fatalf("expected no store without a bucket, got %v, %v", s, err)
+	}
+	s, err = NewS3Store(config.ObjectStorage{Bucket: "gophish", Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("error creating store: %v", err)
+	}
+	if got := s.objectURL("key"); got != "https://gophish.s3.eu-west-1.amazonaws.com/key" {
+		t.Fatalf("unexpected object url: %s", got)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gophish-storage")
+	if err != nil {
+		t.Fatalf("error creating directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	s := NewDirStore(filepath.Join(dir, "objects"))
+	err = s.Put("simulated/campaign-1/a.eml", []byte("content"), "message/rfc822")
+	if err != nil {
+		t.Fatalf("error putting object: %v", err)
+	}
+	r, err := s.Get("simulated/campaign-1/a.eml")
+	if err != nil {
+		t.Fatalf("error getting object: %v", err)
+	}
+	b, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(b) != "content" {
+		t.Fatalf("unexpected object content: %s", b)
+	}
+	err = s.Delete("simulated/campaign-1/a.eml")
+	if err != nil {
+		t.Fatalf("error deleting object: %v", err)
+	}
+	_, err = s.Get("simulated/campaign-1/a.eml")
+	if err != ErrNotFound {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+	err = s.Delete("simulated/campaign-1/a.eml")
+	if err != nil {
+		t.Fatalf("unexpected error deleting a missing object: %v", err)
+	}
+
+	// Keys can't refer to files outside of the directory
+	err = s.Put("../escaped", []byte("content"), "")
+	if err != nil {
+		t.Fatalf("error putting object: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("expected the object not to be written outside of the directory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "objects", "escaped")); err != nil {
+		t.Fatalf("expected the object to be written in the directory: %v", err)
+	}
+	err = s.Put("/", []byte("content"), "")
+	if err != ErrInvalidKey {
+		t.Fatalf("expected %v, got %v", ErrInvalidKey, err)
+	}
+}
@@ -0,0 +1,304 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophish/gophish/config"
+	log "github.com/gophish/gophish/logger"
+)
+
+// DefaultServiceName identifies Gophish in the traces if no service name is
+// configured.
+const DefaultServiceName = "gophish"
+
+// DefaultTimeout is how long exporting a batch of spans can take.
+const DefaultTimeout = 10 * time.Second
+
+// ExportInterval is how often the spans which have ended are exported.
+var ExportInterval = 5 * time.Second
+
+const (
+	// MaxQueueSize is the most spans waiting to be exported. Spans over the
+	// limit are dropped, so that an unreachable collector doesn't use up
+	// memory while a large campaign is sent.
+	MaxQueueSize = 8192
+	// MaxBatchSize is the most spans exported in a single request.
+	MaxBatchSize = 512
+)
+
+// instrumentationScope is the name of the instrumentation given in the
+// traces.
+const instrumentationScope = "github.com/gophish/gophish"
+
+// Exporter sends spans to an OpenTelemetry collector using OTLP over HTTP,
+// encoded as JSON.
+type Exporter struct {
+	URL         string
+	Headers     http.Header
+	ServiceName string
+	Client      *http.Client
+
+	mu      sync.Mutex
+	spans   []*Span
+	dropped int
+}
+
+// NewExporter returns an exporter for the configured collector, or nil if
+// tracing isn't configured.
+func NewExporter(c config.Tracing) (*Exporter, error) {
+	if c.Endpoint == "" {
+		return nil, nil
+	}
+	headers := http.Header{}
+	for _, header := range c.Headers {
+		kv := strings.SplitN(header, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid tracing header %q: expected name=value", kv[0])
+		}
+		headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	name := c.ServiceName
+	if name == "" {
+		name = DefaultServiceName
+	}
+	return &Exporter{
+		URL:         strings.TrimSuffix(c.Endpoint, "/") + "/v1/traces",
+		Headers:     headers,
+		ServiceName: name,
+		Client:      &http.Client{Timeout: DefaultTimeout},
+	}, nil
+}
+
+// queue adds the span to those waiting to be exported.
+func (e *Exporter) queue(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) >= MaxQueueSize {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, s)
+}
+
+// Run exports the spans which have ended every ExportInterval until the
+// context is cancelled, then exports the remaining spans.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(ExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+			defer cancel()
+			err := e.Flush(fctx)
+			if err != nil {
+				log.Warnf("error exporting traces: %v", err)
+			}
+			return
+		case <-ticker.C:
+			err := e.Flush(ctx)
+			if err != nil {
+				log.Warnf("error exporting traces: %v", err)
+			}
+		}
+	}
+}
+
+// Flush exports the spans which have ended, in batches of up to
+// MaxBatchSize spans. The spans of a batch which can't be exported are
+// dropped.
+func (e *Exporter) Flush(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		n := len(e.spans)
+		if n > MaxBatchSize {
+			n = MaxBatchSize
+		}
+		batch := e.spans[:n]
+		e.spans = append([]*Span{}, e.spans[n:]...)
+		dropped := e.dropped
+		e.dropped = 0
+		e.mu.Unlock()
+		if dropped > 0 {
+			log.Warnf("dropped %d spans since the export queue was full", dropped)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		err := e.export(ctx, batch)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+// The status codes of spans, as defined by OTLP
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue holds one of the types of attribute values. Integers are
+// encoded as strings, since they're 64 bits.
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// newValue returns the OTLP value of an attribute.
+func newValue(value interface{}) otlpValue {
+	var i int64
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case int:
+		i = int64(v)
+	case int64:
+		i = v
+	case int32:
+		i = int64(v)
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+	s := strconv.FormatInt(i, 10)
+	return otlpValue{IntValue: &s}
+}
+
+// otlp returns the span as encoded by OTLP.
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: statusUnset},
+	}
+	if s.parentID != [8]byte{} {
+		o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	keys := make([]string, 0, len(s.attributes))
+	for k := range s.attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		o.Attributes = append(o.Attributes, otlpKeyValue{Key: k, Value: newValue(s.attributes[k])})
+	}
+	if s.err != nil {
+		o.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+	}
+	return o
+}
+
+// export sends a batch of spans to the collector.
+func (e *Exporter) export(ctx context.Context, batch []*Span) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = s.otlp()
+	}
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: []otlpKeyValue{
+				{Key: "service.name", Value: newValue(e.ServiceName)},
+			}},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScope, Version: config.Version},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for name, values := range e.Headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of spans, as defined by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// TraceparentHeader is the W3C Trace Context header used to continue traces
+// started by other services.
+const TraceparentHeader = "traceparent"
+
+var (
+	mu       sync.RWMutex
+	exporter *Exporter
+)
+
+// SetExporter sets the exporter which spans are sent to once they end. If
+// the exporter is nil, no spans are recorded.
+func SetExporter(e *Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	exporter = e
+}
+
+func currentExporter() *Exporter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return exporter
+}
+
+// Span is an operation within a trace, such as sending an email. Its
+// methods can be called on a nil span, which is returned when tracing is
+// disabled.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	exporter *Exporter
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+}
+
+type spanKey struct{}
+
+// FromContext returns the span held by the context, if any.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start starts a span as a child of the span held by the context, or as a new
+// trace if there's none. The returned context holds the span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, KindInternal)
+}
+
+func start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		exporter:   e,
+		attributes: map[string]interface{}{},
+	}
+	if parent := FromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute sets an attribute of the span, such as the ID of the campaign
+// it's for. Values are strings, integers, floats or booleans.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed if the error isn't nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End ends the span, queueing it to be exported. Spans are only exported
+// once.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.exporter.queue(s)
+}
+
+// TraceID returns the ID of the span's trace, as a hex string.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// parseTraceparent returns the trace ID and the ID of the parent span given
+// in a traceparent header, such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(header string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return traceID, parentID, false
+	}
+	if traceID == [16]byte{} || parentID == [8]byte{} {
+		return traceID, parentID, false
+	}
+	return traceID, parentID, true
+}
+
+// Middleware starts a span for each request handled by the server, which
+// is the parent of the spans started while handling it. Traces started by a
+// load balancer or another service are continued using the traceparent
+// header.
+func Middleware(server string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := start(r.Context(), server+" "+r.Method, KindServer)
+			if span == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			defer span.End()
+			if traceID, parentID, ok := parseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+				span.traceID = traceID
+				span.parentID = parentID
+			}
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.target", r.URL.Path)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophish/gophish/config"
+)
+
+// collect starts a collector which sends the requests it receives to the
+// returned channels, and sets an exporter for it. The returned function stops
+// the collector.
+func collect(t *testing.T) (*Exporter, chan *http.Request, chan otlpRequest, func()) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan otlpRequest, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := otlpRequest{}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("error decoding export: %v", err)
+		}
+		requests <- r
+		bodies <- body
+	}))
+	e, err := NewExporter(config.Tracing{Endpoint: ts.URL + "/", Headers: []string{"x-api-key=secret"}})
+	if err != nil {
+		t.Fatalf("error creating exporter: %v", err)
+	}
+	SetExporter(e)
+	return e, requests, bodies, func() {
+		SetExporter(nil)
+		ts.Close()
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	SetExporter(nil)
+	ctx, span := Start(context.Background(), "campaign.launch")
+	if span != nil || FromContext(ctx) != nil {
+		t.Fatalf("expected no span when tracing is disabled")
+	}
+	span.SetAttribute("campaign_id", 1)
+	span.SetError(errors.New("failed"))
+	span.End()
+	e, err := NewExporter(config.Tracing{})
+	if e != nil || err != nil {
+		t.Fatalf("expected no exporter without an endpoint. got %v %v", e, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	e, requests, bodies, stop := collect(t)
+	defer stop()
+	ctx, parent := Start(context.Background(), "mailer.send_batch")
+	parent.SetAttribute("emails", 42)
+	_, child := Start(ctx, "smtp.send")
+	child.SetError(errors.New("connection reset"))
+	child.End()
+	parent.End()
+	// Spans are only exported once
+	parent.End()
+
+	err := e.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("error exporting spans: %v", err)
+	}
+	r := <-requests
+	if r.URL.Path != "/v1/traces" || r.Header.Get("x-api-key") != "secret" {
+		t.Fatalf("unexpected export request %s %v", r.URL.Path, r.Header)
+	}
+	body := <-bodies
+	rs := body.ResourceSpans[0]
+	if *rs.Resource.Attributes[0].Value.StringValue != DefaultServiceName {
+		t.Fatalf("unexpected resource %#v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("unexpected number of spans. expected 2 got %d", len(spans))
+	}
+	sent, batch := spans[0], spans[1]
+	switch {
+	case sent.Name != "smtp.send" || batch.Name != "mailer.send_batch":
+		t.Fatalf("unexpected spans %s %s", sent.Name, batch.Name)
+	case sent.TraceID != batch.TraceID || sent.ParentSpanID != batch.SpanID || batch.ParentSpanID != "":
+		t.Fatalf("unexpected span relationship %#v %#v", sent, batch)
+	case sent.Status.Code != statusError || sent.Status.Message != "connection reset":
+		t.Fatalf("unexpected status %#v", sent.Status)
+	case len(batch.Attributes) != 1 || *batch.Attributes[0].Value.IntValue != "42":
+		t.Fatalf("unexpected attributes %#v", batch.Attributes)
+	}
+
+	// Nothing is exported if no spans have ended
+	err = e.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("error exporting spans: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("unexpected export without spans")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	e, _, bodies, stop := collect(t)
+	defer stop()
+	var traceID string
+	handler := Middleware("phish")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "event.record")
+		traceID = span.TraceID()
+		span.End()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/track", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace not continued. got trace id %s", traceID)
+	}
+	err := e.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("error exporting spans: %v", err)
+	}
+	spans := (<-bodies).ResourceSpans[0].ScopeSpans[0].Spans
+	server := spans[1]
+	if server.Name != "phish GET" || server.Kind != KindServer || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected server span %#v", server)
+	}
+}
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/gophish/gophish/logger"
+)
+
+// CertificateWatchInterval is how often certificate files are checked for
+// changes.
+var CertificateWatchInterval = 30 * time.Second
+
+// Certificate is a TLS key pair which can be reloaded from disk while the
+// server using it is running, so that renewed certificates are picked up
+// without a restart.
+type Certificate struct {
+	certPath string
+	keyPath  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	done     chan struct{}
+	once     sync.Once
+}
+
+// LoadCertificate loads the key pair from the given paths.
+func LoadCertificate(certPath, keyPath string) (*Certificate, error) {
+	c := &Certificate{
+		certPath: certPath,
+		keyPath:  keyPath,
+		done:     make(chan struct{}),
+	}
+	err := c.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// lastModified returns the latest modification time of the certificate and
+// key files.
+func (c *Certificate) lastModified() time.Time {
+	var latest time.Time
+	for _, path := range []string{c.certPath, c.keyPath} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// Reload loads the key pair from disk. If the new key pair is invalid, such
+// as when the certificate has been renewed but the key hasn't been written
+// yet, the current key pair is kept and an error is returned.
+func (c *Certificate) Reload() error {
+	modTime := c.lastModified()
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// GetCertificate returns the current key pair. It's used as the
+// GetCertificate function of a tls.Config.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// Watch periodically checks the certificate and key files, reloading them
+// when they change, until the certificate is closed.
+func (c *Certificate) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.RLock()
+			changed := c.lastModified().After(c.modTime)
+			c.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			err := c.Reload()
+			if err != nil {
+				log.Errorf("error reloading certificate %s: %v", c.certPath, err)
+				continue
+			}
+			log.Infof("Reloaded certificate %s", c.certPath)
+		}
+	}
+}
+
+// Close stops watching the certificate files.
+func (c *Certificate) Close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
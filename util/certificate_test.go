@@ -0,0 +1,61 @@
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificateReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gophish-certificate")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certPath := filepath.Join(dir, "gophish.crt")
+	keyPath := filepath.Join(dir, "gophish.key")
+	err = CheckAndCreateSSL(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	c, err := LoadCertificate(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("error loading certificate: %v", err)
+	}
+	defer c.Close()
+	original, _ := c.GetCertificate(nil)
+
+	// A renewal which has only written the certificate so far keeps the
+	// current key pair
+	os.Remove(certPath)
+	os.Remove(keyPath)
+	CheckAndCreateSSL(certPath, keyPath)
+	renewedKey, _ := ioutil.ReadFile(keyPath)
+	ioutil.WriteFile(keyPath, []byte("partial"), 0600)
+	if err = c.Reload(); err == nil {
+		t.Fatalf("expected error reloading a mismatched key pair")
+	}
+	current, _ := c.GetCertificate(nil)
+	if current != original {
+		t.Fatalf("certificate changed after a failed reload")
+	}
+
+	// Once the renewal is complete, the watcher picks up the new key pair
+	ioutil.WriteFile(keyPath, renewedKey, 0600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(keyPath, future, future)
+	go c.Watch(10 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		current, _ = c.GetCertificate(nil)
+		if current != original {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if bytes.Equal(current.Certificate[0], original.Certificate[0]) {
+		t.Fatalf("certificate wasn't reloaded after the files changed")
+	}
+}
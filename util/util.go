@@ -16,6 +16,7 @@ import (
 	"net/mail"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
@@ -24,10 +25,15 @@ import (
 )
 
 var (
-	firstNameRegex = regexp.MustCompile(`(?i)first[\s_-]*name`)
-	lastNameRegex  = regexp.MustCompile(`(?i)last[\s_-]*name`)
-	emailRegex     = regexp.MustCompile(`(?i)email`)
-	positionRegex  = regexp.MustCompile(`(?i)position`)
+	firstNameRegex  = regexp.MustCompile(`(?i)first[\s_-]*name`)
+	lastNameRegex   = regexp.MustCompile(`(?i)last[\s_-]*name`)
+	emailRegex      = regexp.MustCompile(`(?i)email`)
+	positionRegex   = regexp.MustCompile(`(?i)position`)
+	departmentRegex = regexp.MustCompile(`(?i)department`)
+	managerRegex    = regexp.MustCompile(`(?i)manager`)
+	languageRegex   = regexp.MustCompile(`(?i)language`)
+	statusRegex     = regexp.MustCompile(`(?i)status`)
+	tagsRegex       = regexp.MustCompile(`(?i)^tags?$`)
 )
 
 // ParseMail takes in an HTTP Request and returns an Email object
@@ -70,10 +76,16 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 		li := -1
 		ei := -1
 		pi := -1
+		lgi := -1
+		si := -1
+		ti := -1
 		fn := ""
 		ln := ""
 		ea := ""
 		ps := ""
+		lg := ""
+		st := ""
+		tg := ""
 		for i, v := range record {
 			switch {
 			case firstNameRegex.MatchString(v):
@@ -84,6 +96,12 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 				ei = i
 			case positionRegex.MatchString(v):
 				pi = i
+			case languageRegex.MatchString(v):
+				lgi = i
+			case statusRegex.MatchString(v):
+				si = i
+			case tagsRegex.MatchString(v):
+				ti = i
 			}
 		}
 		if fi == -1 && li == -1 && ei == -1 && pi == -1 {
@@ -110,13 +128,25 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 			if pi != -1 && len(record) > pi {
 				ps = record[pi]
 			}
+			if lgi != -1 && len(record) > lgi {
+				lg = record[lgi]
+			}
+			if si != -1 && len(record) > si {
+				st = strings.ToLower(strings.TrimSpace(record[si]))
+			}
+			if ti != -1 && len(record) > ti {
+				tg = record[ti]
+			}
 			t := models.Target{
 				BaseRecipient: models.BaseRecipient{
 					FirstName: fn,
 					LastName:  ln,
 					Email:     ea,
 					Position:  ps,
+					Language:  lg,
 				},
+				Status: st,
+				Tags:   tg,
 			}
 			ts = append(ts, t)
 		}
@@ -124,6 +154,76 @@ func ParseCSV(r *http.Request) ([]models.Target, error) {
 	return ts, nil
 }
 
+// ParseOrgCSV parses the user provided csv file containing an org chart, with
+// the email address, department and manager's email address of each member
+func ParseOrgCSV(r *http.Request) ([]models.OrgMember, error) {
+	mr, err := r.MultipartReader()
+	ms := []models.OrgMember{}
+	if err != nil {
+		return ms, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		// Skip the "submit" part
+		if part.FileName() == "" {
+			continue
+		}
+		defer part.Close()
+		reader := csv.NewReader(part)
+		reader.TrimLeadingSpace = true
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		ei := -1
+		di := -1
+		mi := -1
+		for i, v := range record {
+			switch {
+			// The manager's email column also matches the email regex
+			case managerRegex.MatchString(v):
+				mi = i
+			case emailRegex.MatchString(v):
+				ei = i
+			case departmentRegex.MatchString(v):
+				di = i
+			}
+		}
+		if ei == -1 {
+			continue
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			m := models.OrgMember{}
+			if len(record) <= ei {
+				continue
+			}
+			csvEmail, err := mail.ParseAddress(record[ei])
+			if err != nil {
+				continue
+			}
+			m.Email = csvEmail.Address
+			if di != -1 && len(record) > di {
+				m.Department = record[di]
+			}
+			if mi != -1 && len(record) > mi && record[mi] != "" {
+				managerEmail, err := mail.ParseAddress(record[mi])
+				if err == nil {
+					m.ManagerEmail = managerEmail.Address
+				}
+			}
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
 // CheckAndCreateSSL is a helper to setup self-signed certificates for the administrative interface.
 func CheckAndCreateSSL(cp string, kp string) error {
 	// Check whether there is an existing SSL certificate and/or key, and if so, abort execution of this function
@@ -60,3 +60,34 @@ func TestParseCSVEmail(t *testing.T) {
 		t.Fatalf("Incorrect targets received. Expected: %#v\nGot: %#v", expected, got)
 	}
 }
+
+func TestParseOrgCSV(t *testing.T) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("files[]", "org.csv")
+	if err != nil {
+		t.Fatalf("error creating form file: %v", err)
+	}
+	part.Write([]byte("Email,Department,Manager Email\n"))
+	part.Write([]byte("jane@example.com,Engineering,<john@example.com>\n"))
+	part.Write([]byte("john@example.com,Engineering,\n"))
+	part.Write([]byte("invalid,Sales,john@example.com\n"))
+	writer.Close()
+	r, err := http.NewRequest("POST", "http://127.0.0.1", body)
+	if err != nil {
+		t.Fatalf("error building CSV request: %v", err)
+	}
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	got, err := ParseOrgCSV(r)
+	if err != nil {
+		t.Fatalf("error parsing CSV: %v", err)
+	}
+	expected := []models.OrgMember{
+		{Email: "jane@example.com", Department: "Engineering", ManagerEmail: "john@example.com"},
+		{Email: "john@example.com", Department: "Engineering"},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("Incorrect org chart members received. Expected: %#v\nGot: %#v", expected, got)
+	}
+}
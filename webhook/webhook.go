@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -9,9 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/schema"
 )
 
 const (
@@ -31,6 +35,14 @@ const (
 	// Sha256Prefix is the prefix that specifies the hashing algorithm used
 	// for the signature
 	Sha256Prefix = "sha256"
+
+	// KeyIDPrefix is the prefix that specifies the ID of the key used for a
+	// signature
+	KeyIDPrefix = "keyid"
+
+	// RequestIDHeader is the name of the HTTP header which contains the ID
+	// of the request which caused the webhook to be sent, if any
+	RequestIDHeader = "X-Request-ID"
 )
 
 // Sender represents a type which can send webhooks to an EndPoint
@@ -57,61 +69,195 @@ func SetTransport(tr *http.Transport) {
 }
 
 // EndPoint represents a URL to send the webhook to, as well as a secret used
-// to sign the event
+// to sign the event and the schema version of the payloads it expects.
+//
+// If the endpoint has keys, the event is signed with each of them instead of
+// the secret, so that receivers can rotate secrets without rejecting events.
 type EndPoint struct {
-	URL    string
+	URL           string
+	Secret        string
+	Keys          []Key
+	SchemaVersion int
+}
+
+// Key is a secret used to sign events, along with the ID that receivers use
+// to look it up.
+type Key struct {
+	ID     string
 	Secret string
 }
 
+// Envelope wraps the payloads sent to endpoints using schema version 2 or
+// later.
+type Envelope struct {
+	SchemaVersion int         `json:"schema_version"`
+	Type          string      `json:"type"`
+	Data          interface{} `json:"data"`
+}
+
+// Typed is implemented by payloads to set the type given in their envelope.
+// Payloads which don't implement it have the type "event".
+type Typed interface {
+	WebhookType() string
+}
+
+// payload returns the payload to send to an endpoint using the given schema
+// version.
+func payload(version int, data interface{}) interface{} {
+	converted := schema.Convert(data, version)
+	if version < 2 || converted == nil {
+		return converted
+	}
+	t := "event"
+	if td, ok := data.(Typed); ok {
+		t = td.WebhookType()
+	}
+	return Envelope{SchemaVersion: version, Type: t, Data: converted}
+}
+
 // Send sends data to a single EndPoint
 func Send(endPoint EndPoint, data interface{}) error {
 	return senderInstance.Send(endPoint, data)
 }
 
+// SendContext sends data to a single EndPoint along with the ID of the
+// request held by the context, which is also logged with any errors.
+func SendContext(ctx context.Context, endPoint EndPoint, data interface{}) error {
+	return senderInstance.send(ctx, endPoint, data)
+}
+
 // SendAll sends data to multiple EndPoints
 func SendAll(endPoints []EndPoint, data interface{}) {
+	SendAllContext(context.Background(), endPoints, data)
+}
+
+// SendAllContext sends data to multiple EndPoints along with the ID of the
+// request held by the context. Since the data is sent in the background, the
+// context shouldn't be canceled once the request is handled.
+func SendAllContext(ctx context.Context, endPoints []EndPoint, data interface{}) {
 	for _, e := range endPoints {
 		go func(e EndPoint) {
-			senderInstance.Send(e, data)
+			senderInstance.send(ctx, e, data)
 		}(e)
 	}
 }
 
 // Send contains the implementation of sending webhook to an EndPoint
 func (ds defaultSender) Send(endPoint EndPoint, data interface{}) error {
-	jsonData, err := json.Marshal(data)
+	return ds.send(context.Background(), endPoint, data)
+}
+
+// send sends the webhook, canceling the request if the context is canceled.
+func (ds defaultSender) send(ctx context.Context, endPoint EndPoint, data interface{}) error {
+	logger := log.FromContext(ctx)
+	version := endPoint.SchemaVersion
+	if version < schema.Oldest {
+		version = schema.Oldest
+	}
+	p := payload(version, data)
+	// The payload doesn't exist in older versions, so there's nothing to send
+	if p == nil {
+		return nil
+	}
+	jsonData, err := json.Marshal(p)
 	if err != nil {
-		log.Error(err)
+		logger.Error(err)
 		return err
 	}
 
 	req, err := http.NewRequest("POST", endPoint.URL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Error(err)
+		logger.Error(err)
 		return err
 	}
-	signat, err := sign(endPoint.Secret, jsonData)
+	req = req.WithContext(ctx)
+	signature, err := Signature(endPoint, jsonData)
 	if err != nil {
-		log.Error(err)
+		logger.Error(err)
 		return err
 	}
-	req.Header.Set(SignatureHeader, fmt.Sprintf("%s=%s", Sha256Prefix, signat))
+	req.Header.Set(SignatureHeader, signature)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(schema.Header, strconv.Itoa(version))
+	if id := log.RequestID(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
 	resp, err := ds.client.Do(req)
 	if err != nil {
-		log.Error(err)
+		logger.Error(err)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= MinHTTPStatusErrorCode {
 		errMsg := fmt.Sprintf("http status of response: %s", resp.Status)
-		log.Error(errMsg)
+		logger.Error(errMsg)
 		return errors.New(errMsg)
 	}
 	return nil
 }
 
+// Signature returns the value of the signature header for data sent to the
+// endpoint.
+//
+// Endpoints without keys have a single signature, "sha256=<signature>".
+// Otherwise, there's a signature for each key, separated by commas, such as
+// "keyid=<id>;sha256=<signature>, keyid=<id>;sha256=<signature>".
+func Signature(endPoint EndPoint, data []byte) (string, error) {
+	if len(endPoint.Keys) == 0 {
+		signature, err := sign(endPoint.Secret, data)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s=%s", Sha256Prefix, signature), nil
+	}
+	signatures := make([]string, len(endPoint.Keys))
+	for i, k := range endPoint.Keys {
+		signature, err := sign(k.Secret, data)
+		if err != nil {
+			return "", err
+		}
+		signatures[i] = fmt.Sprintf("%s=%s;%s=%s", KeyIDPrefix, k.ID, Sha256Prefix, signature)
+	}
+	return strings.Join(signatures, ", "), nil
+}
+
+// Verify returns whether the signature header is valid for data signed with
+// any of the keys. Signatures without a key ID are checked against each key.
+func Verify(header string, data []byte, keys []Key) bool {
+	for _, entry := range strings.Split(header, ",") {
+		var keyID, signature string
+		for _, param := range strings.Split(entry, ";") {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case KeyIDPrefix:
+				keyID = kv[1]
+			case Sha256Prefix:
+				signature = kv[1]
+			}
+		}
+		if signature == "" {
+			continue
+		}
+		for _, k := range keys {
+			if keyID != "" && keyID != k.ID {
+				continue
+			}
+			expected, err := sign(k.Secret, data)
+			if err != nil {
+				continue
+			}
+			if hmac.Equal([]byte(expected), []byte(signature)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func sign(secret string, data []byte) (string, error) {
 	hash1 := hmac.New(sha256.New, []byte(secret))
 	_, err := hash1.Write(data)
@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -8,7 +9,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+
+	logger "github.com/gophish/gophish/logger"
 )
 
 type mockSender struct {
@@ -101,3 +105,120 @@ func TestSignature(t *testing.T) {
 		t.Fatalf("invalid signature received. expected %s got %s", expected, got)
 	}
 }
+
+func TestSignatureKeys(t *testing.T) {
+	payload := []byte("some payload456")
+	old := Key{ID: "old", Secret: "secret123"}
+	current := Key{ID: "current", Secret: "secret456"}
+
+	// Endpoints without keys keep the original format
+	legacy, err := Signature(EndPoint{Secret: old.Secret}, payload)
+	if err != nil {
+		t.Fatalf("error signing payload: %v", err)
+	}
+	expected := "sha256=ab7844c1e9149f8dc976c4188a72163c005930f3c2266a163ffe434230bdf761"
+	if legacy != expected {
+		t.Fatalf("invalid signature received. expected %s got %s", expected, legacy)
+	}
+
+	header, err := Signature(EndPoint{Keys: []Key{current, old}}, payload)
+	if err != nil {
+		t.Fatalf("error signing payload: %v", err)
+	}
+	if !strings.HasPrefix(header, "keyid=current;sha256=") || !strings.HasSuffix(header, ", keyid=old;sha256=ab7844c1e9149f8dc976c4188a72163c005930f3c2266a163ffe434230bdf761") {
+		t.Fatalf("invalid signature received. got %s", header)
+	}
+
+	tests := []struct {
+		header string
+		keys   []Key
+		valid  bool
+	}{
+		// Receivers which only know the old key, or have already dropped it,
+		// can verify the event
+		{header: header, keys: []Key{old}, valid: true},
+		{header: header, keys: []Key{current}, valid: true},
+		{header: header, keys: []Key{{ID: "other", Secret: old.Secret}}, valid: false},
+		{header: legacy, keys: []Key{old}, valid: true},
+		{header: legacy, keys: []Key{current}, valid: false},
+		{header: "keyid=old;sha256=invalid", keys: []Key{old}, valid: false},
+		{header: "", keys: []Key{old}, valid: false},
+	}
+	for _, tc := range tests {
+		if got := Verify(tc.header, payload, tc.keys); got != tc.valid {
+			t.Fatalf("unexpected result verifying %q with %v. expected %v got %v", tc.header, tc.keys, tc.valid, got)
+		}
+	}
+}
+
+type versionedPayload struct {
+	Name string `json:"name"`
+	New  string `json:"new"`
+}
+
+func (p versionedPayload) ForSchema(version int) interface{} {
+	if version < 2 {
+		return map[string]string{"name": p.Name}
+	}
+	return p
+}
+
+func (p versionedPayload) WebhookType() string {
+	return "test"
+}
+
+func TestSendVersioned(t *testing.T) {
+	data := versionedPayload{Name: "name", New: "new"}
+	tests := []struct {
+		version int
+		header  string
+		body    string
+	}{
+		{version: 0, header: "1", body: `{"name":"name"}`},
+		{version: 1, header: "1", body: `{"name":"name"}`},
+		{version: 2, header: "2", body: `{"schema_version":2,"type":"test","data":{"name":"name","new":"new"}}`},
+	}
+	for _, tc := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("X-Gophish-Schema-Version"); got != tc.header {
+				t.Errorf("invalid schema version header for version %d. expected %s got %s", tc.version, tc.header, got)
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatalf("error reading JSON body from webhook request: %v", err)
+			}
+			if string(body) != tc.body {
+				t.Errorf("invalid payload for version %d. expected %s got %s", tc.version, tc.body, body)
+			}
+		}))
+		err := Send(EndPoint{URL: ts.URL, Secret: "secret", SchemaVersion: tc.version}, data)
+		ts.Close()
+		if err != nil {
+			t.Fatalf("error sending data to webhook endpoint: %v", err)
+		}
+	}
+}
+
+func TestSendRequestID(t *testing.T) {
+	ids := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get(RequestIDHeader)
+	}))
+	defer ts.Close()
+	endpoint := EndPoint{URL: ts.URL, Secret: "secret"}
+	ctx := logger.WithRequestID(context.Background(), "4c8f0a9e21d7b356")
+	err := SendContext(ctx, endpoint, map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatalf("error sending data to webhook endpoint: %v", err)
+	}
+	if got := <-ids; got != "4c8f0a9e21d7b356" {
+		t.Fatalf("unexpected request id. expected %s got %s", "4c8f0a9e21d7b356", got)
+	}
+	err = Send(endpoint, map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatalf("error sending data to webhook endpoint: %v", err)
+	}
+	if got := <-ids; got != "" {
+		t.Fatalf("unexpected request id without a request. got %s", got)
+	}
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/gophish/gophish/logger"
+	"github.com/gophish/gophish/mailer"
+	"github.com/gophish/gophish/models"
+)
+
+// ElectedWorker is a Worker for running multiple Gophish instances against
+// the same database. Campaign emails are only sent by the instance which has
+// been elected leader, while test emails can be sent from any instance.
+type ElectedWorker struct {
+	mu     sync.Mutex
+	leader Worker
+	mailer mailer.Mailer
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewElectedWorker returns an ElectedWorker which hasn't been elected yet.
+func NewElectedWorker() *ElectedWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ElectedWorker{
+		mailer: mailer.NewMailWorker(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start launches the mailer used for test emails. Campaign emails aren't sent
+// until the worker is promoted.
+func (w *ElectedWorker) Start() {
+	w.mailer.Start(w.ctx)
+}
+
+// Promote starts sending campaign emails once this instance has been elected
+// leader. Maillogs left over by the previous leader are recovered first.
+func (w *ElectedWorker) Promote() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.leader != nil {
+		return nil
+	}
+	err := models.RecoverMailLogs()
+	if err != nil {
+		return err
+	}
+	leader, err := New()
+	if err != nil {
+		return err
+	}
+	w.leader = leader
+	go leader.Start()
+	return nil
+}
+
+// Demote stops sending campaign emails, waiting for the emails currently
+// being sent to finish.
+func (w *ElectedWorker) Demote(ctx context.Context) error {
+	w.mu.Lock()
+	leader := w.leader
+	w.leader = nil
+	w.mu.Unlock()
+	if leader == nil {
+		return nil
+	}
+	return leader.Shutdown(ctx)
+}
+
+// Shutdown stops the worker, including the mailer used for test emails.
+func (w *ElectedWorker) Shutdown(ctx context.Context) error {
+	w.cancel()
+	return w.Demote(ctx)
+}
+
+// LaunchCampaign sends the campaign's emails if this instance is the leader.
+// Otherwise, the leader sends them the next time it checks for queued
+// maillogs.
+func (w *ElectedWorker) LaunchCampaign(c models.Campaign) {
+	w.mu.Lock()
+	leader := w.leader
+	w.mu.Unlock()
+	if leader == nil {
+		log.Infof("Campaign %d will be launched by the leader", c.Id)
+		return
+	}
+	leader.LaunchCampaign(c)
+}
+
+// SendTestEmail sends a test email
+func (w *ElectedWorker) SendTestEmail(s *models.EmailRequest) error {
+	go func() {
+		ms := []mailer.Mail{s}
+		w.mailer.Queue(ms)
+	}()
+	return <-s.ErrorChan
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/gophish/gophish/mailer"
+)
+
+// Stats reports how a worker is sending campaign emails.
+type Stats struct {
+	// Leader is false for an ElectedWorker which hasn't been elected, since
+	// only the leader sends campaign emails.
+	Leader       bool   `json:"leader"`
+	PollInterval string `json:"poll_interval"`
+	BatchSize    int    `json:"batch_size"`
+	// LastPoll is when the worker last checked for queued maillogs, and
+	// LastPollMailLogs is how many it found.
+	LastPoll         time.Time    `json:"last_poll"`
+	LastPollMailLogs int          `json:"last_poll_maillogs"`
+	Mailer           mailer.Stats `json:"mailer"`
+}
+
+// StatsReporter is implemented by workers which report their Stats.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// Stats returns how the worker is sending campaign emails.
+func (w *DefaultWorker) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := Stats{
+		Leader:           true,
+		PollInterval:     PollInterval.String(),
+		BatchSize:        BatchSize,
+		LastPoll:         w.lastPoll,
+		LastPollMailLogs: w.lastPollMailLogs,
+	}
+	if m, ok := w.mailer.(interface{ Stats() mailer.Stats }); ok {
+		s.Mailer = m.Stats()
+	}
+	return s
+}
+
+// Stats returns the leader's stats if this instance is the leader.
+func (w *ElectedWorker) Stats() Stats {
+	w.mu.Lock()
+	leader := w.leader
+	w.mu.Unlock()
+	if sr, ok := leader.(StatsReporter); ok {
+		return sr.Stats()
+	}
+	return Stats{
+		PollInterval: PollInterval.String(),
+		BatchSize:    BatchSize,
+	}
+}
@@ -2,31 +2,72 @@ package worker
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gophish/gophish/config"
 	log "github.com/gophish/gophish/logger"
 	"github.com/gophish/gophish/mailer"
 	"github.com/gophish/gophish/models"
+	"github.com/gophish/gophish/tracing"
 	"github.com/sirupsen/logrus"
 )
 
+// RetentionInterval is how often the worker purges campaign data which has
+// expired according to the configured data retention settings.
+var RetentionInterval = time.Hour
+
+// HealthCheckInterval is how often the worker checks the health of each
+// sending profile.
+var HealthCheckInterval = 15 * time.Minute
+
+// NotificationInterval is how often the worker evaluates notification rules
+// against the new campaign events.
+var NotificationInterval = 10 * time.Second
+
+// DomainCheckInterval is how often the worker checks the domains in the
+// domain inventory.
+var DomainCheckInterval = config.DefaultDomainCheckHours * time.Hour
+
+// PollInterval is how often the worker checks for maillogs which are ready
+// to be sent.
+var PollInterval = config.DefaultWorkerPollSeconds * time.Second
+
+// BatchSize is the most maillogs the worker sends each time it polls, or zero
+// if there's no limit. Maillogs over the limit are sent the next time.
+var BatchSize = 0
+
 // Worker is an interface that defines the operations needed for a background worker
 type Worker interface {
 	Start()
+	Shutdown(ctx context.Context) error
 	LaunchCampaign(c models.Campaign)
 	SendTestEmail(s *models.EmailRequest) error
 }
 
 // DefaultWorker is the background worker that handles watching for new campaigns and sending emails appropriately.
 type DefaultWorker struct {
-	mailer mailer.Mailer
+	mailer  mailer.Mailer
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started int32
+	done    chan struct{}
+
+	mu               sync.Mutex
+	lastPoll         time.Time
+	lastPollMailLogs int
 }
 
 // New creates a new worker object to handle the creation of campaigns
 func New(options ...func(Worker) error) (Worker, error) {
 	defaultMailer := mailer.NewMailWorker()
+	ctx, cancel := context.WithCancel(context.Background())
 	w := &DefaultWorker{
 		mailer: defaultMailer,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
 	}
 	for _, opt := range options {
 		if err := opt(w); err != nil {
@@ -45,79 +86,248 @@ func WithMailer(m mailer.Mailer) func(*DefaultWorker) error {
 	}
 }
 
+// mailContextKey identifies the maillogs which are sent using the same
+// campaign, sending profile and template.
+type mailContextKey struct {
+	campaignId int64
+	smtpId     int64
+	templateId int64
+}
+
 // processCampaigns loads maillogs scheduled to be sent before the provided
 // time and sends them to the mailer.
 func (w *DefaultWorker) processCampaigns(t time.Time) error {
-	ms, err := models.GetQueuedMailLogs(t.UTC())
+	// Each poll is given its own ID, so that the messages logged while
+	// queueing its maillogs can be found together.
+	logger := log.FromContext(log.WithRequestID(context.Background(), log.NewRequestID()))
+	_, span := tracing.Start(context.Background(), "worker.process_maillogs")
+	defer span.End()
+	ms, err := models.GetQueuedMailLogsBatch(t.UTC(), BatchSize)
 	if err != nil {
-		log.Error(err)
+		logger.Error(err)
+		span.SetError(err)
 		return err
 	}
+	span.SetAttribute("maillogs", len(ms))
+	w.mu.Lock()
+	w.lastPoll = t.UTC()
+	w.lastPollMailLogs = len(ms)
+	w.mu.Unlock()
 	// Lock the MailLogs (they will be unlocked after processing)
 	err = models.LockMailLogs(ms, true)
 	if err != nil {
+		span.SetError(err)
 		return err
 	}
-	campaignCache := make(map[int64]models.Campaign)
-	// We'll group the maillogs by campaign ID to (roughly) group
-	// them by sending profile. This lets the mailer re-use the Sender
-	// instead of having to re-connect to the SMTP server for every
-	// email.
-	msg := make(map[int64][]mailer.Mail)
+	campaignCache := make(map[mailContextKey]models.Campaign)
+	// We'll group the maillogs by campaign ID and sending profile. This lets
+	// the mailer re-use the Sender instead of having to re-connect to the
+	// SMTP server for every email.
+	msg := make(map[mailContextKey][]mailer.Mail)
+	paused := make(map[mailContextKey]bool)
+	// The maillogs are loaded starting with the campaigns with the highest
+	// priority, so the groups are queued in the order they're first seen.
+	order := []mailContextKey{}
 	for _, m := range ms {
 		// We cache the campaign here to greatly reduce the time it takes to
 		// generate the message (ref #1726)
-		c, ok := campaignCache[m.CampaignId]
+		key := mailContextKey{campaignId: m.CampaignId, smtpId: m.SMTPId, templateId: m.TemplateId}
+		c, ok := campaignCache[key]
 		if !ok {
-			c, err = models.GetCampaignMailContext(m.CampaignId, m.UserId)
+			c, err = models.GetMailLogContext(m)
 			if err != nil {
+				span.SetError(err)
 				return err
 			}
-			campaignCache[c.Id] = c
+			campaignCache[key] = c
+			paused[key] = models.SendingProfilePaused(c.SMTP.Id)
+		}
+		// Emails for sending profiles whose circuit breaker has tripped stay
+		// queued until the profile recovers.
+		if paused[key] {
+			err = m.Unlock()
+			if err != nil {
+				logger.Error(err)
+			}
+			continue
 		}
 		m.CacheCampaign(&c)
-		msg[m.CampaignId] = append(msg[m.CampaignId], m)
+		if _, ok := msg[key]; !ok {
+			order = append(order, key)
+		}
+		msg[key] = append(msg[key], m)
 	}
 
-	// Next, we process each group of maillogs in parallel
-	for cid, msc := range msg {
-		go func(cid int64, msc []mailer.Mail) {
-			c := campaignCache[cid]
+	// Next, we queue each group of maillogs, which the mailer sends in
+	// parallel. Queueing them in order lets the groups of urgent campaigns
+	// start sending first when the mailer's concurrency is limited.
+	go func() {
+		for _, key := range order {
+			c := campaignCache[key]
+			msc := msg[key]
 			if c.Status == models.CampaignQueued {
 				err := c.UpdateStatus(models.CampaignInProgress)
 				if err != nil {
-					log.Error(err)
-					return
+					logger.Error(err)
+					continue
 				}
 			}
-			log.WithFields(logrus.Fields{
+			logger.WithFields(logrus.Fields{
 				"num_emails": len(msc),
+				"priority":   c.Priority,
 			}).Info("Sending emails to mailer for processing")
 			w.mailer.Queue(msc)
-		}(cid, msc)
-	}
+		}
+	}()
 	return nil
 }
 
-// Start launches the worker to poll the database every minute for any pending maillogs
-// that need to be processed.
+// Start launches the worker to poll the database every PollInterval for any
+// pending maillogs that need to be processed.
 func (w *DefaultWorker) Start() {
 	log.Info("Background Worker Started Successfully - Waiting for Campaigns")
-	go w.mailer.Start(context.Background())
-	for t := range time.Tick(1 * time.Minute) {
-		err := w.processCampaigns(t)
-		if err != nil {
-			log.Error(err)
-			continue
+	atomic.StoreInt32(&w.started, 1)
+	go func() {
+		w.mailer.Start(w.ctx)
+		close(w.done)
+	}()
+	go w.purgeExpiredData()
+	go w.checkSMTPHealth()
+	go w.sendNotifications()
+	go w.checkDomains()
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case t := <-ticker.C:
+			// Campaigns are completed before sending, so that emails aren't
+			// sent for campaigns which have just been completed.
+			err := models.AutoCompleteCampaigns(t)
+			if err != nil {
+				log.Error(err)
+			}
+			err = models.LaunchDueWaves(t)
+			if err != nil {
+				log.Error(err)
+			}
+			err = models.ProcessSequences(t)
+			if err != nil {
+				log.Error(err)
+			}
+			err = w.processCampaigns(t)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+		}
+	}
+}
+
+// Shutdown stops the worker from processing any more maillogs, and waits for
+// the emails currently being sent to finish so that their results are saved.
+// Maillogs which haven't been sent are unlocked so they can be sent once
+// Gophish is started again. If the context expires first, its error is
+// returned.
+func (w *DefaultWorker) Shutdown(ctx context.Context) error {
+	w.cancel()
+	if atomic.LoadInt32(&w.started) == 0 {
+		return nil
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// purgeExpiredData periodically removes campaign data that is older than the
+// configured retention periods.
+func (w *DefaultWorker) purgeExpiredData() {
+	ticker := time.NewTicker(RetentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case t := <-ticker.C:
+			err := models.PurgeExpiredData(t)
+			if err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// checkSMTPHealth periodically checks the health of each sending profile,
+// resuming the emails of profiles which have recovered.
+func (w *DefaultWorker) checkSMTPHealth() {
+	ticker := time.NewTicker(HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			err := models.CheckSMTPHealth()
+			if err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// sendNotifications periodically evaluates the notification rules against
+// the events added since the worker started, and the completed campaigns.
+func (w *DefaultWorker) sendNotifications() {
+	after, err := models.LastEventId()
+	if err != nil {
+		log.Error(err)
+	}
+	ticker := time.NewTicker(NotificationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			after, err = models.EvaluateNotifications(after)
+			if err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// checkDomains periodically checks the domains in the domain inventory,
+// warning of those which have been burned.
+func (w *DefaultWorker) checkDomains() {
+	ticker := time.NewTicker(DomainCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			err := models.CheckDomains(w.ctx)
+			if err != nil {
+				log.Error(err)
+			}
 		}
 	}
 }
 
 // LaunchCampaign starts a campaign
 func (w *DefaultWorker) LaunchCampaign(c models.Campaign) {
+	_, span := tracing.Start(context.Background(), "campaign.launch")
+	defer span.End()
+	span.SetAttribute("campaign_id", c.Id)
 	ms, err := models.GetMailLogsByCampaign(c.Id)
 	if err != nil {
 		log.Error(err)
+		span.SetError(err)
 		return
 	}
 	models.LockMailLogs(ms, true)
@@ -125,11 +335,9 @@ func (w *DefaultWorker) LaunchCampaign(c models.Campaign) {
 	// that implements an interface as a slice of that interface.
 	mailEntries := []mailer.Mail{}
 	currentTime := time.Now().UTC()
-	campaignMailCtx, err := models.GetCampaignMailContext(c.Id, c.UserId)
-	if err != nil {
-		log.Error(err)
-		return
-	}
+	// Emails for campaign waves with their own template are sent using a
+	// separate context for each template.
+	campaignMailCtxs := map[int64]*models.Campaign{}
 	for _, m := range ms {
 		// Only send the emails scheduled to be sent for the past minute to
 		// respect the campaign scheduling options
@@ -137,13 +345,24 @@ func (w *DefaultWorker) LaunchCampaign(c models.Campaign) {
 			m.Unlock()
 			continue
 		}
-		err = m.CacheCampaign(&campaignMailCtx)
+		campaignMailCtx, ok := campaignMailCtxs[m.TemplateId]
+		if !ok {
+			mc, err := models.GetMailLogContext(m)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			campaignMailCtx = &mc
+			campaignMailCtxs[m.TemplateId] = campaignMailCtx
+		}
+		err = m.CacheCampaign(campaignMailCtx)
 		if err != nil {
 			log.Error(err)
 			return
 		}
 		mailEntries = append(mailEntries, m)
 	}
+	span.SetAttribute("emails", len(mailEntries))
 	w.mailer.Queue(mailEntries)
 }
 
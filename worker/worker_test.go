@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -161,3 +162,121 @@ func TestMailLogGrouping(t *testing.T) {
 		}
 	}
 }
+
+func TestMailLogGroupingBySendingProfile(t *testing.T) {
+	setupTest(t)
+	campaign, err := setupCampaign(0)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	smtp := models.SMTP{Name: "Backup", UserId: 1, Host: "backup.example.com", FromAddress: "backup@example.com"}
+	err = models.PostSMTP(&smtp)
+	if err != nil {
+		t.Fatalf("error creating sending profile: %v", err)
+	}
+	ms, err := models.GetMailLogsByCampaign(campaign.Id)
+	if err != nil {
+		t.Fatalf("error getting maillogs for campaign: %v", err)
+	}
+	// Half of the emails fail and are re-sent using the backup profile
+	for i, m := range ms {
+		if i%2 == 0 {
+			err = m.Error(errors.New("550 mailbox unavailable"))
+		} else {
+			err = m.Unlock()
+		}
+		if err != nil {
+			t.Fatalf("error updating maillog: %v", err)
+		}
+	}
+	_, err = models.ResendFailedEmails(campaign.Id, 1, models.ResendRequest{SMTP: "Backup"})
+	if err != nil {
+		t.Fatalf("error re-sending emails: %v", err)
+	}
+
+	lm := &logMailer{queue: make(chan []mailer.Mail)}
+	worker := &DefaultWorker{}
+	worker.mailer = lm
+	worker.processCampaigns(time.Now())
+
+	for i := 0; i < 2; i++ {
+		batch := <-lm.queue
+		expected := batch[0].(*models.MailLog).SMTPId
+		for _, m := range batch {
+			got := m.(*models.MailLog).SMTPId
+			if got != expected {
+				t.Fatalf("unexpected sending profile received for maillog: got %d expected %d", got, expected)
+			}
+		}
+	}
+}
+
+func TestMailLogBatchSize(t *testing.T) {
+	setupTest(t)
+	defer func(orig int) { BatchSize = orig }(BatchSize)
+	BatchSize = 1
+	campaign, err := setupCampaign(0)
+	if err != nil {
+		t.Fatalf("error creating campaign: %v", err)
+	}
+	ms, err := models.GetMailLogsByCampaign(campaign.Id)
+	if err != nil {
+		t.Fatalf("error getting maillogs for campaign: %v", err)
+	}
+	for _, m := range ms {
+		m.Unlock()
+	}
+
+	lm := &logMailer{queue: make(chan []mailer.Mail)}
+	worker := &DefaultWorker{}
+	worker.mailer = lm
+	worker.processCampaigns(time.Now())
+
+	batch := <-lm.queue
+	if len(batch) != 1 {
+		t.Fatalf("unexpected number of maillogs sent. expected %d got %d", 1, len(batch))
+	}
+	stats := worker.Stats()
+	if stats.LastPollMailLogs != 1 {
+		t.Fatalf("unexpected number of maillogs polled. expected %d got %d", 1, stats.LastPollMailLogs)
+	}
+	if stats.BatchSize != 1 {
+		t.Fatalf("unexpected batch size. expected %d got %d", 1, stats.BatchSize)
+	}
+}
+
+func TestMailLogPriority(t *testing.T) {
+	setupTest(t)
+	var urgent *models.Campaign
+	for i := 0; i < 2; i++ {
+		campaign, err := setupCampaign(i)
+		if err != nil {
+			t.Fatalf("error creating campaign: %v", err)
+		}
+		ms, err := models.GetMailLogsByCampaign(campaign.Id)
+		if err != nil {
+			t.Fatalf("error getting maillogs for campaign: %v", err)
+		}
+		for _, m := range ms {
+			m.Unlock()
+		}
+		urgent = campaign
+	}
+	err := models.SetCampaignPriority(urgent.Id, urgent.UserId, models.PriorityUrgent)
+	if err != nil {
+		t.Fatalf("error setting campaign priority: %v", err)
+	}
+
+	lm := &logMailer{queue: make(chan []mailer.Mail)}
+	worker := &DefaultWorker{}
+	worker.mailer = lm
+	worker.processCampaigns(time.Now())
+
+	for i, expected := range []bool{true, false} {
+		batch := <-lm.queue
+		got := batch[0].(*models.MailLog).CampaignId == urgent.Id
+		if got != expected {
+			t.Fatalf("unexpected campaign for batch %d. expected urgent %v got %v", i, expected, got)
+		}
+	}
+}